@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/internal/watchdog"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/schema"
+	"kafka-bridge/pkg/store"
+)
+
+// idempotencyHeader lets callers retry an admin mutation against any replica safely.
+const idempotencyHeader = "Idempotency-Key"
+
+// errBroadcast fails an admin mutation that was applied locally but not published to the
+// peer replicas.
+var errBroadcast = errors.New("broadcast to peer replicas failed")
+
+// adminDeps carries the state shared by the admin HTTP API.
+type adminDeps struct {
+	// bridge is the state of the routes the admin API serves.
+	*bridge
+	matchers map[string]*engine.Matcher
+	// routes holds the configuration of every route by route key.
+	routes map[string]config.Route
+	store  *store.MatchStore
+	// cfg supplies the consumer group IDs reported by GET /routes; nil in most tests.
+	cfg *config.Config
+	// pollers load referenceHTTP and referenceSQL values, by route key.
+	pollers map[string][]*referencePoller
+	// peers broadcasts mutations to other replicas; nil when coordination is disabled.
+	peers *kafkapkg.Coordinator
+	// schema reports payload drift; nil when schemaDrift is disabled.
+	schema *schema.Tracker
+	// writers is reported by /debug/vars; nil in tests that do not forward.
+	writers *delivery.Pool
+	// watchdog reports leak findings; nil when the watchdog is disabled.
+	watchdog *watchdog.Watchdog
+	// electing is set when leader election decides which replica collects references.
+	electing bool
+	// readOnly rejects every mutating endpoint while leaving inspection endpoints available.
+	readOnly bool
+	// audit records mutating calls; nil when audit is disabled.
+	audit *auditLog
+	// adminToken, when set, is required as a bearer token by mutating and debug endpoints.
+	adminToken string
+	// debug mounts the pprof and /debug/vars endpoints.
+	debug bool
+}
+
+// mutating guards a handler that changes state so it is refused in read-only mode and
+// requires the admin token when one is configured. Every call is audited.
+func (a adminDeps) mutating(h http.HandlerFunc) http.HandlerFunc {
+	return a.audited(a.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if a.readOnly {
+			http.Error(w, "admin API is read-only", http.StatusForbidden)
+			return
+		}
+		if !a.stateRecovery.ready() {
+			http.Error(w, "cache is still being restored from storage", http.StatusServiceUnavailable)
+			return
+		}
+		h(w, r)
+	}))
+}
+
+// authorized requires "Authorization: Bearer <adminToken>" when an admin token is configured.
+func (a adminDeps) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.adminToken != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="kafka-bridge"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// submit applies an admin mutation locally and, when coordination is enabled, broadcasts
+// it to peer replicas. Repeated idempotency keys are acknowledged without re-applying. A
+// key is released when its command fails to apply or to reach the peers, so the caller can
+// retry it; the retry applies it again, which leaves the cache as one application would.
+func (a adminDeps) submit(ctx context.Context, idempotencyKey string, cmd kafkapkg.Command) (changed bool, err error) {
+	defer func() { noteCommand(ctx, cmd, changed, err) }()
+	if cmd.Op == kafkapkg.CommandInject && a.memoryBudget.refusesAdds(cmd.Route) {
+		return false, errMemoryBudget
+	}
+	if a.peers == nil {
+		return a.apply(cmd)
+	}
+	cmd.ID = idempotencyKey
+	if cmd.ID == "" {
+		cmd.ID = kafkapkg.NewCommandID()
+	}
+	if !a.peers.Claim(cmd.ID) {
+		log.Printf("admin command %s already applied, skipping", cmd.ID)
+		return false, nil
+	}
+	changed, err = a.apply(cmd)
+	if err != nil {
+		a.peers.Release(cmd.ID)
+		return false, err
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := a.peers.Publish(publishCtx, cmd); err != nil {
+		a.peers.Release(cmd.ID)
+		return changed, fmt.Errorf("%w: command %s (%s): %v", errBroadcast, cmd.ID, cmd.Op, err)
+	}
+	return changed, nil
+}
+
+// apply executes a mutation against the local store. An empty route targets every route.
+func (a adminDeps) apply(cmd kafkapkg.Command) (bool, error) {
+	switch cmd.Op {
+	case kafkapkg.CommandClear:
+		removed := a.store.Clear()
+		log.Printf("cache cleared via admin command (%d fingerprints removed)", removed)
+		return removed > 0, nil
+	case kafkapkg.CommandInject, kafkapkg.CommandDelete:
+		targets, err := a.targets(cmd.Route)
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		for _, m := range targets {
+			if cmd.Op == kafkapkg.CommandInject && cmd.Meta != nil && m.AddValuesWithMeta(cmd.Values, *cmd.Meta) {
+				changed = true
+			}
+			if cmd.Op == kafkapkg.CommandInject && cmd.Meta == nil && m.AddAnnotatedValues(cmd.Values, cmd.Annotations) {
+				changed = true
+			}
+			if cmd.Op == kafkapkg.CommandDelete && m.RemoveValues(cmd.Values) {
+				changed = true
+			}
+		}
+		if len(cmd.Annotations) > 0 {
+			log.Printf("%s of %d value(s) on %s via admin command, annotations %s", cmd.Op, len(cmd.Values), routeLabel(cmd.Route), formatAnnotations(cmd.Annotations))
+		}
+		return changed, nil
+	case kafkapkg.CommandSplit:
+		if _, err := a.targets(cmd.Route); err != nil {
+			return false, err
+		}
+		if _, err := a.targets(cmd.Target); err != nil || cmd.Target == "" || cmd.Target == cmd.Route {
+			return false, fmt.Errorf("split target %q must be another configured route", cmd.Target)
+		}
+		cloned := splitRoute(a.store, cmd.Route, cmd.Target, cmd.Values)
+		log.Printf("split %s into %s via admin command (%d values cloned)", cmd.Route, cmd.Target, cloned)
+		return cloned > 0, nil
+	default:
+		return false, fmt.Errorf("unknown admin command %q", cmd.Op)
+	}
+}
+
+func (a adminDeps) targets(routeID string) ([]*engine.Matcher, error) {
+	if routeID == "" {
+		out := make([]*engine.Matcher, 0, len(a.matchers))
+		for _, m := range a.matchers {
+			out = append(out, m)
+		}
+		return out, nil
+	}
+	m, ok := a.matchers[routeID]
+	if !ok {
+		return nil, fmt.Errorf("route %s not found", routeID)
+	}
+	return []*engine.Matcher{m}, nil
+}
+
+// applyPeerCommand applies a command broadcast by another replica.
+func (a adminDeps) applyPeerCommand(cmd kafkapkg.Command) {
+	if _, err := a.apply(cmd); err != nil {
+		log.Printf("peer command %s from %s failed: %v", cmd.ID, cmd.Origin, err)
+		return
+	}
+	log.Printf("applied %s command %s from peer %s", cmd.Op, cmd.ID, cmd.Origin)
+}
+
+func routeLabel(routeID string) string {
+	if routeID == "" {
+		return "all routes"
+	}
+	return routeID
+}
+
+// formatAnnotations renders annotations as sorted key=value pairs for logs.
+func formatAnnotations(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+strconv.Quote(annotations[k]))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestReadOnlyAdminRejectsMutations(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"value1"})
+
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b,
+		matchers: map[string]*engine.Matcher{"route-a": matcher},
+		store:    matchStore,
+		readOnly: true,
+	}))
+	t.Cleanup(server.Close)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{method: http.MethodPost, path: "/cache/clear", status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/cache/compact", status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/referenceAllRoutes", body: `["v"]`, status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/reference/route-a", body: `["v"]`, status: http.StatusForbidden},
+		{method: http.MethodDelete, path: "/reference/route-a", body: `["value1"]`, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/cache", status: http.StatusOK},
+		{method: http.MethodPost, path: "/routes/route-a/test", body: `{"payload":{"a":"value1"}}`, status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.status, resp.StatusCode)
+		}
+	}
+	if got := matchStore.Size("route-a"); got != 1 {
+		t.Fatalf("expected cache to be untouched, size=%d", got)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b,
+		matchers: map[string]*engine.Matcher{"route-a": matcher},
+		routes:   map[string]config.Route{"route-a": {Name: "route-a", Annotations: map[string]string{"owner": "payments"}}},
+		store:    matchStore,
+	}))
+	t.Cleanup(server.Close)
+
+	body := `{"values":["value1"],"annotations":{"ticket":"INC-42","reason":"fraud review"}}`
+	resp, err := http.Post(server.URL+"/reference/route-a", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /reference/route-a failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", resp.StatusCode)
+	}
+	resp, err = http.Post(server.URL+"/reference/route-a", "application/json", strings.NewReader(`{"annotations":{"ticket":"INC-43"}}`))
+	if err != nil {
+		t.Fatalf("POST /reference/route-a failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400 without values, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/cache/route-a")
+	if err != nil {
+		t.Fatalf("GET /cache/route-a failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var got RouteCache
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Annotations["owner"] != "payments" {
+		t.Fatalf("expected route annotations, got %v", got.Annotations)
+	}
+	if len(got.Values) != 1 || got.Values[0].Origin.Annotations["ticket"] != "INC-42" || got.Values[0].Origin.Annotations["reason"] != "fraud review" {
+		t.Fatalf("expected value annotations, got %+v", got.Values)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/archive"
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+// archiveFlushTimeout bounds the upload of the last archive object on shutdown.
+const archiveFlushTimeout = 30 * time.Second
+
+// startArchive runs route's archive sink until ctx is done or stop is called. stop uploads
+// the object still open; call it once the route has stopped writing.
+func startArchive(ctx context.Context, route config.Route) (*archive.Sink, func()) {
+	a := route.Archive
+	s3 := &archive.S3{
+		Bucket:          a.Bucket,
+		Region:          a.Region,
+		Endpoint:        a.Endpoint,
+		PathStyle:       a.PathStyle,
+		AccessKeyID:     a.AccessKeyID,
+		SecretAccessKey: a.SecretAccessKey,
+		SessionToken:    a.SessionToken,
+	}
+	sink := archive.NewSink(s3, a.Prefix+routeKey(route)+"/", a.MaxObjectBytes, a.MaxObjectAge)
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sink.Run(runCtx)
+	}()
+	return sink, func() {
+		cancel()
+		<-done
+		flushCtx, cancel := context.WithTimeout(context.Background(), archiveFlushTimeout)
+		defer cancel()
+		if err := sink.Flush(flushCtx); err != nil {
+			log.Printf("route %s: archive: %v", route.DisplayName(), err)
+		}
+	}
+}
+
+// archiveWriter copies every message its destination accepted to an archive sink.
+type archiveWriter struct {
+	delivery.MessageWriter
+	sink        *archive.Sink
+	route       string
+	destination string
+}
+
+// WriteMessages archives the messages of msgs the destination accepted, including those of
+// a partly failed write, and returns the destination's error. A failure to archive is
+// logged rather than returned, since Deliver would write the delivered messages again.
+func (w archiveWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	err := w.MessageWriter.WriteMessages(ctx, msgs...)
+	records := make([]archive.Record, 0, len(msgs))
+	for i, msg := range msgs {
+		if delivery.MessageError(err, len(msgs), i) == nil {
+			records = append(records, archive.NewRecord(w.route, w.destination, msg))
+		}
+	}
+	if len(records) > 0 {
+		if archiveErr := w.sink.Add(ctx, records...); archiveErr != nil {
+			log.Printf("warn: route %s: archive %d message(s) written to %s: %v", w.route, len(records), w.destination, archiveErr)
+		}
+	}
+	return err
+}
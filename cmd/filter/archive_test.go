@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/archive"
+	"kafka-bridge/pkg/delivery"
+)
+
+// uploaderFunc adapts a function to archive.Uploader.
+type uploaderFunc func(ctx context.Context, key string, body []byte, contentType string) error
+
+func (f uploaderFunc) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	return f(ctx, key, body, contentType)
+}
+
+func TestArchiveWriterArchivesAcceptedMessages(t *testing.T) {
+	var archived []int64
+	sink := archive.NewSink(uploaderFunc(func(_ context.Context, _ string, body []byte, _ string) error {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		lines := bufio.NewScanner(zr)
+		for lines.Scan() {
+			var rec archive.Record
+			if err := json.Unmarshal(lines.Bytes(), &rec); err != nil {
+				return err
+			}
+			archived = append(archived, rec.Offset)
+		}
+		return lines.Err()
+	}), "archive/", 1<<20, time.Hour)
+
+	// the destination fails offset 1 once, as a multi-partition write whose leader moved would
+	notLeader := errors.New("not leader")
+	var written []int64
+	failed := false
+	destination := writerFunc(func(_ context.Context, msgs ...kafka.Message) error {
+		errs := make(kafka.WriteErrors, len(msgs))
+		for i, msg := range msgs {
+			if msg.Offset == 1 && !failed {
+				failed = true
+				errs[i] = notLeader
+				continue
+			}
+			written = append(written, msg.Offset)
+		}
+		if errs.Count() == 0 {
+			return nil
+		}
+		return errs
+	})
+	w := archiveWriter{MessageWriter: destination, sink: sink, route: "route-a", destination: "out"}
+	msgs := []kafka.Message{{Offset: 0, Value: []byte(`{}`)}, {Offset: 1, Value: []byte(`{}`)}, {Offset: 2, Value: []byte(`{}`)}}
+	if err := delivery.Deliver(context.Background(), w, delivery.RetryPolicy{InitialBackoff: time.Millisecond}, msgs...); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	slices.Sort(written)
+	slices.Sort(archived)
+	if want := []int64{0, 1, 2}; !slices.Equal(written, want) || !slices.Equal(archived, want) {
+		t.Fatalf("written %v and archived %v, want each of %v once", written, archived, want)
+	}
+
+	// an archive that cannot take the records does not fail a write the destination accepted
+	sink = archive.NewSink(uploaderFunc(func(context.Context, string, []byte, string) error {
+		return errors.New("store unavailable")
+	}), "archive/", 1, time.Hour)
+	if err := sink.Add(context.Background(), archive.NewRecord("route-a", "out", msgs[0])); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	recorder := &recordingWriter{}
+	w = archiveWriter{MessageWriter: recorder, sink: sink, route: "route-a", destination: "out"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.WriteMessages(ctx, msgs[1]); err != nil {
+		t.Fatalf("archive failure returned as a write failure: %v", err)
+	}
+	if len(recorder.written) != 1 {
+		t.Fatalf("destination got %d message(s), want 1", len(recorder.written))
+	}
+}
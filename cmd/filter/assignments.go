@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/metrics"
+)
+
+// Readers of a route whose partition assignments are tracked.
+const (
+	readerSource    = "source"
+	readerReference = "reference"
+)
+
+type assignmentRegistry struct {
+	mu      sync.Mutex
+	readers map[assignmentKey]*ReaderAssignment
+}
+
+type assignmentKey struct {
+	route  string
+	reader string
+}
+
+// watch sets the logger of rc to record the assignments of the reader of routeID, and
+// returns the func that forgets them once the reader is closed.
+func (r *assignmentRegistry) watch(rc *kafka.ReaderConfig, routeID, reader string) func() {
+	key := assignmentKey{route: routeID, reader: reader}
+	r.mu.Lock()
+	r.readers[key] = &ReaderAssignment{Reader: reader, GroupID: rc.GroupID, Partitions: []AssignedPartition{}}
+	r.mu.Unlock()
+	rc.Logger = kafka.LoggerFunc(func(format string, args ...any) { r.observe(key, format, args) })
+	return func() {
+		r.mu.Lock()
+		a := r.readers[key]
+		delete(r.readers, key)
+		r.mu.Unlock()
+		if a != nil && len(a.Partitions) > 0 {
+			log.Printf("event: route %s %s reader of group %s closed; released %s", routeID, reader, a.GroupID, formatPartitions(a.Partitions))
+		}
+	}
+}
+
+// observe updates the assignment of key from one kafka-go log line: the group join
+// reports the generation, the subscription the partitions of that generation.
+func (r *assignmentRegistry) observe(key assignmentKey, format string, args []any) {
+	switch {
+	case strings.EqualFold(format, "joined group %s as member %s in generation %d") && len(args) == 3:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if a := r.readers[key]; a != nil {
+			a.MemberID, _ = args[1].(string)
+			if gen := reflect.ValueOf(args[2]); gen.CanInt() {
+				a.Generation = int(gen.Int())
+			}
+		}
+	case format == "subscribed to topics and partitions: %+v" && len(args) == 1:
+		r.assign(key, subscribedPartitions(args[0]), time.Now())
+	}
+}
+
+// assign records the partitions of a new generation and logs what it added and revoked.
+func (r *assignmentRegistry) assign(key assignmentKey, partitions []AssignedPartition, now time.Time) {
+	r.mu.Lock()
+	a := r.readers[key]
+	if a == nil {
+		r.mu.Unlock()
+		return
+	}
+	held := make(map[string]bool, len(a.Partitions))
+	for _, p := range a.Partitions {
+		held[partitionName(p)] = true
+	}
+	var assigned, kept []AssignedPartition
+	for _, p := range partitions {
+		if held[partitionName(p)] {
+			kept = append(kept, p)
+			delete(held, partitionName(p))
+		} else {
+			assigned = append(assigned, p)
+		}
+	}
+	var revoked []AssignedPartition
+	for _, p := range a.Partitions {
+		if held[partitionName(p)] {
+			revoked = append(revoked, p)
+		}
+	}
+	a.Partitions, a.AssignedAt = partitions, now
+	a.Rebalances++
+	group, generation, member := a.GroupID, a.Generation, a.MemberID
+	r.mu.Unlock()
+
+	log.Printf("event: route %s %s reader of group %s joined generation %d as %s: assigned %s, revoked %s, kept %d partition(s)",
+		key.route, key.reader, group, generation, member, formatPartitions(assigned), formatPartitions(revoked), len(kept))
+}
+
+// route returns the assignments of the readers of routeID, source reader first.
+func (r *assignmentRegistry) route(routeID string) []ReaderAssignment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	readers := []ReaderAssignment{}
+	for _, reader := range []string{readerSource, readerReference} {
+		if a := r.readers[assignmentKey{route: routeID, reader: reader}]; a != nil {
+			cp := *a
+			cp.Partitions = append([]AssignedPartition{}, a.Partitions...)
+			readers = append(readers, cp)
+		}
+	}
+	return readers
+}
+
+func (r *assignmentRegistry) metrics() []metrics.Family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]assignmentKey, 0, len(r.readers))
+	for k := range r.readers {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].reader < keys[j].reader
+	})
+
+	assigned := metrics.Family{Name: "kafka_bridge_route_assigned_partitions", Help: "Partitions currently assigned to the reader.", Type: metrics.TypeGauge}
+	rebalances := metrics.Family{Name: "kafka_bridge_route_rebalances_total", Help: "Partition assignments the reader received since it was opened.", Type: metrics.TypeCounter}
+	for _, k := range keys {
+		labels := metrics.Labels{"route": k.route, "reader": k.reader}
+		assigned.Add(labels, float64(len(r.readers[k].Partitions)))
+		rebalances.Add(labels, float64(r.readers[k].Rebalances))
+	}
+	return []metrics.Family{assigned, rebalances}
+}
+
+// subscribedPartitions reads the map of topic and partition to start offset that kafka-go
+// logs on subscription. Its key type is unexported, so the fields are read by name.
+func subscribedPartitions(arg any) []AssignedPartition {
+	partitions := []AssignedPartition{}
+	v := reflect.ValueOf(arg)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.Struct {
+		return partitions
+	}
+	for it := v.MapRange(); it.Next(); {
+		topic, partition := it.Key().FieldByName("topic"), it.Key().FieldByName("partition")
+		if topic.Kind() != reflect.String || !partition.CanInt() || !it.Value().CanInt() {
+			continue
+		}
+		partitions = append(partitions, AssignedPartition{Topic: topic.String(), Partition: int(partition.Int()), StartOffset: it.Value().Int()})
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+	return partitions
+}
+
+func partitionName(p AssignedPartition) string {
+	return fmt.Sprintf("%s/%d", p.Topic, p.Partition)
+}
+
+// formatPartitions renders partitions as topic[0,1] lists, or none.
+func formatPartitions(partitions []AssignedPartition) string {
+	if len(partitions) == 0 {
+		return "none"
+	}
+	var topics []string
+	byTopic := make(map[string][]string)
+	for _, p := range partitions {
+		if _, ok := byTopic[p.Topic]; !ok {
+			topics = append(topics, p.Topic)
+		}
+		byTopic[p.Topic] = append(byTopic[p.Topic], fmt.Sprint(p.Partition))
+	}
+	out := make([]string, 0, len(topics))
+	for _, t := range topics {
+		out = append(out, t+"["+strings.Join(byTopic[t], ",")+"]")
+	}
+	return strings.Join(out, " ")
+}
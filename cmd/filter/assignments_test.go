@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestAssignmentLoggerTracksRebalances(t *testing.T) {
+	b := newBridge(config.Logging{})
+	// the key type kafka-go logs subscriptions with, which is unexported there
+	type topicPartition struct {
+		topic     string
+		partition int32
+	}
+	rc := kafka.ReaderConfig{GroupID: "bridge-orders"}
+	release := b.routeAssignments.watch(&rc, "orders", readerSource)
+	subscribe := func(generation int32, offsets map[topicPartition]int64) {
+		rc.Logger.Printf("Joined group %s as member %s in generation %d", rc.GroupID, "member-1", generation)
+		rc.Logger.Printf("subscribed to topics and partitions: %+v", offsets)
+	}
+	subscribe(1, map[topicPartition]int64{{"src", 1}: 40, {"src", 0}: 12})
+	subscribe(2, map[topicPartition]int64{{"src", 1}: 41})
+
+	admin := adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"orders": nil}, store: store.NewMatchStore()}
+	rec := httptest.NewRecorder()
+	buildHTTPMux(admin).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/routes/orders/assignments", nil))
+	var resp RouteAssignments
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	if len(resp.Readers) != 1 {
+		t.Fatalf("unexpected assignments %+v", resp)
+	}
+	got := resp.Readers[0]
+	want := []AssignedPartition{{Topic: "src", Partition: 1, StartOffset: 41}}
+	if got.Reader != readerSource || got.MemberID != "member-1" || got.Generation != 2 || got.Rebalances != 2 || !reflect.DeepEqual(got.Partitions, want) {
+		t.Fatalf("unexpected source assignment %+v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf, b.routeAssignments.metrics()); err != nil {
+		t.Fatal(err)
+	}
+	if want := `kafka_bridge_route_assigned_partitions{reader="source",route="orders"} 1`; !strings.Contains(buf.String(), want) {
+		t.Fatalf("metrics missing %s:\n%s", want, buf.String())
+	}
+	release()
+	if readers := b.routeAssignments.route("orders"); len(readers) != 0 {
+		t.Fatalf("closed reader still reported: %+v", readers)
+	}
+}
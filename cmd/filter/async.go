@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+)
+
+// defaultAsyncInFlight bounds the uncommitted source messages of an async route without
+// a maxInFlight of its own.
+const defaultAsyncInFlight = 1000
+
+// streamAsync is the read loop of a route with delivery.async. Each source message is
+// forwarded without waiting for the brokers to acknowledge its write, and its offset is
+// committed once it and every message fetched before it from its partition are complete:
+// skipped, or forwarded and acknowledged. A failed write stops the route, which then
+// resumes from the last committed offset, so messages are redelivered rather than lost;
+// the pool writes none of the messages queued behind a failed write, so none overtakes a
+// redelivered one.
+// At most maxInFlight source messages, or defaultAsyncInFlight, are uncommitted at once.
+func streamAsync(ctx context.Context, reader sourceReader, forward func(context.Context, kafka.Message) error, stats *routeStats, limit int) error {
+	if limit <= 0 {
+		limit = defaultAsyncInFlight
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	acks := newAckTracker(limit, func(err error) { cancel(err) })
+
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-acks.ready:
+			}
+			msgs, n, bytes := acks.completed()
+			if len(msgs) == 0 {
+				continue
+			}
+			if err := reader.CommitMessages(ctx, msgs...); err != nil {
+				cancel(fmt.Errorf("commit offset %d: %w", msgs[len(msgs)-1].Offset, err))
+				return
+			}
+			stats.inFlight.Add(-int64(n))
+			stats.inFlightBytes.Add(-bytes)
+		}
+	}()
+	defer func() {
+		cancel(nil)
+		<-committed
+	}()
+
+	for {
+		if err := acks.waitForRoom(ctx); err != nil {
+			return context.Cause(ctx)
+		}
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+		stats.consumed.Add(1)
+		stats.inFlight.Add(1)
+		stats.inFlightBytes.Add(messageBytes(msg))
+		pending := acks.add(msg)
+		if err := forward(delivery.WithCompletion(ctx, pending), msg); err != nil {
+			return err
+		}
+		acks.dispatched(pending)
+	}
+}
+
+// ackTracker holds the fetched source messages of an async route per topic partition, in
+// fetch order, until they are complete and committed.
+type ackTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition][]*pendingAck
+	count      int
+	limit      int
+	// room is signalled when a message is committed.
+	room chan struct{}
+	// ready is signalled when a message completes.
+	ready chan struct{}
+	fail  func(error)
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+func newAckTracker(limit int, fail func(error)) *ackTracker {
+	return &ackTracker{
+		partitions: make(map[topicPartition][]*pendingAck),
+		limit:      limit,
+		room:       make(chan struct{}, 1),
+		ready:      make(chan struct{}, 1),
+		fail:       fail,
+	}
+}
+
+// pendingAck is the delivery.Completion of one source message.
+type pendingAck struct {
+	tracker *ackTracker
+	msg     kafka.Message
+	// writes counts the message's writes not yet finished; dispatched is set once
+	// forwarding returned, so writes cannot reach zero before all were started. A failed
+	// message is never committed.
+	writes     int
+	dispatched bool
+	failed     bool
+}
+
+func (t *ackTracker) add(msg kafka.Message) *pendingAck {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := &pendingAck{tracker: t, msg: msg}
+	key := topicPartition{msg.Topic, msg.Partition}
+	t.partitions[key] = append(t.partitions[key], p)
+	t.count++
+	return p
+}
+
+func (t *ackTracker) dispatched(p *pendingAck) {
+	t.mu.Lock()
+	p.dispatched = true
+	done := p.writes == 0
+	t.mu.Unlock()
+	if done {
+		wake(t.ready)
+	}
+}
+
+func (p *pendingAck) Started() {
+	p.tracker.mu.Lock()
+	defer p.tracker.mu.Unlock()
+	p.writes++
+}
+
+func (p *pendingAck) Finished(err error) {
+	t := p.tracker
+	t.mu.Lock()
+	p.writes--
+	p.failed = p.failed || err != nil
+	done := p.writes == 0 && p.dispatched
+	t.mu.Unlock()
+	if err != nil {
+		t.fail(fmt.Errorf("write offset %d of partition %d: %w", p.msg.Offset, p.msg.Partition, err))
+		return
+	}
+	if done {
+		wake(t.ready)
+	}
+}
+
+// completed removes the complete messages at the head of every partition and returns
+// the last of each to commit, with how many messages and source bytes it removed.
+func (t *ackTracker) completed() ([]kafka.Message, int, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var commit []kafka.Message
+	var n int
+	var bytes int64
+	for partition, queue := range t.partitions {
+		i := 0
+		for i < len(queue) && queue[i].dispatched && queue[i].writes == 0 && !queue[i].failed {
+			bytes += messageBytes(queue[i].msg)
+			i++
+		}
+		if i == 0 {
+			continue
+		}
+		commit = append(commit, queue[i-1].msg)
+		n += i
+		if i == len(queue) {
+			delete(t.partitions, partition)
+		} else {
+			t.partitions[partition] = queue[i:]
+		}
+	}
+	if n > 0 {
+		t.count -= n
+		wake(t.room)
+	}
+	return commit, n, bytes
+}
+
+// waitForRoom blocks while the tracker holds its limit of uncommitted messages.
+func (t *ackTracker) waitForRoom(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		full := t.count >= t.limit
+		t.mu.Unlock()
+		if !full {
+			return nil
+		}
+		select {
+		case <-t.room:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// wake wakes the waiter of ch without blocking when it is already woken.
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+)
+
+// asyncWriter records the completions of the messages written to it, as an async Kafka
+// writer would until the brokers respond.
+type asyncWriter struct {
+	mu      sync.Mutex
+	pending map[string]delivery.Completion
+}
+
+func (w *asyncWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	c, _ := delivery.CompletionFrom(ctx)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, msg := range msgs {
+		c.Started()
+		w.pending[fmt.Sprintf("%d/%d", msg.Partition, msg.Offset)] = c
+	}
+	return nil
+}
+
+func (w *asyncWriter) finish(key string, err error) {
+	w.mu.Lock()
+	c := w.pending[key]
+	w.mu.Unlock()
+	c.Finished(err)
+}
+
+func TestStreamAsyncCommitsAcknowledgedPrefix(t *testing.T) {
+	w := &asyncWriter{pending: make(map[string]delivery.Completion)}
+	forward := func(ctx context.Context, msg kafka.Message) error {
+		if msg.Offset%2 == 1 {
+			return nil // skipped, complete at once
+		}
+		return w.WriteMessages(ctx, msg)
+	}
+	reader := &scriptedReader{msgs: []kafka.Message{{Partition: 0, Offset: 0}, {Partition: 0, Offset: 1}, {Partition: 0, Offset: 2}, {Partition: 1, Offset: 0}}}
+	stats := &routeStats{}
+	done := make(chan error, 1)
+	go func() { done <- streamAsync(context.Background(), reader, forward, stats, 10) }()
+
+	waitFor := func(inFlight int64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for stats.inFlight.Load() != inFlight {
+			if time.Now().After(deadline) {
+				t.Fatalf("in flight = %d, want %d", stats.inFlight.Load(), inFlight)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(4)
+	// partition 0 cannot commit past offset 0 until it is acknowledged
+	w.finish("0/2", nil)
+	w.finish("1/0", nil)
+	waitFor(3)
+	w.finish("0/0", errors.New("not enough replicas"))
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "write offset 0 of partition 0: not enough replicas") {
+			t.Fatalf("expected the failed write to stop the route, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("streamAsync did not stop after a failed write")
+	}
+	if len(reader.committed) != 1 || len(reader.committed[0]) != 1 || reader.committed[0][0].Partition != 1 {
+		t.Fatalf("expected only partition 1 committed, got %v", reader.committed)
+	}
+
+	// maxInFlight stops fetching while that many messages are unacknowledged
+	reader = &scriptedReader{msgs: []kafka.Message{{Offset: 0}, {Offset: 2}, {Offset: 4}}}
+	stats = &routeStats{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := streamAsync(ctx, reader, forward, stats, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("streamAsync: %v", err)
+	}
+	if n := stats.consumed.Load(); n != 2 {
+		t.Fatalf("expected 2 messages fetched under maxInFlight 2, got %d", n)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+)
+
+// auditRecord describes one mutating admin call. Calls refused before reaching a handler,
+// by the admin token or read-only mode, are recorded too, with no op.
+type auditRecord struct {
+	At       time.Time `json:"at"`
+	Instance string    `json:"instance,omitempty"`
+	// API is http or grpc; Endpoint is the method and path, or the full gRPC method.
+	API        string `json:"api"`
+	Endpoint   string `json:"endpoint"`
+	Caller     string `json:"caller,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Op is the admin command the call issued: inject, delete, clear, split, or compact.
+	Op     string `json:"op,omitempty"`
+	Route  string `json:"route,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Values counts the reference values the call added or removed, summed over the
+	// commands of an import.
+	Values         int               `json:"values"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	Changed        bool              `json:"changed"`
+	// Status is the HTTP status code or the gRPC status code name.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// auditLog writes audit records to the log and, when audit.topic is set, to Kafka.
+type auditLog struct {
+	instance       string
+	identityHeader string
+	// topic is nil unless audit.topic is set.
+	topic delivery.MessageWriter
+}
+
+func newAuditLog(cfg *config.Config, writers *delivery.Pool) *auditLog {
+	if !cfg.Audit.Enabled {
+		return nil
+	}
+	l := &auditLog{instance: cfg.LoopPrevention.BridgeID, identityHeader: cfg.Audit.IdentityHeader}
+	if cfg.Audit.Topic != "" {
+		l.topic = writers.Topic(cfg.Audit.Topic)
+	}
+	return l
+}
+
+// write emits rec. A failed Kafka write is logged; the record is still in the log.
+func (l *auditLog) write(rec *auditRecord) {
+	rec.At = time.Now().UTC()
+	rec.Instance = l.instance
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("audit record encode failed: %v", err)
+		return
+	}
+	log.Printf("audit: %s", raw)
+	if l.topic == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.topic.WriteMessages(ctx, kafka.Message{Key: []byte(rec.Route), Value: raw}); err != nil {
+		log.Printf("warn: audit record for %s not published: %v", rec.Endpoint, err)
+	}
+}
+
+type auditContextKey struct{}
+
+// auditFrom returns the record of the admin call ctx belongs to, or nil when auditing is
+// disabled.
+func auditFrom(ctx context.Context) *auditRecord {
+	rec, _ := ctx.Value(auditContextKey{}).(*auditRecord)
+	return rec
+}
+
+// noteCommand adds cmd, as submitted, to the call's audit record.
+func noteCommand(ctx context.Context, cmd kafkapkg.Command, changed bool, err error) {
+	rec := auditFrom(ctx)
+	if rec == nil {
+		return
+	}
+	rec.Op, rec.Route, rec.Target = cmd.Op, cmd.Route, cmd.Target
+	if cmd.Op == kafkapkg.CommandInject || cmd.Op == kafkapkg.CommandDelete {
+		rec.Values += len(cmd.Values)
+	}
+	if len(cmd.Annotations) > 0 {
+		rec.Annotations = cmd.Annotations
+	}
+	rec.Changed = rec.Changed || changed
+	if err != nil {
+		rec.Error = err.Error()
+	}
+}
+
+// audited records every call of the HTTP handler h once it returns.
+func (a adminDeps) audited(h http.HandlerFunc) http.HandlerFunc {
+	if a.audit == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &auditRecord{
+			API:            "http",
+			Endpoint:       r.Method + " " + r.URL.Path,
+			RemoteAddr:     r.RemoteAddr,
+			IdempotencyKey: r.Header.Get(idempotencyHeader),
+		}
+		if a.audit.identityHeader != "" {
+			rec.Caller = r.Header.Get(a.audit.identityHeader)
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h(sw, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, rec)))
+		rec.Status = strconv.Itoa(sw.status)
+		a.audit.write(rec)
+	}
+}
+
+// auditGRPC starts the audit record of a gRPC mutation. Call finish with the error the
+// handler returns.
+func (a adminDeps) auditGRPC(ctx context.Context, idempotencyKey string) (context.Context, func(error)) {
+	if a.audit == nil {
+		return ctx, func(error) {}
+	}
+	rec := &auditRecord{API: "grpc", IdempotencyKey: idempotencyKey}
+	rec.Endpoint, _ = grpc.Method(ctx)
+	if p, ok := peer.FromContext(ctx); ok {
+		rec.RemoteAddr = p.Addr.String()
+	}
+	if a.audit.identityHeader != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		rec.Caller = strings.Join(md.Get(a.audit.identityHeader), ",")
+	}
+	return context.WithValue(ctx, auditContextKey{}, rec), func(err error) {
+		rec.Status = status.Code(err).String()
+		if err != nil && rec.Error == "" {
+			rec.Error = status.Convert(err).Message()
+		}
+		a.audit.write(rec)
+	}
+}
+
+// statusWriter remembers the status code a handler responded with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestAdminMutationsAreAudited(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	topic := &recordingWriter{}
+	admin := adminDeps{bridge: b,
+		matchers:   map[string]*engine.Matcher{"route-a": matcher},
+		store:      matchStore,
+		adminToken: "s3cret",
+		audit:      &auditLog{instance: "bridge-1", identityHeader: "X-Forwarded-User", topic: topic},
+	}
+	server := httptest.NewServer(buildHTTPMux(admin))
+	t.Cleanup(server.Close)
+
+	post := func(path, token, body string) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		req.Header.Set("X-Forwarded-User", "alice@example.com")
+		req.Header.Set(idempotencyHeader, "key-1")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	post("/reference/route-a", "s3cret", `{"values":["one","two"],"annotations":{"ticket":"SEC-1"}}`)
+	post("/cache/clear", "", "")
+	if _, err := http.Get(server.URL + "/cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topic.written) != 2 {
+		t.Fatalf("expected two audit records, got %d", len(topic.written))
+	}
+	var added, refused auditRecord
+	if err := json.Unmarshal(topic.written[0].Value, &added); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(topic.written[1].Value, &refused); err != nil {
+		t.Fatal(err)
+	}
+	if added.API != "http" || added.Endpoint != "POST /reference/route-a" || added.Caller != "alice@example.com" || added.Op != kafkapkg.CommandInject ||
+		added.Route != "route-a" || added.Values != 2 || added.Annotations["ticket"] != "SEC-1" || !added.Changed || added.Status != "201" ||
+		added.IdempotencyKey != "key-1" || added.Instance != "bridge-1" || added.RemoteAddr == "" || added.At.IsZero() {
+		t.Fatalf("unexpected record: %+v", added)
+	}
+	if string(topic.written[0].Key) != "route-a" {
+		t.Fatalf("audit record key = %q", topic.written[0].Key)
+	}
+	if refused.Endpoint != "POST /cache/clear" || refused.Op != "" || refused.Status != "401" {
+		t.Fatalf("unexpected record of the refused call: %+v", refused)
+	}
+}
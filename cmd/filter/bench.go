@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// benchSettings are the flags of `filter bench`.
+type benchSettings struct {
+	values    int
+	messages  int
+	matchRate float64
+	fields    int
+	workers   int
+	strategy  string
+	prefilter bool
+	legacy    bool
+
+	brokers        string
+	referenceTopic string
+	sourceTopic    string
+	batch          int
+}
+
+// runBench implements `filter bench`: it generates synthetic reference values and source
+// payloads and either matches them in process, against an in-memory store, or, with
+// -brokers, produces them to a reference and a source topic for a running bridge to
+// consume. It reports throughput, latency percentiles, and allocations.
+func runBench(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var b benchSettings
+	fs.IntVar(&b.values, "values", 100000, "reference values to cache")
+	fs.IntVar(&b.messages, "messages", 1000000, "source messages to generate")
+	fs.Float64Var(&b.matchRate, "match-rate", 0.1, "fraction of source messages that hold a cached value")
+	fs.IntVar(&b.fields, "fields", 10, "scalar fields per source payload")
+	fs.IntVar(&b.workers, "workers", runtime.GOMAXPROCS(0), "concurrent matchers or producers")
+	fs.StringVar(&b.strategy, "strategy", engine.MatchStrategyFields, "matchStrategy: fields or bytesContains")
+	fs.BoolVar(&b.prefilter, "prefilter", false, "prefilter payloads as a route with prefilter: true does")
+	fs.BoolVar(&b.legacy, "legacy-decode", false, "decode payloads into maps as legacyJsonDecode does")
+	fs.StringVar(&b.brokers, "brokers", "", "comma-separated brokers to produce the traffic to instead of matching in process")
+	fs.StringVar(&b.referenceTopic, "reference-topic", "bench-reference", "topic reference records are produced to, with -brokers")
+	fs.StringVar(&b.sourceTopic, "source-topic", "bench-source", "topic source messages are produced to, with -brokers")
+	fs.IntVar(&b.batch, "batch", 100, "messages per produce call, with -brokers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if b.values <= 0 || b.messages <= 0 || b.workers <= 0 || b.fields <= 0 || b.batch <= 0 {
+		return errors.New("-values, -messages, -fields, -workers, and -batch must be positive")
+	}
+	if b.matchRate < 0 || b.matchRate > 1 {
+		return errors.New("-match-rate must be between 0 and 1")
+	}
+	if b.strategy != engine.MatchStrategyFields && b.strategy != engine.MatchStrategyBytesContains {
+		return fmt.Errorf("unknown -strategy %q (want fields or bytesContains)", b.strategy)
+	}
+	payloads := benchPayloads(b)
+	if b.brokers != "" {
+		return benchProduce(b, payloads, out)
+	}
+	return benchMatch(b, payloads, out)
+}
+
+// benchValue is the i-th synthetic reference value.
+func benchValue(i int) string {
+	return fmt.Sprintf("ACC-%08d", i)
+}
+
+// benchPayloads returns a pool of source payloads, cycled through by the run, of which
+// about matchRate hold a cached value.
+func benchPayloads(b benchSettings) [][]byte {
+	rng := rand.New(rand.NewSource(1))
+	payloads := make([][]byte, min(b.messages, 4096))
+	for i := range payloads {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, `{"id":"evt-%d","at":"%s"`, i, time.Unix(1700000000+int64(i), 0).UTC().Format(time.RFC3339))
+		for f := 0; f < b.fields-3; f++ {
+			fmt.Fprintf(&sb, `,"attr%d":"value-%d-%d"`, f, f, rng.Intn(1000))
+		}
+		account := fmt.Sprintf("MISS-%08d", rng.Intn(b.values))
+		if rng.Float64() < b.matchRate {
+			account = benchValue(rng.Intn(b.values))
+		}
+		fmt.Fprintf(&sb, `,"customer":{"account":%q}}`, account)
+		payloads[i] = []byte(sb.String())
+	}
+	return payloads
+}
+
+// benchMatch loads the values into an in-memory store and matches the messages with
+// b.workers goroutines sharing one matcher, as a route's partitions do.
+func benchMatch(b benchSettings, payloads [][]byte, out io.Writer) error {
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("bench", []engine.Feed{{Topic: b.referenceTopic, MatchFields: []string{"account"}}}, matchStore,
+		engine.WithMatchStrategy(b.strategy), engine.WithPrefilter(b.prefilter), engine.WithLegacyDecode(b.legacy))
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for i := 0; i < b.values; i++ {
+		value := fmt.Sprintf(`{"account":%q}`, benchValue(i))
+		if _, err := matcher.ProcessReference(engine.ReferenceMessage{Topic: b.referenceTopic, Offset: int64(i), Value: []byte(value)}); err != nil {
+			return fmt.Errorf("reference %d: %w", i, err)
+		}
+	}
+	loaded := time.Since(start)
+	fmt.Fprintf(out, "reference: %d values in %s (%.0f values/sec)\n", b.values, loaded.Round(time.Millisecond), float64(b.values)/loaded.Seconds())
+
+	var matched, failed atomic.Int64
+	latencies := benchRun(b, b.messages, 1, func(i int) error {
+		ok, err := matcher.ShouldForward(payloads[i%len(payloads)])
+		if err != nil {
+			failed.Add(1)
+		} else if ok {
+			matched.Add(1)
+		}
+		return nil
+	}, out)
+	fmt.Fprintf(out, "matched: %d of %d (%d decode errors)\n", matched.Load(), b.messages, failed.Load())
+	benchLatencies(out, "match latency", latencies)
+	return nil
+}
+
+// benchProduce writes the reference values and then the source messages, b.batch per
+// call, to the brokers.
+func benchProduce(b benchSettings, payloads [][]byte, out io.Writer) error {
+	pool := delivery.NewPool(strings.Split(b.brokers, ","), nil)
+	defer pool.Close()
+	ctx := context.Background()
+	references := pool.Topic(b.referenceTopic)
+	start := time.Now()
+	for i := 0; i < b.values; i += b.batch {
+		batch := make([]kafka.Message, 0, b.batch)
+		for j := i; j < min(i+b.batch, b.values); j++ {
+			batch = append(batch, kafka.Message{Value: fmt.Appendf(nil, `{"account":%q}`, benchValue(j))})
+		}
+		if err := references.WriteMessages(ctx, batch...); err != nil {
+			return fmt.Errorf("produce reference values: %w", err)
+		}
+	}
+	loaded := time.Since(start)
+	fmt.Fprintf(out, "reference: %d values to %s in %s (%.0f values/sec)\n", b.values, b.referenceTopic, loaded.Round(time.Millisecond), float64(b.values)/loaded.Seconds())
+
+	sources := pool.Topic(b.sourceTopic)
+	var produceErr error
+	var once sync.Once
+	batches := (b.messages + b.batch - 1) / b.batch
+	latencies := benchRun(b, batches, b.batch, func(i int) error {
+		batch := make([]kafka.Message, 0, b.batch)
+		for j := i * b.batch; j < min((i+1)*b.batch, b.messages); j++ {
+			batch = append(batch, kafka.Message{Value: payloads[j%len(payloads)]})
+		}
+		if err := sources.WriteMessages(ctx, batch...); err != nil {
+			once.Do(func() { produceErr = err })
+			return err
+		}
+		return nil
+	}, out)
+	if produceErr != nil {
+		return fmt.Errorf("produce source messages: %w", produceErr)
+	}
+	benchLatencies(out, fmt.Sprintf("produce latency per %d messages", b.batch), latencies)
+	return nil
+}
+
+// benchRun calls op ops times, spread over b.workers goroutines, each call handling
+// perOp messages. It reports the throughput and allocations per message and returns each
+// call's duration. It stops at the first error.
+func benchRun(b benchSettings, ops, perOp int, op func(i int) error, out io.Writer) []time.Duration {
+	var next atomic.Int64
+	var stopped atomic.Bool
+	perWorker := make([][]time.Duration, b.workers)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := range perWorker {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latencies := make([]time.Duration, 0, ops/b.workers+1)
+			for !stopped.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= ops {
+					break
+				}
+				t := time.Now()
+				err := op(i)
+				latencies = append(latencies, time.Since(t))
+				if err != nil {
+					stopped.Store(true)
+				}
+			}
+			perWorker[w] = latencies
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	n := min(min(int(next.Load()), ops)*perOp, b.messages)
+	fmt.Fprintf(out, "source: %d messages in %s with %d workers (%.0f msgs/sec)\n", n, elapsed.Round(time.Millisecond), b.workers, float64(n)/elapsed.Seconds())
+	fmt.Fprintf(out, "allocations: %.1f allocs/msg, %.0f B/msg\n", float64(after.Mallocs-before.Mallocs)/float64(n), float64(after.TotalAlloc-before.TotalAlloc)/float64(n))
+	var all []time.Duration
+	for _, l := range perWorker {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// benchLatencies prints percentiles of the recorded durations.
+func benchLatencies(out io.Writer, label string, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	at := func(q float64) time.Duration {
+		return latencies[min(len(latencies)-1, int(q*float64(len(latencies))))]
+	}
+	fmt.Fprintf(out, "%s: p50=%s p90=%s p99=%s p99.9=%s max=%s\n", label, at(0.5), at(0.9), at(0.99), at(0.999), latencies[len(latencies)-1])
+}
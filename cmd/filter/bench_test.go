@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBenchMatchesInProcess(t *testing.T) {
+	var out bytes.Buffer
+	if err := runBench([]string{"-values", "200", "-messages", "1000", "-workers", "2", "-match-rate", "0.5"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	for _, want := range []string{"reference: 200 values", "source: 1000 messages", "msgs/sec", "allocs/msg", "match latency: p50="} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("bench report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "matched: 0 of") || strings.Contains(report, "matched: 1000 of") {
+		t.Fatalf("expected about half the messages to match:\n%s", report)
+	}
+	if err := runBench([]string{"-strategy", "regex"}, io.Discard); err == nil {
+		t.Fatal("expected an unknown strategy to fail")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/serialize"
+)
+
+// bridge is the state shared by the routes of one bridge process, or of one subcommand
+// that processes messages, and by the admin API serving them. It is built by newBridge
+// and handed to whatever runs the routes.
+type bridge struct {
+	// routeCounters holds the source-side counters of every route since startup, or since
+	// they were first saved under storage.persistCounters.
+	routeCounters *statsRegistry
+	// routeLatencies holds the forwarding latency histograms of every route and
+	// destination.
+	routeLatencies *latencyRegistry
+	// messageLogs writes the per-message log lines of every route, sampled by
+	// logging.sampleEvery.
+	messageLogs *messageLogger
+	// forwardEvents fans forwarding decisions out to admin API watchers.
+	forwardEvents *eventHub
+	// routeAssignments holds the partitions the consumer group assigned to the source and
+	// reference readers of every route, as reported by the kafka-go reader log.
+	routeAssignments *assignmentRegistry
+	// openReaders counts the Kafka readers each route currently holds open.
+	openReaders *readerGauge
+	// routeDecodeErrors holds the decode-error topic writer of every route whose
+	// onDecodeError is dlq.
+	routeDecodeErrors *decodeErrorRegistry
+	// routeRejections holds the rejected-topic sampler of every route with a
+	// rejectedTopic.
+	routeRejections *rejectionRegistry
+	// routeEncoders holds the output encoder of every route that re-encodes its payloads.
+	routeEncoders *encoderRegistry
+	// routeDedup holds the dedup window of every route that declares one.
+	routeDedup *dedupRegistry
+	// routeGroups holds, for every route in a route group, the routes evaluated before it.
+	routeGroups *groupRegistry
+	// routeExpiries records the routes that declare an expiry and those already paused by
+	// it.
+	routeExpiries *expiryRegistry
+	// routeSeeks holds the seek controls of the routes whose source reader is streaming.
+	routeSeeks *seekRegistry
+	// routeStarts tells the routes of a pipeline when the routes feeding them start
+	// streaming.
+	routeStarts *startRegistry
+	// routeRestarts holds the failed workers waiting to be restarted, which keep /readyz
+	// failing.
+	routeRestarts *restartRegistry
+	// routeWarmups holds the reference lag of the routes waiting for their reference feeds
+	// to catch up, which keeps /readyz failing.
+	routeWarmups *warmupRegistry
+	// memoryBudget holds the measured memory of every route and the policy each one sheds
+	// under while it or the bridge is over budget.
+	memoryBudget *budgetMonitor
+	// stateRecovery reports whether the cache has been restored from storage. It is held
+	// while a storage.required backend is restored: /readyz fails and routes, reference
+	// ingestion, and admin mutations wait until it is released.
+	stateRecovery *recoveryGate
+	// leading reports whether this replica currently runs the reference collectors under
+	// leader election.
+	leading atomic.Bool
+	// memoryBroker stands in for every cluster, and serves /mock/topics, when the bridge
+	// runs with -mock; nil otherwise.
+	memoryBroker *kafkapkg.MemoryBroker
+}
+
+// newBridge returns a bridge with empty registries whose per-message log lines follow
+// logging.
+func newBridge(logging config.Logging) *bridge {
+	b := &bridge{
+		routeCounters:     &statsRegistry{routes: make(map[string]*routeStats)},
+		routeLatencies:    &latencyRegistry{series: make(map[latencyKey]*latencySeries)},
+		messageLogs:       &messageLogger{counts: make(map[string]*atomic.Uint64)},
+		forwardEvents:     &eventHub{subscribers: make(map[*subscription]struct{})},
+		routeAssignments:  &assignmentRegistry{readers: make(map[assignmentKey]*ReaderAssignment)},
+		openReaders:       &readerGauge{counts: make(map[string]int)},
+		routeDecodeErrors: &decodeErrorRegistry{writers: make(map[string]delivery.MessageWriter)},
+		routeRejections:   &rejectionRegistry{samplers: make(map[string]*rejectionSampler)},
+		routeEncoders:     &encoderRegistry{encoders: make(map[string]serialize.Encoder)},
+		routeDedup:        &dedupRegistry{windows: make(map[string]*dedupWindow)},
+		routeExpiries:     &expiryRegistry{deadlines: make(map[string]time.Time), expired: make(map[string]bool)},
+		routeSeeks:        &seekRegistry{routes: make(map[string]*seekControl)},
+		routeStarts:       &startRegistry{started: make(map[string]chan struct{})},
+		routeRestarts:     &restartRegistry{failed: make(map[restartKey]string)},
+		routeWarmups:      &warmupRegistry{lag: make(map[string]int64)},
+		stateRecovery:     &recoveryGate{},
+	}
+	b.routeGroups = &groupRegistry{ahead: make(map[string][]groupMember), expiries: b.routeExpiries}
+	b.memoryBudget = &budgetMonitor{shedding: make(map[string]string), changed: make(chan struct{}), counters: b.routeCounters}
+	b.messageLogs.configure(logging)
+	return b
+}
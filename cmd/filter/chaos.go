@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+var (
+	errChaosWrite  = errors.New("chaos: injected write failure")
+	errChaosDecode = errors.New("chaos: injected decode failure")
+)
+
+// chaosRand draws the rolls of fault injection; tests replace it.
+var chaosRand = rand.Float64
+
+// chaosRoll reports whether a fault injected into percent of attempts hits this one.
+func chaosRoll(percent float64) bool {
+	return percent > 0 && chaosRand()*100 < percent
+}
+
+// chaosWriter delays and fails write attempts to a route's destination as its chaos
+// block asks, before they reach the destination.
+type chaosWriter struct {
+	delivery.MessageWriter
+	chaos config.Chaos
+}
+
+func (w chaosWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if chaosRoll(w.chaos.LatencyPercent) {
+		select {
+		case <-time.After(w.chaos.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if chaosRoll(w.chaos.WriteErrorPercent) {
+		return errChaosWrite
+	}
+	return w.MessageWriter.WriteMessages(ctx, msgs...)
+}
+
+// injectDecodeError returns errChaosDecode for the share of source messages chaos fails
+// to decode, and nil otherwise or without chaos.
+func injectDecodeError(chaos *config.Chaos) error {
+	if chaos != nil && chaosRoll(chaos.DecodeErrorPercent) {
+		return errChaosDecode
+	}
+	return nil
+}
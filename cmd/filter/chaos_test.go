@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestChaosInjection(t *testing.T) {
+	b := newBridge(config.Logging{})
+	defer func(orig func() float64) { chaosRand = orig }(chaosRand)
+	roll := 0.5
+	chaosRand = func() float64 { return roll }
+
+	w := &recordingWriter{}
+	chaos := chaosWriter{MessageWriter: w, chaos: config.Chaos{WriteErrorPercent: 60, LatencyPercent: 40, Latency: time.Hour}}
+	if err := chaos.WriteMessages(context.Background(), kafka.Message{Value: []byte("a")}); !errors.Is(err, errChaosWrite) || len(w.written) != 0 {
+		t.Fatalf("expected a roll of 50 to fail a 60%% write error without writing, got %v", err)
+	}
+	roll = 0.7
+	if err := chaos.WriteMessages(context.Background(), kafka.Message{Value: []byte("a")}); err != nil || len(w.written) != 1 {
+		t.Fatalf("expected a roll of 70 to pass, got %v with %d written", err, len(w.written))
+	}
+
+	matcher, err := engine.NewMatcher("route-chaos", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	matcher.AddValues([]string{"x"})
+	route := config.Route{Name: "route-chaos", DestinationTopic: "dest", Chaos: &config.Chaos{DecodeErrorPercent: 80}}
+	destination := &recordingWriter{}
+	before := b.routeCounters.route(routeKey(route)).decodeErrors.Load()
+	if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, destination, delivery.RetryPolicy{}, kafka.Message{Value: []byte(`{"id":"x"}`)}); err != nil {
+		t.Fatalf("forwardMessage: %v", err)
+	}
+	if got := b.routeCounters.route(routeKey(route)).decodeErrors.Load() - before; got != 1 || len(destination.written) != 0 {
+		t.Fatalf("expected an injected decode error to skip the message, got %d decode error(s) and %d written", got, len(destination.written))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"kafka-bridge/internal/config"
+)
+
+// globalFlags are the flags given before the subcommand. A subcommand's own -config,
+// -read-only-admin, and -mock default to them.
+type globalFlags struct {
+	config        string
+	readOnlyAdmin bool
+	mock          bool
+}
+
+// subcommand is one of the tools of the filter binary.
+type subcommand struct {
+	name    string
+	summary string
+	run     func(g globalFlags, args []string) error
+}
+
+// subcommands lists the subcommands in the order usage prints them.
+func subcommands() []subcommand {
+	return []subcommand{
+		{name: "run", summary: "run the bridge (the default), or match a file of records with -source-file", run: func(g globalFlags, args []string) error {
+			return runRun(g, args, os.Stdout)
+		}},
+		{name: "validate", summary: "check a config, and with -connect the topics, groups, and ACLs it needs", run: func(g globalFlags, args []string) error {
+			return runValidate(g.config, args, os.Stdout)
+		}},
+		{name: "replay", summary: "re-filter a route's source topic between two offsets or timestamps", run: func(g globalFlags, args []string) error {
+			return runReplay(g.config, args)
+		}},
+		{name: "split", summary: "seed a new route with the cache and offsets of an existing one", run: func(g globalFlags, args []string) error {
+			return runSplit(g.config, args)
+		}},
+		{name: "snapshot", summary: "inspect, merge, filter, or convert cache snapshot files", run: func(g globalFlags, args []string) error {
+			return runSnapshot(g, args, os.Stdout)
+		}},
+		{name: "bench", summary: "measure matcher throughput, or produce synthetic traffic with -brokers", run: func(g globalFlags, args []string) error {
+			return runBench(args, os.Stdout)
+		}},
+		{name: "version", summary: "print the build version", run: func(g globalFlags, args []string) error {
+			return runVersion(args, os.Stdout)
+		}},
+	}
+}
+
+// findSubcommand returns the subcommand called name.
+func findSubcommand(name string) (subcommand, bool) {
+	for _, cmd := range subcommands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return subcommand{}, false
+}
+
+// writeUsage prints the usage of the filter binary, with its global flags from fs.
+func writeUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: filter [flags] [subcommand] [subcommand flags]\n\n")
+	fmt.Fprintf(w, "Without a subcommand, filter runs the bridge.\n\nSubcommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun 'filter <subcommand> -h' for the flags of a subcommand.\n\nFlags:\n")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// loadConfig loads the config at path, as every subcommand that processes messages does
+// first.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// runVersion implements `filter version`.
+func runVersion(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the build information as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(out).Encode(buildVersion())
+	}
+	_, err := fmt.Fprintln(out, buildVersion())
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSubcommandUsage(t *testing.T) {
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	fs.String("config", "config/config.yaml", "path to YAML config file")
+	var out bytes.Buffer
+	writeUsage(&out, fs)
+	for _, cmd := range subcommands() {
+		if !strings.Contains(out.String(), "  "+cmd.name+" ") {
+			t.Fatalf("usage does not list %s:\n%s", cmd.name, out.String())
+		}
+		if found, ok := findSubcommand(cmd.name); !ok || found.name != cmd.name {
+			t.Fatalf("findSubcommand(%q) = %v, %v", cmd.name, found.name, ok)
+		}
+	}
+	if !strings.Contains(out.String(), "-config") {
+		t.Fatalf("usage does not list the global flags:\n%s", out.String())
+	}
+	if _, ok := findSubcommand("serve"); ok {
+		t.Fatal("expected an unknown subcommand not to be found")
+	}
+
+	out.Reset()
+	if err := runVersion([]string{"-json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var info VersionInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || info.Version == "" || info.Features != nil {
+		t.Fatalf("unexpected version output %q: %v", out.String(), err)
+	}
+	if err := runVersion([]string{"-h"}, io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected -h to return flag.ErrHelp, got %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// compactedRestoreIdle bounds the wait for the next record while the destination topic is
+// read back at startup, since a partition's last offsets may hold only transaction markers.
+const compactedRestoreIdle = 10 * time.Second
+
+// compactedRoute keeps a compacted destination topic in step with the cache, so the topic
+// holds the latest record of every source key that currently matches. It remembers which
+// fingerprints admitted each forwarded key and writes a tombstone once none of them are
+// cached, the key's latest record stops matching, or the source deletes the key. The
+// index is rebuilt from the destination topic when the route starts.
+type compactedRoute struct {
+	bridge      *bridge
+	route       config.Route
+	routeID     string
+	guard       loopGuard
+	headers     headerRewriter
+	destination delivery.MessageWriter
+	policy      delivery.RetryPolicy
+
+	// writeMu serialises destination writes so a tombstone never overtakes a record.
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	keys          map[string]*compactedKey
+	byFingerprint map[string]map[string]struct{}
+	pending       map[string]struct{}
+	signal        chan struct{}
+}
+
+type compactedKey struct {
+	partition    int
+	fingerprints map[string]struct{}
+}
+
+func newCompactedRoute(b *bridge, route config.Route, guard loopGuard, headers headerRewriter, destination delivery.MessageWriter, policy delivery.RetryPolicy) *compactedRoute {
+	return &compactedRoute{
+		bridge:        b,
+		route:         route,
+		routeID:       routeKey(route),
+		guard:         guard,
+		headers:       headers,
+		destination:   destination,
+		policy:        policy,
+		keys:          make(map[string]*compactedKey),
+		byFingerprint: make(map[string]map[string]struct{}),
+		pending:       make(map[string]struct{}),
+		signal:        make(chan struct{}, 1),
+	}
+}
+
+// observe is a store observer: removing a fingerprint queues tombstones for the keys that
+// no longer have any cached match.
+func (c *compactedRoute) observe(m store.Mutation) {
+	if m.Op != store.OpRemove || m.Route != c.routeID {
+		return
+	}
+	c.mu.Lock()
+	queued := false
+	for key := range c.byFingerprint[m.Fingerprint] {
+		state := c.keys[key]
+		delete(state.fingerprints, m.Fingerprint)
+		if len(state.fingerprints) == 0 {
+			c.pending[key] = struct{}{}
+			queued = true
+		}
+	}
+	delete(c.byFingerprint, m.Fingerprint)
+	c.mu.Unlock()
+
+	if queued {
+		select {
+		case c.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// restore rebuilds the index from records, the destination topic in partition order: the
+// latest record of each key is matched against the cache again, and keys whose record no
+// longer matches are queued for a tombstone. It returns how many keys were indexed and
+// queued.
+func (c *compactedRoute) restore(records []kafka.Message, matcher *engine.Matcher) (indexed, queued int) {
+	latest := make(map[string]kafka.Message)
+	for _, msg := range records {
+		if len(msg.Key) == 0 {
+			continue
+		}
+		if msg.Value == nil {
+			delete(latest, string(msg.Key))
+			continue
+		}
+		latest[string(msg.Key)] = msg
+	}
+
+	// held while matching, so removals made meanwhile are applied to the rebuilt index
+	c.mu.Lock()
+	for key, msg := range latest {
+		result, err := matcher.Evaluate(msg.Value)
+		if err != nil {
+			log.Printf("warn: route %s: destination record at partition %d offset %d not indexed: %v", c.route.DisplayName(), msg.Partition, msg.Offset, err)
+			continue
+		}
+		c.forgetLocked(key)
+		state := &compactedKey{partition: msg.Partition, fingerprints: make(map[string]struct{}, len(result.Matches))}
+		c.keys[key] = state
+		if len(result.Matches) == 0 {
+			c.pending[key] = struct{}{}
+			queued++
+			continue
+		}
+		c.indexLocked(key, state, result.Matches)
+		indexed++
+	}
+	c.mu.Unlock()
+
+	if queued > 0 {
+		select {
+		case c.signal <- struct{}{}:
+		default:
+		}
+	}
+	return indexed, queued
+}
+
+// readDestination returns every record of the route's destination topic on the bridge
+// cluster, partition by partition.
+func (c *compactedRoute) readDestination(ctx context.Context, brokers []string, dialer *kafka.Dialer) ([]kafka.Message, error) {
+	if broker := c.bridge.memoryBroker; broker != nil {
+		return broker.Messages(c.route.DestinationTopic), nil
+	}
+	ranges, err := kafkapkg.ResolveRange(ctx, brokers, dialer, c.route.DestinationTopic, -1, kafkapkg.Bound{Offset: kafka.FirstOffset}, kafkapkg.Bound{Offset: kafka.LastOffset})
+	if err != nil {
+		return nil, err
+	}
+	var records []kafka.Message
+	for _, r := range ranges {
+		if r.Start >= r.End {
+			continue
+		}
+		read, err := readPartitionRange(ctx, kafka.ReaderConfig{Brokers: brokers, Topic: c.route.DestinationTopic, Partition: r.Partition, Dialer: dialer}, r)
+		if err != nil {
+			return nil, fmt.Errorf("read partition %d: %w", r.Partition, err)
+		}
+		records = append(records, read...)
+	}
+	return records, nil
+}
+
+// readPartitionRange reads the records of r, stopping early once the partition has been
+// idle for compactedRestoreIdle.
+func readPartitionRange(ctx context.Context, readerCfg kafka.ReaderConfig, r kafkapkg.PartitionRange) ([]kafka.Message, error) {
+	reader := kafka.NewReader(readerCfg)
+	defer reader.Close()
+	if err := reader.SetOffset(r.Start); err != nil {
+		return nil, fmt.Errorf("seek to %d: %w", r.Start, err)
+	}
+	var records []kafka.Message
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, compactedRestoreIdle)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return records, nil
+			}
+			return records, err
+		}
+		if msg.Offset >= r.End {
+			return records, nil
+		}
+		records = append(records, msg)
+		if msg.Offset == r.End-1 {
+			return records, nil
+		}
+	}
+}
+
+// run writes queued tombstones until ctx is done.
+func (c *compactedRoute) run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.signal:
+		}
+		if err := c.flushTombstones(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("route %s: tombstone write failed, will retry on the next removal: %v", c.route.DisplayName(), err)
+		}
+	}
+}
+
+// flushTombstones writes a tombstone for every queued key that is still unmatched. Keys
+// whose write fails stay queued.
+func (c *compactedRoute) flushTombstones(ctx context.Context) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	var tombstones []kafka.Message
+	for key := range c.pending {
+		if state, ok := c.keys[key]; ok && len(state.fingerprints) == 0 {
+			tombstones = append(tombstones, kafka.Message{Partition: state.partition, Key: []byte(key)})
+		}
+	}
+	c.mu.Unlock()
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	if err := delivery.Deliver(ctx, c.destination, c.policy, tombstones...); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	for _, t := range tombstones {
+		key := string(t.Key)
+		delete(c.pending, key)
+		// a record may have re-admitted the key while the tombstones were written
+		if state, ok := c.keys[key]; ok && len(state.fingerprints) == 0 {
+			delete(c.keys, key)
+		}
+	}
+	c.mu.Unlock()
+	c.bridge.routeCounters.route(c.routeID).tombstones.Add(uint64(len(tombstones)))
+	log.Printf("route %s wrote %d tombstone(s) to %s", c.route.DisplayName(), len(tombstones), c.route.DestinationTopic)
+	return nil
+}
+
+// forward handles one source record: matching records are written under their source key,
+// while deletes and records that stop matching become tombstones for keys already written.
+// Records without a key cannot be compacted and are skipped.
+func (c *compactedRoute) forward(ctx context.Context, matcher *engine.Matcher, msg kafka.Message) error {
+	stats := c.bridge.routeCounters.route(c.routeID)
+	switch v := screen(c.route, c.guard, c.headers, msg, time.Now()); v.outcome {
+	case verdictLoop:
+		stats.dropped.Add(1)
+		c.bridge.publishDecision(c.routeID, decisionDropped, msg, v.reason)
+		c.bridge.messageLogs.printf(c.routeID, "route %s: offset %d dropped: %s", c.route.DisplayName(), msg.Offset, v.reason)
+		return nil
+	case verdictTooOld:
+		stats.tooOld.Add(1)
+		c.bridge.publishDecision(c.routeID, decisionDropped, msg, v.reason)
+		c.bridge.messageLogs.printf(c.routeID, "route %s: offset %d dropped: %s", c.route.DisplayName(), msg.Offset, v.reason)
+		return nil
+	case verdictHeaderFiltered:
+		stats.headerFiltered.Add(1)
+		c.bridge.publishDecision(c.routeID, decisionSkipped, msg, v.reason)
+		c.bridge.rejectMessage(ctx, c.route, msg, rejectedHeaderFilter, v.reason)
+		return nil
+	}
+	if len(msg.Key) == 0 {
+		stats.skipped.Add(1)
+		c.bridge.publishDecision(c.routeID, decisionSkipped, msg, "record has no key")
+		c.bridge.rejectMessage(ctx, c.route, msg, rejectedNoKey, "record has no key")
+		c.bridge.messageLogs.printf(c.routeID, "route %s: record at offset %d has no key, skipped for compacted destination", c.route.DisplayName(), msg.Offset)
+		return nil
+	}
+	key := string(msg.Key)
+
+	var matches []engine.Match
+	value := msg.Value
+	if msg.Value != nil {
+		var err error
+		if c.route.Payload.ForwardDecompressed {
+			value, err = matcher.Decompress(msg.Value)
+		}
+		if err == nil {
+			err = injectDecodeError(c.route.Chaos)
+		}
+		if err == nil {
+			matches, err = matcher.Matches(value)
+		}
+		if err != nil {
+			return c.bridge.handleDecodeError(ctx, c.route, c.guard, c.headers, c.destination, c.policy, msg, err)
+		}
+	}
+
+	var winner string
+	if len(matches) > 0 {
+		if winner = c.bridge.routeGroups.preemptedBy(c.routeID, msg.Value); winner != "" {
+			// the key now belongs to the winning route, so retract it here like a non-match
+			stats.preempted.Add(1)
+			matches = nil
+		}
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if len(matches) == 0 {
+		c.mu.Lock()
+		_, known := c.keys[key]
+		c.mu.Unlock()
+		if !known {
+			if winner != "" {
+				c.bridge.publishDecision(c.routeID, decisionSkipped, msg, "matched higher-priority route "+winner)
+				c.bridge.rejectMessage(ctx, c.route, msg, rejectedPreempted, "matched higher-priority route "+winner)
+				return nil
+			}
+			stats.skipped.Add(1)
+			c.bridge.publishDecision(c.routeID, decisionSkipped, msg, "")
+			c.bridge.rejectMessage(ctx, c.route, msg, rejectedNoMatch, "")
+			return nil
+		}
+		tombstone := kafka.Message{Partition: msg.Partition, Key: append([]byte(nil), msg.Key...)}
+		if err := delivery.Deliver(ctx, c.destination, c.policy, tombstone); err != nil {
+			return fmt.Errorf("write tombstone for offset %d to %s: %w", msg.Offset, c.route.DestinationTopic, err)
+		}
+		c.mu.Lock()
+		c.forgetLocked(key)
+		c.mu.Unlock()
+		stats.tombstones.Add(1)
+		c.bridge.messageLogs.printf(c.routeID, "route %s wrote tombstone for offset %d to %s", c.route.DisplayName(), msg.Offset, c.route.DestinationTopic)
+		return nil
+	}
+
+	out := cloneMessage(msg)
+	if c.route.Payload.ForwardDecompressed {
+		out.Value = value
+	}
+	out.Headers = c.headers.rewrite(out.Headers, msg, time.Now())
+	out.Headers = c.guard.stamp(out.Headers)
+	if c.route.ExplainHeaders {
+		out.Headers = withExplainHeaders(out.Headers, c.routeID, matches[0], msg.Offset)
+	}
+	if err := delivery.Deliver(ctx, c.destination, c.policy, out); err != nil {
+		return fmt.Errorf("write offset %d to %s: %w", msg.Offset, c.route.DestinationTopic, err)
+	}
+	c.mu.Lock()
+	c.forgetLocked(key)
+	state := &compactedKey{partition: msg.Partition, fingerprints: make(map[string]struct{}, len(matches))}
+	c.indexLocked(key, state, matches)
+	c.keys[key] = state
+	c.mu.Unlock()
+	now := time.Now()
+	stats.recordForward(msg.Partition, msg.Offset, now)
+	c.bridge.forwardEvents.publish(forwardEvent{Route: c.routeID, Decision: decisionForwarded, Match: matches[0], Partition: msg.Partition, Offset: msg.Offset, Destination: c.route.DestinationTopic, At: now})
+	c.bridge.messageLogs.printf(c.routeID, "route %s forwarded offset %d to %s", c.route.DisplayName(), msg.Offset, c.route.DestinationTopic)
+	return nil
+}
+
+// indexLocked records that matches admitted key, whose state is state.
+func (c *compactedRoute) indexLocked(key string, state *compactedKey, matches []engine.Match) {
+	for _, match := range matches {
+		state.fingerprints[match.Fingerprint] = struct{}{}
+		keys, ok := c.byFingerprint[match.Fingerprint]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.byFingerprint[match.Fingerprint] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// forgetLocked drops key from the index, including any queued tombstone.
+func (c *compactedRoute) forgetLocked(key string) {
+	state, ok := c.keys[key]
+	if !ok {
+		return
+	}
+	for fp := range state.fingerprints {
+		if keys := c.byFingerprint[fp]; keys != nil {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(c.byFingerprint, fp)
+			}
+		}
+	}
+	delete(c.keys, key)
+	delete(c.pending, key)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestCompactedRouteTombstones(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"alpha", "beta"})
+	w := &recordingWriter{}
+	c := newCompactedRoute(b, config.Route{Name: "route-a", DestinationTopic: "dest", Compacted: true}, loopGuard{}, headerRewriter{}, w, delivery.RetryPolicy{InitialBackoff: time.Millisecond})
+	matchStore.AddObserver(c.observe)
+	ctx := context.Background()
+
+	for i, rec := range []struct{ key, value string }{
+		{"k1", `{"x":"alpha"}`},
+		{"k2", `{"x":"alpha","y":"beta"}`},
+		{"k3", `{"x":"miss"}`},
+		{"", `{"x":"alpha"}`},
+	} {
+		msg := kafka.Message{Partition: 2, Offset: int64(i), Key: []byte(rec.key), Value: []byte(rec.value)}
+		if err := c.forward(ctx, matcher, msg); err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if len(w.written) != 2 {
+		t.Fatalf("expected 2 keyed records forwarded, got %v", w.written)
+	}
+
+	// k2 still matches beta, so only k1 loses its last match
+	matcher.RemoveValues([]string{"alpha"})
+	if err := c.flushTombstones(ctx); err != nil {
+		t.Fatalf("flushTombstones: %v", err)
+	}
+	if len(w.written) != 3 || string(w.written[2].Key) != "k1" || w.written[2].Value != nil || w.written[2].Partition != 2 {
+		t.Fatalf("expected tombstone for k1, got %v", w.written)
+	}
+
+	// a later record for k2 that no longer matches removes it from the destination
+	if err := c.forward(ctx, matcher, kafka.Message{Partition: 2, Offset: 9, Key: []byte("k2"), Value: []byte(`{"x":"miss"}`)}); err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if len(w.written) != 4 || string(w.written[3].Key) != "k2" || w.written[3].Value != nil {
+		t.Fatalf("expected tombstone for k2, got %v", w.written)
+	}
+	matcher.RemoveValues([]string{"beta"})
+	if err := c.flushTombstones(ctx); err != nil {
+		t.Fatalf("flushTombstones: %v", err)
+	}
+	if len(w.written) != 4 {
+		t.Fatalf("unexpected extra writes: %v", w.written[4:])
+	}
+}
+
+func TestCompactedRouteRestore(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"alpha", "beta"})
+	route := config.Route{Name: "route-a", DestinationTopic: "dest", Compacted: true}
+	policy := delivery.RetryPolicy{InitialBackoff: time.Millisecond}
+
+	// the destination topic as a previous run left it, with k4 deleted since
+	destination := []kafka.Message{
+		{Partition: 0, Offset: 0, Key: []byte("k1"), Value: []byte(`{"x":"alpha"}`)},
+		{Partition: 1, Offset: 0, Key: []byte("k2"), Value: []byte(`{"x":"alpha","y":"beta"}`)},
+		{Partition: 0, Offset: 1, Key: []byte("k3"), Value: []byte(`{"x":"gone"}`)},
+		{Partition: 0, Offset: 2, Key: []byte("k4"), Value: []byte(`{"x":"beta"}`)},
+		{Partition: 0, Offset: 3, Key: []byte("k4")},
+	}
+	w := &recordingWriter{}
+	c := newCompactedRoute(b, route, loopGuard{}, headerRewriter{}, w, policy)
+	matchStore.AddObserver(c.observe)
+	if indexed, queued := c.restore(destination, matcher); indexed != 2 || queued != 1 {
+		t.Fatalf("restore indexed %d and queued %d key(s), want 2 and 1", indexed, queued)
+	}
+	ctx := context.Background()
+	if err := c.flushTombstones(ctx); err != nil {
+		t.Fatalf("flushTombstones: %v", err)
+	}
+	if len(w.written) != 1 || string(w.written[0].Key) != "k3" || w.written[0].Value != nil {
+		t.Fatalf("expected a tombstone for k3, which no longer matches, got %v", w.written)
+	}
+
+	// removals after the restart still retract the keys written before it
+	matcher.RemoveValues([]string{"alpha"})
+	if err := c.flushTombstones(ctx); err != nil {
+		t.Fatalf("flushTombstones: %v", err)
+	}
+	if len(w.written) != 2 || string(w.written[1].Key) != "k1" || w.written[1].Partition != 0 {
+		t.Fatalf("expected a tombstone for k1 on partition 0, got %v", w.written)
+	}
+	// as do source records that stop matching
+	if err := c.forward(ctx, matcher, kafka.Message{Partition: 1, Offset: 7, Key: []byte("k2"), Value: []byte(`{"x":"miss"}`)}); err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if len(w.written) != 3 || string(w.written[2].Key) != "k2" || w.written[2].Value != nil {
+		t.Fatalf("expected a tombstone for k2, got %v", w.written)
+	}
+}
+
+func TestCompactedRouteRestartsReleaseObserver(t *testing.T) {
+	b := newBridge(config.Logging{})
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	cfg := &config.Config{ReferenceGroupID: "refs"}
+	route := config.Route{Name: "compact", SourceCluster: "mock", SourceTopic: "orders", DestinationTopic: "matched", Compacted: true,
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}}}
+	matchStore := store.NewMatchStore()
+	matcher, err := newRouteMatcher(cfg, route, matchStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher.AddValues([]string{"c1"})
+	writers := delivery.NewPool(nil, nil, delivery.WithTopicWriters(func(topic string) delivery.MessageWriter { return b.memoryBroker.Topic(topic) }))
+	produce := func(key string) {
+		if _, err := b.memoryBroker.Produce("orders", kafka.Message{Key: []byte(key), Value: []byte(`{"customer":"c1"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(what string, done func() bool) {
+		for deadline := time.Now().Add(2 * time.Second); !done(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	// start runs the route until the returned func stops it, once key is forwarded
+	start := func(key string, forwarded int) func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			_ = b.streamRoute(ctx, cfg, route, config.SourceCluster{Name: "mock", SourceGroupID: "src"}, nil, writers, matchStore, matcher)
+		}()
+		produce(key)
+		waitFor(key+" to be forwarded", func() bool { return len(b.memoryBroker.Messages("matched")) == forwarded })
+		if n := matchStore.Observers(); n != 1 {
+			t.Fatalf("%d observer(s) registered while the route runs, want 1", n)
+		}
+		return func() {
+			cancel()
+			<-stopped
+		}
+	}
+
+	for i := range 3 {
+		start("k"+strconv.Itoa(i), i+1)()
+		if n := matchStore.Observers(); n != 0 {
+			t.Fatalf("%d observer(s) still registered after run %d stopped", n, i+1)
+		}
+	}
+
+	// the last run indexes k0-k2 from the destination; removing c1 retracts them and k3 once
+	stop := start("k3", 4)
+	defer stop()
+	matcher.RemoveValues([]string{"c1"})
+	var tombstones []string
+	waitFor("tombstones", func() bool {
+		tombstones = tombstones[:0]
+		for _, msg := range b.memoryBroker.Messages("matched") {
+			if msg.Value == nil {
+				tombstones = append(tombstones, string(msg.Key))
+			}
+		}
+		return len(tombstones) >= 4
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(b.memoryBroker.Messages("matched")); n != 8 {
+		t.Fatalf("destination holds %d record(s), want 4 forwarded and 4 tombstones", n)
+	}
+	slices.Sort(tombstones)
+	if want := []string{"k0", "k1", "k2", "k3"}; !slices.Equal(tombstones, want) {
+		t.Fatalf("tombstones %v, want one for each of %v", tombstones, want)
+	}
+}
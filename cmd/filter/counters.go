@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/store"
+)
+
+// savedCounters is the persisted form of one route's counters under
+// storage.persistCounters.
+type savedCounters struct {
+	// Since is when the route was first counted.
+	Since          time.Time      `json:"since"`
+	Consumed       uint64         `json:"consumed"`
+	Forwarded      uint64         `json:"forwarded"`
+	Skipped        uint64         `json:"skipped"`
+	DecodeErrors   uint64         `json:"decodeErrors"`
+	WriteErrors    uint64         `json:"writeErrors"`
+	Dropped        uint64         `json:"dropped"`
+	Tombstones     uint64         `json:"tombstones"`
+	Oversized      uint64         `json:"oversized"`
+	Preempted      uint64         `json:"preempted"`
+	Duplicates     uint64         `json:"duplicates"`
+	HeaderFiltered uint64         `json:"headerFiltered"`
+	TooOld         uint64         `json:"tooOld"`
+	Sources        []SourceReport `json:"sources,omitempty"`
+}
+
+// saved returns the route's counters for persisting.
+func (s *routeStats) saved() savedCounters {
+	out := savedCounters{
+		Consumed:       s.consumed.Load(),
+		Forwarded:      s.forwarded.Load(),
+		Skipped:        s.skipped.Load(),
+		DecodeErrors:   s.decodeErrors.Load(),
+		WriteErrors:    s.writeErrors.Load(),
+		Dropped:        s.dropped.Load(),
+		Tombstones:     s.tombstones.Load(),
+		Oversized:      s.oversized.Load(),
+		Preempted:      s.preempted.Load(),
+		Duplicates:     s.duplicates.Load(),
+		HeaderFiltered: s.headerFiltered.Load(),
+		TooOld:         s.tooOld.Load(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out.Since = s.countedSince
+	if len(s.sources) > 0 {
+		out.Sources = s.sourceReportsLocked()
+	}
+	return out
+}
+
+// restore adds saved to the route's counters, which count on from the saved totals.
+func (s *routeStats) restore(saved savedCounters) {
+	s.consumed.Add(saved.Consumed)
+	s.forwarded.Add(saved.Forwarded)
+	s.skipped.Add(saved.Skipped)
+	s.decodeErrors.Add(saved.DecodeErrors)
+	s.writeErrors.Add(saved.WriteErrors)
+	s.dropped.Add(saved.Dropped)
+	s.tombstones.Add(saved.Tombstones)
+	s.oversized.Add(saved.Oversized)
+	s.preempted.Add(saved.Preempted)
+	s.duplicates.Add(saved.Duplicates)
+	s.headerFiltered.Add(saved.HeaderFiltered)
+	s.tooOld.Add(saved.TooOld)
+	for _, src := range saved.Sources {
+		counts := s.source(src.Cluster, src.Topic)
+		counts.consumed.Add(src.Consumed)
+		counts.forwarded.Add(src.Forwarded)
+	}
+	s.mu.Lock()
+	s.countedSince = saved.Since
+	s.mu.Unlock()
+}
+
+// restoreCounters restores the counters of routes from path, if it exists. Routes without
+// saved counters are counted from now, as are all of them when path cannot be read, whose
+// error is returned; saved counters of routes no longer configured are dropped with the
+// next save.
+func (b *bridge) restoreCounters(path string, routes []config.Route, now time.Time) error {
+	saved, err := readCountersFile(path)
+	for _, route := range routes {
+		routeID := routeKey(route)
+		counters, ok := saved[routeID]
+		if !ok || counters.Since.IsZero() {
+			counters = savedCounters{Since: now}
+		}
+		b.routeCounters.route(routeID).restore(counters)
+	}
+	return err
+}
+
+// saveCounters atomically writes the counters of every route counted since restore.
+func (b *bridge) saveCounters(path string) error {
+	b.routeCounters.mu.Lock()
+	saved := make(map[string]savedCounters, len(b.routeCounters.routes))
+	for id, stats := range b.routeCounters.routes {
+		if counters := stats.saved(); !counters.Since.IsZero() {
+			saved[id] = counters
+		}
+	}
+	b.routeCounters.mu.Unlock()
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, data, 0o644)
+}
+
+// startCounterWriter saves the routes' counters to path every interval and once more when
+// ctx is done.
+func (b *bridge) startCounterWriter(ctx context.Context, path string, interval time.Duration, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := b.saveCounters(path); err != nil {
+					log.Printf("warn: save route counters %s: %v", path, err)
+				}
+				return
+			case <-ticker.C:
+				if err := b.saveCounters(path); err != nil {
+					log.Printf("warn: save route counters %s: %v", path, err)
+				}
+			}
+		}
+	}()
+}
+
+func readCountersFile(path string) (map[string]savedCounters, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read route counters %s: %w", path, err)
+	}
+	var saved map[string]savedCounters
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode route counters %s: %w", path, err)
+	}
+	return saved, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestRouteCountersSurviveRestart(t *testing.T) {
+	b := newBridge(config.Logging{})
+	path := filepath.Join(t.TempDir(), "cache.json.counters")
+	routes := []config.Route{{Name: "route-kept"}, {Name: "route-multi"}}
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := b.restoreCounters(path, routes, first); err != nil {
+		t.Fatalf("restoreCounters without a file: %v", err)
+	}
+	kept := b.routeCounters.route("route-kept")
+	kept.consumed.Add(5)
+	kept.recordForward(0, 1, first)
+	kept.skipped.Add(4)
+	multi := b.routeCounters.route("route-multi")
+	multi.source("a", "in-a").consumed.Add(2)
+	b.routeCounters.route("route-unsaved").consumed.Add(9)
+	if err := b.saveCounters(path); err != nil {
+		t.Fatalf("saveCounters: %v", err)
+	}
+
+	// a restart, with one more route configured
+	b = newBridge(config.Logging{})
+	routes = append(routes, config.Route{Name: "route-new"})
+	later := first.Add(time.Hour)
+	if err := b.restoreCounters(path, routes, later); err != nil {
+		t.Fatalf("restoreCounters: %v", err)
+	}
+	b.routeCounters.route("route-kept").consumed.Add(1)
+	got := b.routeCounters.route("route-kept").report("route-kept", 0, later)
+	if got.Consumed != 6 || got.Forwarded != 1 || got.Skipped != 4 || got.CountedSince.IsZero() || !got.CountedSince.Equal(first) {
+		t.Fatalf("unexpected restored counters: %+v", got)
+	}
+	if sources := b.routeCounters.route("route-multi").report("route-multi", 0, later).Sources; len(sources) != 1 || sources[0].Consumed != 2 {
+		t.Fatalf("unexpected restored sources: %+v", sources)
+	}
+	if got := b.routeCounters.route("route-new").report("route-new", 0, later); got.CountedSince.IsZero() || !got.CountedSince.Equal(later) {
+		t.Fatalf("a new route counts from the restart, got %+v", got.CountedSince)
+	}
+	if got := b.routeCounters.route("route-unsaved").consumed.Load(); got != 0 {
+		t.Fatalf("counters of an unconfigured route were restored: %d", got)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b = newBridge(config.Logging{})
+	if err := b.restoreCounters(path, routes, later); err == nil {
+		t.Fatal("restoreCounters of a corrupt file succeeded")
+	}
+	if got := b.routeCounters.route("route-kept").report("route-kept", 0, later); got.Consumed != 0 || got.CountedSince.IsZero() {
+		t.Fatalf("a corrupt file should leave routes counted from now, got %+v", got)
+	}
+}
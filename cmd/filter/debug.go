@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+
+	"kafka-bridge/internal/watchdog"
+)
+
+var processStart = time.Now()
+
+// debugVars is the document served at /debug/vars.
+type debugVars struct {
+	UptimeSeconds float64               `json:"uptimeSeconds"`
+	Goroutines    int                   `json:"goroutines"`
+	Memory        debugMemory           `json:"memory"`
+	Routes        map[string]debugRoute `json:"routes"`
+	Writers       debugWriters          `json:"writers"`
+	Watchdog      []watchdog.Finding    `json:"watchdog,omitempty"`
+}
+
+type debugMemory struct {
+	HeapAlloc   uint64 `json:"heapAlloc"`
+	HeapInuse   uint64 `json:"heapInuse"`
+	HeapObjects uint64 `json:"heapObjects"`
+	Sys         uint64 `json:"sys"`
+	NumGC       uint32 `json:"numGC"`
+}
+
+type debugRoute struct {
+	CacheValues int    `json:"cacheValues"`
+	MaxValues   int    `json:"maxValues,omitempty"`
+	Evicted     uint64 `json:"evicted,omitempty"`
+	Rejected    uint64 `json:"rejected,omitempty"`
+	// BloomBytes and FalsePositives are reported for routes with a bloomFilter.
+	BloomBytes     int    `json:"bloomBytes,omitempty"`
+	FalsePositives uint64 `json:"falsePositives,omitempty"`
+	Readers        int    `json:"readers"`
+}
+
+type debugWriters struct {
+	Open   int      `json:"open"`
+	Topics []string `json:"topics"`
+}
+
+// registerDebug mounts net/http/pprof and /debug/vars behind the admin token.
+func registerDebug(mux *http.ServeMux, admin adminDeps) {
+	mux.HandleFunc("/debug/pprof/", admin.authorized(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", admin.authorized(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", admin.authorized(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", admin.authorized(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", admin.authorized(pprof.Trace))
+	mux.HandleFunc("/debug/vars", admin.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(collectDebugVars(admin)); err != nil {
+			log.Printf("debug vars encode failed: %v", err)
+		}
+	}))
+}
+
+func collectDebugVars(admin adminDeps) debugVars {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	vars := debugVars{
+		UptimeSeconds: time.Since(processStart).Seconds(),
+		Goroutines:    runtime.NumGoroutine(),
+		Memory: debugMemory{
+			HeapAlloc:   ms.HeapAlloc,
+			HeapInuse:   ms.HeapInuse,
+			HeapObjects: ms.HeapObjects,
+			Sys:         ms.Sys,
+			NumGC:       ms.NumGC,
+		},
+		Routes:  make(map[string]debugRoute, len(admin.matchers)),
+		Writers: debugWriters{Topics: []string{}},
+	}
+	for id := range admin.matchers {
+		stats := admin.store.Stats(id)
+		vars.Routes[id] = debugRoute{
+			CacheValues:    stats.Values,
+			MaxValues:      stats.MaxValues,
+			Evicted:        stats.Evicted,
+			Rejected:       stats.Rejected,
+			BloomBytes:     stats.FilterBytes,
+			FalsePositives: stats.FalsePositives,
+			Readers:        admin.openReaders.get(id),
+		}
+	}
+	if admin.writers != nil {
+		vars.Writers.Topics = admin.writers.Topics()
+		vars.Writers.Open = len(vars.Writers.Topics)
+	}
+	if admin.watchdog != nil {
+		vars.Watchdog = admin.watchdog.Findings()
+	}
+	sort.Strings(vars.Writers.Topics)
+	return vars
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestAdminTokenAndDebugEndpoints(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"one"})
+	admin := adminDeps{bridge: b,
+		matchers:   map[string]*engine.Matcher{"route-a": matcher},
+		store:      matchStore,
+		adminToken: "s3cret",
+		debug:      true,
+	}
+	server := httptest.NewServer(buildHTTPMux(admin))
+	t.Cleanup(server.Close)
+
+	cases := []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{method: http.MethodGet, path: "/debug/vars", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/debug/vars", token: "wrong", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/debug/vars", token: "s3cret", status: http.StatusOK},
+		{method: http.MethodGet, path: "/debug/pprof/", token: "s3cret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/cache/clear", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/cache", status: http.StatusOK},
+		{method: http.MethodPost, path: "/cache/clear", token: "s3cret", status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tc.method, tc.path, err)
+		}
+		if resp.StatusCode != tc.status {
+			resp.Body.Close()
+			t.Fatalf("%s %s (token %q): expected %d, got %d", tc.method, tc.path, tc.token, tc.status, resp.StatusCode)
+		}
+		if tc.path == "/debug/vars" && tc.status == http.StatusOK {
+			var vars debugVars
+			if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+				t.Fatalf("decode debug vars: %v", err)
+			}
+			if vars.Goroutines == 0 || vars.Routes["route-a"].CacheValues != 1 {
+				t.Fatalf("unexpected debug vars: %+v", vars)
+			}
+		}
+		resp.Body.Close()
+	}
+}
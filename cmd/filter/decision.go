@@ -0,0 +1,114 @@
+package main
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+)
+
+// Outcomes of decide, in the order its checks run.
+const (
+	verdictForward = iota
+	// verdictLoop is a message refused by loop prevention.
+	verdictLoop
+	verdictTooOld
+	verdictHeaderFiltered
+	// verdictNoKey is a record without a key on a compacted route.
+	verdictNoKey
+	// verdictInvalid is a payload that could not be decompressed or decoded.
+	verdictInvalid
+	verdictNoMatch
+	verdictPreempted
+	verdictDuplicate
+)
+
+// verdict is what a route decides for a source message before writing it.
+type verdict struct {
+	outcome int
+	// reason explains why the message is not forwarded; it is empty for verdictNoMatch.
+	reason string
+	// err is the decode error of verdictInvalid.
+	err error
+	// value is the payload that was matched, decompressed when the route forwards it so.
+	value []byte
+	// match is the first payload value that hit the cache.
+	match engine.Match
+	// matches holds every match of a dry run.
+	matches []engine.Match
+	// dedupKey identifies the message in the route's dedup window, if it has one.
+	dedupKey string
+}
+
+// screen runs the checks that only look at msg's headers and timestamp, returning
+// verdictForward when it passes them.
+func screen(route config.Route, guard loopGuard, headers headerRewriter, msg kafka.Message, now time.Time) verdict {
+	if reason := guard.check(msg.Headers); reason != "" {
+		return verdict{outcome: verdictLoop, reason: reason}
+	}
+	if reason := tooOld(route, msg, now); reason != "" {
+		return verdict{outcome: verdictTooOld, reason: reason}
+	}
+	if reason := headers.filter(msg.Headers); reason != "" {
+		return verdict{outcome: verdictHeaderFiltered, reason: reason}
+	}
+	return verdict{outcome: verdictForward}
+}
+
+// decide runs every check a route makes before forwarding msg, without counting,
+// publishing, or recording anything. A dry run, as made by POST /routes/{id}/test, injects
+// no chaos, collects every match, and leaves the payload out of schema sampling.
+func (b *bridge) decide(route config.Route, guard loopGuard, headers headerRewriter, matcher *engine.Matcher, msg kafka.Message, dryRun bool) verdict {
+	v := screen(route, guard, headers, msg, time.Now())
+	if v.outcome != verdictForward {
+		return v
+	}
+	if route.Compacted && len(msg.Key) == 0 {
+		return verdict{outcome: verdictNoKey, reason: "record has no key"}
+	}
+	v.value = msg.Value
+	if route.Payload.ForwardDecompressed {
+		var err error
+		if v.value, err = matcher.Decompress(msg.Value); err != nil {
+			return verdict{outcome: verdictInvalid, reason: err.Error(), err: err}
+		}
+	}
+	var matched bool
+	if dryRun {
+		result, err := matcher.Evaluate(v.value)
+		if err != nil {
+			return verdict{outcome: verdictInvalid, reason: err.Error(), err: err}
+		}
+		v.matches, matched = result.Matches, result.Forward
+		if matched {
+			v.match = result.Matches[0]
+		}
+	} else {
+		err := injectDecodeError(route.Chaos)
+		if err == nil {
+			v.match, matched, err = matcher.FirstMatch(v.value)
+		}
+		if err != nil {
+			return verdict{outcome: verdictInvalid, reason: err.Error(), err: err}
+		}
+	}
+	if !matched {
+		v.outcome = verdictNoMatch
+		return v
+	}
+	routeID := routeKey(route)
+	if winner := b.routeGroups.preemptedBy(routeID, msg.Value); winner != "" {
+		v.outcome, v.reason = verdictPreempted, "matched higher-priority route "+winner
+		return v
+	}
+	if dedup := b.routeDedup.get(routeID); dedup != nil {
+		v.dedupKey = dedup.key(route.SourceCluster, msg, v.value, matcher)
+		if at, dup := dedup.forwardedAt(v.dedupKey, time.Now()); dup {
+			v.outcome, v.reason = verdictDuplicate, "duplicate of a message forwarded at "+at.Format(time.RFC3339)
+			return v
+		}
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+// headerDecodeError carries the decode error of a message sent to a decodeErrorTopic.
+const headerDecodeError = "x-bridge-decode-error"
+
+type decodeErrorRegistry struct {
+	mu      sync.RWMutex
+	writers map[string]delivery.MessageWriter
+}
+
+func (r *decodeErrorRegistry) set(routeID string, w delivery.MessageWriter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writers[routeID] = w
+}
+
+func (r *decodeErrorRegistry) get(routeID string) delivery.MessageWriter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.writers[routeID]
+}
+
+// handleDecodeError applies the route's onDecodeError policy to a source message whose
+// payload could not be decompressed, decoded, or encoded in the route's output format.
+// It returns an error only when a write the
+// policy calls for fails, so the message is retried like any other.
+func (b *bridge) handleDecodeError(ctx context.Context, route config.Route, guard loopGuard, headers headerRewriter, destination delivery.MessageWriter, policy delivery.RetryPolicy, msg kafka.Message, decodeErr error) error {
+	routeID := routeKey(route)
+	stats := b.routeCounters.route(routeID)
+	stats.decodeErrors.Add(1)
+	switch route.OnDecodeError {
+	case config.OnDecodeErrorForward:
+		out := cloneMessage(msg)
+		out.Headers = headers.rewrite(out.Headers, msg, time.Now())
+		out.Headers = guard.stamp(out.Headers)
+		if err := delivery.Deliver(ctx, destination, policy, out); err != nil {
+			return fmt.Errorf("write undecodable offset %d to %s: %w", msg.Offset, destinationName(route), err)
+		}
+		now := time.Now()
+		stats.recordForward(msg.Partition, msg.Offset, now)
+		countSourceForward(ctx)
+		b.forwardEvents.publish(forwardEvent{Route: routeID, Decision: decisionForwarded, Partition: msg.Partition, Offset: msg.Offset, Reason: "undecodable payload forwarded unchanged: " + decodeErr.Error(), Destination: destinationName(route), At: now})
+		b.messageLogs.printf(routeID, "route %s: undecodable payload at offset %d forwarded unchanged: %v", route.DisplayName(), msg.Offset, decodeErr)
+	case config.OnDecodeErrorDLQ:
+		out := cloneMessage(msg)
+		out.Headers = append(out.Headers, kafka.Header{Key: headerDecodeError, Value: []byte(decodeErr.Error())})
+		if err := delivery.Deliver(ctx, b.routeDecodeErrors.get(routeID), policy, out); err != nil {
+			return fmt.Errorf("write undecodable offset %d to %s: %w", msg.Offset, route.DecodeErrorTopic, err)
+		}
+		b.publishDecision(routeID, decisionInvalid, msg, decodeErr.Error()+" (sent to "+route.DecodeErrorTopic+")")
+		b.messageLogs.printf(routeID, "route %s: undecodable payload at offset %d sent to %s: %v", route.DisplayName(), msg.Offset, route.DecodeErrorTopic, decodeErr)
+	default:
+		b.publishDecision(routeID, decisionInvalid, msg, decodeErr.Error())
+		b.messageLogs.printf(routeID, "route %s: invalid payload skipped: %v", route.DisplayName(), decodeErr)
+	}
+	return nil
+}
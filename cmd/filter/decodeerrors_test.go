@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestForwardMessageOnDecodeError(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matcher, err := engine.NewMatcher("route-decode", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	msg := kafka.Message{Offset: 7, Value: []byte("not json")}
+	for _, tc := range []struct {
+		policy              string
+		forwarded, diverted int
+	}{{config.OnDecodeErrorSkip, 0, 0}, {config.OnDecodeErrorForward, 1, 0}, {config.OnDecodeErrorDLQ, 0, 1}} {
+		route := config.Route{Name: "route-decode", DestinationTopic: "dest", OnDecodeError: tc.policy}
+		if tc.policy == config.OnDecodeErrorDLQ {
+			route.DecodeErrorTopic = "dest.undecodable"
+		}
+		destination, dlq := &recordingWriter{}, &recordingWriter{}
+		b.routeDecodeErrors.set(routeKey(route), dlq)
+		before := b.routeCounters.route(routeKey(route)).decodeErrors.Load()
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, destination, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("%s: forwardMessage: %v", tc.policy, err)
+		}
+		if len(destination.written) != tc.forwarded || len(dlq.written) != tc.diverted {
+			t.Fatalf("%s: forwarded %d, diverted %d", tc.policy, len(destination.written), len(dlq.written))
+		}
+		if tc.diverted == 1 && headerValue(dlq.written[0].Headers, headerDecodeError) == "" {
+			t.Fatalf("%s: missing %s header: %v", tc.policy, headerDecodeError, dlq.written[0].Headers)
+		}
+		if got := b.routeCounters.route(routeKey(route)).decodeErrors.Load() - before; got != 1 {
+			t.Fatalf("%s: decode errors counted %d times", tc.policy, got)
+		}
+	}
+	b.routeDecodeErrors.set("route-decode", nil)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// dedupSaveInterval is how often a persisted dedup window is written to its file.
+const dedupSaveInterval = 30 * time.Second
+
+type dedupRegistry struct {
+	mu      sync.RWMutex
+	windows map[string]*dedupWindow
+}
+
+func (r *dedupRegistry) set(routeID string, w *dedupWindow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.windows[routeID] = w
+}
+
+// get returns the route's window, or nil when it does not deduplicate.
+func (r *dedupRegistry) get(routeID string) *dedupWindow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.windows[routeID]
+}
+
+// dedupWindow remembers when each key was forwarded, forgetting keys older than the
+// window and, past maxEntries, the oldest ones.
+type dedupWindow struct {
+	cfg config.Dedup
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []dedupEntry
+}
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// newDedupWindow builds the window of a route, restoring it from cfg.Path if the file exists.
+func newDedupWindow(cfg config.Dedup) (*dedupWindow, error) {
+	w := &dedupWindow{cfg: cfg, seen: make(map[string]time.Time)}
+	if cfg.Path == "" {
+		return w, nil
+	}
+	saved, err := readDedupFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	w.merge(saved, time.Now())
+	return w, nil
+}
+
+// key identifies msg, read from cluster, for deduplication: its dedup field when
+// configured and present, otherwise its source cluster, topic, partition, and offset.
+func (w *dedupWindow) key(cluster string, msg kafka.Message, value []byte, matcher *engine.Matcher) string {
+	if w.cfg.Field != "" {
+		if id, err := matcher.SourceField(value, w.cfg.Field); err == nil {
+			return "field:" + id
+		}
+	}
+	return "offset:" + cluster + ":" + msg.Topic + ":" + strconv.Itoa(msg.Partition) + ":" + strconv.FormatInt(msg.Offset, 10)
+}
+
+// forwardedAt reports when key was last forwarded, if that is within the window.
+func (w *dedupWindow) forwardedAt(key string, now time.Time) (time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	at, ok := w.seen[key]
+	if !ok || now.Sub(at) >= w.cfg.Window {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// record remembers that key was forwarded at now.
+func (w *dedupWindow) record(key string, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seen[key] = now
+	w.order = append(w.order, dedupEntry{key: key, at: now})
+	w.pruneLocked(now)
+}
+
+// pruneLocked drops expired keys and, past maxEntries, the oldest ones. order may hold
+// stale entries for keys recorded again later; those are skipped.
+func (w *dedupWindow) pruneLocked(now time.Time) {
+	drop := 0
+	for ; drop < len(w.order); drop++ {
+		e := w.order[drop]
+		if at, ok := w.seen[e.key]; ok && at.Equal(e.at) {
+			if now.Sub(e.at) < w.cfg.Window && len(w.seen) <= w.cfg.MaxEntries {
+				break
+			}
+			delete(w.seen, e.key)
+		}
+	}
+	if drop > 0 {
+		w.order = append(w.order[:0:0], w.order[drop:]...)
+	}
+}
+
+// merge adds saved keys, keeping the later time for keys already remembered.
+func (w *dedupWindow) merge(saved map[string]time.Time, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, at := range saved {
+		if cur, ok := w.seen[key]; !ok || at.After(cur) {
+			w.seen[key] = at
+		}
+	}
+	w.order = w.order[:0]
+	for key, at := range w.seen {
+		w.order = append(w.order, dedupEntry{key: key, at: at})
+	}
+	sort.Slice(w.order, func(i, j int) bool { return w.order[i].at.Before(w.order[j].at) })
+	w.pruneLocked(now)
+}
+
+// save merges the keys another process wrote to the file, such as a replay of the
+// route, and writes the window back atomically.
+func (w *dedupWindow) save(now time.Time) error {
+	saved, err := readDedupFile(w.cfg.Path)
+	if err != nil {
+		return err
+	}
+	w.merge(saved, now)
+	w.mu.Lock()
+	data, err := json.Marshal(w.seen)
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(w.cfg.Path, data, 0o644)
+}
+
+// run saves a persisted window periodically and once more when ctx is done.
+func (w *dedupWindow) run(ctx context.Context, route string) {
+	if w.cfg.Path == "" {
+		return
+	}
+	ticker := time.NewTicker(dedupSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.save(time.Now()); err != nil {
+				log.Printf("route %s: save dedup window %s: %v", route, w.cfg.Path, err)
+			}
+			return
+		case <-ticker.C:
+			if err := w.save(time.Now()); err != nil {
+				log.Printf("route %s: save dedup window %s: %v", route, w.cfg.Path, err)
+			}
+		}
+	}
+}
+
+func readDedupFile(path string) (map[string]time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dedup window %s: %w", path, err)
+	}
+	var saved map[string]time.Time
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode dedup window %s: %w", path, err)
+	}
+	return saved, nil
+}
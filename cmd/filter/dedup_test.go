@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestForwardMessageDedupWindow(t *testing.T) {
+	b := newBridge(config.Logging{})
+	route := config.Route{Name: "route-dedup", DestinationTopic: "dest"}
+	matcher, err := engine.NewMatcher(routeKey(route), []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	matcher.AddValues([]string{"a"})
+	path := filepath.Join(t.TempDir(), "dedup.json")
+	window, err := newDedupWindow(config.Dedup{Window: time.Hour, Field: "eventId", MaxEntries: 10, Path: path})
+	if err != nil {
+		t.Fatalf("newDedupWindow: %v", err)
+	}
+	b.routeDedup.set(routeKey(route), window)
+
+	w := &recordingWriter{}
+	msgs := []kafka.Message{
+		{Offset: 0, Value: []byte(`{"id":"a","eventId":"e-1"}`)},
+		{Offset: 1, Value: []byte(`{"id":"a","eventId":"e-1"}`)}, // same event, new offset
+		{Offset: 2, Value: []byte(`{"id":"a","eventId":"e-2"}`)},
+	}
+	for _, msg := range msgs {
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
+	}
+	if len(w.written) != 2 || b.routeCounters.route(routeKey(route)).duplicates.Load() != 1 {
+		t.Fatalf("wrote %d message(s), %d duplicate(s); want 2 and 1", len(w.written), b.routeCounters.route(routeKey(route)).duplicates.Load())
+	}
+
+	// a replay sharing the file skips what the stream already forwarded
+	if err := window.save(time.Now()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	restored, err := newDedupWindow(config.Dedup{Window: time.Hour, Field: "eventId", MaxEntries: 10, Path: path})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, dup := restored.forwardedAt("field:e-2", time.Now()); !dup {
+		t.Fatal("restored window forgot e-2")
+	}
+	if _, dup := restored.forwardedAt("field:e-2", time.Now().Add(2*time.Hour)); dup {
+		t.Fatal("e-2 still a duplicate after the window")
+	}
+}
+
+func TestDedupWindowOffsetKey(t *testing.T) {
+	window, err := newDedupWindow(config.Dedup{Window: time.Hour, Field: "eventId", MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("newDedupWindow: %v", err)
+	}
+	matcher, err := engine.NewMatcher("route", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	value := []byte(`{"id":"a"}`)
+	key := window.key("a", kafka.Message{Topic: "orders-eu", Partition: 1, Offset: 7}, value, matcher)
+	for _, other := range []string{
+		window.key("a", kafka.Message{Topic: "orders-us", Partition: 1, Offset: 7}, value, matcher),
+		window.key("b", kafka.Message{Topic: "orders-eu", Partition: 1, Offset: 7}, value, matcher),
+	} {
+		if other == key {
+			t.Fatalf("messages at the same partition and offset of another topic or cluster share key %q", key)
+		}
+	}
+	if again := window.key("a", kafka.Message{Topic: "orders-eu", Partition: 1, Offset: 7}, value, matcher); again != key {
+		t.Fatalf("redelivery keyed %q, want %q", again, key)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/archive"
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+// newDestination returns the writer route forwards to, its destination topic or its
+// webhook, with the route's partitioner and oversize policy applied and its latency
+// measured. Messages the destination accepts are copied to sink unless it is nil. The
+// route's chaos, if any, acts on every write attempt.
+func (b *bridge) newDestination(route config.Route, writers *delivery.Pool, sink *archive.Sink) (delivery.MessageWriter, error) {
+	withPolicies := func(w delivery.MessageWriter) delivery.MessageWriter {
+		if route.Chaos != nil {
+			w = chaosWriter{MessageWriter: w, chaos: *route.Chaos}
+		}
+		if sink != nil {
+			w = archiveWriter{MessageWriter: w, sink: sink, route: routeKey(route), destination: destinationName(route)}
+		}
+		w = b.newOversizeWriter(route, w, writers)
+		return latencyWriter{MessageWriter: w, series: b.routeLatencies.get(routeKey(route), destinationName(route))}
+	}
+	if route.Destination.Type != config.DestinationWebhook {
+		return withPolicies(writers.TopicWith(route.DestinationTopic, delivery.WriterOptions{Partitioner: route.Delivery.Partitioner, Compression: route.Delivery.Compression, Async: route.Delivery.Async})), nil
+	}
+	hook := route.Destination.Webhook
+	tlsConfig, err := hook.TLSConfigObject()
+	if err != nil {
+		return nil, fmt.Errorf("webhook tls: %w", err)
+	}
+	client := &http.Client{Timeout: hook.Timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	webhook := delivery.NewWebhook(hook.URL,
+		delivery.WithHeaders(hook.Headers),
+		delivery.WithHTTPClient(client),
+		delivery.WithBatchSize(hook.BatchSize),
+	)
+	return withPolicies(webhook), nil
+}
+
+// evictIdleWriters closes the writers idle for timeout, checking every half timeout until
+// ctx is done.
+func evictIdleWriters(ctx context.Context, writers *delivery.Pool, timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			topics, err := writers.EvictIdle(now)
+			for _, topic := range topics {
+				log.Printf("event: closed the writer of %s after %s idle", topic, timeout)
+			}
+			if err != nil {
+				log.Printf("warn: %v", err)
+			}
+		}
+	}
+}
+
+// destinationName identifies route's destination in logs and events. Webhook URLs are
+// reported without credentials or query, which may carry tokens.
+func destinationName(route config.Route) string {
+	if route.Destination.Type != config.DestinationWebhook {
+		return route.DestinationTopic
+	}
+	return redactedURL(route.Destination.Webhook.URL, "webhook")
+}
+
+// redactedURL returns raw without its credentials and query, or fallback when it does not
+// parse.
+func redactedURL(raw, fallback string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fallback
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// batchCollector gathers the messages forwarded for a batch of source records, so they are
+// delivered in one write before the records are committed.
+type batchCollector struct {
+	msgs []kafka.Message
+}
+
+func (b *batchCollector) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	b.msgs = append(b.msgs, msgs...)
+	return nil
+}
+
+// sourceReader is the part of *kafka.Reader that streamBatches uses.
+type sourceReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// streamBatches is the read loop of a webhook route with batchSize above one. Source
+// records are matched as usual, and their forwarded messages are delivered together once
+// batchSize have matched or linger has passed since the first record of the batch. The
+// batch's records are committed only after its delivery succeeded. A route's maxInFlight
+// also flushes the batch once that many records are uncommitted, matched or not.
+func (b *bridge) streamBatches(ctx context.Context, reader sourceReader, route config.Route, forward func(context.Context, delivery.MessageWriter, kafka.Message) error, destination delivery.MessageWriter, policy delivery.RetryPolicy) error {
+	hook := route.Destination.Webhook
+	stats := b.routeCounters.route(routeKey(route))
+	batch := &batchCollector{}
+	var fetched []kafka.Message
+	var deadline time.Time
+
+	flush := func() error {
+		if len(batch.msgs) > 0 {
+			if err := delivery.Deliver(ctx, destination, policy, batch.msgs...); err != nil {
+				return fmt.Errorf("write batch of %d to %s: %w", len(batch.msgs), destinationName(route), err)
+			}
+		}
+		if len(fetched) > 0 {
+			if err := reader.CommitMessages(ctx, fetched...); err != nil {
+				return fmt.Errorf("commit offset %d: %w", fetched[len(fetched)-1].Offset, err)
+			}
+		}
+		batch.msgs, fetched = batch.msgs[:0], fetched[:0]
+		stats.inFlight.Store(0)
+		stats.inFlightBytes.Store(0)
+		return nil
+	}
+
+	for {
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(fetched) > 0 {
+			fetchCtx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if len(fetched) == 0 {
+			deadline = time.Now().Add(hook.Linger)
+		}
+		stats.consumed.Add(1)
+		stats.inFlight.Add(1)
+		stats.inFlightBytes.Add(messageBytes(msg))
+		fetched = append(fetched, msg)
+		if err := forward(countSource(ctx, route, stats, msg), batch, msg); err != nil {
+			return err
+		}
+		if len(batch.msgs) >= hook.BatchSize || (route.MaxInFlight > 0 && len(fetched) >= route.MaxInFlight) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
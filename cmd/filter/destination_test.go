@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/delivery"
+)
+
+func TestStreamBatchesMaxInFlight(t *testing.T) {
+	b := newBridge(config.Logging{})
+	route := config.Route{Name: "route-inflight", MaxInFlight: 3, Destination: config.Destination{
+		Type: config.DestinationWebhook, Webhook: config.Webhook{URL: "http://sink", BatchSize: 10, Linger: time.Hour}}}
+	reader := &scriptedReader{}
+	for i := 0; i < 7; i++ {
+		reader.msgs = append(reader.msgs, kafka.Message{Offset: int64(i)})
+	}
+	forward := func(ctx context.Context, w delivery.MessageWriter, msg kafka.Message) error {
+		if msg.Offset%2 == 0 {
+			return w.WriteMessages(ctx, msg)
+		}
+		return nil
+	}
+	dest := &recordingWriter{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := b.streamBatches(ctx, reader, route, forward, dest, delivery.RetryPolicy{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("streamBatches: %v", err)
+	}
+	if len(reader.committed) != 2 || len(reader.committed[0]) != 3 || len(reader.committed[1]) != 3 {
+		t.Fatalf("expected two commits of 3 records before the batch filled, got %v", reader.committed)
+	}
+	if len(dest.written) != 3 {
+		t.Fatalf("expected the 3 matched records of the flushed batches, got %d", len(dest.written))
+	}
+	if n := b.routeCounters.route("route-inflight").inFlight.Load(); n != 1 {
+		t.Fatalf("expected 1 record in flight, got %d", n)
+	}
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf, b.routeCounters.metrics()); err != nil {
+		t.Fatalf("metrics.Write: %v", err)
+	}
+	if !strings.Contains(buf.String(), `kafka_bridge_route_in_flight{route="route-inflight"} 1`) {
+		t.Fatalf("in-flight gauge missing:\n%s", buf.String())
+	}
+}
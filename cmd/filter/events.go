@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kafka-bridge/pkg/engine"
+)
+
+// Decisions reported in forwarding events.
+const (
+	decisionForwarded = "forwarded"
+	// decisionSkipped is a valid message that matched no cached value, or a record without
+	// a key on a compacted route.
+	decisionSkipped = "skipped"
+	// decisionInvalid is a message whose payload could not be decoded.
+	decisionInvalid = "invalid"
+	// decisionDropped is a message refused by loop prevention or older than maxMessageAge.
+	decisionDropped = "dropped"
+)
+
+// forwardEvent describes what a route decided for one source message.
+type forwardEvent struct {
+	Route     string
+	Decision  string
+	Partition int
+	Offset    int64
+	// Match is set for forwarded messages.
+	Match engine.Match
+	// Reason explains invalid and dropped messages.
+	Reason      string
+	Destination string
+	At          time.Time
+}
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscription]struct{}
+	// active lets publishers skip building events while nobody is watching.
+	active atomic.Int32
+}
+
+// subscription receives the events of one route, or of every route when route is empty,
+// that accept lets through.
+type subscription struct {
+	hub     *eventHub
+	route   string
+	accept  func(forwardEvent) bool
+	events  chan forwardEvent
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// subscribe registers a watcher with room for buffer events. Events are dropped rather
+// than block a route when the buffer is full; accept may be nil to receive everything.
+func (h *eventHub) subscribe(route string, buffer int, accept func(forwardEvent) bool) *subscription {
+	sub := &subscription{hub: h, route: route, accept: accept, events: make(chan forwardEvent, buffer)}
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	h.active.Add(1)
+	return sub
+}
+
+// watching reports whether any subscription is registered.
+func (h *eventHub) watching() bool {
+	return h.active.Load() > 0
+}
+
+func (h *eventHub) publish(ev forwardEvent) {
+	if !h.watching() {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if sub.route != "" && sub.route != ev.Route || sub.accept != nil && !sub.accept(ev) {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// takeDropped returns how many events were dropped since the last call.
+func (s *subscription) takeDropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *subscription) close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subscribers, s)
+		s.hub.mu.Unlock()
+		s.hub.active.Add(-1)
+	})
+}
+
+// eventsBuffer is how many events a slow /routes/{id}/events client may fall behind by
+// before events are dropped and a dropped notice is sent instead.
+const eventsBuffer = 512
+
+// eventsKeepalive is how often an idle event stream sends a comment so proxies keep it open.
+const eventsKeepalive = 15 * time.Second
+
+// eventFilter builds the subscription filter of an events request from its decision,
+// field, and value query parameters.
+func eventFilter(q url.Values) (func(forwardEvent) bool, error) {
+	decisions := map[string]bool{}
+	for _, d := range strings.Split(q.Get("decision"), ",") {
+		switch d = strings.TrimSpace(d); d {
+		case "":
+		case decisionForwarded, decisionSkipped, decisionInvalid, decisionDropped:
+			decisions[d] = true
+		default:
+			return nil, fmt.Errorf("unknown decision %q (want forwarded, skipped, invalid, or dropped)", d)
+		}
+	}
+	field, value := q.Get("field"), q.Get("value")
+	return func(ev forwardEvent) bool {
+		if len(decisions) > 0 && !decisions[ev.Decision] {
+			return false
+		}
+		if field != "" && ev.Match.Field != field {
+			return false
+		}
+		return value == "" || ev.Match.Value == value || ev.Match.Fingerprint == value
+	}, nil
+}
+
+// serveRouteEvents streams the decisions of routeID as server-sent events until the
+// client disconnects.
+func (b *bridge) serveRouteEvents(w http.ResponseWriter, r *http.Request, routeID string) {
+	accept, err := eventFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sub := b.forwardEvents.subscribe(routeID, eventsBuffer, accept)
+	defer sub.close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			_, err = io.WriteString(w, ": keepalive\n\n")
+		case ev := <-sub.events:
+			if dropped := sub.takeDropped(); dropped > 0 {
+				if err = writeEvent(w, "dropped", map[string]uint64{"dropped": dropped}); err != nil {
+					return
+				}
+			}
+			err = writeEvent(w, "decision", toRouteEvent(ev))
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func toRouteEvent(ev forwardEvent) RouteEvent {
+	out := RouteEvent{
+		Route:     ev.Route,
+		Decision:  ev.Decision,
+		Partition: ev.Partition,
+		Offset:    ev.Offset,
+		Reason:    ev.Reason,
+		At:        ev.At,
+	}
+	if ev.Decision == decisionForwarded {
+		origin := ev.Match.Origin
+		out.Field, out.Value, out.Fingerprint, out.Origin = ev.Match.Field, ev.Match.Value, ev.Match.Fingerprint, &origin
+		out.Destination = ev.Destination
+	}
+	return out
+}
+
+func writeEvent(w io.Writer, name string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, raw)
+	return err
+}
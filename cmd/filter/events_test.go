@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteEventsStream(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-events", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"hit"})
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-events": matcher}, store: matchStore}))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/routes/route-events/events?decision=bogus")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown decision, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/routes/route-events/events?decision=skipped,invalid", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	route := config.Route{Name: "route-events", DestinationTopic: "dest"}
+	for i, value := range []string{`{"fieldA":"hit"}`, `{"fieldA":"miss"}`} {
+		msg := kafka.Message{Partition: 0, Offset: int64(i), Value: []byte(value)}
+		if err := b.forwardMessage(ctx, route, loopGuard{}, headerRewriter{}, matcher, &recordingWriter{}, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
+	}
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for lines.Scan() && len(got) < 2 {
+		if line := lines.Text(); line != "" {
+			got = append(got, line)
+		}
+	}
+	if len(got) != 2 || got[0] != "event: decision" {
+		t.Fatalf("unexpected stream: %q", got)
+	}
+	var ev RouteEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(got[1], "data: ")), &ev); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if ev.Decision != decisionSkipped || ev.Offset != 1 || ev.Route != "route-events" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
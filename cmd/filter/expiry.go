@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+)
+
+// expiryWarning is how far ahead of its expiry a route is flagged at startup.
+const expiryWarning = 24 * time.Hour
+
+type expiryRegistry struct {
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+	expired   map[string]bool
+}
+
+// register records route's expiry and reports whether it has already passed.
+func (r *expiryRegistry) register(route config.Route, at time.Time, now time.Time) bool {
+	r.mu.Lock()
+	r.deadlines[routeKey(route)] = at
+	r.mu.Unlock()
+	if !now.Before(at) {
+		return true
+	}
+	if at.Sub(now) <= expiryWarning {
+		log.Printf("warn: route %s expires at %s", route.DisplayName(), at.Format(time.RFC3339))
+	}
+	return false
+}
+
+// pause marks route expired and raises the expiry event.
+func (r *expiryRegistry) pause(route config.Route, at time.Time) {
+	r.mu.Lock()
+	r.expired[routeKey(route)] = true
+	r.mu.Unlock()
+	owner := ""
+	if len(route.Annotations) > 0 {
+		owner = " (" + formatAnnotations(route.Annotations) + ")"
+	}
+	log.Printf("event: route %s%s expired at %s and is paused; remove it from the config or extend its expiry", route.DisplayName(), owner, at.Format(time.RFC3339))
+}
+
+// paused reports whether routeID has passed its expiry.
+func (r *expiryRegistry) paused(routeID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expired[routeID]
+}
+
+func (r *expiryRegistry) metrics() []metrics.Family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := make([]string, 0, len(r.deadlines))
+	for id := range r.deadlines {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+
+	deadline := metrics.Family{Name: "kafka_bridge_route_expiry_timestamp_seconds", Help: "Unix time at which the route pauses.", Type: metrics.TypeGauge}
+	expired := metrics.Family{Name: "kafka_bridge_route_expired", Help: "1 when the route has passed its expiry and is paused.", Type: metrics.TypeGauge}
+	for _, id := range routes {
+		labels := metrics.Labels{"route": id}
+		deadline.Add(labels, float64(r.deadlines[id].Unix()))
+		value := 0.0
+		if r.expired[id] {
+			value = 1
+		}
+		expired.Add(labels, value)
+	}
+	return []metrics.Family{deadline, expired}
+}
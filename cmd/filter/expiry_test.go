@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+)
+
+func TestExpiryRegistry(t *testing.T) {
+	reg := &expiryRegistry{deadlines: make(map[string]time.Time), expired: make(map[string]bool)}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	past := config.Route{Name: "investigation"}
+	future := config.Route{Name: "route-b"}
+	if !reg.register(past, now.Add(-time.Hour), now) {
+		t.Fatalf("expected past expiry to be reported as expired")
+	}
+	reg.pause(past, now.Add(-time.Hour))
+	if reg.register(future, now.Add(time.Hour), now) {
+		t.Fatalf("expected future expiry to be active")
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf, reg.metrics()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, want := range []string{
+		`kafka_bridge_route_expired{route="investigation"} 1`,
+		`kafka_bridge_route_expired{route="route-b"} 0`,
+		`kafka_bridge_route_expiry_timestamp_seconds{route="route-b"} 1.7145252e+09`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+)
+
+type groupRegistry struct {
+	mu    sync.RWMutex
+	ahead map[string][]groupMember
+	// expiries tells which routes ahead no longer claim messages.
+	expiries *expiryRegistry
+}
+
+type groupMember struct {
+	routeID string
+	matcher *engine.Matcher
+}
+
+// register orders the routes of every group by priority, then config order, replacing
+// any previous registration.
+func (r *groupRegistry) register(routes []config.Route, matchers map[string]*engine.Matcher) {
+	groups := make(map[string][]config.Route)
+	for _, route := range routes {
+		if route.Group != "" {
+			groups[route.Group] = append(groups[route.Group], route)
+		}
+	}
+	ahead := make(map[string][]groupMember)
+	for _, members := range groups {
+		sort.SliceStable(members, func(i, j int) bool { return members[i].Priority < members[j].Priority })
+		var before []groupMember
+		for _, route := range members {
+			id := routeKey(route)
+			ahead[id] = before
+			before = append(append([]groupMember(nil), before...), groupMember{routeID: id, matcher: matchers[id]})
+		}
+	}
+	r.mu.Lock()
+	r.ahead = ahead
+	r.mu.Unlock()
+}
+
+// preemptedBy returns the first route ahead of routeID in its group that matches value,
+// or "" when routeID may forward it. Expired routes no longer claim messages, and a
+// payload a route cannot decode does not match it.
+func (r *groupRegistry) preemptedBy(routeID string, value []byte) string {
+	r.mu.RLock()
+	ahead := r.ahead[routeID]
+	r.mu.RUnlock()
+	for _, m := range ahead {
+		if m.matcher == nil || r.expiries.paused(m.routeID) {
+			continue
+		}
+		if res, err := m.matcher.Evaluate(value); err == nil && res.Forward {
+			return m.routeID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteGroupFirstMatchWins(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	routes := []config.Route{
+		{Name: "grp-low", DestinationTopic: "low", Group: "orders", Priority: 20},
+		{Name: "grp-high", DestinationTopic: "high", Group: "orders", Priority: 10},
+	}
+	matchers := make(map[string]*engine.Matcher)
+	for _, route := range routes {
+		m, err := engine.NewMatcher(routeKey(route), []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, matchStore)
+		if err != nil {
+			t.Fatalf("NewMatcher: %v", err)
+		}
+		matchers[routeKey(route)] = m
+	}
+	matchers["grp-high"].AddValues([]string{"both"})
+	matchers["grp-low"].AddValues([]string{"both", "low-only"})
+	b.routeGroups.register(routes, matchers)
+
+	written := map[string]*recordingWriter{"grp-low": {}, "grp-high": {}}
+	for i, value := range []string{`{"id":"both"}`, `{"id":"low-only"}`} {
+		msg := kafka.Message{Offset: int64(i), Value: []byte(value)}
+		for _, route := range routes {
+			if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matchers[routeKey(route)], written[routeKey(route)], delivery.RetryPolicy{}, msg); err != nil {
+				t.Fatalf("forwardMessage: %v", err)
+			}
+		}
+	}
+	if got := written["grp-high"].written; len(got) != 1 || string(got[0].Value) != `{"id":"both"}` {
+		t.Fatalf("high-priority route wrote %v", got)
+	}
+	if got := written["grp-low"].written; len(got) != 1 || string(got[0].Value) != `{"id":"low-only"}` {
+		t.Fatalf("low-priority route wrote %v", got)
+	}
+	if n := b.routeCounters.route("grp-low").preempted.Load(); n != 1 {
+		t.Fatalf("expected 1 preempted message, got %d", n)
+	}
+}
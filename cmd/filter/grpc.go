@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/store"
+	bridgev1 "kafka-bridge/proto/kafkabridge/v1"
+)
+
+// watchBuffer is how many match events a slow WatchMatches client may fall behind by
+// before events are dropped.
+const watchBuffer = 256
+
+func startGRPCServer(ctx context.Context, addr string, admin adminDeps) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := newGRPCServer(admin)
+
+	go func() {
+		<-ctx.Done()
+		// watch streams only end when their clients go away, so bound the graceful stop
+		timer := time.AfterFunc(5*time.Second, server.Stop)
+		defer timer.Stop()
+		server.GracefulStop()
+	}()
+
+	log.Printf("grpc server listening on %s", addr)
+	if err := server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func newGRPCServer(admin adminDeps) *grpc.Server {
+	server := grpc.NewServer()
+	bridgev1.RegisterReferenceServiceServer(server, referenceService{admin: admin})
+	return server
+}
+
+// referenceService implements the gRPC reference API on top of the same admin commands
+// as the HTTP endpoints, so mutations are coordinated and logged identically.
+type referenceService struct {
+	bridgev1.UnimplementedReferenceServiceServer
+	admin adminDeps
+}
+
+func (s referenceService) AddValues(ctx context.Context, req *bridgev1.AddValuesRequest) (*bridgev1.MutationResponse, error) {
+	return s.mutate(ctx, kafkapkg.Command{Op: kafkapkg.CommandInject, Route: req.GetRoute(), Values: req.GetValues(), Annotations: req.GetAnnotations()}, req.GetIdempotencyKey())
+}
+
+func (s referenceService) RemoveValues(ctx context.Context, req *bridgev1.RemoveValuesRequest) (*bridgev1.MutationResponse, error) {
+	return s.mutate(ctx, kafkapkg.Command{Op: kafkapkg.CommandDelete, Route: req.GetRoute(), Values: req.GetValues()}, req.GetIdempotencyKey())
+}
+
+func (s referenceService) mutate(ctx context.Context, cmd kafkapkg.Command, idempotencyKey string) (_ *bridgev1.MutationResponse, err error) {
+	ctx, finish := s.admin.auditGRPC(ctx, idempotencyKey)
+	defer func() { finish(err) }()
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if s.admin.readOnly {
+		return nil, status.Error(codes.PermissionDenied, "admin API is read-only")
+	}
+	if !s.admin.stateRecovery.ready() {
+		return nil, status.Error(codes.Unavailable, "cache is still being restored from storage")
+	}
+	if cmd.Route != "" {
+		if _, ok := s.admin.matchers[cmd.Route]; !ok {
+			return nil, status.Error(codes.NotFound, "route not found")
+		}
+	}
+	if len(cmd.Values) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "values required")
+	}
+	changed, err := s.admin.submit(ctx, idempotencyKey, cmd)
+	if errors.Is(err, errMemoryBudget) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, errBroadcast) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &bridgev1.MutationResponse{Changed: changed}, nil
+}
+
+func (s referenceService) QueryCache(_ context.Context, req *bridgev1.QueryCacheRequest) (*bridgev1.QueryCacheResponse, error) {
+	if _, ok := s.admin.matchers[req.GetRoute()]; !ok {
+		return nil, status.Error(codes.NotFound, "route not found")
+	}
+	cache := s.admin.routeCache(req.GetRoute(), req.GetFingerprints())
+	resp := &bridgev1.QueryCacheResponse{Route: cache.Route, Annotations: cache.Annotations, Values: make([]*bridgev1.CachedValue, 0, len(cache.Values))}
+	for _, v := range cache.Values {
+		resp.Values = append(resp.Values, &bridgev1.CachedValue{Fingerprint: v.Fingerprint, Canonical: v.Canonical, Origin: originProto(v.Origin)})
+	}
+	return resp, nil
+}
+
+func (s referenceService) WatchMatches(req *bridgev1.WatchMatchesRequest, stream grpc.ServerStreamingServer[bridgev1.MatchEvent]) error {
+	if route := req.GetRoute(); route != "" {
+		if _, ok := s.admin.matchers[route]; !ok {
+			return status.Error(codes.NotFound, "route not found")
+		}
+	}
+	sub := s.admin.forwardEvents.subscribe(req.GetRoute(), watchBuffer, func(ev forwardEvent) bool { return ev.Decision == decisionForwarded })
+	defer sub.close()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-sub.events:
+			err := stream.Send(&bridgev1.MatchEvent{
+				Route:            ev.Route,
+				Field:            ev.Match.Field,
+				Value:            ev.Match.Value,
+				Fingerprint:      ev.Match.Fingerprint,
+				Origin:           originProto(ev.Match.Origin),
+				SourcePartition:  int32(ev.Partition),
+				SourceOffset:     ev.Offset,
+				DestinationTopic: ev.Destination,
+				ForwardedAt:      timestamppb.New(ev.At),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// authorize requires "authorization: Bearer <adminToken>" metadata when an admin token is
+// configured, like adminDeps.authorized does for HTTP.
+func (s referenceService) authorize(ctx context.Context) error {
+	if s.admin.adminToken == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.admin.adminToken)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func originProto(m store.Metadata) *bridgev1.Origin {
+	origin := &bridgev1.Origin{
+		Source:      m.Source,
+		Feed:        m.Feed,
+		Topic:       m.Topic,
+		Partition:   int32(m.Partition),
+		Offset:      m.Offset,
+		Annotations: m.Annotations,
+	}
+	if !m.AddedAt.IsZero() {
+		origin.AddedAt = timestamppb.New(m.AddedAt)
+	}
+	return origin
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+	bridgev1 "kafka-bridge/proto/kafkabridge/v1"
+)
+
+func TestGRPCReferenceService(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-grpc", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	admin := adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-grpc": matcher}, store: matchStore, adminToken: "secret"}
+	lis := bufconn.Listen(1 << 20)
+	server := newGRPCServer(admin)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := bridgev1.NewReferenceServiceClient(conn)
+	ctx := context.Background()
+
+	req := &bridgev1.AddValuesRequest{Route: "route-grpc", Values: []string{"abc"}, Annotations: map[string]string{"owner": "ops"}}
+	if _, err := client.AddValues(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without token, got %v", err)
+	}
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	resp, err := client.AddValues(authed, req)
+	if err != nil || !resp.GetChanged() {
+		t.Fatalf("AddValues = %v, %v", resp, err)
+	}
+	if _, err := client.AddValues(authed, &bridgev1.AddValuesRequest{Route: "route-x", Values: []string{"abc"}}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for unknown route, got %v", err)
+	}
+
+	cache, err := client.QueryCache(ctx, &bridgev1.QueryCacheRequest{Route: "route-grpc"})
+	if err != nil {
+		t.Fatalf("QueryCache: %v", err)
+	}
+	if len(cache.GetValues()) != 1 || cache.GetValues()[0].GetFingerprint() != "abc" || cache.GetValues()[0].GetOrigin().GetAnnotations()["owner"] != "ops" {
+		t.Fatalf("unexpected cache: %v", cache)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchMatches(watchCtx, &bridgev1.WatchMatchesRequest{Route: "route-grpc"})
+	if err != nil {
+		t.Fatalf("WatchMatches: %v", err)
+	}
+	// the subscription is registered asynchronously; publish until the event arrives
+	received := make(chan *bridgev1.MatchEvent, 1)
+	go func() {
+		if ev, err := stream.Recv(); err == nil {
+			received <- ev
+		}
+	}()
+	route := config.Route{Name: "route-grpc", DestinationTopic: "dest"}
+	w := &recordingWriter{}
+	deadline := time.After(5 * time.Second)
+	for offset := int64(0); ; offset++ {
+		msg := kafka.Message{Partition: 1, Offset: offset, Value: []byte(`{"fieldA":"abc"}`)}
+		if err := b.forwardMessage(ctx, route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
+		select {
+		case ev := <-received:
+			if ev.GetRoute() != "route-grpc" || ev.GetFingerprint() != "abc" || ev.GetDestinationTopic() != "dest" || ev.GetSourcePartition() != 1 {
+				t.Fatalf("unexpected event: %v", ev)
+			}
+			return
+		case <-deadline:
+			t.Fatal("no match event received")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+)
+
+// Provenance headers stamped on forwarded messages when a route sets headers.provenance.
+// The source offset reuses headerSourceOffset from the explain headers.
+const (
+	headerSourceCluster   = "x-bridge-source-cluster"
+	headerSourceTopic     = "x-bridge-source-topic"
+	headerSourcePartition = "x-bridge-source-partition"
+	headerInstance        = "x-bridge-instance"
+	headerForwardedAt     = "x-bridge-forwarded-at"
+)
+
+// headerRewriter applies a route's header policy to forwarded messages. The zero value
+// forwards the source headers unchanged.
+type headerRewriter struct {
+	include    []string
+	exclude    []string
+	provenance bool
+	cluster    string
+	topic      string
+	bridgeID   string
+	// accept holds the route's sourceHeaderFilters, checked by filter.
+	accept map[string][]string
+}
+
+func newHeaderRewriter(cfg *config.Config, route config.Route) headerRewriter {
+	return headerRewriter{
+		include:    lowerAll(route.Headers.Include),
+		exclude:    lowerAll(route.Headers.Exclude),
+		provenance: route.Headers.Provenance,
+		cluster:    route.SourceCluster,
+		topic:      route.SourceTopic,
+		bridgeID:   cfg.LoopPrevention.BridgeID,
+		accept:     route.SourceHeaderValues(),
+	}
+}
+
+// filter returns why a source message with headers fails the route's
+// sourceHeaderFilters, or "" when it passes. It only looks at headers, so messages it
+// rejects are skipped without decoding their payload.
+func (h headerRewriter) filter(headers []kafka.Header) string {
+	if len(h.accept) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(h.accept))
+	for name := range h.accept {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var found bool
+		var value []byte
+		for _, hdr := range headers {
+			if strings.EqualFold(hdr.Key, name) {
+				found, value = true, hdr.Value
+				break
+			}
+		}
+		switch {
+		case !found:
+			return "no " + name + " header"
+		case !slices.Contains(h.accept[name], string(value)):
+			return fmt.Sprintf("header %s is %q, want %s", name, value, strings.Join(h.accept[name], " or "))
+		}
+	}
+	return ""
+}
+
+// rewrite filters headers, which belong to msg, and appends the provenance headers.
+// Provenance stamped by an upstream bridge is replaced, so it names the latest hop.
+func (h headerRewriter) rewrite(headers []kafka.Header, msg kafka.Message, now time.Time) []kafka.Header {
+	if len(h.include) == 0 && len(h.exclude) == 0 && !h.provenance {
+		return headers
+	}
+	out := make([]kafka.Header, 0, len(headers)+6)
+	for _, hdr := range headers {
+		key := strings.ToLower(hdr.Key)
+		switch key {
+		case headerHops, headerPath:
+			out = append(out, hdr)
+			continue
+		case headerSourceCluster, headerSourceTopic, headerSourcePartition, headerSourceOffset, headerInstance, headerForwardedAt:
+			if h.provenance {
+				continue
+			}
+		}
+		if len(h.include) > 0 && !matchesHeader(h.include, key) || matchesHeader(h.exclude, key) {
+			continue
+		}
+		out = append(out, hdr)
+	}
+	if !h.provenance {
+		return out
+	}
+	topic := msg.Topic
+	if topic == "" {
+		topic = h.topic
+	}
+	return append(out,
+		kafka.Header{Key: headerSourceCluster, Value: []byte(h.cluster)},
+		kafka.Header{Key: headerSourceTopic, Value: []byte(topic)},
+		kafka.Header{Key: headerSourcePartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: headerSourceOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: headerInstance, Value: []byte(h.bridgeID)},
+		kafka.Header{Key: headerForwardedAt, Value: []byte(now.UTC().Format(time.RFC3339Nano))},
+	)
+}
+
+// matchesHeader reports whether the lower-cased key matches one of patterns.
+func matchesHeader(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]string, len(in))
+	for i, s := range in {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
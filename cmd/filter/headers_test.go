@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestForwardMessageSourceHeaderFilters(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-headers", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"hit"})
+	route := config.Route{Name: "route-headers", DestinationTopic: "dest", SourceHeaderFilters: []string{"event-type=ORDER", "event-type=REFUND", "region=eu"}}
+	headers := newHeaderRewriter(&config.Config{}, route)
+	stats := b.routeCounters.route(routeKey(route))
+	before := stats.report(routeKey(route), 0, time.Now())
+	w := &recordingWriter{}
+
+	for i, hdrs := range [][]kafka.Header{
+		{{Key: "Event-Type", Value: []byte("ORDER")}, {Key: "region", Value: []byte("eu")}},
+		{{Key: "event-type", Value: []byte("REFUND")}, {Key: "region", Value: []byte("eu")}},
+		{{Key: "event-type", Value: []byte("PAYMENT")}, {Key: "region", Value: []byte("eu")}},
+		{{Key: "event-type", Value: []byte("ORDER")}},
+	} {
+		// the filtered messages are never decoded, so a broken payload is not an error
+		value := `{"x":"hit"}`
+		if i >= 2 {
+			value = `{broken`
+		}
+		msg := kafka.Message{Offset: int64(i), Headers: hdrs, Value: []byte(value)}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headers, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage(%d): %v", i, err)
+		}
+	}
+	if len(w.written) != 2 {
+		t.Fatalf("expected the two messages passing the filters forwarded, got %d", len(w.written))
+	}
+	after := stats.report(routeKey(route), 0, time.Now())
+	if after.HeaderFiltered-before.HeaderFiltered != 2 || after.DecodeErrors != before.DecodeErrors || after.Skipped != before.Skipped {
+		t.Fatalf("unexpected counters: headerFiltered=%d decodeErrors=%d skipped=%d", after.HeaderFiltered-before.HeaderFiltered, after.DecodeErrors-before.DecodeErrors, after.Skipped-before.Skipped)
+	}
+	if reason := headers.filter([]kafka.Header{{Key: "event-type", Value: []byte("PAYMENT")}, {Key: "region", Value: []byte("eu")}}); reason != `header event-type is "PAYMENT", want ORDER or REFUND` {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+	if reason := (headerRewriter{}).filter(nil); reason != "" {
+		t.Fatalf("expected a route without filters to pass everything, got %q", reason)
+	}
+}
+
+func TestHeaderRewriter(t *testing.T) {
+	cfg := &config.Config{LoopPrevention: config.LoopPrevention{BridgeID: "eu"}}
+	route := config.Route{
+		SourceCluster: "source-a",
+		SourceTopic:   "orders",
+		Headers: config.HeaderPolicy{
+			Provenance: true,
+			Include:    []string{"Trace-*", "content-type", "authorization"},
+			Exclude:    []string{"authorization"},
+		},
+	}
+	rewriter := newHeaderRewriter(cfg, route)
+	headers := []kafka.Header{
+		{Key: "trace-id", Value: []byte("t1")},
+		{Key: "Content-Type", Value: []byte("json")},
+		{Key: "Authorization", Value: []byte("secret")},
+		{Key: "other", Value: []byte("x")},
+		{Key: headerHops, Value: []byte("1")},
+		{Key: headerSourceCluster, Value: []byte("upstream")},
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	got := rewriter.rewrite(headers, kafka.Message{Partition: 4, Offset: 99}, now)
+
+	var keys []string
+	values := map[string]string{}
+	for _, h := range got {
+		keys = append(keys, h.Key)
+		values[h.Key] = string(h.Value)
+	}
+	want := []string{"trace-id", "Content-Type", headerHops, headerSourceCluster, headerSourceTopic, headerSourcePartition, headerSourceOffset, headerInstance, headerForwardedAt}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("headers = %v, want %v", keys, want)
+	}
+	if values[headerSourceCluster] != "source-a" || values[headerSourceTopic] != "orders" || values[headerSourcePartition] != "4" ||
+		values[headerSourceOffset] != "99" || values[headerInstance] != "eu" || values[headerForwardedAt] != "2024-05-01T12:00:00Z" {
+		t.Fatalf("unexpected provenance: %v", values)
+	}
+
+	if out := (headerRewriter{}).rewrite(headers, kafka.Message{}, now); len(out) != len(headers) {
+		t.Fatalf("zero rewriter should keep headers, got %v", out)
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func startHTTPServer(ctx context.Context, addr string, admin adminDeps) error {
+	mux := buildHTTPMux(admin)
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("http server listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func buildHTTPMux(admin adminDeps) *http.ServeMux {
+	matchers, matchStore := admin.matchers, admin.store
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		snapshot := matchStore.Snapshot()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			log.Printf("cache snapshot encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status := admin.bridgeReadiness()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Printf("readiness encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		families := append(cacheMetrics(admin), admin.routeExpiries.metrics()...)
+		families = append(families, admin.routeCounters.metrics()...)
+		families = append(families, admin.memoryBudget.metrics(admin.cfg)...)
+		families = append(families, writerMetrics(admin.writers)...)
+		families = append(families, admin.routeLatencies.metrics()...)
+		families = append(families, admin.leaderMetrics(admin.electing)...)
+		families = append(families, admin.routeAssignments.metrics()...)
+		families = append(families, buildInfoMetrics()...)
+		if err := metrics.Write(w, families); err != nil {
+			log.Printf("metrics write failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/cache/{routeID}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("routeID")
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		resp := admin.routeCache(routeID, nil)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("route cache encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/cache/clear", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := admin.submit(r.Context(), r.Header.Get(idempotencyHeader), kafkapkg.Command{Op: kafkapkg.CommandClear}); err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	}))
+	mux.HandleFunc("/cache/compact", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		added, removed := compactMatchers(matchers)
+		if rec := auditFrom(r.Context()); rec != nil {
+			rec.Op, rec.Changed = "compact", added+removed > 0
+		}
+		log.Printf("cache compacted via HTTP (added=%d removed=%d)", added, removed)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(CompactResult{Added: added, Removed: removed}); err != nil {
+			log.Printf("compact result encode failed: %v", err)
+		}
+	}))
+	mux.HandleFunc("/referenceAllRoutes", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		defer r.Body.Close()
+		req, err := decodeReferenceRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		added, err := admin.submit(r.Context(), r.Header.Get(idempotencyHeader), kafkapkg.Command{Op: kafkapkg.CommandInject, Values: req.Values, Annotations: req.Annotations})
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		status := http.StatusOK
+		if added {
+			status = http.StatusCreated
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok\n"))
+	}))
+	mux.HandleFunc("/schema", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if admin.schema == nil {
+			http.Error(w, "schema drift tracking disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(admin.schema.Reports()); err != nil {
+			log.Printf("schema reports encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/schema/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if admin.schema == nil {
+			http.Error(w, "schema drift tracking disabled", http.StatusNotFound)
+			return
+		}
+		report, ok := admin.schema.Report(r.PathValue("topic"))
+		if !ok {
+			http.Error(w, "topic not observed", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("schema report encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(routeInfos(admin, time.Now())); err != nil {
+			log.Printf("routes encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/routes/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		stats := admin.routeCounters.route(routeID).report(routeID, matchStore.Size(routeID), time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.Printf("route stats encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/routes/{id}/assignments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(RouteAssignments{Route: routeID, Readers: admin.routeAssignments.route(routeID)}); err != nil {
+			log.Printf("route assignments encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/routes/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		admin.serveRouteEvents(w, r, routeID)
+	})
+	mux.HandleFunc("/routes/{id}/test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		matcher, ok := matchers[routeID]
+		if !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		defer r.Body.Close()
+		var req TestMatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON test request", http.StatusBadRequest)
+			return
+		}
+		if len(req.Payload) == 0 {
+			http.Error(w, "payload required", http.StatusBadRequest)
+			return
+		}
+
+		resp := admin.testMatch(routeID, matcher, req)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("test match encode failed: %v", err)
+		}
+	})
+	mux.HandleFunc("/routes/{id}/split", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		defer r.Body.Close()
+		var req SplitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == "" {
+			http.Error(w, "invalid JSON split request", http.StatusBadRequest)
+			return
+		}
+		cloned, err := admin.submit(r.Context(), r.Header.Get(idempotencyHeader), kafkapkg.Command{Op: kafkapkg.CommandSplit, Route: routeID, Target: req.Into, Values: req.Feeds})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status := http.StatusOK
+		if cloned {
+			status = http.StatusCreated
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok\n"))
+	}))
+	mux.HandleFunc("/reference/", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		op := kafkapkg.CommandInject
+		switch r.Method {
+		case http.MethodPost:
+		case http.MethodDelete:
+			op = kafkapkg.CommandDelete
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := strings.TrimPrefix(r.URL.Path, "/reference/")
+		if routeID == "" {
+			http.Error(w, "route id required", http.StatusBadRequest)
+			return
+		}
+		if _, ok := matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		defer r.Body.Close()
+		req, err := decodeReferenceRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changed, err := admin.submit(r.Context(), r.Header.Get(idempotencyHeader), kafkapkg.Command{Op: op, Route: routeID, Values: req.Values, Annotations: req.Annotations})
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		status := http.StatusOK
+		if changed && op == kafkapkg.CommandInject {
+			status = http.StatusCreated
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok\n"))
+	}))
+	registerCacheTransfer(mux, admin)
+	admin.registerSeek(mux, admin)
+	registerOpenAPI(mux)
+	registerVersion(mux, admin)
+	if admin.debug {
+		registerDebug(mux, admin)
+	}
+	if admin.memoryBroker != nil {
+		registerMock(mux, admin)
+	}
+	return mux
+}
+
+// routeCache lists the cached values of routeID sorted by fingerprint, limited to only
+// when it is not empty. A route that hashes its values also finds them by the values.
+func (a adminDeps) routeCache(routeID string, only []string) RouteCache {
+	entries := a.store.Entries(routeID)
+	if len(only) > 0 {
+		filtered := make(map[string]store.Entry, len(only))
+		for _, fp := range only {
+			if _, ok := entries[fp]; !ok && a.matchers[routeID] != nil {
+				fp = a.matchers[routeID].Fingerprint(fp)
+			}
+			if e, ok := entries[fp]; ok {
+				filtered[fp] = e
+			}
+		}
+		entries = filtered
+	}
+	resp := RouteCache{Route: routeID, Annotations: a.routes[routeID].Annotations, Values: make([]CachedValue, 0, len(entries))}
+	for fp, e := range entries {
+		resp.Values = append(resp.Values, CachedValue{Fingerprint: fp, Canonical: e.Canonical, Origin: e.Meta})
+	}
+	sort.Slice(resp.Values, func(i, j int) bool { return resp.Values[i].Fingerprint < resp.Values[j].Fingerprint })
+	return resp
+}
+
+func decodeReferenceRequest(body io.Reader) (ReferenceValues, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return ReferenceValues{}, err
+	}
+	var req ReferenceValues
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &req.Values)
+	} else {
+		err = json.Unmarshal(raw, &req)
+	}
+	if err != nil {
+		return ReferenceValues{}, errors.New(`invalid JSON array of strings or {"values":[...],"annotations":{...}} object`)
+	}
+	if len(req.Values) == 0 {
+		return ReferenceValues{}, errors.New("empty payload")
+	}
+	return req, nil
+}
+
+// rawPayload returns the message value to evaluate. A JSON string payload is treated as
+// the raw message bytes so callers can paste values exactly as they appear on the topic.
+func (r TestMatchRequest) rawPayload() []byte {
+	var raw string
+	if err := json.Unmarshal(r.Payload, &raw); err == nil {
+		return []byte(raw)
+	}
+	return r.Payload
+}
+
+// message returns the Kafka message the request simulates on route.
+func (r TestMatchRequest) message(route config.Route, now time.Time) kafka.Message {
+	msg := kafka.Message{Topic: route.SourceTopic, Value: r.rawPayload(), Time: now}
+	if r.Key != "" {
+		msg.Key = []byte(r.Key)
+	}
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(r.Headers[name])})
+	}
+	return msg
+}
+
+// testMatch decides the simulated message of req through the checks the route's stream
+// makes, without counting or recording it.
+func (a adminDeps) testMatch(routeID string, matcher *engine.Matcher, req TestMatchRequest) TestMatchResponse {
+	route, ok := a.routes[routeID]
+	if !ok {
+		route = config.Route{Name: routeID}
+	}
+	var guard loopGuard
+	var headers headerRewriter
+	if a.cfg != nil {
+		guard = newLoopGuard(a.cfg.LoopPrevention, routeID)
+		headers = newHeaderRewriter(a.cfg, route)
+	}
+	resp := TestMatchResponse{Route: routeID, Matches: []engine.Match{}}
+	v := a.decide(route, guard, headers, matcher, req.message(route, time.Now()), true)
+	switch v.outcome {
+	case verdictForward:
+		resp.Forward = true
+	case verdictInvalid:
+		resp.Error = v.reason
+	default:
+		resp.Reason = v.reason
+	}
+	if v.matches != nil {
+		resp.Matches = v.matches
+	}
+	return resp
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/delivery"
+)
+
+type latencyKey struct {
+	route       string
+	destination string
+}
+
+// latencySeries measures, per route and destination, how long after the source publish a
+// message was written and how long each destination write took.
+type latencySeries struct {
+	endToEnd *metrics.Histogram
+	write    *metrics.Histogram
+}
+
+type latencyRegistry struct {
+	mu     sync.Mutex
+	series map[latencyKey]*latencySeries
+}
+
+func (r *latencyRegistry) get(route, destination string) *latencySeries {
+	key := latencyKey{route: route, destination: destination}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series[key]
+	if !ok {
+		s = &latencySeries{endToEnd: metrics.NewHistogram(metrics.DefaultLatencyBuckets), write: metrics.NewHistogram(metrics.DefaultLatencyBuckets)}
+		r.series[key] = s
+	}
+	return s
+}
+
+func (r *latencyRegistry) metrics() []metrics.Family {
+	r.mu.Lock()
+	keys := make([]latencyKey, 0, len(r.series))
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	r.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].destination < keys[j].destination
+	})
+
+	endToEnd := metrics.Family{Name: "kafka_bridge_forward_latency_seconds", Help: "Time from the source message's timestamp to its successful write to the destination.", Type: metrics.TypeHistogram}
+	write := metrics.Family{Name: "kafka_bridge_write_duration_seconds", Help: "Duration of each destination write attempt, including failed ones.", Type: metrics.TypeHistogram}
+	for _, key := range keys {
+		s := r.get(key.route, key.destination)
+		labels := metrics.Labels{"route": key.route, "destination": key.destination}
+		endToEnd.AddHistogram(labels, s.endToEnd)
+		write.AddHistogram(labels, s.write)
+	}
+	return []metrics.Family{endToEnd, write}
+}
+
+// latencyWriter records the latency histograms of the writes made through it. Messages
+// without a source timestamp, such as tombstones, only count towards write durations.
+type latencyWriter struct {
+	delivery.MessageWriter
+	series *latencySeries
+}
+
+func (w latencyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	start := time.Now()
+	err := w.MessageWriter.WriteMessages(ctx, msgs...)
+	now := time.Now()
+	w.series.write.Observe(now.Sub(start).Seconds())
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if !msg.Time.IsZero() {
+			w.series.endToEnd.Observe(now.Sub(msg.Time).Seconds())
+		}
+	}
+	return nil
+}
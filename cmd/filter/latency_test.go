@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+)
+
+func TestLatencyWriterRecordsHistograms(t *testing.T) {
+	b := newBridge(config.Logging{})
+	series := b.routeLatencies.get("route-latency", "orders.filtered")
+	w := latencyWriter{MessageWriter: &recordingWriter{failures: 1}, series: series}
+	msg := kafka.Message{Value: []byte("{}"), Time: time.Now().Add(-2 * time.Second)}
+	if err := w.WriteMessages(context.Background(), msg); err == nil {
+		t.Fatal("expected the first write to fail")
+	}
+	if err := w.WriteMessages(context.Background(), msg, kafka.Message{Value: nil}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf, b.routeLatencies.metrics()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`kafka_bridge_forward_latency_seconds_bucket{destination="orders.filtered",le="1",route="route-latency"} 0`,
+		`kafka_bridge_forward_latency_seconds_bucket{destination="orders.filtered",le="2.5",route="route-latency"} 1`,
+		`kafka_bridge_forward_latency_seconds_count{destination="orders.filtered",route="route-latency"} 1`,
+		`kafka_bridge_write_duration_seconds_count{destination="orders.filtered",route="route-latency"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("metrics missing %s:\n%s", want, out)
+		}
+	}
+}
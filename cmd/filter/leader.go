@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// runElectedCollectors takes part in the leader election and runs every route's
+// reference collector and pollers while this replica leads. Changes the collectors make are
+// broadcast to the followers, which apply them through the coordinator.
+func runElectedCollectors(ctx context.Context, cfg *config.Config, admin adminDeps, dialer *kafka.Dialer) error {
+	le := cfg.Coordination.LeaderElection
+	election := kafkapkg.NewLeaderElection(cfg.BridgeCluster.Brokers, dialer, le.Topic, le.GroupID, admin.peers.InstanceID())
+	return election.Run(ctx, func(ctx context.Context) {
+		admin.leading.Store(true)
+		defer admin.leading.Store(false)
+		var wg sync.WaitGroup
+		for _, route := range cfg.Routes {
+			matcher := admin.matchers[routeKey(route)]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				admin.superviseRoute(ctx, routeKey(route), route.DisplayName(), workerCollector, func(ctx context.Context) error {
+					return admin.runReferenceCollector(ctx, cfg, route, dialer, matcher, admin.broadcast)
+				})
+			}()
+			for _, poller := range admin.pollers[routeKey(route)] {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					poller.run(ctx, admin.broadcast)
+				}()
+			}
+		}
+		wg.Wait()
+	})
+}
+
+// replicateUpdate broadcasts the cache changes a reference record made on routeID.
+func replicateUpdate(ctx context.Context, broadcast func(context.Context, kafkapkg.Command), routeID string, update engine.ReferenceUpdate, msg kafka.Message) {
+	if broadcast == nil {
+		return
+	}
+	if len(update.Dropped) > 0 {
+		broadcast(ctx, kafkapkg.Command{Op: kafkapkg.CommandDelete, Route: routeID, Values: update.Dropped})
+	}
+	if len(update.Stored) > 0 {
+		meta := store.Metadata{Source: store.SourceKafka, Feed: update.Feed, Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset}
+		broadcast(ctx, kafkapkg.Command{Op: kafkapkg.CommandInject, Route: routeID, Values: update.Stored, Meta: &meta})
+	}
+}
+
+// broadcast publishes an already applied command to peer replicas without applying it.
+func (a adminDeps) broadcast(ctx context.Context, cmd kafkapkg.Command) {
+	cmd.ID = kafkapkg.NewCommandID()
+	a.peers.Claim(cmd.ID)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := a.peers.Publish(ctx, cmd); err != nil {
+		log.Printf("warn: broadcast of %s on %s to peers failed: %v", cmd.Op, cmd.Route, err)
+	}
+}
+
+// leaderMetrics reports the election state; it is empty when leader election is disabled.
+func (b *bridge) leaderMetrics(enabled bool) []metrics.Family {
+	if !enabled {
+		return nil
+	}
+	leader := metrics.Family{Name: "kafka_bridge_leader", Help: "1 while this replica leads and runs the reference collectors.", Type: metrics.TypeGauge}
+	value := 0.0
+	if b.leading.Load() {
+		value = 1
+	}
+	leader.Add(nil, value)
+	return []metrics.Family{leader}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestReplicateUpdateToFollower(t *testing.T) {
+	b := newBridge(config.Logging{})
+	feeds := []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}
+	leaderStore := store.NewMatchStore()
+	leader, err := engine.NewMatcher("route-a", feeds, leaderStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	followerStore := store.NewMatchStore()
+	follower, err := engine.NewMatcher("route-a", feeds, followerStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	followerAdmin := adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-a": follower}, store: followerStore}
+
+	var sent []kafkapkg.Command
+	broadcast := func(_ context.Context, cmd kafkapkg.Command) { sent = append(sent, cmd) }
+	for i, value := range []string{`{"fieldA":"old"}`, `{"fieldA":"new"}`} {
+		msg := kafka.Message{Topic: "ref", Partition: 1, Offset: int64(i), Key: []byte("k"), Value: []byte(value)}
+		update, err := leader.ProcessReference(engine.ReferenceMessage{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset, Key: msg.Key, Value: msg.Value})
+		if err != nil {
+			t.Fatalf("ProcessReference: %v", err)
+		}
+		replicateUpdate(context.Background(), broadcast, "route-a", update, msg)
+	}
+	// the second record replaces the key's value: a delete of "old", then an inject of "new"
+	if len(sent) != 3 || sent[1].Op != kafkapkg.CommandDelete || sent[2].Op != kafkapkg.CommandInject || sent[2].Meta == nil {
+		t.Fatalf("unexpected broadcasts: %+v", sent)
+	}
+	for _, cmd := range sent {
+		followerAdmin.applyPeerCommand(cmd)
+	}
+	if followerStore.Contains("route-a", "old") || !followerStore.Contains("route-a", "new") {
+		t.Fatalf("follower cache not converged: %v", followerStore.Snapshot())
+	}
+	meta, _ := followerStore.Lookup("route-a", "new")
+	if meta.Source != store.SourceKafka || meta.Feed != "feed-a" || meta.Offset != 1 {
+		t.Fatalf("follower lost feed provenance: %+v", meta)
+	}
+	replicateUpdate(context.Background(), nil, "route-a", engine.ReferenceUpdate{Stored: []string{"x"}}, kafka.Message{})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kafka-bridge/internal/config"
+)
+
+type messageLogger struct {
+	every atomic.Int64
+
+	mu     sync.Mutex
+	counts map[string]*atomic.Uint64
+}
+
+// configure applies logging.sampleEvery.
+func (l *messageLogger) configure(cfg config.Logging) {
+	l.every.Store(int64(cfg.SampleEvery))
+}
+
+// printf logs one of route's per-message lines: every line by default, the first of every
+// sampleEvery lines when sampling, and none when sampleEvery is -1.
+func (l *messageLogger) printf(route, format string, args ...any) {
+	switch every := l.every.Load(); {
+	case every < 0:
+		return
+	case every > 1:
+		if l.count(route).Add(1)%uint64(every) != 1 {
+			return
+		}
+	}
+	log.Printf(format, args...)
+}
+
+func (l *messageLogger) count(route string) *atomic.Uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.counts[route]
+	if !ok {
+		c = new(atomic.Uint64)
+		l.counts[route] = c
+	}
+	return c
+}
+
+// routeTotals is a snapshot of the counters a log summary reports.
+type routeTotals struct {
+	consumed, forwarded, skipped, dropped, decodeErrors, writeErrors uint64
+}
+
+func (s *routeStats) totals() routeTotals {
+	return routeTotals{
+		consumed:     s.consumed.Load(),
+		forwarded:    s.forwarded.Load(),
+		skipped:      s.skipped.Load(),
+		dropped:      s.dropped.Load(),
+		decodeErrors: s.decodeErrors.Load(),
+		writeErrors:  s.writeErrors.Load(),
+	}
+}
+
+// logSummary logs what each route did since the previous summary. Idle routes are left out.
+type logSummary struct {
+	counters *statsRegistry
+	routeIDs []string
+	last     map[string]routeTotals
+}
+
+// newLogSummary returns a summary of routeIDs counted from now.
+func (b *bridge) newLogSummary(routeIDs []string) *logSummary {
+	sort.Strings(routeIDs)
+	s := &logSummary{counters: b.routeCounters, routeIDs: routeIDs, last: make(map[string]routeTotals, len(routeIDs))}
+	for _, id := range routeIDs {
+		s.last[id] = s.counters.route(id).totals()
+	}
+	return s
+}
+
+// run logs a summary every interval, and once more for the partial interval when ctx is
+// done.
+func (s *logSummary) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			s.log(time.Since(since).Round(time.Millisecond).String() + " before shutdown")
+			return
+		case <-ticker.C:
+			s.log(interval.String())
+			since = time.Now()
+		}
+	}
+}
+
+// log logs what each route did in the last period.
+func (s *logSummary) log(period string) {
+	for _, id := range s.routeIDs {
+		now := s.counters.route(id).totals()
+		if line := summaryLine(now, s.last[id]); line != "" {
+			log.Printf("route %s in the last %s: %s", id, period, line)
+		}
+		s.last[id] = now
+	}
+}
+
+// summaryLine describes the change from prev to now, or returns "" when nothing changed.
+func summaryLine(now, prev routeTotals) string {
+	if now == prev {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range []struct {
+		name       string
+		now, start uint64
+	}{
+		{"consumed", now.consumed, prev.consumed},
+		{"forwarded", now.forwarded, prev.forwarded},
+		{"skipped", now.skipped, prev.skipped},
+		{"dropped", now.dropped, prev.dropped},
+		{"decodeErrors", now.decodeErrors, prev.decodeErrors},
+		{"writeErrors", now.writeErrors, prev.writeErrors},
+	} {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(c.name)
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatUint(c.now-c.start, 10))
+	}
+	return b.String()
+}
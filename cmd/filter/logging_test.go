@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestMessageLogsSample(t *testing.T) {
+	b := newBridge(config.Logging{})
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	b.messageLogs.configure(config.Logging{SampleEvery: 3})
+	for i := 1; i <= 7; i++ {
+		b.messageLogs.printf("route-sampled", "forwarded offset %d", i)
+	}
+	b.messageLogs.configure(config.Logging{SampleEvery: -1})
+	b.messageLogs.printf("route-sampled", "forwarded offset %d", 8)
+
+	var offsets []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		offsets = append(offsets, line[strings.LastIndex(line, " ")+1:])
+	}
+	if got := strings.Join(offsets, ","); got != "1,4,7" {
+		t.Fatalf("logged offsets %s, want 1,4,7", got)
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	prev := routeTotals{consumed: 100, forwarded: 10, skipped: 90}
+	if line := summaryLine(prev, prev); line != "" {
+		t.Fatalf("idle route summarized as %q", line)
+	}
+	now := routeTotals{consumed: 150, forwarded: 12, skipped: 137, writeErrors: 1}
+	want := "consumed=50 forwarded=2 skipped=47 dropped=0 decodeErrors=0 writeErrors=1"
+	if line := summaryLine(now, prev); line != want {
+		t.Fatalf("summary %q, want %q", line, want)
+	}
+}
+
+func TestLogSummaryReportsPartialIntervalOnShutdown(t *testing.T) {
+	b := newBridge(config.Logging{})
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	summary := b.newLogSummary([]string{"route-busy", "route-idle"})
+	stats := b.routeCounters.route("route-busy")
+	stats.consumed.Add(3)
+	stats.forwarded.Add(2)
+	stats.skipped.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary.run(ctx, time.Hour)
+
+	out := buf.String()
+	if !strings.Contains(out, "route route-busy in the last ") || !strings.Contains(out, " before shutdown: consumed=3 forwarded=2 skipped=1 ") {
+		t.Fatalf("no final summary of the partial interval in %q", out)
+	}
+	if strings.Contains(out, "route-idle") {
+		t.Fatalf("idle route summarized in %q", out)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+)
+
+// Headers stamped on every forwarded message to detect loops between chained bridges.
+const (
+	headerHops = "x-bridge-hops"
+	headerPath = "x-bridge-path"
+)
+
+// loopGuard drops messages that have already crossed this route or too many routes, and
+// stamps forwarded messages with the updated hop count and path. The zero value is
+// disabled.
+type loopGuard struct {
+	// hop is this route's entry in x-bridge-path, bridgeId/routeId.
+	hop     string
+	maxHops int
+}
+
+func newLoopGuard(cfg config.LoopPrevention, routeID string) loopGuard {
+	return loopGuard{hop: cfg.BridgeID + "/" + routeID, maxHops: cfg.MaxHops}
+}
+
+// check returns why a message with headers must not be forwarded, or "" to forward it.
+func (g loopGuard) check(headers []kafka.Header) string {
+	if g.hop == "" {
+		return ""
+	}
+	hops, path := hopHeaders(headers)
+	for _, hop := range path {
+		if hop == g.hop {
+			return fmt.Sprintf("loop detected, already forwarded by %s (path %s)", g.hop, strings.Join(path, ","))
+		}
+	}
+	if g.maxHops > 0 && hops >= g.maxHops {
+		return fmt.Sprintf("hop limit reached (%d of maxHops=%d, path %s)", hops, g.maxHops, strings.Join(path, ","))
+	}
+	return ""
+}
+
+// stamp replaces the hop headers with ones including this route.
+func (g loopGuard) stamp(headers []kafka.Header) []kafka.Header {
+	if g.hop == "" {
+		return headers
+	}
+	hops, path := hopHeaders(headers)
+	out := make([]kafka.Header, 0, len(headers)+2)
+	for _, h := range headers {
+		switch strings.ToLower(h.Key) {
+		case headerHops, headerPath:
+			continue
+		}
+		out = append(out, h)
+	}
+	return append(out,
+		kafka.Header{Key: headerHops, Value: []byte(strconv.Itoa(hops + 1))},
+		kafka.Header{Key: headerPath, Value: []byte(strings.Join(append(path, g.hop), ","))},
+	)
+}
+
+// hopHeaders reads the hop count and path. A missing or malformed count falls back to the
+// path length, so stripping one header does not reset the count.
+func hopHeaders(headers []kafka.Header) (int, []string) {
+	hops := -1
+	var path []string
+	for _, h := range headers {
+		switch strings.ToLower(h.Key) {
+		case headerHops:
+			if n, err := strconv.Atoi(string(h.Value)); err == nil && n >= 0 {
+				hops = n
+			}
+		case headerPath:
+			for _, hop := range strings.Split(string(h.Value), ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					path = append(path, hop)
+				}
+			}
+		}
+	}
+	if hops < len(path) {
+		hops = len(path)
+	}
+	return hops, path
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestLoopGuard(t *testing.T) {
+	guard := newLoopGuard(config.LoopPrevention{BridgeID: "eu", MaxHops: 3}, "route-a")
+	header := func(headers []kafka.Header, key string) string {
+		for _, h := range headers {
+			if h.Key == key {
+				return string(h.Value)
+			}
+		}
+		return ""
+	}
+
+	stamped := guard.stamp([]kafka.Header{{Key: "trace", Value: []byte("1")}})
+	if header(stamped, headerHops) != "1" || header(stamped, headerPath) != "eu/route-a" || header(stamped, "trace") != "1" {
+		t.Fatalf("unexpected stamped headers: %v", stamped)
+	}
+	if reason := guard.check(stamped); !strings.Contains(reason, "loop detected") {
+		t.Fatalf("expected loop on own path, got %q", reason)
+	}
+
+	other := newLoopGuard(config.LoopPrevention{BridgeID: "us", MaxHops: 3}, "route-b")
+	chained := other.stamp(stamped)
+	if header(chained, headerHops) != "2" || header(chained, headerPath) != "eu/route-a,us/route-b" {
+		t.Fatalf("unexpected chained headers: %v", chained)
+	}
+	if reason := newLoopGuard(config.LoopPrevention{BridgeID: "ap", MaxHops: 3}, "route-c").check(chained); reason != "" {
+		t.Fatalf("expected third hop to pass, got %q", reason)
+	}
+	// a stripped hop count falls back to the path length
+	limited := []kafka.Header{{Key: headerPath, Value: []byte("a/1,b/2,c/3")}}
+	if reason := guard.check(limited); !strings.Contains(reason, "hop limit") {
+		t.Fatalf("expected hop limit, got %q", reason)
+	}
+	if reason := (loopGuard{}).check(limited); reason != "" {
+		t.Fatalf("zero guard should allow everything, got %q", reason)
+	}
+}
@@ -1,16 +1,15 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -18,24 +17,68 @@ import (
 
 	"github.com/segmentio/kafka-go"
 
+	"kafka-bridge/internal/archive"
 	"kafka-bridge/internal/config"
-	"kafka-bridge/internal/engine"
 	kafkapkg "kafka-bridge/internal/kafka"
-	"kafka-bridge/internal/store"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/schema"
+	"kafka-bridge/pkg/store"
 )
 
 func main() {
-	var cfgPath string
-	flag.StringVar(&cfgPath, "config", "config/config.yaml", "path to YAML config file")
+	var g globalFlags
+	var printVersion bool
+	flag.StringVar(&g.config, "config", "config/config.yaml", "path to YAML config file")
+	flag.BoolVar(&g.readOnlyAdmin, "read-only-admin", false, "disable mutating admin HTTP endpoints (clear, inject, delete, compact)")
+	flag.BoolVar(&g.mock, "mock", false, "replace every Kafka cluster with an in-memory broker, fed and read through /mock/topics")
+	flag.BoolVar(&printVersion, "version", false, "print the build version and exit")
+	flag.Usage = func() { writeUsage(flag.CommandLine.Output(), flag.CommandLine) }
 	flag.Parse()
 
-	cfg, err := config.Load(cfgPath)
+	if printVersion {
+		fmt.Println(buildVersion())
+		return
+	}
+	if flag.NArg() == 0 {
+		runBridge(g.config, g.readOnlyAdmin, g.mock)
+		return
+	}
+	cmd, ok := findSubcommand(flag.Arg(0))
+	if !ok {
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown subcommand %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(g, flag.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("%s: %v", cmd.name, err)
+	}
+}
+
+// runBridge runs every route of the config at cfgPath until SIGINT or SIGTERM.
+func runBridge(cfgPath string, readOnlyAdmin, mock bool) {
+	cfg, err := loadConfig(cfgPath)
 	if err != nil {
-		log.Fatalf("load config: %v", err)
+		log.Fatal(err)
+	}
+	b := newBridge(cfg.Logging)
+	if mock {
+		if err := validateMock(cfg); err != nil {
+			log.Fatalf("mock: %v", err)
+		}
+		b.memoryBroker = kafkapkg.NewMemoryBroker()
+		log.Printf("mock mode: sources, references, and destinations are in-memory topics")
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	log.Printf("starting %s", buildVersion())
+	for _, w := range cfg.Lint() {
+		log.Printf("warn: config: %s: %s", w.Path, w.Message)
+	}
 
 	sourceDialers := make(map[string]*kafka.Dialer, len(cfg.SourceClusters))
 	for _, sc := range cfg.SourceClusters {
@@ -50,7 +93,11 @@ func main() {
 		log.Fatalf("bridge dialer: %v", err)
 	}
 
-	writerPool := kafkapkg.NewWriterPool(cfg.BridgeCluster.Brokers, bridgeDialer)
+	poolOpts := []delivery.PoolOption{delivery.WithIdleTimeout(cfg.BridgeCluster.Writers.IdleTimeout)}
+	if b.memoryBroker != nil {
+		poolOpts = append(poolOpts, delivery.WithTopicWriters(func(topic string) delivery.MessageWriter { return b.memoryBroker.Topic(topic) }))
+	}
+	writerPool := delivery.NewPool(cfg.BridgeCluster.Brokers, bridgeDialer, poolOpts...)
 	defer func() {
 		if err := writerPool.Close(); err != nil {
 			log.Printf("close writers: %v", err)
@@ -58,133 +105,656 @@ func main() {
 	}()
 
 	matchStore := store.NewMatchStore()
+	var schemaTracker *schema.Tracker
+	if cfg.SchemaDrift.Enabled {
+		schemaTracker = schema.NewTracker(cfg.SchemaDrift.BaselineMessages)
+	}
 	matchers := make(map[string]*engine.Matcher)
+	pollers := make(map[string][]*referencePoller)
 	for _, route := range cfg.Routes {
 		routeID := routeKey(route)
-		m, err := engine.NewMatcher(routeID, route.ReferenceFeeds, matchStore)
+		var opts []engine.Option
+		if schemaTracker != nil {
+			opts = append(opts, engine.WithSchemaTracker(schemaTracker, sourceLabel(route), cfg.SchemaDrift.SourceSampleEvery))
+		}
+		m, err := newRouteMatcher(cfg, route, matchStore, opts...)
 		if err != nil {
 			log.Fatalf("build matcher for %s: %v", route.DisplayName(), err)
 		}
+		if route.Compacted {
+			writerPool.SetCompacted(route.DestinationTopic)
+		}
+		if c := route.Chaos; c != nil {
+			log.Printf("warn: route %s: chaos injects %g%% write errors, %g%% decode errors, and %s latency into %g%% of writes", route.DisplayName(), c.WriteErrorPercent, c.DecodeErrorPercent, c.Latency, c.LatencyPercent)
+		}
+		if route.MaxValues > 0 {
+			matchStore.SetLimit(routeID, store.Limit{MaxValues: route.MaxValues, Policy: store.EvictionPolicy(route.Eviction)})
+		}
 		matchers[routeID] = m
+		if pollers[routeID], err = newReferencePollers(route, m, matchStore, b.memoryBudget); err != nil {
+			log.Fatalf("route %s: %v", route.DisplayName(), err)
+		}
 	}
+	b.routeGroups.register(cfg.Routes, matchers)
 
-	if cfg.Storage.Path != "" {
-		if err := loadSnapshot(cfg.Storage.Path, matchStore); err != nil {
-			log.Printf("warn: failed to load snapshot: %v", err)
+	var wg sync.WaitGroup
+	var (
+		state *kafkapkg.StateTopic
+		db    *store.SQLite
+		exact store.ExactSet
+	)
+	switch cfg.Storage.Backend {
+	case config.StorageBackendKafka:
+		state = kafkapkg.NewStateTopic(cfg.BridgeCluster.Brokers, bridgeDialer, cfg.Storage.Topic)
+		defer func() {
+			if err := state.Close(); err != nil {
+				log.Printf("close state topic: %v", err)
+			}
+		}()
+	case config.StorageBackendSQLite:
+		if db, err = store.OpenSQLite(cfg.Storage.Path); err != nil {
+			log.Fatalf("open sqlite storage: %v", err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				log.Printf("close sqlite storage: %v", err)
+			}
+		}()
+		exact = db
+	}
+	var referenceState *kafkapkg.StateTopic
+	if cfg.ReferenceState.Topic != "" {
+		referenceState = kafkapkg.NewStateTopic(cfg.BridgeCluster.Brokers, bridgeDialer, cfg.ReferenceState.Topic)
+		defer func() {
+			if err := referenceState.Close(); err != nil {
+				log.Printf("close reference state topic: %v", err)
+			}
+		}()
+	}
+	setBloomFilters(cfg, matchStore, exact)
+	restore := func(ctx context.Context) error { return restoreState(ctx, cfg, matchStore, state, db) }
+	start := func() {
+		if err := startStorage(ctx, cfg, matchStore, matchers, state, db, &wg); err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		if referenceState != nil {
+			startReferenceState(ctx, cfg.ReferenceState, matchStore, referenceState, &wg)
+		}
+		b.stateRecovery.done()
+	}
+	if cfg.Storage.Required {
+		log.Printf("storage is required: routes and /readyz wait until the cache is restored")
+		b.stateRecovery.hold()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if restoreUntilDone(ctx, b.stateRecovery, restore) == nil {
+				start()
+			}
+		}()
+	} else {
+		if err := restore(ctx); err != nil {
+			log.Fatal(err)
+		}
+		start()
+	}
+	if cfg.Storage.PersistCounters {
+		// restored before any route streams, so no message is counted twice
+		if err := b.restoreCounters(cfg.Storage.CountersPath(), cfg.Routes, time.Now()); err != nil {
+			log.Printf("warn: route counters start from zero: %v", err)
 		}
-		startSnapshotWriter(ctx, cfg.Storage.Path, cfg.Storage.FlushInterval, matchStore)
+		b.startCounterWriter(ctx, cfg.Storage.CountersPath(), cfg.Storage.FlushInterval, &wg)
+	}
+
+	routes := make(map[string]config.Route, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		routes[routeKey(route)] = route
+	}
+	admin := adminDeps{
+		bridge:     b,
+		cfg:        cfg,
+		matchers:   matchers,
+		pollers:    pollers,
+		routes:     routes,
+		store:      matchStore,
+		schema:     schemaTracker,
+		writers:    writerPool,
+		electing:   cfg.Coordination.LeaderElection.Enabled,
+		readOnly:   readOnlyAdmin,
+		adminToken: cfg.HTTP.AdminToken,
+		debug:      cfg.HTTP.Debug,
+	}
+	admin.audit = newAuditLog(cfg, writerPool)
+	if readOnlyAdmin {
+		log.Printf("admin API running in read-only mode")
+	}
+	if cfg.Coordination.Topic != "" {
+		admin.peers = kafkapkg.NewCoordinator(cfg.BridgeCluster.Brokers, bridgeDialer, cfg.Coordination.Topic, cfg.Coordination.InstanceID)
+		defer func() {
+			if err := admin.peers.Close(); err != nil {
+				log.Printf("close coordinator: %v", err)
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.stateRecovery.wait(ctx) != nil {
+				return
+			}
+			if err := admin.peers.Run(ctx, admin.applyPeerCommand); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("coordinator stopped: %v", err)
+			}
+		}()
+	}
+
+	if cfg.Coordination.LeaderElection.Enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.stateRecovery.wait(ctx) != nil {
+				return
+			}
+			if err := runElectedCollectors(ctx, cfg, admin, bridgeDialer); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("leader election stopped: %v", err)
+			}
+		}()
+	}
+
+	if cfg.Watchdog.Enabled {
+		admin.watchdog = b.newWatchdog(cfg, matchStore, matchers, writerPool)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = admin.watchdog.Run(ctx)
+		}()
+	}
+
+	if memoryBudgeted(cfg) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.runMemoryBudget(ctx, cfg, matchStore)
+		}()
+	}
+
+	if timeout := cfg.BridgeCluster.Writers.IdleTimeout; timeout > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			evictIdleWriters(ctx, writerPool, timeout)
+		}()
 	}
 
-	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := startHTTPServer(ctx, cfg.HTTP.ListenAddr, matchers, matchStore); err != nil && !errors.Is(err, context.Canceled) {
+		if err := startHTTPServer(ctx, cfg.HTTP.ListenAddr, admin); err != nil && !errors.Is(err, context.Canceled) {
 			log.Printf("http server stopped: %v", err)
 		}
 	}()
+	if cfg.GRPC.ListenAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := startGRPCServer(ctx, cfg.GRPC.ListenAddr, admin); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("grpc server stopped: %v", err)
+			}
+		}()
+	}
+
+	if interval := cfg.Logging.SummaryInterval; interval > 0 {
+		routeIDs := make([]string, 0, len(cfg.Routes))
+		for _, route := range cfg.Routes {
+			routeIDs = append(routeIDs, routeKey(route))
+		}
+		summary := b.newLogSummary(routeIDs)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary.run(ctx, interval)
+		}()
+	}
 
 	for _, route := range cfg.Routes {
 		route := route
 		routeID := routeKey(route)
 		matcher := matchers[routeID]
 
-		sourceCluster, ok := cfg.SourceClusterByName(route.SourceCluster)
-		if !ok {
-			log.Fatalf("route %s references unknown sourceCluster %s", route.DisplayName(), route.SourceCluster)
+		// a route with sources on several clusters streams each cluster's topics with a
+		// reader of its own
+		var streams []routeStream
+		for _, name := range route.SourceClusterNames() {
+			sourceCluster, ok := cfg.SourceClusterByName(name)
+			if !ok {
+				log.Fatalf("route %s references unknown sourceCluster %s", route.DisplayName(), name)
+			}
+			sourceDialer, ok := sourceDialers[sourceCluster.Name]
+			if !ok {
+				log.Fatalf("source dialer missing for %s", sourceCluster.Name)
+			}
+			on := route
+			on.SourceCluster = name
+			streams = append(streams, routeStream{route: on, cluster: sourceCluster, dialer: sourceDialer})
 		}
-		sourceDialer, ok := sourceDialers[sourceCluster.Name]
-		if !ok {
-			log.Fatalf("source dialer missing for %s", sourceCluster.Name)
+
+		if !cfg.Coordination.LeaderElection.Enabled {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if b.stateRecovery.wait(ctx) != nil {
+					return
+				}
+				b.superviseRoute(ctx, routeID, route.DisplayName(), workerCollector, func(ctx context.Context) error {
+					return b.runReferenceCollector(ctx, cfg, route, bridgeDialer, matcher, nil)
+				})
+			}()
 		}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := runReferenceCollector(ctx, cfg, route, bridgeDialer, matcher); err != nil && !errors.Is(err, context.Canceled) {
-				log.Printf("reference collector %s stopped: %v", route.DisplayName(), err)
+		if route.Dedup != nil {
+			window, err := newDedupWindow(*route.Dedup)
+			if err != nil {
+				log.Fatalf("route %s: %v", route.DisplayName(), err)
 			}
-		}()
+			b.routeDedup.set(routeKey(route), window)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				window.run(ctx, route.DisplayName())
+			}()
+		}
+
+		var hydrate []*referencePoller
+		if !cfg.Coordination.LeaderElection.Enabled {
+			hydrate = pollers[routeID]
+		}
+		for _, poller := range hydrate {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if b.stateRecovery.wait(ctx) == nil {
+					poller.refresh(ctx, nil)
+				}
+			}()
+		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := streamRoute(ctx, cfg, route, sourceCluster, sourceDialer, writerPool, matcher); err != nil && !errors.Is(err, context.Canceled) {
-				log.Printf("route %s stopped: %v", route.DisplayName(), err)
+			if b.stateRecovery.wait(ctx) != nil {
+				return
 			}
+			// hydrate before the first source message is matched
+			for _, poller := range hydrate {
+				poller.hydrate(ctx, nil)
+			}
+			if b.awaitUpstreams(ctx, cfg, route) != nil || b.awaitReferenceWarmup(ctx, cfg, route, bridgeDialer) != nil {
+				return
+			}
+			b.routeStarts.start(routeID)
+			var running sync.WaitGroup
+			for _, stream := range streams {
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					b.superviseRoute(ctx, routeID, route.DisplayName(), workerStream, func(ctx context.Context) error {
+						return b.streamRoute(ctx, cfg, stream.route, stream.cluster, stream.dialer, writerPool, matchStore, matcher)
+					})
+				}()
+			}
+			running.Wait()
 		}()
 	}
 
 	wg.Wait()
 }
 
+// sourceLabel names what route reads: its sourceTopic, sourceTopicPattern, or the topics
+// of its sources.
+func sourceLabel(route config.Route) string {
+	topics := make([]string, 0, len(route.Sources))
+	for _, src := range route.Sources {
+		topics = append(topics, src.Topic)
+	}
+	return cmp.Or(route.SourceTopic, route.SourceTopicPattern, strings.Join(topics, ","))
+}
+
+// routeStream is the part of a route read from one source cluster; route has that
+// cluster as its sourceCluster.
+type routeStream struct {
+	route   config.Route
+	cluster config.SourceCluster
+	dialer  *kafka.Dialer
+}
+
 func buildDialer(cluster config.ClusterConfig, clientID string) (*kafka.Dialer, error) {
 	tlsCfg, err := cluster.TLSConfigObject()
 	if err != nil {
 		return nil, err
 	}
-	return &kafka.Dialer{
+	dialer := &kafka.Dialer{
 		Timeout:   10 * time.Second,
 		DualStack: true,
 		TLS:       tlsCfg,
 		ClientID:  clientID,
-	}, nil
+	}
+	if cluster.SASL != nil {
+		// validated by config: OAUTHBEARER is the only mechanism
+		oauth := cluster.SASL.OAuth
+		dialer.SASLMechanism = kafkapkg.OAuthBearer{
+			Source: &kafkapkg.ClientCredentials{
+				TokenURL:     oauth.TokenURL,
+				ClientID:     oauth.ClientID,
+				ClientSecret: oauth.ClientSecret,
+				Secret:       oauth.ResolveClientSecret,
+				Scopes:       oauth.Scopes,
+			},
+			Extensions: oauth.Extensions,
+		}
+	}
+	return dialer, nil
 }
 
-func streamRoute(ctx context.Context, cfg *config.Config, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer, writers *kafkapkg.WriterPool, matcher *engine.Matcher) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        sourceCluster.Brokers,
-		GroupID:        fmt.Sprintf("%s-%s", sourceCluster.SourceGroupID, slug(route.DisplayName())),
-		GroupTopics:    []string{route.SourceTopic},
-		CommitInterval: cfg.CommitInterval,
-		StartOffset:    kafka.LastOffset,
-		Dialer:         dialer,
-	})
+func (b *bridge) streamRoute(ctx context.Context, cfg *config.Config, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer, writers *delivery.Pool, matchStore *store.MatchStore, matcher *engine.Matcher) (err error) {
+	if expiresAt, ok := route.Expiry(); ok {
+		if b.routeExpiries.register(route, expiresAt, time.Now()) {
+			b.routeExpiries.pause(route, expiresAt)
+			return nil
+		}
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiresAt)
+		defer cancel()
+		defer func() {
+			// the deadline, not shutdown, stopped the route: pause it instead of failing
+			if parent.Err() == nil && ctx.Err() != nil {
+				b.routeExpiries.pause(route, expiresAt)
+				err = nil
+			}
+		}()
+	}
+
+	stream := func(ctx context.Context) error {
+		if route.SourceTopicPattern != "" {
+			return b.streamTopicPattern(ctx, cfg, route, sourceCluster, dialer, writers, matchStore, matcher)
+		}
+		return b.streamTopics(ctx, cfg, route, route.SourceTopicsOn(route.SourceCluster), sourceCluster, dialer, writers, matchStore, matcher)
+	}
+	// one seek cannot move the groups of several clusters together
+	if len(route.SourceClusterNames()) > 1 {
+		return stream(ctx)
+	}
+	return b.streamSeekable(ctx, route, b.newSeekControl(route, sourceCluster, dialer), stream)
+}
+
+// streamTopics reads topics with the route's consumer group and forwards what matches
+// until ctx ends or a write fails.
+func (b *bridge) streamTopics(ctx context.Context, cfg *config.Config, route config.Route, topics []string, sourceCluster config.SourceCluster, dialer *kafka.Dialer, writers *delivery.Pool, matchStore *store.MatchStore, matcher *engine.Matcher) error {
+	readerCfg := sourceReaderConfig(cfg, route, sourceCluster, dialer)
+	readerCfg.GroupTopics = topics
+	// in-memory topics start empty, so there is nothing to skip
+	if at, ok := route.Consumer.StartTime(); ok && b.memoryBroker == nil {
+		for _, topic := range topics {
+			seeded, err := kafkapkg.SeedGroupOffsetsAt(ctx, sourceCluster.Brokers, dialer, readerCfg.GroupID, topic, at)
+			if err != nil {
+				return fmt.Errorf("seed start offsets of %s at %s: %w", topic, at.Format(time.RFC3339), err)
+			}
+			if seeded {
+				log.Printf("route %s starting group %s on %s from %s", route.DisplayName(), readerCfg.GroupID, topic, at.Format(time.RFC3339))
+			}
+		}
+	}
+	defer b.routeAssignments.watch(&readerCfg, routeKey(route), readerSource)()
+	reader := b.newMessageReader(readerCfg)
 	defer reader.Close()
+	defer b.openReaders.track(routeKey(route))()
 
-	log.Printf("route %s listening to source topic %s", route.DisplayName(), route.SourceTopic)
-	for {
-		msg, err := reader.ReadMessage(ctx)
+	var sink *archive.Sink
+	if route.Archive != nil {
+		var stopArchive func()
+		sink, stopArchive = startArchive(ctx, route)
+		defer stopArchive()
+	}
+	var destination delivery.MessageWriter
+	var destinations *topicDestinations
+	var err error
+	if route.DestinationTemplated() {
+		destinations, err = b.newTopicDestinations(route, writers, sink)
+	} else {
+		destination, err = b.newDestination(route, writers, sink)
+	}
+	if err != nil {
+		return err
+	}
+	encoder, err := newRouteEncoder(ctx, route)
+	if err != nil {
+		return err
+	}
+	b.routeEncoders.set(routeKey(route), encoder)
+	if route.OnDecodeError == config.OnDecodeErrorDLQ {
+		b.routeDecodeErrors.set(routeKey(route), writers.Topic(route.DecodeErrorTopic))
+	}
+	stats := b.routeCounters.route(routeKey(route))
+	stats.start(time.Now())
+	stats.maxInFlight.Store(int64(route.MaxInFlight))
+	if len(route.Sources) > 0 {
+		// listed from the start, before any message arrives
+		for _, topic := range topics {
+			stats.source(route.SourceCluster, topic)
+		}
+	}
+	defer stats.inFlight.Store(0)
+	defer stats.inFlightBytes.Store(0)
+	policy := delivery.RetryPolicy{
+		InitialBackoff: route.Delivery.RetryBackoff,
+		MaxBackoff:     route.Delivery.MaxRetryBackoff,
+		MaxAttempts:    route.Delivery.MaxAttempts,
+		OnFailure:      func(error) { stats.writeErrors.Add(1) },
+	}
+	if route.RejectedTopic != "" {
+		b.routeRejections.set(routeKey(route), newRejectionSampler(route, writers.Topic(route.RejectedTopic), policy))
+	}
+	guard := newLoopGuard(cfg.LoopPrevention, routeKey(route))
+	headers := newHeaderRewriter(cfg, route)
+	var compacted *compactedRoute
+	if route.Compacted {
+		compacted = newCompactedRoute(b, route, guard, headers, destination, policy)
+		// unregistered on return, since every restart, seek, and resubscribe builds the route anew
+		defer matchStore.AddObserver(compacted.observe)()
+		bridgeDialer, err := buildDialer(cfg.BridgeCluster, cfg.ClientID)
 		if err != nil {
-			return err
+			return fmt.Errorf("bridge dialer: %w", err)
 		}
+		records, err := compacted.readDestination(ctx, cfg.BridgeCluster.Brokers, bridgeDialer)
+		if err != nil {
+			return fmt.Errorf("read destination %s: %w", route.DestinationTopic, err)
+		}
+		indexed, queued := compacted.restore(records, matcher)
+		log.Printf("route %s: indexed %d key(s) of %s, %d no longer matching", route.DisplayName(), indexed, route.DestinationTopic, queued)
+		tombstoneCtx, stopTombstones := context.WithCancel(ctx)
+		defer stopTombstones()
+		go func() {
+			_ = compacted.run(tombstoneCtx)
+		}()
+	}
 
-		match, err := matcher.ShouldForward(msg.Value)
+	log.Printf("route %s listening to source topic %s", route.DisplayName(), strings.Join(topics, ", "))
+	if route.Destination.Type == config.DestinationWebhook && route.Destination.Webhook.BatchSize > 1 {
+		forward := func(ctx context.Context, w delivery.MessageWriter, msg kafka.Message) error {
+			return b.forwardMessage(ctx, route, guard, headers, matcher, w, policy, msg)
+		}
+		return b.streamBatches(ctx, reader, route, forward, destination, policy)
+	}
+	forward := func(ctx context.Context, msg kafka.Message) error {
+		switch {
+		case compacted != nil:
+			return compacted.forward(ctx, matcher, msg)
+		case destinations != nil:
+			topicRoute, w, err := destinations.forTopic(msg.Topic)
+			if err != nil {
+				return err
+			}
+			return b.forwardMessage(ctx, topicRoute, guard, headers, matcher, w, policy, msg)
+		}
+		return b.forwardMessage(ctx, route, guard, headers, matcher, destination, policy, msg)
+	}
+	if route.Delivery.Async {
+		return streamAsync(ctx, reader, func(ctx context.Context, msg kafka.Message) error {
+			return forward(countSource(ctx, route, stats, msg), msg)
+		}, stats, route.MaxInFlight)
+	}
+	for {
+		msg, err := reader.FetchMessage(ctx)
 		if err != nil {
-			log.Printf("route %s: invalid payload skipped: %v", route.DisplayName(), err)
-			continue
+			return err
 		}
-		if !match {
-			continue
+		stats.consumed.Add(1)
+		stats.inFlight.Add(1)
+		stats.inFlightBytes.Add(messageBytes(msg))
+		if err := forward(countSource(ctx, route, stats, msg), msg); err != nil {
+			return err
 		}
+		// commit only after the write succeeded so a crash redelivers rather than drops
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return fmt.Errorf("commit offset %d: %w", msg.Offset, err)
+		}
+		stats.inFlight.Add(-1)
+		stats.inFlightBytes.Add(-messageBytes(msg))
+	}
+}
 
-		writer, err := writers.Get(route.DestinationTopic)
-		if err != nil {
-			log.Printf("route %s: ensure topic %s failed: %v", route.DisplayName(), route.DestinationTopic, err)
-			continue
+// forwardedKey returns the key route forwards a message with payload value and source key
+// under, following its destinationKey or delivery.keyField. A payload without the key's
+// field keeps its source key, and an empty key is replaced by the key's constant, if any.
+func forwardedKey(route config.Route, matcher *engine.Matcher, value, key []byte) []byte {
+	var k config.DestinationKey
+	switch {
+	case route.DestinationKey != nil:
+		k = *route.DestinationKey
+	case route.Delivery.KeyField != "":
+		k = config.DestinationKey{From: config.DestinationKeyField, Field: route.Delivery.KeyField}
+	default:
+		return key
+	}
+	switch k.From {
+	case config.DestinationKeyConstant:
+		return []byte(k.Constant)
+	case config.DestinationKeyField:
+		if field, err := matcher.SourceField(value, k.Field); err == nil {
+			key = []byte(field)
 		}
+	}
+	if len(key) == 0 && k.Constant != "" {
+		return []byte(k.Constant)
+	}
+	return key
+}
 
-		if err := writer.WriteMessages(ctx, cloneMessage(msg)); err != nil {
-			log.Printf("route %s: write failed: %v", route.DisplayName(), err)
-			continue
+// tooOld returns why msg is older than the route's maxMessageAge at now, or "" when it is
+// not or the route keeps messages of any age. Messages without a timestamp are kept.
+func tooOld(route config.Route, msg kafka.Message, now time.Time) string {
+	if route.MaxMessageAge <= 0 || msg.Time.IsZero() {
+		return ""
+	}
+	if age := now.Sub(msg.Time); age > route.MaxMessageAge {
+		return fmt.Sprintf("message is %s old, over maxMessageAge %s", age.Round(time.Second), route.MaxMessageAge)
+	}
+	return ""
+}
+
+// forwardMessage writes msg to the destination when it matches. Failed writes are retried
+// in order before the next source message is read, preserving per-partition ordering.
+func (b *bridge) forwardMessage(ctx context.Context, route config.Route, guard loopGuard, headers headerRewriter, matcher *engine.Matcher, destination delivery.MessageWriter, policy delivery.RetryPolicy, msg kafka.Message) error {
+	routeID := routeKey(route)
+	stats := b.routeCounters.route(routeID)
+	v := b.decide(route, guard, headers, matcher, msg, false)
+	switch v.outcome {
+	case verdictLoop:
+		stats.dropped.Add(1)
+		b.publishDecision(routeID, decisionDropped, msg, v.reason)
+		b.messageLogs.printf(routeID, "route %s: offset %d dropped: %s", route.DisplayName(), msg.Offset, v.reason)
+		return nil
+	case verdictTooOld:
+		stats.tooOld.Add(1)
+		b.publishDecision(routeID, decisionDropped, msg, v.reason)
+		b.messageLogs.printf(routeID, "route %s: offset %d dropped: %s", route.DisplayName(), msg.Offset, v.reason)
+		return nil
+	case verdictHeaderFiltered:
+		stats.headerFiltered.Add(1)
+		b.publishDecision(routeID, decisionSkipped, msg, v.reason)
+		b.rejectMessage(ctx, route, msg, rejectedHeaderFilter, v.reason)
+		return nil
+	case verdictInvalid:
+		return b.handleDecodeError(ctx, route, guard, headers, destination, policy, msg, v.err)
+	case verdictNoMatch:
+		stats.skipped.Add(1)
+		b.publishDecision(routeID, decisionSkipped, msg, "")
+		b.rejectMessage(ctx, route, msg, rejectedNoMatch, "")
+		return nil
+	case verdictPreempted:
+		stats.preempted.Add(1)
+		b.publishDecision(routeID, decisionSkipped, msg, v.reason)
+		b.rejectMessage(ctx, route, msg, rejectedPreempted, v.reason)
+		return nil
+	case verdictDuplicate:
+		stats.duplicates.Add(1)
+		b.publishDecision(routeID, decisionSkipped, msg, v.reason)
+		b.rejectMessage(ctx, route, msg, rejectedDuplicate, v.reason)
+		return nil
+	}
+	value, match := v.value, v.match
+
+	out := cloneMessage(msg)
+	if route.Payload.ForwardDecompressed {
+		out.Value = value
+	}
+	if encoder := b.routeEncoders.get(routeID); encoder != nil {
+		encoded, err := encoder.Encode(value)
+		if err != nil {
+			return b.handleDecodeError(ctx, route, guard, headers, destination, policy, msg, err)
 		}
-		log.Printf("route %s forwarded offset %d to %s", route.DisplayName(), msg.Offset, route.DestinationTopic)
+		out.Value = encoded
+	}
+	out.Key = forwardedKey(route, matcher, value, out.Key)
+	out.Headers = headers.rewrite(out.Headers, msg, time.Now())
+	out.Headers = guard.stamp(out.Headers)
+	if route.ExplainHeaders {
+		out.Headers = withExplainHeaders(out.Headers, routeID, match, msg.Offset)
+	}
+	if err := delivery.Deliver(ctx, destination, policy, out); err != nil {
+		return fmt.Errorf("write offset %d to %s: %w", msg.Offset, destinationName(route), err)
 	}
+	now := time.Now()
+	if v.dedupKey != "" {
+		b.routeDedup.get(routeID).record(v.dedupKey, now)
+	}
+	stats.recordForward(msg.Partition, msg.Offset, now)
+	countSourceForward(ctx)
+	b.forwardEvents.publish(forwardEvent{Route: routeID, Decision: decisionForwarded, Match: match, Partition: msg.Partition, Offset: msg.Offset, Destination: destinationName(route), At: now})
+	b.messageLogs.printf(routeID, "route %s forwarded offset %d to %s", route.DisplayName(), msg.Offset, destinationName(route))
+	return nil
 }
 
-func runReferenceCollector(ctx context.Context, cfg *config.Config, route config.Route, dialer *kafka.Dialer, matcher *engine.Matcher) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
+// runReferenceCollector feeds the route's reference records into matcher. When broadcast
+// is set, the cache changes are also sent to peer replicas.
+func (b *bridge) runReferenceCollector(ctx context.Context, cfg *config.Config, route config.Route, dialer *kafka.Dialer, matcher *engine.Matcher, broadcast func(context.Context, kafkapkg.Command)) error {
+	rc := withFetch(kafka.ReaderConfig{
 		Brokers:        cfg.BridgeCluster.Brokers,
-		GroupID:        fmt.Sprintf("%s-%s", cfg.ReferenceGroupID, slug(route.DisplayName())),
+		GroupID:        referenceGroupID(cfg, route),
 		GroupTopics:    referenceTopics(route.ReferenceFeeds),
 		CommitInterval: cfg.CommitInterval,
 		StartOffset:    kafka.LastOffset,
 		Dialer:         dialer,
-	})
+	}, cfg.BridgeCluster.Fetch)
+	defer b.routeAssignments.watch(&rc, routeKey(route), readerReference)()
+	reader := b.newMessageReader(rc)
 	defer reader.Close()
+	defer b.openReaders.track(routeKey(route))()
 
 	log.Printf("reference collector %s listening to %s", route.DisplayName(), strings.Join(referenceFeedLabels(route.ReferenceFeeds), ","))
 	for {
+		if err := b.memoryBudget.awaitReferences(ctx, routeKey(route)); err != nil {
+			return err
+		}
 		msg, err := reader.ReadMessage(ctx)
 		if err != nil {
 			return err
@@ -195,8 +765,15 @@ func runReferenceCollector(ctx context.Context, cfg *config.Config, route config
 			headerMap[strings.ToLower(h.Key)] = string(h.Value)
 		}
 
-		added, feedName, err := matcher.ProcessReference(msg.Topic, headerMap, msg.Value)
-		feedLabel := feedName
+		update, err := matcher.ProcessReference(engine.ReferenceMessage{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Key:       msg.Key,
+			Headers:   headerMap,
+			Value:     msg.Value,
+		})
+		feedLabel := update.Feed
 		if feedLabel == "" {
 			feedLabel = msg.Topic
 		}
@@ -205,23 +782,68 @@ func runReferenceCollector(ctx context.Context, cfg *config.Config, route config
 			continue
 		}
 
-		if added {
+		replicateUpdate(ctx, broadcast, routeKey(route), update, msg)
+		if update.Added {
 			log.Printf("reference collector %s[%s] stored fingerprint (count=%d)", route.DisplayName(), feedLabel, matcher.Size())
 		}
+		if update.Removed {
+			log.Printf("reference collector %s[%s] removed fingerprint (count=%d)", route.DisplayName(), feedLabel, matcher.Size())
+		}
 	}
 }
 
+// cloneMessage copies m for writing. Partition carries the source partition so the
+// destination writer keeps each source partition on one destination partition, and Offset
+// the source offset, which Kafka writers ignore but webhooks report.
 func cloneMessage(m kafka.Message) kafka.Message {
 	cloned := kafka.Message{
-		Key:     append([]byte(nil), m.Key...),
-		Value:   append([]byte(nil), m.Value...),
-		Headers: make([]kafka.Header, len(m.Headers)),
-		Time:    m.Time,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       append([]byte(nil), m.Key...),
+		Value:     append([]byte(nil), m.Value...),
+		Headers:   make([]kafka.Header, len(m.Headers)),
+		Time:      m.Time,
 	}
 	copy(cloned.Headers, m.Headers)
 	return cloned
 }
 
+// publishDecision reports a message that was not forwarded to event watchers.
+func (b *bridge) publishDecision(routeID, decision string, msg kafka.Message, reason string) {
+	if b.forwardEvents.watching() {
+		b.forwardEvents.publish(forwardEvent{Route: routeID, Decision: decision, Partition: msg.Partition, Offset: msg.Offset, Reason: reason, At: time.Now()})
+	}
+}
+
+// Headers stamped on forwarded messages when a route enables explainHeaders.
+const (
+	headerRoute         = "x-bridge-route"
+	headerMatchedValue  = "x-bridge-matched-value"
+	headerMatchedField  = "x-bridge-matched-field"
+	headerMatchedOrigin = "x-bridge-matched-origin"
+	headerSourceOffset  = "x-bridge-source-offset"
+)
+
+// withExplainHeaders replaces any existing explanation headers with ones describing match.
+func withExplainHeaders(headers []kafka.Header, routeID string, match engine.Match, offset int64) []kafka.Header {
+	explain := []kafka.Header{
+		{Key: headerRoute, Value: []byte(routeID)},
+		{Key: headerMatchedValue, Value: []byte(match.Fingerprint)},
+		{Key: headerMatchedField, Value: []byte(match.Field)},
+		{Key: headerMatchedOrigin, Value: []byte(match.Origin.String())},
+		{Key: headerSourceOffset, Value: []byte(strconv.FormatInt(offset, 10))},
+	}
+	out := make([]kafka.Header, 0, len(headers)+len(explain))
+	for _, h := range headers {
+		switch strings.ToLower(h.Key) {
+		case headerRoute, headerMatchedValue, headerMatchedField, headerMatchedOrigin, headerSourceOffset:
+			continue
+		}
+		out = append(out, h)
+	}
+	return append(out, explain...)
+}
+
 func slug(in string) string {
 	replacer := strings.NewReplacer(" ", "-", "/", "-", "\\", "-", ".", "-")
 	return replacer.Replace(strings.ToLower(in))
@@ -231,6 +853,78 @@ func routeKey(route config.Route) string {
 	return slug(route.DisplayName())
 }
 
+// newRouteMatcher builds the matcher for route from its reference feeds, payload settings,
+// and the global decode limits; opts apply after those.
+func newRouteMatcher(cfg *config.Config, route config.Route, matchStore *store.MatchStore, opts ...engine.Option) (*engine.Matcher, error) {
+	feeds := make([]engine.Feed, 0, len(route.ReferenceFeeds))
+	for _, f := range route.ReferenceFeeds {
+		feeds = append(feeds, engine.Feed(f))
+	}
+	opts = append([]engine.Option{
+		engine.WithDecodeLimits(engine.DecodeLimits(cfg.DecodeLimits)),
+		engine.WithCompression(route.Payload.Compression, route.Payload.MaxDecompressedBytes),
+		engine.WithSourceFormat(route.PayloadFormat),
+		engine.WithEventFilter(route.EventFilter),
+		engine.WithMatchStrategy(route.MatchStrategy),
+		engine.WithPrefilter(route.Prefilter),
+		engine.WithLegacyDecode(cfg.LegacyJSONDecode),
+	}, opts...)
+	if w := route.TimeWindow; w != nil {
+		opts = append(opts, engine.WithTimeWindow(w.SourceField, w.Before, w.After))
+	}
+	if h := route.HashValues; h != nil {
+		opts = append(opts, engine.WithValueHash(true, []byte(h.Key)))
+	}
+	return engine.NewMatcher(routeKey(route), feeds, matchStore, opts...)
+}
+
+// sourceGroupID is the consumer group a route reads its source topic with.
+func sourceGroupID(sourceCluster config.SourceCluster, route config.Route) string {
+	suffix := route.Consumer.GroupSuffix
+	if suffix == "" {
+		suffix = slug(route.DisplayName())
+	}
+	return fmt.Sprintf("%s-%s", sourceCluster.SourceGroupID, suffix)
+}
+
+// sourceReaderConfig applies the route's consumer overrides on top of the global settings
+// and the fetch settings of its source cluster. A timestamp startOffset is seeded into the
+// group before the reader starts, so the reader itself falls back to the latest offset.
+func sourceReaderConfig(cfg *config.Config, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer) kafka.ReaderConfig {
+	rc := withFetch(kafka.ReaderConfig{
+		Brokers:        sourceCluster.Brokers,
+		GroupID:        sourceGroupID(sourceCluster, route),
+		GroupTopics:    []string{route.SourceTopic},
+		CommitInterval: cfg.CommitInterval,
+		StartOffset:    kafka.LastOffset,
+		Dialer:         dialer,
+	}, sourceCluster.Fetch.Override(route.Consumer.Fetch()))
+	if route.Consumer.CommitInterval > 0 {
+		rc.CommitInterval = route.Consumer.CommitInterval
+	}
+	if route.Consumer.StartOffset == config.StartOffsetEarliest {
+		rc.StartOffset = kafka.FirstOffset
+	}
+	if route.MaxInFlight > 0 && (rc.QueueCapacity == 0 || rc.QueueCapacity > route.MaxInFlight) {
+		// the reader stops fetching once its queue is full
+		rc.QueueCapacity = route.MaxInFlight
+	}
+	return rc
+}
+
+// withFetch returns rc with the fetch settings of f; zero fields keep the kafka-go defaults.
+func withFetch(rc kafka.ReaderConfig, f config.Fetch) kafka.ReaderConfig {
+	rc.MinBytes, rc.MaxBytes, rc.MaxWait = f.MinBytes, f.MaxBytes, f.MaxWait
+	rc.QueueCapacity, rc.ReadBatchTimeout = f.QueueCapacity, f.ReadBatchTimeout
+	rc.SessionTimeout, rc.RebalanceTimeout = f.SessionTimeout, f.RebalanceTimeout
+	return rc
+}
+
+// referenceGroupID is the consumer group a route reads its reference feeds with.
+func referenceGroupID(cfg *config.Config, route config.Route) string {
+	return fmt.Sprintf("%s-%s", cfg.ReferenceGroupID, slug(route.DisplayName()))
+}
+
 func referenceTopics(feeds []config.ReferenceFeed) []string {
 	out := make([]string, 0, len(feeds))
 	for _, f := range feeds {
@@ -251,144 +945,73 @@ func referenceFeedLabels(feeds []config.ReferenceFeed) []string {
 	return out
 }
 
-func loadSnapshot(path string, store *store.MatchStore) error {
-	snap, err := store.LoadSnapshot(path)
+// setBloomFilters switches the routes with a bloomFilter to probabilistic caches, before
+// the cache is restored. exact confirms hits for routes that verify them.
+func setBloomFilters(cfg *config.Config, matchStore *store.MatchStore, exact store.ExactSet) {
+	for _, route := range cfg.Routes {
+		bloom := route.BloomFilter
+		if bloom == nil {
+			continue
+		}
+		p := store.Probabilistic{ExpectedValues: bloom.ExpectedValues, FalsePositiveRate: bloom.FalsePositiveRate}
+		if bloom.Verify {
+			p.Exact = exact
+		}
+		if err := matchStore.SetProbabilistic(routeKey(route), p); err != nil {
+			log.Fatalf("route %s: bloomFilter: %v", route.DisplayName(), err)
+		}
+	}
+}
+
+func loadSnapshot(path string, matchStore *store.MatchStore) error {
+	file, err := store.ReadSnapshotFile(path)
 	if err != nil {
 		return err
 	}
-	store.Load(snap)
+	matchStore.LoadEntries(file.Entries())
 	return nil
 }
 
-func startSnapshotWriter(ctx context.Context, path string, interval time.Duration, store *store.MatchStore) {
+// compactMatchers regenerates restored variants so changed variant rules apply without a resync.
+func compactMatchers(matchers map[string]*engine.Matcher) (int, int) {
+	totalAdded, totalRemoved := 0, 0
+	for routeID, m := range matchers {
+		added, removed := m.Compact()
+		if added > 0 || removed > 0 {
+			log.Printf("compacted cache for %s (added=%d removed=%d)", routeID, added, removed)
+		}
+		totalAdded += added
+		totalRemoved += removed
+	}
+	return totalAdded, totalRemoved
+}
+
+// startSnapshotWriter saves the snapshot of the routes keep accepts, or of all routes when
+// it is nil, every interval and on shutdown. With a wal, each save checkpoints it,
+// dropping the changes the snapshot now holds.
+func startSnapshotWriter(ctx context.Context, path string, interval time.Duration, opts store.SaveOptions, matchStore *store.MatchStore, wal *store.WAL, keep func(routeID string) bool) {
 	if interval <= 0 {
 		interval = 10 * time.Second
 	}
+	save := func() error {
+		if wal == nil {
+			return saveRoutes(matchStore, path, opts, keep)
+		}
+		return wal.Checkpoint(func() error { return saveRoutes(matchStore, path, opts, keep) })
+	}
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
-				_ = store.SaveSnapshot(path)
+				_ = save()
 				return
 			case <-ticker.C:
-				if err := store.SaveSnapshot(path); err != nil {
+				if err := save(); err != nil {
 					log.Printf("warn: snapshot save failed: %v", err)
 				}
 			}
 		}
 	}()
 }
-
-func startHTTPServer(ctx context.Context, addr string, matchers map[string]*engine.Matcher, matchStore *store.MatchStore) error {
-	mux := buildHTTPMux(matchers, matchStore)
-	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
-		BaseContext: func(_ net.Listener) context.Context {
-			return ctx
-		},
-	}
-
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = server.Shutdown(shutdownCtx)
-	}()
-
-	log.Printf("http server listening on %s", addr)
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return err
-	}
-	return ctx.Err()
-}
-
-func buildHTTPMux(matchers map[string]*engine.Matcher, matchStore *store.MatchStore) *http.ServeMux {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		snapshot := matchStore.Snapshot()
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
-			log.Printf("cache snapshot encode failed: %v", err)
-		}
-	})
-	mux.HandleFunc("/cache/clear", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		removed := matchStore.Clear()
-		log.Printf("cache cleared via HTTP (%d fingerprints removed)", removed)
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok\n"))
-	})
-	mux.HandleFunc("/referenceAllRoutes", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		defer r.Body.Close()
-		var values []string
-		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
-			http.Error(w, "invalid JSON array of strings", http.StatusBadRequest)
-			return
-		}
-		if len(values) == 0 {
-			http.Error(w, "empty payload", http.StatusBadRequest)
-			return
-		}
-
-		added := false
-		for _, matcher := range matchers {
-			if matcher.AddValues(values) {
-				added = true
-			}
-		}
-		status := http.StatusOK
-		if added {
-			status = http.StatusCreated
-		}
-		w.WriteHeader(status)
-		_, _ = w.Write([]byte("ok\n"))
-	})
-	mux.HandleFunc("/reference/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		routeID := strings.TrimPrefix(r.URL.Path, "/reference/")
-		if routeID == "" {
-			http.Error(w, "route id required", http.StatusBadRequest)
-			return
-		}
-		matcher, ok := matchers[routeID]
-		if !ok {
-			http.Error(w, "route not found", http.StatusNotFound)
-			return
-		}
-		defer r.Body.Close()
-		var values []string
-		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
-			http.Error(w, "invalid JSON array of strings", http.StatusBadRequest)
-			return
-		}
-		if len(values) == 0 {
-			http.Error(w, "empty payload", http.StatusBadRequest)
-			return
-		}
-		added := matcher.AddValues(values)
-		status := http.StatusOK
-		if added {
-			status = http.StatusCreated
-		}
-		w.WriteHeader(status)
-		_, _ = w.Write([]byte("ok\n"))
-	})
-	return mux
-}
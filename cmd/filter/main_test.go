@@ -1,93 +1,322 @@
 package main
 
 import (
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
-	"kafka-bridge/internal/engine"
-	"kafka-bridge/internal/store"
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
 )
 
-func TestCacheClearEndpoint(t *testing.T) {
-	matchStore := store.NewMatchStore()
-	matchStore.Add("route-a", "value1")
-	matchStore.Add("route-b", "value2")
+func TestWithExplainHeaders(t *testing.T) {
+	headers := []kafka.Header{
+		{Key: "trace-id", Value: []byte("abc")},
+		{Key: "X-Bridge-Route", Value: []byte("upstream-route")},
+	}
+	match := engine.Match{Field: "sub.fieldB", Value: "2023/abc", Fingerprint: "23/abc", Origin: store.Metadata{Source: store.SourceHTTP}}
+
+	got := withExplainHeaders(headers, "route-a", match, 42)
+	want := map[string]string{
+		"trace-id":                "abc",
+		"x-bridge-route":          "route-a",
+		"x-bridge-matched-value":  "23/abc",
+		"x-bridge-matched-field":  "sub.fieldB",
+		"x-bridge-matched-origin": "http",
+		"x-bridge-source-offset":  "42",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d headers, got %d: %v", len(want), len(got), got)
+	}
+	for _, h := range got {
+		if w, ok := want[h.Key]; !ok || w != string(h.Value) {
+			t.Fatalf("unexpected header %s=%s", h.Key, h.Value)
+		}
+	}
+}
+
+func TestSourceReaderConfig(t *testing.T) {
+	cfg := &config.Config{CommitInterval: 5 * time.Second}
+	sc := config.SourceCluster{Name: "source-a", Brokers: []string{"b:9092"}, SourceGroupID: "bridge"}
+	cases := []struct {
+		name     string
+		consumer config.Consumer
+		group    string
+		commit   time.Duration
+		start    int64
+		minBytes int
+		maxBytes int
+	}{
+		{name: "defaults", group: "bridge-route-a", commit: 5 * time.Second, start: kafka.LastOffset},
+		{
+			name:     "overrides",
+			consumer: config.Consumer{GroupSuffix: "bulk", CommitInterval: time.Second, StartOffset: config.StartOffsetEarliest, MinBytes: 1024, MaxBytes: 1 << 20},
+			group:    "bridge-bulk",
+			commit:   time.Second,
+			start:    kafka.FirstOffset,
+			minBytes: 1024,
+			maxBytes: 1 << 20,
+		},
+		{name: "timestamp", consumer: config.Consumer{StartOffset: "2024-01-02T03:04:05Z"}, group: "bridge-route-a", commit: 5 * time.Second, start: kafka.LastOffset},
+	}
+	for _, tc := range cases {
+		route := config.Route{Name: "Route A", SourceTopic: "src", Consumer: tc.consumer}
+		rc := sourceReaderConfig(cfg, route, sc, nil)
+		if rc.GroupID != tc.group || rc.CommitInterval != tc.commit || rc.StartOffset != tc.start || rc.MinBytes != tc.minBytes || rc.MaxBytes != tc.maxBytes {
+			t.Fatalf("%s: unexpected reader config %+v", tc.name, rc)
+		}
+	}
 
-	server := httptest.NewServer(buildHTTPMux(map[string]*engine.Matcher{}, matchStore))
-	t.Cleanup(server.Close)
+	sc.Fetch = config.Fetch{MinBytes: 1 << 10, MaxBytes: 8 << 20, MaxWait: time.Second, QueueCapacity: 1000, SessionTimeout: 45 * time.Second}
+	route := config.Route{Name: "Route A", SourceTopic: "src", MaxInFlight: 500, Consumer: config.Consumer{MaxBytes: 16 << 20, RebalanceTimeout: time.Minute}}
+	rc := sourceReaderConfig(cfg, route, sc, nil)
+	if rc.MinBytes != 1<<10 || rc.MaxBytes != 16<<20 || rc.MaxWait != time.Second || rc.SessionTimeout != 45*time.Second || rc.RebalanceTimeout != time.Minute {
+		t.Fatalf("route consumer settings not applied over the cluster fetch settings: %+v", rc)
+	}
+	if rc.QueueCapacity != 500 {
+		t.Fatalf("QueueCapacity = %d, want it bounded by maxInFlight 500", rc.QueueCapacity)
+	}
+}
 
-	resp, err := http.Post(server.URL+"/cache/clear", "application/json", nil)
+type recordingWriter struct {
+	failures int
+	written  []kafka.Message
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("leader not available")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+// writerFunc adapts a function to delivery.MessageWriter.
+type writerFunc func(ctx context.Context, msgs ...kafka.Message) error
+
+func (f writerFunc) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return f(ctx, msgs...)
+}
+
+func TestForwardMessageRetriesInOrder(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
 	if err != nil {
-		t.Fatalf("POST /cache/clear request failed: %v", err)
+		t.Fatalf("NewMatcher error: %v", err)
 	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	matcher.AddValues([]string{"hit"})
+	route := config.Route{Name: "route-a", DestinationTopic: "dest"}
+	policy := delivery.RetryPolicy{InitialBackoff: time.Millisecond}
+	w := &recordingWriter{failures: 2}
+
+	for i, value := range []string{`{"x":"hit","n":1}`, `{"x":"miss"}`, `{broken`, `{"x":"hit","n":2}`} {
+		msg := kafka.Message{Partition: 3, Offset: int64(i), Value: []byte(value)}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, policy, msg); err != nil {
+			t.Fatalf("forwardMessage(%s): %v", value, err)
+		}
 	}
-	if got := matchStore.Size("route-a"); got != 0 {
-		t.Fatalf("expected cache to be cleared for route-a, got %d", got)
+	if len(w.written) != 2 || string(w.written[0].Value) != `{"x":"hit","n":1}` || string(w.written[1].Value) != `{"x":"hit","n":2}` {
+		t.Fatalf("unexpected writes: %v", w.written)
 	}
-	if got := matchStore.Size("route-b"); got != 0 {
-		t.Fatalf("expected cache to be cleared for route-b, got %d", got)
+	if w.written[0].Partition != 3 {
+		t.Fatalf("expected source partition carried for balancing, got %d", w.written[0].Partition)
 	}
 
-	resp, err = http.Get(server.URL + "/cache/clear")
+	w = &recordingWriter{failures: 5}
+	policy.MaxAttempts = 2
+	err = b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, policy, kafka.Message{Value: []byte(`{"x":"hit"}`)})
+	if err == nil || len(w.written) != 0 {
+		t.Fatalf("expected route to stop after max attempts, got err=%v writes=%v", err, w.written)
+	}
+}
+
+func TestForwardMessageMaxMessageAge(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matcher, err := engine.NewMatcher("route-age", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, store.NewMatchStore())
 	if err != nil {
-		t.Fatalf("GET /cache/clear request failed: %v", err)
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"hit"})
+	route := config.Route{Name: "route-age", DestinationTopic: "dest", MaxMessageAge: time.Hour}
+	stats := b.routeCounters.route(routeKey(route))
+	before := stats.tooOld.Load()
+	w := &recordingWriter{}
+	now := time.Now()
+	for i, at := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Minute), {}} {
+		msg := kafka.Message{Offset: int64(i), Time: at, Value: []byte(`{"fieldA":"hit"}`)}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage(%d): %v", i, err)
+		}
+	}
+	if got := stats.tooOld.Load() - before; got != 1 || len(w.written) != 2 {
+		t.Fatalf("expected the message older than an hour dropped, got %d dropped and %d forwarded", got, len(w.written))
 	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusMethodNotAllowed {
-		t.Fatalf("expected status 405 for GET, got %d", resp.StatusCode)
+	if reason := tooOld(route, kafka.Message{Time: now.Add(-90 * time.Minute)}, now); reason != "message is 1h30m0s old, over maxMessageAge 1h0m0s" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
 	}
+	return ""
 }
 
-func TestCacheGetEndpoint(t *testing.T) {
+func TestForwardMessageDecompressesPayload(t *testing.T) {
+	b := newBridge(config.Logging{})
 	matchStore := store.NewMatchStore()
-	matchStore.Add("route-a", "value1")
-	matchStore.Add("route-b", "value2")
+	matcher, err := engine.NewMatcher("route-gz", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	matcher.AddValues([]string{"abc"})
+	matcher.SetPayloadCompression(config.PayloadCompressionGzip, config.DefaultMaxDecompressedBytes)
+	plain := []byte(`{"fieldA":"abc"}`)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(plain)
+	zw.Close()
+	msg := kafka.Message{Value: gz.Bytes()}
 
-	server := httptest.NewServer(buildHTTPMux(map[string]*engine.Matcher{}, matchStore))
-	t.Cleanup(server.Close)
+	for _, forwardDecompressed := range []bool{false, true} {
+		route := config.Route{Name: "route-gz", DestinationTopic: "dest", Payload: config.Payload{Compression: config.PayloadCompressionGzip, ForwardDecompressed: forwardDecompressed}}
+		w := &recordingWriter{}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
+		want := gz.Bytes()
+		if forwardDecompressed {
+			want = plain
+		}
+		if len(w.written) != 1 || !bytes.Equal(w.written[0].Value, want) {
+			t.Fatalf("forwardDecompressed=%v: unexpected forwarded values %v", forwardDecompressed, w.written)
+		}
+	}
+}
 
-	resp, err := http.Get(server.URL + "/cache")
+func TestForwardMessageKeyField(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matcher, err := engine.NewMatcher("route-key", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, store.NewMatchStore())
 	if err != nil {
-		t.Fatalf("GET /cache request failed: %v", err)
+		t.Fatalf("NewMatcher: %v", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	matcher.AddValues([]string{"abc"})
+	route := config.Route{Name: "route-key", DestinationTopic: "dest", Delivery: config.Delivery{Partitioner: config.PartitionerKey, KeyField: "customer.id"}}
+	w := &recordingWriter{}
+	for _, value := range []string{`{"fieldA":"abc","customer":{"id":42}}`, `{"fieldA":"abc"}`} {
+		msg := kafka.Message{Key: []byte("source-key"), Value: []byte(value)}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
 	}
-	var snapshot map[string][]string
-	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
-		t.Fatalf("failed to decode snapshot: %v", err)
+	if len(w.written) != 2 || string(w.written[0].Key) != "42" || string(w.written[1].Key) != "source-key" {
+		t.Fatalf("unexpected forwarded keys: %v", w.written)
 	}
-	if len(snapshot) != 2 {
-		t.Fatalf("expected 2 routes in snapshot, got %d", len(snapshot))
+}
+
+func TestForwardedKey(t *testing.T) {
+	matcher, err := engine.NewMatcher("route-key", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
 	}
-	if vals, ok := snapshot["route-a"]; !ok || !contains(vals, "value1") {
-		t.Fatalf("route-a missing expected value: %v", snapshot["route-a"])
+	withOrder, withoutOrder := []byte(`{"fieldA":"abc","order":{"id":"o-7"}}`), []byte(`{"fieldA":"abc"}`)
+	for _, tc := range []struct {
+		key   config.DestinationKey
+		value []byte
+		src   string
+		want  string
+	}{
+		{config.DestinationKey{From: config.DestinationKeySource}, withOrder, "src", "src"},
+		{config.DestinationKey{From: config.DestinationKeySource, Constant: "none"}, withOrder, "", "none"},
+		{config.DestinationKey{From: config.DestinationKeyConstant, Constant: "fixed"}, withOrder, "src", "fixed"},
+		{config.DestinationKey{From: config.DestinationKeyField, Field: "order.id"}, withOrder, "src", "o-7"},
+		{config.DestinationKey{From: config.DestinationKeyField, Field: "order.id"}, withoutOrder, "src", "src"},
+		{config.DestinationKey{From: config.DestinationKeyField, Field: "order.id", Constant: "none"}, withoutOrder, "", "none"},
+	} {
+		route := config.Route{Name: "route-key", DestinationKey: &tc.key}
+		if got := string(forwardedKey(route, matcher, tc.value, []byte(tc.src))); got != tc.want {
+			t.Errorf("%+v with source key %q: forwarded under %q, want %q", tc.key, tc.src, got, tc.want)
+		}
 	}
-	if vals, ok := snapshot["route-b"]; !ok || !contains(vals, "value2") {
-		t.Fatalf("route-b missing expected value: %v", snapshot["route-b"])
+}
+
+// scriptedReader serves msgs, then blocks until its context ends, recording commits.
+type scriptedReader struct {
+	msgs      []kafka.Message
+	committed [][]kafka.Message
+}
+
+func (r *scriptedReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
 	}
+	msg := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return msg, nil
+}
 
-	resp, err = http.Post(server.URL+"/cache", "application/json", nil)
+func (r *scriptedReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func TestStreamRouteReadsEverySource(t *testing.T) {
+	b := newBridge(config.Logging{})
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	cfg := &config.Config{ReferenceGroupID: "refs"}
+	route := config.Route{Name: "merged", SourceCluster: "mock", DestinationTopic: "matched",
+		Sources:        []config.RouteSource{{Cluster: "mock", Topic: "orders-eu"}, {Cluster: "mock", Topic: "orders-us"}},
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}}}
+	matchStore := store.NewMatchStore()
+	matcher, err := newRouteMatcher(cfg, route, matchStore)
 	if err != nil {
-		t.Fatalf("POST /cache request failed: %v", err)
+		t.Fatal(err)
 	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusMethodNotAllowed {
-		t.Fatalf("expected status 405 for POST, got %d", resp.StatusCode)
+	matcher.AddValues([]string{"c1"})
+	for _, topic := range []string{"orders-eu", "orders-us"} {
+		if _, err := b.memoryBroker.Produce(topic,
+			// the match comes last, so once it is forwarded both were consumed
+			kafka.Message{Value: []byte(`{"customer":"c2"}`)},
+			kafka.Message{Value: []byte(`{"customer":"c1"}`)}); err != nil {
+			t.Fatal(err)
+		}
 	}
-}
+	writers := delivery.NewPool(nil, nil, delivery.WithTopicWriters(func(topic string) delivery.MessageWriter { return b.memoryBroker.Topic(topic) }))
 
-func contains(values []string, target string) bool {
-	for _, v := range values {
-		if v == target {
-			return true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = b.streamRoute(ctx, cfg, route, config.SourceCluster{Name: "mock", SourceGroupID: "src"}, nil, writers, matchStore, matcher)
+	}()
+	for deadline := time.Now().Add(2 * time.Second); len(b.memoryBroker.Messages("matched")) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for both sources, got %d message(s)", len(b.memoryBroker.Messages("matched")))
 		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	stats := b.routeCounters.route(routeKey(route)).report(routeKey(route), 0, time.Now())
+	want := []SourceReport{
+		{Cluster: "mock", Topic: "orders-eu", Consumed: 2, Forwarded: 1},
+		{Cluster: "mock", Topic: "orders-us", Consumed: 2, Forwarded: 1},
+	}
+	if !reflect.DeepEqual(stats.Sources, want) {
+		t.Fatalf("per-source stats = %+v, want %+v", stats.Sources, want)
 	}
-	return false
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/store"
+)
+
+// errMemoryBudget refuses an admin add while the refuse-adds policy is in force.
+var errMemoryBudget = errors.New("memory budget exceeded: adds are refused until usage drops")
+
+type budgetMonitor struct {
+	mu       sync.Mutex
+	usage    map[string]int64
+	evicted  map[string]uint64
+	shedding map[string]string
+	// changed is closed and replaced whenever shedding changes, waking paused collectors.
+	changed chan struct{}
+	// counters supplies the in-flight bytes counted in each route's usage.
+	counters *statsRegistry
+}
+
+// memoryBudgeted reports whether cfg sets a global or per-route memory budget.
+func memoryBudgeted(cfg *config.Config) bool {
+	if cfg.Memory.MaxBytes > 0 {
+		return true
+	}
+	for _, route := range cfg.Routes {
+		if route.Memory != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// messageBytes approximates what a fetched source message holds in memory.
+func messageBytes(msg kafka.Message) int64 {
+	n := len(msg.Key) + len(msg.Value) + len(msg.Topic)
+	for _, h := range msg.Headers {
+		n += len(h.Key) + len(h.Value)
+	}
+	return int64(n)
+}
+
+// runMemoryBudget measures memory every checkInterval until ctx is done.
+func (b *bridge) runMemoryBudget(ctx context.Context, cfg *config.Config, matchStore *store.MatchStore) {
+	ticker := time.NewTicker(cfg.Memory.CheckInterval)
+	defer ticker.Stop()
+	for {
+		b.memoryBudget.check(cfg, matchStore)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check measures the cache and in-flight messages of every route. A route over its own
+// budget sheds under its policy; when all routes together are over the global budget,
+// every other route sheds under the global policy. evict-oldest drops the values cached
+// longest ago right away, from the largest caches first for the global budget.
+func (b *budgetMonitor) check(cfg *config.Config, matchStore *store.MatchStore) {
+	usage := make(map[string]int64, len(cfg.Routes))
+	cached := make(map[string]int64, len(cfg.Routes))
+	var total int64
+	for _, route := range cfg.Routes {
+		id := routeKey(route)
+		cached[id] = matchStore.MemoryUsage(id)
+		usage[id] = cached[id] + b.counters.route(id).inFlightBytes.Load()
+		total += usage[id]
+	}
+
+	shedding := make(map[string]string)
+	evict := make(map[string]int64)
+	for _, route := range cfg.Routes {
+		id := routeKey(route)
+		if route.Memory == nil || usage[id] <= route.Memory.MaxBytes {
+			continue
+		}
+		shedding[id] = cfg.MemoryPolicy(route)
+		if shedding[id] == config.MemoryEvictPolicy {
+			evict[id] = min(usage[id]-route.Memory.MaxBytes, cached[id])
+		}
+	}
+	if limit := cfg.Memory.MaxBytes; limit > 0 && total > limit {
+		ids := make([]string, 0, len(usage))
+		for id := range usage {
+			if _, ok := shedding[id]; !ok {
+				shedding[id] = cfg.Memory.Policy
+			}
+			ids = append(ids, id)
+		}
+		if cfg.Memory.Policy == config.MemoryEvictPolicy {
+			sort.Slice(ids, func(i, j int) bool { return cached[ids[i]] > cached[ids[j]] })
+			excess := total - limit
+			for _, id := range ids {
+				excess -= evict[id]
+			}
+			for _, id := range ids {
+				if excess <= 0 {
+					break
+				}
+				more := min(excess, cached[id]-evict[id])
+				evict[id] += more
+				excess -= more
+			}
+		}
+	}
+
+	evicted := make(map[string]int, len(evict))
+	for id, bytes := range evict {
+		if n := matchStore.EvictOldest(id, bytes); n > 0 {
+			evicted[id] = n
+			log.Printf("warn: route %s: memory budget exceeded, evicted the %d oldest value(s)", id, n)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.usage = usage
+	if b.evicted == nil {
+		b.evicted = make(map[string]uint64)
+	}
+	for id, n := range evicted {
+		b.evicted[id] += uint64(n)
+	}
+	if maps.Equal(shedding, b.shedding) {
+		return
+	}
+	for id, policy := range shedding {
+		if b.shedding[id] != policy {
+			log.Printf("event: route %s: memory budget exceeded, applying %s", id, policy)
+		}
+	}
+	for id := range b.shedding {
+		if _, ok := shedding[id]; !ok {
+			log.Printf("event: route %s: memory back within budget", id)
+		}
+	}
+	b.shedding = shedding
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// policy returns the policy routeID sheds under, or "" when it is within budget.
+func (b *budgetMonitor) policy(routeID string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.shedding[routeID]
+}
+
+// refusesAdds reports whether an admin add to routeID, or to every route when it is
+// empty, is refused.
+func (b *budgetMonitor) refusesAdds(routeID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if routeID != "" {
+		return b.shedding[routeID] == config.MemoryRefusePolicy
+	}
+	for _, policy := range b.shedding {
+		if policy == config.MemoryRefusePolicy {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitReferences blocks a reference collector or poller of routeID while the route
+// sheds under pause-references.
+func (b *budgetMonitor) awaitReferences(ctx context.Context, routeID string) error {
+	for {
+		b.mu.Lock()
+		paused, changed := b.shedding[routeID] == config.MemoryPausePolicy, b.changed
+		b.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// adminErrorStatus is the HTTP status of a failed admin command.
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errMemoryBudget):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, errBroadcast):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// metrics reports the measured memory, budget, shedding, and evictions of every route,
+// once memory has been measured.
+func (b *budgetMonitor) metrics(cfg *config.Config) []metrics.Family {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.usage == nil || cfg == nil {
+		return nil
+	}
+	routes := make([]string, 0, len(b.usage))
+	for id := range b.usage {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+
+	used := metrics.Family{Name: "kafka_bridge_memory_bytes", Help: "Approximate memory of each route's cache and in-flight source messages.", Type: metrics.TypeGauge}
+	budget := metrics.Family{Name: "kafka_bridge_memory_budget_bytes", Help: "Configured memory budget (route empty for the global one, 0 = unbounded).", Type: metrics.TypeGauge}
+	shedding := metrics.Family{Name: "kafka_bridge_memory_shedding", Help: "1 while a route sheds under the labelled policy because a memory budget is exceeded.", Type: metrics.TypeGauge}
+	evicted := metrics.Family{Name: "kafka_bridge_memory_evictions_total", Help: "Values evicted by the evict-oldest memory policy.", Type: metrics.TypeCounter}
+	budget.Add(metrics.Labels{"route": ""}, float64(cfg.Memory.MaxBytes))
+	limits := make(map[string]int64)
+	for _, route := range cfg.Routes {
+		if route.Memory != nil {
+			limits[routeKey(route)] = route.Memory.MaxBytes
+		}
+	}
+	for _, id := range routes {
+		labels := metrics.Labels{"route": id}
+		used.Add(labels, float64(b.usage[id]))
+		if limit, ok := limits[id]; ok {
+			budget.Add(labels, float64(limit))
+		}
+		if policy := b.shedding[id]; policy != "" {
+			shedding.Add(metrics.Labels{"route": id, "policy": policy}, 1)
+		}
+		evicted.Add(labels, float64(b.evicted[id]))
+	}
+	families := []metrics.Family{used, budget, evicted}
+	if len(shedding.Samples) > 0 {
+		families = append(families, shedding)
+	}
+	return families
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestMemoryBudgetSheds(t *testing.T) {
+	b := newBridge(config.Logging{})
+
+	matchStore := store.NewMatchStore()
+	start := time.Now().Add(-time.Hour)
+	for i := range 10 {
+		matchStore.AddWithMeta("big", "value-"+strconv.Itoa(i), store.Metadata{AddedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	matchStore.AddAll("small", []string{"a", "b"})
+	cfg := &config.Config{
+		Memory: config.Memory{MaxBytes: 1000, Policy: config.MemoryRefusePolicy},
+		Routes: []config.Route{
+			{Name: "big", Memory: &config.Memory{MaxBytes: 500, Policy: config.MemoryEvictPolicy}},
+			{Name: "small"},
+		},
+	}
+
+	b.memoryBudget.check(cfg, matchStore)
+	if n := matchStore.Size("big"); n != 2 {
+		t.Fatalf("expected big evicted down to its budget, %d value(s) left", n)
+	}
+	if !matchStore.Contains("big", "value-9") || !matchStore.Contains("big", "value-8") {
+		t.Fatal("expected the oldest values of big to be evicted")
+	}
+	if !b.memoryBudget.refusesAdds("small") || b.memoryBudget.refusesAdds("big") || !b.memoryBudget.refusesAdds("") {
+		t.Fatalf("expected the global refuse-adds policy on small only, shedding %v", b.memoryBudget.shedding)
+	}
+	admin := adminDeps{bridge: b, matchers: map[string]*engine.Matcher{}, store: matchStore}
+	if _, err := admin.submit(context.Background(), "", kafkapkg.Command{Op: kafkapkg.CommandInject, Route: "small", Values: []string{"c"}}); !errors.Is(err, errMemoryBudget) {
+		t.Fatalf("expected the add to be refused, got %v", err)
+	}
+
+	// the eviction brought the total back within the global budget
+	b.memoryBudget.check(cfg, matchStore)
+	if b.memoryBudget.refusesAdds("") || b.memoryBudget.policy("big") != "" {
+		t.Fatalf("expected no shedding left, got %v", b.memoryBudget.shedding)
+	}
+
+	cfg.Memory.Policy = config.MemoryPausePolicy
+	cfg.Memory.MaxBytes = 1
+	b.memoryBudget.check(cfg, matchStore)
+	done := make(chan error, 1)
+	go func() { done <- b.memoryBudget.awaitReferences(context.Background(), "small") }()
+	select {
+	case err := <-done:
+		t.Fatalf("expected the reference collector to pause, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cfg.Memory.MaxBytes = 0
+	b.memoryBudget.check(cfg, matchStore)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the reference collector to resume within budget")
+	}
+}
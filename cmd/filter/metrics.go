@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+
+	"kafka-bridge/internal/metrics"
+	"kafka-bridge/pkg/delivery"
+)
+
+// cacheMetrics reports per-route cache size and eviction counters.
+func cacheMetrics(admin adminDeps) []metrics.Family {
+	routes := make([]string, 0, len(admin.matchers))
+	for id := range admin.matchers {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+
+	values := metrics.Family{Name: "kafka_bridge_cache_values", Help: "Values cached per route.", Type: metrics.TypeGauge}
+	limit := metrics.Family{Name: "kafka_bridge_cache_max_values", Help: "Configured maxValues per route (0 = unlimited).", Type: metrics.TypeGauge}
+	evicted := metrics.Family{Name: "kafka_bridge_cache_evictions_total", Help: "Values evicted because the route reached maxValues.", Type: metrics.TypeCounter}
+	rejected := metrics.Family{Name: "kafka_bridge_cache_rejected_total", Help: "New values refused because the route reached maxValues (reject-new).", Type: metrics.TypeCounter}
+	for _, id := range routes {
+		stats := admin.store.Stats(id)
+		labels := metrics.Labels{"route": id}
+		values.Add(labels, float64(stats.Values))
+		limit.Add(labels, float64(stats.MaxValues))
+		evicted.Add(labels, float64(stats.Evicted))
+		rejected.Add(labels, float64(stats.Rejected))
+	}
+	return []metrics.Family{values, limit, evicted, rejected}
+}
+
+// writerMetrics reports the bytes the destination writers delivered before and after
+// compression, per topic and codec, and the kafka-go statistics of each topic's writers.
+func writerMetrics(writers *delivery.Pool) []metrics.Family {
+	if writers == nil {
+		return nil
+	}
+	uncompressed := metrics.Family{Name: "kafka_bridge_writer_uncompressed_bytes_total", Help: "Keys, values, and headers of the messages written to the bridge cluster.", Type: metrics.TypeCounter}
+	sent := metrics.Family{Name: "kafka_bridge_writer_sent_bytes_total", Help: "Bytes sent to the bridge cluster brokers after compression, including protocol overhead and retries.", Type: metrics.TypeCounter}
+	for _, b := range writers.Bytes() {
+		labels := metrics.Labels{"topic": b.Topic, "compression": b.Compression}
+		uncompressed.Add(labels, float64(b.Uncompressed))
+		sent.Add(labels, float64(b.Sent))
+	}
+
+	open := metrics.Family{Name: "kafka_bridge_writer_open", Help: "Open destination writers per topic.", Type: metrics.TypeGauge}
+	writes := metrics.Family{Name: "kafka_bridge_writer_writes_total", Help: "Produce requests the writers of each topic sent.", Type: metrics.TypeCounter}
+	messages := metrics.Family{Name: "kafka_bridge_writer_messages_total", Help: "Messages the writers of each topic wrote.", Type: metrics.TypeCounter}
+	errors := metrics.Family{Name: "kafka_bridge_writer_errors_total", Help: "Failed writes of the writers of each topic.", Type: metrics.TypeCounter}
+	retries := metrics.Family{Name: "kafka_bridge_writer_retries_total", Help: "Writes the writers of each topic retried.", Type: metrics.TypeCounter}
+	evicted := metrics.Family{Name: "kafka_bridge_writer_evictions_total", Help: "Writers closed after bridgeCluster.writers.idleTimeout unused.", Type: metrics.TypeCounter}
+	for _, s := range writers.Stats() {
+		labels := metrics.Labels{"topic": s.Topic}
+		open.Add(labels, float64(s.Writers))
+		writes.Add(labels, float64(s.Writes))
+		messages.Add(labels, float64(s.Messages))
+		errors.Add(labels, float64(s.Errors))
+		retries.Add(labels, float64(s.Retries))
+		evicted.Add(labels, float64(s.Evicted))
+	}
+	return []metrics.Family{uncompressed, sent, open, writes, messages, errors, retries, evicted}
+}
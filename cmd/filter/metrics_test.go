@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestMetricsEndpointReportsEvictions(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-a", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matchStore.SetLimit("route-a", store.Limit{MaxValues: 1, Policy: store.EvictLRU})
+	matcher.AddValues([]string{"one", "two"})
+
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-a": matcher}, store: matchStore}))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	for _, line := range []string{
+		`kafka_bridge_cache_values{route="route-a"} 1`,
+		`kafka_bridge_cache_max_values{route="route-a"} 1`,
+		`kafka_bridge_cache_evictions_total{route="route-a"} 1`,
+		`kafka_bridge_cache_rejected_total{route="route-a"} 0`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("metrics missing %q:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+)
+
+// messageReader is the part of *kafka.Reader, and of the in-memory reader of -mock, that
+// the source and reference loops use.
+type messageReader interface {
+	sourceReader
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// newMessageReader returns a reader for rc, or one of the in-memory broker's with -mock.
+func (b *bridge) newMessageReader(rc kafka.ReaderConfig) messageReader {
+	if b.memoryBroker != nil {
+		return b.memoryBroker.Reader(rc.GroupID, rc.GroupTopics)
+	}
+	return kafka.NewReader(rc)
+}
+
+// validateMock rejects the settings -mock cannot emulate: they need the group membership
+// or topic metadata of a real cluster.
+func validateMock(cfg *config.Config) error {
+	var errs []error
+	if cfg.Storage.Backend == config.StorageBackendKafka {
+		errs = append(errs, errors.New("storage.backend kafka needs a cluster; use file or sqlite storage, or none"))
+	}
+	if cfg.Coordination.Topic != "" || cfg.Coordination.LeaderElection.Enabled {
+		errs = append(errs, errors.New("coordination needs a cluster; run a single replica without it"))
+	}
+	for _, route := range cfg.Routes {
+		if route.SourceTopicPattern != "" {
+			errs = append(errs, fmt.Errorf("route %s: sourceTopicPattern needs topic metadata; name the sourceTopic", route.DisplayName()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wireMessage is a Kafka message as JSON, as produced to and read from the in-memory
+// broker and as written by `filter run -source-file`.
+type wireMessage struct {
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Key       string `json:"key,omitempty"`
+	// Value is a JSON document, or a string holding the raw bytes.
+	Value   json.RawMessage   `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+func (m wireMessage) message() kafka.Message {
+	msg := kafka.Message{Partition: m.Partition, Key: []byte(m.Key), Value: TestMatchRequest{Payload: m.Value}.rawPayload(), Time: m.Time}
+	if m.Key == "" {
+		msg.Key = nil
+	}
+	for k, v := range m.Headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return msg
+}
+
+// newWireMessage renders msg, with its value as JSON when it is a JSON document.
+func newWireMessage(msg kafka.Message) wireMessage {
+	m := wireMessage{Partition: msg.Partition, Offset: msg.Offset, Key: string(msg.Key), Time: msg.Time}
+	if json.Valid(msg.Value) {
+		m.Value = append(json.RawMessage(nil), msg.Value...)
+	} else {
+		m.Value, _ = json.Marshal(string(msg.Value))
+	}
+	if len(msg.Headers) > 0 {
+		m.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			m.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return m
+}
+
+// registerMock mounts /mock/topics/{topic}, through which tests produce source and
+// reference messages to the in-memory broker and read what the routes forwarded.
+func registerMock(mux *http.ServeMux, admin adminDeps) {
+	broker := admin.memoryBroker
+	mux.HandleFunc("/mock/topics/{topic}", admin.authorized(func(w http.ResponseWriter, r *http.Request) {
+		topic := r.PathValue("topic")
+		resp := MockMessages{Messages: []wireMessage{}}
+		status := http.StatusOK
+		switch r.Method {
+		case http.MethodGet:
+			for _, msg := range broker.Messages(topic) {
+				resp.Messages = append(resp.Messages, newWireMessage(msg))
+			}
+		case http.MethodPost:
+			defer r.Body.Close()
+			var req MockMessages
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON messages", http.StatusBadRequest)
+				return
+			}
+			msgs := make([]kafka.Message, 0, len(req.Messages))
+			for _, m := range req.Messages {
+				if len(m.Value) == 0 {
+					http.Error(w, "value required", http.StatusBadRequest)
+					return
+				}
+				msgs = append(msgs, m.message())
+			}
+			stored, err := broker.Produce(topic, msgs...)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, msg := range stored {
+				resp.Messages = append(resp.Messages, newWireMessage(msg))
+			}
+			status = http.StatusCreated
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("mock messages encode failed: %v", err)
+		}
+	}))
+}
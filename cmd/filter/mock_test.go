@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/store"
+)
+
+func TestMockModeForwardsEndToEnd(t *testing.T) {
+	b := newBridge(config.Logging{})
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	cfg := &config.Config{ReferenceGroupID: "refs"}
+	route := config.Route{Name: "mock-route", SourceTopic: "orders", DestinationTopic: "matched",
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}}}
+	cfg.Routes = []config.Route{route}
+	if err := validateMock(cfg); err != nil {
+		t.Fatalf("validateMock: %v", err)
+	}
+	if err := validateMock(&config.Config{Storage: config.Storage{Backend: config.StorageBackendKafka}}); err == nil {
+		t.Fatal("expected kafka storage to be rejected in mock mode")
+	}
+
+	matchStore := store.NewMatchStore()
+	matcher, err := newRouteMatcher(cfg, route, matchStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writers := delivery.NewPool(nil, nil, delivery.WithTopicWriters(func(topic string) delivery.MessageWriter { return b.memoryBroker.Topic(topic) }))
+	mux := buildHTTPMux(adminDeps{bridge: b, store: matchStore})
+	produce := func(topic, body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mock/topics/"+topic, strings.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("produce to %s: %d %s", topic, rec.Code, rec.Body)
+		}
+	}
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); !cond(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = b.runReferenceCollector(ctx, cfg, route, nil, matcher, nil) }()
+	go func() {
+		_ = b.streamRoute(ctx, cfg, route, config.SourceCluster{Name: "mock", SourceGroupID: "src"}, nil, writers, matchStore, matcher)
+	}()
+
+	produce("customers", `{"messages":[{"value":{"id":"c1"}}]}`)
+	eventually("the reference value", func() bool { return matcher.Size() == 1 })
+	produce("orders", `{"messages":[{"key":"o1","value":{"order":"o1","customer":"c1"}},{"value":{"order":"o2","customer":"c2"}},{"value":"not json"}]}`)
+	var forwarded MockMessages
+	eventually("the forwarded message", func() bool {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mock/topics/matched", nil))
+		forwarded = MockMessages{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &forwarded)
+		return len(forwarded.Messages) > 0
+	})
+	if len(forwarded.Messages) != 1 || forwarded.Messages[0].Key != "o1" || !strings.Contains(string(forwarded.Messages[0].Value), `"order":"o1"`) {
+		t.Fatalf("unexpected forwarded messages: %+v", forwarded.Messages)
+	}
+}
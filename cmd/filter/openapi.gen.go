@@ -0,0 +1,342 @@
+// Code generated by openapigen. DO NOT EDIT.
+// source: openapi.json
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// AssignedPartition is the AssignedPartition schema.
+//
+// A partition assigned to a reader, with the offset it started from.
+type AssignedPartition struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	// Offset the reader started the partition from in this generation; negative values are
+	// kafka-go's first and last offset markers.
+	StartOffset int64 `json:"startOffset"`
+}
+
+// CachedValue is the CachedValue schema.
+type CachedValue struct {
+	Fingerprint string         `json:"fingerprint"`
+	Canonical   string         `json:"canonical"`
+	Origin      store.Metadata `json:"origin"`
+}
+
+// CompactResult is the CompactResult schema.
+type CompactResult struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Features is the Features schema.
+//
+// What the build supports and what the running config enables.
+type Features struct {
+	// Cluster authentication mechanisms the build supports.
+	AuthModes []string `json:"authModes"`
+	// Where the match cache is persisted; memory when nothing is. One of file, sqlite, kafka,
+	// memory.
+	StorageBackend string `json:"storageBackend"`
+	AdminToken     bool   `json:"adminToken"`
+	ReadOnlyAdmin  bool   `json:"readOnlyAdmin"`
+	// Whether the gRPC reference API is served.
+	GRPC           bool `json:"grpc"`
+	Coordination   bool `json:"coordination"`
+	LeaderElection bool `json:"leaderElection"`
+	SchemaDrift    bool `json:"schemaDrift"`
+	Watchdog       bool `json:"watchdog"`
+	Audit          bool `json:"audit"`
+	Debug          bool `json:"debug"`
+	Mock           bool `json:"mock"`
+}
+
+// ForwardedPosition is the ForwardedPosition schema.
+type ForwardedPosition struct {
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	At        time.Time `json:"at"`
+}
+
+// ImportResult is the ImportResult schema.
+//
+// The response of POST /cache/import.
+type ImportResult struct {
+	// One of merge, replace.
+	Mode    string `json:"mode"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	// Snapshot routes that are not configured on this bridge.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// MockMessages is the MockMessages schema.
+//
+// The body of /mock/topics/{topic} requests and responses.
+type MockMessages struct {
+	Messages []wireMessage `json:"messages"`
+}
+
+// ReaderAssignment is the ReaderAssignment schema.
+//
+// The group membership of one reader of a route.
+type ReaderAssignment struct {
+	// One of source, reference.
+	Reader     string `json:"reader"`
+	GroupID    string `json:"groupId"`
+	MemberID   string `json:"memberId,omitempty"`
+	Generation int    `json:"generation"`
+	// Sorted by topic and partition; empty between generations.
+	Partitions []AssignedPartition `json:"partitions"`
+	// When the current partitions were assigned; omitted before the first assignment.
+	AssignedAt time.Time `json:"assignedAt,omitzero"`
+	// Assignments received since the reader was opened.
+	Rebalances int `json:"rebalances"`
+}
+
+// Readiness is the Readiness schema.
+//
+// The body of GET /readyz.
+type Readiness struct {
+	Ready bool `json:"ready"`
+	// Restore attempts of a required storage that failed.
+	FailedRestores int `json:"failedRestores"`
+	// Why the last restore attempt failed, while not ready.
+	Error string `json:"error,omitempty"`
+	// Reference lag of each route waiting for its reference feeds to catch up, by route key;
+	// -1 until first measured.
+	WarmingUp map[string]int64 `json:"warmingUp,omitempty"`
+	// Failed route workers waiting to be restarted.
+	Restarting []RestartingWorker `json:"restarting,omitempty"`
+}
+
+// ReferenceValues is the ReferenceValues schema.
+//
+// Values with annotations recorded against them. The reference endpoints also accept a
+// plain JSON array of values.
+type ReferenceValues struct {
+	Values      []string          `json:"values"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RestartingWorker is the RestartingWorker schema.
+//
+// A failed worker of a route waiting for its restart.
+type RestartingWorker struct {
+	Route string `json:"route"`
+	// One of stream, collector.
+	Worker string `json:"worker"`
+	Error  string `json:"error"`
+}
+
+// RouteAssignments is the RouteAssignments schema.
+//
+// The body of GET /routes/{id}/assignments.
+type RouteAssignments struct {
+	Route   string             `json:"route"`
+	Readers []ReaderAssignment `json:"readers"`
+}
+
+// RouteCache is the RouteCache schema.
+//
+// A route's cached fingerprints with their provenance.
+type RouteCache struct {
+	Route       string            `json:"route"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Values      []CachedValue     `json:"values"`
+}
+
+// RouteEvent is the RouteEvent schema.
+//
+// The JSON data of a "decision" server-sent event.
+type RouteEvent struct {
+	Route string `json:"route"`
+	// One of forwarded, skipped, invalid, dropped.
+	Decision    string          `json:"decision"`
+	Partition   int             `json:"partition"`
+	Offset      int64           `json:"offset"`
+	Field       string          `json:"field,omitempty"`
+	Value       string          `json:"value,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Origin      *store.Metadata `json:"origin,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Destination string          `json:"destination,omitempty"`
+	At          time.Time       `json:"at"`
+}
+
+// RouteFeed is the RouteFeed schema.
+type RouteFeed struct {
+	Name          string   `json:"name"`
+	Topic         string   `json:"topic"`
+	MatchFields   []string `json:"matchFields"`
+	PayloadFormat string   `json:"payloadFormat,omitempty"`
+}
+
+// RouteInfo is the RouteInfo schema.
+//
+// Listed by GET /routes: what a route reads and writes, its consumer groups and state,
+// and its counters.
+type RouteInfo struct {
+	RouteStats
+	Name string `json:"name,omitempty"`
+	// A route is paused once past its expiry, and in error while its stream waits to be
+	// restarted, with error saying why. One of starting, running, paused, error.
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+	// Why the reference collector failed, while it waits to be restarted.
+	CollectorError string `json:"collectorError,omitempty"`
+	// Memory policy the route sheds under while a memory budget is exceeded. One of
+	// pause-references, evict-oldest, refuse-adds.
+	MemoryShedding     string `json:"memoryShedding,omitempty"`
+	SourceCluster      string `json:"sourceCluster,omitempty"`
+	SourceTopic        string `json:"sourceTopic,omitempty"`
+	SourceTopicPattern string `json:"sourceTopicPattern,omitempty"`
+	// One of kafka, webhook.
+	DestinationType  string      `json:"destinationType,omitempty"`
+	Destination      string      `json:"destination,omitempty"`
+	SourceGroupID    string      `json:"sourceGroupId,omitempty"`
+	ReferenceGroupID string      `json:"referenceGroupId,omitempty"`
+	ReferenceFeeds   []RouteFeed `json:"referenceFeeds,omitempty"`
+	// The referenceHTTP URL without credentials or query.
+	ReferenceHTTP string `json:"referenceHttp,omitempty"`
+	// The database/sql driver of the route's referenceSQL query.
+	ReferenceSQL string `json:"referenceSql,omitempty"`
+}
+
+// RouteStats is the RouteStats schema.
+//
+// Returned by GET /routes/{id}/stats and listed by GET /routes.
+type RouteStats struct {
+	Route     string `json:"route"`
+	Consumed  uint64 `json:"consumed"`
+	Forwarded uint64 `json:"forwarded"`
+	// Valid messages that matched no cached value.
+	Skipped      uint64 `json:"skipped"`
+	DecodeErrors uint64 `json:"decodeErrors"`
+	// Failed destination write attempts, including ones later retried.
+	WriteErrors uint64 `json:"writeErrors"`
+	// Messages refused by loop prevention.
+	Dropped    uint64 `json:"dropped"`
+	Tombstones uint64 `json:"tombstones"`
+	// Messages over delivery.maxMessageBytes, whatever the policy did.
+	Oversized uint64 `json:"oversized"`
+	// Matches left to a higher-priority route of the route's group.
+	Preempted uint64 `json:"preempted"`
+	// Matches suppressed by the route's dedup window.
+	Duplicates uint64 `json:"duplicates"`
+	// Messages skipped by sourceHeaderFilters without being decoded; they are not counted as
+	// skipped.
+	HeaderFiltered uint64 `json:"headerFiltered"`
+	// Messages dropped before matching for being older than maxMessageAge.
+	TooOld uint64 `json:"tooOld"`
+	// Source messages fetched but not yet committed.
+	InFlight      int64              `json:"inFlight"`
+	LastForwarded *ForwardedPosition `json:"lastForwarded,omitempty"`
+	CachedValues  int                `json:"cachedValues"`
+	StartedAt     time.Time          `json:"startedAt,omitzero"`
+	UptimeSeconds float64            `json:"uptimeSeconds"`
+	// When the counters started counting under storage.persistCounters, which keeps them
+	// across restarts; without it they count since startedAt.
+	CountedSince time.Time `json:"countedSince,omitzero"`
+	// Consumed and forwarded per source topic, for a route with sources.
+	Sources []SourceReport `json:"sources,omitempty"`
+	// Restarts of the route's stream after it failed.
+	Restarts uint64 `json:"restarts"`
+	// Restarts of the route's reference collector after it failed.
+	CollectorRestarts uint64 `json:"collectorRestarts"`
+}
+
+// SeekPartition is the SeekPartition schema.
+type SeekPartition struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	// The committed offset, or -1 when the group had committed none.
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// SeekRequest is the SeekRequest schema.
+//
+// Set exactly one of offset, timestamp, earliest, and latest.
+type SeekRequest struct {
+	// Absolute offset on every partition, clamped to the offsets retained.
+	Offset *int64 `json:"offset,omitempty"`
+	// The first offset written at or after this time on every partition.
+	Timestamp time.Time `json:"timestamp,omitzero"`
+	Earliest  bool      `json:"earliest,omitempty"`
+	Latest    bool      `json:"latest,omitempty"`
+	// The route key; required to apply the seek rather than preview it.
+	Confirm string `json:"confirm,omitempty"`
+}
+
+// SeekResponse is the SeekResponse schema.
+//
+// The offsets a seek moves, or would move, the group to.
+type SeekResponse struct {
+	Route   string `json:"route"`
+	GroupID string `json:"groupId"`
+	// earliest, latest, an RFC 3339 timestamp, or an offset.
+	Target     string          `json:"target"`
+	Applied    bool            `json:"applied"`
+	Partitions []SeekPartition `json:"partitions"`
+}
+
+// SourceReport is the SourceReport schema.
+//
+// The count of one source topic in the statistics of a route with sources.
+type SourceReport struct {
+	Cluster   string `json:"cluster"`
+	Topic     string `json:"topic"`
+	Consumed  uint64 `json:"consumed"`
+	Forwarded uint64 `json:"forwarded"`
+}
+
+// SplitRequest is the SplitRequest schema.
+type SplitRequest struct {
+	Into string `json:"into"`
+	// Limits the cloned values to those collected from the named reference feeds.
+	Feeds []string `json:"feeds,omitempty"`
+}
+
+// TestMatchRequest is the TestMatchRequest schema.
+//
+// Key and headers mirror the Kafka message being simulated, which is timestamped now.
+type TestMatchRequest struct {
+	// The message value: a JSON document, or a string holding the raw bytes.
+	Payload json.RawMessage   `json:"payload"`
+	Key     string            `json:"key,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// TestMatchResponse is the TestMatchResponse schema.
+//
+// What the route would do with the simulated message.
+type TestMatchResponse struct {
+	Route   string         `json:"route"`
+	Forward bool           `json:"forward"`
+	Matches []engine.Match `json:"matches"`
+	// Why a matching message would not be forwarded, such as a header filter, a loop, a
+	// higher-priority route, or a duplicate.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// VersionInfo is the VersionInfo schema.
+//
+// The document served at /version.
+type VersionInfo struct {
+	// Release version, or dev for builds without one.
+	Version string `json:"version"`
+	// VCS revision, suffixed -dirty for builds of a modified tree.
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	// Omitted by filter -version, which loads no config.
+	Features *Features `json:"features,omitempty"`
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The request and response types of openapi.gen.go are generated from openapi.json;
+// edit the document and rerun go generate rather than editing them.
+//
+//go:generate go run kafka-bridge/internal/openapigen -o openapi.gen.go openapi.json
+
+// openAPISpec documents every endpoint buildHTTPMux mounts and the JSON types they
+// exchange. TestOpenAPISpecMatchesMux fails when a handler, or a Go type a schema names
+// with x-go-type, changes without the document.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// registerOpenAPI mounts GET /openapi.json.
+func registerOpenAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPISpec)
+	})
+}
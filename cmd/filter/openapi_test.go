@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/schema"
+	"kafka-bridge/pkg/store"
+)
+
+func TestOpenAPISpecMatchesMux(t *testing.T) {
+	b := newBridge(config.Logging{})
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				GoType     string                     `json:"x-go-type"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	// every documented path is served, by a handler registered for that path
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	mux := buildHTTPMux(adminDeps{bridge: b, store: store.NewMatchStore(), debug: true})
+	served := make(map[string]bool)
+	for path, ops := range spec.Paths {
+		concrete := regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(path, "x")
+		for method := range ops {
+			_, pattern := mux.Handler(httptest.NewRequest(strings.ToUpper(method), concrete, nil))
+			if pattern != path && !(strings.HasSuffix(pattern, "/") && pattern != "/" && strings.HasPrefix(path, pattern)) {
+				t.Errorf("%s %s is documented but served by %q", strings.ToUpper(method), path, pattern)
+			}
+			served[pattern] = true
+		}
+	}
+
+	// every registered pattern is documented
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool { return !strings.HasSuffix(fi.Name(), "_test.go") }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkgs["main"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "HandleFunc" {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if pattern, _ := strconv.Unquote(lit.Value); !served[pattern] {
+					t.Errorf("%s registers %s, which openapi.json does not document", fset.Position(lit.Pos()), pattern)
+				}
+			}
+			return true
+		})
+	}
+
+	// schemas naming an existing Go type with x-go-type list exactly its JSON fields; the
+	// rest are generated into openapi.gen.go, which internal/openapigen keeps current
+	types := map[string]reflect.Type{
+		"Drift":         reflect.TypeFor[schema.Drift](),
+		"FieldReport":   reflect.TypeFor[schema.FieldReport](),
+		"Match":         reflect.TypeFor[engine.Match](),
+		"MissingReport": reflect.TypeFor[schema.MissingReport](),
+		"MockMessage":   reflect.TypeFor[wireMessage](),
+		"Origin":        reflect.TypeFor[store.Metadata](),
+		"SchemaReport":  reflect.TypeFor[schema.Report](),
+	}
+	for name, s := range spec.Components.Schemas {
+		if s.GoType == "" {
+			continue
+		}
+		typ, ok := types[name]
+		if !ok {
+			t.Errorf("schema %s names Go type %s, which this test does not check", name, s.GoType)
+			continue
+		}
+		if got := strings.TrimPrefix(typ.String(), "main."); got != s.GoType {
+			t.Errorf("schema %s names Go type %s; this test checks %s", name, s.GoType, got)
+		}
+		var documented []string
+		for prop := range s.Properties {
+			documented = append(documented, prop)
+		}
+		slices.Sort(documented)
+		if fields := jsonFields(typ); !slices.Equal(documented, fields) {
+			t.Errorf("schema %s documents %v; %s encodes %v", name, documented, typ, fields)
+		}
+	}
+}
+
+// jsonFields lists the JSON keys t encodes, sorted, flattening embedded structs.
+func jsonFields(t reflect.Type) []string {
+	var out []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		out = append(out, cmp.Or(name, f.Name))
+	}
+	slices.Sort(out)
+	return out
+}
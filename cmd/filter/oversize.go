@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+// Headers stamped on messages changed by an oversize policy.
+const (
+	// headerTruncated carries the original value length of a truncated message.
+	headerTruncated = "x-bridge-truncated"
+	// headerContentEncoding is gzip on messages compressed to fit.
+	headerContentEncoding = "x-bridge-content-encoding"
+	// headerOversizeBytes carries the size of a message sent to the dead-letter topic.
+	headerOversizeBytes = "x-bridge-oversize-bytes"
+)
+
+// oversizeWriter applies a route's oversize policy to messages larger than its
+// delivery.maxMessageBytes before handing them to the destination. Messages it drops are
+// treated as written, so the source offset still advances.
+type oversizeWriter struct {
+	delivery.MessageWriter
+	route      string
+	limit      int
+	policy     string
+	deadLetter delivery.MessageWriter
+	stats      *routeStats
+	logs       *messageLogger
+}
+
+// newOversizeWriter wraps destination when the route limits message size; otherwise it
+// returns destination unchanged.
+func (b *bridge) newOversizeWriter(route config.Route, destination delivery.MessageWriter, writers *delivery.Pool) delivery.MessageWriter {
+	if route.Delivery.MaxMessageBytes == 0 {
+		return destination
+	}
+	w := &oversizeWriter{
+		MessageWriter: destination,
+		route:         route.DisplayName(),
+		limit:         route.Delivery.MaxMessageBytes,
+		policy:        route.Delivery.Oversize,
+		stats:         b.routeCounters.route(routeKey(route)),
+		logs:          b.messageLogs,
+	}
+	if w.policy == config.OversizeDeadLetter {
+		w.deadLetter = writers.Topic(route.Delivery.DeadLetterTopic)
+	}
+	return w
+}
+
+// WriteMessages writes the messages that fit, or were made to fit, to the destination and
+// those sent aside to the dead-letter topic. A failure of either write is returned as a
+// kafka.WriteErrors over msgs, so Deliver retries only the messages that were not written.
+func (w *oversizeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	out := make([]kafka.Message, 0, len(msgs))
+	var dead []kafka.Message
+	// outAt and deadAt hold the index in msgs of each message of out and dead
+	outAt := make([]int, 0, len(msgs))
+	var deadAt []int
+	for i, msg := range msgs {
+		size := messageSize(msg)
+		if size <= w.limit {
+			out, outAt = append(out, msg), append(outAt, i)
+			continue
+		}
+		w.stats.oversized.Add(1)
+		switch w.policy {
+		case config.OversizeTruncate:
+			if fitted, ok := truncateMessage(msg, w.limit); ok {
+				out, outAt = append(out, fitted), append(outAt, i)
+				continue
+			}
+		case config.OversizeCompress:
+			if fitted, ok := compressMessage(msg, w.limit); ok {
+				out, outAt = append(out, fitted), append(outAt, i)
+				continue
+			}
+		case config.OversizeDeadLetter:
+			msg.Headers = append(append([]kafka.Header(nil), msg.Headers...), kafka.Header{Key: headerOversizeBytes, Value: []byte(strconv.Itoa(size))})
+			dead, deadAt = append(dead, msg), append(deadAt, i)
+			continue
+		}
+		w.logs.printf(w.route, "route %s: offset %d dropped: %d bytes exceeds maxMessageBytes %d", w.route, msg.Offset, size, w.limit)
+	}
+	var errs kafka.WriteErrors
+	for _, part := range []struct {
+		writer delivery.MessageWriter
+		msgs   []kafka.Message
+		at     []int
+	}{{w.MessageWriter, out, outAt}, {w.deadLetter, dead, deadAt}} {
+		if len(part.msgs) == 0 {
+			continue
+		}
+		err := part.writer.WriteMessages(ctx, part.msgs...)
+		if err == nil {
+			continue
+		}
+		if len(part.msgs) == len(msgs) {
+			// every message went to this writer, in order
+			return err
+		}
+		if errs == nil {
+			errs = make(kafka.WriteErrors, len(msgs))
+		}
+		for j, i := range part.at {
+			errs[i] = delivery.MessageError(err, len(part.msgs), j)
+		}
+	}
+	if errs.Count() == 0 {
+		return nil
+	}
+	return errs
+}
+
+// messageSize approximates the bytes a message occupies in a record batch: its key,
+// value, and headers, without the per-record framing.
+func messageSize(msg kafka.Message) int {
+	size := len(msg.Key) + len(msg.Value)
+	for _, h := range msg.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
+
+// truncateMessage cuts the value so the message fits limit, recording the original value
+// length in a header. It fails when the key and headers alone exceed limit.
+func truncateMessage(msg kafka.Message, limit int) (kafka.Message, bool) {
+	marker := kafka.Header{Key: headerTruncated, Value: []byte(strconv.Itoa(len(msg.Value)))}
+	headers := append(append([]kafka.Header(nil), msg.Headers...), marker)
+	keep := limit - (messageSize(msg) - len(msg.Value)) - len(marker.Key) - len(marker.Value)
+	if keep < 0 {
+		return msg, false
+	}
+	msg.Value = msg.Value[:keep]
+	msg.Headers = headers
+	return msg, true
+}
+
+// compressMessage gzips the value, marking it with a content-encoding header, and
+// reports whether the result fits limit.
+func compressMessage(msg kafka.Message, limit int) (kafka.Message, bool) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(msg.Value); err != nil {
+		return msg, false
+	}
+	if err := zw.Close(); err != nil {
+		return msg, false
+	}
+	msg.Value = buf.Bytes()
+	msg.Headers = append(append([]kafka.Header(nil), msg.Headers...), kafka.Header{Key: headerContentEncoding, Value: []byte("gzip")})
+	return msg, messageSize(msg) <= limit
+}
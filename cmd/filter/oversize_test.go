@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+func TestOversizeWriterPolicies(t *testing.T) {
+	b := newBridge(config.Logging{})
+	big := kafka.Message{Key: []byte("k"), Value: bytes.Repeat([]byte("a"), 500), Headers: []kafka.Header{{Key: "h", Value: []byte("v")}}}
+	small := kafka.Message{Key: []byte("k"), Value: []byte("ok")}
+	newWriter := func(policy string) (*oversizeWriter, *recordingWriter, *recordingWriter) {
+		dest, dead := &recordingWriter{}, &recordingWriter{}
+		return &oversizeWriter{MessageWriter: dest, route: "route-a", limit: 100, policy: policy, deadLetter: dead, stats: &routeStats{}, logs: b.messageLogs}, dest, dead
+	}
+
+	w, dest, _ := newWriter(config.OversizeDrop)
+	if err := w.WriteMessages(context.Background(), big, small); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if len(dest.written) != 1 || string(dest.written[0].Value) != "ok" || w.stats.oversized.Load() != 1 {
+		t.Fatalf("drop: expected only the small message, got %d (oversized %d)", len(dest.written), w.stats.oversized.Load())
+	}
+
+	w, dest, _ = newWriter(config.OversizeTruncate)
+	if err := w.WriteMessages(context.Background(), big); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if len(dest.written) != 1 || messageSize(dest.written[0]) != 100 || headerValue(dest.written[0].Headers, headerTruncated) != "500" {
+		t.Fatalf("truncate: unexpected %+v", dest.written)
+	}
+	if len(big.Headers) != 1 {
+		t.Fatalf("truncate modified the caller's headers: %v", big.Headers)
+	}
+
+	w, dest, _ = newWriter(config.OversizeCompress)
+	if err := w.WriteMessages(context.Background(), big); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if len(dest.written) != 1 || headerValue(dest.written[0].Headers, headerContentEncoding) != "gzip" {
+		t.Fatalf("compress: unexpected %+v", dest.written)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(dest.written[0].Value))
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if raw, _ := io.ReadAll(zr); !bytes.Equal(raw, big.Value) {
+		t.Fatalf("compress: value does not round-trip")
+	}
+
+	w, dest, dead := newWriter(config.OversizeDeadLetter)
+	if err := w.WriteMessages(context.Background(), big, small); err != nil {
+		t.Fatalf("deadLetter: %v", err)
+	}
+	if len(dest.written) != 1 || len(dead.written) != 1 || !bytes.Equal(dead.written[0].Value, big.Value) || headerValue(dead.written[0].Headers, headerOversizeBytes) != "503" {
+		t.Fatalf("deadLetter: unexpected dest %d dead %+v", len(dest.written), dead.written)
+	}
+}
+
+func TestOversizeWriterPartialFailures(t *testing.T) {
+	b := newBridge(config.Logging{})
+	big := func(key string) kafka.Message {
+		return kafka.Message{Key: []byte(key), Value: bytes.Repeat([]byte("a"), 500)}
+	}
+	small := func(key string) kafka.Message { return kafka.Message{Key: []byte(key), Value: []byte("ok")} }
+	notLeader := errors.New("not leader")
+
+	for _, tc := range []struct {
+		name string
+		// destination and deadLetter fail the first write of a message whose key they list
+		destination, deadLetter []string
+	}{
+		{name: "destination fails part of its batch", destination: []string{"s2"}},
+		{name: "dead-letter topic fails", deadLetter: []string{"b1", "b3"}},
+		{name: "both fail", destination: []string{"s0"}, deadLetter: []string{"b3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var written []string
+			failing := func(keys []string) writerFunc {
+				failed := make(map[string]bool)
+				return func(_ context.Context, msgs ...kafka.Message) error {
+					errs := make(kafka.WriteErrors, len(msgs))
+					for i, msg := range msgs {
+						if key := string(msg.Key); slices.Contains(keys, key) && !failed[key] {
+							failed[key] = true
+							errs[i] = notLeader
+							continue
+						}
+						written = append(written, string(msg.Key))
+					}
+					if errs.Count() == 0 {
+						return nil
+					}
+					return errs
+				}
+			}
+			w := &oversizeWriter{MessageWriter: failing(tc.destination), route: "route-a", limit: 100, policy: config.OversizeDeadLetter,
+				deadLetter: failing(tc.deadLetter), stats: &routeStats{}, logs: b.messageLogs}
+			err := delivery.Deliver(context.Background(), w, delivery.RetryPolicy{InitialBackoff: time.Millisecond}, small("s0"), big("b1"), small("s2"), big("b3"))
+			if err != nil {
+				t.Fatalf("Deliver: %v", err)
+			}
+			slices.Sort(written)
+			if want := []string{"b1", "b3", "s0", "s2"}; !slices.Equal(written, want) {
+				t.Fatalf("written %v, want each message once: %v", written, want)
+			}
+		})
+	}
+
+	w := &oversizeWriter{MessageWriter: writerFunc(func(context.Context, ...kafka.Message) error { return notLeader }), route: "route-a", limit: 100,
+		policy: config.OversizeDeadLetter, deadLetter: &recordingWriter{}, stats: &routeStats{}, logs: b.messageLogs}
+	var errs kafka.WriteErrors
+	if err := w.WriteMessages(context.Background(), small("s0"), big("b1"), small("s2")); !errors.As(err, &errs) || len(errs) != 3 ||
+		!errors.Is(errs[0], notLeader) || errs[1] != nil || !errors.Is(errs[2], notLeader) {
+		t.Fatalf("failed destination write returned %v, want write errors of s0 and s2 only", err)
+	}
+}
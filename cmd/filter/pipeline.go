@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"kafka-bridge/internal/config"
+)
+
+type startRegistry struct {
+	mu      sync.Mutex
+	started map[string]chan struct{}
+}
+
+func (r *startRegistry) channel(routeID string) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.started[routeID]
+	if !ok {
+		ch = make(chan struct{})
+		r.started[routeID] = ch
+	}
+	return ch
+}
+
+// start records that routeID is streaming.
+func (r *startRegistry) start(routeID string) {
+	ch := r.channel(routeID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// awaitUpstreams holds a route of a pipeline back until every route feeding it streams,
+// so its reference feeds are written before it matches against them.
+func (b *bridge) awaitUpstreams(ctx context.Context, cfg *config.Config, route config.Route) error {
+	upstreams := cfg.PipelineUpstreams(route)
+	if len(upstreams) == 0 {
+		return nil
+	}
+	names := make([]string, len(upstreams))
+	for i, up := range upstreams {
+		names[i] = up.DisplayName()
+	}
+	log.Printf("route %s: waiting for upstream route(s) %s to start", route.DisplayName(), strings.Join(names, ", "))
+	for _, up := range upstreams {
+		select {
+		case <-b.routeStarts.channel(routeKey(up)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	log.Printf("event: route %s: upstream route(s) %s started", route.DisplayName(), strings.Join(names, ", "))
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestAwaitUpstreams(t *testing.T) {
+	b := newBridge(config.Logging{})
+	upstream := config.Route{Name: "customers-active", SourceTopic: "customers", DestinationTopic: "customers.active"}
+	downstream := config.Route{Name: "orders-active", SourceTopic: "orders", DestinationTopic: "orders.active",
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "customers.active", MatchFields: []string{"id"}}}}
+	cfg := &config.Config{Routes: []config.Route{upstream, downstream},
+		Pipelines: []config.Pipeline{{Name: "active", Routes: []string{"orders-active", "customers-active"}}}}
+	ctx := context.Background()
+	if err := b.awaitUpstreams(ctx, cfg, upstream); err != nil {
+		t.Fatalf("the first route of a pipeline should not wait: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.awaitUpstreams(ctx, cfg, downstream) }()
+	select {
+	case err := <-done:
+		t.Fatalf("expected the downstream route to wait, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	b.routeStarts.start(routeKey(upstream))
+	b.routeStarts.start(routeKey(upstream))
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("awaitUpstreams: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the downstream route to start after its upstream")
+	}
+}
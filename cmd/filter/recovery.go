@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"sync"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// recoveryGate is open until held; a nil restored channel means nothing waits.
+type recoveryGate struct {
+	mu       sync.Mutex
+	restored chan struct{}
+	failures int
+	err      error
+}
+
+// bridgeReadiness reports whether the cache is restored, every route has finished its
+// reference warm-up, and no route worker is waiting to be restarted.
+func (b *bridge) bridgeReadiness() Readiness {
+	r := b.stateRecovery.status()
+	if r.WarmingUp = b.routeWarmups.snapshot(); len(r.WarmingUp) > 0 {
+		r.Ready = false
+	}
+	if r.Restarting = b.routeRestarts.snapshot(); len(r.Restarting) > 0 {
+		r.Ready = false
+	}
+	return r
+}
+
+// hold makes the gate wait for done.
+func (g *recoveryGate) hold() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.restored = make(chan struct{})
+}
+
+// done marks the cache restored and releases everything waiting for it.
+func (g *recoveryGate) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.restored != nil {
+		close(g.restored)
+		g.restored = nil
+	}
+}
+
+func (g *recoveryGate) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures++
+	g.err = err
+}
+
+func (g *recoveryGate) ready() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.restored == nil
+}
+
+// wait blocks until the cache is restored or ctx is done.
+func (g *recoveryGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	restored := g.restored
+	g.mu.Unlock()
+	if restored == nil {
+		return nil
+	}
+	select {
+	case <-restored:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *recoveryGate) status() Readiness {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := Readiness{Ready: g.restored == nil, FailedRestores: g.failures}
+	if !r.Ready && g.err != nil {
+		r.Error = g.err.Error()
+	}
+	return r
+}
+
+// Bounds of the backoff between restore attempts of a required storage.
+const (
+	restoreRetryInitial = time.Second
+	restoreRetryMax     = 30 * time.Second
+)
+
+// restoreUntilDone runs restore until it succeeds, backing off between failed attempts,
+// which are recorded on g. It returns an error only when ctx is done first.
+func restoreUntilDone(ctx context.Context, g *recoveryGate, restore func(context.Context) error) error {
+	backoff := restoreRetryInitial
+	for {
+		err := restore(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		g.fail(err)
+		log.Printf("warn: cache not restored, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, restoreRetryMax)
+	}
+}
+
+// restoreState loads the cache from the configured storage: the state topic, the sqlite
+// database, or the snapshot and wal of the file backend, then the files of the routes
+// with storage of their own. state and db are the open kafka and sqlite backends, nil
+// for the others. Without storage.required an unreadable snapshot is logged and the
+// cache starts empty; with it every failure is returned, so the restore is retried.
+func restoreState(ctx context.Context, cfg *config.Config, matchStore *store.MatchStore, state *kafkapkg.StateTopic, db *store.SQLite) error {
+	switch cfg.Storage.Backend {
+	case config.StorageBackendKafka:
+		restored, err := state.Restore(ctx, matchStore)
+		if err != nil {
+			return fmt.Errorf("restore state from topic %s: %w", cfg.Storage.Topic, err)
+		}
+		log.Printf("restored %d fingerprints from state topic %s", restored, cfg.Storage.Topic)
+	case config.StorageBackendSQLite:
+		restored, err := db.Restore(ctx, matchStore)
+		if err != nil {
+			return fmt.Errorf("restore state from %s: %w", cfg.Storage.Path, err)
+		}
+		log.Printf("restored %d fingerprints from %s", restored, cfg.Storage.Path)
+	default:
+		if cfg.Storage.Path == "" {
+			break
+		}
+		if err := loadSnapshot(cfg.Storage.Path, matchStore); err != nil {
+			// a snapshot not written yet is a fresh start, not a failed restore
+			if cfg.Storage.Required && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("load snapshot %s: %w", cfg.Storage.Path, err)
+			}
+			log.Printf("warn: failed to load snapshot: %v", err)
+		}
+		if cfg.Storage.WAL {
+			replayed, err := store.ReplayWAL(cfg.Storage.WALPath(), matchStore)
+			if err != nil {
+				return fmt.Errorf("replay wal %s: %w", cfg.Storage.WALPath(), err)
+			}
+			log.Printf("replayed %d cache change(s) from %s", replayed, cfg.Storage.WALPath())
+		}
+	}
+	return restoreRouteStorages(cfg, matchStore, cfg.Storage.Required)
+}
+
+// startStorage starts persisting the restored cache: it observes cache changes for the
+// backend, starts its writers, compacts the restored values, and rebuilds the matchers'
+// indexes of reference record keys from them. wg tracks the writers.
+func startStorage(ctx context.Context, cfg *config.Config, matchStore *store.MatchStore, matchers map[string]*engine.Matcher, state *kafkapkg.StateTopic, db *store.SQLite, wg *sync.WaitGroup) error {
+	shared := sharedStorageRoutes(cfg)
+	switch cfg.Storage.Backend {
+	case config.StorageBackendKafka:
+		matchStore.SetObserver(routeObserver(state.Record, shared))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := state.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("state topic writer stopped: %v", err)
+			}
+		}()
+		if cfg.Storage.Replicate {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := state.Follow(ctx, matchStore); err != nil && !errors.Is(err, context.Canceled) {
+					log.Printf("state topic replication stopped: %v", err)
+				}
+			}()
+		}
+	case config.StorageBackendSQLite:
+		matchStore.SetObserver(routeObserver(db.Record, shared))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("sqlite writer stopped: %v", err)
+			}
+		}()
+	default:
+		if cfg.Storage.Path == "" {
+			break
+		}
+		var wal *store.WAL
+		if cfg.Storage.WAL {
+			// left open until exit: the final snapshot save still checkpoints it
+			var err error
+			if wal, err = store.OpenWAL(cfg.Storage.WALPath()); err != nil {
+				return fmt.Errorf("open wal: %w", err)
+			}
+			matchStore.SetObserver(routeObserver(wal.Record, shared))
+		}
+		opts := store.SaveOptions{Gzip: cfg.Storage.Compression == config.StorageCompressionGzip}
+		startSnapshotWriter(ctx, cfg.Storage.Path, cfg.Storage.FlushInterval, opts, matchStore, wal, shared)
+	}
+	if err := startRouteStorages(ctx, cfg, matchStore); err != nil {
+		return fmt.Errorf("route storage: %w", err)
+	}
+	compactMatchers(matchers)
+	for routeID, m := range matchers {
+		if n := m.RestoreKeys(); n > 0 {
+			log.Printf("restored %d reference record key(s) for %s", n, routeID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRequiredStorageHoldsReadiness(t *testing.T) {
+	b := newBridge(config.Logging{})
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Storage: config.Storage{Backend: config.StorageBackendFile, Path: path, Required: true}}
+	matchStore := store.NewMatchStore()
+	if err := restoreState(context.Background(), cfg, matchStore, nil, nil); err == nil {
+		t.Fatal("expected an unreadable snapshot to fail a required restore")
+	}
+	cfg.Storage.Required = false
+	if err := restoreState(context.Background(), cfg, matchStore, nil, nil); err != nil {
+		t.Fatalf("expected an optional restore to start empty, got %v", err)
+	}
+
+	gate := &recoveryGate{}
+	defer func(prev *recoveryGate) { b.stateRecovery = prev }(b.stateRecovery)
+	b.stateRecovery = gate
+	gate.hold()
+	gate.fail(errors.New("snapshot unreadable"))
+	mux := buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{}, store: matchStore})
+	get := func() (int, Readiness) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body Readiness
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode readiness: %v", err)
+		}
+		return rec.Code, body
+	}
+	if code, body := get(); code != http.StatusServiceUnavailable || body.Ready || body.FailedRestores != 1 || body.Error != "snapshot unreadable" {
+		t.Fatalf("expected not ready, got %d %+v", code, body)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cache/clear", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected mutations refused while restoring, got %d", rec.Code)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := gate.wait(ctx); err == nil {
+		t.Fatal("expected wait to block while the gate is held")
+	}
+
+	gate.done()
+	if err := gate.wait(context.Background()); err != nil {
+		t.Fatalf("wait after done: %v", err)
+	}
+	if code, body := get(); code != http.StatusOK || !body.Ready || body.Error != "" {
+		t.Fatalf("expected ready, got %d %+v", code, body)
+	}
+}
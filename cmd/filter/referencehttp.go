@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// newHTTPPoller returns the poller of route's referenceHTTP endpoint.
+func newHTTPPoller(route config.Route, matcher *engine.Matcher, matchStore *store.MatchStore) (*referencePoller, error) {
+	src := route.ReferenceHTTP
+	path, err := engine.ParseJSONPath(src.ValuesPath)
+	if err != nil {
+		return nil, fmt.Errorf("referenceHTTP: %w", err)
+	}
+	tlsConfig, err := src.TLSConfigObject()
+	if err != nil {
+		return nil, fmt.Errorf("referenceHTTP tls: %w", err)
+	}
+	client := &http.Client{Timeout: src.Timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &referencePoller{
+		route:    route,
+		name:     "referenceHTTP",
+		source:   store.SourceReferenceHTTP,
+		interval: src.PollInterval,
+		matcher:  matcher,
+		store:    matchStore,
+		fetch: func(ctx context.Context) ([]string, error) {
+			return fetchReferenceHTTP(ctx, client, src, path)
+		},
+	}, nil
+}
+
+// fetchReferenceHTTP requests the endpoint and returns the values at valuesPath.
+func fetchReferenceHTTP(ctx context.Context, client *http.Client, src *config.ReferenceHTTP, path engine.JSONPath) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range src.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("GET %s: %s: %s", redactedURL(src.URL, "referenceHTTP"), resp.Status, body)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return path.Strings(doc), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// referencePoller keeps a route's cache in step with an external reference source,
+// such as its referenceHTTP endpoint or referenceSQL query.
+type referencePoller struct {
+	route config.Route
+	// name labels the source in logs, e.g. referenceHTTP.
+	name string
+	// source is the store.Metadata source recorded with the values the poller loads.
+	source   string
+	interval time.Duration
+	matcher  *engine.Matcher
+	store    *store.MatchStore
+	fetch    func(ctx context.Context) ([]string, error)
+	// budget pauses the refreshes while the route sheds under pause-references.
+	budget *budgetMonitor
+}
+
+// newReferencePollers returns the pollers of the external reference sources route
+// configures, refreshing under budget.
+func newReferencePollers(route config.Route, matcher *engine.Matcher, matchStore *store.MatchStore, budget *budgetMonitor) ([]*referencePoller, error) {
+	var pollers []*referencePoller
+	if route.ReferenceHTTP != nil {
+		p, err := newHTTPPoller(route, matcher, matchStore)
+		if err != nil {
+			return nil, err
+		}
+		pollers = append(pollers, p)
+	}
+	if route.ReferenceSQL != nil {
+		p, err := newSQLPoller(route, matcher, matchStore)
+		if err != nil {
+			return nil, err
+		}
+		pollers = append(pollers, p)
+	}
+	for _, p := range pollers {
+		p.budget = budget
+	}
+	return pollers, nil
+}
+
+// run hydrates the route and then refreshes it every interval until ctx is done.
+func (p *referencePoller) run(ctx context.Context, broadcast func(context.Context, kafkapkg.Command)) {
+	p.hydrate(ctx, broadcast)
+	p.refresh(ctx, broadcast)
+}
+
+// hydrate polls once, logging rather than returning a failure: the values already cached
+// stay in place until a later poll succeeds.
+func (p *referencePoller) hydrate(ctx context.Context, broadcast func(context.Context, kafkapkg.Command)) {
+	added, removed, err := p.poll(ctx, broadcast)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("warn: %s %s: %v", p.name, p.route.DisplayName(), err)
+		}
+		return
+	}
+	log.Printf("%s %s: added=%d removed=%d (count=%d)", p.name, p.route.DisplayName(), added, removed, p.matcher.Size())
+}
+
+// refresh polls every interval until ctx is done.
+func (p *referencePoller) refresh(ctx context.Context, broadcast func(context.Context, kafkapkg.Command)) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.budget.awaitReferences(ctx, routeKey(p.route)) != nil {
+				return
+			}
+			p.hydrate(ctx, broadcast)
+		}
+	}
+}
+
+// poll fetches the source's values, adds the new ones, and removes values an earlier
+// poll loaded that the source no longer lists. Values cached from other sources are
+// never removed. When broadcast is set, the changes are also sent to peer replicas.
+func (p *referencePoller) poll(ctx context.Context, broadcast func(context.Context, kafkapkg.Command)) (int, int, error) {
+	values, err := p.fetch(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	routeID := routeKey(p.route)
+	// wanted maps the fingerprint of each value to the value, which differ on routes that
+	// hash their values
+	wanted := make(map[string]string, len(values))
+	for _, v := range values {
+		wanted[p.matcher.Fingerprint(v)] = v
+	}
+	polled := make(map[string]struct{})
+	for fingerprint, e := range p.store.Entries(routeID) {
+		if e.Meta.Source != p.source {
+			continue
+		}
+		if e.Canonical != "" {
+			fingerprint = e.Canonical
+		}
+		polled[fingerprint] = struct{}{}
+	}
+	var add, remove []string
+	for fingerprint, v := range wanted {
+		if _, ok := polled[fingerprint]; !ok && !p.store.Contains(routeID, fingerprint) {
+			add = append(add, v)
+		}
+	}
+	for v := range polled {
+		if _, ok := wanted[v]; !ok {
+			remove = append(remove, v)
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	meta := store.Metadata{Source: p.source, AddedAt: time.Now()}
+	if len(remove) > 0 {
+		p.matcher.RemoveValues(remove)
+	}
+	if len(add) > 0 {
+		p.matcher.AddValuesWithMeta(add, meta)
+	}
+	if broadcast != nil {
+		for start := 0; start < len(remove); start += importChunk {
+			broadcast(ctx, kafkapkg.Command{Op: kafkapkg.CommandDelete, Route: routeID, Values: remove[start:min(start+importChunk, len(remove))]})
+		}
+		for start := 0; start < len(add); start += importChunk {
+			broadcast(ctx, kafkapkg.Command{Op: kafkapkg.CommandInject, Route: routeID, Values: add[start:min(start+importChunk, len(add))], Meta: &meta})
+		}
+	}
+	return len(add), len(remove), nil
+}
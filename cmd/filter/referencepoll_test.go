@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestReferencePollerSyncsValues(t *testing.T) {
+	var body atomic.Value
+	body.Store(`{"items":[{"id":"abc"},{"id":"def"}]}`)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, body.Load().(string))
+	}))
+	defer api.Close()
+
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-rest", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	matchStore.AddWithMeta("route-rest", "kafka-only", store.Metadata{Source: store.SourceKafka})
+	route := config.Route{Name: "route-rest", ReferenceHTTP: &config.ReferenceHTTP{URL: api.URL, ValuesPath: "$.items[*].id", Headers: map[string]string{"Authorization": "Bearer token"}, Timeout: time.Second}}
+	poller, err := newHTTPPoller(route, matcher, matchStore)
+	if err != nil {
+		t.Fatalf("newHTTPPoller: %v", err)
+	}
+	var sent []kafkapkg.Command
+	broadcast := func(_ context.Context, cmd kafkapkg.Command) { sent = append(sent, cmd) }
+	if added, removed, err := poller.poll(context.Background(), broadcast); err != nil || added != 2 || removed != 0 {
+		t.Fatalf("first poll = %d, %d, %v", added, removed, err)
+	}
+	if meta, ok := matchStore.Lookup("route-rest", "abc"); !ok || meta.Source != store.SourceReferenceHTTP {
+		t.Fatalf("abc not loaded with referenceHTTP provenance: %+v, %v", meta, ok)
+	}
+
+	body.Store(`{"items":[{"id":"def"},{"id":"ghi"}]}`)
+	if added, removed, err := poller.poll(context.Background(), broadcast); err != nil || added != 1 || removed != 1 {
+		t.Fatalf("second poll = %d, %d, %v", added, removed, err)
+	}
+	if matchStore.Contains("route-rest", "abc") || !matchStore.Contains("route-rest", "ghi") || !matchStore.Contains("route-rest", "kafka-only") {
+		t.Fatalf("unexpected values after refresh: %v", matchStore.CanonicalSnapshot()["route-rest"])
+	}
+	if len(sent) != 3 || sent[1].Op != kafkapkg.CommandDelete || sent[1].Values[0] != "abc" {
+		t.Fatalf("unexpected broadcasts: %+v", sent)
+	}
+
+	route.ReferenceHTTP.Headers = nil
+	if _, _, err := poller.poll(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected failed poll, got %v", err)
+	}
+	if !matchStore.Contains("route-rest", "ghi") {
+		t.Fatal("a failed poll should keep the values already loaded")
+	}
+}
+
+func TestReferencePollerHashedValues(t *testing.T) {
+	var body atomic.Value
+	body.Store(`["abc","def"]`)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body.Load().(string))
+	}))
+	defer api.Close()
+
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-rest", nil, matchStore, engine.WithValueHash(true, nil))
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	route := config.Route{Name: "route-rest", ReferenceHTTP: &config.ReferenceHTTP{URL: api.URL, ValuesPath: "$[*]", Timeout: time.Second}}
+	poller, err := newHTTPPoller(route, matcher, matchStore)
+	if err != nil {
+		t.Fatalf("newHTTPPoller: %v", err)
+	}
+	if added, removed, err := poller.poll(context.Background(), nil); err != nil || added != 2 || removed != 0 {
+		t.Fatalf("first poll = %d, %d, %v", added, removed, err)
+	}
+	// unchanged values are recognised by their hashes rather than added again
+	body.Store(`["def","ghi"]`)
+	if added, removed, err := poller.poll(context.Background(), nil); err != nil || added != 1 || removed != 1 {
+		t.Fatalf("second poll = %d, %d, %v", added, removed, err)
+	}
+	if matchStore.Contains("route-rest", matcher.Fingerprint("abc")) || !matchStore.Contains("route-rest", matcher.Fingerprint("ghi")) || matchStore.Size("route-rest") != 2 {
+		t.Fatalf("unexpected values after refresh: %v", matchStore.CanonicalSnapshot()["route-rest"])
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// sqlSource runs a route's referenceSQL query. The database is opened on first use and
+// reopened when a DSN read from a secrets provider changes.
+type sqlSource struct {
+	src *config.ReferenceSQL
+
+	mu  sync.Mutex
+	db  *sql.DB
+	dsn string
+}
+
+// newSQLPoller returns the poller of route's referenceSQL query.
+func newSQLPoller(route config.Route, matcher *engine.Matcher, matchStore *store.MatchStore) (*referencePoller, error) {
+	src := route.ReferenceSQL
+	if !registeredSQLDriver(src.Driver) {
+		return nil, fmt.Errorf("referenceSQL: driver %q is not linked into this binary (have %s)", src.Driver, strings.Join(sql.Drivers(), ", "))
+	}
+	q := &sqlSource{src: src}
+	return &referencePoller{
+		route:    route,
+		name:     "referenceSQL",
+		source:   store.SourceReferenceSQL,
+		interval: src.RefreshInterval,
+		matcher:  matcher,
+		store:    matchStore,
+		fetch:    q.fetch,
+	}, nil
+}
+
+func registeredSQLDriver(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch runs the query and returns the non-NULL values of its column.
+func (q *sqlSource) fetch(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.src.Timeout)
+	defer cancel()
+	db, err := q.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, q.src.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query columns: %w", err)
+	}
+	col := 0
+	if q.src.Column != "" {
+		col = -1
+		for i, name := range columns {
+			if strings.EqualFold(name, q.src.Column) {
+				col = i
+			}
+		}
+		if col < 0 {
+			return nil, fmt.Errorf("query returns no column %q (have %s)", q.src.Column, strings.Join(columns, ", "))
+		}
+	}
+	dest := make([]any, len(columns))
+	for i := range dest {
+		dest[i] = new(sql.RawBytes)
+	}
+	var value sql.NullString
+	dest[col] = &value
+	var values []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		if value.Valid {
+			values = append(values, value.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read rows: %w", err)
+	}
+	return values, nil
+}
+
+func (q *sqlSource) open(ctx context.Context) (*sql.DB, error) {
+	dsn, err := q.src.ResolveDSN(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dsn: %w", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.db != nil && dsn == q.dsn {
+		return q.db, nil
+	}
+	db, err := sql.Open(q.src.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if q.db != nil {
+		q.db.Close()
+	}
+	q.db, q.dsn = db, dsn
+	return db, nil
+}
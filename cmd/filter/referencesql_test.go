@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestReferenceSQLPoller(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "master.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE customers (id TEXT, active INTEGER, name TEXT); INSERT INTO customers VALUES ('abc', 1, 'A'), ('def', 1, NULL), (NULL, 1, 'none'), ('old', 0, 'O')`); err != nil {
+		t.Fatal(err)
+	}
+
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-sql", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"id"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	route := config.Route{Name: "route-sql", ReferenceSQL: &config.ReferenceSQL{Driver: "sqlite", DSN: dsn, Query: "SELECT name, id FROM customers WHERE active = 1", Column: "ID", Timeout: time.Second}}
+	poller, err := newSQLPoller(route, matcher, matchStore)
+	if err != nil {
+		t.Fatalf("newSQLPoller: %v", err)
+	}
+	if added, removed, err := poller.poll(context.Background(), nil); err != nil || added != 2 || removed != 0 {
+		t.Fatalf("first poll = %d, %d, %v", added, removed, err)
+	}
+	if _, err := db.Exec(`UPDATE customers SET active = 0 WHERE id = 'abc'`); err != nil {
+		t.Fatal(err)
+	}
+	if added, removed, err := poller.poll(context.Background(), nil); err != nil || added != 0 || removed != 1 {
+		t.Fatalf("second poll = %d, %d, %v", added, removed, err)
+	}
+	if meta, ok := matchStore.Lookup("route-sql", "def"); !ok || meta.Source != store.SourceReferenceSQL || matchStore.Contains("route-sql", "abc") {
+		t.Fatalf("unexpected cache after refresh: %v", matchStore.CanonicalSnapshot()["route-sql"])
+	}
+
+	route.ReferenceSQL.Column = "missing"
+	if _, _, err := poller.poll(context.Background(), nil); err == nil || !strings.Contains(err.Error(), `no column "missing"`) {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+	route.ReferenceSQL.Driver = "oracle"
+	if _, err := newSQLPoller(route, matcher, matchStore); err == nil {
+		t.Fatal("expected error for an unlinked driver")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/store"
+)
+
+// referenceStateRetry is how long a failed reference state snapshot waits to be retried.
+var referenceStateRetry = 5 * time.Second
+
+// startReferenceState publishes the cache to the reference state topic. In changes mode
+// it observes every cache change, then publishes one snapshot, retried until it reads the
+// topic; in snapshot mode it publishes a snapshot every interval. wg tracks the publisher.
+func startReferenceState(ctx context.Context, rs config.ReferenceState, matchStore *store.MatchStore, publisher *kafkapkg.StateTopic, wg *sync.WaitGroup) {
+	if rs.Mode == config.ReferenceStateChanges {
+		matchStore.AddObserver(publisher.Record)
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := publisher.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("reference state writer stopped: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			wait := rs.Interval
+			n, err := publisher.PublishSnapshot(ctx, matchStore)
+			if err != nil {
+				log.Printf("warn: reference state: snapshot to %s failed, retrying: %v", rs.Topic, err)
+				wait = referenceStateRetry
+			} else {
+				log.Printf("event: published a snapshot of %d reference value(s) to %s", n, rs.Topic)
+				if rs.Mode == config.ReferenceStateChanges {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+// Headers explaining why a message written to a rejectedTopic was not forwarded.
+const (
+	// headerRejectedReason is header-filter, no-match, preempted, duplicate, or no-key.
+	headerRejectedReason = "x-bridge-rejected-reason"
+	headerRejectedDetail = "x-bridge-rejected-detail"
+)
+
+// Reasons reported in headerRejectedReason.
+const (
+	rejectedNoMatch   = "no-match"
+	rejectedPreempted = "preempted"
+	rejectedDuplicate = "duplicate"
+	rejectedNoKey     = "no-key"
+	// rejectedHeaderFilter messages failed the route's sourceHeaderFilters.
+	rejectedHeaderFilter = "header-filter"
+)
+
+type rejectionRegistry struct {
+	mu       sync.RWMutex
+	samplers map[string]*rejectionSampler
+}
+
+func (r *rejectionRegistry) set(routeID string, s *rejectionSampler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samplers[routeID] = s
+}
+
+func (r *rejectionRegistry) get(routeID string) *rejectionSampler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.samplers[routeID]
+}
+
+// rejectionSampler writes every n-th rejected message of a route, n being set by the
+// route's rejectedSampleRatio, so the sample is spread evenly over the stream.
+type rejectionSampler struct {
+	route  config.Route
+	writer delivery.MessageWriter
+	policy delivery.RetryPolicy
+	// noMatch is the detail of a message that matched no cached value.
+	noMatch string
+	seen    atomic.Uint64
+}
+
+func newRejectionSampler(route config.Route, w delivery.MessageWriter, policy delivery.RetryPolicy) *rejectionSampler {
+	var fields []string
+	for _, feed := range route.ReferenceFeeds {
+		for _, f := range feed.MatchFields {
+			if !slices.ContainsFunc(fields, func(seen string) bool { return strings.EqualFold(seen, f) }) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	noMatch := "no payload value is cached"
+	if len(fields) > 0 {
+		noMatch = "no value of " + strings.Join(fields, ", ") + " is cached"
+	}
+	return &rejectionSampler{route: route, writer: w, policy: policy, noMatch: noMatch}
+}
+
+// sampled counts one rejected message and reports whether it belongs to the sample.
+func (s *rejectionSampler) sampled() bool {
+	n := s.seen.Add(1)
+	ratio := s.route.RejectedSampleRatio
+	return uint64(float64(n)*ratio) != uint64(float64(n-1)*ratio)
+}
+
+// rejectMessage writes msg to the route's rejectedTopic when it has one and msg is
+// sampled. The rejection stream is for offline analysis, so a failed write is logged
+// rather than holding up the route.
+func (b *bridge) rejectMessage(ctx context.Context, route config.Route, msg kafka.Message, reason, detail string) {
+	routeID := routeKey(route)
+	s := b.routeRejections.get(routeID)
+	if s == nil || !s.sampled() {
+		return
+	}
+	if reason == rejectedNoMatch && detail == "" {
+		detail = s.noMatch
+	}
+	out := cloneMessage(msg)
+	out.Headers = append(out.Headers,
+		kafka.Header{Key: headerRoute, Value: []byte(routeID)},
+		kafka.Header{Key: headerRejectedReason, Value: []byte(reason)},
+		kafka.Header{Key: headerRejectedDetail, Value: []byte(detail)},
+		kafka.Header{Key: headerSourceOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	if err := delivery.Deliver(ctx, s.writer, s.policy, out); err != nil {
+		b.messageLogs.printf(routeID, "warn: route %s: rejected offset %d not written to %s: %v", route.DisplayName(), msg.Offset, route.RejectedTopic, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRejectedTopicSamplesSkippedMessages(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-rejected", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher.AddValues([]string{"hit"})
+	route := config.Route{Name: "route-rejected", DestinationTopic: "dest", RejectedTopic: "rejected", RejectedSampleRatio: 0.5,
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "ref", MatchFields: []string{"fieldA"}}}}
+	rejected := &recordingWriter{}
+	b.routeRejections.set("route-rejected", newRejectionSampler(route, rejected, delivery.RetryPolicy{}))
+	defer b.routeRejections.set("route-rejected", nil)
+
+	dest := &recordingWriter{}
+	for i, value := range []string{`{"x":"miss-1"}`, `{"x":"hit"}`, `{"x":"miss-2"}`, `{"x":"miss-3"}`, `{"x":"miss-4"}`} {
+		msg := kafka.Message{Offset: int64(i), Value: []byte(value)}
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, dest, delivery.RetryPolicy{}, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(dest.written) != 1 {
+		t.Fatalf("forwarded %d message(s), want 1", len(dest.written))
+	}
+	// half of the four rejections, evenly spread
+	if len(rejected.written) != 2 || string(rejected.written[0].Value) != `{"x":"miss-2"}` || string(rejected.written[1].Value) != `{"x":"miss-4"}` {
+		t.Fatalf("unexpected rejected sample %+v", rejected.written)
+	}
+	headers := make(map[string]string)
+	for _, h := range rejected.written[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers[headerRejectedReason] != rejectedNoMatch || headers[headerRejectedDetail] != "no value of fieldA is cached" || headers[headerSourceOffset] != "2" || headers[headerRoute] != "route-rejected" {
+		t.Fatalf("unexpected rejection headers %v", headers)
+	}
+}
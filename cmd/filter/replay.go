@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/archive"
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// runReplay implements `filter replay`: it re-reads a route's source topic between two
+// bounds, matches it against the cache in the configured storage, forwards the matches,
+// and exits. Partitions are read directly rather than through a consumer group, so nothing
+// is committed and the route's own group is left untouched.
+func runReplay(defaultConfig string, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfig, "path to YAML config file")
+	routeName := fs.String("route", "", "route key to replay")
+	fromFlag := fs.String("from", "", "start: earliest, an RFC 3339 timestamp, or an offset (required)")
+	toFlag := fs.String("to", "latest", "end (exclusive): latest, an RFC 3339 timestamp, or an offset")
+	partition := fs.Int("partition", -1, "replay only this source partition")
+	destination := fs.String("destination", "", "write matches to this topic instead of the route's destination")
+	dryRun := fs.Bool("dry-run", false, "count matches without writing them")
+	idle := fs.Duration("idle-timeout", 30*time.Second, "finish a partition when no record arrives for this long")
+	sourceFlag := fs.String("source", "", "source topic to replay of a route with sources, as topic or cluster/topic, or with a sourceTopicPattern")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *routeName == "" || *fromFlag == "" {
+		return errors.New("-route and -from are required")
+	}
+	from, err := kafkapkg.ParseBound(*fromFlag, kafkapkg.Bound{})
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	to, err := kafkapkg.ParseBound(*toFlag, kafkapkg.Bound{Offset: kafka.LastOffset})
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		return err
+	}
+	b := newBridge(cfg.Logging)
+	var route *config.Route
+	for i := range cfg.Routes {
+		if routeKey(cfg.Routes[i]) == *routeName {
+			route = &cfg.Routes[i]
+		}
+	}
+	if route == nil {
+		return fmt.Errorf("route %s is not defined in %s", *routeName, *cfgPath)
+	}
+	if err := selectReplaySource(route, *sourceFlag); err != nil {
+		return err
+	}
+	if *destination != "" {
+		route.DestinationTopic = *destination
+		route.Destination = config.Destination{Type: config.DestinationKafka}
+	}
+	sourceCluster, _ := cfg.SourceClusterByName(route.SourceCluster)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	bridgeDialer, err := buildDialer(cfg.BridgeCluster, cfg.ClientID)
+	if err != nil {
+		return fmt.Errorf("bridge dialer: %w", err)
+	}
+	sourceDialer, err := buildDialer(sourceCluster.ClusterConfig(), cfg.ClientID)
+	if err != nil {
+		return fmt.Errorf("source dialer %s: %w", sourceCluster.Name, err)
+	}
+
+	matchStore, err := loadCache(ctx, cfg, bridgeDialer)
+	if err != nil {
+		return err
+	}
+	matcher, err := newRouteMatcher(cfg, *route, matchStore)
+	if err != nil {
+		return fmt.Errorf("build matcher: %w", err)
+	}
+	if route.Dedup != nil {
+		window, err := newDedupWindow(*route.Dedup)
+		if err != nil {
+			return err
+		}
+		b.routeDedup.set(routeKey(*route), window)
+		// a dry run forwards nothing, so it must not mark messages as forwarded
+		if route.Dedup.Path != "" && !*dryRun {
+			defer func() {
+				if err := window.save(time.Now()); err != nil {
+					log.Printf("save dedup window %s: %v", route.Dedup.Path, err)
+				}
+			}()
+		}
+	}
+	if matcher.Size() == 0 {
+		log.Printf("warn: route %s has no cached values; nothing will match", route.DisplayName())
+	}
+
+	ranges, err := kafkapkg.ResolveRange(ctx, sourceCluster.Brokers, sourceDialer, route.SourceTopic, *partition, from, to)
+	if err != nil {
+		return err
+	}
+
+	var writer delivery.MessageWriter = discardWriter{}
+	if route.OnDecodeError == config.OnDecodeErrorDLQ {
+		b.routeDecodeErrors.set(routeKey(*route), discardWriter{})
+	}
+	if !*dryRun {
+		writers := delivery.NewPool(cfg.BridgeCluster.Brokers, bridgeDialer)
+		defer writers.Close()
+		var sink *archive.Sink
+		if route.Archive != nil {
+			var stopArchive func()
+			sink, stopArchive = startArchive(ctx, *route)
+			defer stopArchive()
+		}
+		if writer, err = b.newDestination(*route, writers, sink); err != nil {
+			return err
+		}
+		encoder, err := newRouteEncoder(ctx, *route)
+		if err != nil {
+			return err
+		}
+		b.routeEncoders.set(routeKey(*route), encoder)
+		if route.OnDecodeError == config.OnDecodeErrorDLQ {
+			b.routeDecodeErrors.set(routeKey(*route), writers.Topic(route.DecodeErrorTopic))
+		}
+	}
+	counted := &countingWriter{MessageWriter: writer}
+	policy := delivery.RetryPolicy{
+		InitialBackoff: route.Delivery.RetryBackoff,
+		MaxBackoff:     route.Delivery.MaxRetryBackoff,
+		MaxAttempts:    route.Delivery.MaxAttempts,
+	}
+	replay := replayer{
+		bridge:  b,
+		route:   *route,
+		guard:   newLoopGuard(cfg.LoopPrevention, routeKey(*route)),
+		headers: newHeaderRewriter(cfg, *route),
+		matcher: matcher,
+		dest:    counted,
+		policy:  policy,
+		idle:    *idle,
+	}
+
+	var scanned int
+	for _, r := range ranges {
+		n, err := replay.partition(ctx, withFetch(kafka.ReaderConfig{
+			Brokers:   sourceCluster.Brokers,
+			Topic:     route.SourceTopic,
+			Partition: r.Partition,
+			Dialer:    sourceDialer,
+		}, sourceCluster.Fetch.Override(route.Consumer.Fetch())), r)
+		scanned += n
+		if err != nil {
+			return fmt.Errorf("replay partition %d: %w", r.Partition, err)
+		}
+	}
+	verb := "forwarded"
+	if *dryRun {
+		verb = "matched (dry run)"
+	}
+	log.Printf("replay of %s from %s to %s done: scanned %d record(s), %s %d to %s", route.SourceTopic, from, to, scanned, verb, counted.n, destinationName(*route))
+	return nil
+}
+
+// loadCache restores the cached values from the configured storage, global and per route.
+func loadCache(ctx context.Context, cfg *config.Config, bridgeDialer *kafka.Dialer) (*store.MatchStore, error) {
+	matchStore := store.NewMatchStore()
+	switch {
+	case cfg.Storage.Backend == config.StorageBackendKafka:
+		state := kafkapkg.NewStateTopic(cfg.BridgeCluster.Brokers, bridgeDialer, cfg.Storage.Topic)
+		defer state.Close()
+		if _, err := state.Restore(ctx, matchStore); err != nil {
+			return nil, fmt.Errorf("restore state topic %s: %w", cfg.Storage.Topic, err)
+		}
+	case cfg.Storage.Backend == config.StorageBackendSQLite:
+		db, err := store.OpenSQLite(cfg.Storage.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if _, err := db.Restore(ctx, matchStore); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", cfg.Storage.Path, err)
+		}
+	case cfg.Storage.Path != "":
+		if err := loadSnapshot(cfg.Storage.Path, matchStore); err != nil {
+			return nil, fmt.Errorf("load snapshot: %w", err)
+		}
+		if cfg.Storage.WAL {
+			if _, err := store.ReplayWAL(cfg.Storage.WALPath(), matchStore); err != nil {
+				return nil, fmt.Errorf("replay wal: %w", err)
+			}
+		}
+	case sharedStorageRoutes(cfg) == nil:
+		return nil, errors.New("no storage configured; replay matches against the persisted cache")
+	}
+	if err := restoreRouteStorages(cfg, matchStore, true); err != nil {
+		return nil, err
+	}
+	return matchStore, nil
+}
+
+type replayer struct {
+	bridge  *bridge
+	route   config.Route
+	guard   loopGuard
+	headers headerRewriter
+	matcher *engine.Matcher
+	dest    delivery.MessageWriter
+	policy  delivery.RetryPolicy
+	idle    time.Duration
+}
+
+// partition forwards the matching records of r and returns how many records it read.
+func (p replayer) partition(ctx context.Context, readerCfg kafka.ReaderConfig, r kafkapkg.PartitionRange) (int, error) {
+	if r.Start >= r.End {
+		return 0, nil
+	}
+	reader := kafka.NewReader(readerCfg)
+	defer reader.Close()
+	if err := reader.SetOffset(r.Start); err != nil {
+		return 0, fmt.Errorf("seek to %d: %w", r.Start, err)
+	}
+
+	scanned := 0
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, p.idle)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				// the rest of the range was compacted away or holds only transaction markers
+				log.Printf("warn: partition %d idle for %s before offset %d; finishing early", r.Partition, p.idle, r.End)
+				return scanned, nil
+			}
+			return scanned, err
+		}
+		if msg.Offset >= r.End {
+			return scanned, nil
+		}
+		scanned++
+		if err := p.bridge.forwardMessage(ctx, p.route, p.guard, p.headers, p.matcher, p.dest, p.policy, msg); err != nil {
+			return scanned, err
+		}
+		if msg.Offset+1 >= r.End {
+			return scanned, nil
+		}
+	}
+}
+
+// countingWriter counts the messages written successfully.
+type countingWriter struct {
+	delivery.MessageWriter
+	n int
+}
+
+func (w *countingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := w.MessageWriter.WriteMessages(ctx, msgs...); err != nil {
+		return err
+	}
+	w.n += len(msgs)
+	return nil
+}
+
+type discardWriter struct{}
+
+func (discardWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	return nil
+}
+
+// selectReplaySource points route at the one source topic that replay reads: the one of
+// its sources named by source, or the only one, or the topic named by source that its
+// sourceTopicPattern matches.
+func selectReplaySource(route *config.Route, source string) error {
+	if route.SourceTopicPattern != "" {
+		return selectReplayTopic(route, source)
+	}
+	if len(route.Sources) == 0 {
+		if source != "" {
+			return fmt.Errorf("-source applies to routes with sources or a sourceTopicPattern; %s reads %s", routeKey(*route), route.SourceTopic)
+		}
+		return nil
+	}
+	var picked []config.RouteSource
+	for _, src := range route.Sources {
+		if source == "" || source == src.Topic || source == src.Cluster+"/"+src.Topic {
+			picked = append(picked, src)
+		}
+	}
+	switch {
+	case len(picked) == 0:
+		return fmt.Errorf("route %s has no source %s", routeKey(*route), source)
+	case len(picked) > 1 && source == "":
+		return fmt.Errorf("route %s has %d sources; name the one to replay with -source", routeKey(*route), len(picked))
+	case len(picked) > 1:
+		return fmt.Errorf("%s is a topic on several clusters of route %s; name it as cluster/topic", source, routeKey(*route))
+	}
+	route.SourceCluster, route.SourceTopic = picked[0].Cluster, picked[0].Topic
+	return nil
+}
+
+// selectReplayTopic points route, which reads the topics its sourceTopicPattern matches, at
+// source alone, expanding a templated destinationTopic for it.
+func selectReplayTopic(route *config.Route, source string) error {
+	if source == "" {
+		return fmt.Errorf("route %s reads the topics matching %s; name the one to replay with -source", routeKey(*route), route.SourceLabel())
+	}
+	re, err := route.SourceTopicRegexp()
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(source) {
+		return fmt.Errorf("route %s has no source %s: it does not match %s", routeKey(*route), source, route.SourceLabel())
+	}
+	if route.DestinationTemplated() {
+		topic, err := route.DestinationTopicFor(re, source)
+		if err != nil {
+			return err
+		}
+		// an unnamed route is keyed by its destinationTopic, whose cache must still apply
+		route.Name = route.DisplayName()
+		route.DestinationTopic = topic
+	}
+	route.SourceTopic, route.SourceTopicPattern = source, ""
+	return nil
+}
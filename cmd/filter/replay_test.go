@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestSelectReplaySource(t *testing.T) {
+	sources := config.Route{Name: "multi", Sources: []config.RouteSource{{Cluster: "eu", Topic: "orders"}, {Cluster: "us", Topic: "orders"}, {Cluster: "us", Topic: "returns"}}}
+	pattern := config.Route{SourceCluster: "eu", SourceTopicPattern: `orders\.(.+)`, DestinationTopic: "mirror.$1"}
+	for _, tc := range []struct {
+		name   string
+		route  config.Route
+		source string
+		// want is the cluster/topic -> destination replay reads and writes, or the start of the error
+		want string
+	}{
+		{name: "single topic", route: config.Route{SourceCluster: "eu", SourceTopic: "orders", DestinationTopic: "out"}, want: "eu/orders -> out"},
+		{name: "single topic with -source", route: config.Route{SourceCluster: "eu", SourceTopic: "orders", DestinationTopic: "out"}, source: "orders", want: "-source applies to routes with sources or a sourceTopicPattern"},
+		{name: "sources by cluster/topic", route: sources, source: "us/orders", want: "us/orders -> "},
+		{name: "sources by topic", route: sources, source: "returns", want: "us/returns -> "},
+		{name: "ambiguous source", route: sources, source: "orders", want: "orders is a topic on several clusters"},
+		{name: "sources without -source", route: sources, want: "route multi has 3 sources"},
+		{name: "pattern", route: pattern, source: "orders.eu", want: "eu/orders.eu -> mirror.eu"},
+		{name: "pattern without -source", route: pattern, want: `route mirror-$1 reads the topics matching /orders\.(.+)/`},
+		{name: "pattern not matched", route: pattern, source: "returns.eu", want: "route mirror-$1 has no source returns.eu"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			route := tc.route
+			key := routeKey(route)
+			var got string
+			if err := selectReplaySource(&route, tc.source); err != nil {
+				got = err.Error()
+			} else {
+				got = route.SourceCluster + "/" + route.SourceTopic + " -> " + route.DestinationTopic
+				if routeKey(route) != key || route.SourceTopicPattern != "" {
+					t.Fatalf("replayed route keyed %s with pattern %q, want %s and a single topic", routeKey(route), route.SourceTopicPattern, key)
+				}
+			}
+			if !strings.HasPrefix(got, tc.want) {
+				t.Fatalf("selected %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/store"
+)
+
+// sharedStorageRoutes returns the filter of the routes the global storage persists, or
+// nil when no route has storage of its own and the global storage keeps them all.
+func sharedStorageRoutes(cfg *config.Config) func(routeID string) bool {
+	own := make(map[string]bool)
+	for _, route := range cfg.Routes {
+		if route.Storage != nil {
+			own[routeKey(route)] = true
+		}
+	}
+	if len(own) == 0 {
+		return nil
+	}
+	return func(routeID string) bool { return !own[routeID] }
+}
+
+// routeObserver passes fn the cache changes of the routes keep accepts, or all of them
+// when keep is nil.
+func routeObserver(fn store.Observer, keep func(routeID string) bool) store.Observer {
+	if keep == nil {
+		return fn
+	}
+	return func(m store.Mutation) {
+		if keep(m.Route) {
+			fn(m)
+		}
+	}
+}
+
+// saveRoutes writes the snapshot of the routes keep accepts, or of every route when keep
+// is nil, to path.
+func saveRoutes(matchStore *store.MatchStore, path string, opts store.SaveOptions, keep func(routeID string) bool) error {
+	snapshot, meta := matchStore.CanonicalSnapshotMeta()
+	for routeID := range snapshot {
+		if keep != nil && !keep(routeID) {
+			delete(snapshot, routeID)
+			delete(meta, routeID)
+		}
+	}
+	return store.SaveWithMeta(path, snapshot, meta, opts)
+}
+
+// restoreRouteStorages loads the cache of every route with storage of its own from its
+// snapshot file and wal. Runs after the global storage is restored: a route whose file
+// does not exist yet keeps the values restored for it there, for startRouteStorages to
+// move. Unless strict, a file that cannot be read is logged and the route keeps them too.
+func restoreRouteStorages(cfg *config.Config, matchStore *store.MatchStore, strict bool) error {
+	for _, route := range cfg.Routes {
+		st := route.Storage
+		if st == nil {
+			continue
+		}
+		routeID := routeKey(route)
+		file, err := store.ReadSnapshotFile(st.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil && strict:
+			return fmt.Errorf("route %s: load snapshot %s: %w", route.DisplayName(), st.Path, err)
+		case err != nil:
+			log.Printf("warn: route %s: failed to load snapshot %s: %v", route.DisplayName(), st.Path, err)
+		default:
+			matchStore.LoadRouteEntries(routeID, file.Entries()[routeID])
+			log.Printf("route %s: loaded %d value(s) from %s", route.DisplayName(), len(file.Routes[routeID]), st.Path)
+		}
+		if st.WAL {
+			replayed, err := store.ReplayWAL(st.WALPath(), matchStore)
+			if err != nil {
+				return fmt.Errorf("route %s: replay wal: %w", route.DisplayName(), err)
+			}
+			log.Printf("route %s: replayed %d cache change(s) from %s", route.DisplayName(), replayed, st.WALPath())
+		}
+	}
+	return nil
+}
+
+// startRouteStorages starts the snapshot writer of every route with storage of its own.
+// A route whose file does not exist yet has its values written there at once, so a
+// combined snapshot is split up without losing anything.
+func startRouteStorages(ctx context.Context, cfg *config.Config, matchStore *store.MatchStore) error {
+	for _, route := range cfg.Routes {
+		st := route.Storage
+		if st == nil {
+			continue
+		}
+		routeID := routeKey(route)
+		keep := func(id string) bool { return id == routeID }
+		opts := store.SaveOptions{Gzip: st.Compression == config.StorageCompressionGzip}
+		if _, err := os.Stat(st.Path); errors.Is(err, fs.ErrNotExist) {
+			if n := matchStore.Size(routeID); n > 0 {
+				if err := saveRoutes(matchStore, st.Path, opts, keep); err != nil {
+					return err
+				}
+				log.Printf("event: route %s: moved %d value(s) from the global storage to %s", route.DisplayName(), n, st.Path)
+			}
+		}
+		var wal *store.WAL
+		if st.WAL {
+			var err error
+			if wal, err = store.OpenWAL(st.WALPath()); err != nil {
+				return err
+			}
+			matchStore.AddObserver(routeObserver(wal.Record, keep))
+		}
+		startSnapshotWriter(ctx, st.Path, st.FlushInterval, opts, matchStore, wal, keep)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteStoragesMigrateCombinedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	shared := config.Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "shared"}
+	own := config.Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "own",
+		Storage: &config.Storage{Backend: config.StorageBackendFile, Path: filepath.Join(dir, "own.json"), FlushInterval: time.Hour}}
+	cfg := &config.Config{Routes: []config.Route{shared, own}}
+	sharedID, ownID := routeKey(shared), routeKey(own)
+
+	matchStore := store.NewMatchStore()
+	matchStore.Load(map[string][]string{sharedID: {"a"}, ownID: {"b", "c"}})
+	if err := restoreRouteStorages(cfg, matchStore, true); err != nil {
+		t.Fatalf("restoreRouteStorages: %v", err)
+	}
+	if err := startRouteStorages(context.Background(), cfg, matchStore); err != nil {
+		t.Fatalf("startRouteStorages: %v", err)
+	}
+	moved, err := store.Load(own.Storage.Path)
+	if err != nil {
+		t.Fatalf("load route snapshot: %v", err)
+	}
+	if len(moved) != 1 || len(moved[ownID]) != 2 {
+		t.Fatalf("expected only %s in its own snapshot, got %v", ownID, moved)
+	}
+
+	global := filepath.Join(dir, "cache.json")
+	if err := saveRoutes(matchStore, global, store.SaveOptions{}, sharedStorageRoutes(cfg)); err != nil {
+		t.Fatalf("saveRoutes: %v", err)
+	}
+	saved, err := store.Load(global)
+	if err != nil {
+		t.Fatalf("load global snapshot: %v", err)
+	}
+	if _, ok := saved[ownID]; ok || len(saved[sharedID]) != 1 {
+		t.Fatalf("expected the global snapshot to leave out %s, got %v", ownID, saved)
+	}
+
+	// once the route's file exists it wins over whatever the global storage restored
+	restarted := store.NewMatchStore()
+	restarted.Load(map[string][]string{sharedID: {"a"}, ownID: {"stale"}})
+	if err := restoreRouteStorages(cfg, restarted, true); err != nil {
+		t.Fatalf("restoreRouteStorages: %v", err)
+	}
+	if err := startRouteStorages(context.Background(), cfg, restarted); err != nil {
+		t.Fatalf("startRouteStorages: %v", err)
+	}
+	if restarted.Contains(ownID, "stale") || !restarted.Contains(ownID, "b") || !restarted.Contains(sharedID, "a") {
+		t.Fatalf("unexpected cache after restart: %v", restarted.CanonicalSnapshot())
+	}
+}
+
+func TestSnapshotsKeepProvenance(t *testing.T) {
+	dir := t.TempDir()
+	own := config.Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "own",
+		Storage: &config.Storage{Backend: config.StorageBackendFile, Path: filepath.Join(dir, "own.json"), FlushInterval: time.Hour}}
+	shared := config.Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "shared"}
+	cfg := &config.Config{Routes: []config.Route{shared, own}}
+	sharedID, ownID := routeKey(shared), routeKey(own)
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fromFeed := store.Metadata{Source: store.SourceKafka, Feed: "feed-a", Topic: "ref", Offset: 3, AddedAt: at, EventTime: at.Add(-time.Hour)}
+	injected := store.Metadata{Source: store.SourceHTTP, AddedAt: at, Annotations: map[string]string{"ticket": "OPS-1"}}
+	matchStore := store.NewMatchStore()
+	matchStore.AddWithMeta(ownID, "b", fromFeed)
+	matchStore.AddWithMeta(sharedID, "a", injected)
+
+	global := filepath.Join(dir, "cache.json")
+	if err := saveRoutes(matchStore, own.Storage.Path, store.SaveOptions{}, func(id string) bool { return id == ownID }); err != nil {
+		t.Fatalf("saveRoutes: %v", err)
+	}
+	if err := saveRoutes(matchStore, global, store.SaveOptions{}, sharedStorageRoutes(cfg)); err != nil {
+		t.Fatalf("saveRoutes: %v", err)
+	}
+
+	restarted := store.NewMatchStore()
+	if err := loadSnapshot(global, restarted); err != nil {
+		t.Fatalf("loadSnapshot: %v", err)
+	}
+	if err := restoreRouteStorages(cfg, restarted, true); err != nil {
+		t.Fatalf("restoreRouteStorages: %v", err)
+	}
+	if got, ok := restarted.Lookup(ownID, "b"); !ok || !reflect.DeepEqual(got, fromFeed) {
+		t.Fatalf("route storage restored %+v, %v; want %+v", got, ok, fromFeed)
+	}
+	if got, ok := restarted.Lookup(sharedID, "a"); !ok || !reflect.DeepEqual(got, injected) {
+		t.Fatalf("global snapshot restored %+v, %v; want %+v", got, ok, injected)
+	}
+}
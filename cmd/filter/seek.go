@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+)
+
+type seekRegistry struct {
+	mu     sync.Mutex
+	routes map[string]*seekControl
+}
+
+// seekControl plans seeks of one route's source consumer group and hands confirmed ones
+// to the route's stream loop, which alone can stop and restart the reader.
+type seekControl struct {
+	groupID string
+	plan    func(ctx context.Context, to kafkapkg.Bound) ([]kafkapkg.OffsetReset, error)
+	commit  func(ctx context.Context, resets []kafkapkg.OffsetReset) error
+	calls   chan *seekCall
+}
+
+// seekCall is a confirmed seek. As the cancellation cause of the route's stream it tells
+// the loop to commit the new offsets once the reader has left the group.
+type seekCall struct {
+	to   kafkapkg.Bound
+	done chan seekResult
+}
+
+type seekResult struct {
+	resets []kafkapkg.OffsetReset
+	err    error
+}
+
+func (*seekCall) Error() string { return "seek requested" }
+
+// bound returns the target of req.
+func (req SeekRequest) bound() (kafkapkg.Bound, error) {
+	var targets []kafkapkg.Bound
+	if req.Offset != nil {
+		if *req.Offset < 0 {
+			return kafkapkg.Bound{}, errors.New("offset cannot be negative")
+		}
+		targets = append(targets, kafkapkg.Bound{Offset: *req.Offset})
+	}
+	if !req.Timestamp.IsZero() {
+		targets = append(targets, kafkapkg.Bound{Time: req.Timestamp})
+	}
+	if req.Earliest {
+		targets = append(targets, kafkapkg.Bound{Offset: kafka.FirstOffset})
+	}
+	if req.Latest {
+		targets = append(targets, kafkapkg.Bound{Offset: kafka.LastOffset})
+	}
+	if len(targets) != 1 {
+		return kafkapkg.Bound{}, errors.New("set exactly one of offset, timestamp, earliest, and latest")
+	}
+	return targets[0], nil
+}
+
+// open registers the seek control of routeID and returns the func that removes it.
+func (r *seekRegistry) open(routeID string, control *seekControl) func() {
+	r.mu.Lock()
+	r.routes[routeID] = control
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.routes, routeID)
+		r.mu.Unlock()
+	}
+}
+
+func (r *seekRegistry) get(routeID string) *seekControl {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.routes[routeID]
+}
+
+// newSeekControl plans and commits seeks of the route's source group on its cluster, or on
+// the in-memory broker with -mock.
+func (b *bridge) newSeekControl(route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer) *seekControl {
+	group := sourceGroupID(sourceCluster, route)
+	control := &seekControl{groupID: group, calls: make(chan *seekCall)}
+	if broker := b.memoryBroker; broker != nil {
+		control.plan = func(_ context.Context, to kafkapkg.Bound) ([]kafkapkg.OffsetReset, error) {
+			return broker.PlanGroupSeek(group, route.SourceTopicsOn(route.SourceCluster), to), nil
+		}
+		control.commit = func(_ context.Context, resets []kafkapkg.OffsetReset) error {
+			broker.CommitGroupOffsets(group, resets)
+			return nil
+		}
+		return control
+	}
+	control.plan = func(ctx context.Context, to kafkapkg.Bound) ([]kafkapkg.OffsetReset, error) {
+		topics := route.SourceTopicsOn(route.SourceCluster)
+		if route.SourceTopicPattern != "" {
+			re, err := route.SourceTopicRegexp()
+			if err != nil {
+				return nil, err
+			}
+			if topics, err = kafkapkg.MatchingTopics(ctx, sourceCluster.Brokers, dialer, re); err != nil {
+				return nil, err
+			}
+		}
+		return kafkapkg.PlanGroupSeek(ctx, sourceCluster.Brokers, dialer, group, topics, to)
+	}
+	control.commit = func(ctx context.Context, resets []kafkapkg.OffsetReset) error {
+		return kafkapkg.CommitGroupOffsets(ctx, sourceCluster.Brokers, dialer, group, resets)
+	}
+	return control
+}
+
+// streamSeekable runs stream until it returns on its own, restarting it after every
+// confirmed seek: the stream is cancelled so its reader leaves the group, the new offsets
+// are committed, and the stream starts again from them.
+func (b *bridge) streamSeekable(ctx context.Context, route config.Route, control *seekControl, stream func(context.Context) error) error {
+	defer b.routeSeeks.open(routeKey(route), control)()
+	for {
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		go func() {
+			select {
+			case call := <-control.calls:
+				cancel(call)
+			case <-streamCtx.Done():
+			}
+		}()
+		err := stream(streamCtx)
+		cancel(nil)
+		var call *seekCall
+		if !errors.As(context.Cause(streamCtx), &call) || ctx.Err() != nil {
+			return err
+		}
+		resets, err := control.plan(ctx, call.to)
+		if err == nil {
+			err = control.commit(ctx, resets)
+		}
+		call.done <- seekResult{resets: resets, err: err}
+		if err != nil {
+			log.Printf("warn: route %s: seek of group %s to %s failed, resuming from the committed offsets: %v", route.DisplayName(), control.groupID, call.to, err)
+			continue
+		}
+		log.Printf("event: route %s: group %s moved to %s on %d partition(s); resuming", route.DisplayName(), control.groupID, call.to, len(resets))
+	}
+}
+
+// registerSeek mounts POST /routes/{id}/seek.
+func (b *bridge) registerSeek(mux *http.ServeMux, admin adminDeps) {
+	mux.HandleFunc("/routes/{id}/seek", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		routeID := r.PathValue("id")
+		if _, ok := admin.matchers[routeID]; !ok {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		defer r.Body.Close()
+		var req SeekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON seek request", http.StatusBadRequest)
+			return
+		}
+		to, err := req.bound()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		control := b.routeSeeks.get(routeID)
+		if control == nil {
+			http.Error(w, "route is not streaming on this replica", http.StatusConflict)
+			return
+		}
+
+		resp := SeekResponse{Route: routeID, GroupID: control.groupID, Target: to.String(), Partitions: []SeekPartition{}}
+		var resets []kafkapkg.OffsetReset
+		if req.Confirm != routeID {
+			if resets, err = control.plan(r.Context(), to); err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		} else {
+			call := &seekCall{to: to, done: make(chan seekResult, 1)}
+			select {
+			case control.calls <- call:
+			case <-r.Context().Done():
+				return
+			}
+			select {
+			case res := <-call.done:
+				if res.err != nil {
+					http.Error(w, fmt.Sprintf("seek failed; the route resumed from its committed offsets: %v", res.err), http.StatusBadGateway)
+					return
+				}
+				resets, resp.Applied = res.resets, true
+			case <-r.Context().Done():
+				return
+			}
+		}
+		for _, reset := range resets {
+			resp.Partitions = append(resp.Partitions, SeekPartition{Topic: reset.Topic, Partition: reset.Partition, From: reset.From, To: reset.To})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("seek encode failed: %v", err)
+		}
+	}))
+}
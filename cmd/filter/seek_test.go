@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteSeekRewindsMockRoute(t *testing.T) {
+	b := newBridge(config.Logging{})
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	cfg := &config.Config{}
+	route := config.Route{Name: "seek-route", SourceTopic: "orders", DestinationTopic: "matched",
+		ReferenceFeeds: []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}}}
+	matchStore := store.NewMatchStore()
+	matcher, err := newRouteMatcher(cfg, route, matchStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher.AddValues([]string{"c1"})
+	writers := delivery.NewPool(nil, nil, delivery.WithTopicWriters(func(topic string) delivery.MessageWriter { return b.memoryBroker.Topic(topic) }))
+	mux := buildHTTPMux(adminDeps{bridge: b, store: matchStore, matchers: map[string]*engine.Matcher{"seek-route": matcher, "idle": nil}})
+	seek := func(routeID, body string) (int, SeekResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/routes/"+routeID+"/seek", strings.NewReader(body)))
+		var resp SeekResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid seek response %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, resp
+	}
+	forwarded := func(n int) {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); len(b.memoryBroker.Messages("matched")) != n; {
+			if time.Now().After(deadline) {
+				t.Fatalf("forwarded %d message(s), want %d", len(b.memoryBroker.Messages("matched")), n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = b.streamRoute(ctx, cfg, route, config.SourceCluster{Name: "mock", SourceGroupID: "src"}, nil, writers, matchStore, matcher)
+	}()
+	if _, err := b.memoryBroker.Produce("orders", kafka.Message{Value: []byte(`{"customer":"c1"}`)}, kafka.Message{Value: []byte(`{"customer":"c1","n":2}`)}); err != nil {
+		t.Fatal(err)
+	}
+	forwarded(2)
+
+	if code, _ := seek("seek-route", `{"earliest":true,"latest":true}`); code != http.StatusBadRequest {
+		t.Fatalf("two targets: status %d, want 400", code)
+	}
+	if code, _ := seek("idle", `{"earliest":true}`); code != http.StatusConflict {
+		t.Fatalf("route not streaming: status %d, want 409", code)
+	}
+	code, preview := seek("seek-route", `{"offset":1}`)
+	want := []SeekPartition{{Topic: "orders", Partition: 0, From: 2, To: 1}}
+	if code != http.StatusOK || preview.Applied || preview.GroupID != "src-seek-route" || !reflect.DeepEqual(preview.Partitions, want) {
+		t.Fatalf("unexpected preview %d %+v", code, preview)
+	}
+	forwarded(2)
+
+	code, applied := seek("seek-route", `{"earliest":true,"confirm":"seek-route"}`)
+	want[0].To = 0
+	if code != http.StatusOK || !applied.Applied || applied.Target != "earliest" || !reflect.DeepEqual(applied.Partitions, want) {
+		t.Fatalf("unexpected seek %d %+v", code, applied)
+	}
+	forwarded(4)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/serialize"
+)
+
+type encoderRegistry struct {
+	mu       sync.RWMutex
+	encoders map[string]serialize.Encoder
+}
+
+func (r *encoderRegistry) set(routeID string, enc serialize.Encoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encoders[routeID] = enc
+}
+
+// get returns the route's encoder, or nil when it forwards payloads as they are.
+func (r *encoderRegistry) get(routeID string) serialize.Encoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.encoders[routeID]
+}
+
+// newRouteEncoder builds the encoder of route's output format, registering an Avro schema
+// with its registry first, or returns nil for JSON output.
+func newRouteEncoder(ctx context.Context, route config.Route) (serialize.Encoder, error) {
+	out := route.Output
+	if out == nil {
+		return nil, nil
+	}
+	switch out.Format {
+	case config.OutputFormatAvro:
+		schema, err := os.ReadFile(out.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		avro, err := serialize.NewAvro(string(schema))
+		if err != nil {
+			return nil, fmt.Errorf("output: %s: %w", out.SchemaFile, err)
+		}
+		if out.Registry == nil {
+			return avro, nil
+		}
+		registry, err := newSchemaRegistry(*out.Registry)
+		if err != nil {
+			return nil, err
+		}
+		id, err := registry.RegisterAvro(ctx, out.Registry.Subject, string(schema))
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		log.Printf("route %s encodes avro with schema %d of subject %s", route.DisplayName(), id, out.Registry.Subject)
+		return avro.Framed(id), nil
+	case config.OutputFormatProtobuf:
+		set, err := os.ReadFile(out.DescriptorFile)
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		pb, err := serialize.NewProtobuf(set, out.Message)
+		if err != nil {
+			return nil, fmt.Errorf("output: %s: %w", out.DescriptorFile, err)
+		}
+		return pb, nil
+	}
+	return nil, nil
+}
+
+func newSchemaRegistry(reg config.SchemaRegistry) (*serialize.Registry, error) {
+	tlsConfig, err := reg.TLSConfigObject()
+	if err != nil {
+		return nil, fmt.Errorf("output registry tls: %w", err)
+	}
+	client := &http.Client{Timeout: reg.Timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return serialize.NewRegistry(reg.URL, client, reg.Headers), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/serialize"
+	"kafka-bridge/pkg/store"
+)
+
+func TestForwardMessageOutputFormat(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matcher, err := engine.NewMatcher("route-avro", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	matcher.AddValues([]string{"abc"})
+	avro, err := serialize.NewAvro(`{"type":"record","name":"Match","fields":[{"name":"fieldA","type":"string"},{"name":"n","type":"long"}]}`)
+	if err != nil {
+		t.Fatalf("NewAvro: %v", err)
+	}
+	b.routeEncoders.set("route-avro", avro.Framed(3))
+	route := config.Route{Name: "route-avro", DestinationTopic: "dest"}
+	w := &recordingWriter{}
+	for _, value := range []string{`{"fieldA":"abc","n":1}`, `{"fieldA":"abc","n":"one"}`} {
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, delivery.RetryPolicy{}, kafka.Message{Value: []byte(value)}); err != nil {
+			t.Fatalf("forwardMessage: %v", err)
+		}
+	}
+	// the payload that does not fit the schema is skipped as undecodable
+	if len(w.written) != 1 || !bytes.Equal(w.written[0].Value, []byte{0, 0, 0, 0, 3, 6, 'a', 'b', 'c', 2}) {
+		t.Fatalf("unexpected forwarded values: %v", w.written)
+	}
+	if got := b.routeCounters.route("route-avro").decodeErrors.Load(); got != 1 {
+		t.Fatalf("decode errors = %d, want 1", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/store"
+)
+
+// runSnapshot implements `filter snapshot inspect` and `filter snapshot convert`, which work
+// on the cache snapshot files of the file storage backend without a running bridge.
+func runSnapshot(g globalFlags, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: filter snapshot inspect|convert [flags] [file...]")
+	}
+	switch args[0] {
+	case "inspect":
+		return runSnapshotInspect(g, args[1:], out)
+	case "convert":
+		return runSnapshotConvert(args[1:], out)
+	default:
+		return fmt.Errorf("unknown snapshot subcommand %q: want inspect or convert", args[0])
+	}
+}
+
+// snapshotReport is what `filter snapshot inspect -json` prints for one file.
+type snapshotReport struct {
+	File    string                `json:"file"`
+	Version int                   `json:"version"`
+	Gzip    bool                  `json:"gzip"`
+	Values  int                   `json:"values"`
+	Routes  []snapshotRouteReport `json:"routes"`
+}
+
+// snapshotRouteReport describes one route of a snapshot file.
+type snapshotRouteReport struct {
+	Route   string   `json:"route"`
+	Values  int      `json:"values"`
+	Samples []string `json:"samples,omitempty"`
+	// Matches are the values containing the -search text.
+	Matches []string `json:"matches,omitempty"`
+}
+
+// runSnapshotInspect prints the routes of each snapshot file with their value counts and
+// sample values. Without a file it inspects the snapshot files of the config, global and
+// per route.
+func runSnapshotInspect(g globalFlags, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("snapshot inspect", flag.ContinueOnError)
+	cfgPath := fs.String("config", g.config, "path to YAML config file whose storage.path is inspected when no file is given")
+	routeList := fs.String("route", "", "comma-separated route keys to report (default all)")
+	samples := fs.Int("samples", 5, "sample values to print per route")
+	search := fs.String("search", "", "report the values of each route containing this text")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		cfg, err := config.Load(*cfgPath)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		if cfg.Storage.Backend == config.StorageBackendFile && cfg.Storage.Path != "" {
+			files = append(files, cfg.Storage.Path)
+		}
+		for _, route := range cfg.Routes {
+			if route.Storage != nil {
+				files = append(files, route.Storage.Path)
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("%s does not use file storage; name the snapshot files to inspect", *cfgPath)
+		}
+	}
+	routes := splitList(*routeList)
+
+	reports := make([]snapshotReport, 0, len(files))
+	for _, path := range files {
+		file, err := store.ReadSnapshotFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		file.Routes = filterSnapshotRoutes(file.Routes, routes)
+		report := snapshotReport{File: path, Version: file.Version, Gzip: file.Gzip, Routes: []snapshotRouteReport{}}
+		for _, route := range sortedKeys(file.Routes) {
+			values := append([]string(nil), file.Routes[route]...)
+			sort.Strings(values)
+			r := snapshotRouteReport{Route: route, Values: len(values), Samples: values[:min(*samples, len(values))]}
+			if *search != "" {
+				for _, v := range values {
+					if strings.Contains(v, *search) {
+						r.Matches = append(r.Matches, v)
+					}
+				}
+			}
+			report.Values += r.Values
+			report.Routes = append(report.Routes, r)
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+	for _, report := range reports {
+		format := fmt.Sprintf("version %d", report.Version)
+		if report.Version == 0 {
+			format = "legacy"
+		}
+		if report.Gzip {
+			format += ", gzip"
+		}
+		fmt.Fprintf(out, "%s: %s, %d route(s), %d value(s)\n", report.File, format, len(report.Routes), report.Values)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, r := range report.Routes {
+			fmt.Fprintf(tw, "  %s\t%d\t%s\n", r.Route, r.Values, strings.Join(r.Samples, ", "))
+			if *search != "" {
+				fmt.Fprintf(tw, "  \t\t%d match(es) for %q: %s\n", len(r.Matches), *search, strings.Join(r.Matches, ", "))
+			}
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+// runSnapshotConvert merges snapshot files into one, keeping the union of the values of
+// each route, and writes it in the requested format.
+func runSnapshotConvert(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("snapshot convert", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the snapshot to, or - for stdout")
+	routeList := fs.String("route", "", "comma-separated route keys to keep (default all)")
+	gzip := fs.Bool("gzip", false, "compress the written snapshot")
+	legacy := fs.Bool("legacy", false, "write the unversioned format of earlier bridges")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" || fs.NArg() == 0 {
+		return errors.New("usage: filter snapshot convert -o out [-route a,b] [-gzip] [-legacy] file...")
+	}
+	routes := splitList(*routeList)
+
+	// merged holds the provenance of every value; a later file's wins when it has one.
+	merged := make(map[string]map[string]store.Metadata)
+	for _, path := range fs.Args() {
+		file, err := store.ReadSnapshotFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		for route, values := range filterSnapshotRoutes(file.Routes, routes) {
+			set := merged[route]
+			if set == nil {
+				set = make(map[string]store.Metadata, len(values))
+				merged[route] = set
+			}
+			for _, v := range values {
+				if meta, ok := file.Meta[route][v]; ok {
+					set[v] = meta
+				} else if _, ok := set[v]; !ok {
+					set[v] = store.Metadata{}
+				}
+			}
+		}
+	}
+	snapshot := make(map[string][]string, len(merged))
+	meta := make(map[string]map[string]store.Metadata)
+	for route, set := range merged {
+		values := make([]string, 0, len(set))
+		for v, m := range set {
+			values = append(values, v)
+			if !m.IsZero() {
+				if meta[route] == nil {
+					meta[route] = make(map[string]store.Metadata)
+				}
+				meta[route][v] = m
+			}
+		}
+		sort.Strings(values)
+		snapshot[route] = values
+	}
+
+	opts := store.SaveOptions{Gzip: *gzip, Legacy: *legacy}
+	if *output == "-" {
+		return store.WriteSnapshotWithMeta(out, snapshot, meta, opts)
+	}
+	if err := store.SaveWithMeta(*output, snapshot, meta, opts); err != nil {
+		return fmt.Errorf("write %s: %w", *output, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d route(s) from %d file(s) to %s\n", len(snapshot), fs.NArg(), *output)
+	return nil
+}
+
+// filterSnapshotRoutes returns the routes of snapshot named in keep, or all of them when
+// keep is empty.
+func filterSnapshotRoutes(snapshot map[string][]string, keep []string) map[string][]string {
+	if len(keep) == 0 {
+		return snapshot
+	}
+	filtered := make(map[string][]string, len(keep))
+	for _, route := range keep {
+		if values, ok := snapshot[route]; ok {
+			filtered[route] = values
+		}
+	}
+	return filtered
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
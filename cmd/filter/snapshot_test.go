@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kafka-bridge/pkg/store"
+)
+
+func TestSnapshotInspectAndConvert(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json.gz")
+	if err := store.Save(a, map[string][]string{"orders": {"o-2", "o-1"}, "users": {"u-1"}}, store.SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(b, map[string][]string{"orders": {"o-2", "o-3"}}, store.SaveOptions{Gzip: true, Legacy: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runSnapshot(globalFlags{}, []string{"inspect", "-json", "-search", "o-3", a, b}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var reports []snapshotReport
+	if err := json.Unmarshal(out.Bytes(), &reports); err != nil {
+		t.Fatalf("invalid inspect output %q: %v", out.String(), err)
+	}
+	if len(reports) != 2 || reports[0].Version != 2 || reports[0].Values != 3 || len(reports[0].Routes) != 2 {
+		t.Fatalf("unexpected report of %s: %+v", a, reports)
+	}
+	if r := reports[1]; r.Version != 0 || !r.Gzip || len(r.Routes) != 1 || !reflect.DeepEqual(r.Routes[0].Matches, []string{"o-3"}) {
+		t.Fatalf("unexpected report of %s: %+v", b, r)
+	}
+
+	merged := filepath.Join(dir, "merged.json")
+	if err := runSnapshot(globalFlags{}, []string{"convert", "-o", merged, "-route", "orders", "-legacy", a, b}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	file, err := store.ReadSnapshotFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"orders": {"o-1", "o-2", "o-3"}}; file.Version != 0 || !reflect.DeepEqual(file.Routes, want) {
+		t.Fatalf("converted snapshot = version %d %v, want legacy %v", file.Version, file.Routes, want)
+	}
+
+	out.Reset()
+	if err := runSnapshot(globalFlags{}, []string{"inspect", "-samples", "1", merged}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "legacy, 1 route(s), 3 value(s)") || !strings.Contains(out.String(), "orders  3  o-1\n") {
+		t.Fatalf("unexpected inspect output:\n%s", out.String())
+	}
+	if err := runSnapshot(globalFlags{}, []string{"convert", a}, io.Discard); err == nil {
+		t.Fatal("expected convert without -o to fail")
+	}
+}
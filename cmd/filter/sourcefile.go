@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// runRun implements `filter run`: it runs the bridge, as filter does without a subcommand,
+// or with -source-file matches the records of a file instead of a source topic.
+func runRun(g globalFlags, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	cfgPath := fs.String("config", g.config, "path to YAML config file")
+	readOnlyAdmin := fs.Bool("read-only-admin", g.readOnlyAdmin, "disable mutating admin HTTP endpoints (clear, inject, delete, compact)")
+	mock := fs.Bool("mock", g.mock, "replace every Kafka cluster with an in-memory broker, fed and read through /mock/topics")
+	var src sourceFileSettings
+	fs.StringVar(&src.path, "source-file", "", "match the NDJSON records of this file, or - for stdin, instead of the source topic")
+	fs.StringVar(&src.route, "route", "", "route key to match -source-file with; optional when the config has one route")
+	fs.StringVar(&src.referenceFile, "reference-file", "", "cache the NDJSON reference records of this file first")
+	fs.StringVar(&src.referenceTopic, "reference-topic", "", "reference topic the -reference-file records are read as (default: the route's first feed)")
+	fs.BoolVar(&src.envelope, "envelope", false, "read each line as a message with key, value, and headers rather than as the value")
+	fs.StringVar(&src.output, "output", "-", "file to write the decisions to, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if src.path == "" {
+		runBridge(*cfgPath, *readOnlyAdmin, *mock)
+		return nil
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if src.output != "-" {
+		f, err := os.Create(src.output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return runSourceFile(ctx, cfg, src, out)
+}
+
+// sourceFileSettings are the -source-file flags of `filter run`.
+type sourceFileSettings struct {
+	path           string
+	route          string
+	referenceFile  string
+	referenceTopic string
+	envelope       bool
+	output         string
+}
+
+// fileDecision is the line `filter run -source-file` writes for every record it reads.
+type fileDecision struct {
+	Line        int             `json:"line"`
+	Route       string          `json:"route"`
+	Decision    string          `json:"decision"`
+	Field       string          `json:"field,omitempty"`
+	Value       string          `json:"value,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Origin      *store.Metadata `json:"origin,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	// Forwarded is the message the route would write, after its key and header rewrites.
+	Forwarded *wireMessage `json:"forwarded,omitempty"`
+}
+
+// runSourceFile matches the records of src.path as the route's source messages and
+// writes one decision per record to out. The cache starts from the configured storage,
+// when there is one, plus the records of src.referenceFile; nothing is written to Kafka,
+// and dead-lettered messages are only reported.
+func runSourceFile(ctx context.Context, cfg *config.Config, src sourceFileSettings, out io.Writer) error {
+	b := newBridge(cfg.Logging)
+	route, err := sourceFileRoute(cfg, src.route)
+	if err != nil {
+		return err
+	}
+	routeID := routeKey(route)
+	matchStore := store.NewMatchStore()
+	if cfg.Storage.Backend != "" || cfg.Storage.Path != "" {
+		bridgeDialer, err := buildDialer(cfg.BridgeCluster, cfg.ClientID)
+		if err != nil {
+			return fmt.Errorf("bridge dialer: %w", err)
+		}
+		if matchStore, err = loadCache(ctx, cfg, bridgeDialer); err != nil {
+			return err
+		}
+	}
+	matcher, err := newRouteMatcher(cfg, route, matchStore)
+	if err != nil {
+		return fmt.Errorf("build matcher: %w", err)
+	}
+	if src.referenceFile != "" {
+		if err := loadReferenceFile(src, route, matcher); err != nil {
+			return err
+		}
+	}
+	if route.Dedup != nil {
+		window, err := newDedupWindow(*route.Dedup)
+		if err != nil {
+			return err
+		}
+		b.routeDedup.set(routeID, window)
+	}
+	if route.OnDecodeError == config.OnDecodeErrorDLQ {
+		b.routeDecodeErrors.set(routeID, discardWriter{})
+	}
+
+	in, closeIn, err := openNDJSON(src.path)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+	decisions := b.forwardEvents.subscribe(routeID, 4, nil)
+	defer decisions.close()
+	collected := &batchCollector{}
+	guard := newLoopGuard(cfg.LoopPrevention, routeID)
+	headers := newHeaderRewriter(cfg, route)
+	enc := json.NewEncoder(out)
+	forwarded := 0
+	line := 0
+	for in.Scan() {
+		line++
+		if len(in.Bytes()) == 0 {
+			continue
+		}
+		msg, err := sourceFileMessage(in.Bytes(), src.envelope)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", src.path, line, err)
+		}
+		msg.Topic, msg.Offset = route.SourceTopic, int64(line-1)
+		if msg.Time.IsZero() {
+			msg.Time = time.Now()
+		}
+		collected.msgs = collected.msgs[:0]
+		if err := b.forwardMessage(ctx, route, guard, headers, matcher, collected, delivery.RetryPolicy{}, msg); err != nil {
+			return fmt.Errorf("%s:%d: %w", src.path, line, err)
+		}
+		d := fileDecision{Line: line, Route: routeID, Decision: decisionSkipped}
+		select {
+		case ev := <-decisions.events:
+			d.Decision, d.Reason = ev.Decision, ev.Reason
+			if ev.Decision == decisionForwarded && ev.Match.Fingerprint != "" {
+				origin := ev.Match.Origin
+				d.Field, d.Value, d.Fingerprint, d.Origin = ev.Match.Field, ev.Match.Value, ev.Match.Fingerprint, &origin
+			}
+		default:
+		}
+		if len(collected.msgs) > 0 {
+			forwarded++
+			w := newWireMessage(collected.msgs[0])
+			d.Forwarded = &w
+		}
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	if err := in.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", src.path, err)
+	}
+	log.Printf("source file %s: read %d record(s), %d would be forwarded to %s", src.path, line, forwarded, destinationName(route))
+	return nil
+}
+
+// sourceFileRoute returns the route with key routeID, or the only route when it is empty.
+func sourceFileRoute(cfg *config.Config, routeID string) (config.Route, error) {
+	if routeID == "" {
+		if len(cfg.Routes) != 1 {
+			return config.Route{}, errors.New("-route is required when the config has more than one route")
+		}
+		return cfg.Routes[0], nil
+	}
+	for _, route := range cfg.Routes {
+		if routeKey(route) == routeID {
+			return route, nil
+		}
+	}
+	return config.Route{}, fmt.Errorf("route %s is not defined", routeID)
+}
+
+// loadReferenceFile caches the records of src.referenceFile as if read from the route's
+// reference topic.
+func loadReferenceFile(src sourceFileSettings, route config.Route, matcher *engine.Matcher) error {
+	topic := src.referenceTopic
+	if topic == "" && len(route.ReferenceFeeds) > 0 {
+		topic = route.ReferenceFeeds[0].Topic
+	}
+	in, closeIn, err := openNDJSON(src.referenceFile)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+	line := 0
+	for in.Scan() {
+		line++
+		if len(in.Bytes()) == 0 {
+			continue
+		}
+		msg, err := sourceFileMessage(in.Bytes(), src.envelope)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", src.referenceFile, line, err)
+		}
+		headerMap := make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headerMap[strings.ToLower(h.Key)] = string(h.Value)
+		}
+		if _, err := matcher.ProcessReference(engine.ReferenceMessage{Topic: topic, Offset: int64(line - 1), Key: msg.Key, Headers: headerMap, Value: msg.Value}); err != nil {
+			log.Printf("%s:%d: invalid reference record skipped: %v", src.referenceFile, line, err)
+		}
+	}
+	if err := in.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", src.referenceFile, err)
+	}
+	log.Printf("reference file %s: %d value(s) cached for route %s", src.referenceFile, matcher.Size(), route.DisplayName())
+	return nil
+}
+
+// openNDJSON returns a scanner of the lines of path, or of stdin for -.
+func openNDJSON(path string) (*bufio.Scanner, func(), error) {
+	var r io.Reader = os.Stdin
+	closeFn := func() {}
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, closeFn = f, func() { f.Close() }
+	}
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 16<<20)
+	return in, closeFn, nil
+}
+
+// sourceFileMessage reads line as the message value, or with envelope as a wireMessage.
+func sourceFileMessage(line []byte, envelope bool) (kafka.Message, error) {
+	if !envelope {
+		return kafka.Message{Value: append([]byte(nil), line...)}, nil
+	}
+	var m wireMessage
+	if err := json.Unmarshal(line, &m); err != nil {
+		return kafka.Message{}, fmt.Errorf("invalid message envelope: %w", err)
+	}
+	if len(m.Value) == 0 {
+		return kafka.Message{}, errors.New("message envelope without value")
+	}
+	return m.message(), nil
+}
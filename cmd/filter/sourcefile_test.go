@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestRunSourceFileWritesDecisions(t *testing.T) {
+	dir := t.TempDir()
+	refs := filepath.Join(dir, "customers.ndjson")
+	if err := os.WriteFile(refs, []byte("{\"id\":\"c1\"}\n{\"id\":\"c2\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "orders.ndjson")
+	lines := `{"key":"o1","value":{"order":"o1","customer":"c2"},"headers":{"trace":"t1"}}` + "\n\n" +
+		`{"value":{"order":"o2","customer":"c9"}}` + "\n" +
+		`{"value":"not json"}` + "\n"
+	if err := os.WriteFile(source, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Routes: []config.Route{{
+		Name:             "file-route",
+		SourceTopic:      "orders",
+		DestinationTopic: "orders-filtered",
+		ReferenceFeeds:   []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}},
+	}}}
+
+	var out bytes.Buffer
+	src := sourceFileSettings{path: source, referenceFile: refs, envelope: true}
+	// the reference file holds bare values, not envelopes
+	if err := runSourceFile(context.Background(), cfg, src, &out); err == nil {
+		t.Fatal("expected the bare reference records to be rejected as envelopes")
+	}
+	if err := os.WriteFile(refs, []byte("{\"value\":{\"id\":\"c1\"}}\n{\"value\":{\"id\":\"c2\"}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runSourceFile(context.Background(), cfg, src, &out); err != nil {
+		t.Fatal(err)
+	}
+	var decisions []fileDecision
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var d fileDecision
+		if err := dec.Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		decisions = append(decisions, d)
+	}
+	if len(decisions) != 3 {
+		t.Fatalf("expected 3 decisions, got %+v", decisions)
+	}
+	first := decisions[0]
+	if first.Line != 1 || first.Decision != decisionForwarded || first.Fingerprint != "c2" || first.Forwarded == nil ||
+		first.Forwarded.Key != "o1" || first.Forwarded.Headers["trace"] != "t1" {
+		t.Fatalf("unexpected first decision: %+v", first)
+	}
+	if d := decisions[1]; d.Line != 3 || d.Decision != decisionSkipped || d.Forwarded != nil {
+		t.Fatalf("unexpected second decision: %+v", d)
+	}
+	if d := decisions[2]; d.Decision != decisionInvalid || d.Reason == "" {
+		t.Fatalf("unexpected third decision: %+v", d)
+	}
+
+	cfg.Routes = append(cfg.Routes, config.Route{Name: "other"})
+	if err := runSourceFile(context.Background(), cfg, src, io.Discard); err == nil {
+		t.Fatal("expected -route to be required with several routes")
+	}
+}
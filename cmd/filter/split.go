@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/store"
+)
+
+// splitRoute clones the cache of route from into route into. When feeds is non-empty only
+// values collected from those reference feeds are copied; values injected over HTTP carry
+// no feed and are copied only when feeds is empty.
+func splitRoute(matchStore *store.MatchStore, from, into string, feeds []string) int {
+	var keep func(store.Entry) bool
+	if len(feeds) > 0 {
+		names := make(map[string]struct{}, len(feeds))
+		for _, f := range feeds {
+			names[strings.ToLower(f)] = struct{}{}
+		}
+		keep = func(e store.Entry) bool {
+			_, ok := names[strings.ToLower(e.Meta.Feed)]
+			return ok && e.Meta.Feed != ""
+		}
+	}
+	return matchStore.Clone(from, into, keep)
+}
+
+// runSplit implements `filter split`: with the bridge stopped, it seeds a route newly added
+// to the config from an existing one by copying consumer group offsets and cached values.
+func runSplit(defaultConfig string, args []string) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfig, "path to YAML config file containing both routes")
+	from := fs.String("from", "", "route key to split")
+	into := fs.String("into", "", "route key to seed")
+	feedList := fs.String("feeds", "", "comma-separated reference feed names to copy (default all)")
+	skipOffsets := fs.Bool("skip-offsets", false, "clone cached values only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *into == "" || *from == *into {
+		return errors.New("-from and -into must name two different routes")
+	}
+	var feeds []string
+	for _, f := range strings.Split(*feedList, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			feeds = append(feeds, f)
+		}
+	}
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		return err
+	}
+	var src, dst *config.Route
+	for i := range cfg.Routes {
+		switch routeKey(cfg.Routes[i]) {
+		case *from:
+			src = &cfg.Routes[i]
+		case *into:
+			dst = &cfg.Routes[i]
+		}
+	}
+	if src == nil || dst == nil {
+		return fmt.Errorf("routes %s and %s must both be defined in %s", *from, *into, *cfgPath)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	bridgeDialer, err := buildDialer(cfg.BridgeCluster, cfg.ClientID)
+	if err != nil {
+		return fmt.Errorf("bridge dialer: %w", err)
+	}
+	if !*skipOffsets {
+		if err := copyRouteOffsets(ctx, cfg, *src, *dst); err != nil {
+			return err
+		}
+	}
+
+	if src.Storage != nil || dst.Storage != nil {
+		return splitOwnStorage(cfg, *src, *dst, feeds)
+	}
+	switch cfg.Storage.Backend {
+	case config.StorageBackendKafka:
+		state := kafkapkg.NewStateTopic(cfg.BridgeCluster.Brokers, bridgeDialer, cfg.Storage.Topic)
+		defer state.Close()
+		matchStore := store.NewMatchStore()
+		if _, err := state.Restore(ctx, matchStore); err != nil {
+			return fmt.Errorf("restore state topic %s: %w", cfg.Storage.Topic, err)
+		}
+		matchStore.SetObserver(state.Record)
+		cloned := splitRoute(matchStore, *from, *into, feeds)
+		if err := state.Flush(ctx); err != nil {
+			return fmt.Errorf("publish cloned values: %w", err)
+		}
+		log.Printf("cloned %d value(s) from %s into %s on state topic %s", cloned, *from, *into, cfg.Storage.Topic)
+	case config.StorageBackendSQLite:
+		db, err := store.OpenSQLite(cfg.Storage.Path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		matchStore := store.NewMatchStore()
+		if _, err := db.Restore(ctx, matchStore); err != nil {
+			return fmt.Errorf("restore %s: %w", cfg.Storage.Path, err)
+		}
+		matchStore.SetObserver(db.Record)
+		cloned := splitRoute(matchStore, *from, *into, feeds)
+		if err := db.Flush(ctx); err != nil {
+			return fmt.Errorf("write cloned values: %w", err)
+		}
+		log.Printf("cloned %d value(s) from %s into %s in %s", cloned, *from, *into, cfg.Storage.Path)
+	default:
+		if cfg.Storage.Path == "" {
+			log.Printf("no storage configured; %s will rebuild its cache from its reference feeds", *into)
+			return nil
+		}
+		matchStore := store.NewMatchStore()
+		if err := loadSnapshot(cfg.Storage.Path, matchStore); err != nil {
+			return fmt.Errorf("load snapshot: %w", err)
+		}
+		if cfg.Storage.WAL {
+			if _, err := store.ReplayWAL(cfg.Storage.WALPath(), matchStore); err != nil {
+				return fmt.Errorf("replay wal: %w", err)
+			}
+		}
+		cloned := splitRoute(matchStore, *from, *into, feeds)
+		opts := store.SaveOptions{Gzip: cfg.Storage.Compression == config.StorageCompressionGzip}
+		if err := matchStore.SaveSnapshot(cfg.Storage.Path, opts); err != nil {
+			return fmt.Errorf("save snapshot: %w", err)
+		}
+		log.Printf("cloned %d value(s) from %s into %s in snapshot %s", cloned, *from, *into, cfg.Storage.Path)
+	}
+	return nil
+}
+
+// copyRouteOffsets starts dst's consumer groups where src's left off: the source group when
+// both routes read the same source topic or topic pattern, and the reference group for
+// every shared feed topic.
+func copyRouteOffsets(ctx context.Context, cfg *config.Config, src, dst config.Route) error {
+	sameSources := slices.Equal(src.Sources, dst.Sources) && len(src.SourceClusterNames()) == 1
+	if src.SourceCluster == dst.SourceCluster && src.SourceTopic == dst.SourceTopic && src.SourceTopicPattern == dst.SourceTopicPattern && sameSources {
+		sourceCluster, _ := cfg.SourceClusterByName(src.SourceCluster)
+		dialer, err := buildDialer(sourceCluster.ClusterConfig(), cfg.ClientID)
+		if err != nil {
+			return fmt.Errorf("source dialer %s: %w", sourceCluster.Name, err)
+		}
+		topics := src.SourceTopicsOn(src.SourceCluster)
+		if src.SourceTopicPattern != "" {
+			re, err := src.SourceTopicRegexp()
+			if err != nil {
+				return fmt.Errorf("sourceTopicPattern: %w", err)
+			}
+			if topics, err = kafkapkg.MatchingTopics(ctx, sourceCluster.Brokers, dialer, re); err != nil {
+				return err
+			}
+		}
+		n, err := kafkapkg.CopyGroupOffsets(ctx, sourceCluster.Brokers, dialer, sourceGroupID(sourceCluster, src), sourceGroupID(sourceCluster, dst), topics)
+		if err != nil {
+			return err
+		}
+		log.Printf("copied %d source offset(s) from %s to %s", n, sourceGroupID(sourceCluster, src), sourceGroupID(sourceCluster, dst))
+	} else {
+		log.Printf("warn: %s and %s read different source topics; source offsets not copied", routeKey(src), routeKey(dst))
+	}
+
+	shared := sharedTopics(referenceTopics(src.ReferenceFeeds), referenceTopics(dst.ReferenceFeeds))
+	if len(shared) == 0 {
+		return nil
+	}
+	dialer, err := buildDialer(cfg.BridgeCluster, cfg.ClientID)
+	if err != nil {
+		return fmt.Errorf("bridge dialer: %w", err)
+	}
+	n, err := kafkapkg.CopyGroupOffsets(ctx, cfg.BridgeCluster.Brokers, dialer, referenceGroupID(cfg, src), referenceGroupID(cfg, dst), shared)
+	if err != nil {
+		return err
+	}
+	log.Printf("copied %d reference offset(s) from %s to %s", n, referenceGroupID(cfg, src), referenceGroupID(cfg, dst))
+	return nil
+}
+
+func sharedTopics(a, b []string) []string {
+	inA := make(map[string]struct{}, len(a))
+	for _, t := range a {
+		inA[t] = struct{}{}
+	}
+	var out []string
+	for _, t := range b {
+		if _, ok := inA[t]; ok {
+			out = append(out, t)
+			delete(inA, t)
+		}
+	}
+	return out
+}
+
+// splitOwnStorage clones between routes when either has storage of its own. Route storage
+// is a snapshot file, so the other route must be kept in the global file storage too.
+func splitOwnStorage(cfg *config.Config, src, dst config.Route, feeds []string) error {
+	global := cfg.Storage.Backend == config.StorageBackendFile && cfg.Storage.Path != ""
+	if (src.Storage == nil || dst.Storage == nil) && !global {
+		return fmt.Errorf("routes %s and %s must both use file storage to be split", routeKey(src), routeKey(dst))
+	}
+	matchStore := store.NewMatchStore()
+	if global {
+		if err := loadSnapshot(cfg.Storage.Path, matchStore); err != nil {
+			return fmt.Errorf("load snapshot: %w", err)
+		}
+		if cfg.Storage.WAL {
+			if _, err := store.ReplayWAL(cfg.Storage.WALPath(), matchStore); err != nil {
+				return fmt.Errorf("replay wal: %w", err)
+			}
+		}
+	}
+	if err := restoreRouteStorages(cfg, matchStore, true); err != nil {
+		return err
+	}
+	from, into := routeKey(src), routeKey(dst)
+	cloned := splitRoute(matchStore, from, into, feeds)
+	path, compression, keep := cfg.Storage.Path, cfg.Storage.Compression, sharedStorageRoutes(cfg)
+	if st := dst.Storage; st != nil {
+		path, compression, keep = st.Path, st.Compression, func(id string) bool { return id == into }
+	}
+	opts := store.SaveOptions{Gzip: compression == config.StorageCompressionGzip}
+	if err := saveRoutes(matchStore, path, opts, keep); err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	log.Printf("cloned %d value(s) from %s into %s in snapshot %s", cloned, from, into, path)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteSplitEndpoint(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	feeds := []engine.Feed{
+		{Name: "feed-a", Topic: "ref-a", MatchFields: []string{"fieldA"}},
+		{Name: "feed-b", Topic: "ref-b", MatchFields: []string{"fieldB"}},
+	}
+	matchers := make(map[string]*engine.Matcher)
+	for _, id := range []string{"route-a", "route-b"} {
+		m, err := engine.NewMatcher(id, feeds, matchStore)
+		if err != nil {
+			t.Fatalf("NewMatcher error: %v", err)
+		}
+		matchers[id] = m
+	}
+	if _, err := matchers["route-a"].ProcessReference(engine.ReferenceMessage{Topic: "ref-a", Value: []byte(`{"fieldA":"from-a"}`)}); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+	if _, err := matchers["route-a"].ProcessReference(engine.ReferenceMessage{Topic: "ref-b", Value: []byte(`{"fieldB":"from-b"}`)}); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+	matchers["route-a"].AddValues([]string{"manual"})
+
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: matchers, store: matchStore}))
+	t.Cleanup(server.Close)
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{body: `{"into":"route-b","feeds":["FEED-A"]}`, status: http.StatusCreated},
+		{body: `{"into":"route-b","feeds":["feed-a"]}`, status: http.StatusOK},
+		{body: `{"into":"route-x"}`, status: http.StatusBadRequest},
+		{body: `{"into":"route-a"}`, status: http.StatusBadRequest},
+		{body: `{}`, status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		resp, err := http.Post(server.URL+"/routes/route-a/split", "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("POST split failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("body %s: expected status %d, got %d", tc.body, tc.status, resp.StatusCode)
+		}
+	}
+	if !matchStore.Contains("route-b", "from-a") || matchStore.Contains("route-b", "from-b") || matchStore.Contains("route-b", "manual") {
+		t.Fatalf("unexpected route-b cache: %v", matchStore.Snapshot()["route-b"])
+	}
+}
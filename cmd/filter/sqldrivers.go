@@ -0,0 +1,10 @@
+package main
+
+// Drivers available to referenceSQL. The pure-Go "sqlite" driver is always linked; link a
+// Postgres or MySQL driver by adding its blank import here, e.g.
+//
+//	_ "github.com/jackc/pgx/v5/stdlib" // registers "pgx"
+//	_ "github.com/go-sql-driver/mysql" // registers "mysql"
+import (
+	_ "modernc.org/sqlite" // registers "sqlite"
+)
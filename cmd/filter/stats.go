@@ -0,0 +1,331 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+)
+
+type statsRegistry struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+// route returns the counters for routeID, creating them on first use.
+func (r *statsRegistry) route(routeID string) *routeStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.routes[routeID]
+	if !ok {
+		s = &routeStats{}
+		r.routes[routeID] = s
+	}
+	return s
+}
+
+// routeStats counts what a route did with its source messages.
+type routeStats struct {
+	consumed     atomic.Uint64
+	forwarded    atomic.Uint64
+	skipped      atomic.Uint64
+	decodeErrors atomic.Uint64
+	writeErrors  atomic.Uint64
+	dropped      atomic.Uint64
+	tombstones   atomic.Uint64
+	oversized    atomic.Uint64
+	preempted    atomic.Uint64
+	duplicates   atomic.Uint64
+	// headerFiltered counts messages skipped by sourceHeaderFilters.
+	headerFiltered atomic.Uint64
+	// tooOld counts messages dropped for being older than maxMessageAge.
+	tooOld atomic.Uint64
+	// inFlight counts source messages fetched but not yet committed; maxInFlight is the
+	// route's configured bound.
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+	// inFlightBytes approximates the memory the in-flight messages hold.
+	inFlightBytes atomic.Int64
+
+	mu        sync.Mutex
+	startedAt time.Time
+	// countedSince is when the counters, restored under storage.persistCounters, started
+	// counting; zero when they count since startup.
+	countedSince  time.Time
+	lastForwarded *ForwardedPosition
+	// failure is why the route stopped streaming, if it did; collectorFailure why its
+	// reference collector stopped.
+	failure          string
+	collectorFailure string
+	// restarts and collectorRestarts count the supervisor's restarts of the route's
+	// stream and reference collector.
+	restarts          atomic.Uint64
+	collectorRestarts atomic.Uint64
+	// sources counts the messages of each source topic of a route with sources.
+	sources map[config.RouteSource]*sourceStats
+}
+
+// sourceStats counts what a multi-source route read from, and forwarded of, one source
+// topic.
+type sourceStats struct {
+	consumed  atomic.Uint64
+	forwarded atomic.Uint64
+}
+
+// source returns the counters of one source topic, creating them on first use.
+func (s *routeStats) source(cluster, topic string) *sourceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := config.RouteSource{Cluster: cluster, Topic: topic}
+	src, ok := s.sources[key]
+	if !ok {
+		if s.sources == nil {
+			s.sources = make(map[config.RouteSource]*sourceStats)
+		}
+		src = &sourceStats{}
+		s.sources[key] = src
+	}
+	return src
+}
+
+type sourceStatsKey struct{}
+
+// withSourceStats makes the forwards of messages handled under ctx count for src.
+func withSourceStats(ctx context.Context, src *sourceStats) context.Context {
+	return context.WithValue(ctx, sourceStatsKey{}, src)
+}
+
+// countSource counts msg for its source topic when route has sources, and returns the
+// context its forward is to be counted under.
+func countSource(ctx context.Context, route config.Route, stats *routeStats, msg kafka.Message) context.Context {
+	if len(route.Sources) == 0 {
+		return ctx
+	}
+	src := stats.source(route.SourceCluster, msg.Topic)
+	src.consumed.Add(1)
+	return withSourceStats(ctx, src)
+}
+
+// countSourceForward counts a forwarded message for the source topic ctx carries, if any.
+func countSourceForward(ctx context.Context) {
+	if src, ok := ctx.Value(sourceStatsKey{}).(*sourceStats); ok {
+		src.forwarded.Add(1)
+	}
+}
+
+// sourceReportsLocked lists the counters of every source topic, sorted by cluster and topic.
+func (s *routeStats) sourceReportsLocked() []SourceReport {
+	out := make([]SourceReport, 0, len(s.sources))
+	for key, src := range s.sources {
+		out = append(out, SourceReport{Cluster: key.Cluster, Topic: key.Topic, Consumed: src.consumed.Load(), Forwarded: src.forwarded.Load()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return cmp.Or(cmp.Compare(out[i].Cluster, out[j].Cluster), cmp.Compare(out[i].Topic, out[j].Topic)) < 0
+	})
+	return out
+}
+
+func (s *routeStats) start(now time.Time) {
+	s.mu.Lock()
+	s.startedAt = now
+	s.failure = ""
+	s.mu.Unlock()
+}
+
+// fail records that the route stopped streaming because of err.
+func (s *routeStats) fail(err error) {
+	s.mu.Lock()
+	s.failure = err.Error()
+	s.mu.Unlock()
+}
+
+// failWorker records that worker, stream or collector, stopped because of err.
+func (s *routeStats) failWorker(worker string, err error) {
+	if worker == workerStream {
+		s.fail(err)
+		return
+	}
+	s.mu.Lock()
+	s.collectorFailure = err.Error()
+	s.mu.Unlock()
+}
+
+// restart counts a restart of worker. The stream's failure is cleared once it streams
+// again; the collector's at once, as it reports no start of its own.
+func (s *routeStats) restart(worker string) {
+	if worker == workerStream {
+		s.restarts.Add(1)
+		return
+	}
+	s.collectorRestarts.Add(1)
+	s.mu.Lock()
+	s.collectorFailure = ""
+	s.mu.Unlock()
+}
+
+// state reports whether the route is starting, running, or stopped by an error.
+func (s *routeStats) state() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.failure != "":
+		return routeStateError, s.failure
+	case s.startedAt.IsZero():
+		return routeStateStarting, ""
+	}
+	return routeStateRunning, ""
+}
+
+func (s *routeStats) recordForward(partition int, offset int64, now time.Time) {
+	s.forwarded.Add(1)
+	s.mu.Lock()
+	s.lastForwarded = &ForwardedPosition{Partition: partition, Offset: offset, At: now}
+	s.mu.Unlock()
+}
+
+func (s *routeStats) report(routeID string, cached int, now time.Time) RouteStats {
+	resp := RouteStats{
+		Route:        routeID,
+		Consumed:     s.consumed.Load(),
+		Forwarded:    s.forwarded.Load(),
+		Skipped:      s.skipped.Load(),
+		DecodeErrors: s.decodeErrors.Load(),
+		WriteErrors:  s.writeErrors.Load(),
+		Dropped:      s.dropped.Load(),
+		Tombstones:   s.tombstones.Load(),
+		Oversized:    s.oversized.Load(),
+		Preempted:    s.preempted.Load(),
+		Duplicates:   s.duplicates.Load(),
+		InFlight:     s.inFlight.Load(),
+		CachedValues: cached,
+	}
+	resp.Restarts, resp.CollectorRestarts = s.restarts.Load(), s.collectorRestarts.Load()
+	resp.HeaderFiltered = s.headerFiltered.Load()
+	resp.TooOld = s.tooOld.Load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sources) > 0 {
+		resp.Sources = s.sourceReportsLocked()
+	}
+	if s.lastForwarded != nil {
+		last := *s.lastForwarded
+		resp.LastForwarded = &last
+	}
+	resp.CountedSince = s.countedSince
+	resp.StartedAt = s.startedAt
+	if !s.startedAt.IsZero() {
+		resp.UptimeSeconds = now.Sub(s.startedAt).Seconds()
+	}
+	return resp
+}
+
+// Route states reported by GET /routes.
+const (
+	routeStateStarting = "starting"
+	routeStateRunning  = "running"
+	routeStatePaused   = "paused"
+	routeStateError    = "error"
+)
+
+// routeInfos describes every configured route, sorted by route.
+func routeInfos(admin adminDeps, now time.Time) []RouteInfo {
+	routes := make([]string, 0, len(admin.matchers))
+	for id := range admin.matchers {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+	out := make([]RouteInfo, 0, len(routes))
+	for _, id := range routes {
+		stats := admin.routeCounters.route(id)
+		info := RouteInfo{RouteStats: stats.report(id, admin.store.Size(id), now)}
+		info.State, info.Error = stats.state()
+		stats.mu.Lock()
+		info.CollectorError = stats.collectorFailure
+		stats.mu.Unlock()
+		info.MemoryShedding = admin.memoryBudget.policy(id)
+		if admin.routeExpiries.paused(id) {
+			info.State = routeStatePaused
+		}
+		if route, ok := admin.routes[id]; ok {
+			describeRoute(&info, admin.cfg, route)
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+// describeRoute fills info from route's configuration. cfg supplies the consumer group IDs
+// and may be nil.
+func describeRoute(info *RouteInfo, cfg *config.Config, route config.Route) {
+	info.Name = route.Name
+	info.SourceCluster = route.SourceCluster
+	info.SourceTopic = route.SourceTopic
+	info.SourceTopicPattern = route.SourceTopicPattern
+	info.DestinationType = cmp.Or(route.Destination.Type, config.DestinationKafka)
+	info.Destination = destinationName(route)
+	for _, feed := range route.ReferenceFeeds {
+		info.ReferenceFeeds = append(info.ReferenceFeeds, RouteFeed{Name: feed.Name, Topic: feed.Topic, MatchFields: feed.MatchFields, PayloadFormat: feed.PayloadFormat})
+	}
+	if route.ReferenceHTTP != nil {
+		info.ReferenceHTTP = redactedURL(route.ReferenceHTTP.URL, "referenceHTTP")
+	}
+	if route.ReferenceSQL != nil {
+		info.ReferenceSQL = route.ReferenceSQL.Driver
+	}
+	if cfg == nil {
+		return
+	}
+	if sc, ok := cfg.SourceClusterByName(route.SourceCluster); ok {
+		info.SourceGroupID = sourceGroupID(sc, route)
+	}
+	if len(route.ReferenceFeeds) > 0 {
+		info.ReferenceGroupID = referenceGroupID(cfg, route)
+	}
+}
+
+// metrics reports the in-flight gauges and decode error counters of every route that has
+// started.
+func (r *statsRegistry) metrics() []metrics.Family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := make([]string, 0, len(r.routes))
+	for id := range r.routes {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+
+	inFlight := metrics.Family{Name: "kafka_bridge_route_in_flight", Help: "Source messages fetched but not yet written and committed.", Type: metrics.TypeGauge}
+	limit := metrics.Family{Name: "kafka_bridge_route_max_in_flight", Help: "Configured maxInFlight per route (0 = reader default).", Type: metrics.TypeGauge}
+	decodeErrors := metrics.Family{Name: "kafka_bridge_route_decode_errors_total", Help: "Source messages that could not be decompressed or decoded, whatever onDecodeError did with them.", Type: metrics.TypeCounter}
+	restarts := metrics.Family{Name: "kafka_bridge_route_restarts_total", Help: "Restarts of failed route streams and reference collectors.", Type: metrics.TypeCounter}
+	sourceConsumed := metrics.Family{Name: "kafka_bridge_source_consumed_total", Help: "Source messages read per source topic of routes with sources.", Type: metrics.TypeCounter}
+	sourceForwarded := metrics.Family{Name: "kafka_bridge_source_forwarded_total", Help: "Messages forwarded per source topic of routes with sources.", Type: metrics.TypeCounter}
+	for _, id := range routes {
+		labels := metrics.Labels{"route": id}
+		inFlight.Add(labels, float64(r.routes[id].inFlight.Load()))
+		limit.Add(labels, float64(r.routes[id].maxInFlight.Load()))
+		decodeErrors.Add(labels, float64(r.routes[id].decodeErrors.Load()))
+		restarts.Add(metrics.Labels{"route": id, "worker": workerStream}, float64(r.routes[id].restarts.Load()))
+		restarts.Add(metrics.Labels{"route": id, "worker": workerCollector}, float64(r.routes[id].collectorRestarts.Load()))
+		r.routes[id].mu.Lock()
+		sources := r.routes[id].sourceReportsLocked()
+		r.routes[id].mu.Unlock()
+		for _, src := range sources {
+			labels := metrics.Labels{"route": id, "cluster": src.Cluster, "topic": src.Topic}
+			sourceConsumed.Add(labels, float64(src.Consumed))
+			sourceForwarded.Add(labels, float64(src.Forwarded))
+		}
+	}
+	families := []metrics.Family{inFlight, limit, decodeErrors, restarts}
+	if len(sourceConsumed.Samples) > 0 {
+		families = append(families, sourceConsumed, sourceForwarded)
+	}
+	return families
+}
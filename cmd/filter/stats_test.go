@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestRouteStatsEndpoints(t *testing.T) {
+	b := newBridge(config.Logging{})
+	matchStore := store.NewMatchStore()
+	matcher, err := engine.NewMatcher("route-stats", []engine.Feed{{Name: "feed-a", Topic: "ref", MatchFields: []string{"fieldA"}}}, matchStore)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	matcher.AddValues([]string{"hit"})
+	route := config.Route{Name: "route-stats", DestinationTopic: "dest"}
+	stats := b.routeCounters.route("route-stats")
+	stats.start(time.Now().Add(-time.Minute))
+	policy := delivery.RetryPolicy{InitialBackoff: time.Millisecond, OnFailure: func(error) { stats.writeErrors.Add(1) }}
+	w := &recordingWriter{failures: 1}
+	for i, value := range []string{`{"x":"hit"}`, `{"x":"miss"}`, `{broken`} {
+		msg := kafka.Message{Partition: 2, Offset: int64(10 + i), Value: []byte(value)}
+		stats.consumed.Add(1)
+		if err := b.forwardMessage(context.Background(), route, loopGuard{}, headerRewriter{}, matcher, w, policy, msg); err != nil {
+			t.Fatalf("forwardMessage(%s): %v", value, err)
+		}
+	}
+
+	server := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-stats": matcher}, store: matchStore}))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/routes/route-stats/stats")
+	if err != nil {
+		t.Fatalf("GET stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var out RouteStats
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.Consumed != 3 || out.Forwarded != 1 || out.Skipped != 1 || out.DecodeErrors != 1 || out.WriteErrors != 1 || out.CachedValues != 1 {
+		t.Fatalf("unexpected stats: %+v", out)
+	}
+	if out.LastForwarded == nil || out.LastForwarded.Partition != 2 || out.LastForwarded.Offset != 10 {
+		t.Fatalf("unexpected last forwarded: %+v", out.LastForwarded)
+	}
+	if out.UptimeSeconds < 60 {
+		t.Fatalf("expected uptime of at least a minute, got %v", out.UptimeSeconds)
+	}
+
+	resp, err = http.Get(server.URL + "/routes")
+	if err != nil {
+		t.Fatalf("GET /routes failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var all []RouteStats
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(all) != 1 || all[0].Route != "route-stats" || all[0].Forwarded != 1 {
+		t.Fatalf("unexpected summary: %+v", all)
+	}
+
+	resp, err = http.Get(server.URL + "/routes/route-x/stats")
+	if err != nil {
+		t.Fatalf("GET unknown stats failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Workers of a route the supervisor restarts.
+const (
+	workerStream    = "stream"
+	workerCollector = "collector"
+)
+
+// Backoff between the restarts of a failed worker; variables so tests can shorten it.
+var (
+	restartBackoffInitial = time.Second
+	restartBackoffMax     = time.Minute
+)
+
+// restartResetAfter is how long a worker has to run for its next failure to be restarted
+// after the initial backoff again.
+const restartResetAfter = 5 * time.Minute
+
+type restartKey struct{ route, worker string }
+
+type restartRegistry struct {
+	mu     sync.Mutex
+	failed map[restartKey]string
+}
+
+func (r *restartRegistry) set(routeID, worker string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed[restartKey{routeID, worker}] = err.Error()
+}
+
+func (r *restartRegistry) clear(routeID, worker string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failed, restartKey{routeID, worker})
+}
+
+// snapshot lists the waiting workers sorted by route and worker.
+func (r *restartRegistry) snapshot() []RestartingWorker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.failed) == 0 {
+		return nil
+	}
+	out := make([]RestartingWorker, 0, len(r.failed))
+	for key, err := range r.failed {
+		out = append(out, RestartingWorker{Route: key.route, Worker: key.worker, Error: err})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Route != out[j].Route {
+			return out[i].Route < out[j].Route
+		}
+		return out[i].Worker < out[j].Worker
+	})
+	return out
+}
+
+// superviseRoute runs a worker of a route until it returns nil or ctx is done. When it
+// returns an error or panics, the failure is counted in the route's statistics and the
+// worker is restarted after a backoff that doubles up to restartBackoffMax, and starts
+// over once the worker has run for restartResetAfter.
+func (b *bridge) superviseRoute(ctx context.Context, routeID, name, worker string, run func(context.Context) error) {
+	stats := b.routeCounters.route(routeID)
+	backoff := restartBackoffInitial
+	for {
+		started := time.Now()
+		err := runRecovered(ctx, run)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= restartResetAfter {
+			backoff = restartBackoffInitial
+		}
+		stats.failWorker(worker, err)
+		b.routeRestarts.set(routeID, worker, err)
+		log.Printf("warn: route %s: %s failed, restarting in %s: %v", name, worker, backoff, err)
+		select {
+		case <-ctx.Done():
+			b.routeRestarts.clear(routeID, worker)
+			return
+		case <-time.After(backoff):
+		}
+		b.routeRestarts.clear(routeID, worker)
+		stats.restart(worker)
+		log.Printf("event: route %s: %s restarted", name, worker)
+		backoff = min(backoff*2, restartBackoffMax)
+	}
+}
+
+// runRecovered runs run, turning a panic into an error so one broken message or feed
+// cannot take the whole bridge down.
+func runRecovered(ctx context.Context, run func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
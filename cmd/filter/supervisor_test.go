@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-bridge/internal/config"
+)
+
+func TestSuperviseRouteRestartsFailedWorkers(t *testing.T) {
+	b := newBridge(config.Logging{})
+	initial := restartBackoffInitial
+	restartBackoffInitial = 20 * time.Millisecond
+	defer func() { restartBackoffInitial = initial }()
+
+	failed := make(chan struct{})
+	resume := make(chan struct{})
+	runs := 0
+	worker := func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			close(failed)
+			return errors.New("broker unavailable")
+		case 2:
+			<-resume
+			panic("bad record")
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.superviseRoute(context.Background(), "supervised", "supervised", workerStream, worker)
+	}()
+	<-failed
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if r := b.bridgeReadiness(); !r.Ready && len(r.Restarting) == 1 {
+			if w := r.Restarting[0]; w.Route != "supervised" || w.Worker != workerStream || w.Error != "broker unavailable" {
+				t.Fatalf("restarting = %+v", w)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the failed stream to keep the bridge unready")
+		}
+	}
+	close(resume)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the worker to be restarted until it returned nil")
+	}
+	stats := b.routeCounters.route("supervised").report("supervised", 0, time.Now())
+	if runs != 3 || stats.Restarts != 2 || stats.CollectorRestarts != 0 {
+		t.Fatalf("runs = %d, restarts = %d, collector restarts = %d", runs, stats.Restarts, stats.CollectorRestarts)
+	}
+	if state, detail := b.routeCounters.route("supervised").state(); state != routeStateError || detail != "panic: bad record" {
+		t.Fatalf("state = %s (%s), want the last failure until the stream starts again", state, detail)
+	}
+	if r := b.bridgeReadiness(); len(r.Restarting) != 0 {
+		t.Fatalf("expected no worker left restarting, got %+v", r.Restarting)
+	}
+}
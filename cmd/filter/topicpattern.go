@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/archive"
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+// errSourceTopicsChanged stops a pattern route's reader so it resubscribes to the
+// topics its sourceTopicPattern matches now.
+var errSourceTopicsChanged = errors.New("source topics changed")
+
+// streamTopicPattern streams every topic matching the route's sourceTopicPattern,
+// resubscribing whenever topics matching it are created or deleted. Topics that appear
+// while the route runs are read from the start, since everything in them is new.
+func (b *bridge) streamTopicPattern(ctx context.Context, cfg *config.Config, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer, writers *delivery.Pool, matchStore *store.MatchStore, matcher *engine.Matcher) error {
+	re, err := route.SourceTopicRegexp()
+	if err != nil {
+		return fmt.Errorf("sourceTopicPattern: %w", err)
+	}
+	var known []string
+	var subscribedAt time.Time
+	for {
+		topics, err := waitForTopics(ctx, route, sourceCluster, dialer, re)
+		if err != nil {
+			return err
+		}
+		groupID := sourceGroupID(sourceCluster, route)
+		for _, topic := range topics {
+			if known == nil || slices.Contains(known, topic) {
+				continue
+			}
+			if _, err := kafkapkg.SeedGroupOffsetsAt(ctx, sourceCluster.Brokers, dialer, groupID, topic, subscribedAt); err != nil {
+				return fmt.Errorf("seed offsets of new topic %s: %w", topic, err)
+			}
+		}
+		known, subscribedAt = topics, time.Now()
+
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		go watchTopics(streamCtx, cancel, route, sourceCluster, dialer, re, topics)
+		err = b.streamTopics(streamCtx, cfg, route, topics, sourceCluster, dialer, writers, matchStore, matcher)
+		changed := errors.Is(context.Cause(streamCtx), errSourceTopicsChanged)
+		cancel(nil)
+		if !changed || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("route %s: topics matching %s changed; resubscribing", route.DisplayName(), route.SourceLabel())
+	}
+}
+
+// waitForTopics returns the topics re matches, polling until there is at least one.
+func waitForTopics(ctx context.Context, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer, re *regexp.Regexp) ([]string, error) {
+	warned := false
+	for {
+		topics, err := kafkapkg.MatchingTopics(ctx, sourceCluster.Brokers, dialer, re)
+		if err != nil {
+			return nil, fmt.Errorf("list topics matching %s: %w", route.SourceLabel(), err)
+		}
+		if len(topics) > 0 {
+			return topics, nil
+		}
+		if !warned {
+			log.Printf("warn: route %s: no topic on %s matches %s yet", route.DisplayName(), sourceCluster.Name, route.SourceLabel())
+			warned = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(route.TopicRefreshInterval):
+		}
+	}
+}
+
+// watchTopics cancels ctx with errSourceTopicsChanged once the topics re matches differ
+// from topics. Failed lookups are logged and retried on the next tick.
+func watchTopics(ctx context.Context, cancel context.CancelCauseFunc, route config.Route, sourceCluster config.SourceCluster, dialer *kafka.Dialer, re *regexp.Regexp, topics []string) {
+	ticker := time.NewTicker(route.TopicRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := kafkapkg.MatchingTopics(ctx, sourceCluster.Brokers, dialer, re)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("route %s: list topics matching %s: %v", route.DisplayName(), route.SourceLabel(), err)
+			}
+			continue
+		}
+		if len(current) > 0 && !slices.Equal(current, topics) {
+			cancel(errSourceTopicsChanged)
+			return
+		}
+	}
+}
+
+// topicDestinations resolves a templated destinationTopic per source topic. Each source
+// topic gets a copy of the route naming its own destination topic, and a writer for it.
+type topicDestinations struct {
+	route   config.Route
+	re      *regexp.Regexp
+	bridge  *bridge
+	writers *delivery.Pool
+	sink    *archive.Sink
+	byTopic map[string]topicDestination
+}
+
+type topicDestination struct {
+	route  config.Route
+	writer delivery.MessageWriter
+}
+
+func (b *bridge) newTopicDestinations(route config.Route, writers *delivery.Pool, sink *archive.Sink) (*topicDestinations, error) {
+	re, err := route.SourceTopicRegexp()
+	if err != nil {
+		return nil, fmt.Errorf("sourceTopicPattern: %w", err)
+	}
+	return &topicDestinations{bridge: b, route: route, re: re, writers: writers, sink: sink, byTopic: make(map[string]topicDestination)}, nil
+}
+
+// forTopic returns the route and writer that messages read from sourceTopic go to.
+func (d *topicDestinations) forTopic(sourceTopic string) (config.Route, delivery.MessageWriter, error) {
+	if dest, ok := d.byTopic[sourceTopic]; ok {
+		return dest.route, dest.writer, nil
+	}
+	topic, err := d.route.DestinationTopicFor(d.re, sourceTopic)
+	if err != nil {
+		return config.Route{}, nil, err
+	}
+	if topic == "" || strings.ContainsFunc(topic, func(c rune) bool { return !validTopicChar(c) }) {
+		return config.Route{}, nil, fmt.Errorf("destinationTopic %q expands to %q for %s, which is not a valid topic name", d.route.DestinationTopic, topic, sourceTopic)
+	}
+	route := d.route
+	route.DestinationTopic = topic
+	writer, err := d.bridge.newDestination(route, d.writers, d.sink)
+	if err != nil {
+		return config.Route{}, nil, err
+	}
+	d.byTopic[sourceTopic] = topicDestination{route: route, writer: writer}
+	log.Printf("route %s maps source topic %s to %s", route.DisplayName(), sourceTopic, topic)
+	return route, writer, nil
+}
+
+// validTopicChar reports whether Kafka allows c in a topic name.
+func validTopicChar(c rune) bool {
+	return c == '.' || c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/delivery"
+)
+
+func TestTopicDestinationsExpandPerSourceTopic(t *testing.T) {
+	b := newBridge(config.Logging{})
+	route := config.Route{Name: "orders", SourceCluster: "a", SourceTopicPattern: `orders\.(?P<region>[a-z]+)`, DestinationTopic: "filtered.${region}"}
+	writers := delivery.NewPool([]string{"bridge:9092"}, nil)
+	defer writers.Close()
+	dests, err := b.newTopicDestinations(route, writers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eu, euWriter, err := dests.forTopic("orders.eu")
+	if err != nil || eu.DestinationTopic != "filtered.eu" || routeKey(eu) != routeKey(route) {
+		t.Fatalf("forTopic(orders.eu) = %s, %v", eu.DestinationTopic, err)
+	}
+	again, againWriter, _ := dests.forTopic("orders.eu")
+	if again.DestinationTopic != eu.DestinationTopic || againWriter != euWriter {
+		t.Fatal("expected the cached destination on the second lookup")
+	}
+	if us, _, _ := dests.forTopic("orders.us"); us.DestinationTopic != "filtered.us" {
+		t.Fatalf("forTopic(orders.us) = %s", us.DestinationTopic)
+	}
+	if _, _, err := dests.forTopic("payments.eu"); err == nil {
+		t.Fatal("expected an error for a topic the pattern does not match")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	kafkapkg "kafka-bridge/internal/kafka"
+	"kafka-bridge/pkg/store"
+)
+
+// Modes accepted by POST /cache/import.
+const (
+	importMerge   = "merge"
+	importReplace = "replace"
+)
+
+// importChunk bounds the values carried by one admin command, so imports broadcast to
+// peers stay well below the coordination topic's message size limit.
+const importChunk = 10000
+
+// registerCacheTransfer mounts GET /cache/export and POST /cache/import, which move the
+// cached canonical values between bridges in the snapshot file format.
+func registerCacheTransfer(mux *http.ServeMux, admin adminDeps) {
+	mux.HandleFunc("/cache/export", admin.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		snapshot := admin.store.CanonicalSnapshot()
+		if routeID := r.URL.Query().Get("route"); routeID != "" {
+			if _, ok := admin.matchers[routeID]; !ok {
+				http.Error(w, "route not found", http.StatusNotFound)
+				return
+			}
+			snapshot = map[string][]string{routeID: snapshot[routeID]}
+		}
+		for _, values := range snapshot {
+			sort.Strings(values)
+		}
+		opts := store.SaveOptions{}
+		switch compression := r.URL.Query().Get("compression"); compression {
+		case "", "none":
+		case "gzip":
+			opts.Gzip = true
+		default:
+			http.Error(w, fmt.Sprintf("unknown compression %q (want none or gzip)", compression), http.StatusBadRequest)
+			return
+		}
+		name := "cache.json"
+		w.Header().Set("Content-Type", "application/json")
+		if opts.Gzip {
+			name = "cache.json.gz"
+			w.Header().Set("Content-Type", "application/gzip")
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		if err := store.WriteSnapshot(w, snapshot, opts); err != nil {
+			log.Printf("cache export failed: %v", err)
+		}
+	}))
+	mux.HandleFunc("/cache/import", admin.mutating(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mode := r.URL.Query().Get("mode")
+		switch mode {
+		case "":
+			mode = importMerge
+		case importMerge, importReplace:
+		default:
+			http.Error(w, fmt.Sprintf("unknown mode %q (want merge or replace)", mode), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		snapshot, err := store.ReadSnapshot(r.Body)
+		if err != nil {
+			http.Error(w, "invalid snapshot: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := admin.importSnapshot(r, snapshot, mode)
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		log.Printf("cache import via HTTP (%s): added=%d removed=%d skipped=%v", mode, result.Added, result.Removed, result.Skipped)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Printf("import result encode failed: %v", err)
+		}
+	}))
+}
+
+// importSnapshot injects the snapshot's values into the configured routes through admin
+// commands, so peers apply them too. Under replace, every configured route also loses
+// the canonical values the snapshot does not list for it.
+func (a adminDeps) importSnapshot(r *http.Request, snapshot map[string][]string, mode string) (ImportResult, error) {
+	result := ImportResult{Mode: mode}
+	routeIDs := make([]string, 0, len(a.matchers))
+	for id := range a.matchers {
+		routeIDs = append(routeIDs, id)
+	}
+	sort.Strings(routeIDs)
+	for id := range snapshot {
+		if _, ok := a.matchers[id]; !ok {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+	sort.Strings(result.Skipped)
+
+	key := r.Header.Get(idempotencyHeader)
+	existing := a.store.CanonicalSnapshot()
+	for _, id := range routeIDs {
+		current := existing[id]
+		cached := make(map[string]struct{}, len(current))
+		for _, v := range current {
+			cached[v] = struct{}{}
+		}
+		// values are compared as the route caches them, hashed on routes that hash values
+		wanted := make(map[string]struct{}, len(snapshot[id]))
+		var add []string
+		for _, v := range snapshot[id] {
+			fp := a.matchers[id].Fingerprint(v)
+			wanted[fp] = struct{}{}
+			if _, ok := cached[fp]; !ok {
+				add = append(add, v)
+			}
+		}
+		var remove []string
+		if mode == importReplace {
+			for _, v := range current {
+				if _, ok := wanted[v]; !ok {
+					remove = append(remove, v)
+				}
+			}
+		}
+		sort.Strings(remove)
+		for _, batch := range []struct {
+			op     string
+			values []string
+		}{{kafkapkg.CommandDelete, remove}, {kafkapkg.CommandInject, add}} {
+			for start := 0; start < len(batch.values); start += importChunk {
+				chunk := batch.values[start:min(start+importChunk, len(batch.values))]
+				cmdKey := ""
+				if key != "" {
+					cmdKey = key + "/" + id + "/" + batch.op + "/" + strconv.Itoa(start)
+				}
+				cmd := kafkapkg.Command{Op: batch.op, Route: id, Values: chunk, Annotations: map[string]string{"source": "import"}}
+				if _, err := a.submit(r.Context(), cmdKey, cmd); err != nil {
+					return result, fmt.Errorf("route %s: %w", id, err)
+				}
+			}
+		}
+		result.Added += len(add)
+		result.Removed += len(remove)
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func TestCacheExportImportEndpoints(t *testing.T) {
+	b := newBridge(config.Logging{})
+	newRoute := func(s *store.MatchStore) *engine.Matcher {
+		m, err := engine.NewMatcher("route-a", []engine.Feed{{Topic: "ref", MatchFields: []string{"id"}}}, s)
+		if err != nil {
+			t.Fatalf("NewMatcher error: %v", err)
+		}
+		return m
+	}
+	srcStore := store.NewMatchStore()
+	newRoute(srcStore).AddValues([]string{"one", "two"})
+	src := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-a": newRoute(srcStore)}, store: srcStore}))
+	t.Cleanup(src.Close)
+
+	resp, err := http.Get(src.URL + "/cache/export?compression=gzip")
+	if err != nil {
+		t.Fatalf("GET /cache/export failed: %v", err)
+	}
+	exported, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/gzip" {
+		t.Fatalf("export status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	dstStore := store.NewMatchStore()
+	newRoute(dstStore).AddValues([]string{"stale"})
+	dst := httptest.NewServer(buildHTTPMux(adminDeps{bridge: b, matchers: map[string]*engine.Matcher{"route-a": newRoute(dstStore)}, store: dstStore}))
+	t.Cleanup(dst.Close)
+	post := func(mode string, body []byte) ImportResult {
+		t.Helper()
+		resp, err := http.Post(dst.URL+"/cache/import?mode="+mode, "application/gzip", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /cache/import failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := io.ReadAll(resp.Body)
+			t.Fatalf("import status %d: %s", resp.StatusCode, msg)
+		}
+		var out ImportResult
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode import result: %v", err)
+		}
+		return out
+	}
+
+	if out := post("merge", exported); out.Added != 2 || out.Removed != 0 || !dstStore.Contains("route-a", "stale") {
+		t.Fatalf("merge = %+v", out)
+	}
+	var snapshot bytes.Buffer
+	if err := store.WriteSnapshot(&snapshot, map[string][]string{"route-a": {"one"}, "unknown": {"x"}}, store.SaveOptions{}); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	out := post("replace", snapshot.Bytes())
+	if out.Added != 0 || out.Removed != 2 || len(out.Skipped) != 1 || out.Skipped[0] != "unknown" {
+		t.Fatalf("replace = %+v", out)
+	}
+	if dstStore.Size("route-a") != 1 || !dstStore.Contains("route-a", "one") {
+		t.Fatalf("unexpected cache after replace: %v", dstStore.Snapshot())
+	}
+
+	resp, err = http.Post(dst.URL+"/cache/import", "application/json", strings.NewReader(`{"format":"kafka-bridge-snapshot","version":1,"checksum":"sha256:00","routes":{}}`))
+	if err != nil {
+		t.Fatalf("POST /cache/import failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a corrupt snapshot, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+)
+
+// Check statuses reported by `filter validate`.
+const (
+	checkOK    = "ok"
+	checkWarn  = "warn"
+	checkError = "error"
+)
+
+type validationReport struct {
+	Config string            `json:"config"`
+	Valid  bool              `json:"valid"`
+	Checks []validationCheck `json:"checks"`
+}
+
+type validationCheck struct {
+	// Name identifies what was checked, e.g. config, cluster/bridge, or topic/bridge/ref-a.
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (r *validationReport) add(name, status, detail string) {
+	r.Checks = append(r.Checks, validationCheck{Name: name, Status: status, Detail: detail})
+	if status == checkError {
+		r.Valid = false
+	}
+}
+
+// clusterProbe describes topics and groups on one cluster; kafkapkg.CheckCluster in production.
+type clusterProbe func(ctx context.Context, brokers []string, dialer *kafka.Dialer, topics, groups []string) (kafkapkg.ClusterCheck, error)
+
+// runValidate implements `filter validate`: it loads and validates the config and, with
+// -connect, dials every cluster to check the topics and consumer groups the routes use.
+// The report is printed as JSON and an invalid config returns an error.
+func runValidate(defaultConfig string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfig, "path to YAML config file")
+	connect := fs.Bool("connect", false, "dial each cluster and check topics, groups, and ACLs")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout per cluster when -connect is set")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report := validationReport{Config: *cfgPath, Valid: true}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		report.add("config", checkError, err.Error())
+	} else {
+		report.add("config", checkOK, "")
+		for _, w := range cfg.Lint() {
+			report.add("lint/"+w.Path, checkWarn, w.Message)
+		}
+		for _, route := range cfg.Routes {
+			if at, ok := route.Expiry(); ok && !time.Now().Before(at) {
+				report.add("route/"+routeKey(route), checkWarn, fmt.Sprintf("expired at %s; the route will stay paused", at.Format(time.RFC3339)))
+			}
+		}
+		for _, p := range cfg.Pipelines {
+			// validated by config, so the order has no cycle
+			order, _ := cfg.PipelineOrder(p)
+			report.add("pipeline/"+p.Name, checkOK, "start order: "+strings.Join(order, " -> "))
+		}
+		if *connect {
+			checkClusters(context.Background(), cfg, *timeout, kafkapkg.CheckCluster, &report)
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return errors.New("config is invalid")
+	}
+	return nil
+}
+
+// clusterResources lists what one cluster must serve. Topics that are required fail the
+// report when missing; optional ones are created by the bridge and only warn.
+type clusterResources struct {
+	name     string
+	cluster  config.ClusterConfig
+	required map[string]struct{}
+	optional map[string]string
+	groups   map[string]struct{}
+}
+
+func checkClusters(ctx context.Context, cfg *config.Config, timeout time.Duration, probe clusterProbe, report *validationReport) {
+	bridge := &clusterResources{name: "bridge", cluster: cfg.BridgeCluster, required: map[string]struct{}{}, optional: map[string]string{}, groups: map[string]struct{}{}}
+	clusters := []*clusterResources{}
+	sources := make(map[string]*clusterResources, len(cfg.SourceClusters))
+	for _, sc := range cfg.SourceClusters {
+		res := &clusterResources{name: sc.Name, cluster: sc.ClusterConfig(), required: map[string]struct{}{}, optional: map[string]string{}, groups: map[string]struct{}{}}
+		sources[sc.Name] = res
+		clusters = append(clusters, res)
+	}
+	clusters = append(clusters, bridge)
+
+	for _, route := range cfg.Routes {
+		for _, name := range route.SourceClusterNames() {
+			sc, _ := cfg.SourceClusterByName(name)
+			src := sources[name]
+			// a pattern may match no topic yet; the route waits for one
+			for _, topic := range route.SourceTopicsOn(name) {
+				if topic != "" {
+					src.required[topic] = struct{}{}
+				}
+			}
+			src.groups[sourceGroupID(sc, route)] = struct{}{}
+		}
+		for _, topic := range referenceTopics(route.ReferenceFeeds) {
+			bridge.required[topic] = struct{}{}
+		}
+		bridge.groups[referenceGroupID(cfg, route)] = struct{}{}
+		if route.Destination.Type != config.DestinationWebhook && !route.DestinationTemplated() {
+			bridge.optional[route.DestinationTopic] = "destination topic"
+		}
+		if route.Delivery.Oversize == config.OversizeDeadLetter {
+			bridge.optional[route.Delivery.DeadLetterTopic] = "dead-letter topic"
+		}
+	}
+	if cfg.Storage.Backend == config.StorageBackendKafka {
+		bridge.optional[cfg.Storage.Topic] = "state topic"
+	}
+	if cfg.Coordination.Topic != "" {
+		bridge.optional[cfg.Coordination.Topic] = "coordination topic"
+	}
+	if le := cfg.Coordination.LeaderElection; le.Enabled {
+		bridge.optional[le.Topic] = "leader election topic"
+		bridge.groups[le.GroupID] = struct{}{}
+	}
+
+	for _, res := range clusters {
+		checkCluster(ctx, cfg.ClientID, res, timeout, probe, report)
+	}
+}
+
+func checkCluster(ctx context.Context, clientID string, res *clusterResources, timeout time.Duration, probe clusterProbe, report *validationReport) {
+	name := "cluster/" + res.name
+	dialer, err := buildDialer(res.cluster, clientID)
+	if err != nil {
+		report.add(name, checkError, err.Error())
+		return
+	}
+	topics := make([]string, 0, len(res.required)+len(res.optional))
+	for topic := range res.required {
+		topics = append(topics, topic)
+	}
+	for topic := range res.optional {
+		if _, ok := res.required[topic]; !ok {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	groups := make([]string, 0, len(res.groups))
+	for group := range res.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	check, err := probe(ctx, res.cluster.Brokers, dialer, topics, groups)
+	if err != nil {
+		report.add(name, checkError, describeKafkaError(err, "cluster"))
+		return
+	}
+	report.add(name, checkOK, "")
+
+	for _, topic := range topics {
+		name := fmt.Sprintf("topic/%s/%s", res.name, topic)
+		err := check.Topics[topic]
+		_, required := res.required[topic]
+		switch {
+		case err == nil:
+			report.add(name, checkOK, "")
+		case !required && errors.Is(err, kafka.UnknownTopicOrPartition):
+			report.add(name, checkWarn, fmt.Sprintf("%s does not exist yet; the bridge creates it, which needs CREATE on the cluster or topic", res.optional[topic]))
+		default:
+			report.add(name, checkError, describeKafkaError(err, "topic "+topic))
+		}
+	}
+	for _, group := range groups {
+		name := fmt.Sprintf("group/%s/%s", res.name, group)
+		if err := check.Groups[group]; err != nil {
+			report.add(name, checkError, describeKafkaError(err, "group "+group))
+			continue
+		}
+		report.add(name, checkOK, "")
+	}
+}
+
+// describeKafkaError explains the errors most often caused by missing ACLs or credentials.
+func describeKafkaError(err error, resource string) string {
+	switch {
+	case errors.Is(err, kafka.TopicAuthorizationFailed):
+		return fmt.Sprintf("not authorized to describe %s: grant DESCRIBE plus READ (source and reference topics) or WRITE (destinations) to the bridge principal", resource)
+	case errors.Is(err, kafka.GroupAuthorizationFailed):
+		return fmt.Sprintf("not authorized for %s: grant DESCRIBE and READ on the consumer group to the bridge principal", resource)
+	case errors.Is(err, kafka.ClusterAuthorizationFailed):
+		return fmt.Sprintf("not authorized for %s: the operation needs a cluster-level ACL", resource)
+	case errors.Is(err, kafka.SASLAuthenticationFailed):
+		return fmt.Sprintf("SASL authentication failed: check the credentials configured for the %s", resource)
+	case errors.Is(err, kafka.UnknownTopicOrPartition):
+		return fmt.Sprintf("%s does not exist", resource)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("timed out reaching %s: check brokers, network access, and TLS settings", resource)
+	}
+	return err.Error()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+)
+
+func TestCheckClustersReport(t *testing.T) {
+	cfg := &config.Config{
+		SourceClusters:   []config.SourceCluster{{Name: "source-a", Brokers: []string{"source:9092"}, SourceGroupID: "src"}},
+		BridgeCluster:    config.ClusterConfig{Brokers: []string{"bridge:9092"}},
+		ClientID:         "bridge",
+		ReferenceGroupID: "ref",
+		Routes: []config.Route{{
+			Name:             "route-a",
+			SourceCluster:    "source-a",
+			SourceTopic:      "orders",
+			DestinationTopic: "orders-filtered",
+			ReferenceFeeds:   []config.ReferenceFeed{{Name: "feed", Topic: "customers", MatchFields: []string{"id"}}},
+		}},
+	}
+	probe := func(_ context.Context, brokers []string, _ *kafka.Dialer, topics, groups []string) (kafkapkg.ClusterCheck, error) {
+		check := kafkapkg.ClusterCheck{Topics: map[string]error{}, Groups: map[string]error{}}
+		switch brokers[0] {
+		case "source:9092":
+			check.Topics["orders"] = kafka.TopicAuthorizationFailed
+			check.Groups["src-route-a"] = nil
+		case "bridge:9092":
+			check.Topics["customers"] = nil
+			check.Topics["orders-filtered"] = kafka.UnknownTopicOrPartition
+			check.Groups["ref-route-a"] = kafka.GroupAuthorizationFailed
+		}
+		return check, nil
+	}
+
+	report := validationReport{Valid: true}
+	checkClusters(context.Background(), cfg, time.Second, probe, &report)
+	got := make(map[string]string)
+	for _, c := range report.Checks {
+		got[c.Name] = c.Status
+		if c.Status == checkError && !strings.Contains(c.Detail, "grant") {
+			t.Fatalf("expected an ACL hint for %s, got %q", c.Name, c.Detail)
+		}
+	}
+	want := map[string]string{
+		"cluster/source-a":             checkOK,
+		"topic/source-a/orders":        checkError,
+		"group/source-a/src-route-a":   checkOK,
+		"cluster/bridge":               checkOK,
+		"topic/bridge/customers":       checkOK,
+		"topic/bridge/orders-filtered": checkWarn,
+		"group/bridge/ref-route-a":     checkError,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected checks: %v", report.Checks)
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Fatalf("%s: status %q, want %q (all: %v)", name, got[name], status, report.Checks)
+		}
+	}
+	if report.Valid {
+		t.Fatalf("expected report to be invalid")
+	}
+}
+
+func TestRunValidateReportsConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("clientId: bridge\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runValidate(path, nil, &out); err == nil {
+		t.Fatalf("expected invalid config error")
+	}
+	var report validationReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, out.String())
+	}
+	if report.Valid || len(report.Checks) != 1 || report.Checks[0].Name != "config" || report.Checks[0].Status != checkError {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestRunValidateReportsLintWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := `
+clientId: bridge
+referenceGroupId: refs
+bridgeCluster:
+  brokers: ["b:9092"]
+sourceClusters:
+  - name: a
+    brokers: ["a:9092"]
+    sourceGroupId: src
+routes:
+  - sourceCluster: a
+    sourceTopic: in
+    destinationTopic: out
+    referenceFeeds:
+      - name: f
+        topic: ref
+        matchFields: [id]
+`
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runValidate(path, nil, &out); err != nil {
+		t.Fatalf("runValidate: %v\n%s", err, out.String())
+	}
+	var report validationReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, out.String())
+	}
+	warned := make(map[string]string)
+	for _, check := range report.Checks {
+		warned[check.Name] = check.Status
+	}
+	if !report.Valid || warned["lint/storage.path"] != checkWarn || warned["lint/routes[0].referenceFeeds"] != checkWarn {
+		t.Fatalf("expected a valid report with lint warnings, got %+v", report)
+	}
+}
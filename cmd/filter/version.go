@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/metrics"
+)
+
+// Build information, set with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// When commit is not set, it and buildDate are read from the VCS stamp go build embeds.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// authModes are the cluster authentication mechanisms this build supports.
+var authModes = []string{"plaintext", "tls", "mtls", "sasl-oauthbearer"}
+
+// buildVersion returns the build information without features.
+func buildVersion() VersionInfo {
+	info := VersionInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if info.Commit != "" {
+		return info
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		dirty := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+		if dirty && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	return info
+}
+
+// String renders the build information on one line, as `filter -version` prints it.
+func (v VersionInfo) String() string {
+	s := "kafka-bridge " + v.Version
+	if v.Commit != "" {
+		s += " (commit " + v.Commit
+		if v.BuildDate != "" {
+			s += ", built " + v.BuildDate
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s %s %s/%s", s, v.GoVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// features reports the features of the bridge a serves; nil without a config.
+func (a adminDeps) features() *Features {
+	cfg := a.cfg
+	if cfg == nil {
+		return nil
+	}
+	storage := cfg.Storage.Backend
+	if storage == "" || storage == config.StorageBackendFile && cfg.Storage.Path == "" {
+		storage = "memory"
+	}
+	return &Features{
+		AuthModes:      authModes,
+		StorageBackend: storage,
+		AdminToken:     a.adminToken != "",
+		ReadOnlyAdmin:  a.readOnly,
+		GRPC:           cfg.GRPC.ListenAddr != "",
+		Coordination:   cfg.Coordination.Topic != "",
+		LeaderElection: a.electing,
+		SchemaDrift:    a.schema != nil,
+		Watchdog:       cfg.Watchdog.Enabled,
+		Audit:          a.audit != nil,
+		Debug:          a.debug,
+		Mock:           a.memoryBroker != nil,
+	}
+}
+
+// registerVersion mounts /version.
+func registerVersion(mux *http.ServeMux, admin adminDeps) {
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		info := buildVersion()
+		info.Features = admin.features()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			log.Printf("version encode failed: %v", err)
+		}
+	})
+}
+
+// buildInfoMetrics exports the build information as the labels of a constant gauge.
+func buildInfoMetrics() []metrics.Family {
+	info := buildVersion()
+	family := metrics.Family{Name: "kafka_bridge_build_info", Help: "Always 1; labelled with the version and commit of the running build.", Type: metrics.TypeGauge}
+	family.Add(metrics.Labels{"version": info.Version, "commit": info.Commit, "goversion": info.GoVersion}, 1)
+	return []metrics.Family{family}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/pkg/store"
+)
+
+func TestVersionEndpointReportsFeatures(t *testing.T) {
+	b := newBridge(config.Logging{})
+	defer func(v, c string) { version, commit = v, c }(version, commit)
+	version, commit = "1.2.3", "abc123"
+	cfg := &config.Config{Storage: config.Storage{Backend: config.StorageBackendFile}, GRPC: config.GRPCServer{ListenAddr: ":9090"}}
+	mux := buildHTTPMux(adminDeps{bridge: b, cfg: cfg, store: store.NewMatchStore(), adminToken: "secret", readOnly: true})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info VersionInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("version is not JSON: %v\n%s", err, rec.Body)
+	}
+	if info.Version != "1.2.3" || info.Commit != "abc123" || info.GoVersion == "" {
+		t.Fatalf("unexpected build info: %+v", info)
+	}
+	f := info.Features
+	if f == nil || f.StorageBackend != "memory" || !f.AdminToken || !f.ReadOnlyAdmin || !f.GRPC || f.Mock || len(f.AuthModes) == 0 {
+		t.Fatalf("unexpected features: %+v", f)
+	}
+	if s := info.String(); !strings.HasPrefix(s, "kafka-bridge 1.2.3 (commit abc123") {
+		t.Fatalf("unexpected version line %q", s)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `kafka_bridge_build_info{commit="abc123"`) {
+		t.Fatalf("build info metric missing:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+)
+
+// warmupPollInterval is how often a warming route measures its reference lag.
+const warmupPollInterval = time.Second
+
+type warmupRegistry struct {
+	mu  sync.Mutex
+	lag map[string]int64
+}
+
+func (r *warmupRegistry) set(routeID string, lag int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lag[routeID] = lag
+}
+
+func (r *warmupRegistry) clear(routeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lag, routeID)
+}
+
+func (r *warmupRegistry) snapshot() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lag) == 0 {
+		return nil
+	}
+	return maps.Clone(r.lag)
+}
+
+// referenceLag counts the records of the route's reference topics its reference group
+// has not consumed yet. A partition the group has never committed on a cluster starts at
+// its end and so has no backlog; on the in-memory broker it starts at its beginning.
+func (b *bridge) referenceLag(ctx context.Context, cfg *config.Config, route config.Route, dialer *kafka.Dialer) (int64, error) {
+	var topics []string
+	for _, topic := range referenceTopics(route.ReferenceFeeds) {
+		if topic != "" && !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	group, end := referenceGroupID(cfg, route), kafkapkg.Bound{Offset: kafka.LastOffset}
+	var resets []kafkapkg.OffsetReset
+	if broker := b.memoryBroker; broker != nil {
+		resets = broker.PlanGroupSeek(group, topics, end)
+	} else {
+		var err error
+		if resets, err = kafkapkg.PlanGroupSeek(ctx, cfg.BridgeCluster.Brokers, dialer, group, topics, end); err != nil {
+			return 0, err
+		}
+	}
+	var lag int64
+	for _, r := range resets {
+		from := r.From
+		if from < 0 {
+			from = r.To
+			if b.memoryBroker != nil {
+				from = 0
+			}
+		}
+		lag += max(r.To-from, 0)
+	}
+	return lag, nil
+}
+
+// awaitReferenceWarmup blocks until the route's reference group is at most
+// referenceWarmup.maxLag records behind, or referenceWarmup.timeout has passed, so the
+// route does not drop matches for values its collector has not read yet. A failed lag
+// check counts as not caught up. It returns an error only when ctx is done.
+func (b *bridge) awaitReferenceWarmup(ctx context.Context, cfg *config.Config, route config.Route, dialer *kafka.Dialer) error {
+	w := route.ReferenceWarmup
+	if w == nil {
+		return nil
+	}
+	routeID := routeKey(route)
+	// unknown until the first check
+	b.routeWarmups.set(routeID, -1)
+	defer b.routeWarmups.clear(routeID)
+	deadline := time.Now().Add(w.Timeout)
+	lag := int64(-1)
+	for {
+		measured, err := b.referenceLag(ctx, cfg, route, dialer)
+		switch {
+		case err != nil:
+			log.Printf("warn: route %s: reference lag check failed: %v", route.DisplayName(), err)
+		case measured <= w.MaxLag:
+			log.Printf("event: route %s: reference feeds caught up (lag %d); starting", route.DisplayName(), measured)
+			return nil
+		default:
+			lag = measured
+			b.routeWarmups.set(routeID, lag)
+		}
+		if !time.Now().Before(deadline) {
+			if lag < 0 {
+				log.Printf("warn: route %s: reference lag still unknown after %s; starting anyway", route.DisplayName(), w.Timeout)
+			} else {
+				log.Printf("warn: route %s: reference feeds still %d record(s) behind after %s; starting anyway", route.DisplayName(), lag, w.Timeout)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(warmupPollInterval):
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/internal/config"
+	kafkapkg "kafka-bridge/internal/kafka"
+)
+
+func TestAwaitReferenceWarmup(t *testing.T) {
+	b := newBridge(config.Logging{})
+	b.memoryBroker = kafkapkg.NewMemoryBroker()
+	cfg := &config.Config{ReferenceGroupID: "refs"}
+	route := config.Route{Name: "warm", SourceTopic: "orders", DestinationTopic: "matched",
+		ReferenceFeeds:  []config.ReferenceFeed{{Topic: "customers", MatchFields: []string{"id"}}, {Topic: "customers", MatchFields: []string{"alt"}}},
+		ReferenceWarmup: &config.ReferenceWarmup{MaxLag: 1, Timeout: time.Nanosecond}}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := b.memoryBroker.Produce("customers", kafka.Message{Value: []byte(`{"id":"` + id + `"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	if lag, err := b.referenceLag(ctx, cfg, route, nil); err != nil || lag != 3 {
+		t.Fatalf("expected lag 3 for an uncommitted group, got %d (%v)", lag, err)
+	}
+	// the timeout passes before the lag drops, so the route starts anyway
+	if err := b.awaitReferenceWarmup(ctx, cfg, route, nil); err != nil {
+		t.Fatalf("awaitReferenceWarmup: %v", err)
+	}
+	if warming := b.bridgeReadiness().WarmingUp; warming != nil {
+		t.Fatalf("expected no route left warming up, got %v", warming)
+	}
+
+	reader := b.memoryBroker.Reader(referenceGroupID(cfg, route), []string{"customers"})
+	for range 2 {
+		if _, err := reader.ReadMessage(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lag, err := b.referenceLag(ctx, cfg, route, nil); err != nil || lag != 1 {
+		t.Fatalf("expected lag 1 after two reads, got %d (%v)", lag, err)
+	}
+	route.ReferenceWarmup.Timeout = time.Hour
+	done := make(chan error, 1)
+	go func() { done <- b.awaitReferenceWarmup(ctx, cfg, route, nil) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("awaitReferenceWarmup: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a lag within maxLag to start the route at once")
+	}
+}
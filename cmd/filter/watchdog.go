@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"kafka-bridge/internal/config"
+	"kafka-bridge/internal/watchdog"
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+type readerGauge struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// track records an opened reader for route and returns the func that records its close.
+func (g *readerGauge) track(route string) func() {
+	g.mu.Lock()
+	g.counts[route]++
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		g.counts[route]--
+		g.mu.Unlock()
+	}
+}
+
+func (g *readerGauge) get(route string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.counts[route]
+}
+
+// newWatchdog registers the runtime, writer pool, and per-route gauges watched for leaks.
+func (b *bridge) newWatchdog(cfg *config.Config, matchStore *store.MatchStore, matchers map[string]*engine.Matcher, writers *delivery.Pool) *watchdog.Watchdog {
+	dog := watchdog.New(watchdog.Config{
+		Interval:   cfg.Watchdog.Interval,
+		Window:     cfg.Watchdog.Window,
+		MinGrowth:  cfg.Watchdog.MinGrowth,
+		ProfileDir: cfg.Watchdog.ProfileDir,
+	})
+	dog.RegisterRuntime()
+	dog.Register("writers", func() float64 { return float64(writers.Len()) })
+
+	routes := make([]string, 0, len(matchers))
+	for id := range matchers {
+		routes = append(routes, id)
+	}
+	sort.Strings(routes)
+	for _, id := range routes {
+		id := id
+		dog.Register("route:"+id+":cache_values", func() float64 { return float64(matchStore.Size(id)) })
+		dog.Register("route:"+id+":readers", func() float64 { return float64(b.openReaders.get(id)) })
+	}
+	return dog
+}
@@ -0,0 +1,185 @@
+// Package archive copies forwarded messages to gzip-compressed NDJSON objects in
+// S3-compatible storage.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Uploader stores a finished archive object.
+type Uploader interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// Record is one NDJSON line of an archive object. Values that are valid JSON are embedded
+// as they are; any other value is base64 encoded in ValueBase64.
+type Record struct {
+	Route       string            `json:"route"`
+	Destination string            `json:"destination"`
+	Partition   int               `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Time        time.Time         `json:"timestamp"`
+	Key         string            `json:"key,omitempty"`
+	Value       json.RawMessage   `json:"value,omitempty"`
+	ValueBase64 []byte            `json:"valueBase64,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// NewRecord converts a forwarded message into an archive record.
+func NewRecord(route, destination string, msg kafka.Message) Record {
+	rec := Record{
+		Route:       route,
+		Destination: destination,
+		Partition:   msg.Partition,
+		Offset:      msg.Offset,
+		Time:        msg.Time,
+		Key:         string(msg.Key),
+	}
+	if json.Valid(msg.Value) {
+		rec.Value = msg.Value
+	} else if len(msg.Value) > 0 {
+		rec.ValueBase64 = msg.Value
+	}
+	if len(msg.Headers) > 0 {
+		rec.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			rec.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return rec
+}
+
+// Retry backoff bounds for failed uploads.
+const (
+	uploadBackoff    = time.Second
+	maxUploadBackoff = time.Minute
+)
+
+// Sink batches records into an object and uploads it once it holds MaxBytes of
+// uncompressed NDJSON or has been open for MaxAge. Failed uploads are retried until they
+// succeed or the context is done, holding back further records, so a store outage slows
+// the route instead of losing archived messages.
+type Sink struct {
+	// Prefix starts every object key, which continues with
+	// YYYY/MM/DD/HH/<unix nanos>-<sequence>.ndjson.gz from the time the object was opened.
+	Prefix   string
+	MaxBytes int
+	MaxAge   time.Duration
+
+	uploader Uploader
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	zw       *gzip.Writer
+	written  int
+	count    int
+	opened   time.Time
+	seq      int
+	now      func() time.Time
+}
+
+// NewSink builds a sink that uploads objects with uploader.
+func NewSink(uploader Uploader, prefix string, maxBytes int, maxAge time.Duration) *Sink {
+	return &Sink{Prefix: prefix, MaxBytes: maxBytes, MaxAge: maxAge, uploader: uploader, now: time.Now}
+}
+
+// Add appends records to the open object, uploading it first if they would not fit.
+func (s *Sink) Add(ctx context.Context, records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("encode archive record: %w", err)
+		}
+		line = append(line, '\n')
+		if s.count > 0 && s.written+len(line) > s.MaxBytes {
+			if err := s.rotateLocked(ctx); err != nil {
+				return err
+			}
+		}
+		if s.count == 0 {
+			s.buf.Reset()
+			s.zw = gzip.NewWriter(&s.buf)
+			s.opened = s.now()
+		}
+		if _, err := s.zw.Write(line); err != nil {
+			return fmt.Errorf("compress archive record: %w", err)
+		}
+		s.written += len(line)
+		s.count++
+	}
+	return nil
+}
+
+// Run uploads the open object whenever it reaches MaxAge, until ctx is done.
+func (s *Sink) Run(ctx context.Context) {
+	tick := s.MaxAge / 4
+	if tick < 100*time.Millisecond {
+		tick = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		if s.count > 0 && s.now().Sub(s.opened) >= s.MaxAge {
+			if err := s.rotateLocked(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("archive %s: %v", s.Prefix, err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Flush uploads the open object, if it holds any records.
+func (s *Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return nil
+	}
+	return s.rotateLocked(ctx)
+}
+
+func (s *Sink) rotateLocked(ctx context.Context) error {
+	if err := s.zw.Close(); err != nil {
+		return fmt.Errorf("compress archive object: %w", err)
+	}
+	s.seq++
+	opened := s.opened.UTC()
+	key := fmt.Sprintf("%s%s/%d-%d.ndjson.gz", s.Prefix, opened.Format("2006/01/02/15"), opened.UnixNano(), s.seq)
+	body := s.buf.Bytes()
+
+	backoff := uploadBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.uploader.Put(ctx, key, body, "application/gzip")
+		if err == nil {
+			break
+		}
+		log.Printf("archive upload of %s failed (attempt %d), retrying in %s: %v", key, attempt, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("upload %s: %w", key, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxUploadBackoff)
+	}
+	log.Printf("archived %d record(s) to %s", s.count, key)
+	s.written, s.count = 0, 0
+	return nil
+}
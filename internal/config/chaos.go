@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Chaos injects faults into a route so its retries, dead-letter topics, and decode error
+// handling can be exercised in a test environment. Each percentage is of the route's
+// destination write attempts or source messages, from 0 to 100. Validation refuses it
+// unless the top-level unsafeChaos is set.
+type Chaos struct {
+	// WriteErrorPercent fails write attempts before they reach the destination, so they
+	// are retried and, once retries run out, fail the route like a real write error.
+	WriteErrorPercent float64 `yaml:"writeErrorPercent"`
+	// DecodeErrorPercent fails decoding source messages, which onDecodeError handles.
+	DecodeErrorPercent float64 `yaml:"decodeErrorPercent"`
+	// LatencyPercent delays write attempts by Latency before they reach the destination.
+	LatencyPercent float64       `yaml:"latencyPercent"`
+	Latency        time.Duration `yaml:"latency"`
+}
+
+func (c *Chaos) validate() error {
+	for _, p := range []struct {
+		name    string
+		percent float64
+	}{
+		{"writeErrorPercent", c.WriteErrorPercent},
+		{"decodeErrorPercent", c.DecodeErrorPercent},
+		{"latencyPercent", c.LatencyPercent},
+	} {
+		if p.percent < 0 || p.percent > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", p.name)
+		}
+	}
+	if c.Latency < 0 {
+		return errors.New("latency must not be negative")
+	}
+	if (c.LatencyPercent > 0) != (c.Latency > 0) {
+		return errors.New("latencyPercent and latency must be set together")
+	}
+	return nil
+}
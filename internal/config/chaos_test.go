@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosRequiresUnsafeFlag(t *testing.T) {
+	base := func(chaos *Chaos) Config {
+		return Config{
+			SourceClusters:   []SourceCluster{{Name: "a", Brokers: []string{"a:9092"}, SourceGroupID: "src"}},
+			BridgeCluster:    ClusterConfig{Brokers: []string{"b:9092"}},
+			ClientID:         "bridge",
+			ReferenceGroupID: "refs",
+			Routes: []Route{{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", Chaos: chaos,
+				ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}},
+		}
+	}
+	cfg := base(&Chaos{WriteErrorPercent: 10})
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "requires unsafeChaos") {
+		t.Fatalf("expected chaos without unsafeChaos to fail, got %v", err)
+	}
+	cfg = base(&Chaos{WriteErrorPercent: 10, LatencyPercent: 50, Latency: 200 * time.Millisecond})
+	cfg.UnsafeChaos = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	for _, c := range []Chaos{{WriteErrorPercent: 101}, {DecodeErrorPercent: -1}, {LatencyPercent: 10}, {Latency: time.Second}, {LatencyPercent: 10, Latency: -time.Second}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected validation to fail", c)
+		}
+	}
+}
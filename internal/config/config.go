@@ -1,19 +1,47 @@
 package config
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 )
 
 const defaultCommitInterval = 5 * time.Second
 
+// Eviction policies accepted by routes[].eviction.
+const (
+	EvictionLRU       = "lru"
+	EvictionLFU       = "lfu"
+	EvictionRejectNew = "reject-new"
+)
+
+// Storage backends supported by the storage block.
+const (
+	StorageBackendFile  = "file"
+	StorageBackendKafka = "kafka"
+	// StorageBackendSQLite persists the cache to a queryable SQLite database at path.
+	StorageBackendSQLite = "sqlite"
+)
+
+// Snapshot compression accepted by storage.compression for the file backend.
+const (
+	StorageCompressionNone = "none"
+	StorageCompressionGzip = "gzip"
+)
+
 // Config captures all runtime settings.
 type Config struct {
 	SourceClusters   []SourceCluster `yaml:"sourceClusters"`
@@ -23,57 +51,1653 @@ type Config struct {
 	CommitInterval   time.Duration   `yaml:"commitInterval"`
 	Routes           []Route         `yaml:"routes"`
 	HTTP             HTTPServer      `yaml:"http"`
+	GRPC             GRPCServer      `yaml:"grpc"`
+	Audit            Audit           `yaml:"audit"`
+	Logging          Logging         `yaml:"logging"`
 	Storage          Storage         `yaml:"storage"`
+	Coordination     Coordination    `yaml:"coordination"`
+	SchemaDrift      SchemaDrift     `yaml:"schemaDrift"`
+	Watchdog         Watchdog        `yaml:"watchdog"`
+	DecodeLimits     DecodeLimits    `yaml:"decodeLimits"`
+	LoopPrevention   LoopPrevention  `yaml:"loopPrevention"`
+	// Pipelines chain routes whose output feeds another route's cache.
+	Pipelines []Pipeline `yaml:"pipelines"`
+	// Memory bounds the approximate memory of every route's cache and in-flight messages
+	// together.
+	Memory Memory `yaml:"memory"`
+	// DestinationNaming names the destination topics routes omit and enforces a naming
+	// convention on all of them.
+	DestinationNaming DestinationNaming `yaml:"destinationNaming"`
+	// ReferenceState publishes the cache to a compacted topic for other systems to consume.
+	ReferenceState ReferenceState `yaml:"referenceState"`
+	// Secrets configures the providers of vault: and k8s: references in TLS and SASL
+	// fields.
+	Secrets Secrets `yaml:"secrets"`
+	// LegacyJSONDecode decodes json source payloads into maps before matching them, as
+	// earlier versions did, instead of scanning their values in place.
+	LegacyJSONDecode bool `yaml:"legacyJsonDecode"`
+	// UnsafeChaos allows routes to set chaos. It must never be set in production: chaos
+	// fails and delays real traffic on purpose.
+	UnsafeChaos bool `yaml:"unsafeChaos"`
+	// MaxMessageAge drops source messages whose Kafka timestamp is older than this before
+	// they are matched, for every route without a maxMessageAge of its own; zero keeps
+	// messages of any age.
+	MaxMessageAge time.Duration `yaml:"maxMessageAge"`
+}
+
+// ClusterConfig holds broker, TLS, and SASL settings.
+type ClusterConfig struct {
+	Brokers []string    `yaml:"brokers"`
+	TLS     *TLSConfig  `yaml:"tls"`
+	SASL    *SASLConfig `yaml:"sasl"`
+	// Fetch tunes the readers of the cluster; on the bridge cluster, the reference readers.
+	Fetch Fetch `yaml:"fetch"`
+	// Writers tunes the destination writers of the bridge cluster.
+	Writers Writers `yaml:"writers"`
+}
+
+// Writers tunes the pool of destination writers.
+type Writers struct {
+	// IdleTimeout closes a writer once it has written nothing for this long, such as the
+	// writer of a destination no route uses any more; zero keeps writers open until
+	// shutdown. A closed writer is reopened by its next write.
+	IdleTimeout time.Duration `yaml:"idleTimeout"`
+}
+
+// SourceCluster ties a cluster configuration to a unique name for routing.
+type SourceCluster struct {
+	Name          string      `yaml:"name"`
+	Brokers       []string    `yaml:"brokers"`
+	SourceGroupID string      `yaml:"sourceGroupId"`
+	TLS           *TLSConfig  `yaml:"tls"`
+	SASL          *SASLConfig `yaml:"sasl"`
+	// Fetch tunes the source readers of the cluster; a route's consumer block overrides it.
+	Fetch Fetch `yaml:"fetch"`
+}
+
+// Fetch tunes how a kafka-go reader fetches and joins its group. Zero fields keep the
+// kafka-go defaults.
+type Fetch struct {
+	MinBytes int           `yaml:"minBytes"`
+	MaxBytes int           `yaml:"maxBytes"`
+	MaxWait  time.Duration `yaml:"maxWait"`
+	// QueueCapacity is the number of fetched messages the reader buffers.
+	QueueCapacity    int           `yaml:"queueCapacity"`
+	ReadBatchTimeout time.Duration `yaml:"readBatchTimeout"`
+	SessionTimeout   time.Duration `yaml:"sessionTimeout"`
+	RebalanceTimeout time.Duration `yaml:"rebalanceTimeout"`
+}
+
+// Override returns f with the non-zero fields of o.
+func (f Fetch) Override(o Fetch) Fetch {
+	if o.MinBytes > 0 {
+		f.MinBytes = o.MinBytes
+	}
+	if o.MaxBytes > 0 {
+		f.MaxBytes = o.MaxBytes
+	}
+	if o.MaxWait > 0 {
+		f.MaxWait = o.MaxWait
+	}
+	if o.QueueCapacity > 0 {
+		f.QueueCapacity = o.QueueCapacity
+	}
+	if o.ReadBatchTimeout > 0 {
+		f.ReadBatchTimeout = o.ReadBatchTimeout
+	}
+	if o.SessionTimeout > 0 {
+		f.SessionTimeout = o.SessionTimeout
+	}
+	if o.RebalanceTimeout > 0 {
+		f.RebalanceTimeout = o.RebalanceTimeout
+	}
+	return f
+}
+
+func (f Fetch) validate() error {
+	if f.MinBytes < 0 || f.MaxBytes < 0 {
+		return errors.New("minBytes and maxBytes cannot be negative")
+	}
+	if f.MinBytes > 0 && f.MaxBytes > 0 && f.MinBytes > f.MaxBytes {
+		return fmt.Errorf("minBytes %d exceeds maxBytes %d", f.MinBytes, f.MaxBytes)
+	}
+	if f.QueueCapacity < 0 {
+		return errors.New("queueCapacity cannot be negative")
+	}
+	if f.MaxWait < 0 || f.ReadBatchTimeout < 0 || f.SessionTimeout < 0 || f.RebalanceTimeout < 0 {
+		return errors.New("maxWait, readBatchTimeout, sessionTimeout, and rebalanceTimeout cannot be negative")
+	}
+	return nil
+}
+
+// SASL mechanisms accepted by sasl.mechanism.
+const SASLMechanismOAuthBearer = "OAUTHBEARER"
+
+// SASLConfig configures SASL authentication to a cluster.
+type SASLConfig struct {
+	Mechanism string       `yaml:"mechanism"`
+	OAuth     *OAuthConfig `yaml:"oauth"`
+}
+
+// OAuthConfig describes an OIDC client credentials token endpoint for OAUTHBEARER.
+type OAuthConfig struct {
+	TokenURL     string   `yaml:"tokenUrl"`
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Scopes       []string `yaml:"scopes"`
+	// Extensions are sent as SASL extensions, e.g. logicalCluster on some platforms.
+	Extensions map[string]string `yaml:"extensions"`
+
+	secrets *SecretStore
+}
+
+// ResolveClientSecret returns the client secret, resolving a vault: or k8s: reference
+// through the cached secret store so rotated secrets are picked up.
+func (o *OAuthConfig) ResolveClientSecret(ctx context.Context) (string, error) {
+	if o.secrets == nil || !isSecretRef(o.ClientSecret) {
+		return o.ClientSecret, nil
+	}
+	return o.secrets.Resolve(ctx, o.ClientSecret)
+}
+
+// TLSConfig describes certificates required for TLS/mTLS. CAFile, CertFile, and KeyFile
+// are file paths or vault: and k8s: references to PEM contents.
+type TLSConfig struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+
+	secrets *SecretStore
+}
+
+// Route maps one or more source topics to a destination topic with reference feeds.
+type Route struct {
+	Name             string          `yaml:"name"`
+	SourceCluster    string          `yaml:"sourceCluster"`
+	SourceTopic      string          `yaml:"sourceTopic"`
+	DestinationTopic string          `yaml:"destinationTopic"`
+	ReferenceFeeds   []ReferenceFeed `yaml:"referenceFeeds"`
+	// SourceTopicPattern, instead of sourceTopic, subscribes to every topic whose whole
+	// name matches this regular expression. destinationTopic may then refer to its
+	// capture groups as $1 or $name.
+	SourceTopicPattern string `yaml:"sourceTopicPattern"`
+	// TopicRefreshInterval is how often a sourceTopicPattern is matched against the
+	// cluster's topics again; the route resubscribes when the set changes.
+	TopicRefreshInterval time.Duration `yaml:"topicRefreshInterval"`
+	// Sources, instead of sourceTopic, reads the route from several source topics, on
+	// sourceCluster or clusters of their own, all matched by the route's cache and
+	// forwarded to its destination.
+	Sources []RouteSource `yaml:"sources"`
+	// Destination selects where matched messages go; by default destinationTopic on the
+	// bridge cluster.
+	Destination Destination `yaml:"destination"`
+	// Archive, when set, also copies every forwarded message to S3-compatible storage.
+	Archive *Archive `yaml:"archive"`
+	// Dedup, when set, suppresses forwarding the same source message twice within a window.
+	Dedup *Dedup `yaml:"dedup"`
+	// ReferenceHTTP, when set, also loads reference values from a REST endpoint at startup
+	// and refreshes them periodically.
+	ReferenceHTTP *ReferenceHTTP `yaml:"referenceHTTP"`
+	// ReferenceSQL, when set, also loads reference values from a database query at startup
+	// and refreshes them periodically.
+	ReferenceSQL *ReferenceSQL `yaml:"referenceSQL"`
+	// TimeWindow, when set, matches values cached with an event time only against source
+	// messages whose own timestamp is close to it.
+	TimeWindow *TimeWindow `yaml:"timeWindow"`
+	// Annotations are free-form notes such as owner, ticket, and reason, reported by the
+	// admin API so operators can tell why a route exists.
+	Annotations map[string]string `yaml:"annotations"`
+	// ExplainHeaders stamps forwarded messages with x-bridge-* headers describing the match.
+	ExplainHeaders bool `yaml:"explainHeaders"`
+	// Headers filters the source headers copied to forwarded messages and can add
+	// provenance headers.
+	Headers HeaderPolicy `yaml:"headers"`
+	// SourceHeaderFilters, name=value entries, skip source messages without a matching
+	// header before their payload is decoded; see SourceHeaderValues.
+	SourceHeaderFilters []string `yaml:"sourceHeaderFilters"`
+	// Compacted forwards keyed records for a compacted destination topic and writes a
+	// tombstone for a key once none of the reference values that matched it are cached.
+	Compacted bool `yaml:"compacted"`
+	// MaxValues caps the values cached for the route; zero means unlimited.
+	MaxValues int `yaml:"maxValues"`
+	// Eviction is applied once MaxValues is reached: lru (default), lfu, or reject-new.
+	Eviction string `yaml:"eviction"`
+	// BloomFilter, when set, keeps the route's cache in a counting bloom filter instead
+	// of an exact in-memory set.
+	BloomFilter *BloomFilter `yaml:"bloomFilter"`
+	// Consumer overrides the source consumer settings for this route.
+	Consumer Consumer `yaml:"consumer"`
+	// OnDecodeError handles source messages that cannot be decompressed or decoded: skip
+	// (default), forward them unchanged, or dlq, writing them to DecodeErrorTopic on the
+	// bridge cluster.
+	OnDecodeError    string `yaml:"onDecodeError"`
+	DecodeErrorTopic string `yaml:"decodeErrorTopic"`
+	// RejectedTopic, when set, receives on the bridge cluster a sample of the valid source
+	// messages the route does not forward, with headers explaining why.
+	RejectedTopic string `yaml:"rejectedTopic"`
+	// RejectedSampleRatio is the fraction of rejected messages written to RejectedTopic,
+	// above 0 and at most 1 (default).
+	RejectedSampleRatio float64 `yaml:"rejectedSampleRatio"`
+	// MaxInFlight bounds the source messages fetched but not yet written and committed,
+	// including those prefetched by the reader; zero keeps the reader's default queue.
+	MaxInFlight int `yaml:"maxInFlight"`
+	// Delivery controls retries of failed destination writes.
+	Delivery Delivery `yaml:"delivery"`
+	// Payload describes how source values are encoded.
+	Payload Payload `yaml:"payload"`
+	// Group names a set of routes on the same source topic of which only the first, in
+	// Priority order, that matches a message forwards it.
+	Group string `yaml:"group"`
+	// Priority orders the routes of a Group, lowest first; ties keep config order.
+	Priority int `yaml:"priority"`
+	// PayloadFormat is json (default), xml, or cloudevents for source values.
+	PayloadFormat string `yaml:"payloadFormat"`
+	// EventFilter forwards only CloudEvents whose attributes match every entry, exactly
+	// or by prefix with a trailing *. It requires payloadFormat cloudevents.
+	EventFilter map[string]string `yaml:"eventFilter"`
+	// MatchStrategy is fields (default), matching decoded field values, or bytesContains,
+	// searching the raw payload bytes for cached values without decoding it.
+	MatchStrategy string `yaml:"matchStrategy"`
+	// Prefilter scans each json source payload with an index of the cached values and
+	// skips decoding the ones they do not occur in.
+	Prefilter bool `yaml:"prefilter"`
+	// HashValues, when set, caches and compares SHA-256 hashes of the route's values
+	// instead of the values.
+	HashValues *HashValues `yaml:"hashValues"`
+	// Storage, when set, persists the route's cache in a snapshot file of its own instead
+	// of with the other routes in the global storage block. It supports the file backend
+	// only, and inherits flushInterval and compression from the global block.
+	Storage *Storage `yaml:"storage"`
+	// ReferenceWarmup, when set, holds the route back at startup until its reference
+	// collector has caught up with the reference topics.
+	ReferenceWarmup *ReferenceWarmup `yaml:"referenceWarmup"`
+	// Memory, when set, bounds the approximate memory of the route's cache and in-flight
+	// messages on its own, in addition to the global memory budget.
+	Memory *Memory `yaml:"memory"`
+	// Chaos injects write failures, decode failures, and latency into the route for
+	// testing; it requires the top-level unsafeChaos.
+	Chaos *Chaos `yaml:"chaos"`
+	// MaxMessageAge drops source messages whose Kafka timestamp is older than this before
+	// they are matched, such as those of a replay of old data; it defaults to the
+	// top-level maxMessageAge.
+	MaxMessageAge time.Duration `yaml:"maxMessageAge"`
+	// DestinationKey sets the key of forwarded messages: the source key, a constant, or a
+	// payload field. delivery.keyField is short for a payload field.
+	DestinationKey *DestinationKey `yaml:"destinationKey"`
+	// Output, when set, re-encodes forwarded payloads as Avro or protobuf for destinations
+	// that do not take JSON.
+	Output *Output `yaml:"output"`
+	// Team owns the route; it fills {{ .team }} in destinationNaming.template.
+	Team string `yaml:"team"`
+	// ExpiresAt pauses the route at an RFC 3339 timestamp or a date (YYYY-MM-DD, UTC).
+	ExpiresAt string `yaml:"expiresAt"`
+	// TTL pauses the route this long after CreatedAt, as an alternative to ExpiresAt.
+	TTL       time.Duration `yaml:"ttl"`
+	CreatedAt string        `yaml:"createdAt"`
+
+	// namePattern is destinationNaming.pattern, which the topics a templated
+	// destinationTopic expands to must match.
+	namePattern *regexp.Regexp
+}
+
+// HashValues keeps a route's cache free of plaintext values. Without a key the hashes
+// are plain SHA-256; with one they are HMAC-SHA256, which cannot be reversed by hashing
+// guessed values.
+type HashValues struct {
+	Key string `yaml:"key"`
+	// KeyEnv names the environment variable holding the key, as an alternative to Key.
+	KeyEnv string `yaml:"keyEnv"`
+}
+
+func (h *HashValues) validate() error {
+	if h == nil {
+		return nil
+	}
+	if h.Key != "" && h.KeyEnv != "" {
+		return errors.New("key and keyEnv cannot both be set")
+	}
+	if h.KeyEnv != "" {
+		if h.Key = os.Getenv(h.KeyEnv); h.Key == "" {
+			return fmt.Errorf("keyEnv %s is not set", h.KeyEnv)
+		}
+	}
+	return nil
+}
+
+// RouteSource is one source topic of a multi-source route.
+type RouteSource struct {
+	// Cluster names the source cluster of Topic; it defaults to the route's sourceCluster.
+	Cluster string `yaml:"cluster"`
+	Topic   string `yaml:"topic"`
+}
+
+// SourceClusterNames returns the source clusters the route reads, in the order of their
+// first source.
+func (r Route) SourceClusterNames() []string {
+	if len(r.Sources) == 0 {
+		return []string{r.SourceCluster}
+	}
+	var names []string
+	for _, src := range r.Sources {
+		if !slices.Contains(names, src.Cluster) {
+			names = append(names, src.Cluster)
+		}
+	}
+	return names
+}
+
+// SourceTopicsOn returns the topics the route reads on cluster: those of its sources
+// there, or its sourceTopic.
+func (r Route) SourceTopicsOn(cluster string) []string {
+	if len(r.Sources) == 0 {
+		return []string{r.SourceTopic}
+	}
+	var topics []string
+	for _, src := range r.Sources {
+		if src.Cluster == cluster {
+			topics = append(topics, src.Topic)
+		}
+	}
+	return topics
+}
+
+// validateSources defaults the clusters of the route's sources and rejects what a route
+// reading several topics cannot do.
+func (r *Route) validateSources() error {
+	if len(r.Sources) == 0 {
+		return nil
+	}
+	switch {
+	case r.SourceTopic != "" || r.SourceTopicPattern != "":
+		return errors.New("sources cannot be combined with sourceTopic or sourceTopicPattern")
+	case r.Compacted:
+		return errors.New("sources cannot be combined with compacted; keys from several topics would overwrite each other")
+	case r.Group != "":
+		return errors.New("sources cannot be combined with group, whose routes share one source topic")
+	case r.TopicRefreshInterval != 0:
+		return errors.New("topicRefreshInterval requires sourceTopicPattern")
+	case strings.Contains(r.DestinationTopic, "$"):
+		return fmt.Errorf("destinationTopic %q refers to capture groups but the route has no sourceTopicPattern", r.DestinationTopic)
+	}
+	seen := make(map[RouteSource]bool, len(r.Sources))
+	for i := range r.Sources {
+		src := &r.Sources[i]
+		if src.Topic == "" {
+			return fmt.Errorf("sources[%d]: topic is required", i)
+		}
+		if src.Cluster == "" {
+			src.Cluster = r.SourceCluster
+		}
+		if seen[*src] {
+			return fmt.Errorf("sources[%d]: %s/%s is listed twice", i, src.Cluster, src.Topic)
+		}
+		seen[*src] = true
+	}
+	return nil
+}
+
+// DefaultTopicRefreshInterval is how often a sourceTopicPattern is matched again unless
+// topicRefreshInterval says otherwise.
+const DefaultTopicRefreshInterval = time.Minute
+
+func (r *Route) validateSourceTopic() error {
+	switch {
+	case r.SourceTopic == "" && r.SourceTopicPattern == "":
+		return errors.New("sourceTopic cannot be empty")
+	case r.SourceTopic != "" && r.SourceTopicPattern != "":
+		return errors.New("sourceTopic and sourceTopicPattern cannot both be set")
+	case r.SourceTopic != "":
+		if r.TopicRefreshInterval != 0 {
+			return errors.New("topicRefreshInterval requires sourceTopicPattern")
+		}
+		if strings.Contains(r.DestinationTopic, "$") {
+			return fmt.Errorf("destinationTopic %q refers to capture groups but the route has no sourceTopicPattern", r.DestinationTopic)
+		}
+		return nil
+	}
+	re, err := r.SourceTopicRegexp()
+	if err != nil {
+		return fmt.Errorf("sourceTopicPattern: %w", err)
+	}
+	if r.TopicRefreshInterval < 0 {
+		return errors.New("topicRefreshInterval cannot be negative")
+	}
+	if r.TopicRefreshInterval == 0 {
+		r.TopicRefreshInterval = DefaultTopicRefreshInterval
+	}
+	if r.Compacted {
+		return errors.New("sourceTopicPattern cannot be combined with compacted")
+	}
+	if !r.DestinationTemplated() {
+		return nil
+	}
+	if r.Name == "" {
+		return errors.New("name is required when destinationTopic refers to capture groups")
+	}
+	if r.Destination.Type == DestinationWebhook {
+		return errors.New("destinationTopic capture groups require a kafka destination")
+	}
+	return checkTopicTemplate(r.DestinationTopic, re)
+}
+
+// checkTopicTemplate requires every $n, $name, or ${name} in template to name a capture
+// group of re.
+func checkTopicTemplate(template string, re *regexp.Regexp) error {
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			continue
+		}
+		rest := template[i+1:]
+		if strings.HasPrefix(rest, "$") {
+			i++
+			continue
+		}
+		var ref string
+		if strings.HasPrefix(rest, "{") {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return fmt.Errorf("destinationTopic %q has an unterminated ${", template)
+			}
+			ref = rest[1:end]
+		} else {
+			end := strings.IndexFunc(rest, func(c rune) bool {
+				return !(c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z')
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			ref = rest[:end]
+		}
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n < 0 || n > re.NumSubexp() {
+				return fmt.Errorf("destinationTopic %q refers to group $%d; sourceTopicPattern has %d", template, n, re.NumSubexp())
+			}
+			continue
+		}
+		if ref == "" || re.SubexpIndex(ref) < 0 {
+			return fmt.Errorf("destinationTopic %q refers to %q, which is not a capture group of sourceTopicPattern", template, "$"+ref)
+		}
+	}
+	return nil
+}
+
+// SourceTopicRegexp compiles SourceTopicPattern to match whole topic names.
+func (r Route) SourceTopicRegexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + r.SourceTopicPattern + ")$")
+}
+
+// DestinationTemplated reports whether destinationTopic is derived from each source topic.
+func (r Route) DestinationTemplated() bool {
+	return r.SourceTopicPattern != "" && strings.Contains(r.DestinationTopic, "$")
+}
+
+// DestinationTopicFor expands destinationTopic with the capture groups sourceTopic matched
+// in re, the compiled sourceTopicPattern.
+func (r Route) DestinationTopicFor(re *regexp.Regexp, sourceTopic string) (string, error) {
+	match := re.FindStringSubmatchIndex(sourceTopic)
+	if match == nil {
+		return "", fmt.Errorf("topic %s does not match sourceTopicPattern %s", sourceTopic, r.SourceTopicPattern)
+	}
+	topic := string(re.ExpandString(nil, r.DestinationTopic, sourceTopic, match))
+	if r.namePattern != nil && !r.namePattern.MatchString(topic) {
+		return "", fmt.Errorf("destinationTopic %q expands to %q for %s, which does not match destinationNaming.pattern", r.DestinationTopic, topic, sourceTopic)
+	}
+	return topic, nil
+}
+
+// SourceLabel names the route's source topic, or its pattern, in logs.
+func (r Route) SourceLabel() string {
+	if r.SourceTopicPattern != "" {
+		return "/" + r.SourceTopicPattern + "/"
+	}
+	return r.SourceTopic
+}
+
+// Expiry returns when the route pauses, if it declares an expiry. Call it on a validated route.
+func (r Route) Expiry() (time.Time, bool) {
+	if r.ExpiresAt != "" {
+		t, err := parseDateTime(r.ExpiresAt)
+		return t, err == nil
+	}
+	if r.TTL > 0 {
+		created, err := parseDateTime(r.CreatedAt)
+		return created.Add(r.TTL), err == nil
+	}
+	return time.Time{}, false
+}
+
+func (r Route) validateExpiry() error {
+	if r.ExpiresAt != "" {
+		if r.TTL != 0 {
+			return errors.New("set either expiresAt or ttl, not both")
+		}
+		if _, err := parseDateTime(r.ExpiresAt); err != nil {
+			return fmt.Errorf("expiresAt: %w", err)
+		}
+		return nil
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl cannot be negative")
+	}
+	if r.TTL > 0 {
+		if r.CreatedAt == "" {
+			return errors.New("ttl requires createdAt, so restarts do not extend the route's life")
+		}
+		if _, err := parseDateTime(r.CreatedAt); err != nil {
+			return fmt.Errorf("createdAt: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseDateTime accepts an RFC 3339 timestamp or a date, read as midnight UTC.
+func parseDateTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", s)
+	}
+	return t, nil
+}
+
+// Destination types accepted by destination.type.
+const (
+	DestinationKafka   = "kafka"
+	DestinationWebhook = "webhook"
+)
+
+// Default webhook batching and request timeout.
+const (
+	DefaultWebhookLinger  = time.Second
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// Destination selects where a route forwards matched messages.
+type Destination struct {
+	// Type is kafka (default), writing to destinationTopic, or webhook.
+	Type    string  `yaml:"type"`
+	Webhook Webhook `yaml:"webhook"`
+}
+
+// Webhook POSTs matched messages as a JSON array to an HTTP(S) endpoint.
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Headers are sent with every request, e.g. Authorization: Bearer ${WEBHOOK_TOKEN}.
+	Headers map[string]string `yaml:"headers"`
+	// BatchSize is the most messages sent per request; zero or one sends each alone.
+	BatchSize int `yaml:"batchSize"`
+	// Linger bounds how long a partial batch waits for more messages before it is sent.
+	Linger time.Duration `yaml:"linger"`
+	// Timeout bounds each request.
+	Timeout time.Duration `yaml:"timeout"`
+	TLS     *TLSConfig    `yaml:"tls"`
+}
+
+func (d *Destination) validate() error {
+	if d.Type == "" {
+		d.Type = DestinationKafka
+	}
+	switch d.Type {
+	case DestinationKafka:
+		return nil
+	case DestinationWebhook:
+	default:
+		return fmt.Errorf("unknown type %q (want kafka or webhook)", d.Type)
+	}
+	w := &d.Webhook
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q must be an absolute http or https URL", w.URL)
+	}
+	if w.BatchSize < 0 || w.Linger < 0 || w.Timeout < 0 {
+		return errors.New("webhook batchSize, linger, and timeout cannot be negative")
+	}
+	if w.BatchSize == 0 {
+		w.BatchSize = 1
+	}
+	if w.Linger == 0 {
+		w.Linger = DefaultWebhookLinger
+	}
+	if w.Timeout == 0 {
+		w.Timeout = DefaultWebhookTimeout
+	}
+	if err := w.TLS.validate(); err != nil {
+		return fmt.Errorf("webhook tls: %w", err)
+	}
+	return nil
+}
+
+// TimeWindow compares a source timestamp field with the event time of the matched
+// reference value: the source time must lie within [event-before, event+after].
+type TimeWindow struct {
+	SourceField string        `yaml:"sourceField"`
+	Before      time.Duration `yaml:"before"`
+	After       time.Duration `yaml:"after"`
+}
+
+func (r *Route) validateTimeWindow() error {
+	w := r.TimeWindow
+	if w == nil {
+		for _, feed := range r.ReferenceFeeds {
+			if feed.TimestampField != "" {
+				return fmt.Errorf("reference feed %q sets timestampField but the route has no timeWindow", feed.DisplayName())
+			}
+		}
+		return nil
+	}
+	if w.SourceField == "" {
+		return errors.New("timeWindow: sourceField is required")
+	}
+	if w.Before < 0 || w.After < 0 {
+		return errors.New("timeWindow: before and after cannot be negative")
+	}
+	for _, feed := range r.ReferenceFeeds {
+		if feed.TimestampField != "" {
+			return nil
+		}
+	}
+	return errors.New("timeWindow requires a reference feed with timestampField")
+}
+
+// DefaultBloomFalsePositiveRate applies when bloomFilter.falsePositiveRate is unset.
+const DefaultBloomFalsePositiveRate = 0.01
+
+// BloomFilter sizes the probabilistic cache of a route with a very large reference set.
+type BloomFilter struct {
+	// ExpectedValues is how many values the filter is sized for.
+	ExpectedValues    int     `yaml:"expectedValues"`
+	FalsePositiveRate float64 `yaml:"falsePositiveRate"`
+	// Verify confirms filter hits against the sqlite storage, so no false positive is
+	// forwarded.
+	Verify bool `yaml:"verify"`
+}
+
+func (r *Route) validateBloomFilter() error {
+	b := r.BloomFilter
+	if b == nil {
+		return nil
+	}
+	if b.ExpectedValues <= 0 {
+		return errors.New("bloomFilter: expectedValues must be positive")
+	}
+	if b.FalsePositiveRate == 0 {
+		b.FalsePositiveRate = DefaultBloomFalsePositiveRate
+	}
+	if b.FalsePositiveRate < 0 || b.FalsePositiveRate >= 1 {
+		return errors.New("bloomFilter: falsePositiveRate must be between 0 and 1")
+	}
+	if r.MaxValues > 0 {
+		return errors.New("bloomFilter cannot be combined with maxValues")
+	}
+	if r.TimeWindow != nil && !b.Verify {
+		return errors.New("bloomFilter: timeWindow needs verify, since the filter keeps no event times")
+	}
+	return nil
+}
+
+// validateBloomStorage checks the storage backend can persist and verify the routes that
+// use bloom filters: file snapshots are written from the exact set, which such routes
+// do not keep.
+func (c *Config) validateBloomStorage() error {
+	for i, r := range c.Routes {
+		if r.BloomFilter == nil {
+			continue
+		}
+		if r.Storage != nil {
+			return fmt.Errorf("route %d: bloomFilter cannot be combined with route storage, which writes file snapshots", i)
+		}
+		if c.Storage.Backend == StorageBackendFile && c.Storage.Path != "" {
+			return fmt.Errorf("route %d: bloomFilter requires the kafka or sqlite storage backend", i)
+		}
+		if r.BloomFilter.Verify && c.Storage.Backend != StorageBackendSQLite {
+			return fmt.Errorf("route %d: bloomFilter.verify requires the sqlite storage backend", i)
+		}
+	}
+	return nil
+}
+
+// validateRouteStorage checks the storage overrides of routes: each names a snapshot file
+// of its own, and settings it leaves unset come from the global storage block.
+func (c *Config) validateRouteStorage() error {
+	paths := make(map[string]string)
+	if c.Storage.Path != "" {
+		paths[filepath.Clean(c.Storage.Path)] = "the global storage"
+		if c.Storage.WAL {
+			paths[filepath.Clean(c.Storage.WALPath())] = "the global storage wal"
+		}
+		if c.Storage.PersistCounters {
+			paths[filepath.Clean(c.Storage.CountersPath())] = "the global storage counters"
+		}
+	}
+	for i := range c.Routes {
+		s := c.Routes[i].Storage
+		if s == nil {
+			continue
+		}
+		switch {
+		case s.Backend != "" && s.Backend != StorageBackendFile:
+			return fmt.Errorf("route %d: storage: backend %s cannot be set per route; only the file backend can", i, s.Backend)
+		case s.Path == "":
+			return fmt.Errorf("route %d: storage: path is required", i)
+		case s.Topic != "" || s.Replicate:
+			return fmt.Errorf("route %d: storage: topic and replicate belong to the kafka backend, which is global", i)
+		case s.FlushInterval < 0:
+			return fmt.Errorf("route %d: storage: flushInterval cannot be negative", i)
+		case s.Required:
+			return fmt.Errorf("route %d: storage: required is set on the global storage, which covers route storage too", i)
+		case s.PersistCounters:
+			return fmt.Errorf("route %d: storage: persistCounters is set on the global storage, which saves every route's counters", i)
+		}
+		if s.FlushInterval == 0 {
+			s.FlushInterval = c.Storage.FlushInterval
+		}
+		if s.Compression == "" {
+			s.Compression = c.Storage.Compression
+		}
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("route %d: storage: %w", i, err)
+		}
+		own := []string{s.Path}
+		if s.WAL {
+			own = append(own, s.WALPath())
+		}
+		for _, path := range own {
+			if other, taken := paths[filepath.Clean(path)]; taken {
+				return fmt.Errorf("route %d: storage: path %s is already used by %s", i, path, other)
+			}
+			paths[filepath.Clean(path)] = fmt.Sprintf("route %d", i)
+		}
+	}
+	if c.Storage.Required && len(paths) == 0 && c.Storage.Backend == StorageBackendFile {
+		return errors.New("storage: required needs a path, or route storage, to restore from")
+	}
+	return nil
+}
+
+// Default referenceHTTP refresh interval and request timeout.
+const (
+	DefaultReferenceHTTPPollInterval = 5 * time.Minute
+	DefaultReferenceHTTPTimeout      = 30 * time.Second
+)
+
+// ReferenceHTTP fetches a route's reference values from a REST endpoint returning JSON.
+// Values it loaded that a later response no longer lists are removed again.
+type ReferenceHTTP struct {
+	URL string `yaml:"url"`
+	// Headers are sent with every request, e.g. Authorization: Bearer ${REFERENCE_TOKEN}.
+	Headers map[string]string `yaml:"headers"`
+	// ValuesPath is a JSONPath to the values in the response, e.g. $.items[*].id; $ when
+	// the response is an array of values.
+	ValuesPath string `yaml:"valuesPath"`
+	// PollInterval is how often the values are fetched again after startup.
+	PollInterval time.Duration `yaml:"pollInterval"`
+	// Timeout bounds each request.
+	Timeout time.Duration `yaml:"timeout"`
+	TLS     *TLSConfig    `yaml:"tls"`
+}
+
+func (h *ReferenceHTTP) validate() error {
+	if h == nil {
+		return nil
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", h.URL)
+	}
+	if h.ValuesPath == "" {
+		h.ValuesPath = "$"
+	}
+	if !strings.HasPrefix(h.ValuesPath, "$") {
+		return fmt.Errorf("valuesPath %q must start with $", h.ValuesPath)
+	}
+	if h.PollInterval < 0 || h.Timeout < 0 {
+		return errors.New("pollInterval and timeout cannot be negative")
+	}
+	if h.PollInterval == 0 {
+		h.PollInterval = DefaultReferenceHTTPPollInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultReferenceHTTPTimeout
+	}
+	if err := h.TLS.validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	return nil
+}
+
+// TLSConfigObject builds a tls.Config for referenceHTTP requests; nil uses Go's defaults.
+func (h ReferenceHTTP) TLSConfigObject() (*tls.Config, error) {
+	return h.TLS.tlsConfig()
+}
+
+// Default referenceSQL refresh interval and query timeout.
+const (
+	DefaultReferenceSQLRefreshInterval = 5 * time.Minute
+	DefaultReferenceSQLTimeout         = 30 * time.Second
+)
+
+// ReferenceSQL runs a query against a database/sql driver and caches one column of the
+// rows it returns. Values it loaded that a later run no longer returns are removed again.
+type ReferenceSQL struct {
+	// Driver is the database/sql driver name, e.g. sqlite, pgx, or mysql.
+	Driver string `yaml:"driver"`
+	// DSN is the driver's data source name, or a vault: or k8s: reference to it.
+	DSN   string `yaml:"dsn"`
+	Query string `yaml:"query"`
+	// Column names the result column holding the values; the first column by default.
+	Column string `yaml:"column"`
+	// RefreshInterval is how often the query runs again after startup.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	// Timeout bounds each run of the query.
+	Timeout time.Duration `yaml:"timeout"`
+
+	secrets *SecretStore
+}
+
+func (q *ReferenceSQL) validate() error {
+	if q == nil {
+		return nil
+	}
+	if q.Driver == "" || q.DSN == "" || strings.TrimSpace(q.Query) == "" {
+		return errors.New("driver, dsn, and query are required")
+	}
+	if q.RefreshInterval < 0 || q.Timeout < 0 {
+		return errors.New("refreshInterval and timeout cannot be negative")
+	}
+	if q.RefreshInterval == 0 {
+		q.RefreshInterval = DefaultReferenceSQLRefreshInterval
+	}
+	if q.Timeout == 0 {
+		q.Timeout = DefaultReferenceSQLTimeout
+	}
+	return nil
+}
+
+// ResolveDSN returns the DSN, reading it from its secrets provider when it is a reference.
+// Each call may return a newer value as the cached secret is renewed.
+func (q *ReferenceSQL) ResolveDSN(ctx context.Context) (string, error) {
+	if q.secrets == nil || !isSecretRef(q.DSN) {
+		return q.DSN, nil
+	}
+	return q.secrets.Resolve(ctx, q.DSN)
+}
+
+// DefaultDedupMaxEntries bounds the keys a dedup window remembers unless
+// dedup.maxEntries says otherwise.
+const DefaultDedupMaxEntries = 1_000_000
+
+// Dedup remembers the messages a route forwarded so repeats within Window are skipped.
+type Dedup struct {
+	Window time.Duration `yaml:"window"`
+	// Field keys messages by a payload field (dotted, with | fallbacks) instead of their
+	// source partition and offset. Messages without the field are keyed by offset.
+	Field string `yaml:"field"`
+	// MaxEntries bounds the remembered keys; the oldest are forgotten first.
+	MaxEntries int `yaml:"maxEntries"`
+	// Path persists the remembered keys to a file, so restarts and replays of the route
+	// share them.
+	Path string `yaml:"path"`
+}
+
+func (d *Dedup) validate() error {
+	if d == nil {
+		return nil
+	}
+	if d.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+	if d.MaxEntries < 0 {
+		return errors.New("maxEntries cannot be negative")
+	}
+	if d.MaxEntries == 0 {
+		d.MaxEntries = DefaultDedupMaxEntries
+	}
+	for _, path := range strings.Split(d.Field, "|") {
+		if d.Field != "" && (path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..")) {
+			return fmt.Errorf("field %q is invalid", d.Field)
+		}
+	}
+	return nil
+}
+
+// DefaultReferenceWarmupTimeout is how long a route waits for its reference feeds to
+// catch up unless referenceWarmup.timeout says otherwise.
+const DefaultReferenceWarmupTimeout = 5 * time.Minute
+
+// ReferenceWarmup delays a route's source consumption until the reference consumer group
+// is at most MaxLag records behind the end of the reference topics, or Timeout elapses.
+type ReferenceWarmup struct {
+	MaxLag  int64         `yaml:"maxLag"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (w *ReferenceWarmup) validate() error {
+	if w == nil {
+		return nil
+	}
+	if w.MaxLag < 0 {
+		return errors.New("maxLag cannot be negative")
+	}
+	if w.Timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+	if w.Timeout == 0 {
+		w.Timeout = DefaultReferenceWarmupTimeout
+	}
+	return nil
+}
+
+// Policies applied while a memory budget is exceeded.
+const (
+	// MemoryPausePolicy stops reading reference feeds and polling reference sources.
+	MemoryPausePolicy = "pause-references"
+	// MemoryEvictPolicy drops the values cached longest ago.
+	MemoryEvictPolicy = "evict-oldest"
+	// MemoryRefusePolicy refuses values added through the admin APIs.
+	MemoryRefusePolicy = "refuse-adds"
+)
+
+// DefaultMemoryCheckInterval is how often memory usage is measured against the budgets.
+const DefaultMemoryCheckInterval = 10 * time.Second
+
+// Memory is a budget for the approximate memory of cached values and of the source
+// messages in flight, and what is shed while usage is over it.
+type Memory struct {
+	// MaxBytes is the budget; 0 leaves the memory unbounded.
+	MaxBytes int64 `yaml:"maxBytes"`
+	// Policy is pause-references (the default), evict-oldest, or refuse-adds. A route's
+	// budget without a policy uses the global one.
+	Policy string `yaml:"policy"`
+	// CheckInterval is how often usage is measured; global only.
+	CheckInterval time.Duration `yaml:"checkInterval"`
+}
+
+func (m *Memory) validate() error {
+	if m.MaxBytes < 0 {
+		return errors.New("maxBytes cannot be negative")
+	}
+	if m.CheckInterval < 0 {
+		return errors.New("checkInterval cannot be negative")
+	}
+	switch m.Policy {
+	case "", MemoryPausePolicy, MemoryEvictPolicy, MemoryRefusePolicy:
+	default:
+		return fmt.Errorf("unknown policy %q (want pause-references, evict-oldest, or refuse-adds)", m.Policy)
+	}
+	return nil
+}
+
+// MemoryPolicy returns the policy applied while route is over its own budget, or the
+// global one when it sets none.
+func (c *Config) MemoryPolicy(route Route) string {
+	if route.Memory != nil && route.Memory.Policy != "" {
+		return route.Memory.Policy
+	}
+	return c.Memory.Policy
+}
+
+// Default archive object rotation.
+const (
+	DefaultArchiveMaxObjectBytes = 64 << 20
+	DefaultArchiveMaxObjectAge   = 5 * time.Minute
+)
+
+// Archive uploads forwarded messages as gzip-compressed NDJSON objects to an S3 bucket.
+type Archive struct {
+	Bucket string `yaml:"bucket"`
+	// Prefix starts every object key; the route key is appended to it.
+	Prefix string `yaml:"prefix"`
+	Region string `yaml:"region"`
+	// Endpoint selects an S3-compatible store instead of AWS.
+	Endpoint  string `yaml:"endpoint"`
+	PathStyle bool   `yaml:"pathStyle"`
+	// AccessKeyID and SecretAccessKey default to AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY, and SessionToken to AWS_SESSION_TOKEN.
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+	SessionToken    string `yaml:"sessionToken"`
+	// MaxObjectBytes rotates an object once it holds this much uncompressed NDJSON.
+	MaxObjectBytes int `yaml:"maxObjectBytes"`
+	// MaxObjectAge rotates an object this long after its first message.
+	MaxObjectAge time.Duration `yaml:"maxObjectAge"`
+}
+
+func (a *Archive) validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+	if a.Region == "" {
+		return errors.New("region is required")
+	}
+	if a.Endpoint != "" {
+		if u, err := url.Parse(a.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("endpoint %q must be an absolute http or https URL", a.Endpoint)
+		}
+	}
+	if a.AccessKeyID == "" {
+		a.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if a.SecretAccessKey == "" {
+		a.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if a.SessionToken == "" {
+		a.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if a.AccessKeyID == "" || a.SecretAccessKey == "" {
+		return errors.New("accessKeyId and secretAccessKey are required (or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	if a.MaxObjectBytes < 0 || a.MaxObjectAge < 0 {
+		return errors.New("maxObjectBytes and maxObjectAge cannot be negative")
+	}
+	if a.MaxObjectBytes == 0 {
+		a.MaxObjectBytes = DefaultArchiveMaxObjectBytes
+	}
+	if a.MaxObjectAge == 0 {
+		a.MaxObjectAge = DefaultArchiveMaxObjectAge
+	}
+	return nil
+}
+
+// Delivery controls how failed destination writes are retried. Writes are retried in
+// order and the source offset is committed only once the write succeeds.
+type Delivery struct {
+	RetryBackoff    time.Duration `yaml:"retryBackoff"`
+	MaxRetryBackoff time.Duration `yaml:"maxRetryBackoff"`
+	// MaxAttempts stops the route after this many failed writes of one message; zero
+	// retries indefinitely.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// MaxMessageBytes caps the size of a forwarded message (key, value, and headers);
+	// zero means no limit. Larger messages are handled according to Oversize.
+	MaxMessageBytes int `yaml:"maxMessageBytes"`
+	// Oversize is drop (default), truncate, deadLetter, or compress.
+	Oversize string `yaml:"oversize"`
+	// DeadLetterTopic on the bridge cluster receives oversized messages unchanged under
+	// the deadLetter policy.
+	DeadLetterTopic string `yaml:"deadLetterTopic"`
+	// Partitioner places forwarded messages on destination partitions: source (default)
+	// keeps the source partition, key hashes the message key as Java producers do, and
+	// roundRobin spreads messages evenly.
+	Partitioner string `yaml:"partitioner"`
+	// KeyField, a dotted payload path with optional | fallbacks, replaces the key of
+	// forwarded messages with the field's value. It implies the key partitioner.
+	KeyField string `yaml:"keyField"`
+	// Compression is the codec the destination writer compresses batches with: none
+	// (default), gzip, snappy, lz4, or zstd. It requires a Kafka destination.
+	Compression string `yaml:"compression"`
+	// Async writes forwarded messages without waiting for each to be acknowledged, and
+	// commits source offsets as acknowledgements arrive. It requires a Kafka destination.
+	Async bool `yaml:"async"`
+}
+
+// Codecs accepted by delivery.compression.
+const (
+	DeliveryCompressionNone   = "none"
+	DeliveryCompressionGzip   = "gzip"
+	DeliveryCompressionSnappy = "snappy"
+	DeliveryCompressionLz4    = "lz4"
+	DeliveryCompressionZstd   = "zstd"
+)
+
+// Partitioners accepted by delivery.partitioner.
+const (
+	PartitionerSource     = "source"
+	PartitionerKey        = "key"
+	PartitionerRoundRobin = "roundRobin"
+)
+
+// Policies accepted by routes[].onDecodeError.
+const (
+	OnDecodeErrorSkip    = "skip"
+	OnDecodeErrorForward = "forward"
+	OnDecodeErrorDLQ     = "dlq"
+)
+
+// Payload compression accepted by payload.compression.
+const (
+	PayloadCompressionNone   = "none"
+	PayloadCompressionGzip   = "gzip"
+	PayloadCompressionSnappy = "snappy"
+	PayloadCompressionZstd   = "zstd"
+	// PayloadCompressionAuto detects gzip, zstd, and framed snappy by their magic bytes.
+	PayloadCompressionAuto = "auto"
+)
+
+// DefaultMaxDecompressedBytes bounds decompressed source values unless
+// payload.maxDecompressedBytes says otherwise.
+const DefaultMaxDecompressedBytes = 16 << 20
+
+// Payload configures how source values are decompressed before matching.
+type Payload struct {
+	// Compression is none (default), gzip, snappy, zstd, or auto. Values without the
+	// codec's framing are matched as they are.
+	Compression string `yaml:"compression"`
+	// MaxDecompressedBytes rejects values that decompress to more than this.
+	MaxDecompressedBytes int `yaml:"maxDecompressedBytes"`
+	// ForwardDecompressed writes the decompressed value to the destination instead of
+	// the original bytes.
+	ForwardDecompressed bool `yaml:"forwardDecompressed"`
+}
+
+func (p *Payload) validate() error {
+	switch p.Compression {
+	case "", PayloadCompressionNone:
+		return nil
+	case PayloadCompressionGzip, PayloadCompressionSnappy, PayloadCompressionZstd, PayloadCompressionAuto:
+	default:
+		return fmt.Errorf("unknown compression %q (want none, gzip, snappy, zstd, or auto)", p.Compression)
+	}
+	if p.MaxDecompressedBytes < 0 {
+		return errors.New("maxDecompressedBytes cannot be negative")
+	}
+	if p.MaxDecompressedBytes == 0 {
+		p.MaxDecompressedBytes = DefaultMaxDecompressedBytes
+	}
+	return nil
+}
+
+// Oversize policies accepted by delivery.oversize.
+const (
+	OversizeDrop       = "drop"
+	OversizeTruncate   = "truncate"
+	OversizeDeadLetter = "deadLetter"
+	// OversizeCompress gzips the value and drops the message if it still does not fit.
+	OversizeCompress = "compress"
+)
+
+func (d *Delivery) validate() error {
+	if d.RetryBackoff < 0 || d.MaxRetryBackoff < 0 || d.MaxAttempts < 0 || d.MaxMessageBytes < 0 {
+		return errors.New("retryBackoff, maxRetryBackoff, maxAttempts, and maxMessageBytes cannot be negative")
+	}
+	if d.MaxMessageBytes > 0 && d.Oversize == "" {
+		d.Oversize = OversizeDrop
+	}
+	switch d.Oversize {
+	case "", OversizeDrop, OversizeTruncate, OversizeCompress:
+	case OversizeDeadLetter:
+		if d.DeadLetterTopic == "" {
+			return errors.New("oversize deadLetter requires deadLetterTopic")
+		}
+	default:
+		return fmt.Errorf("unknown oversize policy %q (want drop, truncate, deadLetter, or compress)", d.Oversize)
+	}
+	if d.Oversize != "" && d.MaxMessageBytes == 0 {
+		return errors.New("oversize requires maxMessageBytes")
+	}
+	if d.KeyField != "" && d.Partitioner == "" {
+		d.Partitioner = PartitionerKey
+	}
+	switch d.Partitioner {
+	case "", PartitionerSource, PartitionerKey, PartitionerRoundRobin:
+	default:
+		return fmt.Errorf("unknown partitioner %q (want source, key, or roundRobin)", d.Partitioner)
+	}
+	if d.KeyField != "" && d.Partitioner != PartitionerKey {
+		return errors.New("keyField requires the key partitioner")
+	}
+	switch d.Compression {
+	case "", DeliveryCompressionNone, DeliveryCompressionGzip, DeliveryCompressionSnappy, DeliveryCompressionLz4, DeliveryCompressionZstd:
+	default:
+		return fmt.Errorf("unknown compression %q (want none, gzip, snappy, lz4, or zstd)", d.Compression)
+	}
+	return nil
+}
+
+// HeaderPolicy controls the headers of forwarded messages. Include and Exclude list
+// header names, matched case-insensitively; a trailing * matches any name with that
+// prefix. The loop prevention headers are always kept.
+type HeaderPolicy struct {
+	// Provenance stamps x-bridge-source-cluster, -topic, -partition, -offset,
+	// x-bridge-instance, and x-bridge-forwarded-at on every forwarded message.
+	Provenance bool `yaml:"provenance"`
+	// Include, when set, forwards only the source headers it matches.
+	Include []string `yaml:"include"`
+	// Exclude drops matching source headers; it wins over Include.
+	Exclude []string `yaml:"exclude"`
+}
+
+// SourceHeaderValues returns the accepted values of each header named by
+// sourceHeaderFilters, keyed by its lowercased name. A source message must carry every
+// named header, matched case-insensitively, with one of its values, matched exactly; it
+// returns nil when the route filters no headers.
+func (r Route) SourceHeaderValues() map[string][]string {
+	if len(r.SourceHeaderFilters) == 0 {
+		return nil
+	}
+	values := make(map[string][]string)
+	for _, filter := range r.SourceHeaderFilters {
+		name, value, _ := strings.Cut(filter, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		values[name] = append(values[name], value)
+	}
+	return values
+}
+
+func (h HeaderPolicy) validate() error {
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"include", h.Include}, {"exclude", h.Exclude}} {
+		for _, pattern := range list.patterns {
+			name := strings.TrimSuffix(pattern, "*")
+			if name == "" && pattern != "*" {
+				return fmt.Errorf("%s entries cannot be empty", list.name)
+			}
+			if strings.Contains(name, "*") {
+				return fmt.Errorf("%s entry %q: * is only supported as a trailing wildcard", list.name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Start offsets accepted by consumer.startOffset besides an RFC 3339 timestamp.
+const (
+	StartOffsetEarliest = "earliest"
+	StartOffsetLatest   = "latest"
+)
+
+// Consumer tunes the source consumer of one route. Zero fields inherit the global settings.
+type Consumer struct {
+	// GroupSuffix replaces the route name in the source consumer group ID.
+	GroupSuffix    string        `yaml:"groupSuffix"`
+	CommitInterval time.Duration `yaml:"commitInterval"`
+	// StartOffset is where a group without committed offsets begins: earliest, latest
+	// (default), or an RFC 3339 timestamp.
+	StartOffset string `yaml:"startOffset"`
+	MinBytes    int    `yaml:"minBytes"`
+	MaxBytes    int    `yaml:"maxBytes"`
+	// MaxWait, QueueCapacity, ReadBatchTimeout, SessionTimeout, and RebalanceTimeout
+	// override the fetch settings of the source cluster, as MinBytes and MaxBytes do.
+	MaxWait          time.Duration `yaml:"maxWait"`
+	QueueCapacity    int           `yaml:"queueCapacity"`
+	ReadBatchTimeout time.Duration `yaml:"readBatchTimeout"`
+	SessionTimeout   time.Duration `yaml:"sessionTimeout"`
+	RebalanceTimeout time.Duration `yaml:"rebalanceTimeout"`
+}
+
+// Fetch returns the fetch settings the consumer overrides.
+func (c Consumer) Fetch() Fetch {
+	return Fetch{
+		MinBytes:         c.MinBytes,
+		MaxBytes:         c.MaxBytes,
+		MaxWait:          c.MaxWait,
+		QueueCapacity:    c.QueueCapacity,
+		ReadBatchTimeout: c.ReadBatchTimeout,
+		SessionTimeout:   c.SessionTimeout,
+		RebalanceTimeout: c.RebalanceTimeout,
+	}
+}
+
+// StartTime returns the timestamp when StartOffset is one.
+func (c Consumer) StartTime() (time.Time, bool) {
+	switch c.StartOffset {
+	case "", StartOffsetEarliest, StartOffsetLatest:
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, c.StartOffset)
+	return t, err == nil
+}
+
+func (c Consumer) validate() error {
+	if c.CommitInterval < 0 {
+		return errors.New("commitInterval cannot be negative")
+	}
+	if err := c.Fetch().validate(); err != nil {
+		return err
+	}
+	if _, isTime := c.StartTime(); !isTime {
+		switch c.StartOffset {
+		case "", StartOffsetEarliest, StartOffsetLatest:
+		default:
+			return fmt.Errorf("startOffset %q must be earliest, latest, or an RFC 3339 timestamp", c.StartOffset)
+		}
+	}
+	return nil
+}
+
+// HTTPServer configures the optional admin HTTP listener.
+type HTTPServer struct {
+	ListenAddr string `yaml:"listenAddr"`
+	// AdminToken, when set, must be sent as a bearer token to mutating and debug endpoints.
+	AdminToken string `yaml:"adminToken"`
+	// Debug exposes net/http/pprof under /debug/pprof/ and runtime stats at /debug/vars.
+	Debug bool `yaml:"debug"`
+}
+
+// GRPCServer configures the optional gRPC reference API. It shares http.adminToken and
+// the read-only mode with the admin HTTP API. Leaving listenAddr empty disables it.
+type GRPCServer struct {
+	ListenAddr string `yaml:"listenAddr"`
+}
+
+// Logging thins out the log lines written per source message: forwards, drops, invalid
+// payloads, and tombstones.
+type Logging struct {
+	// SampleEvery logs one in every N per-message lines of each route. 0 and 1 log every
+	// line; -1 turns them off, leaving the summary.
+	SampleEvery int `yaml:"sampleEvery"`
+	// SummaryInterval, when set, logs each active route's counts every interval.
+	SummaryInterval time.Duration `yaml:"summaryInterval"`
+}
+
+func (l Logging) validate() error {
+	if l.SampleEvery < -1 {
+		return errors.New("sampleEvery must be positive, 0, or -1")
+	}
+	if l.SummaryInterval < 0 {
+		return errors.New("summaryInterval cannot be negative")
+	}
+	return nil
+}
+
+// Audit records every mutating admin call, over HTTP or gRPC, as a structured audit
+// record.
+type Audit struct {
+	// Enabled logs one JSON audit record per mutating call.
+	Enabled bool `yaml:"enabled"`
+	// Topic, when set, also publishes every record to this topic on the bridge cluster.
+	Topic string `yaml:"topic"`
+	// IdentityHeader names the request header, or gRPC metadata key, carrying the
+	// caller's identity as set by an authenticating proxy, e.g. X-Forwarded-User.
+	IdentityHeader string `yaml:"identityHeader"`
+}
+
+func (a Audit) validate() error {
+	if !a.Enabled && (a.Topic != "" || a.IdentityHeader != "") {
+		return errors.New("topic and identityHeader require enabled: true")
+	}
+	if strings.ContainsAny(a.IdentityHeader, " \t:") {
+		return fmt.Errorf("identityHeader %q is not a header name", a.IdentityHeader)
+	}
+	return nil
+}
+
+// ReferenceFeed describes per-topic extraction rules.
+type ReferenceFeed struct {
+	Name         string   `yaml:"name"`
+	Topic        string   `yaml:"topic"`
+	TopicHeaders []string `yaml:"topicHeaders"`
+	MatchFields  []string `yaml:"matchFields"`
+	// PayloadFormat is json (default), xml, cloudevents, or csv.
+	PayloadFormat string `yaml:"payloadFormat"`
+	// TimestampField is read from every record as the event time of its values, for
+	// routes with a timeWindow.
+	TimestampField string `yaml:"timestampField"`
+	// RecordsPath, a dotted path, locates an array of records inside each message. A
+	// message that is itself an array is always read as one record per element.
+	RecordsPath string `yaml:"recordsPath"`
+	// MatchSource is value (default), reading matchFields from the payload, key, caching
+	// the message key, or header:<name>, caching that header's value.
+	MatchSource string `yaml:"matchSource"`
+	// CSVHeader reads the first line of each csv message as its column names.
+	CSVHeader bool `yaml:"csvHeader"`
+	// CSVColumns names the columns of csv messages without a header line.
+	CSVColumns []string `yaml:"csvColumns"`
+	// CSVDelimiter is the csv field separator: one character, or \t for tabs. Defaults to a
+	// comma.
+	CSVDelimiter string `yaml:"csvDelimiter"`
+	// Canonicalize lists the kinds of value, numbers, booleans, and timestamps, cached in
+	// one canonical form; source values are compared in that form too.
+	Canonicalize []string `yaml:"canonicalize"`
+	// CanonicalTimeLayouts are Go time layouts read as timestamps besides RFC 3339.
+	CanonicalTimeLayouts []string `yaml:"canonicalTimeLayouts"`
+}
+
+func (f ReferenceFeed) validateCanonicalize() error {
+	for _, kind := range f.Canonicalize {
+		switch kind {
+		case CanonicalizeNumbers, CanonicalizeBooleans, CanonicalizeTimestamps:
+		default:
+			return fmt.Errorf("unknown canonicalize kind %q (want numbers, booleans, or timestamps)", kind)
+		}
+	}
+	if len(f.CanonicalTimeLayouts) > 0 && !slices.Contains(f.Canonicalize, CanonicalizeTimestamps) {
+		return errors.New("canonicalTimeLayouts requires canonicalize timestamps")
+	}
+	return nil
+}
+
+// Kinds of value accepted by referenceFeeds[].canonicalize.
+const (
+	CanonicalizeNumbers    = "numbers"
+	CanonicalizeBooleans   = "booleans"
+	CanonicalizeTimestamps = "timestamps"
+)
+
+func (f ReferenceFeed) validateCSV() error {
+	if f.PayloadFormat != PayloadFormatCSV {
+		if f.CSVHeader || len(f.CSVColumns) > 0 || f.CSVDelimiter != "" {
+			return errors.New("csvHeader, csvColumns, and csvDelimiter require payloadFormat csv")
+		}
+		return nil
+	}
+	if f.RecordsPath != "" {
+		return errors.New("recordsPath cannot be combined with payloadFormat csv; every line is a record")
+	}
+	if f.CSVHeader && len(f.CSVColumns) > 0 {
+		return errors.New("csvHeader and csvColumns cannot both be set")
+	}
+	if d := f.CSVDelimiter; d != "" && d != `\t` && (utf8.RuneCountInString(d) != 1 || strings.ContainsAny(d, "\"\r\n")) {
+		return fmt.Errorf("csvDelimiter %q must be one character other than a quote or newline, or \\t", d)
+	}
+	return nil
 }
 
-// ClusterConfig holds broker and TLS settings.
-type ClusterConfig struct {
-	Brokers []string   `yaml:"brokers"`
-	TLS     *TLSConfig `yaml:"tls"`
+// Match sources accepted by referenceFeeds[].matchSource.
+const (
+	MatchSourceValue        = "value"
+	MatchSourceKey          = "key"
+	MatchSourceHeaderPrefix = "header:"
+)
+
+// Payload formats accepted by payloadFormat on routes and reference feeds.
+const (
+	PayloadFormatJSON = "json"
+	// PayloadFormatXML decodes documents so match fields are dotted element paths from the
+	// root element, with attributes as @name, e.g. order.customer.@id.
+	PayloadFormatXML = "xml"
+	// PayloadFormatCloudEvents decodes structured-mode JSON CloudEvents: match fields
+	// address the event data, and the attributes are ce.type, ce.source, ce.subject, and
+	// ce.<extension>.
+	PayloadFormatCloudEvents = "cloudevents"
+	// PayloadFormatCSV reads reference messages as CSV lines, one record per line, whose
+	// match fields are column names or zero-based column indexes. Reference feeds only.
+	PayloadFormatCSV = "csv"
+)
+
+// Match strategies accepted by matchStrategy.
+const (
+	// MatchStrategyFields decodes source payloads and looks up each field value.
+	MatchStrategyFields = "fields"
+	// MatchStrategyBytesContains searches raw source payloads for cached values, which
+	// suits opaque identifiers that cannot occur by accident inside other values.
+	MatchStrategyBytesContains = "bytesContains"
+)
+
+// validateMatchStrategy rejects the settings bytesContains and prefilter cannot honour,
+// since they search the payload before decoding it.
+func (r *Route) validateMatchStrategy() error {
+	if r.Prefilter {
+		switch {
+		case r.MatchStrategy == MatchStrategyBytesContains:
+			return errors.New("prefilter is redundant under matchStrategy bytesContains")
+		case r.PayloadFormat != "" && r.PayloadFormat != PayloadFormatJSON:
+			return fmt.Errorf("prefilter requires json payloads, not payloadFormat %s", r.PayloadFormat)
+		case r.BloomFilter != nil:
+			return errors.New("prefilter needs the exact cache; remove bloomFilter")
+		}
+	}
+	switch r.MatchStrategy {
+	case "", MatchStrategyFields:
+		return nil
+	case MatchStrategyBytesContains:
+	default:
+		return fmt.Errorf("unknown matchStrategy %q (want fields or bytesContains)", r.MatchStrategy)
+	}
+	switch {
+	case r.PayloadFormat != "" && r.PayloadFormat != PayloadFormatJSON:
+		return fmt.Errorf("matchStrategy bytesContains cannot be combined with payloadFormat %s", r.PayloadFormat)
+	case len(r.EventFilter) > 0:
+		return errors.New("matchStrategy bytesContains cannot be combined with eventFilter")
+	case r.TimeWindow != nil:
+		return errors.New("matchStrategy bytesContains cannot be combined with timeWindow")
+	case r.BloomFilter != nil:
+		return errors.New("matchStrategy bytesContains needs the exact cache; remove bloomFilter")
+	}
+	return nil
 }
 
-// SourceCluster ties a cluster configuration to a unique name for routing.
-type SourceCluster struct {
-	Name          string     `yaml:"name"`
-	Brokers       []string   `yaml:"brokers"`
-	SourceGroupID string     `yaml:"sourceGroupId"`
-	TLS           *TLSConfig `yaml:"tls"`
+func validPayloadFormat(format string) bool {
+	switch format {
+	case "", PayloadFormatJSON, PayloadFormatXML, PayloadFormatCloudEvents:
+		return true
+	}
+	return false
 }
 
-// TLSConfig describes certificates required for TLS/mTLS.
-type TLSConfig struct {
-	CAFile             string `yaml:"caFile"`
-	CertFile           string `yaml:"certFile"`
-	KeyFile            string `yaml:"keyFile"`
-	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+// Storage configures optional persistence for cached values, either as on-disk
+// snapshots or as a compacted topic on the bridge cluster.
+type Storage struct {
+	Backend       string        `yaml:"backend"`
+	Path          string        `yaml:"path"`
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	Topic         string        `yaml:"topic"`
+	// Compression is none (default) or gzip for file snapshots.
+	Compression string `yaml:"compression"`
+	// Replicate keeps every replica sharing the kafka backend's topic tailing it, so
+	// changes any replica makes to its cache reach all the others.
+	Replicate bool `yaml:"replicate"`
+	// WAL logs every cache change to <path>.wal as it happens, so the file backend loses
+	// nothing made since its last snapshot when the process crashes.
+	WAL bool `yaml:"wal"`
+	// Required holds routes, reference ingestion, and admin mutations back and keeps
+	// /readyz failing until the cache is restored, retrying a failed restore instead of
+	// starting from an empty cache. Set on the global storage, it covers route storage too.
+	Required bool `yaml:"required"`
+	// PersistCounters saves the routes' counters to <path>.counters every flushInterval
+	// and restores them at startup, so route statistics are totals across restarts. It
+	// needs a backend with a path.
+	PersistCounters bool `yaml:"persistCounters"`
 }
 
-// Route maps one or more source topics to a destination topic with reference feeds.
-type Route struct {
-	Name             string          `yaml:"name"`
-	SourceCluster    string          `yaml:"sourceCluster"`
-	SourceTopic      string          `yaml:"sourceTopic"`
-	DestinationTopic string          `yaml:"destinationTopic"`
-	ReferenceFeeds   []ReferenceFeed `yaml:"referenceFeeds"`
+// WALPath is where the file backend keeps its write-ahead log.
+func (s Storage) WALPath() string {
+	return s.Path + ".wal"
 }
 
-// HTTPServer configures the optional admin HTTP listener.
-type HTTPServer struct {
-	ListenAddr string `yaml:"listenAddr"`
+// CountersPath is where persistCounters keeps the routes' counters.
+func (s Storage) CountersPath() string {
+	return s.Path + ".counters"
 }
 
-// ReferenceFeed describes per-topic extraction rules.
-type ReferenceFeed struct {
-	Name         string   `yaml:"name"`
-	Topic        string   `yaml:"topic"`
-	TopicHeaders []string `yaml:"topicHeaders"`
-	MatchFields  []string `yaml:"matchFields"`
+// Coordination configures broadcasting admin mutations between replicas through a topic
+// on the bridge cluster. Leaving topic empty disables it.
+type Coordination struct {
+	Topic      string `yaml:"topic"`
+	InstanceID string `yaml:"instanceId"`
+	// LeaderElection runs the reference collectors on one elected replica only.
+	LeaderElection LeaderElection `yaml:"leaderElection"`
 }
 
-// Storage configures optional on-disk persistence for cached values.
-type Storage struct {
-	Path          string        `yaml:"path"`
-	FlushInterval time.Duration `yaml:"flushInterval"`
+// LeaderElection elects the replica that consumes the reference feeds; it broadcasts the
+// cache changes they make to the other replicas through the coordination topic. The
+// leader is the consumer group member assigned the single partition of Topic.
+type LeaderElection struct {
+	Enabled bool `yaml:"enabled"`
+	// Topic defaults to <coordination.topic>-leader.
+	Topic string `yaml:"topic"`
+	// GroupID defaults to <referenceGroupId>-leader.
+	GroupID string `yaml:"groupId"`
+}
+
+// SchemaDrift enables tracking of payload field paths per topic for drift reports.
+type SchemaDrift struct {
+	Enabled bool `yaml:"enabled"`
+	// BaselineMessages is how many messages per topic establish the expected schema.
+	BaselineMessages int `yaml:"baselineMessages"`
+	// SourceSampleEvery observes one in N source payloads; reference payloads are all observed.
+	SourceSampleEvery int `yaml:"sourceSampleEvery"`
+}
+
+// DecodeLimits bounds the JSON payloads the matcher will decode. Unset fields take the
+// defaults below; set a field to -1 to disable that limit.
+type DecodeLimits struct {
+	MaxDepth        int `yaml:"maxDepth"`
+	MaxNodes        int `yaml:"maxNodes"`
+	MaxStringLength int `yaml:"maxStringLength"`
+}
+
+// Default decode limits, generous enough for legitimate payloads.
+const (
+	DefaultMaxDepth        = 64
+	DefaultMaxNodes        = 1_000_000
+	DefaultMaxStringLength = 1 << 20
+)
+
+func (d *DecodeLimits) applyDefaults() {
+	defaults := []struct {
+		field *int
+		value int
+	}{
+		{&d.MaxDepth, DefaultMaxDepth},
+		{&d.MaxNodes, DefaultMaxNodes},
+		{&d.MaxStringLength, DefaultMaxStringLength},
+	}
+	for _, f := range defaults {
+		switch {
+		case *f.field == 0:
+			*f.field = f.value
+		case *f.field < 0:
+			*f.field = 0
+		}
+	}
+}
+
+// LoopPrevention stamps forwarded messages with the routes they have crossed so that
+// chained bridges drop messages that loop back instead of forwarding them forever.
+type LoopPrevention struct {
+	// BridgeID names this bridge in the x-bridge-path header; it defaults to clientId.
+	BridgeID string `yaml:"bridgeId"`
+	// MaxHops drops messages that already crossed this many routes; -1 disables the limit.
+	MaxHops int `yaml:"maxHops"`
+}
+
+// DefaultMaxHops is the hop limit applied when loopPrevention.maxHops is unset.
+const DefaultMaxHops = 8
+
+func (l *LoopPrevention) validate(clientID string) error {
+	if l.BridgeID == "" {
+		l.BridgeID = clientID
+	}
+	if strings.ContainsAny(l.BridgeID, ",/") {
+		return fmt.Errorf("bridgeId %q cannot contain ',' or '/'", l.BridgeID)
+	}
+	switch {
+	case l.MaxHops == 0:
+		l.MaxHops = DefaultMaxHops
+	case l.MaxHops < -1:
+		return errors.New("maxHops must be positive or -1")
+	}
+	return nil
+}
+
+// Watchdog enables the leak detector that flags monotonic growth of goroutines, heap,
+// Kafka clients, and per-route cache sizes.
+type Watchdog struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Window is how many consecutive non-decreasing samples count as a trend.
+	Window int `yaml:"window"`
+	// MinGrowth is the fractional growth across the window that is flagged (e.g. 0.1).
+	MinGrowth float64 `yaml:"minGrowth"`
+	// ProfileDir, when set, receives a heap profile whenever growth is flagged.
+	ProfileDir string `yaml:"profileDir"`
 }
 
 // Load parses the YAML configuration.
@@ -83,10 +1707,22 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	if err := expandRouteTemplates(&doc); err != nil {
+		return nil, fmt.Errorf("expand config: %w", err)
+	}
+	if err := interpolate(&doc); err != nil {
+		return nil, fmt.Errorf("interpolate config: %w", err)
+	}
+	var cfg Config
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
 
 	if cfg.CommitInterval == 0 {
 		cfg.CommitInterval = defaultCommitInterval
@@ -95,6 +1731,9 @@ func Load(path string) (*Config, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	if err := cfg.bindSecrets(context.Background()); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
@@ -126,6 +1765,9 @@ func (c *Config) Validate() error {
 	if len(c.Routes) == 0 {
 		return errors.New("at least one route must be defined")
 	}
+	if err := c.applyDestinationNaming(); err != nil {
+		return err
+	}
 	for i := range c.Routes {
 		if err := c.Routes[i].validate(i); err != nil {
 			return err
@@ -133,6 +1775,35 @@ func (c *Config) Validate() error {
 		if _, ok := sourceClusterNames[c.Routes[i].SourceCluster]; !ok {
 			return fmt.Errorf("route %d: sourceCluster %q not found", i, c.Routes[i].SourceCluster)
 		}
+		if c.Routes[i].MaxMessageAge == 0 {
+			c.Routes[i].MaxMessageAge = c.MaxMessageAge
+		}
+		if c.Routes[i].Chaos != nil && !c.UnsafeChaos {
+			return fmt.Errorf("route %d: chaos requires unsafeChaos: true", i)
+		}
+		for j, src := range c.Routes[i].Sources {
+			if _, ok := sourceClusterNames[src.Cluster]; !ok {
+				return fmt.Errorf("route %d: sources[%d]: cluster %q not found", i, j, src.Cluster)
+			}
+		}
+	}
+	if err := c.validateRouteGroups(); err != nil {
+		return err
+	}
+	if err := c.validatePipelines(); err != nil {
+		return err
+	}
+	if c.MaxMessageAge < 0 {
+		return errors.New("maxMessageAge cannot be negative")
+	}
+	if err := c.Memory.validate(); err != nil {
+		return fmt.Errorf("memory: %w", err)
+	}
+	if c.Memory.Policy == "" {
+		c.Memory.Policy = MemoryPausePolicy
+	}
+	if c.Memory.CheckInterval == 0 {
+		c.Memory.CheckInterval = DefaultMemoryCheckInterval
 	}
 	if c.HTTP.ListenAddr == "" {
 		c.HTTP.ListenAddr = ":8080"
@@ -140,6 +1811,55 @@ func (c *Config) Validate() error {
 	if c.Storage.FlushInterval == 0 {
 		c.Storage.FlushInterval = 10 * time.Second
 	}
+	if err := c.Storage.validate(); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	if err := c.ReferenceState.validate(c.Storage); err != nil {
+		return fmt.Errorf("referenceState: %w", err)
+	}
+	if err := c.Secrets.validate(); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if err := c.validateBloomStorage(); err != nil {
+		return err
+	}
+	if err := c.validateRouteStorage(); err != nil {
+		return err
+	}
+	if c.SchemaDrift.BaselineMessages < 0 || c.SchemaDrift.SourceSampleEvery < 0 {
+		return errors.New("schemaDrift: baselineMessages and sourceSampleEvery cannot be negative")
+	}
+	c.DecodeLimits.applyDefaults()
+	if err := c.LoopPrevention.validate(c.ClientID); err != nil {
+		return fmt.Errorf("loopPrevention: %w", err)
+	}
+	if c.Watchdog.Interval < 0 || c.Watchdog.Window < 0 || c.Watchdog.MinGrowth < 0 {
+		return errors.New("watchdog: interval, window, and minGrowth cannot be negative")
+	}
+	if err := c.Audit.validate(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if err := c.Logging.validate(); err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+	if c.Coordination.Topic != "" && c.Coordination.InstanceID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("coordination: instanceId not set and hostname unavailable: %w", err)
+		}
+		c.Coordination.InstanceID = host
+	}
+	if le := &c.Coordination.LeaderElection; le.Enabled {
+		if c.Coordination.Topic == "" {
+			return errors.New("coordination: leaderElection requires topic, which carries the leader's cache changes")
+		}
+		if le.Topic == "" {
+			le.Topic = c.Coordination.Topic + "-leader"
+		}
+		if le.GroupID == "" {
+			le.GroupID = c.ReferenceGroupID + "-leader"
+		}
+	}
 	return nil
 }
 
@@ -162,6 +1882,30 @@ func (c ClusterConfig) validate() error {
 			return err
 		}
 	}
+	if c.SASL != nil {
+		if err := c.SASL.validate(); err != nil {
+			return fmt.Errorf("sasl: %w", err)
+		}
+	}
+	if err := c.Fetch.validate(); err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	if c.Writers.IdleTimeout < 0 {
+		return errors.New("writers: idleTimeout cannot be negative")
+	}
+	return nil
+}
+
+func (s *SASLConfig) validate() error {
+	switch strings.ToUpper(s.Mechanism) {
+	case SASLMechanismOAuthBearer:
+		s.Mechanism = SASLMechanismOAuthBearer
+	default:
+		return fmt.Errorf("unsupported mechanism %q (want OAUTHBEARER)", s.Mechanism)
+	}
+	if s.OAuth == nil || s.OAuth.TokenURL == "" || s.OAuth.ClientID == "" {
+		return errors.New("oauth.tokenUrl and oauth.clientId are required for OAUTHBEARER")
+	}
 	return nil
 }
 
@@ -172,11 +1916,7 @@ func (s SourceCluster) validate() error {
 	if s.SourceGroupID == "" {
 		return errors.New("sourceGroupId is required")
 	}
-	cfg := ClusterConfig{
-		Brokers: s.Brokers,
-		TLS:     s.TLS,
-	}
-	if err := cfg.validate(); err != nil {
+	if err := s.ClusterConfig().validate(); err != nil {
 		return err
 	}
 	return nil
@@ -186,7 +1926,43 @@ func (s SourceCluster) ClusterConfig() ClusterConfig {
 	return ClusterConfig{
 		Brokers: s.Brokers,
 		TLS:     s.TLS,
+		SASL:    s.SASL,
+		Fetch:   s.Fetch,
+	}
+}
+
+func (s *Storage) validate() error {
+	if s.Backend == "" {
+		s.Backend = StorageBackendFile
+	}
+	switch s.Backend {
+	case StorageBackendFile:
+	case StorageBackendKafka:
+		if s.Topic == "" {
+			return errors.New("topic is required for the kafka backend")
+		}
+	case StorageBackendSQLite:
+		if s.Path == "" {
+			return errors.New("path is required for the sqlite backend")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q", s.Backend)
+	}
+	if s.Replicate && s.Backend != StorageBackendKafka {
+		return errors.New("replicate requires the kafka backend")
+	}
+	if s.WAL && (s.Backend != StorageBackendFile || s.Path == "") {
+		return errors.New("wal requires the file backend with a path")
+	}
+	if s.PersistCounters && s.Path == "" {
+		return errors.New("persistCounters requires the file or sqlite backend with a path")
 	}
+	switch s.Compression {
+	case "", StorageCompressionNone, StorageCompressionGzip:
+	default:
+		return fmt.Errorf("unknown compression %q (want none or gzip)", s.Compression)
+	}
+	return nil
 }
 
 func (t *TLSConfig) validate() error {
@@ -203,18 +1979,177 @@ func (t *TLSConfig) validate() error {
 }
 
 func (r *Route) validate(idx int) error {
+	if r.SourceCluster == "" && len(r.Sources) > 0 {
+		r.SourceCluster = r.Sources[0].Cluster
+	}
 	if r.SourceCluster == "" {
 		return fmt.Errorf("route %d: sourceCluster is required", idx)
 	}
-	if r.SourceTopic == "" {
-		return fmt.Errorf("route %d: sourceTopic cannot be empty", idx)
+	if len(r.Sources) > 0 {
+		if err := r.validateSources(); err != nil {
+			return fmt.Errorf("route %d: %w", idx, err)
+		}
+	} else if err := r.validateSourceTopic(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if err := r.Destination.validate(); err != nil {
+		return fmt.Errorf("route %d: destination: %w", idx, err)
 	}
-	if r.DestinationTopic == "" {
+	switch {
+	case r.Destination.Type == DestinationKafka && r.DestinationTopic == "":
 		return fmt.Errorf("route %d: destinationTopic is required", idx)
+	case r.Destination.Type == DestinationWebhook && r.Name == "" && r.DestinationTopic == "":
+		return fmt.Errorf("route %d: name is required for a webhook destination", idx)
+	case r.Destination.Type == DestinationWebhook && r.Compacted:
+		return fmt.Errorf("route %d: compacted requires a kafka destination", idx)
+	case r.Destination.Type == DestinationWebhook && r.Delivery.Partitioner != "":
+		return fmt.Errorf("route %d: delivery.partitioner requires a kafka destination", idx)
+	case r.Destination.Type == DestinationWebhook && r.Delivery.Compression != "":
+		return fmt.Errorf("route %d: delivery.compression requires a kafka destination", idx)
+	case r.Destination.Type == DestinationWebhook && r.Delivery.Async:
+		return fmt.Errorf("route %d: delivery.async requires a kafka destination", idx)
+	case r.Compacted && (r.Delivery.Partitioner == PartitionerRoundRobin || r.Delivery.KeyField != ""):
+		return fmt.Errorf("route %d: compacted routes keep their source keys and partitions; use the source or key partitioner without keyField", idx)
 	}
 	if len(r.ReferenceFeeds) == 0 {
 		return fmt.Errorf("route %d: referenceFeeds cannot be empty", idx)
 	}
+	if r.MaxValues < 0 {
+		return fmt.Errorf("route %d: maxValues cannot be negative", idx)
+	}
+	if r.MaxInFlight < 0 {
+		return fmt.Errorf("route %d: maxInFlight cannot be negative", idx)
+	}
+	switch r.OnDecodeError {
+	case "", OnDecodeErrorSkip:
+	case OnDecodeErrorForward:
+		if r.Compacted {
+			return fmt.Errorf("route %d: onDecodeError forward cannot be combined with compacted", idx)
+		}
+	case OnDecodeErrorDLQ:
+		if r.DecodeErrorTopic == "" {
+			return fmt.Errorf("route %d: onDecodeError dlq requires decodeErrorTopic", idx)
+		}
+	default:
+		return fmt.Errorf("route %d: unknown onDecodeError %q (want skip, forward, or dlq)", idx, r.OnDecodeError)
+	}
+	if r.DecodeErrorTopic != "" && r.OnDecodeError != OnDecodeErrorDLQ {
+		return fmt.Errorf("route %d: decodeErrorTopic requires onDecodeError dlq", idx)
+	}
+	if r.RejectedTopic == "" && r.RejectedSampleRatio != 0 {
+		return fmt.Errorf("route %d: rejectedSampleRatio requires rejectedTopic", idx)
+	}
+	if r.RejectedTopic != "" {
+		if r.RejectedSampleRatio == 0 {
+			r.RejectedSampleRatio = 1
+		}
+		if r.RejectedSampleRatio < 0 || r.RejectedSampleRatio > 1 {
+			return fmt.Errorf("route %d: rejectedSampleRatio %v must be above 0 and at most 1", idx, r.RejectedSampleRatio)
+		}
+		if r.RejectedTopic == r.SourceTopic {
+			return fmt.Errorf("route %d: rejectedTopic cannot be the source topic", idx)
+		}
+	}
+	if r.MaxValues > 0 && r.Eviction == "" {
+		r.Eviction = EvictionLRU
+	}
+	switch r.Eviction {
+	case "", EvictionLRU, EvictionLFU, EvictionRejectNew:
+	default:
+		return fmt.Errorf("route %d: unknown eviction %q (want lru, lfu, or reject-new)", idx, r.Eviction)
+	}
+	if err := r.validateExpiry(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if err := r.Consumer.validate(); err != nil {
+		return fmt.Errorf("route %d: consumer: %w", idx, err)
+	}
+	if err := r.Headers.validate(); err != nil {
+		return fmt.Errorf("route %d: headers: %w", idx, err)
+	}
+	for _, filter := range r.SourceHeaderFilters {
+		if name, _, ok := strings.Cut(filter, "="); !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("route %d: sourceHeaderFilters entry %q must be name=value", idx, filter)
+		}
+	}
+	if err := r.validateDestinationKey(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if err := r.Delivery.validate(); err != nil {
+		return fmt.Errorf("route %d: delivery: %w", idx, err)
+	}
+	if err := r.Archive.validate(); err != nil {
+		return fmt.Errorf("route %d: archive: %w", idx, err)
+	}
+	if err := r.Dedup.validate(); err != nil {
+		return fmt.Errorf("route %d: dedup: %w", idx, err)
+	}
+	if err := r.ReferenceWarmup.validate(); err != nil {
+		return fmt.Errorf("route %d: referenceWarmup: %w", idx, err)
+	}
+	if r.ReferenceWarmup != nil && len(r.ReferenceFeeds) == 0 {
+		return fmt.Errorf("route %d: referenceWarmup needs referenceFeeds to wait for", idx)
+	}
+	if r.Memory != nil {
+		if err := r.Memory.validate(); err != nil {
+			return fmt.Errorf("route %d: memory: %w", idx, err)
+		}
+		if r.Memory.MaxBytes == 0 {
+			return fmt.Errorf("route %d: memory: maxBytes is required", idx)
+		}
+		if r.Memory.CheckInterval != 0 {
+			return fmt.Errorf("route %d: memory: checkInterval is global; set it in the top-level memory block", idx)
+		}
+	}
+	if r.MaxMessageAge < 0 {
+		return fmt.Errorf("route %d: maxMessageAge cannot be negative", idx)
+	}
+	if r.Chaos != nil {
+		if err := r.Chaos.validate(); err != nil {
+			return fmt.Errorf("route %d: chaos: %w", idx, err)
+		}
+	}
+	if err := r.ReferenceHTTP.validate(); err != nil {
+		return fmt.Errorf("route %d: referenceHTTP: %w", idx, err)
+	}
+	if err := r.ReferenceSQL.validate(); err != nil {
+		return fmt.Errorf("route %d: referenceSQL: %w", idx, err)
+	}
+	if err := r.validateTimeWindow(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if err := r.validateBloomFilter(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if r.Dedup != nil && r.Compacted {
+		return fmt.Errorf("route %d: dedup cannot be combined with compacted; a compacted destination already keeps one record per key", idx)
+	}
+	if err := r.Payload.validate(); err != nil {
+		return fmt.Errorf("route %d: payload: %w", idx, err)
+	}
+	if !validPayloadFormat(r.PayloadFormat) {
+		return fmt.Errorf("route %d: unknown payloadFormat %q (want json, xml, or cloudevents)", idx, r.PayloadFormat)
+	}
+	if len(r.EventFilter) > 0 && r.PayloadFormat != PayloadFormatCloudEvents {
+		return fmt.Errorf("route %d: eventFilter requires payloadFormat cloudevents", idx)
+	}
+	for name, pattern := range r.EventFilter {
+		if name == "" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+			return fmt.Errorf("route %d: eventFilter %q: %q is not an exact value or a prefix ending in *", idx, name, pattern)
+		}
+	}
+	if err := r.validateMatchStrategy(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
+	if err := r.HashValues.validate(); err != nil {
+		return fmt.Errorf("route %d: hashValues: %w", idx, err)
+	}
+	if r.HashValues != nil && (r.Prefilter || r.MatchStrategy == MatchStrategyBytesContains) {
+		return fmt.Errorf("route %d: hashValues cannot be combined with prefilter or matchStrategy bytesContains, which search payloads for the plaintext values", idx)
+	}
+	if err := r.validateOutput(); err != nil {
+		return fmt.Errorf("route %d: %w", idx, err)
+	}
 	feedNames := make(map[string]struct{}, len(r.ReferenceFeeds))
 	for fi, feed := range r.ReferenceFeeds {
 		if feed.Name == "" {
@@ -233,24 +2168,224 @@ func (r *Route) validate(idx int) error {
 				return fmt.Errorf("route %d: reference feed %q topicHeaders entry %q must be key=value", idx, feed.DisplayName(), th)
 			}
 		}
-		if len(feed.MatchFields) == 0 {
-			return fmt.Errorf("route %d: reference feed %q matchFields cannot be empty", idx, feed.DisplayName())
+		switch header, isHeader := strings.CutPrefix(feed.MatchSource, MatchSourceHeaderPrefix); {
+		case feed.MatchSource == "" || feed.MatchSource == MatchSourceValue:
+			if len(feed.MatchFields) == 0 {
+				return fmt.Errorf("route %d: reference feed %q matchFields cannot be empty", idx, feed.DisplayName())
+			}
+		case feed.MatchSource == MatchSourceKey || (isHeader && header != ""):
+			if len(feed.MatchFields) > 0 || feed.RecordsPath != "" {
+				return fmt.Errorf("route %d: reference feed %q matchSource %s cannot be combined with matchFields or recordsPath", idx, feed.DisplayName(), feed.MatchSource)
+			}
+		default:
+			return fmt.Errorf("route %d: reference feed %q has unknown matchSource %q (want value, key, or header:<name>)", idx, feed.DisplayName(), feed.MatchSource)
+		}
+		if !validPayloadFormat(feed.PayloadFormat) && feed.PayloadFormat != PayloadFormatCSV {
+			return fmt.Errorf("route %d: reference feed %q has unknown payloadFormat %q (want json, xml, cloudevents, or csv)", idx, feed.DisplayName(), feed.PayloadFormat)
+		}
+		if err := feed.validateCSV(); err != nil {
+			return fmt.Errorf("route %d: reference feed %q: %w", idx, feed.DisplayName(), err)
+		}
+		if err := feed.validateCanonicalize(); err != nil {
+			return fmt.Errorf("route %d: reference feed %q: %w", idx, feed.DisplayName(), err)
+		}
+		if len(feed.Canonicalize) > 0 && (r.Prefilter || r.MatchStrategy == MatchStrategyBytesContains) {
+			return fmt.Errorf("route %d: reference feed %q canonicalize needs decoded source values; remove prefilter and matchStrategy bytesContains", idx, feed.DisplayName())
+		}
+		if feed.RecordsPath != "" && slices.Contains(strings.Split(feed.RecordsPath, "."), "") {
+			return fmt.Errorf("route %d: reference feed %q recordsPath %q is invalid", idx, feed.DisplayName(), feed.RecordsPath)
 		}
 		for _, field := range feed.MatchFields {
-			parts := strings.Split(field, ".")
-			if len(parts) == 0 || len(parts) > 2 {
-				return fmt.Errorf("route %d: match field %q must be 'field' or 'parent.child'", idx, field)
-			}
-			for _, part := range parts {
-				if part == "" {
-					return fmt.Errorf("route %d: match field %q is invalid", idx, field)
+			// a|b|c lists fallback paths tried in order
+			for _, path := range strings.Split(field, "|") {
+				parts := strings.Split(path, ".")
+				// a * segment stands for every key of a map and is not counted as a level
+				named := 0
+				for _, part := range parts {
+					if part != "*" {
+						named++
+					}
+				}
+				if feed.PayloadFormat != PayloadFormatXML && named > 2 {
+					return fmt.Errorf("route %d: match field %q must be 'field' or 'parent.child', with optional * segments", idx, field)
+				}
+				if feed.PayloadFormat == PayloadFormatCSV && len(parts) > 1 {
+					return fmt.Errorf("route %d: match field %q must be a csv column name or index", idx, field)
 				}
+				if named == 0 {
+					return fmt.Errorf("route %d: match field %q needs a named segment besides *", idx, field)
+				}
+				for _, part := range parts {
+					if part == "" || (part != "*" && strings.Contains(part, "*")) {
+						return fmt.Errorf("route %d: match field %q is invalid", idx, field)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateRouteGroups requires the routes of each group to read the same source topic.
+func (c *Config) validateRouteGroups() error {
+	first := make(map[string]int)
+	for i, r := range c.Routes {
+		if r.Group == "" {
+			continue
+		}
+		j, ok := first[r.Group]
+		if !ok {
+			first[r.Group] = i
+			continue
+		}
+		if other := c.Routes[j]; other.SourceCluster != r.SourceCluster || other.SourceTopic != r.SourceTopic {
+			return fmt.Errorf("route %d: group %q mixes source topics: %s/%s and %s/%s", i, r.Group, other.SourceCluster, other.SourceTopic, r.SourceCluster, r.SourceTopic)
+		}
+	}
+	return nil
+}
+
+// Pipeline names routes that feed one another: a route whose destinationTopic is the
+// topic of a reference feed of another route of the pipeline runs upstream of it, and
+// that route starts streaming only once it does.
+type Pipeline struct {
+	Name   string   `yaml:"name"`
+	Routes []string `yaml:"routes"`
+}
+
+// validatePipelines requires every route of a pipeline to exist, to belong to no other
+// pipeline, and to feed or be fed by another of its routes, and the routes not to feed
+// one another in a cycle.
+func (c *Config) validatePipelines() error {
+	names := make(map[string]bool, len(c.Pipelines))
+	member := make(map[string]string)
+	for i, p := range c.Pipelines {
+		if p.Name == "" {
+			return fmt.Errorf("pipeline %d: name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("pipeline %d: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+		if len(p.Routes) < 2 {
+			return fmt.Errorf("pipeline %s: needs at least two routes", p.Name)
+		}
+		for _, name := range p.Routes {
+			if _, ok := c.routeNamed(name); !ok {
+				return fmt.Errorf("pipeline %s: route %q not found", p.Name, name)
+			}
+			if other, ok := member[name]; ok {
+				return fmt.Errorf("pipeline %s: route %s already belongs to pipeline %s", p.Name, name, other)
+			}
+			member[name] = p.Name
+		}
+		edges := c.pipelineEdges(p)
+		for _, name := range p.Routes {
+			linked := len(edges[name]) > 0
+			for _, downstream := range edges {
+				linked = linked || slices.Contains(downstream, name)
+			}
+			if !linked {
+				return fmt.Errorf("pipeline %s: route %s neither feeds nor reads the destinationTopic of another of its routes", p.Name, name)
 			}
 		}
+		if _, err := c.PipelineOrder(p); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// routeNamed returns the route called name.
+func (c *Config) routeNamed(name string) (Route, bool) {
+	for _, r := range c.Routes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Route{}, false
+}
+
+// pipelineEdges maps each route of p to the routes of p it feeds: those with a reference
+// feed on its Kafka destinationTopic.
+func (c *Config) pipelineEdges(p Pipeline) map[string][]string {
+	edges := make(map[string][]string, len(p.Routes))
+	for _, from := range p.Routes {
+		up, _ := c.routeNamed(from)
+		if up.Destination.Type == DestinationWebhook || up.DestinationTemplated() {
+			continue
+		}
+		for _, to := range p.Routes {
+			down, _ := c.routeNamed(to)
+			if to != from && slices.ContainsFunc(down.ReferenceFeeds, func(f ReferenceFeed) bool { return f.Topic == up.DestinationTopic }) {
+				edges[from] = append(edges[from], to)
+			}
+		}
+	}
+	return edges
+}
+
+// PipelineOrder returns the routes of p in the order they start, each after every route
+// feeding it, or an error naming a cycle of routes that feed one another.
+func (c *Config) PipelineOrder(p Pipeline) ([]string, error) {
+	edges := c.pipelineEdges(p)
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(p.Routes))
+	var order, path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			cycle := append(path[slices.Index(path, name):], name)
+			return fmt.Errorf("pipeline %s: routes feed one another in a cycle: %s", p.Name, strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, next := range edges[name] {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range p.Routes {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	slices.Reverse(order)
+	return order, nil
+}
+
+// PipelineUpstreams returns the routes of route's pipeline that feed it directly.
+func (c *Config) PipelineUpstreams(route Route) []Route {
+	if route.Name == "" {
+		return nil
+	}
+	var upstreams []Route
+	for _, p := range c.Pipelines {
+		if !slices.Contains(p.Routes, route.Name) {
+			continue
+		}
+		for from, downstream := range c.pipelineEdges(p) {
+			if slices.Contains(downstream, route.Name) {
+				up, _ := c.routeNamed(from)
+				upstreams = append(upstreams, up)
+			}
+		}
+	}
+	slices.SortFunc(upstreams, func(a, b Route) int { return strings.Compare(a.Name, b.Name) })
+	return upstreams
+}
+
 // DisplayName returns an identifier for logs.
 func (r Route) DisplayName() string {
 	if r.Name != "" {
@@ -275,6 +2410,11 @@ func (c ClusterConfig) TLSConfigObject() (*tls.Config, error) {
 	return c.TLS.tlsConfig()
 }
 
+// TLSConfigObject builds a tls.Config for webhook requests; nil uses Go's defaults.
+func (w Webhook) TLSConfigObject() (*tls.Config, error) {
+	return w.TLS.tlsConfig()
+}
+
 func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
 	if t == nil {
 		return nil, nil
@@ -286,15 +2426,22 @@ func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
 	}
 
 	if t.CertFile != "" && t.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		cert, err := t.clientCertificate()
 		if err != nil {
-			return nil, fmt.Errorf("load client cert: %w", err)
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+		if isSecretRef(t.CertFile) || isSecretRef(t.KeyFile) {
+			// resolve on every handshake so renewed certificates are used
+			tlsConfig.Certificates = nil
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return t.clientCertificate()
+			}
 		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
 	if t.CAFile != "" {
-		caData, err := os.ReadFile(t.CAFile)
+		caData, err := t.readPEM(t.CAFile)
 		if err != nil {
 			return nil, fmt.Errorf("read ca file: %w", err)
 		}
@@ -307,3 +2454,28 @@ func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
 
 	return tlsConfig, nil
 }
+
+func (t *TLSConfig) clientCertificate() (*tls.Certificate, error) {
+	certPEM, err := t.readPEM(t.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client cert: %w", err)
+	}
+	keyPEM, err := t.readPEM(t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client key: %w", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load client cert: %w", err)
+	}
+	return &cert, nil
+}
+
+// readPEM reads value as a file path, or resolves it when it is a secret reference.
+func (t *TLSConfig) readPEM(value string) ([]byte, error) {
+	if t.secrets != nil && isSecretRef(value) {
+		data, err := t.secrets.Resolve(context.Background(), value)
+		return []byte(data), err
+	}
+	return os.ReadFile(value)
+}
@@ -0,0 +1,1003 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteValidateMatchFields(t *testing.T) {
+	cases := []struct {
+		field   string
+		wantErr bool
+	}{
+		{field: "fieldA"},
+		{field: "subObj.fieldB"},
+		{field: "caseId|legacyCaseId|case.id"},
+		{field: "a.b.c", wantErr: true},
+		{field: "caseId||case.id", wantErr: true},
+		{field: "caseId|a.b.c", wantErr: true},
+		{field: "accounts.*.number"},
+		{field: "accounts.*"},
+		{field: "*.id|caseId"},
+		{field: "*", wantErr: true},
+		{field: "accounts.a*.number", wantErr: true},
+		{field: "a.*.b.c", wantErr: true},
+	}
+	for _, tc := range cases {
+		route := Route{
+			SourceCluster:    "source-a",
+			SourceTopic:      "source",
+			DestinationTopic: "dest",
+			ReferenceFeeds: []ReferenceFeed{
+				{Name: "feed", Topic: "ref", MatchFields: []string{tc.field}},
+			},
+		}
+		err := route.validate(0)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("field %q: validate error = %v, wantErr %v", tc.field, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRouteValidateEviction(t *testing.T) {
+	cases := []struct {
+		maxValues int
+		eviction  string
+		want      string
+		wantErr   bool
+	}{
+		{maxValues: 0, eviction: "", want: ""},
+		{maxValues: 100, eviction: "", want: EvictionLRU},
+		{maxValues: 100, eviction: EvictionLFU, want: EvictionLFU},
+		{maxValues: 100, eviction: EvictionRejectNew, want: EvictionRejectNew},
+		{maxValues: 100, eviction: "fifo", wantErr: true},
+		{maxValues: -1, wantErr: true},
+	}
+	for _, tc := range cases {
+		route := Route{
+			SourceCluster:    "source-a",
+			SourceTopic:      "source",
+			DestinationTopic: "dest",
+			ReferenceFeeds:   []ReferenceFeed{{Name: "feed", Topic: "ref", MatchFields: []string{"id"}}},
+			MaxValues:        tc.maxValues,
+			Eviction:         tc.eviction,
+		}
+		err := route.validate(0)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("maxValues=%d eviction=%q: validate error = %v", tc.maxValues, tc.eviction, err)
+		}
+		if !tc.wantErr && route.Eviction != tc.want {
+			t.Fatalf("maxValues=%d: eviction = %q, want %q", tc.maxValues, route.Eviction, tc.want)
+		}
+	}
+}
+
+func TestDecodeLimitsDefaults(t *testing.T) {
+	limits := DecodeLimits{MaxNodes: 10, MaxStringLength: -1}
+	limits.applyDefaults()
+	want := DecodeLimits{MaxDepth: DefaultMaxDepth, MaxNodes: 10, MaxStringLength: 0}
+	if limits != want {
+		t.Fatalf("applyDefaults = %+v, want %+v", limits, want)
+	}
+}
+
+func TestConsumerValidate(t *testing.T) {
+	cases := []struct {
+		consumer Consumer
+		wantErr  bool
+	}{
+		{consumer: Consumer{}},
+		{consumer: Consumer{StartOffset: StartOffsetEarliest, MinBytes: 1, MaxBytes: 10}},
+		{consumer: Consumer{StartOffset: "2024-01-02T03:04:05Z"}},
+		{consumer: Consumer{StartOffset: "yesterday"}, wantErr: true},
+		{consumer: Consumer{MinBytes: 10, MaxBytes: 1}, wantErr: true},
+		{consumer: Consumer{CommitInterval: -1}, wantErr: true},
+		{consumer: Consumer{QueueCapacity: -1}, wantErr: true},
+		{consumer: Consumer{SessionTimeout: -time.Second}, wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := tc.consumer.validate(); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.consumer, err, tc.wantErr)
+		}
+	}
+	if at, ok := (Consumer{StartOffset: "2024-01-02T03:04:05Z"}).StartTime(); !ok || at.Year() != 2024 {
+		t.Fatalf("StartTime = %v, %v", at, ok)
+	}
+}
+
+func TestFetchOverride(t *testing.T) {
+	cluster := Fetch{MinBytes: 1, MaxBytes: 10, MaxWait: time.Second, QueueCapacity: 100}
+	got := cluster.Override(Consumer{MaxBytes: 20, SessionTimeout: time.Minute}.Fetch())
+	want := Fetch{MinBytes: 1, MaxBytes: 20, MaxWait: time.Second, QueueCapacity: 100, SessionTimeout: time.Minute}
+	if got != want {
+		t.Fatalf("Override = %+v, want %+v", got, want)
+	}
+	if err := (ClusterConfig{Brokers: []string{"b:9092"}, Fetch: Fetch{MinBytes: 10, MaxBytes: 1}}).validate(); err == nil {
+		t.Fatal("expected minBytes above maxBytes to be rejected")
+	}
+}
+
+func TestWritersValidate(t *testing.T) {
+	if err := (ClusterConfig{Brokers: []string{"b:9092"}, Writers: Writers{IdleTimeout: -time.Minute}}).validate(); err == nil {
+		t.Fatal("expected a negative idleTimeout to be rejected")
+	}
+	if err := (ClusterConfig{Brokers: []string{"b:9092"}, Writers: Writers{IdleTimeout: 10 * time.Minute}}).validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSASLValidate(t *testing.T) {
+	oauth := &OAuthConfig{TokenURL: "https://idp/token", ClientID: "bridge"}
+	cases := []struct {
+		sasl    SASLConfig
+		wantErr bool
+	}{
+		{sasl: SASLConfig{Mechanism: "oauthbearer", OAuth: oauth}},
+		{sasl: SASLConfig{Mechanism: "OAUTHBEARER"}, wantErr: true},
+		{sasl: SASLConfig{Mechanism: "OAUTHBEARER", OAuth: &OAuthConfig{TokenURL: "https://idp/token"}}, wantErr: true},
+		{sasl: SASLConfig{Mechanism: "PLAIN", OAuth: oauth}, wantErr: true},
+	}
+	for _, tc := range cases {
+		err := tc.sasl.validate()
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.sasl, err, tc.wantErr)
+		}
+		if err == nil && tc.sasl.Mechanism != SASLMechanismOAuthBearer {
+			t.Fatalf("expected mechanism normalised, got %q", tc.sasl.Mechanism)
+		}
+	}
+}
+
+func TestLoopPreventionValidate(t *testing.T) {
+	cases := []struct {
+		in      LoopPrevention
+		want    LoopPrevention
+		wantErr bool
+	}{
+		{in: LoopPrevention{}, want: LoopPrevention{BridgeID: "bridge", MaxHops: DefaultMaxHops}},
+		{in: LoopPrevention{BridgeID: "eu", MaxHops: -1}, want: LoopPrevention{BridgeID: "eu", MaxHops: -1}},
+		{in: LoopPrevention{BridgeID: "eu/1"}, wantErr: true},
+		{in: LoopPrevention{MaxHops: -2}, wantErr: true},
+	}
+	for _, tc := range cases {
+		got := tc.in
+		err := got.validate("bridge")
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if !tc.wantErr && got != tc.want {
+			t.Fatalf("%+v: got %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRouteExpiry(t *testing.T) {
+	cases := []struct {
+		name    string
+		route   Route
+		want    string
+		wantErr bool
+	}{
+		{name: "none", route: Route{}},
+		{name: "timestamp", route: Route{ExpiresAt: "2024-05-01T12:00:00+02:00"}, want: "2024-05-01T10:00:00Z"},
+		{name: "date", route: Route{ExpiresAt: "2024-05-01"}, want: "2024-05-01T00:00:00Z"},
+		{name: "ttl", route: Route{CreatedAt: "2024-05-01", TTL: 36 * time.Hour}, want: "2024-05-02T12:00:00Z"},
+		{name: "ttl without createdAt", route: Route{TTL: time.Hour}, wantErr: true},
+		{name: "both", route: Route{ExpiresAt: "2024-05-01", TTL: time.Hour, CreatedAt: "2024-04-01"}, wantErr: true},
+		{name: "bad date", route: Route{ExpiresAt: "next week"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		err := tc.route.validateExpiry()
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: validateExpiry error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			continue
+		}
+		at, ok := tc.route.Expiry()
+		if ok != (tc.want != "") {
+			t.Fatalf("%s: Expiry ok = %v", tc.name, ok)
+		}
+		if ok && at.UTC().Format(time.RFC3339) != tc.want {
+			t.Fatalf("%s: Expiry = %s, want %s", tc.name, at.UTC().Format(time.RFC3339), tc.want)
+		}
+	}
+}
+
+func TestHeaderPolicyValidate(t *testing.T) {
+	cases := []struct {
+		policy  HeaderPolicy
+		wantErr bool
+	}{
+		{policy: HeaderPolicy{Include: []string{"trace-*", "content-type"}, Exclude: []string{"*"}}},
+		{policy: HeaderPolicy{Include: []string{""}}, wantErr: true},
+		{policy: HeaderPolicy{Exclude: []string{"x-*-id"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := tc.policy.validate(); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.policy, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLeaderElectionDefaults(t *testing.T) {
+	base := func() Config {
+		return Config{
+			SourceClusters:   []SourceCluster{{Name: "a", Brokers: []string{"a:9092"}, SourceGroupID: "src"}},
+			BridgeCluster:    ClusterConfig{Brokers: []string{"b:9092"}},
+			ClientID:         "bridge",
+			ReferenceGroupID: "refs",
+			Routes: []Route{{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out",
+				ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}},
+		}
+	}
+	cfg := base()
+	cfg.Coordination = Coordination{Topic: "coord", InstanceID: "i1", LeaderElection: LeaderElection{Enabled: true}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if le := cfg.Coordination.LeaderElection; le.Topic != "coord-leader" || le.GroupID != "refs-leader" {
+		t.Fatalf("unexpected defaults: %+v", le)
+	}
+	cfg = base()
+	cfg.Coordination.LeaderElection.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected leaderElection without coordination.topic to fail")
+	}
+}
+
+func TestStorageValidateReplicate(t *testing.T) {
+	cases := []struct {
+		storage Storage
+		wantErr bool
+	}{
+		{storage: Storage{Backend: StorageBackendKafka, Topic: "state", Replicate: true}},
+		{storage: Storage{Backend: StorageBackendSQLite, Path: "cache.db", Replicate: true}, wantErr: true},
+		{storage: Storage{Replicate: true}, wantErr: true},
+		{storage: Storage{Path: "cache.json", WAL: true}},
+		{storage: Storage{WAL: true}, wantErr: true},
+		{storage: Storage{Backend: StorageBackendSQLite, Path: "cache.db", WAL: true}, wantErr: true},
+		{storage: Storage{Backend: StorageBackendSQLite, Path: "cache.db", PersistCounters: true}},
+		{storage: Storage{Backend: StorageBackendKafka, Topic: "state", PersistCounters: true}, wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := tc.storage.validate(); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.storage, err, tc.wantErr)
+		}
+	}
+}
+
+func TestConfigValidateRouteStorage(t *testing.T) {
+	base := func(storage ...*Storage) Config {
+		cfg := Config{
+			SourceClusters:   []SourceCluster{{Name: "a", Brokers: []string{"a:9092"}, SourceGroupID: "src"}},
+			BridgeCluster:    ClusterConfig{Brokers: []string{"b:9092"}},
+			ClientID:         "bridge",
+			ReferenceGroupID: "refs",
+			Storage:          Storage{Path: "cache.json", Compression: StorageCompressionGzip, FlushInterval: time.Minute},
+		}
+		for i, s := range storage {
+			cfg.Routes = append(cfg.Routes, Route{SourceCluster: "a", SourceTopic: fmt.Sprintf("in-%d", i), DestinationTopic: "out",
+				ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}, Storage: s})
+		}
+		return cfg
+	}
+	cfg := base(nil, &Storage{Path: "route.json", WAL: true})
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if s := cfg.Routes[1].Storage; s.FlushInterval != time.Minute || s.Compression != StorageCompressionGzip || s.Backend != StorageBackendFile {
+		t.Fatalf("route storage did not inherit the global settings: %+v", s)
+	}
+	cases := []Config{
+		base(&Storage{}),
+		base(&Storage{Backend: StorageBackendSQLite, Path: "route.db"}),
+		base(&Storage{Path: "route.json", Topic: "state"}),
+		base(&Storage{Path: "route.json", FlushInterval: -time.Second}),
+		base(&Storage{Path: "./cache.json"}),
+		base(&Storage{Path: "a.json"}, &Storage{Path: "b.json", WAL: true}, &Storage{Path: "b.json.wal"}),
+		base(&Storage{Path: "route.json", Required: true}),
+		base(&Storage{Path: "route.json", PersistCounters: true}),
+	}
+	for _, cfg := range cases {
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected validation to fail for route storage %+v", cfg.Routes)
+		}
+	}
+
+	required := base(nil)
+	required.Storage = Storage{Required: true}
+	if err := required.Validate(); err == nil {
+		t.Fatal("expected required storage without anything to restore from to fail")
+	}
+	required = base(&Storage{Path: "route.json"})
+	required.Storage = Storage{Required: true}
+	if err := required.Validate(); err != nil {
+		t.Fatalf("expected required storage with route storage to pass: %v", err)
+	}
+}
+
+func TestRouteValidateSources(t *testing.T) {
+	route := func(sources ...RouteSource) Route {
+		return Route{SourceCluster: "a", DestinationTopic: "out", Sources: sources,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	r := route(RouteSource{Topic: "in-1"}, RouteSource{Cluster: "b", Topic: "in-1"}, RouteSource{Topic: "in-2"})
+	if err := r.validate(0); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if got := r.SourceClusterNames(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("SourceClusterNames = %v", got)
+	}
+	if got := r.SourceTopicsOn("a"); !slices.Equal(got, []string{"in-1", "in-2"}) {
+		t.Fatalf("SourceTopicsOn(a) = %v", got)
+	}
+
+	invalid := []Route{
+		route(RouteSource{}),
+		route(RouteSource{Topic: "in"}, RouteSource{Cluster: "a", Topic: "in"}),
+	}
+	withTopic := route(RouteSource{Topic: "in"})
+	withTopic.SourceTopic = "in"
+	compacted := route(RouteSource{Topic: "in"})
+	compacted.Compacted = true
+	grouped := route(RouteSource{Topic: "in"})
+	grouped.Group = "g"
+	invalid = append(invalid, withTopic, compacted, grouped)
+	for _, r := range invalid {
+		if err := r.validate(0); err == nil {
+			t.Fatalf("expected sources %+v to fail validation", r.Sources)
+		}
+	}
+}
+
+func TestReferenceWarmupValidate(t *testing.T) {
+	w := &ReferenceWarmup{MaxLag: 10}
+	if err := w.validate(); err != nil || w.Timeout != DefaultReferenceWarmupTimeout {
+		t.Fatalf("expected the default timeout, got %+v (%v)", w, err)
+	}
+	for _, w := range []*ReferenceWarmup{{MaxLag: -1}, {Timeout: -time.Second}} {
+		if err := w.validate(); err == nil {
+			t.Fatalf("%+v: expected validation to fail", w)
+		}
+	}
+	route := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out",
+		ReferenceHTTP: &ReferenceHTTP{URL: "http://refs.internal/values"}, ReferenceWarmup: &ReferenceWarmup{}}
+	if err := route.validate(0); err == nil {
+		t.Fatal("expected referenceWarmup without referenceFeeds to fail")
+	}
+}
+
+func TestMemoryValidate(t *testing.T) {
+	for _, m := range []Memory{{MaxBytes: -1}, {CheckInterval: -time.Second}, {Policy: "drop-everything"}} {
+		if err := m.validate(); err == nil {
+			t.Fatalf("%+v: expected validation to fail", m)
+		}
+	}
+	base := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out",
+		ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	for _, m := range []*Memory{{}, {MaxBytes: 1 << 20, CheckInterval: time.Second}} {
+		route := base
+		route.Memory = m
+		if err := route.validate(0); err == nil {
+			t.Fatalf("route memory %+v: expected validation to fail", m)
+		}
+	}
+	cfg := &Config{Memory: Memory{MaxBytes: 1 << 30, Policy: MemoryRefusePolicy}}
+	route := base
+	if cfg.MemoryPolicy(route) != MemoryRefusePolicy {
+		t.Fatal("expected a route without a budget to use the global policy")
+	}
+	route.Memory = &Memory{MaxBytes: 1 << 20, Policy: MemoryEvictPolicy}
+	if err := route.validate(0); err != nil || cfg.MemoryPolicy(route) != MemoryEvictPolicy {
+		t.Fatalf("expected the route's own policy, got %q (%v)", cfg.MemoryPolicy(route), err)
+	}
+}
+
+func TestValidatePipelines(t *testing.T) {
+	route := func(name, out string, refs ...string) Route {
+		r := Route{Name: name, SourceCluster: "a", SourceTopic: "in", DestinationTopic: out}
+		for _, ref := range refs {
+			r.ReferenceFeeds = append(r.ReferenceFeeds, ReferenceFeed{Topic: ref, MatchFields: []string{"id"}})
+		}
+		return r
+	}
+	cfg := &Config{
+		Routes: []Route{
+			route("orders", "orders.active", "customers.active"),
+			route("customers", "customers.active", "accounts"),
+			route("shipments", "shipments.active", "orders.active", "customers.active"),
+		},
+		Pipelines: []Pipeline{{Name: "active", Routes: []string{"shipments", "orders", "customers"}}},
+	}
+	if err := cfg.validatePipelines(); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := cfg.PipelineOrder(cfg.Pipelines[0]); !slices.Equal(order, []string{"customers", "orders", "shipments"}) {
+		t.Fatalf("order = %v", order)
+	}
+	var upstreams []string
+	for _, up := range cfg.PipelineUpstreams(cfg.Routes[2]) {
+		upstreams = append(upstreams, up.Name)
+	}
+	if !slices.Equal(upstreams, []string{"customers", "orders"}) {
+		t.Fatalf("upstreams of shipments = %v", upstreams)
+	}
+
+	cases := map[string]*Config{
+		"cycle": {Routes: []Route{route("a", "ta", "tb"), route("b", "tb", "ta")},
+			Pipelines: []Pipeline{{Name: "p", Routes: []string{"a", "b"}}}},
+		"unknown route": {Routes: []Route{route("a", "ta")},
+			Pipelines: []Pipeline{{Name: "p", Routes: []string{"a", "missing"}}}},
+		"unlinked route": {Routes: []Route{route("a", "ta"), route("b", "tb", "ta"), route("c", "tc")},
+			Pipelines: []Pipeline{{Name: "p", Routes: []string{"a", "b", "c"}}}},
+		"two pipelines": {Routes: []Route{route("a", "ta"), route("b", "tb", "ta")},
+			Pipelines: []Pipeline{{Name: "p", Routes: []string{"a", "b"}}, {Name: "q", Routes: []string{"b", "a"}}}},
+		"one route": {Routes: []Route{route("a", "ta")},
+			Pipelines: []Pipeline{{Name: "p", Routes: []string{"a"}}}},
+	}
+	for name, cfg := range cases {
+		if err := cfg.validatePipelines(); err == nil {
+			t.Fatalf("%s: expected validation to fail", name)
+		}
+	}
+	cycle := cases["cycle"]
+	if _, err := cycle.PipelineOrder(cycle.Pipelines[0]); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("expected the cycle to be named, got %v", err)
+	}
+}
+
+func TestDeliveryValidateOversize(t *testing.T) {
+	cases := []struct {
+		delivery Delivery
+		want     string
+		wantErr  bool
+	}{
+		{delivery: Delivery{MaxMessageBytes: 1 << 20}, want: OversizeDrop},
+		{delivery: Delivery{MaxMessageBytes: 1 << 20, Oversize: OversizeCompress}, want: OversizeCompress},
+		{delivery: Delivery{MaxMessageBytes: 1 << 20, Oversize: OversizeDeadLetter}, wantErr: true},
+		{delivery: Delivery{MaxMessageBytes: 1 << 20, Oversize: OversizeDeadLetter, DeadLetterTopic: "dlq"}, want: OversizeDeadLetter},
+		{delivery: Delivery{Oversize: OversizeTruncate}, wantErr: true},
+		{delivery: Delivery{MaxMessageBytes: 1 << 20, Oversize: "split"}, wantErr: true},
+		{delivery: Delivery{MaxMessageBytes: -1}, wantErr: true},
+	}
+	for _, tc := range cases {
+		err := tc.delivery.validate()
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.delivery, err, tc.wantErr)
+		}
+		if err == nil && tc.delivery.Oversize != tc.want {
+			t.Fatalf("%+v: oversize = %q, want %q", tc.delivery, tc.delivery.Oversize, tc.want)
+		}
+	}
+}
+
+func TestMaxMessageAgeDefaults(t *testing.T) {
+	cfg := Config{
+		SourceClusters:   []SourceCluster{{Name: "a", Brokers: []string{"a:9092"}, SourceGroupID: "src"}},
+		BridgeCluster:    ClusterConfig{Brokers: []string{"b:9092"}},
+		ClientID:         "bridge",
+		ReferenceGroupID: "refs",
+		MaxMessageAge:    24 * time.Hour,
+		Routes: []Route{
+			{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}},
+			{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out-recent", MaxMessageAge: time.Hour, ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if cfg.Routes[0].MaxMessageAge != 24*time.Hour || cfg.Routes[1].MaxMessageAge != time.Hour {
+		t.Fatalf("expected the global maxMessageAge only where a route sets none, got %v and %v", cfg.Routes[0].MaxMessageAge, cfg.Routes[1].MaxMessageAge)
+	}
+	cfg.Routes[1].MaxMessageAge = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected a negative maxMessageAge to fail")
+	}
+}
+
+func TestRouteValidateDeliveryCompression(t *testing.T) {
+	route := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", Delivery: Delivery{Compression: DeliveryCompressionZstd},
+		ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	if err := route.validate(0); err != nil {
+		t.Fatal(err)
+	}
+	route.Delivery.Compression = "brotli"
+	if err := route.validate(0); err == nil {
+		t.Fatal("expected an unknown codec to fail")
+	}
+	route.Delivery.Compression = DeliveryCompressionGzip
+	route.Destination = Destination{Type: DestinationWebhook, Webhook: Webhook{URL: "https://example.com/hook"}}
+	if err := route.validate(0); err == nil || !strings.Contains(err.Error(), "requires a kafka destination") {
+		t.Fatalf("expected compression to require a kafka destination, got %v", err)
+	}
+}
+
+func TestRouteValidateMatchSource(t *testing.T) {
+	route := func(feed ReferenceFeed) Route {
+		feed.Name, feed.Topic = "f", "ref"
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", ReferenceFeeds: []ReferenceFeed{feed}}
+	}
+	cases := []struct {
+		feed    ReferenceFeed
+		wantErr bool
+	}{
+		{feed: ReferenceFeed{MatchFields: []string{"id"}}},
+		{feed: ReferenceFeed{MatchSource: MatchSourceValue, MatchFields: []string{"id"}}},
+		{feed: ReferenceFeed{MatchSource: MatchSourceValue}, wantErr: true},
+		{feed: ReferenceFeed{MatchSource: MatchSourceKey}},
+		{feed: ReferenceFeed{MatchSource: "header:x-account"}},
+		{feed: ReferenceFeed{MatchSource: "header:"}, wantErr: true},
+		{feed: ReferenceFeed{MatchSource: MatchSourceKey, MatchFields: []string{"id"}}, wantErr: true},
+		{feed: ReferenceFeed{MatchSource: "body"}, wantErr: true},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, CSVHeader: true, CSVDelimiter: `\t`, MatchFields: []string{"customer"}}},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, MatchFields: []string{"2"}, CSVDelimiter: "|"}},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, MatchFields: []string{"customer.id"}}, wantErr: true},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, CSVHeader: true, CSVColumns: []string{"id"}, MatchFields: []string{"id"}}, wantErr: true},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, CSVDelimiter: "::", MatchFields: []string{"0"}}, wantErr: true},
+		{feed: ReferenceFeed{PayloadFormat: PayloadFormatCSV, RecordsPath: "rows", MatchFields: []string{"0"}}, wantErr: true},
+		{feed: ReferenceFeed{CSVHeader: true, MatchFields: []string{"id"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		r := route(tc.feed)
+		if err := r.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.feed, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRouteValidateCanonicalize(t *testing.T) {
+	cases := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{route: Route{}},
+		{route: Route{ReferenceFeeds: []ReferenceFeed{{Canonicalize: []string{"dates"}}}}, wantErr: true},
+		{route: Route{ReferenceFeeds: []ReferenceFeed{{CanonicalTimeLayouts: []string{"2006-01-02 15:04"}}}}, wantErr: true},
+		{route: Route{ReferenceFeeds: []ReferenceFeed{{Canonicalize: []string{"timestamps"}, CanonicalTimeLayouts: []string{"2006-01-02 15:04"}}}}},
+		{route: Route{Prefilter: true}, wantErr: true},
+		{route: Route{MatchStrategy: MatchStrategyBytesContains}, wantErr: true},
+	}
+	for _, tc := range cases {
+		r := tc.route
+		r.SourceCluster, r.SourceTopic, r.DestinationTopic = "a", "in", "out"
+		if len(r.ReferenceFeeds) == 0 {
+			r.ReferenceFeeds = []ReferenceFeed{{Canonicalize: []string{"numbers", "booleans"}}}
+		}
+		r.ReferenceFeeds[0].Name, r.ReferenceFeeds[0].Topic, r.ReferenceFeeds[0].MatchFields = "f", "ref", []string{"id"}
+		if err := r.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.route, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRouteValidateHashValues(t *testing.T) {
+	t.Setenv("BRIDGE_HASH_KEY", "from-env")
+	cases := []struct {
+		hash      HashValues
+		prefilter bool
+		wantKey   string
+		wantErr   bool
+	}{
+		{hash: HashValues{}},
+		{hash: HashValues{Key: "k"}, wantKey: "k"},
+		{hash: HashValues{KeyEnv: "BRIDGE_HASH_KEY"}, wantKey: "from-env"},
+		{hash: HashValues{KeyEnv: "BRIDGE_HASH_UNSET"}, wantErr: true},
+		{hash: HashValues{Key: "k", KeyEnv: "BRIDGE_HASH_KEY"}, wantErr: true},
+		{hash: HashValues{}, prefilter: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		hash := tc.hash
+		r := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", HashValues: &hash, Prefilter: tc.prefilter,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+		err := r.validate(0)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.hash, err, tc.wantErr)
+		}
+		if err == nil && hash.Key != tc.wantKey {
+			t.Fatalf("%+v: key = %q, want %q", tc.hash, hash.Key, tc.wantKey)
+		}
+	}
+}
+
+func TestRouteValidateOnDecodeError(t *testing.T) {
+	route := func(policy, topic string, compacted bool) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", OnDecodeError: policy, DecodeErrorTopic: topic, Compacted: compacted,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	cases := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{route: route("", "", false)},
+		{route: route(OnDecodeErrorForward, "", false)},
+		{route: route(OnDecodeErrorDLQ, "in.undecodable", true)},
+		{route: route(OnDecodeErrorForward, "", true), wantErr: true},
+		{route: route(OnDecodeErrorDLQ, "", false), wantErr: true},
+		{route: route(OnDecodeErrorSkip, "in.undecodable", false), wantErr: true},
+		{route: route("retry", "", false), wantErr: true},
+	}
+	for i, tc := range cases {
+		if err := tc.route.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("case %d: validate error = %v, wantErr %v", i, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRouteValidateRejectedTopic(t *testing.T) {
+	route := func(topic string, ratio float64) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", RejectedTopic: topic, RejectedSampleRatio: ratio,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	defaulted := route("in.rejected", 0)
+	if err := defaulted.validate(0); err != nil || defaulted.RejectedSampleRatio != 1 {
+		t.Fatalf("validate = %v, ratio %v; want the ratio to default to 1", err, defaulted.RejectedSampleRatio)
+	}
+	for i, r := range []Route{route("", 0.5), route("in.rejected", 1.5), route("in.rejected", -0.1), route("in", 0)} {
+		if err := r.validate(0); err == nil {
+			t.Fatalf("case %d: expected %q with ratio %v to be rejected", i, r.RejectedTopic, r.RejectedSampleRatio)
+		}
+	}
+	if r := route("in.rejected", 0.01); r.validate(0) != nil {
+		t.Fatal("expected a 1% sample to be accepted")
+	}
+}
+
+func TestRouteValidateSourceTopicPattern(t *testing.T) {
+	route := func(name, topic, pattern, destination string) Route {
+		return Route{Name: name, SourceCluster: "a", SourceTopic: topic, SourceTopicPattern: pattern, DestinationTopic: destination,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	cases := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{route: route("", "", `orders\..+`, "orders.filtered")},
+		{route: route("orders", "", `orders\.(.+)`, "orders.$1.filtered")},
+		{route: route("orders", "", `orders\.(?P<region>[a-z]+)`, "filtered.${region}")},
+		{route: route("orders", "", `orders\.(.+)`, "orders.$$1")},
+		{route: route("", "", "", "out"), wantErr: true},
+		{route: route("", "in", "in.*", "out"), wantErr: true},
+		{route: route("", "", "orders.(", "out"), wantErr: true},
+		{route: route("", "in", "", "out.$1"), wantErr: true},
+		{route: route("", "", `orders\.(.+)`, "orders.$1.filtered"), wantErr: true},
+		{route: route("orders", "", `orders\.(.+)`, "orders.$2"), wantErr: true},
+		{route: route("orders", "", `orders\.(?P<region>.+)`, "filtered.$zone"), wantErr: true},
+	}
+	for i, tc := range cases {
+		if err := tc.route.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("case %d: validate error = %v, wantErr %v", i, err, tc.wantErr)
+		}
+	}
+	compacted := route("", "", "orders.*", "out")
+	compacted.Compacted = true
+	if err := compacted.validate(0); err == nil {
+		t.Fatal("expected compacted to be rejected with sourceTopicPattern")
+	}
+	r := route("orders", "", `orders\.(?P<region>[a-z]+)\.v(\d+)`, "filtered.${region}.v$2")
+	if err := r.validate(0); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if r.TopicRefreshInterval != DefaultTopicRefreshInterval {
+		t.Fatalf("topicRefreshInterval = %v", r.TopicRefreshInterval)
+	}
+	re, _ := r.SourceTopicRegexp()
+	if got, err := r.DestinationTopicFor(re, "orders.eu.v2"); err != nil || got != "filtered.eu.v2" {
+		t.Fatalf("DestinationTopicFor = %q, %v", got, err)
+	}
+	if _, err := r.DestinationTopicFor(re, "orders.eu.v2.retry"); err == nil {
+		t.Fatal("the pattern must match the whole topic name")
+	}
+}
+
+func TestReferenceHTTPValidate(t *testing.T) {
+	h := &ReferenceHTTP{URL: "https://reference/api"}
+	if err := h.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if h.ValuesPath != "$" || h.PollInterval != DefaultReferenceHTTPPollInterval || h.Timeout != DefaultReferenceHTTPTimeout {
+		t.Fatalf("defaults not applied: %+v", h)
+	}
+	for _, bad := range []*ReferenceHTTP{
+		{URL: "reference/api"},
+		{URL: "https://reference/api", ValuesPath: "items[*]"},
+		{URL: "https://reference/api", PollInterval: -time.Second},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestReferenceSQLValidate(t *testing.T) {
+	q := &ReferenceSQL{Driver: "pgx", DSN: "postgres://bridge@db/master", Query: "SELECT id FROM customers"}
+	if err := q.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if q.RefreshInterval != DefaultReferenceSQLRefreshInterval || q.Timeout != DefaultReferenceSQLTimeout {
+		t.Fatalf("defaults not applied: %+v", q)
+	}
+	for _, bad := range []*ReferenceSQL{
+		{DSN: "postgres://db", Query: "SELECT 1"},
+		{Driver: "pgx", DSN: "postgres://db", Query: " "},
+		{Driver: "pgx", DSN: "postgres://db", Query: "SELECT 1", Timeout: -time.Second},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestRouteValidatePartitioner(t *testing.T) {
+	route := func(d Delivery) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", Delivery: d,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	webhook := route(Delivery{Partitioner: PartitionerKey})
+	webhook.Destination = Destination{Type: DestinationWebhook, Webhook: Webhook{URL: "http://sink"}}
+	compacted := route(Delivery{KeyField: "customer.id"})
+	compacted.Compacted = true
+	cases := []struct {
+		route   Route
+		want    string
+		wantErr bool
+	}{
+		{route: route(Delivery{})},
+		{route: route(Delivery{Partitioner: PartitionerRoundRobin}), want: PartitionerRoundRobin},
+		{route: route(Delivery{KeyField: "customer.id"}), want: PartitionerKey},
+		{route: route(Delivery{Partitioner: PartitionerSource, KeyField: "customer.id"}), wantErr: true},
+		{route: route(Delivery{Partitioner: "sticky"}), wantErr: true},
+		{route: webhook, wantErr: true},
+		{route: compacted, wantErr: true},
+	}
+	for i, tc := range cases {
+		err := tc.route.validate(0)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("case %d: validate error = %v, wantErr %v", i, err, tc.wantErr)
+		}
+		if err == nil && tc.route.Delivery.Partitioner != tc.want {
+			t.Fatalf("case %d: partitioner = %q, want %q", i, tc.route.Delivery.Partitioner, tc.want)
+		}
+	}
+}
+
+func TestRouteValidatePayloadFormat(t *testing.T) {
+	route := func(format string, filter map[string]string, fields ...string) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", PayloadFormat: format, EventFilter: filter,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", PayloadFormat: format, MatchFields: fields}}}
+	}
+	cases := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{route: route(PayloadFormatXML, nil, "order.customer.@id")},
+		{route: route("", nil, "a.b")},
+		{route: route(PayloadFormatJSON, nil, "a.b.c"), wantErr: true},
+		{route: route("yaml", nil, "a"), wantErr: true},
+		{route: route(PayloadFormatCloudEvents, map[string]string{"type": "order.*"}, "ce.subject")},
+		{route: route(PayloadFormatJSON, map[string]string{"type": "order.*"}, "a"), wantErr: true},
+		{route: route(PayloadFormatCloudEvents, map[string]string{"type": "*.created"}, "a"), wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := tc.route.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.route, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRouteValidateMatchStrategy(t *testing.T) {
+	route := func(strategy string, edit func(*Route)) Route {
+		r := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", MatchStrategy: strategy,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+		if edit != nil {
+			edit(&r)
+		}
+		return r
+	}
+	cases := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{route: route("", nil)},
+		{route: route(MatchStrategyFields, nil)},
+		{route: route(MatchStrategyBytesContains, nil)},
+		{route: route("regex", nil), wantErr: true},
+		{route: route(MatchStrategyBytesContains, func(r *Route) { r.PayloadFormat = PayloadFormatXML }), wantErr: true},
+		{route: route(MatchStrategyBytesContains, func(r *Route) { r.BloomFilter = &BloomFilter{ExpectedValues: 10} }), wantErr: true},
+		{route: route("", func(r *Route) { r.Prefilter = true })},
+		{route: route(MatchStrategyBytesContains, func(r *Route) { r.Prefilter = true }), wantErr: true},
+		{route: route("", func(r *Route) { r.Prefilter, r.PayloadFormat = true, PayloadFormatCloudEvents }), wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := tc.route.validate(0); (err != nil) != tc.wantErr {
+			t.Fatalf("%+v: validate error = %v, wantErr %v", tc.route, err, tc.wantErr)
+		}
+	}
+}
+
+func TestValidateRouteGroups(t *testing.T) {
+	cfg := Config{Routes: []Route{
+		{SourceCluster: "a", SourceTopic: "in", Group: "g"},
+		{SourceCluster: "a", SourceTopic: "in", Group: "g", Priority: 1},
+		{SourceCluster: "a", SourceTopic: "other"},
+	}}
+	if err := cfg.validateRouteGroups(); err != nil {
+		t.Fatalf("validateRouteGroups: %v", err)
+	}
+	cfg.Routes[2].Group = "g"
+	if err := cfg.validateRouteGroups(); err == nil {
+		t.Fatal("expected a group spanning source topics to fail")
+	}
+}
+
+func TestRouteValidateDestination(t *testing.T) {
+	webhook := func(url string) Destination {
+		return Destination{Type: DestinationWebhook, Webhook: Webhook{URL: url}}
+	}
+	cases := []struct {
+		name    string
+		route   Route
+		wantErr bool
+	}{
+		{name: "kafka default", route: Route{DestinationTopic: "dest"}},
+		{name: "kafka without topic", route: Route{Name: "r"}, wantErr: true},
+		{name: "webhook", route: Route{Name: "r", Destination: webhook("https://hooks.example.com/orders")}},
+		{name: "webhook without name", route: Route{Destination: webhook("https://hooks.example.com/orders")}, wantErr: true},
+		{name: "relative url", route: Route{Name: "r", Destination: webhook("/orders")}, wantErr: true},
+		{name: "unsupported scheme", route: Route{Name: "r", Destination: webhook("ftp://hooks.example.com")}, wantErr: true},
+		{name: "compacted webhook", route: Route{Name: "r", Compacted: true, Destination: webhook("https://hooks.example.com")}, wantErr: true},
+		{name: "unknown type", route: Route{DestinationTopic: "dest", Destination: Destination{Type: "s4"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		r := tc.route
+		r.SourceCluster, r.SourceTopic = "source-a", "source"
+		r.ReferenceFeeds = []ReferenceFeed{{Name: "feed", Topic: "ref", MatchFields: []string{"id"}}}
+		err := r.validate(0)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: validate() error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if err == nil && r.Destination.Type == DestinationWebhook && (r.Destination.Webhook.BatchSize != 1 || r.Destination.Webhook.Timeout != DefaultWebhookTimeout) {
+			t.Fatalf("%s: webhook defaults not applied: %+v", tc.name, r.Destination.Webhook)
+		}
+	}
+}
+
+func TestArchiveValidate(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if err := (&Archive{Bucket: "b", Region: "us-east-1"}).validate(); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+	if err := (&Archive{Bucket: "b", Region: "us-east-1", AccessKeyID: "id", SecretAccessKey: "key", Endpoint: "minio:9000"}).validate(); err == nil {
+		t.Fatal("expected an error for an endpoint without scheme")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-key")
+	a := &Archive{Bucket: "b", Region: "us-east-1"}
+	if err := a.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if a.AccessKeyID != "env-id" || a.MaxObjectBytes != DefaultArchiveMaxObjectBytes || a.MaxObjectAge != DefaultArchiveMaxObjectAge {
+		t.Fatalf("defaults not applied: %+v", a)
+	}
+}
+
+func TestRouteValidateSourceHeaderFilters(t *testing.T) {
+	base := Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out",
+		ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	for _, filters := range [][]string{{"event-type"}, {"=ORDER"}} {
+		route := base
+		route.SourceHeaderFilters = filters
+		if err := route.validate(0); err == nil {
+			t.Fatalf("%v: expected validation to fail", filters)
+		}
+	}
+	route := base
+	route.SourceHeaderFilters = []string{"Event-Type=ORDER", "event-type=REFUND", "source="}
+	if err := route.validate(0); err != nil {
+		t.Fatal(err)
+	}
+	values := route.SourceHeaderValues()
+	if len(values) != 2 || len(values["event-type"]) != 2 || values["event-type"][1] != "REFUND" || values["source"][0] != "" {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestDedupValidate(t *testing.T) {
+	d := &Dedup{Window: time.Hour, Field: "eventId|meta.id"}
+	if err := d.validate(); err != nil || d.MaxEntries != DefaultDedupMaxEntries {
+		t.Fatalf("validate() = %v, maxEntries %d", err, d.MaxEntries)
+	}
+	for _, bad := range []Dedup{{}, {Window: time.Hour, Field: "a..b"}, {Window: time.Hour, MaxEntries: -1}} {
+		if err := bad.validate(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestRouteValidateTimeWindow(t *testing.T) {
+	timed := []ReferenceFeed{{Topic: "ref", MatchFields: []string{"id"}, TimestampField: "eventDate"}}
+	r := &Route{ReferenceFeeds: timed, TimeWindow: &TimeWindow{SourceField: "ts", Before: 48 * time.Hour, After: 48 * time.Hour}}
+	if err := r.validateTimeWindow(); err != nil {
+		t.Fatalf("validateTimeWindow() = %v", err)
+	}
+	for _, bad := range []*Route{
+		{ReferenceFeeds: timed},
+		{ReferenceFeeds: timed, TimeWindow: &TimeWindow{Before: time.Hour}},
+		{ReferenceFeeds: timed, TimeWindow: &TimeWindow{SourceField: "ts", Before: -time.Hour}},
+		{ReferenceFeeds: []ReferenceFeed{{Topic: "ref", MatchFields: []string{"id"}}}, TimeWindow: &TimeWindow{SourceField: "ts"}},
+	} {
+		if err := bad.validateTimeWindow(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestBloomFilterValidate(t *testing.T) {
+	r := &Route{BloomFilter: &BloomFilter{ExpectedValues: 1000}}
+	if err := r.validateBloomFilter(); err != nil || r.BloomFilter.FalsePositiveRate != DefaultBloomFalsePositiveRate {
+		t.Fatalf("validateBloomFilter() = %v, rate %v", err, r.BloomFilter.FalsePositiveRate)
+	}
+	for _, bad := range []*Route{
+		{BloomFilter: &BloomFilter{}},
+		{BloomFilter: &BloomFilter{ExpectedValues: 1000, FalsePositiveRate: 1}},
+		{BloomFilter: &BloomFilter{ExpectedValues: 1000}, MaxValues: 10},
+		{BloomFilter: &BloomFilter{ExpectedValues: 1000}, TimeWindow: &TimeWindow{SourceField: "ts"}},
+	} {
+		if err := bad.validateBloomFilter(); err == nil {
+			t.Fatalf("expected error for %+v", bad.BloomFilter)
+		}
+	}
+
+	c := &Config{Routes: []Route{{BloomFilter: &BloomFilter{ExpectedValues: 1000, Verify: true}}}}
+	c.Storage = Storage{Backend: StorageBackendKafka, Topic: "state"}
+	if err := c.validateBloomStorage(); err == nil {
+		t.Fatal("expected verify to require sqlite")
+	}
+	c.Storage = Storage{Backend: StorageBackendSQLite, Path: "cache.db"}
+	if err := c.validateBloomStorage(); err != nil {
+		t.Fatalf("validateBloomStorage() = %v", err)
+	}
+	c.Routes[0].BloomFilter.Verify = false
+	c.Storage = Storage{Backend: StorageBackendFile, Path: "cache.json"}
+	if err := c.validateBloomStorage(); err == nil {
+		t.Fatal("expected file snapshots to be rejected")
+	}
+}
+
+func TestAuditValidate(t *testing.T) {
+	for _, tc := range []struct {
+		audit   Audit
+		wantErr bool
+	}{
+		{audit: Audit{}},
+		{audit: Audit{Enabled: true, Topic: "bridge-audit", IdentityHeader: "X-Forwarded-User"}},
+		{audit: Audit{Topic: "bridge-audit"}, wantErr: true},
+		{audit: Audit{Enabled: true, IdentityHeader: "X-Forwarded User"}, wantErr: true},
+	} {
+		if err := tc.audit.validate(); (err != nil) != tc.wantErr {
+			t.Fatalf("validate(%+v) error = %v, wantErr %v", tc.audit, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLoggingValidate(t *testing.T) {
+	for _, tc := range []struct {
+		logging Logging
+		wantErr bool
+	}{
+		{logging: Logging{}},
+		{logging: Logging{SampleEvery: 100, SummaryInterval: time.Minute}},
+		{logging: Logging{SampleEvery: -1}},
+		{logging: Logging{SampleEvery: -2}, wantErr: true},
+		{logging: Logging{SummaryInterval: -time.Second}, wantErr: true},
+	} {
+		if err := tc.logging.validate(); (err != nil) != tc.wantErr {
+			t.Fatalf("validate(%+v) error = %v, wantErr %v", tc.logging, err, tc.wantErr)
+		}
+	}
+}
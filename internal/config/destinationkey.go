@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sources of the key of forwarded messages accepted by destinationKey.from.
+const (
+	DestinationKeySource   = "source"
+	DestinationKeyConstant = "constant"
+	DestinationKeyField    = "field"
+)
+
+// DestinationKey sets the key of a route's forwarded messages. Constant and field keys
+// imply the key partitioner, so the destination partition follows the new key.
+type DestinationKey struct {
+	// From is source (default), keeping the source key; constant, writing Constant; or
+	// field, reading Field from the payload.
+	From string `yaml:"from"`
+	// Field is a dotted payload path with optional | fallbacks, such as order.id. A
+	// payload without it keeps its source key.
+	Field string `yaml:"field"`
+	// Constant is the key under from: constant. Under source and field it replaces a key
+	// that would be empty.
+	Constant string `yaml:"constant"`
+}
+
+// validateDestinationKey checks destinationKey and sets the partitioner it implies. It
+// runs before delivery is validated, which checks the partitioner.
+func (r *Route) validateDestinationKey() error {
+	k := r.DestinationKey
+	if k == nil {
+		return nil
+	}
+	if r.Delivery.KeyField != "" {
+		return errors.New("destinationKey and delivery.keyField cannot both be set; keyField is short for destinationKey from: field")
+	}
+	if r.Compacted {
+		return errors.New("destinationKey: compacted routes keep their source keys")
+	}
+	switch k.From {
+	case "":
+		k.From = DestinationKeySource
+	case DestinationKeySource, DestinationKeyConstant, DestinationKeyField:
+	default:
+		return fmt.Errorf("destinationKey: unknown from %q (want source, constant, or field)", k.From)
+	}
+	switch {
+	case k.From == DestinationKeyField && k.Field == "":
+		return errors.New("destinationKey: field is required for from: field")
+	case k.From != DestinationKeyField && k.Field != "":
+		return errors.New("destinationKey: field requires from: field")
+	case k.From == DestinationKeyConstant && k.Constant == "":
+		return errors.New("destinationKey: constant is required for from: constant")
+	}
+	if k.From == DestinationKeySource {
+		return nil
+	}
+	if r.Delivery.Partitioner == "" {
+		r.Delivery.Partitioner = PartitionerKey
+	}
+	if r.Delivery.Partitioner != PartitionerKey {
+		return fmt.Errorf("destinationKey: from %s requires the key partitioner", k.From)
+	}
+	return nil
+}
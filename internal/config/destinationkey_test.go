@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteValidateDestinationKey(t *testing.T) {
+	route := func(key *DestinationKey) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", DestinationKey: key,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	r := route(&DestinationKey{Constant: "unknown"})
+	if err := r.validate(0); err != nil || r.DestinationKey.From != DestinationKeySource || r.Delivery.Partitioner != "" {
+		t.Fatalf("validate = %v, from %q, partitioner %q; want source keys on the source partitioner", err, r.DestinationKey.From, r.Delivery.Partitioner)
+	}
+	r = route(&DestinationKey{From: DestinationKeyField, Field: "order.id"})
+	if err := r.validate(0); err != nil || r.Delivery.Partitioner != PartitionerKey {
+		t.Fatalf("validate = %v, partitioner %q; want a field key to imply the key partitioner", err, r.Delivery.Partitioner)
+	}
+
+	for _, tc := range []struct {
+		route func() Route
+		want  string
+	}{
+		{func() Route { return route(&DestinationKey{From: "header"}) }, "unknown from"},
+		{func() Route { return route(&DestinationKey{From: DestinationKeyField}) }, "field is required"},
+		{func() Route { return route(&DestinationKey{From: DestinationKeyConstant}) }, "constant is required"},
+		{func() Route { return route(&DestinationKey{Field: "order.id"}) }, "field requires from: field"},
+		{func() Route {
+			r := route(&DestinationKey{From: DestinationKeyConstant, Constant: "k"})
+			r.Delivery.Partitioner = PartitionerRoundRobin
+			return r
+		}, "requires the key partitioner"},
+		{func() Route {
+			r := route(&DestinationKey{From: DestinationKeyField, Field: "order.id"})
+			r.Delivery.KeyField = "customer.id"
+			return r
+		}, "cannot both be set"},
+		{func() Route {
+			r := route(&DestinationKey{From: DestinationKeySource})
+			r.Compacted = true
+			return r
+		}, "compacted routes keep their source keys"},
+	} {
+		r := tc.route()
+		if err := r.validate(0); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: validate = %v, want an error containing %q", r.DestinationKey, err, tc.want)
+		}
+	}
+}
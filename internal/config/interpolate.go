@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// filePrefix marks a value whose content is read from the named file.
+const filePrefix = "file:"
+
+// interpolate expands ${VAR} (or ${VAR:-default}) references in every scalar value of
+// the document and then replaces values of the form file:/path with the file's contents
+// (trailing newlines trimmed). $$ yields a literal $. Keys are left untouched.
+func interpolate(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolate(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolate(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if path, ok := strings.CutPrefix(value, filePrefix); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("line %d: read %s: %w", node.Line, value, err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		if value != node.Value && node.Style == 0 {
+			// let YAML re-resolve plain scalars so ${PORT} can fill an int field
+			node.Tag = ""
+		}
+		node.Value = value
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references. Unlike os.ExpandEnv, a
+// reference to an unset variable without a default is an error.
+func expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+		if i+1 >= len(s) || s[i+1] != '{' {
+			b.WriteByte('$')
+			continue
+		}
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		ref := s[i+2 : i+2+end]
+		name, fallback, hasDefault := strings.Cut(ref, ":-")
+		if name == "" {
+			return "", fmt.Errorf("empty variable reference in %q", s)
+		}
+		value, ok := os.LookupEnv(name)
+		switch {
+		case ok && (value != "" || !hasDefault):
+		case hasDefault:
+			value = fallback
+		default:
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(value)
+		i += 2 + end
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("BRIDGE_HOST", "broker-1")
+	t.Setenv("BRIDGE_EMPTY", "")
+	cases := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "${BRIDGE_HOST}:9092", want: "broker-1:9092"},
+		{in: "${BRIDGE_MISSING:-fallback}", want: "fallback"},
+		{in: "${BRIDGE_EMPTY:-fallback}", want: "fallback"},
+		{in: "x${BRIDGE_EMPTY}y", want: "xy"},
+		{in: "$$literal $HOME", want: "$literal $HOME"},
+		{in: "${BRIDGE_MISSING}", wantErr: "BRIDGE_MISSING is not set"},
+		{in: "${BRIDGE_HOST", wantErr: "unterminated"},
+	}
+	for _, tc := range cases {
+		got, err := expandEnv(tc.in)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expandEnv(%q) error = %v, want %q", tc.in, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("expandEnv(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestLoadInterpolatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BRIDGE_BROKER", "bridge:9092")
+	t.Setenv("BRIDGE_SECRET_FILE", secret)
+	t.Setenv("BRIDGE_MAX", "250")
+
+	path := filepath.Join(dir, "config.yaml")
+	content := `sourceClusters:
+  - name: source-a
+    brokers: ["${BRIDGE_BROKER}"]
+    sourceGroupId: src
+bridgeCluster:
+  brokers: ["${BRIDGE_BROKER}"]
+clientId: bridge
+referenceGroupId: ref
+http:
+  adminToken: file:${BRIDGE_SECRET_FILE}
+routes:
+  - name: route-a
+    sourceCluster: source-a
+    sourceTopic: src
+    destinationTopic: dest
+    maxValues: ${BRIDGE_MAX}
+    referenceFeeds:
+      - name: feed
+        topic: ref
+        matchFields: ["id"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.BridgeCluster.Brokers[0] != "bridge:9092" || cfg.SourceClusters[0].Brokers[0] != "bridge:9092" {
+		t.Fatalf("brokers not interpolated: %+v", cfg.BridgeCluster)
+	}
+	if cfg.HTTP.AdminToken != "s3cret" {
+		t.Fatalf("adminToken = %q, want file contents", cfg.HTTP.AdminToken)
+	}
+	if cfg.Routes[0].MaxValues != 250 {
+		t.Fatalf("maxValues = %d, want 250", cfg.Routes[0].MaxValues)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(content, "${BRIDGE_MAX}", "${BRIDGE_UNSET_MAX}", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 16: environment variable BRIDGE_UNSET_MAX is not set") {
+		t.Fatalf("expected missing variable error with line, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+)
+
+// LintWarning is a setting that passes validation but is risky, as found by Lint.
+type LintWarning struct {
+	// Path locates the setting, e.g. routes[0].matchStrategy.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Lint checks a validated config for settings that are allowed but usually a mistake in
+// production, and returns a warning for each, in config order.
+func (c *Config) Lint() []LintWarning {
+	var warnings []LintWarning
+	warn := func(path, format string, args ...any) {
+		warnings = append(warnings, LintWarning{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	insecure := func(path string, t *TLSConfig) {
+		if t != nil && t.InsecureSkipVerify {
+			warn(path+".tls.insecureSkipVerify", "certificate verification is off, so the connection can be intercepted")
+		}
+	}
+
+	insecure("bridgeCluster", c.BridgeCluster.TLS)
+	for i, sc := range c.SourceClusters {
+		insecure(fmt.Sprintf("sourceClusters[%d]", i), sc.TLS)
+	}
+	unpersisted := c.Storage.Backend == StorageBackendFile && c.Storage.Path == ""
+	if unpersisted {
+		warn("storage.path", "no path is set, so the cache is not persisted and every restart begins with an empty one")
+	}
+
+	destinations := make(map[string]int)
+	for i, r := range c.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+		insecure(path+".destination.webhook", r.Destination.Webhook.TLS)
+		if r.ReferenceHTTP != nil {
+			insecure(path+".referenceHTTP", r.ReferenceHTTP.TLS)
+		}
+		if unpersisted && r.Storage == nil && len(r.ReferenceFeeds) > 0 {
+			warn(path+".referenceFeeds", "reference feeds are consumed from the latest offset and the cache is not persisted, so after a restart the route matches none of the values published before it")
+		}
+		if r.MatchStrategy == MatchStrategyBytesContains {
+			warn(path+".matchStrategy", "bytesContains matches a cached value anywhere in the raw payload, including inside other values, keys, and field names; use it only for values that cannot occur there by accident")
+		}
+		if r.Destination.Type == DestinationWebhook || r.DestinationTemplated() || r.DestinationTopic == "" {
+			continue
+		}
+		if first, ok := destinations[r.DestinationTopic]; ok {
+			warn(path+".destinationTopic", "%s is also the destination of route %s, so their messages are interleaved and cannot be told apart", r.DestinationTopic, c.Routes[first].DisplayName())
+			continue
+		}
+		destinations[r.DestinationTopic] = i
+	}
+	return warnings
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	feeds := []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}
+	cfg := &Config{
+		BridgeCluster:  ClusterConfig{TLS: &TLSConfig{InsecureSkipVerify: true}},
+		SourceClusters: []SourceCluster{{Name: "a", TLS: &TLSConfig{}}},
+		Storage:        Storage{Backend: StorageBackendFile},
+		Routes: []Route{
+			{Name: "orders", DestinationTopic: "filtered", ReferenceFeeds: feeds},
+			{Name: "payments", DestinationTopic: "filtered", ReferenceFeeds: feeds, MatchStrategy: MatchStrategyBytesContains, Storage: &Storage{Path: "/data/payments.json"}},
+			{Name: "hook", Destination: Destination{Type: DestinationWebhook, Webhook: Webhook{URL: "https://example.com", TLS: &TLSConfig{InsecureSkipVerify: true}}}},
+		},
+	}
+	var paths []string
+	for _, w := range cfg.Lint() {
+		paths = append(paths, w.Path)
+	}
+	want := []string{
+		"bridgeCluster.tls.insecureSkipVerify",
+		"storage.path",
+		"routes[0].referenceFeeds",
+		"routes[1].matchStrategy",
+		"routes[1].destinationTopic",
+		"routes[2].destination.webhook.tls.insecureSkipVerify",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("Lint warned about %v, want %v", paths, want)
+	}
+
+	cfg = &Config{Storage: Storage{Backend: StorageBackendFile, Path: "/data/cache.json"},
+		Routes: []Route{{Name: "orders", DestinationTopic: "filtered", ReferenceFeeds: feeds}}}
+	if warnings := cfg.Lint(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+}
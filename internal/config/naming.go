@@ -0,0 +1,91 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// DestinationNaming names the Kafka destination topics routes leave unset and checks the
+// names of all of them against the bridge cluster's naming convention.
+type DestinationNaming struct {
+	// Template names the destinationTopic of a route that sets none. It is a text/template
+	// with {{ .team }}, {{ .route }}, {{ .sourceTopic }}, and {{ .sourceCluster }}, e.g.
+	// bridge.{{ .team }}.{{ .route }}; referencing one the route leaves empty is an error.
+	Template string `yaml:"template"`
+	// Pattern is a regular expression every Kafka destinationTopic must match in full,
+	// including the topics a templated destinationTopic expands to.
+	Pattern string `yaml:"pattern"`
+	// Team fills {{ .team }} for the routes that set no team of their own.
+	Team string `yaml:"team"`
+}
+
+// applyDestinationNaming fills the destinationTopic of every Kafka route without one from
+// the naming template and requires every destinationTopic to match the naming pattern.
+// It runs before the routes are validated, so a generated name is validated like one
+// written out.
+func (c *Config) applyDestinationNaming() error {
+	n := c.DestinationNaming
+	var tmpl *template.Template
+	if n.Template != "" {
+		var err error
+		if tmpl, err = template.New("").Option("missingkey=error").Parse(n.Template); err != nil {
+			return fmt.Errorf("destinationNaming: template: %w", err)
+		}
+	}
+	var pattern *regexp.Regexp
+	if n.Pattern != "" {
+		var err error
+		if pattern, err = regexp.Compile("^(?:" + n.Pattern + ")$"); err != nil {
+			return fmt.Errorf("destinationNaming: pattern: %w", err)
+		}
+	}
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if r.Destination.Type == DestinationWebhook {
+			continue
+		}
+		if r.DestinationTopic == "" && tmpl != nil {
+			topic, err := r.renderDestination(tmpl, n.Team)
+			if err != nil {
+				return fmt.Errorf("route %d: destinationNaming: template: %w", i, err)
+			}
+			r.DestinationTopic = topic
+		}
+		if pattern == nil || r.DestinationTopic == "" {
+			continue
+		}
+		r.namePattern = pattern
+		if !r.DestinationTemplated() && !pattern.MatchString(r.DestinationTopic) {
+			return fmt.Errorf("route %d: destinationTopic %q does not match destinationNaming.pattern %s", i, r.DestinationTopic, n.Pattern)
+		}
+	}
+	return nil
+}
+
+// renderDestination executes the naming template for r. Variables r leaves empty are not
+// defined, so the template cannot silently render a name with a hole in it.
+func (r Route) renderDestination(tmpl *template.Template, team string) (string, error) {
+	vars := make(map[string]string)
+	for key, value := range map[string]string{
+		"team":          cmp.Or(r.Team, team),
+		"route":         r.Name,
+		"sourceTopic":   r.SourceTopic,
+		"sourceCluster": r.SourceCluster,
+	} {
+		if value != "" {
+			vars[key] = value
+		}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	if b.Len() == 0 {
+		return "", errors.New("renders an empty topic name")
+	}
+	return b.String(), nil
+}
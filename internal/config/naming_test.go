@@ -0,0 +1,63 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestApplyDestinationNaming(t *testing.T) {
+	route := func(name, team, destination string) Route {
+		return Route{Name: name, Team: team, SourceCluster: "a", SourceTopic: "orders", DestinationTopic: destination,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	cfg := &Config{
+		DestinationNaming: DestinationNaming{Template: "bridge.{{ .team }}.{{ .route }}", Pattern: `bridge\.[a-z]+\.[a-z-]+`, Team: "platform"},
+		Routes:            []Route{route("orders", "", ""), route("payments", "billing", ""), route("audit", "", "bridge.platform.audit")},
+	}
+	if err := cfg.applyDestinationNaming(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"bridge.platform.orders", "bridge.billing.payments", "bridge.platform.audit"} {
+		if got := cfg.Routes[i].DestinationTopic; got != want {
+			t.Fatalf("route %d: destinationTopic = %q, want %q", i, got, want)
+		}
+	}
+
+	cfg = &Config{DestinationNaming: DestinationNaming{Template: "bridge.{{ .team }}.{{ .route }}"}, Routes: []Route{route("orders", "", "")}}
+	if err := cfg.applyDestinationNaming(); err == nil || !strings.Contains(err.Error(), "team") {
+		t.Fatalf("expected a template referencing an unset team to fail, got %v", err)
+	}
+
+	cfg = &Config{DestinationNaming: DestinationNaming{Pattern: `bridge\..+`}, Routes: []Route{route("orders", "", "orders.filtered")}}
+	if err := cfg.applyDestinationNaming(); err == nil || !strings.Contains(err.Error(), "does not match destinationNaming.pattern") {
+		t.Fatalf("expected a destinationTopic outside the pattern to fail, got %v", err)
+	}
+	// the pattern must match the whole name, not a part of it
+	cfg = &Config{DestinationNaming: DestinationNaming{Pattern: `bridge\.[a-z]+`}, Routes: []Route{route("orders", "", "bridge.orders.v2")}}
+	if err := cfg.applyDestinationNaming(); err == nil {
+		t.Fatal("expected a partial match to fail")
+	}
+
+	webhook := route("hook", "", "")
+	webhook.Destination = Destination{Type: DestinationWebhook}
+	cfg = &Config{DestinationNaming: DestinationNaming{Template: "bridge.{{ .route }}", Pattern: `bridge\..+`}, Routes: []Route{webhook}}
+	if err := cfg.applyDestinationNaming(); err != nil || cfg.Routes[0].DestinationTopic != "" {
+		t.Fatalf("expected webhook routes to be left alone, got %q (%v)", cfg.Routes[0].DestinationTopic, err)
+	}
+}
+
+func TestDestinationTopicForChecksNamingPattern(t *testing.T) {
+	r := Route{Name: "by-region", SourceCluster: "a", SourceTopicPattern: `orders\.(?P<region>[a-z0-9]+)`, DestinationTopic: "bridge.$region"}
+	cfg := &Config{DestinationNaming: DestinationNaming{Pattern: `bridge\.[a-z]+`}, Routes: []Route{r}}
+	if err := cfg.applyDestinationNaming(); err != nil {
+		t.Fatalf("expected a templated destinationTopic to be checked per topic, got %v", err)
+	}
+	re := regexp.MustCompile("^(?:" + r.SourceTopicPattern + ")$")
+	if topic, err := cfg.Routes[0].DestinationTopicFor(re, "orders.eu"); err != nil || topic != "bridge.eu" {
+		t.Fatalf("DestinationTopicFor(orders.eu) = %q, %v", topic, err)
+	}
+	if _, err := cfg.Routes[0].DestinationTopicFor(re, "orders.eu2"); err == nil {
+		t.Fatal("expected an expanded topic outside the pattern to fail")
+	}
+}
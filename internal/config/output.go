@@ -0,0 +1,136 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Output formats accepted by output.format.
+const (
+	OutputFormatJSON     = "json"
+	OutputFormatAvro     = "avro"
+	OutputFormatProtobuf = "protobuf"
+)
+
+// DefaultSchemaRegistryTimeout bounds schema registry requests unless
+// output.registry.timeout says otherwise.
+const DefaultSchemaRegistryTimeout = 10 * time.Second
+
+// Output re-encodes the JSON payloads a route forwards in another format.
+type Output struct {
+	// Format is json (default), forwarding payloads as they are; avro; or protobuf.
+	Format string `yaml:"format"`
+	// SchemaFile holds the Avro schema, in its JSON form, that avro payloads are encoded
+	// with.
+	SchemaFile string `yaml:"schemaFile"`
+	// Registry, when set, registers the Avro schema at startup and prefixes every payload
+	// with its id in the Confluent wire format.
+	Registry *SchemaRegistry `yaml:"registry"`
+	// DescriptorFile holds a FileDescriptorSet declaring Message, as written by protoc
+	// --include_imports --descriptor_set_out.
+	DescriptorFile string `yaml:"descriptorFile"`
+	// Message is the fully qualified protobuf message payloads are encoded as, such as
+	// shop.v1.Order.
+	Message string `yaml:"message"`
+}
+
+// SchemaRegistry is a Confluent-compatible schema registry.
+type SchemaRegistry struct {
+	URL string `yaml:"url"`
+	// Subject the schema is registered under; it defaults to <destinationTopic>-value.
+	Subject string `yaml:"subject"`
+	// Headers are sent with every request, e.g. Authorization: Basic ${REGISTRY_AUTH}.
+	Headers map[string]string `yaml:"headers"`
+	// Timeout bounds each request.
+	Timeout time.Duration `yaml:"timeout"`
+	TLS     *TLSConfig    `yaml:"tls"`
+}
+
+// TLSConfigObject builds a tls.Config for registry requests; nil uses Go's defaults.
+func (s SchemaRegistry) TLSConfigObject() (*tls.Config, error) {
+	return s.TLS.tlsConfig()
+}
+
+// validateOutput checks the route's output block and defaults its registry subject. Avro
+// and protobuf output imply payload.forwardDecompressed.
+func (r *Route) validateOutput() error {
+	o := r.Output
+	if o == nil {
+		return nil
+	}
+	switch o.Format {
+	case "":
+		o.Format = OutputFormatJSON
+	case OutputFormatJSON, OutputFormatAvro, OutputFormatProtobuf:
+	default:
+		return fmt.Errorf("output: unknown format %q (want json, avro, or protobuf)", o.Format)
+	}
+	switch o.Format {
+	case OutputFormatJSON:
+		if o.SchemaFile != "" || o.Registry != nil || o.DescriptorFile != "" || o.Message != "" {
+			return errors.New("output: schemaFile, registry, descriptorFile, and message require format avro or protobuf")
+		}
+		return nil
+	case OutputFormatAvro:
+		switch {
+		case o.SchemaFile == "":
+			return errors.New("output: format avro requires schemaFile")
+		case o.DescriptorFile != "" || o.Message != "":
+			return errors.New("output: descriptorFile and message require format protobuf")
+		}
+	case OutputFormatProtobuf:
+		switch {
+		case o.DescriptorFile == "" || o.Message == "":
+			return errors.New("output: format protobuf requires descriptorFile and message")
+		case o.SchemaFile != "":
+			return errors.New("output: schemaFile requires format avro")
+		case o.Registry != nil:
+			return errors.New("output: registry supports format avro only")
+		}
+	}
+	switch {
+	case r.PayloadFormat != "" && r.PayloadFormat != PayloadFormatJSON:
+		return fmt.Errorf("output: format %s re-encodes json payloads, not payloadFormat %s", o.Format, r.PayloadFormat)
+	case r.Destination.Type == DestinationWebhook:
+		return fmt.Errorf("output: format %s requires a kafka destination", o.Format)
+	case r.Compacted:
+		return fmt.Errorf("output: format %s cannot be combined with compacted", o.Format)
+	case r.Delivery.Oversize == OversizeTruncate:
+		return fmt.Errorf("output: format %s cannot be combined with delivery.oversize truncate, which would cut encoded payloads short", o.Format)
+	}
+	// the decompressed payload is what gets encoded
+	r.Payload.ForwardDecompressed = true
+	if err := o.Registry.validate(); err != nil {
+		return fmt.Errorf("output: registry: %w", err)
+	}
+	if o.Registry != nil && o.Registry.Subject == "" {
+		if r.DestinationTemplated() {
+			return errors.New("output: registry: subject is required when destinationTopic refers to capture groups")
+		}
+		o.Registry.Subject = r.DestinationTopic + "-value"
+	}
+	return nil
+}
+
+func (s *SchemaRegistry) validate() error {
+	if s == nil {
+		return nil
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", s.URL)
+	}
+	if s.Timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+	if s.Timeout == 0 {
+		s.Timeout = DefaultSchemaRegistryTimeout
+	}
+	if err := s.TLS.validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteValidateOutput(t *testing.T) {
+	route := func(out *Output) Route {
+		return Route{SourceCluster: "a", SourceTopic: "in", DestinationTopic: "out", Output: out,
+			ReferenceFeeds: []ReferenceFeed{{Name: "f", Topic: "ref", MatchFields: []string{"id"}}}}
+	}
+	r := route(&Output{})
+	if err := r.validate(0); err != nil || r.Output.Format != OutputFormatJSON {
+		t.Fatalf("validate = %v, format %q; want json by default", err, r.Output.Format)
+	}
+	r = route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc", Registry: &SchemaRegistry{URL: "http://registry:8081"}})
+	if err := r.validate(0); err != nil || r.Output.Registry.Subject != "out-value" || r.Output.Registry.Timeout != DefaultSchemaRegistryTimeout {
+		t.Fatalf("validate = %v, registry %+v; want subject out-value and the default timeout", err, r.Output.Registry)
+	}
+	r = route(&Output{Format: OutputFormatProtobuf, DescriptorFile: "order.pb", Message: "shop.v1.Order"})
+	if err := r.validate(0); err != nil || !r.Payload.ForwardDecompressed {
+		t.Fatalf("validate = %v, forwardDecompressed %v; want protobuf output to imply it", err, r.Payload.ForwardDecompressed)
+	}
+
+	for _, tc := range []struct {
+		route func() Route
+		want  string
+	}{
+		{func() Route { return route(&Output{Format: "thrift"}) }, "unknown format"},
+		{func() Route { return route(&Output{SchemaFile: "order.avsc"}) }, "require format avro or protobuf"},
+		{func() Route { return route(&Output{Format: OutputFormatAvro}) }, "requires schemaFile"},
+		{func() Route { return route(&Output{Format: OutputFormatProtobuf, DescriptorFile: "order.pb"}) }, "requires descriptorFile and message"},
+		{func() Route {
+			return route(&Output{Format: OutputFormatProtobuf, DescriptorFile: "order.pb", Message: "shop.v1.Order", Registry: &SchemaRegistry{URL: "http://registry:8081"}})
+		}, "avro only"},
+		{func() Route {
+			return route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc", Registry: &SchemaRegistry{URL: "registry:8081"}})
+		}, "absolute http or https URL"},
+		{func() Route {
+			r := route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc"})
+			r.PayloadFormat = PayloadFormatXML
+			return r
+		}, "re-encodes json payloads"},
+		{func() Route {
+			r := route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc"})
+			r.Compacted = true
+			return r
+		}, "cannot be combined with compacted"},
+		{func() Route {
+			r := route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc"})
+			r.Delivery.MaxMessageBytes, r.Delivery.Oversize = 1024, OversizeTruncate
+			return r
+		}, "oversize truncate"},
+		{func() Route {
+			r := route(&Output{Format: OutputFormatAvro, SchemaFile: "order.avsc", Registry: &SchemaRegistry{URL: "http://registry:8081"}})
+			r.Name, r.SourceTopic, r.SourceTopicPattern, r.DestinationTopic = "route-a", "", "orders-(.*)", "bridged-$1"
+			return r
+		}, "subject is required"},
+	} {
+		r := tc.route()
+		if err := r.validate(0); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("validate = %v, want an error containing %q", err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// ReferenceStateChanges publishes a full snapshot at startup, then every cache change
+	// as it happens.
+	ReferenceStateChanges = "changes"
+	// ReferenceStateSnapshot republishes the full cache every interval.
+	ReferenceStateSnapshot = "snapshot"
+)
+
+// DefaultReferenceStateInterval is how often snapshot mode republishes the cache when
+// referenceState.interval is unset.
+const DefaultReferenceStateInterval = time.Minute
+
+// ReferenceState publishes the cache to a compacted topic on the bridge cluster, keyed and
+// encoded like the kafka storage backend, so other systems can bootstrap the current
+// reference set from it. Leaving topic empty disables it.
+type ReferenceState struct {
+	Topic string `yaml:"topic"`
+	// Mode is changes (default) or snapshot.
+	Mode string `yaml:"mode"`
+	// Interval is how often snapshot mode republishes the cache.
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (r *ReferenceState) validate(storage Storage) error {
+	if r.Topic == "" {
+		if r.Mode != "" || r.Interval != 0 {
+			return errors.New("topic is required")
+		}
+		return nil
+	}
+	if storage.Backend == StorageBackendKafka && storage.Topic == r.Topic {
+		return errors.New("topic must differ from storage.topic, which already holds the cache")
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = ReferenceStateChanges
+	case ReferenceStateChanges, ReferenceStateSnapshot:
+	default:
+		return fmt.Errorf("unknown mode %q (want changes or snapshot)", r.Mode)
+	}
+	if r.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if r.Interval != 0 && r.Mode != ReferenceStateSnapshot {
+		return errors.New("interval requires snapshot mode")
+	}
+	if r.Interval == 0 && r.Mode == ReferenceStateSnapshot {
+		r.Interval = DefaultReferenceStateInterval
+	}
+	return nil
+}
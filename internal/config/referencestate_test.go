@@ -0,0 +1,34 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReferenceStateValidate(t *testing.T) {
+	r := ReferenceState{Topic: "reference-state"}
+	if err := r.validate(Storage{}); err != nil || r.Mode != ReferenceStateChanges || r.Interval != 0 {
+		t.Fatalf("validate = %v, mode %q, interval %v; want changes without an interval", err, r.Mode, r.Interval)
+	}
+	r = ReferenceState{Topic: "reference-state", Mode: ReferenceStateSnapshot}
+	if err := r.validate(Storage{}); err != nil || r.Interval != DefaultReferenceStateInterval {
+		t.Fatalf("validate = %v, interval %v; want the default snapshot interval", err, r.Interval)
+	}
+
+	for _, tc := range []struct {
+		state   ReferenceState
+		storage Storage
+		want    string
+	}{
+		{ReferenceState{Mode: ReferenceStateSnapshot}, Storage{}, "topic is required"},
+		{ReferenceState{Topic: "state"}, Storage{Backend: StorageBackendKafka, Topic: "state"}, "must differ from storage.topic"},
+		{ReferenceState{Topic: "reference-state", Mode: "full"}, Storage{}, "unknown mode"},
+		{ReferenceState{Topic: "reference-state", Interval: time.Minute}, Storage{}, "requires snapshot mode"},
+		{ReferenceState{Topic: "reference-state", Mode: ReferenceStateSnapshot, Interval: -time.Second}, Storage{}, "must not be negative"},
+	} {
+		if err := tc.state.validate(tc.storage); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("validate(%+v) = %v, want an error containing %q", tc.state, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefixes of the secret references accepted by TLS and SASL fields.
+const (
+	secretVaultPrefix      = "vault:"
+	secretKubernetesPrefix = "k8s:"
+)
+
+// DefaultSecretRefresh is how long resolved secrets are cached unless
+// secrets.refreshInterval says otherwise.
+const DefaultSecretRefresh = 5 * time.Minute
+
+// secretRetryInterval spaces out fetches of a secret whose refresh failed, while its
+// cached value keeps being used.
+const secretRetryInterval = 30 * time.Second
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Secrets configures how vault:mount/path#key and k8s:namespace/secret#key references
+// in TLS and SASL fields are resolved.
+type Secrets struct {
+	// RefreshInterval is how long a resolved secret is cached before it is fetched
+	// again. A shorter Vault lease wins.
+	RefreshInterval time.Duration     `yaml:"refreshInterval"`
+	Vault           VaultSecrets      `yaml:"vault"`
+	Kubernetes      KubernetesSecrets `yaml:"kubernetes"`
+}
+
+// VaultSecrets locates a Vault server and the token used to read from it.
+type VaultSecrets struct {
+	// Address defaults to VAULT_ADDR.
+	Address string `yaml:"address"`
+	// Token defaults to the contents of TokenFile, re-read on every fetch so an agent can
+	// rotate it, and then to VAULT_TOKEN.
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"tokenFile"`
+	Namespace string `yaml:"namespace"`
+	// KVVersion is the version of the key/value secrets engine at the mount: 2 (default)
+	// or 1.
+	KVVersion int `yaml:"kvVersion"`
+	// CAFile verifies the server certificate instead of the system roots.
+	CAFile string `yaml:"caFile"`
+}
+
+// KubernetesSecrets locates the Kubernetes API. The defaults work inside a pod whose
+// service account may get the referenced secrets.
+type KubernetesSecrets struct {
+	// APIServer defaults to the in-cluster address from KUBERNETES_SERVICE_HOST and
+	// KUBERNETES_SERVICE_PORT.
+	APIServer string `yaml:"apiServer"`
+	// TokenFile and CAFile default to the pod's service account token and CA.
+	TokenFile string `yaml:"tokenFile"`
+	CAFile    string `yaml:"caFile"`
+}
+
+func (s *Secrets) validate() error {
+	if s.RefreshInterval < 0 {
+		return errors.New("refreshInterval cannot be negative")
+	}
+	if s.RefreshInterval == 0 {
+		s.RefreshInterval = DefaultSecretRefresh
+	}
+	switch s.Vault.KVVersion {
+	case 0:
+		s.Vault.KVVersion = 2
+	case 1, 2:
+	default:
+		return fmt.Errorf("vault.kvVersion %d must be 1 or 2", s.Vault.KVVersion)
+	}
+	return nil
+}
+
+// secretRef is a parsed secret reference.
+type secretRef struct {
+	provider string // the reference prefix
+	path     string
+	key      string
+}
+
+// parseSecretRef parses a vault: or k8s: reference. Other values report false.
+func parseSecretRef(s string) (secretRef, bool, error) {
+	var ref secretRef
+	var rest string
+	switch {
+	case strings.HasPrefix(s, secretVaultPrefix):
+		ref.provider, rest = secretVaultPrefix, s[len(secretVaultPrefix):]
+	case strings.HasPrefix(s, secretKubernetesPrefix):
+		ref.provider, rest = secretKubernetesPrefix, s[len(secretKubernetesPrefix):]
+	default:
+		return secretRef{}, false, nil
+	}
+	var ok bool
+	ref.path, ref.key, ok = strings.Cut(rest, "#")
+	if !ok || ref.path == "" || ref.key == "" {
+		return secretRef{}, true, fmt.Errorf("secret reference %q must end in #key", s)
+	}
+	first, second, ok := strings.Cut(ref.path, "/")
+	switch {
+	case ref.provider == secretVaultPrefix && (!ok || first == "" || second == ""):
+		return secretRef{}, true, fmt.Errorf("secret reference %q must be vault:mount/path#key", s)
+	case ref.provider == secretKubernetesPrefix && (!ok || first == "" || second == "" || strings.Contains(second, "/")):
+		return secretRef{}, true, fmt.Errorf("secret reference %q must be k8s:namespace/secret#key", s)
+	}
+	return ref, true, nil
+}
+
+// isSecretRef reports whether value is a vault: or k8s: reference.
+func isSecretRef(value string) bool {
+	_, ok, _ := parseSecretRef(value)
+	return ok
+}
+
+// SecretStore resolves secret references. Each secret is fetched once per refresh
+// interval and shared by the references to its keys; when a refresh fails the cached
+// value stays in use and the failure is logged.
+type SecretStore struct {
+	cfg Secrets
+
+	mu    sync.Mutex
+	cache map[string]*cachedSecret
+	now   func() time.Time
+}
+
+type cachedSecret struct {
+	values  map[string]string
+	expires time.Time
+}
+
+// NewSecretStore returns a store resolving references with the providers in cfg.
+func NewSecretStore(cfg Secrets) *SecretStore {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultSecretRefresh
+	}
+	return &SecretStore{cfg: cfg, cache: make(map[string]*cachedSecret)}
+}
+
+// Resolve returns the secret value a reference names, or value itself when it is not a
+// reference.
+func (s *SecretStore) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok, err := parseSecretRef(value)
+	if !ok || err != nil {
+		return value, err
+	}
+	values, err := s.secret(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	v, ok := values[ref.key]
+	if !ok {
+		return "", fmt.Errorf("secret %s%s has no key %s", ref.provider, ref.path, ref.key)
+	}
+	return v, nil
+}
+
+func (s *SecretStore) secret(ctx context.Context, ref secretRef) (map[string]string, error) {
+	id := ref.provider + ref.path
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	cached := s.cache[id]
+	if cached != nil && now.Before(cached.expires) {
+		return cached.values, nil
+	}
+
+	var values map[string]string
+	var lease time.Duration
+	var err error
+	if ref.provider == secretVaultPrefix {
+		values, lease, err = s.cfg.Vault.fetch(ctx, ref.path)
+	} else {
+		values, err = s.cfg.Kubernetes.fetch(ctx, ref.path)
+	}
+	if err != nil {
+		if cached == nil {
+			return nil, fmt.Errorf("secret %s%s: %w", ref.provider, ref.path, err)
+		}
+		log.Printf("warn: secret %s%s: refresh failed, using the cached value: %v", ref.provider, ref.path, err)
+		cached.expires = now.Add(min(secretRetryInterval, s.cfg.RefreshInterval))
+		return cached.values, nil
+	}
+	ttl := s.cfg.RefreshInterval
+	if lease > 0 && lease < ttl {
+		ttl = lease
+	}
+	s.cache[id] = &cachedSecret{values: values, expires: now.Add(ttl)}
+	return values, nil
+}
+
+func (s *SecretStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// vaultResponse is the body of a Vault read. KV version 2 nests the secret's data under
+// a second data object.
+type vaultResponse struct {
+	Data          json.RawMessage `json:"data"`
+	LeaseDuration int64           `json:"lease_duration"`
+	Errors        []string        `json:"errors"`
+}
+
+// fetch reads the secret at mount/path and returns its keys and lease.
+func (v VaultSecrets) fetch(ctx context.Context, path string) (map[string]string, time.Duration, error) {
+	addr := v.Address
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, 0, errors.New("vault address not configured (set secrets.vault.address or VAULT_ADDR)")
+	}
+	token, err := v.token()
+	if err != nil {
+		return nil, 0, err
+	}
+	apiPath := path
+	if v.KVVersion != 1 {
+		mount, rest, _ := strings.Cut(path, "/")
+		apiPath = mount + "/data/" + rest
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+apiPath, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	client, err := secretHTTPClient(v.CAFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("vault request: %w", err)
+	}
+	defer resp.Body.Close()
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("vault response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(body.Errors, "; "))
+	}
+	data := body.Data
+	if v.KVVersion != 1 {
+		var nested struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &nested); err != nil {
+			return nil, 0, fmt.Errorf("vault response: %w", err)
+		}
+		data = nested.Data
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return nil, 0, fmt.Errorf("vault response has no secret data at %s", path)
+	}
+	values := make(map[string]string, len(raw))
+	for k, val := range raw {
+		if str, ok := val.(string); ok {
+			values[k] = str
+			continue
+		}
+		encoded, _ := json.Marshal(val)
+		values[k] = string(encoded)
+	}
+	return values, time.Duration(body.LeaseDuration) * time.Second, nil
+}
+
+func (v VaultSecrets) token() (string, error) {
+	if v.Token != "" {
+		return v.Token, nil
+	}
+	if v.TokenFile != "" {
+		data, err := os.ReadFile(v.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read vault token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", errors.New("vault token not configured (set secrets.vault.token, tokenFile, or VAULT_TOKEN)")
+}
+
+// fetch reads the secret namespace/name through the Kubernetes API.
+func (k KubernetesSecrets) fetch(ctx context.Context, path string) (map[string]string, error) {
+	namespace, name, _ := strings.Cut(path, "/")
+	server := k.APIServer
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("kubernetes API server not configured and not running in a cluster (set secrets.kubernetes.apiServer)")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+	tokenFile, caFile := k.TokenFile, k.CAFile
+	if tokenFile == "" {
+		tokenFile = serviceAccountDir + "/token"
+	}
+	if caFile == "" && k.APIServer == "" {
+		caFile = serviceAccountDir + "/ca.crt"
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read kubernetes token: %w", err)
+	}
+	endpoint := strings.TrimRight(server, "/") + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	client, err := secretHTTPClient(caFile)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes request: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data    map[string][]byte `json:"data"` // base64 in JSON
+		Message string            `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("kubernetes response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes returned %d: %s", resp.StatusCode, body.Message)
+	}
+	values := make(map[string]string, len(body.Data))
+	for k, v := range body.Data {
+		values[k] = string(v)
+	}
+	return values, nil
+}
+
+// secretHTTPClient returns a client for a secrets provider, trusting caFile when set.
+func secretHTTPClient(caFile string) (*http.Client, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if caFile == "" {
+		return client, nil
+	}
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates in %s", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	client.Transport = transport
+	return client, nil
+}
+
+// bindSecrets hands a shared SecretStore to every TLS and SASL block and referenceSQL DSN
+// and resolves their references once, so a missing or unreadable secret fails the load.
+func (c *Config) bindSecrets(ctx context.Context) error {
+	secrets := NewSecretStore(c.Secrets)
+	type tlsBlock struct {
+		name string
+		tls  *TLSConfig
+	}
+	type saslBlock struct {
+		name string
+		sasl *SASLConfig
+	}
+	tlsBlocks := []tlsBlock{{"bridgeCluster.tls", c.BridgeCluster.TLS}}
+	saslBlocks := []saslBlock{{"bridgeCluster.sasl", c.BridgeCluster.SASL}}
+	for i, sc := range c.SourceClusters {
+		tlsBlocks = append(tlsBlocks, tlsBlock{fmt.Sprintf("sourceCluster %d: tls", i), sc.TLS})
+		saslBlocks = append(saslBlocks, saslBlock{fmt.Sprintf("sourceCluster %d: sasl", i), sc.SASL})
+	}
+	for i, r := range c.Routes {
+		tlsBlocks = append(tlsBlocks, tlsBlock{fmt.Sprintf("route %d: destination.webhook.tls", i), r.Destination.Webhook.TLS})
+		if r.ReferenceHTTP != nil {
+			tlsBlocks = append(tlsBlocks, tlsBlock{fmt.Sprintf("route %d: referenceHTTP.tls", i), r.ReferenceHTTP.TLS})
+		}
+		if r.Output != nil && r.Output.Registry != nil {
+			tlsBlocks = append(tlsBlocks, tlsBlock{fmt.Sprintf("route %d: output.registry.tls", i), r.Output.Registry.TLS})
+		}
+	}
+	for _, b := range tlsBlocks {
+		if b.tls == nil {
+			continue
+		}
+		b.tls.secrets = secrets
+		for _, field := range []struct{ name, value string }{{"caFile", b.tls.CAFile}, {"certFile", b.tls.CertFile}, {"keyFile", b.tls.KeyFile}} {
+			if !isSecretRef(field.value) {
+				continue
+			}
+			if _, err := secrets.Resolve(ctx, field.value); err != nil {
+				return fmt.Errorf("%s: %s: %w", b.name, field.name, err)
+			}
+		}
+	}
+	for i, r := range c.Routes {
+		if r.ReferenceSQL == nil {
+			continue
+		}
+		r.ReferenceSQL.secrets = secrets
+		if !isSecretRef(r.ReferenceSQL.DSN) {
+			continue
+		}
+		if _, err := secrets.Resolve(ctx, r.ReferenceSQL.DSN); err != nil {
+			return fmt.Errorf("route %d: referenceSQL.dsn: %w", i, err)
+		}
+	}
+	for _, b := range saslBlocks {
+		if b.sasl == nil || b.sasl.OAuth == nil {
+			continue
+		}
+		b.sasl.OAuth.secrets = secrets
+		if !isSecretRef(b.sasl.OAuth.ClientSecret) {
+			continue
+		}
+		if _, err := secrets.Resolve(ctx, b.sasl.OAuth.ClientSecret); err != nil {
+			return fmt.Errorf("%s: oauth.clientSecret: %w", b.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    secretRef
+		isRef   bool
+		wantErr bool
+	}{
+		{in: "/etc/tls/ca.pem"},
+		{in: "vault:kv/kafka/client#cert", want: secretRef{provider: secretVaultPrefix, path: "kv/kafka/client", key: "cert"}, isRef: true},
+		{in: "k8s:bridge/kafka-tls#tls.key", want: secretRef{provider: secretKubernetesPrefix, path: "bridge/kafka-tls", key: "tls.key"}, isRef: true},
+		{in: "vault:kv/kafka", isRef: true, wantErr: true},
+		{in: "vault:kv#key", isRef: true, wantErr: true},
+		{in: "k8s:kafka-tls#tls.key", isRef: true, wantErr: true},
+		{in: "k8s:bridge/a/b#key", isRef: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		got, ok, err := parseSecretRef(tc.in)
+		if ok != tc.isRef || (err != nil) != tc.wantErr || (err == nil && got != tc.want) {
+			t.Fatalf("parseSecretRef(%q) = %+v, %v, %v", tc.in, got, ok, err)
+		}
+	}
+}
+
+// fakeVault serves KV version 2 reads of kv/kafka, failing while down is set.
+func fakeVault(t *testing.T, secret *atomic.Value, reads *atomic.Int32, down *atomic.Bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		if r.URL.Path != "/v1/kv/data/kafka" || r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"sealed"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"secret": secret.Load()}}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSecretStoreCachesAndRenews(t *testing.T) {
+	var secret atomic.Value
+	secret.Store("first")
+	var reads atomic.Int32
+	var down atomic.Bool
+	vault := fakeVault(t, &secret, &reads, &down)
+
+	s := NewSecretStore(Secrets{RefreshInterval: time.Minute, Vault: VaultSecrets{Address: vault.URL, Token: "root", KVVersion: 2}})
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v, err := s.Resolve(ctx, "vault:kv/kafka#secret"); err != nil || v != "first" {
+			t.Fatalf("Resolve = %q, %v", v, err)
+		}
+	}
+	if reads.Load() != 1 {
+		t.Fatalf("expected one vault read while cached, got %d", reads.Load())
+	}
+
+	secret.Store("second")
+	now = now.Add(2 * time.Minute)
+	if v, err := s.Resolve(ctx, "vault:kv/kafka#secret"); err != nil || v != "second" {
+		t.Fatalf("Resolve after refresh interval = %q, %v", v, err)
+	}
+	down.Store(true)
+	now = now.Add(2 * time.Minute)
+	if v, err := s.Resolve(ctx, "vault:kv/kafka#secret"); err != nil || v != "second" {
+		t.Fatalf("Resolve with vault down = %q, %v; want the cached value", v, err)
+	}
+	if _, err := s.Resolve(ctx, "vault:kv/kafka#missing"); err == nil {
+		t.Fatal("expected error for a missing key")
+	}
+	if v, _ := s.Resolve(ctx, "/plain/path"); v != "/plain/path" {
+		t.Fatalf("plain values should pass through, got %q", v)
+	}
+}
+
+func TestSecretStoreKubernetes(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/bridge/secrets/kafka-tls" || r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "secrets \"kafka-tls\" not found"})
+			return
+		}
+		// []byte values encode as base64, as the API returns them
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string][]byte{"tls.key": []byte("PRIVATE KEY")}})
+	}))
+	defer api.Close()
+
+	s := NewSecretStore(Secrets{Kubernetes: KubernetesSecrets{APIServer: api.URL, TokenFile: tokenFile}})
+	if v, err := s.Resolve(context.Background(), "k8s:bridge/kafka-tls#tls.key"); err != nil || v != "PRIVATE KEY" {
+		t.Fatalf("Resolve = %q, %v", v, err)
+	}
+	if _, err := s.Resolve(context.Background(), "k8s:bridge/other#tls.key"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestLoadResolvesSecretReferences(t *testing.T) {
+	var secret atomic.Value
+	secret.Store("client-secret")
+	var reads atomic.Int32
+	var down atomic.Bool
+	vault := fakeVault(t, &secret, &reads, &down)
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `sourceClusters:
+  - name: source-a
+    brokers: ["source:9092"]
+    sourceGroupId: src
+bridgeCluster:
+  brokers: ["bridge:9092"]
+  sasl:
+    mechanism: OAUTHBEARER
+    oauth:
+      tokenUrl: https://idp/token
+      clientId: bridge
+      clientSecret: vault:kv/kafka#secret
+clientId: bridge
+referenceGroupId: ref
+routes:
+  - name: route-a
+    sourceCluster: source-a
+    sourceTopic: src
+    destinationTopic: dest
+    referenceFeeds:
+      - name: feed
+        topic: ref
+        matchFields: ["id"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v, err := cfg.BridgeCluster.SASL.OAuth.ResolveClientSecret(context.Background()); err != nil || v != "client-secret" {
+		t.Fatalf("ResolveClientSecret = %q, %v", v, err)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(content, "kafka#secret", "kafka#other", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "bridgeCluster.sasl: oauth.clientSecret") {
+		t.Fatalf("expected unresolvable reference to fail the load, got %v", err)
+	}
+}
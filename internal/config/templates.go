@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routeTemplate generates one route per entry of Vars. Every scalar value in Route may use
+// text/template actions such as {{ .region }}, filled from that entry; referencing a
+// variable the entry does not define is an error.
+type routeTemplate struct {
+	Name  string              `yaml:"name"`
+	Vars  []map[string]string `yaml:"vars"`
+	Route yaml.Node           `yaml:"route"`
+}
+
+// expandRouteTemplates replaces the document's routeTemplates section with the routes it
+// generates, appended to routes, so they are interpolated and validated like routes
+// written out by hand. Generated routes must render distinct names that no other route
+// uses.
+func expandRouteTemplates(doc *yaml.Node) error {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	at := mappingIndex(root, "routeTemplates")
+	if at < 0 {
+		return nil
+	}
+	var templates []routeTemplate
+	if err := root.Content[at+1].Decode(&templates); err != nil {
+		return fmt.Errorf("routeTemplates: %w", err)
+	}
+	root.Content = append(root.Content[:at:at], root.Content[at+2:]...)
+
+	ri := mappingIndex(root, "routes")
+	if ri < 0 {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "routes"},
+			&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"})
+		ri = len(root.Content) - 2
+	}
+	seq := root.Content[ri+1]
+	if seq.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: routes must be a list", seq.Line)
+	}
+	names := make(map[string]string)
+	for _, r := range seq.Content {
+		if name := routeNodeName(r); name != "" {
+			names[strings.ToLower(name)] = "routes"
+		}
+	}
+	for ti, t := range templates {
+		label := fmt.Sprintf("routeTemplates[%d]", ti)
+		if t.Name != "" {
+			label = fmt.Sprintf("routeTemplate %q", t.Name)
+		}
+		if t.Route.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: route must be a mapping", label)
+		}
+		if len(t.Vars) == 0 {
+			return fmt.Errorf("%s: vars cannot be empty", label)
+		}
+		for vi, vars := range t.Vars {
+			route := copyNode(&t.Route)
+			if err := renderNode(route, vars); err != nil {
+				return fmt.Errorf("%s: vars[%d]: %w", label, vi, err)
+			}
+			name := routeNodeName(route)
+			if name == "" {
+				return fmt.Errorf("%s: vars[%d]: the route needs a name, e.g. orders-{{ .region }}", label, vi)
+			}
+			if other, ok := names[strings.ToLower(name)]; ok {
+				return fmt.Errorf("%s: vars[%d]: route name %q is already used by %s", label, vi, name, other)
+			}
+			names[strings.ToLower(name)] = fmt.Sprintf("%s vars[%d]", label, vi)
+			seq.Content = append(seq.Content, route)
+		}
+	}
+	return nil
+}
+
+// mappingIndex returns the index of key's key node in a mapping node, or -1.
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func routeNodeName(route *yaml.Node) string {
+	if route.Kind != yaml.MappingNode {
+		return ""
+	}
+	if i := mappingIndex(route, "name"); i >= 0 {
+		return route.Content[i+1].Value
+	}
+	return ""
+}
+
+// copyNode deep-copies n for rendering. Aliases are replaced by copies of the nodes they
+// refer to, so anchored defaults merged into a template render for each route too.
+func copyNode(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		return copyNode(n.Alias)
+	}
+	c := *n
+	c.Anchor = ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
+
+// renderNode executes every scalar value holding a template action with vars. Keys are
+// left untouched.
+func renderNode(node *yaml.Node, vars map[string]string) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := renderNode(child, vars); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := renderNode(node.Content[i], vars); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "{{") {
+			return nil
+		}
+		tmpl, err := template.New("").Option("missingkey=error").Parse(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		// a value opening with {{ has to be quoted in YAML; let the rendered value
+		// re-resolve anyway so "{{ .maxValues }}" can fill an int field
+		node.Tag, node.Style = "", 0
+		node.Value = b.String()
+	}
+	return nil
+}
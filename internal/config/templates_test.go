@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const templateConfig = `sourceClusters:
+  - name: source-eu
+    brokers: ["eu:9092"]
+    sourceGroupId: src
+  - name: source-us
+    brokers: ["us:9092"]
+    sourceGroupId: src
+bridgeCluster:
+  brokers: ["bridge:9092"]
+clientId: bridge
+referenceGroupId: ref
+x-feeds: &feeds
+  referenceFeeds:
+    - name: customers
+      topic: customers.{{ .region }}
+      matchFields: ["id"]
+routes:
+  - name: audit
+    sourceCluster: source-eu
+    sourceTopic: audit
+    destinationTopic: audit.matched
+    referenceFeeds:
+      - name: feed
+        topic: ref
+        matchFields: ["id"]
+routeTemplates:
+  - name: orders
+    vars:
+      - {region: eu, limit: "100"}
+      - {region: us, limit: "200"}
+    route:
+      <<: *feeds
+      name: orders-{{ .region }}
+      sourceCluster: source-{{ .region }}
+      sourceTopic: orders.{{ .region }}
+      destinationTopic: "{{ .region }}.orders.matched"
+      maxValues: "{{ .limit }}"
+`
+
+func writeTemplateConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExpandsRouteTemplates(t *testing.T) {
+	cfg, err := Load(writeTemplateConfig(t, templateConfig))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(cfg.Routes))
+	}
+	us := cfg.Routes[2]
+	if us.Name != "orders-us" || us.SourceCluster != "source-us" || us.DestinationTopic != "us.orders.matched" || us.MaxValues != 200 {
+		t.Fatalf("unexpected generated route: %+v", us)
+	}
+	if len(us.ReferenceFeeds) != 1 || us.ReferenceFeeds[0].Topic != "customers.us" {
+		t.Fatalf("merged anchor not rendered: %+v", us.ReferenceFeeds)
+	}
+	if cfg.Routes[1].ReferenceFeeds[0].Topic != "customers.eu" {
+		t.Fatalf("routes share the rendered anchor: %+v", cfg.Routes[1].ReferenceFeeds)
+	}
+}
+
+func TestLoadRouteTemplateErrors(t *testing.T) {
+	cases := map[string]string{
+		"missing variable": strings.Replace(templateConfig, "{region: us, limit: \"200\"}", "{region: us}", 1),
+		"duplicate name":   strings.Replace(templateConfig, "name: orders-{{ .region }}", "name: audit", 1),
+		"unknown cluster":  strings.Replace(templateConfig, "{region: us, limit: \"200\"}", "{region: ap, limit: \"1\"}", 1),
+		"no vars":          strings.Replace(strings.Replace(templateConfig, "      - {region: eu, limit: \"100\"}\n", "", 1), "      - {region: us, limit: \"200\"}\n", "", 1),
+	}
+	want := map[string]string{
+		"missing variable": `routeTemplate "orders": vars[1]: line 37: template`,
+		"duplicate name":   `route name "audit" is already used by routes`,
+		"unknown cluster":  `route 2: sourceCluster "source-ap" not found`,
+		"no vars":          "vars cannot be empty",
+	}
+	for name, content := range cases {
+		if _, err := Load(writeTemplateConfig(t, content)); err == nil || !strings.Contains(err.Error(), want[name]) {
+			t.Fatalf("%s: expected error containing %q, got %v", name, want[name], err)
+		}
+	}
+}
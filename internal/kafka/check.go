@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ClusterCheck reports whether the topics and consumer groups a config uses are visible
+// on a cluster. A nil error means the topic exists or the group can be described.
+type ClusterCheck struct {
+	Topics map[string]error
+	Groups map[string]error
+}
+
+// CheckCluster connects to brokers and describes topics and groups without creating or
+// joining anything. The returned error means the cluster could not be reached or
+// authenticated against; per-resource failures are reported in the ClusterCheck.
+func CheckCluster(ctx context.Context, brokers []string, dialer *kafka.Dialer, topics, groups []string) (ClusterCheck, error) {
+	client := newClient(brokers, dialer)
+	check := ClusterCheck{
+		Topics: make(map[string]error, len(topics)),
+		Groups: make(map[string]error, len(groups)),
+	}
+
+	// the metadata request doubles as the connectivity and authentication check
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return check, fmt.Errorf("metadata: %w", err)
+	}
+	for _, topic := range topics {
+		check.Topics[topic] = kafka.UnknownTopicOrPartition
+	}
+	for _, t := range meta.Topics {
+		if _, ok := check.Topics[t.Name]; ok {
+			check.Topics[t.Name] = t.Error
+		}
+	}
+
+	if len(groups) == 0 {
+		return check, nil
+	}
+	described, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: groups})
+	if err != nil {
+		for _, group := range groups {
+			check.Groups[group] = err
+		}
+		return check, nil
+	}
+	for _, g := range described.Groups {
+		check.Groups[g.GroupID] = g.Error
+	}
+	return check, nil
+}
+
+// MatchingTopics lists the topics on brokers whose names re matches, sorted. Internal
+// topics such as __consumer_offsets are never returned.
+func MatchingTopics(ctx context.Context, brokers []string, dialer *kafka.Dialer, re *regexp.Regexp) ([]string, error) {
+	meta, err := newClient(brokers, dialer).Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	var topics []string
+	for _, t := range meta.Topics {
+		if t.Internal || t.Error != nil || !re.MatchString(t.Name) {
+			continue
+		}
+		topics = append(topics, t.Name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
@@ -0,0 +1,207 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/store"
+)
+
+// Admin command operations shared between replicas.
+const (
+	CommandInject = "inject"
+	CommandDelete = "delete"
+	CommandClear  = "clear"
+	CommandSplit  = "split"
+)
+
+const seenCommandLimit = 4096
+
+// Command is an admin mutation broadcast to every replica. An empty Route targets all routes.
+// Split commands clone Route's cache into Target, limited to the feeds listed in Values.
+type Command struct {
+	ID       string    `json:"id"`
+	Origin   string    `json:"origin"`
+	Op       string    `json:"op"`
+	Route    string    `json:"route,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Values   []string  `json:"values,omitempty"`
+	IssuedAt time.Time `json:"issuedAt"`
+	// Annotations are recorded with injected values.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Meta, when set, is the provenance recorded with injected values instead of the
+	// admin API's; the elected leader sets it when broadcasting reference feed changes.
+	Meta *store.Metadata `json:"meta,omitempty"`
+}
+
+// Coordinator fans admin commands out to peer replicas through a single-partition topic
+// and applies commands received from them exactly once per idempotency key.
+type Coordinator struct {
+	brokers    []string
+	dialer     *kafka.Dialer
+	topic      string
+	instanceID string
+	writer     *kafka.Writer
+	seen       *idempotencySet
+}
+
+// NewCoordinator builds a coordinator for the given topic and local instance ID.
+func NewCoordinator(brokers []string, dialer *kafka.Dialer, topic, instanceID string) *Coordinator {
+	return &Coordinator{
+		brokers:    brokers,
+		dialer:     dialer,
+		topic:      topic,
+		instanceID: instanceID,
+		seen:       newIdempotencySet(seenCommandLimit),
+		writer: kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      brokers,
+			Topic:        topic,
+			RequiredAcks: int(kafka.RequireAll),
+			Async:        false,
+			Dialer:       dialer,
+		}),
+	}
+}
+
+// InstanceID returns the identifier stamped on commands published by this replica.
+func (c *Coordinator) InstanceID() string {
+	return c.instanceID
+}
+
+// Claim marks an idempotency key as handled and reports whether it was new. Callers
+// should skip applying a command whose key has already been claimed.
+func (c *Coordinator) Claim(id string) bool {
+	return c.seen.add(id)
+}
+
+// Release forgets a claimed idempotency key, so a command that failed to apply or to
+// publish can be retried under it.
+func (c *Coordinator) Release(id string) {
+	c.seen.remove(id)
+}
+
+// Publish broadcasts a locally applied command to peers. The command ID must already be
+// claimed; release it when Publish fails and the command may be retried.
+func (c *Coordinator) Publish(ctx context.Context, cmd Command) error {
+	cmd.Origin = c.instanceID
+	if cmd.IssuedAt.IsZero() {
+		cmd.IssuedAt = time.Now().UTC()
+	}
+	value, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("encode command: %w", err)
+	}
+	return c.writer.WriteMessages(ctx, kafka.Message{Key: []byte(cmd.ID), Value: value})
+}
+
+// GroupID is the consumer group the replica reads the topic in: <topic>-<instanceID>.
+// A replica restarted under the same instance ID resumes after the last command it
+// handled, so commands published while it was down are applied too.
+func (c *Coordinator) GroupID() string {
+	return c.topic + "-" + c.instanceID
+}
+
+// Run consumes commands and hands those from other replicas to apply, committing each
+// once handled. The first run of an instance ID starts at the end of the topic.
+func (c *Coordinator) Run(ctx context.Context, apply func(Command)) error {
+	err := delivery.EnsureTopic(c.brokers, c.dialer, kafka.TopicConfig{
+		Topic:             c.topic,
+		NumPartitions:     1,
+		ReplicationFactor: -1,
+	})
+	if err != nil {
+		return err
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     c.brokers,
+		Topic:       c.topic,
+		GroupID:     c.GroupID(),
+		StartOffset: kafka.LastOffset,
+		Dialer:      c.dialer,
+	})
+	defer reader.Close()
+
+	log.Printf("coordinator %s listening to %s as group %s", c.instanceID, c.topic, c.GroupID())
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		var cmd Command
+		if err := json.Unmarshal(msg.Value, &cmd); err != nil {
+			log.Printf("coordinator: invalid command skipped at offset %d: %v", msg.Offset, err)
+		} else if cmd.Origin != c.instanceID && cmd.ID != "" && c.Claim(cmd.ID) {
+			apply(cmd)
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return fmt.Errorf("commit offset %d: %w", msg.Offset, err)
+		}
+	}
+}
+
+// Close releases the underlying writer.
+func (c *Coordinator) Close() error {
+	return c.writer.Close()
+}
+
+// NewCommandID returns a random idempotency key for commands that arrive without one.
+func NewCommandID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// idempotencySet remembers the most recent keys, evicting the oldest beyond limit.
+type idempotencySet struct {
+	mu    sync.Mutex
+	limit int
+	keys  map[string]struct{}
+	order []string
+}
+
+func newIdempotencySet(limit int) *idempotencySet {
+	return &idempotencySet{limit: limit, keys: make(map[string]struct{}, limit)}
+}
+
+func (s *idempotencySet) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.keys[key]; !exists {
+		return
+	}
+	delete(s.keys, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *idempotencySet) add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.keys[key]; exists {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	s.order = append(s.order, key)
+	if len(s.order) > s.limit {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		delete(s.keys, oldest)
+	}
+	return true
+}
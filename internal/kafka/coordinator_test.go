@@ -0,0 +1,41 @@
+package kafka
+
+import "testing"
+
+func TestIdempotencySetEvictsOldest(t *testing.T) {
+	s := newIdempotencySet(2)
+	for _, key := range []string{"a", "b"} {
+		if !s.add(key) {
+			t.Fatalf("expected %q to be new", key)
+		}
+	}
+	if s.add("a") {
+		t.Fatalf("expected duplicate key to be rejected")
+	}
+	if !s.add("c") {
+		t.Fatalf("expected c to be new")
+	}
+	// a was the oldest entry and should have been evicted by c
+	if !s.add("a") {
+		t.Fatalf("expected evicted key to be accepted again")
+	}
+	if s.add("c") {
+		t.Fatalf("expected recent key to still be remembered")
+	}
+}
+
+func TestIdempotencySetRemove(t *testing.T) {
+	s := newIdempotencySet(2)
+	s.add("a")
+	s.add("b")
+	s.remove("a")
+	s.remove("missing")
+	if !s.add("a") {
+		t.Fatalf("expected released key to be accepted again")
+	}
+	// b is now the oldest entry and is evicted by c, while a survives
+	s.add("c")
+	if s.add("a") || !s.add("b") {
+		t.Fatalf("unexpected eviction order: %v", s.order)
+	}
+}
@@ -0,0 +1,90 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+)
+
+// LeaderElection elects one leader among replicas through a consumer group on a
+// single-partition topic: the member assigned partition 0 leads until the group
+// rebalances. Nothing is read from or written to the topic.
+type LeaderElection struct {
+	brokers    []string
+	dialer     *kafka.Dialer
+	topic      string
+	groupID    string
+	instanceID string
+}
+
+// NewLeaderElection builds an election for the given topic and consumer group.
+func NewLeaderElection(brokers []string, dialer *kafka.Dialer, topic, groupID, instanceID string) *LeaderElection {
+	return &LeaderElection{brokers: brokers, dialer: dialer, topic: topic, groupID: groupID, instanceID: instanceID}
+}
+
+// Run takes part in the election until ctx is done. Each time this instance becomes the
+// leader, lead runs with a context that is cancelled when leadership is lost; the next
+// rebalance waits for lead to return, so two leaders never overlap within the group.
+func (e *LeaderElection) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	err := delivery.EnsureTopic(e.brokers, e.dialer, kafka.TopicConfig{
+		Topic:             e.topic,
+		NumPartitions:     1,
+		ReplicationFactor: -1,
+	})
+	if err != nil {
+		return err
+	}
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:             e.groupID,
+		Brokers:        e.brokers,
+		Dialer:         e.dialer,
+		Topics:         []string{e.topic},
+		SessionTimeout: 10 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	defer group.Close()
+
+	for {
+		gen, err := group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, kafka.ErrGroupClosed) {
+				return err
+			}
+			log.Printf("leader election %s: %v; rejoining", e.groupID, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if !ownsPartitionZero(gen.Assignments[e.topic]) {
+			log.Printf("leader election %s: %s is a follower (generation %d)", e.groupID, e.instanceID, gen.ID)
+			continue
+		}
+		log.Printf("leader election %s: %s is the leader (generation %d)", e.groupID, e.instanceID, gen.ID)
+		gen.Start(func(genCtx context.Context) {
+			lead(genCtx)
+			log.Printf("leader election %s: %s stepped down (generation %d)", e.groupID, e.instanceID, gen.ID)
+		})
+	}
+}
+
+func ownsPartitionZero(assignments []kafka.PartitionAssignment) bool {
+	for _, a := range assignments {
+		if a.ID == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MemoryBroker is an in-process stand-in for the Kafka clusters, for exercising routes
+// end to end without a cluster. Topics are created on first use and keep every message
+// written to them; consumer groups commit offsets per topic partition like Kafka's do.
+// Every topic starts empty, so a group with nothing committed reads from the first message.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	// committed holds, per group, the next offset to read of each topic partition.
+	committed map[string]map[memoryPartition]int64
+	// written is closed and replaced whenever messages are written, waking blocked readers.
+	written chan struct{}
+}
+
+type memoryTopic struct {
+	partitions [][]kafka.Message
+}
+
+type memoryPartition struct {
+	topic     string
+	partition int
+}
+
+// NewMemoryBroker returns an empty broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics:    make(map[string]*memoryTopic),
+		committed: make(map[string]map[memoryPartition]int64),
+		written:   make(chan struct{}),
+	}
+}
+
+// Topic returns a writer that appends to topic. Messages keep the partition they carry,
+// which is the source partition for forwarded messages; the topic grows partitions for
+// them as needed.
+func (b *MemoryBroker) Topic(topic string) *MemoryWriter {
+	return &MemoryWriter{broker: b, topic: topic}
+}
+
+// MemoryWriter appends messages to a topic of a MemoryBroker.
+type MemoryWriter struct {
+	broker *MemoryBroker
+	topic  string
+}
+
+// WriteMessages appends msgs, setting their topic, offset, and, when unset, time.
+func (w *MemoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	_, err := w.broker.Produce(w.topic, msgs...)
+	return err
+}
+
+// Produce appends msgs to topic and returns them as stored, with their offsets.
+func (b *MemoryBroker) Produce(topic string, msgs ...kafka.Message) ([]kafka.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topics[topic]
+	if t == nil {
+		t = &memoryTopic{partitions: make([][]kafka.Message, 1)}
+		b.topics[topic] = t
+	}
+	now := time.Now()
+	stored := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		partition := max(msg.Partition, 0)
+		for len(t.partitions) <= partition {
+			t.partitions = append(t.partitions, nil)
+		}
+		msg.Topic = topic
+		msg.Partition = partition
+		msg.Offset = int64(len(t.partitions[partition]))
+		if msg.Time.IsZero() {
+			msg.Time = now
+		}
+		t.partitions[partition] = append(t.partitions[partition], msg)
+		stored = append(stored, msg)
+	}
+	if len(stored) > 0 {
+		close(b.written)
+		b.written = make(chan struct{})
+	}
+	return stored, nil
+}
+
+// Messages returns every message written to topic, partition by partition.
+func (b *MemoryBroker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topics[topic]
+	if t == nil {
+		return nil
+	}
+	var out []kafka.Message
+	for _, p := range t.partitions {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Topics returns the names of the topics written to, sorted.
+func (b *MemoryBroker) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		out = append(out, topic)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PlanGroupSeek resolves to on every partition of topics for group, as the function of
+// the same name does on a cluster.
+func (b *MemoryBroker) PlanGroupSeek(group string, topics []string, to Bound) []OffsetReset {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var resets []OffsetReset
+	for _, topic := range topics {
+		t := b.topics[topic]
+		if t == nil {
+			continue
+		}
+		for partition, msgs := range t.partitions {
+			at := kafka.PartitionOffsets{Offsets: make(map[int64]time.Time)}
+			if !to.Time.IsZero() {
+				for _, msg := range msgs {
+					if !msg.Time.Before(to.Time) {
+						at.Offsets[msg.Offset] = msg.Time
+						break
+					}
+				}
+			}
+			from, ok := b.committed[group][memoryPartition{topic: topic, partition: partition}]
+			if !ok {
+				from = -1
+			}
+			resets = append(resets, OffsetReset{Topic: topic, Partition: partition, From: from, To: to.resolve(0, int64(len(msgs)), at)})
+		}
+	}
+	return resets
+}
+
+// CommitGroupOffsets commits the To offset of every reset for group.
+func (b *MemoryBroker) CommitGroupOffsets(group string, resets []OffsetReset) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	committed := b.committed[group]
+	if committed == nil {
+		committed = make(map[memoryPartition]int64)
+		b.committed[group] = committed
+	}
+	for _, r := range resets {
+		committed[memoryPartition{topic: r.Topic, partition: r.Partition}] = r.To
+	}
+}
+
+// Reader returns a reader of topics in groupID, starting at the group's committed
+// offsets. Unlike a Kafka consumer group, the group's readers do not divide the
+// partitions between them: each reads every partition.
+func (b *MemoryBroker) Reader(groupID string, topics []string) *MemoryReader {
+	return &MemoryReader{broker: b, group: groupID, topics: topics, next: make(map[memoryPartition]int64)}
+}
+
+// MemoryReader reads topics of a MemoryBroker as a member of a consumer group. It offers
+// the FetchMessage, CommitMessages, and ReadMessage methods of *kafka.Reader.
+type MemoryReader struct {
+	broker *MemoryBroker
+	group  string
+	topics []string
+	// next is the next offset the reader returns of each partition it has read.
+	next map[memoryPartition]int64
+	// turn rotates which topic is checked first, so a busy topic does not starve others.
+	turn int
+}
+
+// FetchMessage returns the next message of any of the reader's topics, waiting for one to
+// be written until ctx ends. The message is not committed.
+func (r *MemoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		b := r.broker
+		b.mu.Lock()
+		msg, ok := r.nextLocked()
+		written := b.written
+		b.mu.Unlock()
+		if ok {
+			return msg, nil
+		}
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-written:
+		}
+	}
+}
+
+func (r *MemoryReader) nextLocked() (kafka.Message, bool) {
+	for i := range r.topics {
+		topic := r.topics[(r.turn+i)%len(r.topics)]
+		t := r.broker.topics[topic]
+		if t == nil {
+			continue
+		}
+		for partition, msgs := range t.partitions {
+			key := memoryPartition{topic: topic, partition: partition}
+			offset, ok := r.next[key]
+			if !ok {
+				offset = r.broker.committed[r.group][key]
+			}
+			if offset < int64(len(msgs)) {
+				r.next[key] = offset + 1
+				r.turn++
+				return msgs[offset], true
+			}
+		}
+	}
+	return kafka.Message{}, false
+}
+
+// CommitMessages commits the offsets following msgs for the reader's group.
+func (r *MemoryReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	committed := b.committed[r.group]
+	if committed == nil {
+		committed = make(map[memoryPartition]int64)
+		b.committed[r.group] = committed
+	}
+	for _, msg := range msgs {
+		key := memoryPartition{topic: msg.Topic, partition: msg.Partition}
+		committed[key] = max(committed[key], msg.Offset+1)
+	}
+	return nil
+}
+
+// ReadMessage fetches the next message and commits it.
+func (r *MemoryReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := r.FetchMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+	return msg, r.CommitMessages(ctx, msg)
+}
+
+// Close releases the reader. Its uncommitted messages are read again by the group's next
+// reader.
+func (r *MemoryReader) Close() error {
+	return nil
+}
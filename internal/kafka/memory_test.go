@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMemoryBrokerGroups(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+	if err := b.Topic("source").WriteMessages(ctx, kafka.Message{Value: []byte("a")}, kafka.Message{Partition: 2, Value: []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Messages("source"); len(got) != 2 || got[1].Partition != 2 || got[1].Offset != 0 || got[1].Topic != "source" {
+		t.Fatalf("unexpected messages: %+v", got)
+	}
+
+	r := b.Reader("group", []string{"source"})
+	first, err := r.FetchMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitMessages(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FetchMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new reader of the group resumes after the committed message only
+	again, err := b.Reader("group", []string{"source"}).FetchMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again.Value) == string(first.Value) {
+		t.Fatalf("committed message %q was read again", first.Value)
+	}
+
+	// a reader waits for the next write
+	done := make(chan kafka.Message)
+	go func() {
+		msg, _ := b.Reader("other", []string{"later"}).ReadMessage(ctx)
+		done <- msg
+	}()
+	if _, err := b.Produce("later", kafka.Message{Value: []byte("c")}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-done:
+		if string(msg.Value) != "c" {
+			t.Fatalf("read %q, want c", msg.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reader was not woken by the write")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := b.Reader("idle", []string{"empty"}).FetchMessage(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled fetch to fail, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// tokenRefreshMargin is how long before expiry a cached token is replaced.
+const tokenRefreshMargin = 30 * time.Second
+
+// TokenSource supplies OAuth access tokens for SASL OAUTHBEARER.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// OAuthBearer is a SASL OAUTHBEARER mechanism (RFC 7628) that authenticates every new
+// broker connection with a token from Source.
+type OAuthBearer struct {
+	Source TokenSource
+	// Extensions are sent as SASL extensions (e.g. logicalCluster for some platforms).
+	Extensions map[string]string
+}
+
+// Name implements sasl.Mechanism.
+func (o OAuthBearer) Name() string {
+	return "OAUTHBEARER"
+}
+
+// Start implements sasl.Mechanism by sending the GS2 header and bearer token.
+func (o OAuthBearer) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	token, err := o.Source.Token(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oauthbearer: %w", err)
+	}
+	var b strings.Builder
+	b.WriteString("n,,\x01auth=Bearer ")
+	b.WriteString(token)
+	b.WriteString("\x01")
+	for k, v := range o.Extensions {
+		b.WriteString(k + "=" + v + "\x01")
+	}
+	b.WriteString("\x01")
+	return oauthSession{}, []byte(b.String()), nil
+}
+
+type oauthSession struct{}
+
+// Next completes on an empty server response; anything else is the broker's JSON error.
+func (oauthSession) Next(_ context.Context, challenge []byte) (bool, []byte, error) {
+	if len(challenge) == 0 {
+		return true, nil, nil
+	}
+	return false, nil, fmt.Errorf("oauthbearer: broker rejected token: %s", challenge)
+}
+
+// ClientCredentials fetches tokens from an OIDC token endpoint with the client credentials
+// grant and caches each one until shortly before it expires.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Secret, when set, supplies the client secret for every token request instead of
+	// ClientSecret, so a rotated secret is picked up.
+	Secret func(ctx context.Context) (string, error)
+	Scopes []string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	now     func() time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Token returns the cached token or fetches a new one when it is about to expire.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	if c.token != "" && now.Add(tokenRefreshMargin).Before(c.expires) {
+		return c.token, nil
+	}
+	token, lifetime, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.expires = now.Add(lifetime)
+	return token, nil
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	secret := c.ClientSecret
+	if c.Secret != nil {
+		if secret, err = c.Secret(ctx); err != nil {
+			return "", 0, fmt.Errorf("client secret: %w", err)
+		}
+	}
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(secret))
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", 0, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.Description)
+	}
+	if body.AccessToken == "" {
+		return "", 0, errors.New("token response missing access_token")
+	}
+	lifetime := time.Duration(body.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		// no expiry advertised: refresh on a conservative schedule
+		lifetime = 5 * time.Minute
+	}
+	return body.AccessToken, lifetime, nil
+}
+
+func (c *ClientCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
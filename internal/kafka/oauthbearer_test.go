@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientCredentialsCachesAndRefreshes(t *testing.T) {
+	issued := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "bridge" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "kafka offline" {
+			t.Errorf("unexpected token request form: %v", r.Form)
+		}
+		issued++
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-" + string(rune('0'+issued)), "expires_in": 300})
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Unix(1_700_000_000, 0)
+	src := &ClientCredentials{TokenURL: server.URL, ClientID: "bridge", ClientSecret: "s3cret", Scopes: []string{"kafka", "offline"}}
+	src.now = func() time.Time { return now }
+
+	first, err := src.Token(context.Background())
+	if err != nil || first != "token-1" {
+		t.Fatalf("Token = %q, %v", first, err)
+	}
+	now = now.Add(4 * time.Minute)
+	if got, _ := src.Token(context.Background()); got != "token-1" {
+		t.Fatalf("expected cached token, got %q", got)
+	}
+	now = now.Add(45 * time.Second)
+	if got, _ := src.Token(context.Background()); got != "token-2" {
+		t.Fatalf("expected refreshed token near expiry, got %q", got)
+	}
+
+	bad := &ClientCredentials{TokenURL: server.URL, ClientID: "bridge", ClientSecret: "wrong"}
+	if _, err := bad.Token(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected invalid_client error, got %v", err)
+	}
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) { return string(s), nil }
+
+func TestOAuthBearerHandshake(t *testing.T) {
+	mech := OAuthBearer{Source: staticToken("abc"), Extensions: map[string]string{"logicalCluster": "lkc-1"}}
+	sess, ir, err := mech.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if want := "n,,\x01auth=Bearer abc\x01logicalCluster=lkc-1\x01\x01"; string(ir) != want {
+		t.Fatalf("initial response = %q, want %q", ir, want)
+	}
+	if done, _, err := sess.Next(context.Background(), nil); !done || err != nil {
+		t.Fatalf("expected success on empty challenge, got done=%v err=%v", done, err)
+	}
+	if _, _, err := sess.Next(context.Background(), []byte(`{"status":"invalid_token"}`)); err == nil {
+		t.Fatal("expected error on rejection challenge")
+	}
+}
@@ -0,0 +1,213 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// CopyGroupOffsets commits the offsets fromGroup has committed on topics to toGroup and
+// returns the number of partitions copied. toGroup must have no active members, so run
+// it while the consumers of toGroup are stopped.
+func CopyGroupOffsets(ctx context.Context, brokers []string, dialer *kafka.Dialer, fromGroup, toGroup string, topics []string) (int, error) {
+	client := newClient(brokers, dialer)
+	fetched, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: fromGroup})
+	if err != nil {
+		return 0, fmt.Errorf("fetch offsets for %s: %w", fromGroup, err)
+	}
+	if fetched.Error != nil {
+		return 0, fmt.Errorf("fetch offsets for %s: %w", fromGroup, fetched.Error)
+	}
+	commits, count := offsetCommits(fetched.Topics, topics)
+	if count == 0 {
+		return 0, nil
+	}
+	resp, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      toGroup,
+		GenerationID: -1,
+		Topics:       commits,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("commit offsets for %s: %w", toGroup, err)
+	}
+	for topic, partitions := range resp.Topics {
+		for _, p := range partitions {
+			if p.Error != nil {
+				return 0, fmt.Errorf("commit offset %s/%d for %s: %w", topic, p.Partition, toGroup, p.Error)
+			}
+		}
+	}
+	return count, nil
+}
+
+// SeedGroupOffsetsAt commits, for a group with no committed offsets on topic, the first
+// offset of every partition written at or after at. It reports whether offsets were
+// committed; a group that has already committed is left untouched.
+func SeedGroupOffsetsAt(ctx context.Context, brokers []string, dialer *kafka.Dialer, group, topic string, at time.Time) (bool, error) {
+	client := newClient(brokers, dialer)
+	fetched, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: group})
+	if err != nil {
+		return false, fmt.Errorf("fetch offsets for %s: %w", group, err)
+	}
+	if fetched.Error != nil {
+		return false, fmt.Errorf("fetch offsets for %s: %w", group, fetched.Error)
+	}
+	if _, committed := offsetCommits(fetched.Topics, []string{topic}); committed > 0 {
+		return false, nil
+	}
+
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return false, fmt.Errorf("metadata for %s: %w", topic, err)
+	}
+	var requests []kafka.OffsetRequest
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return false, fmt.Errorf("metadata for %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			requests = append(requests, kafka.TimeOffsetOf(p.ID, at))
+		}
+	}
+	if len(requests) == 0 {
+		return false, fmt.Errorf("topic %s has no partitions", topic)
+	}
+	listed, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return false, fmt.Errorf("list offsets for %s: %w", topic, err)
+	}
+	commits := make([]kafka.OffsetCommit, 0, len(requests))
+	for _, p := range listed.Topics[topic] {
+		if p.Error != nil {
+			return false, fmt.Errorf("list offsets for %s/%d: %w", topic, p.Partition, p.Error)
+		}
+		commits = append(commits, kafka.OffsetCommit{Partition: p.Partition, Offset: offsetAtTime(p)})
+	}
+	resp, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return false, fmt.Errorf("commit offsets for %s: %w", group, err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return false, fmt.Errorf("commit offset %s/%d for %s: %w", topic, p.Partition, group, p.Error)
+		}
+	}
+	return true, nil
+}
+
+// OffsetReset moves the committed offset of one partition of a group.
+type OffsetReset struct {
+	Topic     string
+	Partition int
+	// From is the offset the group has committed, or -1 when it has committed none.
+	From int64
+	To   int64
+}
+
+// PlanGroupSeek resolves to on every partition of topics, clamped to the offsets still
+// retained, and pairs it with the offset group has committed there. Nothing is committed.
+func PlanGroupSeek(ctx context.Context, brokers []string, dialer *kafka.Dialer, group string, topics []string, to Bound) ([]OffsetReset, error) {
+	client := newClient(brokers, dialer)
+	fetched, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: group})
+	if err != nil {
+		return nil, fmt.Errorf("fetch offsets for %s: %w", group, err)
+	}
+	if fetched.Error != nil {
+		return nil, fmt.Errorf("fetch offsets for %s: %w", group, fetched.Error)
+	}
+	var resets []OffsetReset
+	for _, topic := range topics {
+		committed := make(map[int]int64)
+		for _, p := range fetched.Topics[topic] {
+			if p.Error == nil && p.CommittedOffset >= 0 {
+				committed[p.Partition] = p.CommittedOffset
+			}
+		}
+		ranges, err := ResolveRange(ctx, brokers, dialer, topic, -1, to, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range ranges {
+			from, ok := committed[r.Partition]
+			if !ok {
+				from = -1
+			}
+			resets = append(resets, OffsetReset{Topic: topic, Partition: r.Partition, From: from, To: r.Start})
+		}
+	}
+	return resets, nil
+}
+
+// CommitGroupOffsets commits the To offset of every reset for group. group must have no
+// active members, so stop its consumers first.
+func CommitGroupOffsets(ctx context.Context, brokers []string, dialer *kafka.Dialer, group string, resets []OffsetReset) error {
+	commits := make(map[string][]kafka.OffsetCommit)
+	for _, r := range resets {
+		commits[r.Topic] = append(commits[r.Topic], kafka.OffsetCommit{Partition: r.Partition, Offset: r.To})
+	}
+	resp, err := newClient(brokers, dialer).OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       commits,
+	})
+	if err != nil {
+		return fmt.Errorf("commit offsets for %s: %w", group, err)
+	}
+	for topic, partitions := range resp.Topics {
+		for _, p := range partitions {
+			if p.Error != nil {
+				return fmt.Errorf("commit offset %s/%d for %s: %w", topic, p.Partition, group, p.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// offsetAtTime picks the offset a timestamp lookup resolved to; when no message is that
+// recent the partition starts at its end.
+func offsetAtTime(p kafka.PartitionOffsets) int64 {
+	best := int64(-1)
+	for offset := range p.Offsets {
+		if offset >= 0 && (best < 0 || offset < best) {
+			best = offset
+		}
+	}
+	if best < 0 {
+		return p.LastOffset
+	}
+	return best
+}
+
+func newClient(brokers []string, dialer *kafka.Dialer) *kafka.Client {
+	return &kafka.Client{
+		Addr: kafka.TCP(brokers...),
+		Transport: &kafka.Transport{
+			TLS:         dialer.TLS,
+			SASL:        dialer.SASLMechanism,
+			ClientID:    dialer.ClientID,
+			DialTimeout: dialer.Timeout,
+		},
+	}
+}
+
+// offsetCommits selects the committed offsets of the requested topics.
+func offsetCommits(fetched map[string][]kafka.OffsetFetchPartition, topics []string) (map[string][]kafka.OffsetCommit, int) {
+	commits := make(map[string][]kafka.OffsetCommit)
+	count := 0
+	for _, topic := range topics {
+		for _, p := range fetched[topic] {
+			if p.Error != nil || p.CommittedOffset < 0 {
+				continue
+			}
+			commits[topic] = append(commits[topic], kafka.OffsetCommit{Partition: p.Partition, Offset: p.CommittedOffset, Metadata: p.Metadata})
+			count++
+		}
+	}
+	return commits, count
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOffsetCommits(t *testing.T) {
+	fetched := map[string][]kafka.OffsetFetchPartition{
+		"source": {
+			{Partition: 0, CommittedOffset: 42},
+			{Partition: 1, CommittedOffset: -1},
+			{Partition: 2, CommittedOffset: 7, Error: errors.New("boom")},
+		},
+		"other": {{Partition: 0, CommittedOffset: 9}},
+	}
+	commits, count := offsetCommits(fetched, []string{"source", "missing"})
+	if count != 1 || len(commits) != 1 {
+		t.Fatalf("expected 1 commit, got %d: %v", count, commits)
+	}
+	if got := commits["source"]; len(got) != 1 || got[0].Partition != 0 || got[0].Offset != 42 {
+		t.Fatalf("unexpected commits: %v", got)
+	}
+}
+
+func TestOffsetAtTime(t *testing.T) {
+	cases := []struct {
+		name string
+		p    kafka.PartitionOffsets
+		want int64
+	}{
+		{name: "resolved", p: kafka.PartitionOffsets{LastOffset: 100, Offsets: map[int64]time.Time{42: {}}}, want: 42},
+		{name: "after last message", p: kafka.PartitionOffsets{LastOffset: 100, Offsets: map[int64]time.Time{-1: {}}}, want: 100},
+		{name: "empty", p: kafka.PartitionOffsets{LastOffset: 7}, want: 7},
+	}
+	for _, tc := range cases {
+		if got := offsetAtTime(tc.p); got != tc.want {
+			t.Fatalf("%s: offsetAtTime = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Bound is one end of a replay range: a timestamp, an absolute offset, or, with Offset set
+// to kafka.FirstOffset or kafka.LastOffset, the start or end of every partition.
+type Bound struct {
+	Time   time.Time
+	Offset int64
+}
+
+// ParseBound reads "earliest", "latest", an RFC 3339 timestamp, or a non-negative offset.
+// An empty string yields def.
+func ParseBound(s string, def Bound) (Bound, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return def, nil
+	case "earliest":
+		return Bound{Offset: kafka.FirstOffset}, nil
+	case "latest":
+		return Bound{Offset: kafka.LastOffset}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return Bound{Time: t}, nil
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return Bound{}, fmt.Errorf("%q is not earliest, latest, an RFC 3339 timestamp, or an offset", s)
+	}
+	return Bound{Offset: offset}, nil
+}
+
+// String renders the bound as ParseBound accepts it.
+func (b Bound) String() string {
+	switch {
+	case !b.Time.IsZero():
+		return b.Time.Format(time.RFC3339)
+	case b.Offset == kafka.FirstOffset:
+		return "earliest"
+	case b.Offset == kafka.LastOffset:
+		return "latest"
+	}
+	return strconv.FormatInt(b.Offset, 10)
+}
+
+// resolve returns the offset b selects within [first, last]; at holds the offsets a
+// timestamp lookup found for the partition.
+func (b Bound) resolve(first, last int64, at kafka.PartitionOffsets) int64 {
+	var offset int64
+	switch {
+	case !b.Time.IsZero():
+		offset = -1
+		for o := range at.Offsets {
+			if o >= 0 && (offset < 0 || o < offset) {
+				offset = o
+			}
+		}
+		if offset < 0 {
+			// nothing written at or after the timestamp
+			offset = last
+		}
+	case b.Offset == kafka.FirstOffset:
+		offset = first
+	case b.Offset == kafka.LastOffset:
+		offset = last
+	default:
+		offset = b.Offset
+	}
+	if offset < first {
+		return first
+	}
+	if offset > last {
+		return last
+	}
+	return offset
+}
+
+// PartitionRange is the half-open offset range [Start, End) to read from one partition.
+type PartitionRange struct {
+	Partition int
+	Start     int64
+	End       int64
+}
+
+// ResolveRange turns from and to into the offset range of every partition of topic (or only
+// of partition when it is non-negative). Ranges are clamped to the offsets still retained.
+func ResolveRange(ctx context.Context, brokers []string, dialer *kafka.Dialer, topic string, partition int, from, to Bound) ([]PartitionRange, error) {
+	client := newClient(brokers, dialer)
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("metadata for %s: %w", topic, err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("metadata for %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			if partition < 0 || p.ID == partition {
+				partitions = append(partitions, p.ID)
+			}
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s has no partition matching %d", topic, partition)
+	}
+	sort.Ints(partitions)
+
+	ends, err := listOffsets(ctx, client, topic, partitions, func(p int) []kafka.OffsetRequest {
+		return []kafka.OffsetRequest{kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	lookupTime := func(b Bound) (map[int]kafka.PartitionOffsets, error) {
+		if b.Time.IsZero() {
+			return nil, nil
+		}
+		return listOffsets(ctx, client, topic, partitions, func(p int) []kafka.OffsetRequest {
+			return []kafka.OffsetRequest{kafka.TimeOffsetOf(p, b.Time)}
+		})
+	}
+	fromAt, err := lookupTime(from)
+	if err != nil {
+		return nil, err
+	}
+	toAt, err := lookupTime(to)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]PartitionRange, 0, len(partitions))
+	for _, p := range partitions {
+		first, last := ends[p].FirstOffset, ends[p].LastOffset
+		out = append(out, PartitionRange{
+			Partition: p,
+			Start:     from.resolve(first, last, fromAt[p]),
+			End:       to.resolve(first, last, toAt[p]),
+		})
+	}
+	return out, nil
+}
+
+func listOffsets(ctx context.Context, client *kafka.Client, topic string, partitions []int, requests func(int) []kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	var reqs []kafka.OffsetRequest
+	for _, p := range partitions {
+		reqs = append(reqs, requests(p)...)
+	}
+	listed, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: reqs}})
+	if err != nil {
+		return nil, fmt.Errorf("list offsets for %s: %w", topic, err)
+	}
+	out := make(map[int]kafka.PartitionOffsets, len(partitions))
+	for _, p := range listed.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("list offsets for %s/%d: %w", topic, p.Partition, p.Error)
+		}
+		out[p.Partition] = p
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestParseBound(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in      string
+		want    Bound
+		wantErr bool
+	}{
+		{in: "", want: Bound{Offset: kafka.LastOffset}},
+		{in: "earliest", want: Bound{Offset: kafka.FirstOffset}},
+		{in: "LATEST", want: Bound{Offset: kafka.LastOffset}},
+		{in: "2024-05-01T00:00:00Z", want: Bound{Time: ts}},
+		{in: "42", want: Bound{Offset: 42}},
+		{in: "-3", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ParseBound(tc.in, Bound{Offset: kafka.LastOffset})
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("ParseBound(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if !tc.wantErr && (!got.Time.Equal(tc.want.Time) || got.Offset != tc.want.Offset) {
+			t.Fatalf("ParseBound(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestBoundResolve(t *testing.T) {
+	found := kafka.PartitionOffsets{Offsets: map[int64]time.Time{120: {}}}
+	notFound := kafka.PartitionOffsets{Offsets: map[int64]time.Time{-1: {}}}
+	ts := Bound{Time: time.Now()}
+	cases := []struct {
+		name  string
+		bound Bound
+		at    kafka.PartitionOffsets
+		want  int64
+	}{
+		{name: "earliest", bound: Bound{Offset: kafka.FirstOffset}, want: 100},
+		{name: "latest", bound: Bound{Offset: kafka.LastOffset}, want: 200},
+		{name: "offset", bound: Bound{Offset: 150}, want: 150},
+		{name: "offset before retention", bound: Bound{Offset: 5}, want: 100},
+		{name: "offset past end", bound: Bound{Offset: 500}, want: 200},
+		{name: "timestamp", bound: ts, at: found, want: 120},
+		{name: "timestamp after last message", bound: ts, at: notFound, want: 200},
+	}
+	for _, tc := range cases {
+		if got := tc.bound.resolve(100, 200, tc.at); got != tc.want {
+			t.Fatalf("%s: resolve = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
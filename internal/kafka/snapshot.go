@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"context"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/store"
+)
+
+// PublishSnapshot queues every fingerprint cached in s, and a tombstone for every one the
+// topic holds that s no longer caches, so the compacted topic converges on the cache once
+// Run or Flush publishes them. The first call reads the topic to learn what it holds;
+// later calls tombstone what the previous snapshot published. Probabilistic routes keep
+// no values and publish none. It returns how many fingerprints it queued.
+func (t *StateTopic) PublishSnapshot(ctx context.Context, s *store.MatchStore) (int, error) {
+	if t.published == nil {
+		state, _, err := t.read(ctx)
+		if err != nil {
+			return 0, err
+		}
+		held := make(map[string]struct{})
+		for route, fps := range state {
+			for fp := range fps {
+				held[stateKey(route, fp)] = struct{}{}
+			}
+		}
+		t.published = held
+	}
+	return t.queueSnapshot(s), nil
+}
+
+// queueSnapshot queues the records of PublishSnapshot. It holds the queue while it reads
+// the cache, so a change observed meanwhile is queued behind the snapshot and the topic
+// ends on the newer value.
+func (t *StateTopic) queueSnapshot(s *store.MatchStore) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	published := make(map[string]struct{}, len(t.published))
+	for route := range s.Snapshot() {
+		for fp, e := range s.Entries(route) {
+			msg, err := stateMessage(store.Mutation{Op: store.OpAdd, Route: route, Fingerprint: fp, Canonical: e.Canonical, Meta: e.Meta})
+			if err != nil {
+				log.Printf("state topic: encode %s failed: %v", msg.Key, err)
+				continue
+			}
+			t.pending = append(t.pending, msg)
+			published[string(msg.Key)] = struct{}{}
+		}
+	}
+	for key := range t.published {
+		if _, ok := published[key]; !ok {
+			t.pending = append(t.pending, kafka.Message{Key: []byte(key)})
+		}
+	}
+	t.published = published
+	t.wake()
+	return len(published)
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"testing"
+
+	"kafka-bridge/pkg/store"
+)
+
+func TestQueueSnapshotTombstonesWhatIsGone(t *testing.T) {
+	s := store.NewMatchStore()
+	s.Add("route-a", "one")
+	s.Add("route-a", "two")
+	s.Add("route-b", "one")
+	topic := &StateTopic{signal: make(chan struct{}, 1), published: map[string]struct{}{
+		stateKey("route-a", "one"):  {},
+		stateKey("route-c", "gone"): {},
+	}}
+
+	if n := topic.queueSnapshot(s); n != 3 {
+		t.Fatalf("queueSnapshot queued %d fingerprint(s), want 3", n)
+	}
+	records := make(map[string]bool)
+	for _, msg := range topic.pending {
+		records[string(msg.Key)] = msg.Value != nil
+	}
+	want := map[string]bool{"route-a|one": true, "route-a|two": true, "route-b|one": true, "route-c|gone": false}
+	if len(records) != len(want) {
+		t.Fatalf("queued %v, want %v", records, want)
+	}
+	for key, live := range want {
+		if got, ok := records[key]; !ok || got != live {
+			t.Fatalf("queued %v, want %v", records, want)
+		}
+	}
+	m, ok := decodeStateRecord(topic.pending[0].Key, topic.pending[0].Value)
+	if !ok || m.Op != store.OpAdd {
+		t.Fatalf("expected a snapshot record to decode as an add, got %+v (%v)", m, ok)
+	}
+
+	// the next snapshot tombstones what the previous one published and the cache dropped
+	topic.pending = nil
+	s.Remove("route-a", "two")
+	if n := topic.queueSnapshot(s); n != 2 {
+		t.Fatalf("queueSnapshot queued %d fingerprint(s), want 2", n)
+	}
+	last := topic.pending[len(topic.pending)-1]
+	if string(last.Key) != "route-a|two" || last.Value != nil || len(topic.pending) != 3 {
+		t.Fatalf("expected route-a|two to be tombstoned after the 2 live records, got %d record(s) ending in %s", len(topic.pending), last.Key)
+	}
+}
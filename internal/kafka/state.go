@@ -0,0 +1,347 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"kafka-bridge/pkg/delivery"
+	"kafka-bridge/pkg/store"
+)
+
+const stateKeySeparator = "|"
+
+// stateRecord is the value written for every cached fingerprint; removals are tombstones.
+type stateRecord struct {
+	Route       string         `json:"route"`
+	Fingerprint string         `json:"fingerprint"`
+	Canonical   string         `json:"canonical,omitempty"`
+	AddedAt     time.Time      `json:"addedAt"`
+	Origin      store.Metadata `json:"origin"`
+}
+
+// StateTopic persists store mutations to a compacted topic and rebuilds the store from it.
+type StateTopic struct {
+	brokers []string
+	dialer  *kafka.Dialer
+	topic   string
+	writer  *kafka.Writer
+
+	mu      sync.Mutex
+	pending []kafka.Message
+	signal  chan struct{}
+
+	// restored holds, per partition, the offset Restore read up to; Follow resumes there.
+	restored map[int]int64
+	// published holds the keys PublishSnapshot last left live on the topic.
+	published map[string]struct{}
+}
+
+// NewStateTopic builds a state backend bound to the given compacted topic.
+func NewStateTopic(brokers []string, dialer *kafka.Dialer, topic string) *StateTopic {
+	return &StateTopic{
+		brokers: brokers,
+		dialer:  dialer,
+		topic:   topic,
+		signal:  make(chan struct{}, 1),
+		writer: kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      brokers,
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: int(kafka.RequireAll),
+			Async:        false,
+			Dialer:       dialer,
+		}),
+	}
+}
+
+// Restore ensures the topic exists, reads it from the beginning up to the current
+// high watermark, and loads the surviving fingerprints into the store.
+func (t *StateTopic) Restore(ctx context.Context, s *store.MatchStore) (int, error) {
+	state, restored, err := t.read(ctx)
+	if err != nil {
+		return 0, err
+	}
+	t.restored = restored
+
+	total := 0
+	for _, fps := range state {
+		total += len(fps)
+	}
+	s.LoadEntries(state)
+	return total, nil
+}
+
+// read ensures the topic exists and folds it, from the beginning up to the current high
+// watermark, into the fingerprints it holds per route. It also returns, per partition,
+// the offset it read up to.
+func (t *StateTopic) read(ctx context.Context) (map[string]map[string]store.Entry, map[int]int64, error) {
+	err := delivery.EnsureTopic(t.brokers, t.dialer, kafka.TopicConfig{
+		Topic:             t.topic,
+		NumPartitions:     -1,
+		ReplicationFactor: -1,
+		ConfigEntries: []kafka.ConfigEntry{
+			{ConfigName: "cleanup.policy", ConfigValue: "compact"},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	partitions, err := t.dialer.LookupPartitions(ctx, "tcp", t.brokers[0], t.topic)
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup partitions: %w", err)
+	}
+
+	state := make(map[string]map[string]store.Entry)
+	restored := make(map[int]int64, len(partitions))
+	for _, p := range partitions {
+		next, err := t.restorePartition(ctx, p.ID, state)
+		if err != nil {
+			return nil, nil, fmt.Errorf("restore partition %d: %w", p.ID, err)
+		}
+		restored[p.ID] = next
+	}
+	return state, restored, nil
+}
+
+// restorePartition folds partition into state and returns the offset it read up to.
+func (t *StateTopic) restorePartition(ctx context.Context, partition int, state map[string]map[string]store.Entry) (int64, error) {
+	conn, err := t.dialer.DialLeader(ctx, "tcp", t.brokers[0], t.topic, partition)
+	if err != nil {
+		return 0, fmt.Errorf("dial leader: %w", err)
+	}
+	first, last, err := conn.ReadOffsets()
+	conn.Close()
+	if err != nil {
+		return 0, fmt.Errorf("read offsets: %w", err)
+	}
+	if first >= last {
+		return last, nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   t.brokers,
+		Topic:     t.topic,
+		Partition: partition,
+		Dialer:    t.dialer,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(first); err != nil {
+		return 0, err
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		applyStateRecord(state, msg.Key, msg.Value)
+		if msg.Offset >= last-1 {
+			return last, nil
+		}
+	}
+}
+
+// Follow tails the topic from where Restore stopped and applies every record, whichever
+// replica published it, to s until ctx is done. Replicas that share the topic and all
+// follow it converge on the same cache: each applies the same records in topic order,
+// so concurrent changes to one fingerprint settle on whichever was published last.
+// Records this replica published are already applied and are no-ops.
+func (t *StateTopic) Follow(ctx context.Context, s *store.MatchStore) error {
+	if t.restored == nil {
+		return errors.New("follow requires a completed Restore")
+	}
+	errs := make(chan error, len(t.restored))
+	for partition, offset := range t.restored {
+		go func() {
+			errs <- t.followPartition(ctx, partition, offset, s)
+		}()
+	}
+	var first error
+	for range t.restored {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (t *StateTopic) followPartition(ctx context.Context, partition int, offset int64, s *store.MatchStore) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   t.brokers,
+		Topic:     t.topic,
+		Partition: partition,
+		Dialer:    t.dialer,
+		MaxWait:   time.Second,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(offset); err != nil {
+		return fmt.Errorf("partition %d: seek to %d: %w", partition, offset, err)
+	}
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("partition %d: %w", partition, err)
+		}
+		if m, ok := decodeStateRecord(msg.Key, msg.Value); ok {
+			s.ApplyReplicated(m)
+		}
+	}
+}
+
+// Record queues a store mutation for publication. It never blocks and is safe to use as a store observer.
+// Mutations applied by Follow are already on the topic and are not published again.
+func (t *StateTopic) Record(m store.Mutation) {
+	if m.Replicated {
+		return
+	}
+	msg, err := stateMessage(m)
+	if err != nil {
+		log.Printf("state topic: encode %s failed: %v", msg.Key, err)
+		return
+	}
+
+	t.mu.Lock()
+	t.pending = append(t.pending, msg)
+	t.mu.Unlock()
+	t.wake()
+}
+
+// stateMessage encodes m as a topic record: a stateRecord for an add, a tombstone for a
+// removal.
+func stateMessage(m store.Mutation) (kafka.Message, error) {
+	msg := kafka.Message{Key: []byte(stateKey(m.Route, m.Fingerprint))}
+	if m.Op != store.OpAdd {
+		return msg, nil
+	}
+	addedAt := m.Meta.AddedAt
+	if addedAt.IsZero() {
+		addedAt = time.Now().UTC()
+	}
+	value, err := json.Marshal(stateRecord{
+		Route:       m.Route,
+		Fingerprint: m.Fingerprint,
+		Canonical:   m.Canonical,
+		AddedAt:     addedAt,
+		Origin:      m.Meta,
+	})
+	msg.Value = value
+	return msg, err
+}
+
+func (t *StateTopic) wake() {
+	select {
+	case t.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes queued mutations until the context is cancelled, then flushes what remains.
+func (t *StateTopic) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := t.flush(flushCtx); err != nil {
+				log.Printf("state topic: final flush failed: %v", err)
+			}
+			return ctx.Err()
+		case <-t.signal:
+			if err := t.flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("state topic: publish failed, retrying: %v", err)
+				time.AfterFunc(time.Second, t.wake)
+			}
+		}
+	}
+}
+
+// Flush publishes every queued mutation, for one-shot tools that do not call Run.
+func (t *StateTopic) Flush(ctx context.Context) error {
+	return t.flush(ctx)
+}
+
+func (t *StateTopic) flush(ctx context.Context) error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := t.writer.WriteMessages(ctx, batch...); err != nil {
+		// keep ordering: failed mutations go back in front of anything queued since
+		t.mu.Lock()
+		t.pending = append(batch, t.pending...)
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close releases the underlying writer.
+func (t *StateTopic) Close() error {
+	return t.writer.Close()
+}
+
+func stateKey(route, fingerprint string) string {
+	return route + stateKeySeparator + fingerprint
+}
+
+func parseStateKey(key []byte) (string, string, bool) {
+	route, fingerprint, ok := strings.Cut(string(key), stateKeySeparator)
+	if !ok || route == "" {
+		return "", "", false
+	}
+	return route, fingerprint, true
+}
+
+// applyStateRecord folds one topic record into state, keyed by route then fingerprint.
+func applyStateRecord(state map[string]map[string]store.Entry, key, value []byte) {
+	m, ok := decodeStateRecord(key, value)
+	if !ok {
+		return
+	}
+	if m.Op == store.OpRemove {
+		delete(state[m.Route], m.Fingerprint)
+		return
+	}
+	fps, ok := state[m.Route]
+	if !ok {
+		fps = make(map[string]store.Entry)
+		state[m.Route] = fps
+	}
+	fps[m.Fingerprint] = store.Entry{Canonical: m.Canonical, Meta: m.Meta}
+}
+
+// decodeStateRecord turns one topic record into the mutation it describes; tombstones are
+// removals. Records with a malformed key are ignored.
+func decodeStateRecord(key, value []byte) (store.Mutation, bool) {
+	route, fingerprint, ok := parseStateKey(key)
+	if !ok {
+		return store.Mutation{}, false
+	}
+	if len(value) == 0 {
+		return store.Mutation{Op: store.OpRemove, Route: route, Fingerprint: fingerprint}, true
+	}
+	var rec stateRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		log.Printf("state topic: undecodable record for %s treated as canonical: %v", key, err)
+	}
+	meta := rec.Origin
+	if meta.AddedAt.IsZero() {
+		meta.AddedAt = rec.AddedAt
+	}
+	return store.Mutation{Op: store.OpAdd, Route: route, Fingerprint: fingerprint, Canonical: rec.Canonical, Meta: meta}, true
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"testing"
+
+	"kafka-bridge/pkg/store"
+)
+
+func TestParseStateKey(t *testing.T) {
+	cases := []struct {
+		key         string
+		route       string
+		fingerprint string
+		ok          bool
+	}{
+		{key: "route-a|value1", route: "route-a", fingerprint: "value1", ok: true},
+		{key: "route-a|a|b", route: "route-a", fingerprint: "a|b", ok: true},
+		{key: "route-a|", route: "route-a", fingerprint: "", ok: true},
+		{key: "no-separator", ok: false},
+		{key: "|value1", ok: false},
+	}
+	for _, tc := range cases {
+		route, fp, ok := parseStateKey([]byte(tc.key))
+		if ok != tc.ok || route != tc.route || fp != tc.fingerprint {
+			t.Fatalf("parseStateKey(%q) = (%q, %q, %v), want (%q, %q, %v)", tc.key, route, fp, ok, tc.route, tc.fingerprint, tc.ok)
+		}
+	}
+}
+
+func TestApplyStateRecordTombstones(t *testing.T) {
+	state := make(map[string]map[string]store.Entry)
+	applyStateRecord(state, []byte(stateKey("route-a", "one")), []byte(`{}`))
+	applyStateRecord(state, []byte(stateKey("route-a", "two")), []byte(`{}`))
+	applyStateRecord(state, []byte(stateKey("route-b", "one")), []byte(`{}`))
+	applyStateRecord(state, []byte(stateKey("route-a", "one")), nil)
+	applyStateRecord(state, []byte(stateKey("route-c", "missing")), nil)
+
+	if _, ok := state["route-a"]["one"]; ok {
+		t.Fatalf("expected tombstone to remove route-a/one")
+	}
+	if _, ok := state["route-a"]["two"]; !ok {
+		t.Fatalf("expected route-a/two to survive")
+	}
+	if _, ok := state["route-b"]["one"]; !ok {
+		t.Fatalf("expected route-b/one to survive")
+	}
+}
+
+func TestApplyStateRecordCanonical(t *testing.T) {
+	state := make(map[string]map[string]store.Entry)
+	applyStateRecord(state, []byte(stateKey("route-a", "2023/x")), []byte(`{"canonical":"23/x"}`))
+	applyStateRecord(state, []byte(stateKey("route-a", "23/x")), []byte(`{"canonical":"23/x"}`))
+	applyStateRecord(state, []byte(stateKey("route-a", "legacy")), []byte(`{"route":"route-a","addedAt":"2024-01-02T03:04:05Z"}`))
+	applyStateRecord(state, []byte(stateKey("route-a", "fed")), []byte(`{"origin":{"source":"kafka","feed":"feed-a","topic":"ref","partition":2,"offset":7}}`))
+
+	if got := state["route-a"]["2023/x"].Canonical; got != "23/x" {
+		t.Fatalf("expected variant to keep canonical 23/x, got %q", got)
+	}
+	legacy := state["route-a"]["legacy"]
+	if legacy.Canonical != "" || legacy.Meta.AddedAt.IsZero() {
+		t.Fatalf("expected legacy record without canonical but with addedAt, got %+v", legacy)
+	}
+	if got := state["route-a"]["fed"].Meta.String(); got != "kafka:feed-a@ref/2:7" {
+		t.Fatalf("unexpected origin %q", got)
+	}
+}
+
+func TestDecodeStateRecord(t *testing.T) {
+	m, ok := decodeStateRecord([]byte(stateKey("route-a", "2023/x")), []byte(`{"canonical":"23/x","origin":{"source":"http"}}`))
+	if !ok || m.Op != store.OpAdd || m.Route != "route-a" || m.Fingerprint != "2023/x" || m.Canonical != "23/x" || m.Meta.Source != store.SourceHTTP {
+		t.Fatalf("unexpected add %+v (%v)", m, ok)
+	}
+	m, ok = decodeStateRecord([]byte(stateKey("route-a", "one")), nil)
+	if !ok || m.Op != store.OpRemove || m.Fingerprint != "one" {
+		t.Fatalf("expected tombstone to decode as a removal, got %+v (%v)", m, ok)
+	}
+	if _, ok := decodeStateRecord([]byte("no-separator"), []byte(`{}`)); ok {
+		t.Fatalf("expected malformed key to be ignored")
+	}
+}
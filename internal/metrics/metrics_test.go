@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	values := Family{Name: "bridge_cache_values", Help: "Cached values per route.", Type: TypeGauge}
+	values.Add(Labels{"route": `a"b`}, 3)
+	values.Add(Labels{"route": "c", "policy": "lru"}, 0.5)
+	total := Family{Name: "bridge_total", Help: "Line one\nline two.", Type: TypeCounter}
+	total.Add(nil, 7)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []Family{values, total}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `# HELP bridge_cache_values Cached values per route.
+# TYPE bridge_cache_values gauge
+bridge_cache_values{route="a\"b"} 3
+bridge_cache_values{policy="lru",route="c"} 0.5
+# HELP bridge_total Line one\nline two.
+# TYPE bridge_total counter
+bridge_total 7
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v)
+	}
+	latency := Family{Name: "bridge_latency_seconds", Help: "Latency.", Type: TypeHistogram}
+	latency.AddHistogram(Labels{"route": "a"}, h)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []Family{latency}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `# HELP bridge_latency_seconds Latency.
+# TYPE bridge_latency_seconds histogram
+bridge_latency_seconds_bucket{le="0.1",route="a"} 2
+bridge_latency_seconds_bucket{le="1",route="a"} 3
+bridge_latency_seconds_bucket{le="+Inf",route="a"} 4
+bridge_latency_seconds_sum{route="a"} 3.65
+bridge_latency_seconds_count{route="a"} 4
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,355 @@
+// Command openapigen generates Go types from the component schemas of an OpenAPI 3
+// document, so the admin API's handlers encode and decode what cmd/filter/openapi.json
+// documents. It follows oapi-codegen's extensions: a schema or property with x-go-type
+// (and x-go-type-import) uses that existing Go type instead of a generated one.
+//
+// Usage:
+//
+//	openapigen [-package name] [-o file] openapi.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("openapigen: ")
+	pkg := flag.String("package", "main", "package of the generated file")
+	out := flag.String("o", "", "file to write (default stdout)")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: openapigen [-package name] [-o file] openapi.json")
+	}
+	spec, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(spec, filepath.Base(flag.Arg(0)), *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*out, src, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// schema is the subset of an OpenAPI schema object the generator understands.
+type schema struct {
+	Ref                  string          `json:"$ref"`
+	Type                 string          `json:"type"`
+	Format               string          `json:"format"`
+	Description          string          `json:"description"`
+	Enum                 []any           `json:"enum"`
+	Properties           properties      `json:"properties"`
+	Required             []string        `json:"required"`
+	Items                *schema         `json:"items"`
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	AllOf                []*schema       `json:"allOf"`
+	GoType               string          `json:"x-go-type"`
+	GoTypeImport         *goImport       `json:"x-go-type-import"`
+}
+
+type goImport struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// properties keeps the properties of a schema in document order, which is the field
+// order of the generated struct.
+type properties struct {
+	names   []string
+	schemas map[string]*schema
+}
+
+func (p *properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("properties: not an object")
+	}
+	p.schemas = make(map[string]*schema)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		var s schema
+		if err := dec.Decode(&s); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		p.names = append(p.names, name)
+		p.schemas[name] = &s
+	}
+	_, err := dec.Token()
+	return err
+}
+
+type generator struct {
+	schemas map[string]*schema
+	// imports maps the path of each import to its name, empty for the default one.
+	imports map[string]string
+	// external marks the imports named by x-go-type-import, grouped after the standard ones.
+	external map[string]bool
+	body     bytes.Buffer
+}
+
+// generate returns the gofmt'd Go source of the types of spec's component schemas.
+func generate(spec []byte, source, pkg string) ([]byte, error) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	g := &generator{schemas: doc.Components.Schemas, imports: make(map[string]string), external: make(map[string]bool)}
+	names := make([]string, 0, len(g.schemas))
+	for name := range g.schemas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := g.writeType(name, g.schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by openapigen. DO NOT EDIT.\n// source: %s\n\npackage %s\n\n", source, pkg)
+	if len(g.imports) > 0 {
+		out.WriteString("import (\n")
+		for _, external := range []bool{false, true} {
+			paths := make([]string, 0, len(g.imports))
+			for path := range g.imports {
+				if g.external[path] == external {
+					paths = append(paths, path)
+				}
+			}
+			slices.Sort(paths)
+			if external && len(paths) > 0 && len(paths) < len(g.imports) {
+				out.WriteString("\n")
+			}
+			for _, path := range paths {
+				fmt.Fprintf(&out, "\t%s %q\n", g.imports[path], path)
+			}
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// writeType writes the struct of an object schema. Schemas with an x-go-type and
+// free-form objects without properties have no generated type.
+func (g *generator) writeType(name string, s *schema) error {
+	if s.GoType != "" || (len(s.AllOf) == 0 && len(s.Properties.names) == 0) {
+		return nil
+	}
+	fmt.Fprintf(&g.body, "// %s is the %s schema.\n", name, name)
+	if s.Description != "" {
+		g.body.WriteString("//\n")
+		writeComment(&g.body, s.Description)
+	}
+	fmt.Fprintf(&g.body, "type %s struct {\n", name)
+	for _, part := range s.AllOf {
+		if part.Ref != "" {
+			ref, err := g.refType(part.Ref)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&g.body, "%s\n", ref)
+			continue
+		}
+		if err := g.writeFields(part); err != nil {
+			return err
+		}
+	}
+	if err := g.writeFields(s); err != nil {
+		return err
+	}
+	g.body.WriteString("}\n\n")
+	return nil
+}
+
+// writeFields writes a field for each property of s. Required properties are always
+// encoded; optional ones are omitted while zero, and are pointers when they are objects.
+func (g *generator) writeFields(s *schema) error {
+	for _, name := range s.Properties.names {
+		prop := s.Properties.schemas[name]
+		required := slices.Contains(s.Required, name)
+		typ, err := g.goType(prop, required)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		doc := prop.Description
+		if len(prop.Enum) > 0 {
+			values := make([]string, len(prop.Enum))
+			for i, v := range prop.Enum {
+				values[i] = fmt.Sprint(v)
+			}
+			doc = strings.TrimSpace(doc + " One of " + strings.Join(values, ", ") + ".")
+		}
+		if doc != "" {
+			writeComment(&g.body, doc)
+		}
+		tag := name
+		switch {
+		case required:
+		case typ == "time.Time":
+			tag += ",omitzero"
+		default:
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&g.body, "%s %s `json:%q`\n", goName(name), typ, tag)
+	}
+	return nil
+}
+
+// goType returns the Go type of a property schema.
+func (g *generator) goType(s *schema, required bool) (string, error) {
+	if s.GoType != "" {
+		g.addImport(s.GoTypeImport)
+		return s.GoType, nil
+	}
+	if s.Ref != "" {
+		typ, err := g.refType(s.Ref)
+		if err != nil || required {
+			return typ, err
+		}
+		return "*" + typ, nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = ""
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		switch s.Format {
+		case "int64", "uint64", "int32", "uint32":
+			return s.Format, nil
+		case "":
+			return "int", nil
+		}
+		return "", fmt.Errorf("unsupported integer format %q", s.Format)
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		elem, err := g.goType(s.Items, true)
+		return "[]" + elem, err
+	case "object":
+		if len(s.Properties.names) > 0 {
+			return "", fmt.Errorf("inline object with properties; declare it as a component schema")
+		}
+		var values schema
+		if bytes.HasPrefix(bytes.TrimSpace(s.AdditionalProperties), []byte("{")) {
+			if err := json.Unmarshal(s.AdditionalProperties, &values); err != nil {
+				return "", err
+			}
+		}
+		if values.Type == "" && values.Ref == "" {
+			return "map[string]any", nil
+		}
+		elem, err := g.goType(&values, true)
+		return "map[string]" + elem, err
+	case "":
+		g.imports["encoding/json"] = ""
+		return "json.RawMessage", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+// refType returns the Go type of the component schema ref points to.
+func (g *generator) refType(ref string) (string, error) {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok {
+		return "", fmt.Errorf("unsupported $ref %q", ref)
+	}
+	target, ok := g.schemas[name]
+	if !ok {
+		return "", fmt.Errorf("$ref to undefined schema %s", name)
+	}
+	if target.GoType != "" {
+		g.addImport(target.GoTypeImport)
+		return target.GoType, nil
+	}
+	return name, nil
+}
+
+func (g *generator) addImport(imp *goImport) {
+	if imp != nil {
+		g.imports[imp.Path] = imp.Name
+		g.external[imp.Path] = true
+	}
+}
+
+// initialisms are the words goName writes in capitals, as golint would have them.
+var initialisms = map[string]bool{"api": true, "grpc": true, "http": true, "id": true, "ip": true, "json": true, "sql": true, "tls": true, "ttl": true, "uri": true, "url": true}
+
+// goName returns the exported Go name of a camelCase JSON property, such as GroupID
+// for groupId.
+func goName(name string) string {
+	var b strings.Builder
+	word := func(w string) {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			return
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	start := 0
+	for i, r := range name {
+		if i > start && unicode.IsUpper(r) {
+			word(name[start:i])
+			start = i
+		}
+	}
+	if start < len(name) {
+		word(name[start:])
+	}
+	return b.String()
+}
+
+// writeComment writes text as // comment lines of at most about 90 columns.
+func writeComment(w *bytes.Buffer, text string) {
+	line := "//"
+	for _, word := range strings.Fields(text) {
+		if len(line) > 2 && len(line)+1+len(word) > 90 {
+			w.WriteString(line + "\n")
+			line = "//"
+		}
+		line += " " + word
+	}
+	w.WriteString(line + "\n")
+}
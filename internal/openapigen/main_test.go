@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedAdminTypesAreCurrent(t *testing.T) {
+	spec, err := os.ReadFile("../../cmd/filter/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := generate(spec, "openapi.json", "main")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	got, err := os.ReadFile("../../cmd/filter/openapi.gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("cmd/filter/openapi.gen.go is stale; run go generate ./cmd/filter")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	spec := `{"components": {"schemas": {
+		"Free": {"type": "object", "additionalProperties": true},
+		"Meta": {"type": "object", "x-go-type": "store.Metadata", "x-go-type-import": {"path": "kafka-bridge/pkg/store"}},
+		"Base": {"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]},
+		"Item": {
+			"description": "An item.",
+			"allOf": [
+				{"$ref": "#/components/schemas/Base"},
+				{"type": "object", "properties": {
+					"zeta": {"type": "integer", "format": "uint64", "description": "Counted."},
+					"alpha": {"type": "string", "enum": ["a", "b"]},
+					"at": {"type": "string", "format": "date-time"},
+					"meta": {"$ref": "#/components/schemas/Meta"},
+					"base": {"$ref": "#/components/schemas/Base"},
+					"raw": {},
+					"tags": {"type": "object", "additionalProperties": {"type": "string"}},
+					"offset": {"type": "integer", "x-go-type": "*int64"},
+					"referenceHttp": {"type": "array", "items": {"$ref": "#/components/schemas/Meta"}}
+				}, "required": ["zeta", "meta"]}
+			]
+		}
+	}}}`
+	src, err := generate([]byte(spec), "spec.json", "api")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	got := string(src)
+	for _, want := range []string{
+		"// Code generated by openapigen. DO NOT EDIT.\n// source: spec.json\n\npackage api\n",
+		"import (\n\t\"encoding/json\"\n\t\"time\"\n\n\t\"kafka-bridge/pkg/store\"\n)\n",
+		"type Base struct {\n\tID string `json:\"id\"`\n}\n",
+		"// Item is the Item schema.\n//\n// An item.\ntype Item struct {\n\tBase\n\t// Counted.\n\tZeta uint64 `json:\"zeta\"`\n",
+		"\t// One of a, b.\n\tAlpha string `json:\"alpha,omitempty\"`\n",
+		"\tAt time.Time `json:\"at,omitzero\"`\n",
+		"\tMeta store.Metadata `json:\"meta\"`\n",
+		"\tBase *Base `json:\"base,omitempty\"`\n",
+		"\tRaw json.RawMessage `json:\"raw,omitempty\"`\n",
+		"\tTags map[string]string `json:\"tags,omitempty\"`\n",
+		"\tOffset *int64 `json:\"offset,omitempty\"`\n",
+		"\tReferenceHTTP []store.Metadata `json:\"referenceHttp,omitempty\"`\n",
+	} {
+		// gofmt aligns the fields of a block, so compare with single spaces
+		if !strings.Contains(strings.Join(strings.Fields(got), " "), strings.Join(strings.Fields(want), " ")) {
+			t.Errorf("generated code lacks %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"type Free", "type Meta"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("generated code has %q", unwanted)
+		}
+	}
+	if _, err := generate([]byte(`{"components": {"schemas": {"A": {"type": "object", "properties": {"b": {"$ref": "#/components/schemas/B"}}}}}}`), "spec.json", "api"); err == nil {
+		t.Error("generate accepted a $ref to an undefined schema")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, want := range map[string]string{
+		"route":            "Route",
+		"groupId":          "GroupID",
+		"sourceGroupId":    "SourceGroupID",
+		"referenceHttp":    "ReferenceHTTP",
+		"referenceSql":     "ReferenceSQL",
+		"grpc":             "GRPC",
+		"uptimeSeconds":    "UptimeSeconds",
+		"identity":         "Identity",
+		"readOnlyAdmin":    "ReadOnlyAdmin",
+		"collectorRestart": "CollectorRestart",
+	} {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -1,37 +0,0 @@
-package store
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
-)
-
-// Save writes the snapshot of route values to the provided path.
-func Save(path string, snapshot map[string][]string) error {
-	if path == "" {
-		return errors.New("path is empty")
-	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return fmt.Errorf("mkdir: %w", err)
-	}
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal: %w", err)
-	}
-	return os.WriteFile(path, data, 0o644)
-}
-
-// Load reads the snapshot from disk.
-func Load(path string) (map[string][]string, error) {
-	raw, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var snapshot map[string][]string
-	if err := json.Unmarshal(raw, &snapshot); err != nil {
-		return nil, fmt.Errorf("unmarshal: %w", err)
-	}
-	return snapshot, nil
-}
@@ -0,0 +1,193 @@
+// Package watchdog samples resource gauges over time and flags monotonic growth, the
+// signature of goroutine, heap, and client leaks that otherwise only surface in long soaks.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults applied to zero Config fields.
+const (
+	DefaultInterval  = time.Minute
+	DefaultWindow    = 30
+	DefaultMinGrowth = 0.1
+)
+
+// Config tunes sampling and the growth considered a leak.
+type Config struct {
+	// Interval between samples.
+	Interval time.Duration
+	// Window is how many consecutive non-decreasing samples make a trend.
+	Window int
+	// MinGrowth is the fractional increase over the window required to flag it.
+	MinGrowth float64
+	// ProfileDir, when set, receives a heap profile each time growth is flagged.
+	ProfileDir string
+}
+
+// Finding describes one gauge that grew monotonically across a full window.
+type Finding struct {
+	Probe   string    `json:"probe"`
+	First   float64   `json:"first"`
+	Last    float64   `json:"last"`
+	Samples int       `json:"samples"`
+	At      time.Time `json:"at"`
+}
+
+type series struct {
+	sample  func() float64
+	values  []float64
+	last    float64
+	finding *Finding
+}
+
+// Watchdog periodically samples registered gauges.
+type Watchdog struct {
+	cfg  Config
+	logf func(format string, args ...any)
+
+	mu     sync.Mutex
+	names  []string
+	series map[string]*series
+}
+
+// New builds a watchdog; zero Config fields take the package defaults.
+func New(cfg Config) *Watchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Window < 2 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.MinGrowth <= 0 {
+		cfg.MinGrowth = DefaultMinGrowth
+	}
+	return &Watchdog{cfg: cfg, logf: log.Printf, series: make(map[string]*series)}
+}
+
+// Register adds a gauge sampled on every tick. Registering a name again replaces it.
+func (w *Watchdog) Register(name string, sample func() float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.series[name]; !ok {
+		w.names = append(w.names, name)
+	}
+	w.series[name] = &series{sample: sample}
+}
+
+// RegisterRuntime adds the process-wide goroutine and heap gauges.
+func (w *Watchdog) RegisterRuntime() {
+	w.Register("goroutines", func() float64 { return float64(runtime.NumGoroutine()) })
+	w.Register("heap_alloc_bytes", func() float64 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return float64(ms.HeapAlloc)
+	})
+}
+
+// Run samples every interval until the context is cancelled.
+func (w *Watchdog) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check takes one sample of every gauge and returns the gauges newly flagged.
+func (w *Watchdog) check() []Finding {
+	w.mu.Lock()
+	var flagged []Finding
+	for _, name := range w.names {
+		s := w.series[name]
+		v := s.sample()
+		s.last = v
+		if n := len(s.values); n > 0 && v < s.values[n-1] {
+			// trend broken: start a new window from here
+			s.values = s.values[:0]
+			s.finding = nil
+		}
+		s.values = append(s.values, v)
+		if len(s.values) < w.cfg.Window {
+			continue
+		}
+		first := s.values[0]
+		if v > first && v >= first*(1+w.cfg.MinGrowth) {
+			f := Finding{Probe: name, First: first, Last: v, Samples: len(s.values), At: time.Now().UTC()}
+			s.finding = &f
+			flagged = append(flagged, f)
+		}
+		// keep sliding: a flagged gauge is reported again only after another full window
+		s.values = append(s.values[:0], v)
+	}
+	w.mu.Unlock()
+
+	for _, f := range flagged {
+		w.logf("watchdog: %s grew monotonically from %.0f to %.0f over %d samples (%s); possible leak",
+			f.Probe, f.First, f.Last, f.Samples, time.Duration(f.Samples-1)*w.cfg.Interval)
+	}
+	if len(flagged) > 0 && w.cfg.ProfileDir != "" {
+		path, err := w.writeHeapProfile()
+		if err != nil {
+			w.logf("watchdog: heap profile capture failed: %v", err)
+		} else {
+			w.logf("watchdog: heap profile written to %s", path)
+		}
+	}
+	return flagged
+}
+
+// Findings returns the gauges currently flagged, sorted by name.
+func (w *Watchdog) Findings() []Finding {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := []Finding{}
+	for _, s := range w.series {
+		if s.finding != nil {
+			out = append(out, *s.finding)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Probe < out[j].Probe })
+	return out
+}
+
+// Latest returns the most recent sample of every gauge.
+func (w *Watchdog) Latest() map[string]float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make(map[string]float64, len(w.series))
+	for name, s := range w.series {
+		out[name] = s.last
+	}
+	return out
+}
+
+func (w *Watchdog) writeHeapProfile() (string, error) {
+	if err := os.MkdirAll(w.cfg.ProfileDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(w.cfg.ProfileDir, fmt.Sprintf("heap-%s.pprof", time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := pprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		return "", err
+	}
+	return path, nil
+}
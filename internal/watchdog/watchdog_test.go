@@ -0,0 +1,49 @@
+package watchdog
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWatchdogFlagsMonotonicGrowth(t *testing.T) {
+	dir := t.TempDir()
+	w := New(Config{Window: 4, MinGrowth: 0.5, ProfileDir: dir})
+	w.logf = func(string, ...any) {}
+
+	cases := map[string][]float64{
+		"leaking":  {10, 12, 14, 16, 20, 24, 28},
+		"flat":     {10, 10, 10, 10, 10, 10, 10},
+		"sawtooth": {10, 20, 30, 5, 6, 5, 6},
+		"slow":     {10, 10, 11, 11, 12, 12, 13},
+	}
+	for name, values := range cases {
+		values := values
+		i := 0
+		w.Register(name, func() float64 {
+			v := values[i]
+			i++
+			return v
+		})
+	}
+
+	var flagged []string
+	for range 7 {
+		for _, f := range w.check() {
+			flagged = append(flagged, f.Probe)
+		}
+	}
+	// leaking: 10->16 over the first window, then 16->28 over the next
+	if len(flagged) != 2 || flagged[0] != "leaking" || flagged[1] != "leaking" {
+		t.Fatalf("unexpected findings: %v", flagged)
+	}
+	if got := w.Findings(); len(got) != 1 || got[0].First != 16 || got[0].Last != 28 {
+		t.Fatalf("unexpected current findings: %+v", got)
+	}
+	if got := w.Latest()["sawtooth"]; got != 6 {
+		t.Fatalf("expected latest sawtooth sample 6, got %v", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("expected heap profile in %s, got %v (%v)", dir, entries, err)
+	}
+}
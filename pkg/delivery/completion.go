@@ -0,0 +1,250 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Completion learns the outcome of the asynchronous writes of one source message. Started
+// is called before each message is handed to an async writer, and Finished once for each
+// Started: with nil when the brokers acknowledged the message or the writer refused it
+// outright, in which case WriteMessages returns the error, and with the error when the
+// write failed after the writer's own retries. Messages queued behind a failed write
+// finish with an error without being written.
+type Completion interface {
+	Started()
+	Finished(err error)
+}
+
+type completionKey struct{}
+
+// WithCompletion returns a context whose writes to async writers report to c instead of
+// being waited for.
+func WithCompletion(ctx context.Context, c Completion) context.Context {
+	return context.WithValue(ctx, completionKey{}, c)
+}
+
+// CompletionFrom returns the Completion of ctx, for MessageWriters that write
+// asynchronously.
+func CompletionFrom(ctx context.Context) (Completion, bool) {
+	c, ok := ctx.Value(completionKey{}).(Completion)
+	return c, ok
+}
+
+// writeAsync hands msgs to an async writer's queue. Without a Completion in ctx it waits
+// for the queue to complete them, so callers that need no throughput still learn of
+// failures.
+func writeAsync(ctx context.Context, q *asyncQueue, msgs []kafka.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	c, ok := CompletionFrom(ctx)
+	var wait *waitCompletion
+	if !ok {
+		wait = &waitCompletion{done: make(chan struct{})}
+		c = wait
+	}
+	tagged := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		msg.WriterData = c
+		tagged[i] = msg
+		c.Started()
+	}
+	if err := q.enqueue(ctx, tagged); err != nil {
+		for range tagged {
+			c.Finished(nil)
+		}
+		return err
+	}
+	if wait == nil {
+		return nil
+	}
+	select {
+	case <-wait.done:
+		return wait.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Limits of one write of an async queue: at most asyncGeneration messages, and batches
+// are sent once asyncBatchTimeout has passed instead of waiting to fill up.
+const (
+	asyncGeneration   = 1000
+	asyncBatchTimeout = time.Millisecond
+	// defaultBatchBytes is kafka-go's limit of a batch when Writer.BatchBytes is zero.
+	defaultBatchBytes = 1 << 20
+	// messageOverhead bounds what kafka-go adds to a message's size in a batch, besides
+	// its headers.
+	messageOverhead = 64
+)
+
+var errAsyncClosed = errors.New("async writer closed")
+
+// asyncQueue is the async side of a pooled writer. Messages handed to it are written by
+// one goroutine, what is queued at a time, small enough to make a single batch per
+// destination partition. Once a write fails, the messages queued behind it fail without
+// being written, so no message reaches a destination partition ahead of an earlier one
+// that has to be redelivered.
+type asyncQueue struct {
+	writer   MessageWriter
+	topic    string
+	maxBytes int64
+	counted  *writerBytes
+
+	mu     sync.Mutex
+	queue  []kafka.Message
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// newAsyncQueue starts the queue of writer, whose batches hold at most maxBytes.
+func newAsyncQueue(writer MessageWriter, topic string, maxBytes int64, counted *writerBytes) *asyncQueue {
+	q := &asyncQueue{
+		writer:   writer,
+		topic:    topic,
+		maxBytes: maxBytes,
+		counted:  counted,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// enqueue queues msgs, each carrying its Completion as WriterData, unless ctx is done or
+// the queue closed.
+func (q *asyncQueue) enqueue(ctx context.Context, msgs []kafka.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if q.closed {
+		return errAsyncClosed
+	}
+	q.queue = append(q.queue, msgs...)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// close writes what is still queued and stops the queue. Later writes are refused.
+func (q *asyncQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	<-q.done
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	for {
+		msgs, closed := q.next()
+		if len(msgs) == 0 {
+			if closed {
+				return
+			}
+			<-q.wake
+			continue
+		}
+		q.write(msgs)
+	}
+}
+
+// next takes the messages of the next write off the queue, and reports whether the queue
+// is closed.
+func (q *asyncQueue) next() ([]kafka.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	var bytes int64
+	for n < len(q.queue) && n < asyncGeneration {
+		size := messagesSize(q.queue[n:n+1]) + messageOverhead*int64(1+len(q.queue[n].Headers))
+		if n > 0 && bytes+size > q.maxBytes {
+			break
+		}
+		bytes += size
+		n++
+	}
+	msgs := q.queue[:n:n]
+	if q.queue = q.queue[n:]; len(q.queue) == 0 {
+		q.queue = nil
+	}
+	return msgs, q.closed
+}
+
+// write writes msgs and finishes them. After a failure the rest of the queue is failed
+// too, under the lock, so writers whose Completion cancels them on the failure have
+// stopped before anything else is queued.
+func (q *asyncQueue) write(msgs []kafka.Message) {
+	err := q.writer.WriteMessages(context.Background(), msgs...)
+	var errs kafka.WriteErrors
+	perMessage := errors.As(err, &errs) && len(errs) == len(msgs)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var failed error
+	for i, msg := range msgs {
+		msgErr := err
+		if perMessage {
+			msgErr = errs[i]
+		}
+		if msgErr == nil {
+			q.counted.uncompressed.Add(messagesSize(msgs[i : i+1]))
+		} else if failed == nil {
+			failed = msgErr
+		}
+		finish(msg, msgErr)
+	}
+	if failed == nil {
+		return
+	}
+	for _, msg := range q.queue {
+		finish(msg, fmt.Errorf("not written after an earlier write to %s failed: %w", q.topic, failed))
+	}
+	q.queue = nil
+}
+
+func finish(msg kafka.Message, err error) {
+	if c, ok := msg.WriterData.(Completion); ok {
+		c.Finished(err)
+	}
+}
+
+// waitCompletion is closed once every started write finished, holding the first error.
+type waitCompletion struct {
+	mu      sync.Mutex
+	pending int
+	err     error
+	done    chan struct{}
+}
+
+func (w *waitCompletion) Started() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending++
+}
+
+func (w *waitCompletion) Finished(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+	if w.pending--; w.pending == 0 {
+		close(w.done)
+	}
+}
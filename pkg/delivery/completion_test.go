@@ -0,0 +1,127 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// gatedWriter blocks each write until its result is sent on results.
+type gatedWriter struct {
+	mu      sync.Mutex
+	written []string
+	calls   chan []kafka.Message
+	results chan error
+}
+
+func (w *gatedWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.calls <- msgs
+	err := <-w.results
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs kafka.WriteErrors
+	errors.As(err, &errs)
+	for i, msg := range msgs {
+		if err == nil || (len(errs) == len(msgs) && errs[i] == nil) {
+			w.written = append(w.written, string(msg.Value))
+		}
+	}
+	return err
+}
+
+// recorded is a Completion that keeps the outcome of its one message.
+type recorded struct {
+	done chan error
+}
+
+func (r *recorded) Started()           {}
+func (r *recorded) Finished(err error) { r.done <- err }
+
+func TestAsyncQueueStopsAfterFailedWrite(t *testing.T) {
+	w := &gatedWriter{calls: make(chan []kafka.Message, 1), results: make(chan error)}
+	counted := &writerBytes{}
+	q := newAsyncQueue(w, "out", defaultBatchBytes, counted)
+	defer q.close()
+
+	outcomes := make([]*recorded, 5)
+	send := func(i int, value string) {
+		t.Helper()
+		outcomes[i] = &recorded{done: make(chan error, 1)}
+		ctx := WithCompletion(context.Background(), outcomes[i])
+		if err := writeAsync(ctx, q, []kafka.Message{{Value: []byte(value)}}); err != nil {
+			t.Fatalf("write %s: %v", value, err)
+		}
+	}
+	expectCall := func(want ...string) {
+		t.Helper()
+		msgs := <-w.calls
+		var got []string
+		for _, msg := range msgs {
+			got = append(got, string(msg.Value))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("write of %v, want %v", got, want)
+		}
+	}
+
+	send(0, "m0")
+	expectCall("m0")
+	send(1, "m1")
+	send(2, "m2")
+	w.results <- nil
+	expectCall("m1", "m2")
+	send(3, "m3")
+	failure := errors.New("not enough replicas")
+	w.results <- kafka.WriteErrors{nil, failure}
+
+	for i, want := range []string{"", "", "not enough replicas", "not written after an earlier write to out failed"} {
+		err := <-outcomes[i].done
+		if (want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), want)) {
+			t.Fatalf("m%d finished with %v, want %q", i, err, want)
+		}
+	}
+
+	send(4, "m4")
+	expectCall("m4")
+	w.results <- nil
+	if err := <-outcomes[4].done; err != nil {
+		t.Fatalf("m4 finished with %v", err)
+	}
+	w.mu.Lock()
+	written := strings.Join(w.written, ",")
+	w.mu.Unlock()
+	if written != "m0,m1,m4" {
+		t.Fatalf("written %s, want m0,m1,m4", written)
+	}
+	if n := counted.uncompressed.Load(); n != 6 {
+		t.Fatalf("expected only acknowledged bytes counted, got %d", n)
+	}
+}
+
+func TestWriteAsyncWaitsWithoutCompletion(t *testing.T) {
+	w := &gatedWriter{calls: make(chan []kafka.Message, 1), results: make(chan error, 1)}
+	q := newAsyncQueue(w, "out", defaultBatchBytes, &writerBytes{})
+	w.results <- errors.New("leader not available")
+	err := writeAsync(context.Background(), q, []kafka.Message{{Value: []byte("abc")}})
+	if err == nil || err.Error() != "leader not available" {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	<-w.calls
+	q.close()
+	if err := writeAsync(context.Background(), q, []kafka.Message{{Value: []byte("abc")}}); !errors.Is(err, errAsyncClosed) {
+		t.Fatalf("expected a closed queue to refuse writes, got %v", err)
+	}
+
+	wait := &waitCompletion{done: make(chan struct{})}
+	c, ok := CompletionFrom(WithCompletion(context.Background(), wait))
+	if !ok || c != wait {
+		t.Fatal("expected the completion back from the context")
+	}
+	if _, ok := CompletionFrom(context.Background()); ok {
+		t.Fatal("expected no completion in a plain context")
+	}
+}
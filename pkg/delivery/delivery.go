@@ -0,0 +1,155 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Default retry backoff bounds for destination writes.
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultMaxRetryBackoff = 10 * time.Second
+)
+
+// MessageWriter is the part of kafka.Writer used to deliver forwarded messages.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// RetryPolicy controls how failed destination writes are retried.
+type RetryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxAttempts bounds the writes tried per message; zero retries until ctx is done.
+	MaxAttempts int
+	// OnFailure, when set, is called with every failed write attempt.
+	OnFailure func(error)
+}
+
+// Deliver writes msgs in order, retrying failures with exponential backoff. Callers must not
+// write later messages from the same source partition until Deliver returns, so a retried
+// message can never land behind one that followed it. When some messages of a batch fail
+// only those are retried; SourcePartitionBalancer keeps each source partition, and
+// KeyHashBalancer each key, on a single destination partition, whose batch succeeds or
+// fails as a whole.
+func Deliver(ctx context.Context, w MessageWriter, policy RetryPolicy, msgs ...kafka.Message) error {
+	backoff := policy.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+
+	pending := msgs
+	for attempt := 1; ; attempt++ {
+		err := w.WriteMessages(ctx, pending...)
+		if err == nil {
+			return nil
+		}
+		if policy.OnFailure != nil {
+			policy.OnFailure(err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return fmt.Errorf("write failed after %d attempts: %w", attempt, err)
+		}
+		pending = failedMessages(pending, err)
+		log.Printf("destination write failed (attempt %d, %d message(s) pending), retrying in %s: %v", attempt, len(pending), backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// failedMessages keeps, in their original order, the messages a write error reports as failed.
+func failedMessages(msgs []kafka.Message, err error) []kafka.Message {
+	out := make([]kafka.Message, 0, len(msgs))
+	for i, msg := range msgs {
+		if MessageError(err, len(msgs), i) != nil {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// MessageError returns the error err reports for the i-th of n messages written together:
+// its entry in a kafka.WriteErrors of length n, or err itself for any other error.
+func MessageError(err error, n, i int) error {
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == n {
+		return writeErrs[i]
+	}
+	return err
+}
+
+// SourcePartitionBalancer sends every message to the destination partition derived from
+// Message.Partition, which the bridge sets to the source partition. Messages of one source
+// partition therefore stay in order on one destination partition.
+type SourcePartitionBalancer struct{}
+
+// Balance implements kafka.Balancer.
+func (SourcePartitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if len(partitions) == 0 {
+		return 0
+	}
+	p := msg.Partition
+	if p < 0 {
+		p = -p
+	}
+	return partitions[p%len(partitions)]
+}
+
+// Partitioners accepted by Balancer and Pool.GetPartitioned.
+const (
+	// PartitionSource keeps each source partition on one destination partition.
+	PartitionSource = "source"
+	// PartitionKey hashes the message key like the Java client's default partitioner.
+	PartitionKey = "key"
+	// PartitionRoundRobin spreads messages evenly, without ordering guarantees.
+	PartitionRoundRobin = "roundRobin"
+)
+
+// Balancer returns a new balancer for partitioner.
+func Balancer(partitioner string) (kafka.Balancer, error) {
+	switch partitioner {
+	case PartitionSource:
+		return SourcePartitionBalancer{}, nil
+	case PartitionKey:
+		return KeyHashBalancer{}, nil
+	case PartitionRoundRobin:
+		return &kafka.RoundRobin{}, nil
+	}
+	return nil, fmt.Errorf("unknown partitioner %q (want source, key, or roundRobin)", partitioner)
+}
+
+// KeyHashBalancer places keyed messages by the murmur2 hash of their key, as Java
+// producers do, so messages keep their per-key order and land on the partition other
+// producers would pick for the key. Messages without a key fall back to
+// SourcePartitionBalancer.
+type KeyHashBalancer struct{}
+
+// Balance implements kafka.Balancer.
+func (KeyHashBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if msg.Key == nil {
+		return SourcePartitionBalancer{}.Balance(msg, partitions...)
+	}
+	return kafka.Murmur2Balancer{}.Balance(msg, partitions...)
+}
@@ -0,0 +1,142 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// flakyWriter fails the first writes per its script and records what was delivered.
+type flakyWriter struct {
+	script    []func(msgs []kafka.Message) error
+	calls     int
+	delivered []string
+}
+
+func (w *flakyWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	var err error
+	if w.calls < len(w.script) {
+		err = w.script[w.calls](msgs)
+	}
+	w.calls++
+	var writeErrs kafka.WriteErrors
+	for i, m := range msgs {
+		if err == nil || (errors.As(err, &writeErrs) && writeErrs[i] == nil) {
+			w.delivered = append(w.delivered, string(m.Value))
+		}
+	}
+	return err
+}
+
+func messages(values ...string) []kafka.Message {
+	out := make([]kafka.Message, len(values))
+	for i, v := range values {
+		out[i] = kafka.Message{Value: []byte(v)}
+	}
+	return out
+}
+
+func TestDeliverPreservesOrderAcrossRetries(t *testing.T) {
+	fail := func([]kafka.Message) error { return errors.New("broker unavailable") }
+	partial := func(msgs []kafka.Message) error {
+		// first destination partition (a, b) failed, second (c) succeeded
+		return kafka.WriteErrors{errors.New("not leader"), errors.New("not leader"), nil}
+	}
+	cases := []struct {
+		name    string
+		script  []func([]kafka.Message) error
+		batches [][]string
+		want    []string
+		calls   int
+	}{
+		{name: "transient failures", script: []func([]kafka.Message) error{fail, fail}, batches: [][]string{{"a"}, {"b"}, {"c"}}, want: []string{"a", "b", "c"}, calls: 5},
+		{name: "partial batch failure", script: []func([]kafka.Message) error{partial}, batches: [][]string{{"a", "b", "c"}, {"d"}}, want: []string{"c", "a", "b", "d"}, calls: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &flakyWriter{script: tc.script}
+			policy := RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+			for _, batch := range tc.batches {
+				if err := Deliver(context.Background(), w, policy, messages(batch...)...); err != nil {
+					t.Fatalf("Deliver(%v): %v", batch, err)
+				}
+			}
+			if w.calls != tc.calls {
+				t.Fatalf("expected %d writes, got %d", tc.calls, w.calls)
+			}
+			if len(w.delivered) != len(tc.want) {
+				t.Fatalf("delivered %v, want %v", w.delivered, tc.want)
+			}
+			for i := range tc.want {
+				if w.delivered[i] != tc.want[i] {
+					t.Fatalf("delivered %v, want %v", w.delivered, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	fail := func([]kafka.Message) error { return errors.New("broker unavailable") }
+	w := &flakyWriter{script: []func([]kafka.Message) error{fail, fail, fail}}
+	policy := RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 2}
+	if err := Deliver(context.Background(), w, policy, messages("a")...); err == nil {
+		t.Fatal("expected error after max attempts")
+	}
+	if w.calls != 2 || len(w.delivered) != 0 {
+		t.Fatalf("expected 2 failed attempts, got calls=%d delivered=%v", w.calls, w.delivered)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = &flakyWriter{script: []func([]kafka.Message) error{fail}}
+	if err := Deliver(ctx, w, RetryPolicy{}, messages("a")...); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context cancellation, got %v", err)
+	}
+}
+
+func TestSourcePartitionBalancer(t *testing.T) {
+	b := SourcePartitionBalancer{}
+	partitions := []int{0, 1, 2}
+	for source, want := range map[int]int{0: 0, 1: 1, 4: 1, 5: 2} {
+		if got := b.Balance(kafka.Message{Partition: source}, partitions...); got != want {
+			t.Fatalf("source partition %d: got %d, want %d", source, got, want)
+		}
+	}
+}
+
+func TestKeyHashBalancer(t *testing.T) {
+	partitions := make([]int, 100)
+	for i := range partitions {
+		partitions[i] = i
+	}
+	// murmur2("foobar") is -790332482 in the Java client, which picks partition 66 of 100
+	b := KeyHashBalancer{}
+	if got := b.Balance(kafka.Message{Key: []byte("foobar"), Partition: 7}, partitions...); got != 66 {
+		t.Fatalf("keyed message: got partition %d, want 66", got)
+	}
+	if got := b.Balance(kafka.Message{Partition: 7}, partitions...); got != 7 {
+		t.Fatalf("unkeyed message: got partition %d, want the source partition 7", got)
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	rr, err := Balancer(PartitionRoundRobin)
+	if err != nil {
+		t.Fatalf("Balancer: %v", err)
+	}
+	var got []int
+	for i := 0; i < 4; i++ {
+		got = append(got, rr.Balance(kafka.Message{Partition: 1}, 0, 1, 2))
+	}
+	if fmt.Sprint(got) != "[0 1 2 0]" {
+		t.Fatalf("round robin placed messages on %v", got)
+	}
+	if _, err := Balancer("sticky"); err == nil {
+		t.Fatal("expected error for unknown partitioner")
+	}
+}
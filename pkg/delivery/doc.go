@@ -0,0 +1,4 @@
+// Package delivery writes forwarded messages to Kafka: Pool manages one writer per
+// destination topic, creating topics on first use, and Deliver retries failed writes
+// without reordering the messages of a source partition.
+package delivery
@@ -0,0 +1,595 @@
+package delivery
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Pool lazily creates writers per topic and partitioner and reuses them. Each topic is
+// ensured with EnsureTopic once per pool.
+type Pool struct {
+	mu      sync.Mutex
+	writers map[writerKey]*kafka.Writer
+	// queues holds the asyncQueue of every open async writer.
+	queues    map[writerKey]*asyncQueue
+	bytes     map[writerKey]*writerBytes
+	compacted map[string]bool
+	brokers   []string
+	dialer    *kafka.Dialer
+	balancer  kafka.Balancer
+	// topics, when set, replaces the Kafka writers of Topic, PartitionedTopic, and
+	// TopicWith.
+	topics func(topic string) MessageWriter
+	// lastUsed is when each open writer was last handed out; idleTimeout closes the ones
+	// idle for longer in EvictIdle.
+	lastUsed    map[writerKey]time.Time
+	idleTimeout time.Duration
+	// totals accumulates the kafka-go statistics of every topic's writers, which reset
+	// them on every read, including the writers since closed.
+	totals map[string]*TopicStats
+	// ensured holds the topics EnsureTopic found or created for the pool, so a new writer
+	// of a known topic does not dial the controller again.
+	ensured map[string]bool
+}
+
+// writerKey identifies a pooled writer.
+type writerKey struct {
+	topic string
+	WriterOptions
+}
+
+// WriterOptions configure a pooled writer beyond its topic. Writers of one topic with
+// different options are pooled separately.
+type WriterOptions struct {
+	// Partitioner places messages (see Balancer); empty is the pool's balancer.
+	Partitioner string
+	// Compression is the codec batches are compressed with; empty is none.
+	Compression string
+	// Async hands messages to the writer's queue without waiting for the brokers; see
+	// WithCompletion and asyncQueue.
+	Async bool
+}
+
+// normalized maps compression none to "", so uncompressed writers are pooled together.
+func (o WriterOptions) normalized() WriterOptions {
+	if o.Compression == CompressionNone {
+		o.Compression = ""
+	}
+	return o
+}
+
+// writerBytes counts what a pooled writer wrote: the size of the messages it delivered,
+// and the bytes it sent to the brokers once compressed.
+type writerBytes struct {
+	uncompressed atomic.Int64
+	sent         atomic.Int64
+}
+
+// Compression codecs accepted by WriterOptions.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionLz4    = "lz4"
+	CompressionZstd   = "zstd"
+)
+
+// codec returns the kafka-go codec of a compression name; none and "" return 0.
+func codec(compression string) (kafka.Compression, error) {
+	switch compression {
+	case "", CompressionNone:
+		return 0, nil
+	case CompressionGzip:
+		return kafka.Gzip, nil
+	case CompressionSnappy:
+		return kafka.Snappy, nil
+	case CompressionLz4:
+		return kafka.Lz4, nil
+	case CompressionZstd:
+		return kafka.Zstd, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q (want none, gzip, snappy, lz4, or zstd)", compression)
+}
+
+// PoolOption configures a Pool built by NewPool.
+type PoolOption func(*Pool)
+
+// WithCompactedTopics marks topics to be created with cleanup.policy=compact, as
+// SetCompacted does.
+func WithCompactedTopics(topics ...string) PoolOption {
+	return func(p *Pool) {
+		for _, topic := range topics {
+			p.compacted[topic] = true
+		}
+	}
+}
+
+// WithBalancer places messages with balancer instead of SourcePartitionBalancer.
+func WithBalancer(balancer kafka.Balancer) PoolOption {
+	return func(p *Pool) { p.balancer = balancer }
+}
+
+// WithIdleTimeout makes EvictIdle close writers that have not been used for timeout.
+func WithIdleTimeout(timeout time.Duration) PoolOption {
+	return func(p *Pool) { p.idleTimeout = timeout }
+}
+
+// WithTopicWriters makes Topic and PartitionedTopic return the writer topics returns
+// instead of a pooled Kafka writer, such as one of an in-memory broker. Partitioners are
+// not applied: messages keep the partition they carry.
+func WithTopicWriters(topics func(topic string) MessageWriter) PoolOption {
+	return func(p *Pool) { p.topics = topics }
+}
+
+// NewPool builds a writer pool for the provided brokers and dialer.
+func NewPool(brokers []string, dialer *kafka.Dialer, opts ...PoolOption) *Pool {
+	p := &Pool{
+		brokers:   brokers,
+		dialer:    dialer,
+		writers:   make(map[writerKey]*kafka.Writer),
+		queues:    make(map[writerKey]*asyncQueue),
+		bytes:     make(map[writerKey]*writerBytes),
+		lastUsed:  make(map[writerKey]time.Time),
+		totals:    make(map[string]*TopicStats),
+		ensured:   make(map[string]bool),
+		compacted: make(map[string]bool),
+		balancer:  SourcePartitionBalancer{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetCompacted marks topic to be created with cleanup.policy=compact if it does not exist
+// yet. Existing topics keep their configuration.
+func (p *Pool) SetCompacted(topic string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.compacted[topic] = true
+}
+
+// Get returns a writer bound to the destination topic, ensuring the topic exists. Writers
+// place messages by Message.Partition (see SourcePartitionBalancer) unless the pool was
+// built WithBalancer.
+func (p *Pool) Get(topic string) (*kafka.Writer, error) {
+	return p.get(writerKey{topic: topic})
+}
+
+// GetPartitioned returns a writer bound to topic that places messages with partitioner
+// (see Balancer). Writers of one topic with different partitioners are pooled separately.
+func (p *Pool) GetPartitioned(topic, partitioner string) (*kafka.Writer, error) {
+	return p.GetWith(topic, WriterOptions{Partitioner: partitioner})
+}
+
+// GetWith returns a writer bound to topic configured by opts.
+func (p *Pool) GetWith(topic string, opts WriterOptions) (*kafka.Writer, error) {
+	return p.get(writerKey{topic: topic, WriterOptions: opts.normalized()})
+}
+
+func (p *Pool) get(key writerKey) (*kafka.Writer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if writer, ok := p.writers[key]; ok {
+		p.lastUsed[key] = time.Now()
+		return writer, nil
+	}
+	balancer := p.balancer
+	if key.Partitioner != "" {
+		var err error
+		if balancer, err = Balancer(key.Partitioner); err != nil {
+			return nil, err
+		}
+	}
+	compression, err := codec(key.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := key.topic
+
+	topicCfg := kafka.TopicConfig{Topic: topic, NumPartitions: -1, ReplicationFactor: -1}
+	if p.compacted[topic] {
+		topicCfg.ConfigEntries = []kafka.ConfigEntry{{ConfigName: "cleanup.policy", ConfigValue: "compact"}}
+	}
+	if !p.ensured[topic] {
+		// other topics' writers are not held up while this one's is ensured and retried
+		p.mu.Unlock()
+		err = EnsureTopic(p.brokers, p.dialer, topicCfg)
+		p.mu.Lock()
+		if err != nil {
+			return nil, err
+		}
+		p.ensured[topic] = true
+		if writer, ok := p.writers[key]; ok {
+			p.lastUsed[key] = time.Now()
+			return writer, nil
+		}
+	}
+
+	writer := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:      p.brokers,
+		Topic:        topic,
+		Balancer:     balancer,
+		RequiredAcks: int(kafka.RequireAll),
+		Dialer:       p.dialer,
+	})
+	writer.Compression = compression
+	counted := p.counters(key)
+	if key.Async {
+		// The queue sizes each write to one batch per partition; send it at once.
+		writer.BatchSize, writer.BatchTimeout = asyncGeneration, asyncBatchTimeout
+		p.queues[key] = newAsyncQueue(writer, topic, defaultBatchBytes, counted)
+	}
+	if transport, ok := writer.Transport.(*kafka.Transport); ok && transport.Dial != nil {
+		dial := transport.Dial
+		transport.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			return countingConn{Conn: conn, sent: &counted.sent}, nil
+		}
+	}
+
+	p.writers[key] = writer
+	p.lastUsed[key] = time.Now()
+	return writer, nil
+}
+
+// counters returns the byte counters of key, creating them on first use. Callers hold
+// p.mu.
+func (p *Pool) counters(key writerKey) *writerBytes {
+	b, ok := p.bytes[key]
+	if !ok {
+		b = &writerBytes{}
+		p.bytes[key] = b
+	}
+	return b
+}
+
+// countingConn counts the bytes a writer sends to a broker, after compression and TLS.
+type countingConn struct {
+	net.Conn
+	sent *atomic.Int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+// Topic returns a MessageWriter for topic that resolves the pooled writer on every write,
+// so a failure to ensure the topic exists is retried like any other write failure.
+func (p *Pool) Topic(topic string) MessageWriter {
+	if p.topics != nil {
+		return p.topics(topic)
+	}
+	return topicWriter{pool: p, key: writerKey{topic: topic}}
+}
+
+// PartitionedTopic is Topic with the writer returned by GetPartitioned.
+func (p *Pool) PartitionedTopic(topic, partitioner string) MessageWriter {
+	return p.TopicWith(topic, WriterOptions{Partitioner: partitioner})
+}
+
+// TopicWith is Topic with the writer returned by GetWith.
+func (p *Pool) TopicWith(topic string, opts WriterOptions) MessageWriter {
+	if p.topics != nil {
+		return p.topics(topic)
+	}
+	return topicWriter{pool: p, key: writerKey{topic: topic, WriterOptions: opts.normalized()}}
+}
+
+type topicWriter struct {
+	pool *Pool
+	key  writerKey
+}
+
+func (t topicWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	writer, err := t.pool.get(t.key)
+	if err != nil {
+		return fmt.Errorf("ensure topic %s: %w", t.key.topic, err)
+	}
+	if t.key.Async {
+		t.pool.mu.Lock()
+		q := t.pool.queues[t.key]
+		t.pool.mu.Unlock()
+		if q == nil {
+			return errAsyncClosed
+		}
+		return writeAsync(ctx, q, msgs)
+	}
+	if err := writer.WriteMessages(ctx, msgs...); err != nil {
+		return err
+	}
+	t.pool.mu.Lock()
+	counted := t.pool.counters(t.key)
+	t.pool.mu.Unlock()
+	counted.uncompressed.Add(messagesSize(msgs))
+	return nil
+}
+
+func messagesSize(msgs []kafka.Message) int64 {
+	var n int64
+	for _, msg := range msgs {
+		n += int64(len(msg.Key) + len(msg.Value))
+		for _, h := range msg.Headers {
+			n += int64(len(h.Key) + len(h.Value))
+		}
+	}
+	return n
+}
+
+// Len returns the number of open writers.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.writers)
+}
+
+// Topics returns the destination topics with an open writer.
+func (p *Pool) Topics() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]string, 0, len(p.writers))
+	seen := make(map[string]bool, len(p.writers))
+	for key := range p.writers {
+		if !seen[key.topic] {
+			seen[key.topic] = true
+			out = append(out, key.topic)
+		}
+	}
+	return out
+}
+
+// WriterBytes is what the pooled writers of one topic and compression wrote.
+type WriterBytes struct {
+	Topic string
+	// Compression is none, gzip, snappy, lz4, or zstd.
+	Compression string
+	// Uncompressed is the size of the keys, values, and headers of the messages written.
+	Uncompressed int64
+	// Sent is what the writers sent to the brokers, compressed and including protocol
+	// overhead, metadata requests, and retried writes.
+	Sent int64
+}
+
+// Bytes returns the byte counters of the pooled writers, sorted by topic and compression.
+func (p *Pool) Bytes() []WriterBytes {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sums := make(map[[2]string]*WriterBytes, len(p.bytes))
+	for key, b := range p.bytes {
+		compression := cmp.Or(key.Compression, CompressionNone)
+		sum, ok := sums[[2]string{key.topic, compression}]
+		if !ok {
+			sum = &WriterBytes{Topic: key.topic, Compression: compression}
+			sums[[2]string{key.topic, compression}] = sum
+		}
+		sum.Uncompressed += b.uncompressed.Load()
+		sum.Sent += b.sent.Load()
+	}
+	out := make([]WriterBytes, 0, len(sums))
+	for _, sum := range sums {
+		out = append(out, *sum)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Topic != out[j].Topic {
+			return out[i].Topic < out[j].Topic
+		}
+		return out[i].Compression < out[j].Compression
+	})
+	return out
+}
+
+// TopicStats aggregates the kafka-go statistics of the writers of one topic since the pool
+// was created, including writers since closed as idle.
+type TopicStats struct {
+	Topic string
+	// Writers counts the open writers of the topic, one per set of WriterOptions.
+	Writers  int
+	Writes   int64
+	Messages int64
+	// Bytes is the size of the messages written, as kafka-go counts it.
+	Bytes   int64
+	Errors  int64
+	Retries int64
+	// Evicted counts the writers of the topic EvictIdle closed.
+	Evicted int64
+	// LastUsed is when a writer of the topic was last handed out.
+	LastUsed time.Time
+}
+
+// Stats returns the statistics of every topic the pool has written to, sorted by topic.
+func (p *Pool) Stats() []TopicStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, writer := range p.writers {
+		p.accumulateLocked(key.topic, writer)
+	}
+	out := make([]TopicStats, 0, len(p.totals))
+	for topic, total := range p.totals {
+		stats := *total
+		stats.Writers = 0
+		for key := range p.writers {
+			if key.topic == topic {
+				stats.Writers++
+				if used := p.lastUsed[key]; used.After(stats.LastUsed) {
+					stats.LastUsed = used
+				}
+			}
+		}
+		out = append(out, stats)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Topic < out[j].Topic })
+	return out
+}
+
+// accumulateLocked adds what writer did since its statistics were last read to the
+// totals of topic. Callers hold p.mu.
+func (p *Pool) accumulateLocked(topic string, writer *kafka.Writer) *TopicStats {
+	total, ok := p.totals[topic]
+	if !ok {
+		total = &TopicStats{Topic: topic}
+		p.totals[topic] = total
+	}
+	s := writer.Stats()
+	total.Writes += s.Writes
+	total.Messages += s.Messages
+	total.Bytes += s.Bytes
+	total.Errors += s.Errors
+	total.Retries += s.Retries
+	return total
+}
+
+// EvictIdle closes the writers that have not been used for the pool's idle timeout, once
+// they flushed what they hold, and returns their topics. A later write to one of them
+// opens a new writer. Without WithIdleTimeout it closes nothing.
+func (p *Pool) EvictIdle(now time.Time) ([]string, error) {
+	if p.idleTimeout <= 0 {
+		return nil, nil
+	}
+	p.mu.Lock()
+	idle := make(map[writerKey]*kafka.Writer)
+	queues := make(map[writerKey]*asyncQueue)
+	lastUsed := make(map[writerKey]time.Time)
+	for key, writer := range p.writers {
+		if now.Sub(p.lastUsed[key]) < p.idleTimeout {
+			continue
+		}
+		idle[key], lastUsed[key] = writer, p.lastUsed[key]
+		if q, ok := p.queues[key]; ok {
+			queues[key] = q
+		}
+		delete(p.writers, key)
+		delete(p.queues, key)
+		delete(p.lastUsed, key)
+	}
+	p.mu.Unlock()
+
+	var topics []string
+	var firstErr error
+	for key, writer := range idle {
+		if q := queues[key]; q != nil {
+			q.close()
+		}
+		if err := writer.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("close writer %s: %w", key.topic, err)
+		}
+		// closing flushed the writer, so its last statistics are final
+		p.mu.Lock()
+		total := p.accumulateLocked(key.topic, writer)
+		total.Evicted++
+		if lastUsed[key].After(total.LastUsed) {
+			total.LastUsed = lastUsed[key]
+		}
+		p.mu.Unlock()
+		topics = append(topics, key.topic)
+	}
+	sort.Strings(topics)
+	return topics, firstErr
+}
+
+// Close flushes and closes all managed writers.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for key, writer := range p.writers {
+		if q := p.queues[key]; q != nil {
+			q.close()
+		}
+		if err := writer.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("close writer %s: %w", key.topic, err)
+		}
+	}
+	return firstErr
+}
+
+// Retry schedule of EnsureTopic for transient errors; variables so tests can shorten it.
+var (
+	ensureAttempts = 5
+	ensureBackoff  = 200 * time.Millisecond
+)
+
+// EnsureTopic creates the topic described by topicCfg through the cluster controller.
+// A topic that already exists is left unchanged and is not an error, nor is one the
+// bridge may not create but that exists. Transient failures, such as an unreachable
+// broker or a controller moving, are retried with backoff.
+func EnsureTopic(brokers []string, dialer *kafka.Dialer, topicCfg kafka.TopicConfig) error {
+	if len(brokers) == 0 {
+		return fmt.Errorf("no brokers configured")
+	}
+	backoff := ensureBackoff
+	for attempt := 1; ; attempt++ {
+		err := createTopic(brokers, dialer, topicCfg)
+		if err == nil {
+			return nil
+		}
+		if !transientError(err) || attempt == ensureAttempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// transientError reports whether a failure to ensure a topic may pass on its own: any
+// transport failure, and the Kafka errors kafka-go marks temporary.
+func transientError(err error) bool {
+	var kerr kafka.Error
+	if errors.As(err, &kerr) {
+		return kerr.Temporary()
+	}
+	return true
+}
+
+func createTopic(brokers []string, dialer *kafka.Dialer, topicCfg kafka.TopicConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		return fmt.Errorf("dial broker: %w", err)
+	}
+	defer conn.Close()
+
+	ctrl, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("get controller: %w", err)
+	}
+
+	ctrlAddr := net.JoinHostPort(ctrl.Host, strconv.Itoa(ctrl.Port))
+	ctrlConn, err := dialer.DialContext(ctx, "tcp", ctrlAddr)
+	if err != nil {
+		return fmt.Errorf("dial controller: %w", err)
+	}
+	defer ctrlConn.Close()
+
+	err = ctrlConn.CreateTopics(topicCfg)
+	switch {
+	case err == nil, errors.Is(err, kafka.TopicAlreadyExists):
+		return nil
+	case errors.Is(err, kafka.TopicAuthorizationFailed), errors.Is(err, kafka.ClusterAuthorizationFailed):
+		// a bridge allowed to write to a topic but not to create topics can use it as is
+		if partitions, lookupErr := conn.ReadPartitions(topicCfg.Topic); lookupErr == nil && len(partitions) > 0 {
+			return nil
+		}
+		return fmt.Errorf("create topic %s: not authorized to create it and it does not exist: %w", topicCfg.Topic, err)
+	}
+	return fmt.Errorf("create topic %s: %w", topicCfg.Topic, err)
+}
@@ -0,0 +1,121 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestCodec(t *testing.T) {
+	for name, want := range map[string]kafka.Compression{"": 0, CompressionNone: 0, CompressionGzip: kafka.Gzip, CompressionSnappy: kafka.Snappy, CompressionLz4: kafka.Lz4, CompressionZstd: kafka.Zstd} {
+		if got, err := codec(name); err != nil || got != want {
+			t.Fatalf("codec(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := codec("brotli"); err == nil {
+		t.Fatal("expected an unknown codec to fail")
+	}
+}
+
+func TestPoolBytes(t *testing.T) {
+	p := NewPool(nil, nil)
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	gzipped := p.counters(writerKey{topic: "orders", WriterOptions: WriterOptions{Partitioner: "key", Compression: CompressionGzip}})
+	conn := countingConn{Conn: client, sent: &gzipped.sent}
+	if _, err := conn.Write(make([]byte, 40)); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	gzipped.uncompressed.Add(100)
+	p.counters(writerKey{topic: "orders", WriterOptions: WriterOptions{Compression: CompressionGzip, Async: true}}).uncompressed.Add(50)
+	p.counters(writerKey{topic: "audit"}).uncompressed.Add(7)
+
+	got := p.Bytes()
+	want := []WriterBytes{
+		{Topic: "audit", Compression: CompressionNone, Uncompressed: 7},
+		{Topic: "orders", Compression: CompressionGzip, Uncompressed: 150, Sent: 40},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Bytes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPoolEvictIdle(t *testing.T) {
+	p := NewPool(nil, nil, WithIdleTimeout(time.Minute))
+	now := time.Now()
+	busy, idle := writerKey{topic: "orders"}, writerKey{topic: "retired"}
+	p.writers[busy] = &kafka.Writer{Addr: kafka.TCP("bridge:9092"), Topic: "orders"}
+	p.writers[idle] = &kafka.Writer{Addr: kafka.TCP("bridge:9092"), Topic: "retired"}
+	p.lastUsed[busy] = now
+	p.lastUsed[idle] = now.Add(-2 * time.Minute)
+
+	topics, err := p.EvictIdle(now)
+	if err != nil || len(topics) != 1 || topics[0] != "retired" {
+		t.Fatalf("EvictIdle = %v, %v; want [retired]", topics, err)
+	}
+	if p.Len() != 1 {
+		t.Fatalf("expected the busy writer kept, %d open", p.Len())
+	}
+	stats := p.Stats()
+	if len(stats) != 2 || stats[0].Topic != "orders" || stats[0].Writers != 1 || stats[1].Topic != "retired" || stats[1].Writers != 0 || stats[1].Evicted != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if !stats[1].LastUsed.Equal(now.Add(-2 * time.Minute)) {
+		t.Fatalf("expected the evicted writer's last use kept, got %v", stats[1].LastUsed)
+	}
+
+	if topics, _ := NewPool(nil, nil).EvictIdle(now.Add(time.Hour)); topics != nil {
+		t.Fatal("expected a pool without an idle timeout to keep its writers")
+	}
+}
+
+func TestEnsureTopicRetriesTransientErrors(t *testing.T) {
+	attempts, backoff := ensureAttempts, ensureBackoff
+	ensureAttempts, ensureBackoff = 3, time.Millisecond
+	defer func() { ensureAttempts, ensureBackoff = attempts, backoff }()
+
+	var dials int
+	dialer := &kafka.Dialer{DialFunc: func(context.Context, string, string) (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	}}
+	if err := EnsureTopic([]string{"bridge:9092"}, dialer, kafka.TopicConfig{Topic: "orders"}); err == nil {
+		t.Fatal("expected an unreachable cluster to fail")
+	}
+	if dials != 3 {
+		t.Fatalf("expected 3 attempts, got %d", dials)
+	}
+
+	p := NewPool([]string{"bridge:9092"}, dialer)
+	defer p.Close()
+	p.ensured["orders"] = true
+	dials = 0
+	if _, err := p.get(writerKey{topic: "orders"}); err != nil || dials != 0 {
+		t.Fatalf("expected a topic the pool ensured not to be checked again, got %v after %d dial(s)", err, dials)
+	}
+	if _, err := NewPool([]string{"bridge:9092"}, dialer).get(writerKey{topic: "orders"}); err == nil || dials != 3 {
+		t.Fatalf("expected another pool to ensure the topic itself, got %v after %d dial(s)", err, dials)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	for err, want := range map[error]bool{
+		fmt.Errorf("create topic x: %w", kafka.TopicAuthorizationFailed): false,
+		fmt.Errorf("create topic x: %w", kafka.InvalidReplicationFactor): false,
+		fmt.Errorf("create topic x: %w", kafka.NotController):            true,
+		fmt.Errorf("dial broker: %w", io.ErrUnexpectedEOF):               true,
+	} {
+		if got := transientError(err); got != want {
+			t.Fatalf("transientError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultWebhookTimeout bounds each webhook request unless WithHTTPClient says otherwise.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// WebhookRecord is the JSON form of one message in a webhook request body, which is an
+// array of records. Values that are valid JSON are embedded as they are; any other value
+// is sent base64 encoded in ValueBase64.
+type WebhookRecord struct {
+	Partition   int               `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Time        time.Time         `json:"timestamp"`
+	Key         string            `json:"key,omitempty"`
+	Value       json.RawMessage   `json:"value,omitempty"`
+	ValueBase64 []byte            `json:"valueBase64,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// Webhook is a MessageWriter that POSTs messages to an HTTP endpoint. A response outside
+// 2xx fails the request's messages, so Deliver retries them.
+type Webhook struct {
+	url       string
+	client    *http.Client
+	headers   map[string]string
+	batchSize int
+}
+
+// WebhookOption configures a Webhook built by NewWebhook.
+type WebhookOption func(*Webhook)
+
+// WithHeaders sends headers, such as Authorization, with every request.
+func WithHeaders(headers map[string]string) WebhookOption {
+	return func(w *Webhook) { w.headers = headers }
+}
+
+// WithHTTPClient sends requests with client instead of one with DefaultWebhookTimeout.
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(w *Webhook) { w.client = client }
+}
+
+// WithBatchSize caps the messages sent per request; writes of more messages are split.
+func WithBatchSize(n int) WebhookOption {
+	return func(w *Webhook) {
+		if n > 0 {
+			w.batchSize = n
+		}
+	}
+}
+
+// NewWebhook builds a writer that POSTs to url, one message per request by default.
+func NewWebhook(url string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		url:       url,
+		client:    &http.Client{Timeout: DefaultWebhookTimeout},
+		batchSize: 1,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// WriteMessages posts msgs in order, batchSize at a time. When a request fails after
+// earlier ones succeeded, the returned kafka.WriteErrors marks only the unsent messages,
+// so Deliver does not post the delivered ones twice.
+func (w *Webhook) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for start := 0; start < len(msgs); start += w.batchSize {
+		end := min(start+w.batchSize, len(msgs))
+		if err := w.post(ctx, msgs[start:end]); err != nil {
+			if start == 0 {
+				return err
+			}
+			errs := make(kafka.WriteErrors, len(msgs))
+			for i := start; i < len(msgs); i++ {
+				errs[i] = err
+			}
+			return errs
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) post(ctx context.Context, msgs []kafka.Message) error {
+	records := make([]WebhookRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		records = append(records, webhookRecord(msg))
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("encode webhook body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func webhookRecord(msg kafka.Message) WebhookRecord {
+	rec := WebhookRecord{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Time:      msg.Time,
+		Key:       string(msg.Key),
+	}
+	if json.Valid(msg.Value) {
+		rec.Value = msg.Value
+	} else {
+		rec.ValueBase64 = msg.Value
+	}
+	if len(msg.Headers) > 0 {
+		rec.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			rec.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return rec
+}
@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestWebhookBatchesAndRetries(t *testing.T) {
+	var requests int
+	var delivered []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if requests == 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var records []WebhookRecord
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		for _, rec := range records {
+			if rec.Value != nil {
+				delivered = append(delivered, string(rec.Value))
+			} else {
+				delivered = append(delivered, string(rec.ValueBase64))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	hook := NewWebhook(srv.URL, WithBatchSize(2), WithHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	msgs := []kafka.Message{{Value: []byte(`{"n":1}`)}, {Value: []byte(`{"n":2}`)}, {Value: []byte("plain")}}
+	if err := Deliver(context.Background(), hook, RetryPolicy{InitialBackoff: 1}, msgs...); err != nil {
+		t.Fatalf("Deliver error: %v", err)
+	}
+	// the second request (plain) failed and was retried alone
+	want := []string{`{"n":1}`, `{"n":2}`, "plain"}
+	if requests != 3 || !reflect.DeepEqual(delivered, want) {
+		t.Fatalf("requests=%d delivered=%q, want 3 requests delivering %q", requests, delivered, want)
+	}
+}
@@ -0,0 +1,49 @@
+package engine
+
+import "kafka-bridge/pkg/store"
+
+// Match strategies accepted by SetMatchStrategy.
+const (
+	// MatchStrategyFields decodes source payloads and looks up each field value.
+	MatchStrategyFields = "fields"
+	// MatchStrategyBytesContains searches the raw source payload for cached values
+	// without decoding it. Any occurrence matches, including inside a longer value, and
+	// values spelled differently in the payload, such as JSON-escaped ones, do not.
+	MatchStrategyBytesContains = "bytesContains"
+)
+
+// SetMatchStrategy selects how source payloads are matched: MatchStrategyFields (default)
+// or MatchStrategyBytesContains. Under bytesContains payloads are only decompressed, so
+// the source format, event filter, time window, and schema tracking do not apply, and
+// the route's values are indexed in an Aho-Corasick automaton kept by the store.
+func (m *Matcher) SetMatchStrategy(strategy string) {
+	m.bytesContains = strategy == MatchStrategyBytesContains
+	if m.bytesContains {
+		m.store.IndexSubstrings(m.routeID, yearVariants)
+	}
+}
+
+// WithMatchStrategy is the construction-time form of SetMatchStrategy.
+func WithMatchStrategy(strategy string) Option {
+	return func(m *Matcher) { m.SetMatchStrategy(strategy) }
+}
+
+// scanBytes reports the cached values found in the raw payload, each by the first variant
+// of it found. Matches have no field.
+func (m *Matcher) scanBytes(payload []byte, first bool) ([]Match, error) {
+	payload, err := m.Decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+	matches := []Match{}
+	seen := make(map[string]struct{})
+	m.store.ScanSubstrings(m.routeID, payload, func(fingerprint, variant string, origin store.Metadata) bool {
+		if _, dup := seen[fingerprint]; dup {
+			return true
+		}
+		seen[fingerprint] = struct{}{}
+		matches = append(matches, Match{Value: variant, Fingerprint: fingerprint, Origin: origin})
+		return !first
+	})
+	return matches, nil
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kinds of value accepted by Feed.Canonicalize.
+const (
+	// CanonicalNumbers writes decimal numbers without exponent, leading zeros, or
+	// trailing fraction zeros: 1.0, "1", and 1e0 all become 1.
+	CanonicalNumbers = "numbers"
+	// CanonicalBooleans lowercases true and false in any case.
+	CanonicalBooleans = "booleans"
+	// CanonicalTimestamps writes RFC 3339 timestamps, and those in the feed's
+	// CanonicalTimeLayouts, in UTC with as many fraction digits as they need.
+	CanonicalTimestamps = "timestamps"
+)
+
+// canonicalizer formats the values of the kinds a feed canonicalizes one way, so a value
+// spelled differently by the reference feed and the source has one fingerprint.
+type canonicalizer struct {
+	numbers, booleans, timestamps bool
+	layouts                       []string
+}
+
+func newCanonicalizer(kinds, layouts []string) (canonicalizer, error) {
+	c := canonicalizer{layouts: append([]string(nil), layouts...)}
+	for _, kind := range kinds {
+		switch kind {
+		case CanonicalNumbers:
+			c.numbers = true
+		case CanonicalBooleans:
+			c.booleans = true
+		case CanonicalTimestamps:
+			c.timestamps = true
+		default:
+			return canonicalizer{}, fmt.Errorf("unknown canonicalize kind %q (want numbers, booleans, or timestamps)", kind)
+		}
+	}
+	return c, nil
+}
+
+func (c canonicalizer) enabled() bool {
+	return c.numbers || c.booleans || c.timestamps
+}
+
+// key identifies the formatting c applies, so matchers probe each distinct one once.
+func (c canonicalizer) key() string {
+	return fmt.Sprint(c.numbers, c.booleans, c.timestamps, c.layouts)
+}
+
+// format returns the canonical form of v, or v when it is of no kind c canonicalizes.
+func (c canonicalizer) format(v string) string {
+	if c.numbers {
+		if n, ok := canonicalNumber(v); ok {
+			return n
+		}
+	}
+	if c.booleans {
+		switch {
+		case strings.EqualFold(v, "true"):
+			return "true"
+		case strings.EqualFold(v, "false"):
+			return "false"
+		}
+	}
+	if c.timestamps {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+		for _, layout := range c.layouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return v
+}
+
+// decimalNumber is the number syntax canonicalized; the exponent is bounded so a short
+// value cannot expand into a huge one.
+var decimalNumber = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,3})?$`)
+
+// canonicalNumber writes the decimal number v exactly, however many digits it has, so
+// identifiers beyond float64 precision keep their value.
+func canonicalNumber(v string) (string, bool) {
+	if len(v) > 64 || !decimalNumber.MatchString(v) {
+		return "", false
+	}
+	r, ok := new(big.Rat).SetString(v)
+	if !ok {
+		return "", false
+	}
+	if r.IsInt() {
+		return r.Num().String(), true
+	}
+	// a decimal has as many fraction digits as the larger power of 2 or 5 dividing its
+	// denominator
+	d := new(big.Int).Set(r.Denom())
+	twos := d.TrailingZeroBits()
+	d.Rsh(d, twos)
+	fives := uint(0)
+	five, rem := big.NewInt(5), new(big.Int)
+	for d.Cmp(big.NewInt(1)) > 0 {
+		d.QuoRem(d, five, rem)
+		fives++
+	}
+	return r.FloatString(int(max(twos, fives))), true
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// cloudEventsKey holds the attributes of a decoded CloudEvent, so match fields and source
+// scanning address them as ce.type, ce.source, ce.subject, or ce.<extension>.
+const cloudEventsKey = "ce"
+
+// decodeCloudEvent decodes a structured-mode JSON CloudEvent into its data, with the
+// event's attributes under "ce". Data carried as a JSON string or as data_base64 is
+// decoded when datacontenttype is JSON (or absent); other data is exposed as data.
+func (m *Matcher) decodeCloudEvent(payload []byte) (map[string]any, error) {
+	var event map[string]any
+	if err := m.decode(payload, &event); err != nil {
+		return nil, err
+	}
+	if _, ok := event["specversion"].(string); !ok {
+		return nil, errors.New("cloudevent: specversion attribute missing")
+	}
+	attrs := make(map[string]any, len(event))
+	for k, v := range event {
+		if k != "data" && k != "data_base64" {
+			attrs[k] = v
+		}
+	}
+	contentType, _ := event["datacontenttype"].(string)
+
+	var data any
+	switch {
+	case event["data_base64"] != nil:
+		encoded, ok := event["data_base64"].(string)
+		if !ok {
+			return nil, errors.New("cloudevent: data_base64 is not a string")
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("cloudevent: data_base64: %w", err)
+		}
+		data = string(raw)
+	default:
+		data = event["data"]
+	}
+	if s, ok := data.(string); ok && jsonContentType(contentType) {
+		var decoded any
+		if err := m.decode([]byte(s), &decoded); err == nil {
+			data = decoded
+		}
+	}
+
+	body, ok := data.(map[string]any)
+	if !ok {
+		body = make(map[string]any, 2)
+		if data != nil {
+			body["data"] = data
+		}
+	}
+	body[cloudEventsKey] = attrs
+	return body, nil
+}
+
+// jsonContentType reports whether a datacontenttype denotes JSON; CloudEvents treats an
+// absent content type as JSON.
+func jsonContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/json"
+}
+
+// SetEventFilter restricts a cloudevents route to events whose attributes match every
+// entry of filter. Patterns match exactly or, with a trailing *, by prefix; a missing
+// attribute never matches.
+func (m *Matcher) SetEventFilter(filter map[string]string) {
+	m.eventFilter = filter
+}
+
+// admitsEvent reports whether a decoded source body passes the event filter.
+func (m *Matcher) admitsEvent(body any) bool {
+	if len(m.eventFilter) == 0 {
+		return true
+	}
+	root, _ := body.(map[string]any)
+	attrs, _ := root[cloudEventsKey].(map[string]any)
+	for name, pattern := range m.eventFilter {
+		v, ok := attrs[name]
+		if !ok {
+			return false
+		}
+		value := fmt.Sprintf("%v", v)
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+			if !strings.HasPrefix(value, prefix) {
+				return false
+			}
+		} else if value != pattern {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic         = []byte{0x1f, 0x8b}
+	zstdMagic         = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	snappyStreamMagic = []byte("\xff\x06\x00\x00sNaPpY")
+)
+
+// SetPayloadCompression makes the matcher decompress source payloads before decoding
+// them. Payloads without the codec's framing are matched as they are, so a topic that
+// mixes compressed and plain producers still matches. maxBytes bounds the decompressed
+// size; zero leaves it unbounded.
+func (m *Matcher) SetPayloadCompression(compression string, maxBytes int) {
+	m.compression = compression
+	m.maxDecompressed = maxBytes
+}
+
+// Decompress returns payload decompressed per SetPayloadCompression, or payload itself
+// when no compression is set or the payload is not compressed.
+func (m *Matcher) Decompress(payload []byte) ([]byte, error) {
+	var r io.Reader
+	switch codec := m.codecFor(payload); codec {
+	case "":
+		return payload, nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("gzip payload: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(payload), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd payload: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case CompressionSnappy:
+		if !bytes.HasPrefix(payload, snappyStreamMagic) {
+			return m.snappyBlock(payload)
+		}
+		r = snappy.NewReader(bytes.NewReader(payload))
+	}
+
+	if m.maxDecompressed > 0 {
+		r = io.LimitReader(r, int64(m.maxDecompressed)+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s payload: %w", m.codecFor(payload), err)
+	}
+	if m.maxDecompressed > 0 && len(out) > m.maxDecompressed {
+		return nil, fmt.Errorf("%w: decompressed payload over %d bytes", ErrLimitExceeded, m.maxDecompressed)
+	}
+	return out, nil
+}
+
+// codecFor picks the codec payload is framed with, or "" to use it as it is. Unframed
+// snappy blocks cannot be recognised, so under snappy anything but a JSON or XML document
+// is treated as one.
+func (m *Matcher) codecFor(payload []byte) string {
+	switch m.compression {
+	case "", CompressionNone:
+		return ""
+	case CompressionSnappy:
+		if looksPlain(payload) {
+			return ""
+		}
+		return CompressionSnappy
+	}
+	switch {
+	case bytes.HasPrefix(payload, gzipMagic) && m.accepts(CompressionGzip):
+		return CompressionGzip
+	case bytes.HasPrefix(payload, zstdMagic) && m.accepts(CompressionZstd):
+		return CompressionZstd
+	case bytes.HasPrefix(payload, snappyStreamMagic) && m.compression == CompressionAuto:
+		return CompressionSnappy
+	}
+	return ""
+}
+
+func (m *Matcher) accepts(codec string) bool {
+	return m.compression == codec || m.compression == CompressionAuto
+}
+
+func (m *Matcher) snappyBlock(payload []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(payload)
+	if err != nil {
+		return nil, fmt.Errorf("snappy payload: %w", err)
+	}
+	if m.maxDecompressed > 0 && n > m.maxDecompressed {
+		return nil, fmt.Errorf("%w: decompressed payload over %d bytes", ErrLimitExceeded, m.maxDecompressed)
+	}
+	out, err := snappy.Decode(nil, payload)
+	if err != nil {
+		return nil, fmt.Errorf("snappy payload: %w", err)
+	}
+	return out, nil
+}
+
+// looksPlain reports whether the first non-space byte opens a JSON object or array or an
+// XML element.
+func looksPlain(payload []byte) bool {
+	trimmed := bytes.TrimLeft(payload, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[' || trimmed[0] == '<')
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// decodeCSV reads a CSV reference payload as one record per line. A record holds each
+// field under its zero-based column index ("0", "1", ...) and, when the feed names its
+// columns through a header line or CSVColumns, under that name too, so match fields can
+// use either. Blank lines are skipped. The decode limits bound the fields, counted as
+// nodes, and their length.
+func decodeCSV(data []byte, feed feedMatcher, limits DecodeLimits) ([]map[string]any, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	if feed.csvDelimiter != 0 {
+		r.Comma = feed.csvDelimiter
+	}
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	columns := feed.csvColumns
+	needHeader := feed.csvHeader
+	var records []map[string]any
+	nodes := 0
+	for {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		nodes += len(fields)
+		if limits.MaxNodes > 0 && nodes > limits.MaxNodes {
+			return nil, fmt.Errorf("%w: more than %d nodes", ErrLimitExceeded, limits.MaxNodes)
+		}
+		for _, f := range fields {
+			if limits.MaxStringLength > 0 && len(f) > limits.MaxStringLength {
+				return nil, fmt.Errorf("%w: string of %d bytes (max %d)", ErrLimitExceeded, len(f), limits.MaxStringLength)
+			}
+		}
+		if needHeader {
+			columns = append([]string(nil), fields...)
+			needHeader = false
+			continue
+		}
+		record := make(map[string]any, 2*len(fields))
+		for i, f := range fields {
+			record[strconv.Itoa(i)] = f
+			if i < len(columns) && columns[i] != "" {
+				record[columns[i]] = f
+			}
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv: payload has no records")
+	}
+	return records, nil
+}
+
+// csvDelimiter returns the rune of a validated csvDelimiter; zero keeps the comma.
+func csvDelimiter(s string) rune {
+	if s == `\t` {
+		return '\t'
+	}
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+// Package engine matches source payloads against values cached from reference feeds.
+//
+// A Matcher is built per route with NewMatcher. ProcessReference feeds it reference
+// records, whose match fields are stored in a store.MatchStore under the route's ID, and
+// FirstMatch, Matches, or Evaluate decide whether a source payload carries a cached
+// value. Payloads are JSON by default; options select XML or CloudEvents decoding,
+// decompression, and decode limits.
+package engine
@@ -0,0 +1,758 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"kafka-bridge/pkg/schema"
+	"kafka-bridge/pkg/store"
+)
+
+// deleteActionField is the top-level reference field that, when set to "delete", removes
+// the extracted values instead of adding them.
+const deleteActionField = "action"
+
+// Matcher coordinates reference caching and source matching per route.
+type Matcher struct {
+	routeID string
+	feeds   []feedMatcher
+	store   *store.MatchStore
+	keys    *keyIndex
+	limits  DecodeLimits
+
+	compression     string
+	maxDecompressed int
+	// format is the source payload format, json unless SetSourceFormat says otherwise.
+	format      string
+	eventFilter map[string]string
+	window      timeWindow
+	// bytesContains selects MatchStrategyBytesContains; prefilter is set by SetPrefilter.
+	bytesContains bool
+	prefilter     bool
+	// legacyDecode matches every json payload by decoding it into a tree.
+	legacyDecode bool
+	// canonical are the distinct formattings of the feeds that canonicalize values.
+	canonical []canonicalizer
+	// hash, set by SetValueHash, turns values into the fingerprints cached for them.
+	hash       func(string) string
+	hashPrefix string
+
+	schema      *schema.Tracker
+	sourceTopic string
+	sampleEvery uint64
+	sourceSeen  atomic.Uint64
+}
+
+type feedMatcher struct {
+	name           string
+	topic          string
+	topicHeaders   map[string]string
+	fields         []string
+	format         string
+	timestampField string
+	recordsPath    string
+	source         string
+	csvHeader      bool
+	csvColumns     []string
+	csvDelimiter   rune
+	canonical      canonicalizer
+}
+
+// ReferenceMessage is a single record consumed from a reference feed.
+type ReferenceMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Headers   map[string]string
+	// Value is the JSON payload; an empty value is a tombstone for Key.
+	Value []byte
+}
+
+// ReferenceUpdate summarises how a reference record changed the cache.
+type ReferenceUpdate struct {
+	Feed    string
+	Added   bool
+	Removed bool
+	// Stored and Dropped list the values the record added to and removed from the cache.
+	Stored  []string
+	Dropped []string
+}
+
+// NewMatcher constructs a matcher for a specific route whose reference values are cached
+// in store under routeID.
+func NewMatcher(routeID string, feeds []Feed, store *store.MatchStore, opts ...Option) (*Matcher, error) {
+	var feedMatchers []feedMatcher
+	var canonical []canonicalizer
+	for _, f := range feeds {
+		hdrs, err := parseTopicHeaders(f.TopicHeaders)
+		if err != nil {
+			return nil, err
+		}
+		canon, err := newCanonicalizer(f.Canonicalize, f.CanonicalTimeLayouts)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", f.DisplayName(), err)
+		}
+		if canon.enabled() && !slices.ContainsFunc(canonical, func(c canonicalizer) bool { return c.key() == canon.key() }) {
+			canonical = append(canonical, canon)
+		}
+		feedMatchers = append(feedMatchers, feedMatcher{
+			name:           f.DisplayName(),
+			topic:          f.Topic,
+			topicHeaders:   hdrs,
+			fields:         append([]string(nil), f.MatchFields...),
+			format:         f.PayloadFormat,
+			timestampField: f.TimestampField,
+			recordsPath:    f.RecordsPath,
+			source:         f.MatchSource,
+			csvHeader:      f.CSVHeader,
+			csvColumns:     append([]string(nil), f.CSVColumns...),
+			csvDelimiter:   csvDelimiter(f.CSVDelimiter),
+			canonical:      canon,
+		})
+	}
+	m := &Matcher{
+		routeID:   routeID,
+		feeds:     feedMatchers,
+		store:     store,
+		keys:      newKeyIndex(),
+		canonical: canonical,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// TrackSchema reports every reference payload and every sampleEvery-th source payload
+// from sourceTopic to tracker, and registers the feeds' matchFields as expected fields.
+func (m *Matcher) TrackSchema(tracker *schema.Tracker, sourceTopic string, sampleEvery int) {
+	if sampleEvery <= 0 {
+		sampleEvery = 1
+	}
+	m.schema = tracker
+	m.sourceTopic = sourceTopic
+	m.sampleEvery = uint64(sampleEvery)
+	for _, f := range m.feeds {
+		tracker.Expect(f.topic, f.fields)
+	}
+}
+
+func (m *Matcher) observeSource(body any) {
+	if m.schema == nil {
+		return
+	}
+	if m.sourceSeen.Add(1)%m.sampleEvery != 0 {
+		return
+	}
+	m.schema.Observe(m.sourceTopic, schema.RoleSource, body)
+}
+
+// ProcessReference ingests a reference record from a specific topic/headers and stores each
+// extracted value. Keyed tombstones remove the values previously stored for that key, and
+// payloads with `"action": "delete"` remove the values they carry. A payload that is an
+// array, or holds one at the feed's RecordsPath, is processed one record per element.
+func (m *Matcher) ProcessReference(msg ReferenceMessage) (ReferenceUpdate, error) {
+	feed, ok := m.feedFor(msg.Topic, msg.Headers)
+	if !ok {
+		return ReferenceUpdate{}, fmt.Errorf("no match fields configured for topic %s with provided headers", msg.Topic)
+	}
+	update := ReferenceUpdate{Feed: feed.name}
+
+	if len(msg.Value) == 0 {
+		if len(msg.Key) == 0 {
+			return update, errors.New("tombstone without key ignored")
+		}
+		for _, v := range m.keys.drop(feed.name, string(msg.Key)) {
+			if m.store.Remove(m.routeID, v) {
+				update.Removed = true
+				update.Dropped = append(update.Dropped, v)
+			}
+		}
+		return update, nil
+	}
+
+	records, err := m.referenceRecords(feed, msg)
+	if err != nil {
+		return update, err
+	}
+	for _, rec := range records {
+		for i, v := range rec.values {
+			if feed.canonical.enabled() {
+				v = feed.canonical.format(v)
+			}
+			rec.values[i] = m.Fingerprint(v)
+		}
+	}
+	var kept []string
+	for _, rec := range records {
+		if !rec.deleted {
+			kept = append(kept, rec.values...)
+		}
+	}
+
+	if len(msg.Key) > 0 && len(kept) > 0 {
+		for _, v := range m.keys.replace(feed.name, string(msg.Key), kept) {
+			if m.store.Remove(m.routeID, v) {
+				update.Removed = true
+				update.Dropped = append(update.Dropped, v)
+			}
+		}
+	}
+	for _, rec := range records {
+		if rec.deleted {
+			for _, v := range rec.values {
+				m.keys.forget(v)
+				if m.store.Remove(m.routeID, v) {
+					update.Removed = true
+					update.Dropped = append(update.Dropped, v)
+				}
+			}
+			continue
+		}
+		meta := store.Metadata{
+			Source:    store.SourceKafka,
+			Feed:      feed.name,
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			EventTime: rec.eventTime,
+			Key:       string(msg.Key),
+		}
+		if feed.timestampField != "" {
+			// the store keeps the first provenance, so a new event time replaces the value
+			for _, v := range rec.values {
+				if prev, ok := m.store.Lookup(m.routeID, v); ok && !prev.EventTime.Equal(meta.EventTime) {
+					m.store.Remove(m.routeID, v)
+				}
+			}
+		}
+		if stored := m.store.AddAllWithMeta(m.routeID, rec.values, meta); len(stored) > 0 {
+			update.Added = true
+			update.Stored = append(update.Stored, stored...)
+		}
+	}
+	return update, nil
+}
+
+// RestoreKeys rebuilds the index of record keys from the provenance of the route's cached
+// values, so tombstones and updates for records consumed before a restart still remove
+// their values. A value carried by several records is recorded under the first only. It
+// returns how many keys were restored.
+func (m *Matcher) RestoreKeys() int {
+	feeds := make(map[string]struct{}, len(m.feeds))
+	for _, f := range m.feeds {
+		feeds[f.name] = struct{}{}
+	}
+	byKey := make(map[string][]string)
+	for fp, e := range m.store.Entries(m.routeID) {
+		if e.Meta.Key == "" || e.Canonical != fp {
+			continue
+		}
+		if _, ok := feeds[e.Meta.Feed]; !ok {
+			continue
+		}
+		id := indexKey(e.Meta.Feed, e.Meta.Key)
+		byKey[id] = append(byKey[id], fp)
+	}
+	m.keys.load(byKey)
+	return len(byKey)
+}
+
+// referenceRecord is one record of a reference message: the values it adds or, when it
+// is a delete action, removes.
+type referenceRecord struct {
+	values    []string
+	deleted   bool
+	eventTime time.Time
+}
+
+// referenceRecords extracts the records of a reference message. Every record is checked
+// before any is applied, so an invalid one skips the whole message.
+func (m *Matcher) referenceRecords(feed feedMatcher, msg ReferenceMessage) ([]referenceRecord, error) {
+	if feed.source != "" && feed.source != MatchSourceValue {
+		rec, err := m.metadataRecord(feed, msg)
+		if err != nil {
+			return nil, err
+		}
+		return []referenceRecord{rec}, nil
+	}
+	bodies, err := m.decodeReferenceRecords(feed, msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]referenceRecord, 0, len(bodies))
+	for i, body := range bodies {
+		if m.schema != nil {
+			m.schema.Observe(msg.Topic, schema.RoleReference, body)
+		}
+		rec, err := parseReferenceRecord(feed, body)
+		if err != nil {
+			if len(bodies) > 1 {
+				err = fmt.Errorf("record %d: %w", i, err)
+			}
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// metadataRecord reads the value of a feed whose matchSource is the message key or a
+// header. The payload need not be decodable; when it is, it can still carry a delete
+// action and the feed's timestamp field.
+func (m *Matcher) metadataRecord(feed feedMatcher, msg ReferenceMessage) (referenceRecord, error) {
+	var value string
+	if header, ok := strings.CutPrefix(feed.source, MatchSourceHeaderPrefix); ok {
+		v, ok := msg.Headers[strings.ToLower(header)]
+		if !ok || v == "" {
+			return referenceRecord{}, fmt.Errorf("header %s not present", header)
+		}
+		value = v
+	} else {
+		if len(msg.Key) == 0 {
+			return referenceRecord{}, errors.New("message has no key")
+		}
+		value = string(msg.Key)
+	}
+	rec := referenceRecord{values: []string{value}}
+	body, err := m.decodeReference(feed, msg.Value)
+	if err != nil {
+		if feed.timestampField != "" {
+			return referenceRecord{}, fmt.Errorf("timestamp field %s: %w", feed.timestampField, err)
+		}
+		return rec, nil
+	}
+	if m.schema != nil {
+		m.schema.Observe(msg.Topic, schema.RoleReference, body)
+	}
+	rec.deleted = isDeleteAction(body)
+	if feed.format == FormatXML {
+		rec.deleted = isXMLDeleteAction(body)
+	}
+	if !rec.deleted && feed.timestampField != "" {
+		if rec.eventTime, err = referenceTime(body, feed.timestampField); err != nil {
+			return referenceRecord{}, err
+		}
+	}
+	return rec, nil
+}
+
+func parseReferenceRecord(feed feedMatcher, body map[string]any) (referenceRecord, error) {
+	values, err := extractMatchValues(body, feed.fields)
+	if err != nil {
+		return referenceRecord{}, err
+	}
+	rec := referenceRecord{values: values, deleted: isDeleteAction(body)}
+	if feed.format == FormatXML {
+		rec.deleted = isXMLDeleteAction(body)
+	}
+	if !rec.deleted && feed.timestampField != "" {
+		if rec.eventTime, err = referenceTime(body, feed.timestampField); err != nil {
+			return referenceRecord{}, err
+		}
+	}
+	return rec, nil
+}
+
+func isDeleteAction(body map[string]any) bool {
+	action, ok := body[deleteActionField].(string)
+	return ok && strings.EqualFold(action, "delete")
+}
+
+// ShouldForward checks if ANY cached reference value appears anywhere in the payload.
+// Only canonical values are cached, so every variant of each payload value is probed.
+func (m *Matcher) ShouldForward(payload []byte) (bool, error) {
+	_, ok, err := m.FirstMatch(payload)
+	return ok, err
+}
+
+// Match records a payload value that hit a cached reference fingerprint.
+type Match struct {
+	Field       string `json:"field"`
+	Value       string `json:"value"`
+	Fingerprint string `json:"fingerprint"`
+	// Origin records where the cached fingerprint came from.
+	Origin store.Metadata `json:"origin"`
+}
+
+// Result is the full forwarding decision for a payload.
+type Result struct {
+	Forward bool    `json:"forward"`
+	Matches []Match `json:"matches"`
+}
+
+// FirstMatch returns the first payload value (in deterministic field order) that hits the cache.
+func (m *Matcher) FirstMatch(payload []byte) (Match, bool, error) {
+	matches, err := m.match(payload, true, true)
+	if err != nil || len(matches) == 0 {
+		return Match{}, false, err
+	}
+	return matches[0], true, nil
+}
+
+// Matches returns every payload value that hits the cache, recording the payload for
+// schema tracking like FirstMatch.
+func (m *Matcher) Matches(payload []byte) ([]Match, error) {
+	return m.match(payload, false, true)
+}
+
+// Evaluate runs the same decision as ShouldForward but collects every matching
+// fingerprint instead of stopping at the first hit.
+func (m *Matcher) Evaluate(payload []byte) (Result, error) {
+	matches, err := m.match(payload, false, false)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Forward: len(matches) > 0, Matches: matches}, nil
+}
+
+// match finds the payload values that hit the cache, stopping at the first when first is
+// set. observe counts the payload towards schema sampling.
+func (m *Matcher) match(payload []byte, first, observe bool) ([]Match, error) {
+	if m.bytesContains {
+		return m.scanBytes(payload, first)
+	}
+	due := observe && m.schema != nil && (m.sourceSeen.Load()+1)%m.sampleEvery == 0
+	if !due && m.scannable() {
+		if observe && m.schema != nil {
+			m.sourceSeen.Add(1)
+		}
+		return m.scanSource(payload, first)
+	}
+	body, candidate, err := m.decodeCandidate(payload, observe, due)
+	if err != nil {
+		return nil, err
+	}
+	if !candidate || !m.admitsEvent(body) {
+		return []Match{}, nil
+	}
+	if observe {
+		m.observeSource(body)
+	}
+	return m.scan(body, first), nil
+}
+
+// SourceField returns the value of field, a dotted path with optional | fallbacks, in a
+// source payload decoded as FirstMatch decodes it.
+func (m *Matcher) SourceField(payload []byte, field string) (string, error) {
+	body, err := m.decodeSource(payload)
+	if err != nil {
+		return "", err
+	}
+	root, ok := body.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("field %s not found: payload is not an object", field)
+	}
+	val, err := lookupField(root, field)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", val), nil
+}
+
+func (m *Matcher) scan(body any, first bool) []Match {
+	type matchKey struct{ field, value, fingerprint string }
+	matches := []Match{}
+	seen := make(map[matchKey]struct{})
+	var sourceTime time.Time
+	sourceTimed := false
+	if m.window.field != "" {
+		sourceTime, sourceTimed = m.window.sourceTime(body)
+	}
+	// every variant of every field is probed in one store call, so a message takes the
+	// store's lock once
+	var fields []fieldValue
+	var variants []string
+	var owners []int
+	for _, fv := range flattenFields("", body) {
+		for _, variant := range m.probeHashes(m.variants(fv.value)) {
+			variants = append(variants, variant)
+			owners = append(owners, len(fields))
+		}
+		fields = append(fields, fv)
+	}
+	m.store.LookupEach(m.routeID, variants, func(i int, origin store.Metadata) bool {
+		if m.window.field != "" && !origin.EventTime.IsZero() && (!sourceTimed || !m.window.admits(origin.EventTime, sourceTime)) {
+			return true
+		}
+		fv := fields[owners[i]]
+		key := matchKey{fv.path, fv.value, variants[i]}
+		if _, dup := seen[key]; dup {
+			return true
+		}
+		seen[key] = struct{}{}
+		matches = append(matches, Match{Field: fv.path, Value: fv.value, Fingerprint: variants[i], Origin: origin})
+		return !first
+	})
+	return matches
+}
+
+// Size returns the number of cached values for the route.
+func (m *Matcher) Size() int {
+	return m.store.Size(m.routeID)
+}
+
+// AddValues inserts raw reference values (used by HTTP injection).
+func (m *Matcher) AddValues(values []string) bool {
+	return m.AddAnnotatedValues(values, nil)
+}
+
+// AddAnnotatedValues inserts raw reference values recording the operator's annotations
+// with them. Values already cached keep the provenance they were first stored with.
+func (m *Matcher) AddAnnotatedValues(values []string, annotations map[string]string) bool {
+	return m.AddValuesWithMeta(values, store.Metadata{Source: store.SourceHTTP, Annotations: annotations})
+}
+
+// AddValuesWithMeta inserts raw reference values with the given provenance, e.g. values
+// collected from a reference feed by another replica.
+func (m *Matcher) AddValuesWithMeta(values []string, meta store.Metadata) bool {
+	return len(m.store.AddAllWithMeta(m.routeID, m.fingerprints(values), meta)) > 0
+}
+
+// RemoveValues drops raw reference values (and any cached variant of them) so they no
+// longer match; a matcher that hashes values also accepts their fingerprints. It reports
+// whether anything was removed.
+func (m *Matcher) RemoveValues(values []string) bool {
+	removed := false
+	for _, v := range values {
+		if m.isFingerprint(v) {
+			removed = m.store.Remove(m.routeID, v) || removed
+			continue
+		}
+		for _, variant := range m.probeHashes(m.variants(v)) {
+			if m.store.Remove(m.routeID, variant) {
+				removed = true
+			}
+		}
+	}
+	return removed
+}
+
+// Compact drops variants persisted by earlier versions, leaving only canonical values
+// since variants are now generated when probing. It returns how many fingerprints were
+// added and removed.
+func (m *Matcher) Compact() (int, int) {
+	return m.store.Compact(m.routeID, canonicalOnly)
+}
+
+func canonicalOnly(v string) []string {
+	return []string{v}
+}
+
+func extractMatchValues(payload map[string]any, fields []string) ([]string, error) {
+	out := make([]string, 0, len(fields))
+	for _, field := range fields {
+		vals, err := lookupValues(payload, field)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range vals {
+			out = append(out, fmt.Sprintf("%v", val))
+		}
+	}
+	return out, nil
+}
+
+// lookupValues resolves a match field like lookupField, except that a * segment stands
+// for every key of the object it is applied to: accounts.*.number yields the number of
+// each child of accounts, in key order. Children without the rest of the path are passed
+// over; the field is missing only when no child has it.
+func lookupValues(payload map[string]any, field string) ([]any, error) {
+	if !strings.Contains(field, "*") {
+		val, err := lookupField(payload, field)
+		if err != nil {
+			return nil, err
+		}
+		return []any{val}, nil
+	}
+	alternatives := strings.Split(field, "|")
+	for _, path := range alternatives {
+		if vals := lookupWildcard(payload, strings.Split(path, ".")); len(vals) > 0 {
+			return vals, nil
+		}
+	}
+	if len(alternatives) == 1 {
+		return nil, fmt.Errorf("field %s not found", field)
+	}
+	return nil, fmt.Errorf("field %s not found (tried %s)", field, strings.Join(alternatives, ", "))
+}
+
+func lookupWildcard(node map[string]any, parts []string) []any {
+	keys := parts[:1]
+	if parts[0] == "*" {
+		keys = make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	var out []any
+	for _, k := range keys {
+		child, ok := node[k]
+		switch {
+		case !ok:
+		case len(parts) == 1:
+			out = append(out, child)
+		default:
+			if obj, ok := child.(map[string]any); ok {
+				out = append(out, lookupWildcard(obj, parts[1:])...)
+			}
+		}
+	}
+	return out
+}
+
+// lookupField resolves a match field. Fields may list fallback paths separated by "|"
+// (e.g. caseId|legacyCaseId|case.id), tried in order until one is present.
+func lookupField(payload map[string]any, field string) (any, error) {
+	alternatives := strings.Split(field, "|")
+	if len(alternatives) == 1 {
+		return lookupPath(payload, field)
+	}
+	for _, path := range alternatives {
+		if val, err := lookupPath(payload, path); err == nil {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("field %s not found (tried %s)", field, strings.Join(alternatives, ", "))
+}
+
+// lookupPath resolves a dotted path. JSON match fields are validated to at most two
+// levels besides * segments, which lookupValues resolves; XML ones may be deeper.
+func lookupPath(payload map[string]any, field string) (any, error) {
+	parts := strings.Split(field, ".")
+	node := payload
+	for i, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("field %s missing nested object %s", field, strings.Join(parts[:i+1], "."))
+		}
+		node = child
+	}
+	if val, ok := node[parts[len(parts)-1]]; ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("field %s not found", field)
+}
+
+type fieldValue struct {
+	path  string
+	value string
+}
+
+// flattenFields returns every scalar in v with its dotted path (array items as name[i]).
+func flattenFields(prefix string, v any) []fieldValue {
+	switch val := v.(type) {
+	case map[string]any:
+		var res []fieldValue
+		// deterministic iteration
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			res = append(res, flattenFields(path, val[k])...)
+		}
+		return res
+	case []any:
+		var res []fieldValue
+		for i, item := range val {
+			res = append(res, flattenFields(fmt.Sprintf("%s[%d]", prefix, i), item)...)
+		}
+		return res
+	default:
+		return []fieldValue{{path: prefix, value: fmt.Sprintf("%v", val)}}
+	}
+}
+
+func parseTopicHeaders(raw []string) (map[string]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(raw))
+	for _, kv := range raw {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid topicHeader %q (expected key=value)", kv)
+		}
+		out[strings.ToLower(parts[0])] = parts[1]
+	}
+	return out, nil
+}
+
+func (m *Matcher) feedFor(topic string, headers map[string]string) (feedMatcher, bool) {
+	for _, f := range m.feeds {
+		if f.topic != topic {
+			continue
+		}
+		if !headersMatch(f.topicHeaders, headers) {
+			continue
+		}
+		return f, true
+	}
+	return feedMatcher{}, false
+}
+
+func headersMatch(expected map[string]string, actual map[string]string) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	for k, v := range expected {
+		if actual == nil {
+			return false
+		}
+		if av, ok := actual[strings.ToLower(k)]; !ok || av != v {
+			return false
+		}
+	}
+	return true
+}
+
+// variants returns the forms a value is probed in: its year variants and the canonical
+// form of every feed that canonicalizes values.
+func (m *Matcher) variants(v string) []string {
+	out := yearVariants(v)
+	for _, c := range m.canonical {
+		if cv := c.format(v); cv != v && !slices.Contains(out, cv) {
+			out = append(out, cv)
+		}
+	}
+	return out
+}
+
+func yearVariants(v string) []string {
+	variants := make(map[string]struct{}, 2)
+	variants[v] = struct{}{}
+
+	// If value starts with NN/, add 20NN/ variant.
+	if len(v) >= 3 && isDigit(rune(v[0])) && isDigit(rune(v[1])) && v[2] == '/' {
+		variants["20"+v] = struct{}{}
+	}
+
+	// If value starts with 20NN/, add NN/ variant.
+	if len(v) >= 5 && strings.HasPrefix(v, "20") && isDigit(rune(v[2])) && isDigit(rune(v[3])) && v[4] == '/' {
+		variants[v[2:]] = struct{}{}
+	}
+
+	out := make([]string, 0, len(variants))
+	for val := range variants {
+		out = append(out, val)
+	}
+	return out
+}
+
+func isDigit(r rune) bool {
+	return unicode.IsDigit(r)
+}
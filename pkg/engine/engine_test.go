@@ -0,0 +1,1042 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"kafka-bridge/pkg/schema"
+	"kafka-bridge/pkg/store"
+)
+
+func TestFingerprintDeterministic(t *testing.T) {
+	payload := map[string]any{
+		"fieldA": "value1",
+		"sub": map[string]any{
+			"fieldB": "value2",
+		},
+	}
+	values, err := extractMatchValues(payload, []string{"sub.fieldB", "fieldA"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := values, []string{"value2", "value1"}; got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("unexpected match values: %v", values)
+	}
+}
+
+func TestFingerprintMissingField(t *testing.T) {
+	payload := map[string]any{"fieldA": "value1"}
+	_, err := extractMatchValues(payload, []string{"missing"})
+	if err == nil {
+		t.Fatalf("expected error for missing field")
+	}
+}
+
+func TestMatcherReferenceAndForward(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Topic: "feed-a", MatchFields: []string{"fieldA"}},
+		{Topic: "feed-b", MatchFields: []string{"sub.fieldB"}},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+
+	refPayload := map[string]any{
+		"fieldA": "value1",
+		"sub":    map[string]any{"fieldB": "value2"},
+	}
+	refBytes, _ := json.Marshal(refPayload)
+
+	update, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: refBytes})
+	if err != nil || !update.Added {
+		t.Fatalf("expected reference to be added, err=%v", err)
+	}
+	update, err = m.ProcessReference(ReferenceMessage{Topic: "feed-b", Value: refBytes})
+	if err != nil || !update.Added {
+		t.Fatalf("expected second reference to be added, err=%v", err)
+	}
+
+	srcPayload := map[string]any{
+		"fieldA": "value1",
+		"sub":    map[string]any{"fieldB": "value2"},
+	}
+	srcBytes, _ := json.Marshal(srcPayload)
+
+	forward, err := m.ShouldForward(srcBytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !forward {
+		t.Fatalf("expected source to forward after reference cached")
+	}
+
+	nonMatching := map[string]any{
+		"fieldA": "other",
+		"sub":    map[string]any{"fieldB": "different"},
+	}
+	nonBytes, _ := json.Marshal(nonMatching)
+	forward, _ = m.ShouldForward(nonBytes)
+	if forward {
+		t.Fatalf("expected non-matching source to be blocked")
+	}
+
+	// manual add should allow matching when value appears anywhere
+	if added := m.AddValues([]string{"other"}); !added {
+		t.Fatalf("expected manual add to succeed")
+	}
+	forward, _ = m.ShouldForward(nonBytes)
+	if !forward {
+		t.Fatalf("expected manual value to allow forwarding when present in payload")
+	}
+}
+
+func TestMatcherCompactDropsStoredVariants(t *testing.T) {
+	s := store.NewMatchStore()
+	// state from an earlier version that stored variants next to the canonical value
+	s.LoadEntries(map[string]map[string]store.Entry{
+		"route": {"23/abc": {}, "2023/abc": {Canonical: "23/abc"}, "retired": {Canonical: "23/abc"}},
+	})
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+
+	added, removed := m.Compact()
+	if added != 0 || removed != 2 {
+		t.Fatalf("expected 0 added / 2 removed, got %d / %d", added, removed)
+	}
+	forward, err := m.ShouldForward([]byte(`{"id":"2023/abc"}`))
+	if err != nil || !forward {
+		t.Fatalf("expected probe-side variant to match, forward=%v err=%v", forward, err)
+	}
+	if s.Contains("route", "retired") {
+		t.Fatalf("expected retired variant to be removed")
+	}
+}
+
+func TestMatcherStoresCanonicalOnly(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: []byte(`{"fieldA":"2023/abc"}`)}); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+	if got := m.Size(); got != 1 {
+		t.Fatalf("expected only the canonical value to be stored, got %d", got)
+	}
+	for _, probe := range []string{`{"id":"2023/abc"}`, `{"id":"23/abc"}`} {
+		forward, err := m.ShouldForward([]byte(probe))
+		if err != nil || !forward {
+			t.Fatalf("expected %s to match via variants, forward=%v err=%v", probe, forward, err)
+		}
+	}
+}
+
+func TestMatcherEvaluateCollectsMatches(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.AddValues([]string{"value1", "23/abc"})
+
+	res, err := m.Evaluate([]byte(`{"a":"value1","b":["2023/abc","value1"],"c":"other"}`))
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if !res.Forward {
+		t.Fatalf("expected forward decision")
+	}
+	want := []Match{
+		{Field: "a", Value: "value1", Fingerprint: "value1"},
+		{Field: "b[0]", Value: "2023/abc", Fingerprint: "23/abc"},
+		{Field: "b[1]", Value: "value1", Fingerprint: "value1"},
+	}
+	if len(res.Matches) != len(want) {
+		t.Fatalf("expected %d matches, got %v", len(want), res.Matches)
+	}
+	for i, w := range want {
+		got := res.Matches[i]
+		if got.Field != w.Field || got.Value != w.Value || got.Fingerprint != w.Fingerprint {
+			t.Fatalf("match %d = %+v, want %+v", i, got, w)
+		}
+		if got.Origin.Source != store.SourceHTTP {
+			t.Fatalf("match %d: expected http origin, got %+v", i, got.Origin)
+		}
+	}
+
+	res, err = m.Evaluate([]byte(`{"a":"nope"}`))
+	if err != nil || res.Forward || len(res.Matches) != 0 {
+		t.Fatalf("expected no matches, got %+v err=%v", res, err)
+	}
+}
+
+func TestMatcherFirstMatchField(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.AddValues([]string{"value2"})
+
+	match, ok, err := m.FirstMatch([]byte(`{"z":"value2","sub":{"items":[{"id":"x"},{"id":"value2"}]}}`))
+	if err != nil || !ok {
+		t.Fatalf("expected match, ok=%v err=%v", ok, err)
+	}
+	if match.Field != "sub.items[1].id" || match.Fingerprint != "value2" {
+		t.Fatalf("unexpected first match: %+v", match)
+	}
+}
+
+func TestMatcherReferenceTombstones(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	ref := func(key, value string) ReferenceMessage {
+		msg := ReferenceMessage{Topic: "feed-a", Key: []byte(key)}
+		if value != "" {
+			msg.Value = []byte(value)
+		}
+		return msg
+	}
+
+	steps := []struct {
+		name    string
+		msg     ReferenceMessage
+		added   bool
+		removed bool
+		cached  []string
+		wantErr bool
+	}{
+		{name: "add k1", msg: ref("k1", `{"fieldA":"v1"}`), added: true, cached: []string{"v1"}},
+		{name: "add k2 same value", msg: ref("k2", `{"fieldA":"v1"}`), cached: []string{"v1"}},
+		{name: "tombstone k1 keeps shared value", msg: ref("k1", ""), cached: []string{"v1"}},
+		{name: "update k2 replaces value", msg: ref("k2", `{"fieldA":"v2"}`), added: true, removed: true, cached: []string{"v2"}},
+		{name: "tombstone k2", msg: ref("k2", ""), removed: true},
+		{name: "unknown tombstone", msg: ref("k9", "")},
+		{name: "unkeyed tombstone", msg: ReferenceMessage{Topic: "feed-a"}, wantErr: true},
+		{name: "add k3", msg: ref("k3", `{"fieldA":"v3"}`), added: true, cached: []string{"v3"}},
+		{name: "delete action", msg: ref("", `{"fieldA":"v3","action":"DELETE"}`), removed: true},
+	}
+	for _, step := range steps {
+		update, err := m.ProcessReference(step.msg)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+		if update.Added != step.added || update.Removed != step.removed {
+			t.Fatalf("%s: got %+v", step.name, update)
+		}
+		if got := m.Size(); got != len(step.cached) {
+			t.Fatalf("%s: expected %d cached values, got %d", step.name, len(step.cached), got)
+		}
+		for _, v := range step.cached {
+			if !s.Contains("route", v) {
+				t.Fatalf("%s: expected %q to be cached", step.name, v)
+			}
+		}
+	}
+}
+
+func TestMatcherReferenceKeysSurviveRestart(t *testing.T) {
+	feeds := []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", feeds, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	for _, msg := range []ReferenceMessage{
+		{Topic: "feed-a", Key: []byte("k1"), Value: []byte(`{"fieldA":"v1"}`)},
+		{Topic: "feed-a", Key: []byte("k2"), Value: []byte(`[{"fieldA":"v2"},{"fieldA":"v3"}]`)},
+		{Topic: "feed-a", Value: []byte(`{"fieldA":"v4"}`)},
+	} {
+		if _, err := m.ProcessReference(msg); err != nil {
+			t.Fatalf("ProcessReference: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := s.SaveSnapshot(path, store.SaveOptions{}); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	restarted := store.NewMatchStore()
+	file, err := store.ReadSnapshotFile(path)
+	if err != nil {
+		t.Fatalf("ReadSnapshotFile: %v", err)
+	}
+	restarted.LoadEntries(file.Entries())
+	m, err = NewMatcher("route", feeds, restarted)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	if n := m.RestoreKeys(); n != 2 {
+		t.Fatalf("RestoreKeys = %d, want 2", n)
+	}
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("k2")}); len(update.Dropped) != 2 {
+		t.Fatalf("tombstone after restart dropped %v, want v2 and v3", update.Dropped)
+	}
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("k1"), Value: []byte(`{"fieldA":"v5"}`)}); !reflect.DeepEqual(update.Dropped, []string{"v1"}) {
+		t.Fatalf("update after restart dropped %v, want [v1]", update.Dropped)
+	}
+	if !restarted.Contains("route", "v4") || !restarted.Contains("route", "v5") || m.Size() != 2 {
+		t.Fatalf("unexpected cache after restart: %v", restarted.CanonicalSnapshot())
+	}
+}
+
+func TestMatcherReferenceArrays(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Name: "flat", Topic: "feed-a", MatchFields: []string{"fieldA"}},
+		{Name: "nested", Topic: "feed-b", MatchFields: []string{"fieldA"}, RecordsPath: "batch.items"},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	update, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("k1"), Value: []byte(`[{"fieldA":"v1"},{"fieldA":"v2"}]`)})
+	if err != nil || !update.Added || len(update.Stored) != 2 {
+		t.Fatalf("top-level array: %+v, %v", update, err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-b", Value: []byte(`{"batch":{"items":[{"fieldA":"v3"},{"fieldA":"v1","action":"delete"}]}}`)}); err != nil {
+		t.Fatalf("records path: %v", err)
+	}
+	if !s.Contains("route", "v2") || !s.Contains("route", "v3") || s.Contains("route", "v1") || m.Size() != 2 {
+		t.Fatalf("unexpected cache after arrays, size %d", m.Size())
+	}
+	// one invalid record skips the whole message
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: []byte(`[{"fieldA":"v4"},{"other":"x"}]`)}); err == nil || !strings.Contains(err.Error(), "record 1") {
+		t.Fatalf("expected record 1 error, got %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: []byte(`["v5"]`)}); err == nil {
+		t.Fatal("expected error for an array of scalars")
+	}
+	if s.Contains("route", "v4") || s.Contains("route", "v5") {
+		t.Fatal("invalid messages must not change the cache")
+	}
+	// the key tracks the values of every record, so a tombstone drops them all
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("k1")}); len(update.Dropped) != 1 || update.Dropped[0] != "v2" {
+		t.Fatalf("tombstone dropped %v, want [v2]", update.Dropped)
+	}
+}
+
+func TestMatcherReferenceWildcardFields(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "ref", MatchFields: []string{"accounts.*.number", "owner.*|ownerId"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	update, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"accounts":{"b7":{"number":"N2"},"a1":{"number":"N1"},"c3":{"closed":true}},"ownerId":"O1"}`)})
+	if err != nil || !update.Added {
+		t.Fatalf("wildcard reference: %+v, %v", update, err)
+	}
+	if got := strings.Join(update.Stored, ","); got != "N1,N2,O1" {
+		t.Fatalf("stored %s, want N1,N2,O1", got)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"accounts":{"x":{"closed":true}},"ownerId":"O2"}`)}); err == nil {
+		t.Fatal("expected an error when no child has the wildcard path")
+	}
+	match, ok, err := m.FirstMatch([]byte(`{"payment":{"accounts":{"zz":{"number":"N2"}}}}`))
+	if err != nil || !ok || match.Field != "payment.accounts.zz.number" {
+		t.Fatalf("FirstMatch = %+v, %v, %v", match, ok, err)
+	}
+}
+
+func TestCanonicalizerFormat(t *testing.T) {
+	c, err := newCanonicalizer([]string{CanonicalNumbers, CanonicalBooleans, CanonicalTimestamps}, []string{"2006-01-02 15:04:05"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"1.0":                       "1",
+		"1e6":                       "1000000",
+		"007":                       "7",
+		"-0.50":                     "-0.5",
+		"12345678901234567890.10":   "12345678901234567890.1",
+		"1e999999":                  "1e999999",
+		"TRUE":                      "true",
+		"False":                     "false",
+		"2024-05-01T12:00:00+02:00": "2024-05-01T10:00:00Z",
+		"2024-05-01T10:00:00.500Z":  "2024-05-01T10:00:00.5Z",
+		"2024-05-01 10:00:00":       "2024-05-01T10:00:00Z",
+		"ACC-1":                     "ACC-1",
+	}
+	for in, want := range cases {
+		if got := c.format(in); got != want {
+			t.Errorf("format(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := newCanonicalizer([]string{"dates"}, nil); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+}
+
+func TestMatcherCanonicalizesValues(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		s := store.NewMatchStore()
+		m, err := NewMatcher("route", []Feed{
+			{Topic: "ref", MatchFields: []string{"id", "active"}, Canonicalize: []string{CanonicalNumbers, CanonicalBooleans}},
+			{Topic: "plain", MatchFields: []string{"id"}},
+		}, s, WithLegacyDecode(legacy))
+		if err != nil {
+			t.Fatalf("NewMatcher error: %v", err)
+		}
+		if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"id":"42.0","active":"TRUE"}`)}); err != nil {
+			t.Fatal(err)
+		}
+		if !s.Contains("route", "42") || !s.Contains("route", "true") || s.Contains("route", "42.0") {
+			t.Fatalf("legacy=%v: reference values were not cached canonically", legacy)
+		}
+		match, ok, err := m.FirstMatch([]byte(`{"order":{"customer":4.2e1}}`))
+		if err != nil || !ok || match.Field != "order.customer" || match.Fingerprint != "42" {
+			t.Fatalf("legacy=%v: FirstMatch = %+v, %v, %v", legacy, match, ok, err)
+		}
+		// feeds without canonicalize still cache and match values as written
+		if _, err := m.ProcessReference(ReferenceMessage{Topic: "plain", Value: []byte(`{"id":"007"}`)}); err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := m.ShouldForward([]byte(`{"x":"007"}`)); !ok {
+			t.Fatalf("legacy=%v: expected the raw value to match", legacy)
+		}
+		if !m.RemoveValues([]string{"42.00"}) || s.Contains("route", "42") {
+			t.Fatalf("legacy=%v: removing a non-canonical spelling should drop the cached value", legacy)
+		}
+	}
+}
+
+func TestMatcherHashesValues(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		s := store.NewMatchStore()
+		m, err := NewMatcher("route", []Feed{{Topic: "ref", MatchFields: []string{"id"}}}, s,
+			WithValueHash(true, []byte("secret")), WithLegacyDecode(legacy))
+		if err != nil {
+			t.Fatalf("NewMatcher error: %v", err)
+		}
+		if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"id":"cust-1"}`)}); err != nil {
+			t.Fatal(err)
+		}
+		m.AddValues([]string{"cust-2"})
+		for _, fp := range s.CanonicalSnapshot()["route"] {
+			if !strings.HasPrefix(fp, HashPrefixHMACSHA256) || strings.Contains(fp, "cust") {
+				t.Fatalf("legacy=%v: cache holds %q, want only hashes", legacy, fp)
+			}
+		}
+		match, ok, err := m.FirstMatch([]byte(`{"customer":"cust-1"}`))
+		if err != nil || !ok || match.Value != "cust-1" || match.Fingerprint != m.Fingerprint("cust-1") {
+			t.Fatalf("legacy=%v: FirstMatch = %+v, %v, %v", legacy, match, ok, err)
+		}
+		if ok, _ := m.ShouldForward([]byte(`{"customer":"cust-2"}`)); !ok {
+			t.Fatalf("legacy=%v: expected the injected value to match", legacy)
+		}
+		// fingerprints are neither hashed again nor differ between matchers with the key
+		other, _ := NewMatcher("other", nil, s, WithValueHash(true, []byte("secret")))
+		if other.Fingerprint(m.Fingerprint("cust-1")) != m.Fingerprint("cust-1") {
+			t.Fatalf("legacy=%v: a fingerprint was hashed again", legacy)
+		}
+		if !m.RemoveValues([]string{"cust-1", m.Fingerprint("cust-2")}) || m.Size() != 0 {
+			t.Fatalf("legacy=%v: values not removed, %d left", legacy, m.Size())
+		}
+	}
+	plain, _ := NewMatcher("route", nil, store.NewMatchStore(), WithValueHash(true, nil))
+	if got := plain.Fingerprint("abc"); got != HashPrefixSHA256+"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("Fingerprint = %s", got)
+	}
+}
+
+func TestMatcherReferenceMatchSource(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Name: "by-key", Topic: "feed-a", MatchSource: MatchSourceKey},
+		{Name: "by-header", Topic: "feed-b", MatchSource: MatchSourceHeaderPrefix + "X-Account"},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("acct-1"), Value: []byte("not json")}); err != nil {
+		t.Fatalf("key source: %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-b", Headers: map[string]string{"x-account": "acct-2"}, Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("header source: %v", err)
+	}
+	if !s.Contains("route", "acct-1") || !s.Contains("route", "acct-2") {
+		t.Fatal("expected the key and header values to be cached")
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-b", Value: []byte(`{}`)}); err == nil {
+		t.Fatal("expected error when the header is missing")
+	}
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-b", Headers: map[string]string{"x-account": "acct-2"}, Value: []byte(`{"action":"delete"}`)}); !update.Removed {
+		t.Fatal("delete action should remove the header value")
+	}
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Key: []byte("acct-1")}); !update.Removed || s.Contains("route", "acct-1") {
+		t.Fatal("tombstone should remove the key value")
+	}
+}
+
+func TestMatcherReferenceCSV(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Name: "header", Topic: "feed-a", PayloadFormat: FormatCSV, CSVHeader: true, MatchFields: []string{"customer"}},
+		{Name: "indexed", Topic: "feed-b", PayloadFormat: FormatCSV, CSVDelimiter: ";", MatchFields: []string{"1"}},
+		{Name: "named", Topic: "feed-c", PayloadFormat: FormatCSV, CSVColumns: []string{"id", "action"}, MatchFields: []string{"id"}},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	for _, msg := range []ReferenceMessage{
+		{Topic: "feed-a", Value: []byte("region,customer\neu,\"acme, inc\"\n\nus,globex\n")},
+		{Topic: "feed-b", Value: []byte("x;initech")},
+		{Topic: "feed-c", Value: []byte("umbrella,\nhooli,add")},
+	} {
+		if _, err := m.ProcessReference(msg); err != nil {
+			t.Fatalf("%s: %v", msg.Topic, err)
+		}
+	}
+	for _, v := range []string{"acme, inc", "globex", "initech", "umbrella", "hooli"} {
+		if !s.Contains("route", v) {
+			t.Fatalf("expected %q to be cached, have %v", v, s.CanonicalSnapshot()["route"])
+		}
+	}
+	if s.Contains("route", "customer") || s.Contains("route", "region") {
+		t.Fatal("the header line should not be cached")
+	}
+	if update, _ := m.ProcessReference(ReferenceMessage{Topic: "feed-c", Value: []byte("hooli,delete")}); !update.Removed || s.Contains("route", "hooli") {
+		t.Fatal("a delete action column should remove the value")
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: []byte("region,customer\n")}); err == nil {
+		t.Fatal("expected error for a message with only a header line")
+	}
+}
+
+func TestMatcherReferenceOrigin(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Name: "feed-a", Topic: "ref-topic", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	msg := ReferenceMessage{Topic: "ref-topic", Partition: 3, Offset: 17, Value: []byte(`{"fieldA":"v1"}`)}
+	if _, err := m.ProcessReference(msg); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+
+	match, ok, err := m.FirstMatch([]byte(`{"x":"v1"}`))
+	if err != nil || !ok {
+		t.Fatalf("expected match, ok=%v err=%v", ok, err)
+	}
+	if got := match.Origin.String(); got != "kafka:feed-a@ref-topic/3:17" {
+		t.Fatalf("unexpected origin %q", got)
+	}
+}
+
+func TestMatcherTimeWindow(t *testing.T) {
+	s := store.NewMatchStore()
+	feeds := []Feed{{Topic: "ref", MatchFields: []string{"accountId"}, TimestampField: "eventDate"}}
+	m, err := NewMatcher("route", feeds, s, WithTimeWindow("ts", 48*time.Hour, 48*time.Hour))
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"accountId":"a1","eventDate":"2024-05-10"}`)}); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"accountId":"a2"}`)}); err == nil {
+		t.Fatal("expected error for a record without its timestamp field")
+	}
+	s.Add("route", "manual")
+
+	cases := []struct {
+		payload string
+		want    bool
+	}{
+		{`{"id":"a1","ts":"2024-05-11T23:00:00Z"}`, true},
+		{`{"id":"a1","ts":1715126400}`, true},     // 2024-05-08, the window's start
+		{`{"id":"a1","ts":1715558400001}`, false}, // just after 2024-05-13 in milliseconds
+		{`{"id":"a1","ts":"2024-05-01"}`, false},
+		{`{"id":"a1"}`, false},
+		{`{"id":"manual"}`, true},
+	}
+	for _, tc := range cases {
+		got, err := m.ShouldForward([]byte(tc.payload))
+		if err != nil || got != tc.want {
+			t.Fatalf("ShouldForward(%s) = %v, %v; want %v", tc.payload, got, err, tc.want)
+		}
+	}
+
+	// a later record with a new event time moves the value's window
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"accountId":"a1","eventDate":"2024-05-01"}`)}); err != nil {
+		t.Fatalf("ProcessReference error: %v", err)
+	}
+	if ok, _ := m.ShouldForward([]byte(`{"id":"a1","ts":"2024-05-01"}`)); !ok {
+		t.Fatal("expected match within the updated window")
+	}
+}
+
+func TestMatcherTrackSchemaSamplesSource(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"fieldA"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	tracker := schema.NewTracker(10)
+	m.TrackSchema(tracker, "source-topic", 2)
+
+	for i := 0; i < 4; i++ {
+		if _, err := m.ShouldForward([]byte(`{"id":"x"}`)); err != nil {
+			t.Fatalf("ShouldForward error: %v", err)
+		}
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: []byte(`{"other":"y"}`)}); err == nil {
+		t.Fatalf("expected extraction error for missing matchField")
+	}
+
+	src, ok := tracker.Report("source-topic")
+	if !ok || src.Messages != 2 {
+		t.Fatalf("expected 2 sampled source messages, got %+v", src)
+	}
+	ref, ok := tracker.Report("feed-a")
+	if !ok || len(ref.Drift.MissingMatchFields) != 1 {
+		t.Fatalf("expected missing matchField to be reported, got %+v", ref)
+	}
+}
+
+func TestLookupFieldFallbacks(t *testing.T) {
+	payload := map[string]any{
+		"legacyCaseId": "legacy-1",
+		"case":         map[string]any{"id": "nested-1"},
+	}
+	cases := []struct {
+		field   string
+		want    any
+		wantErr bool
+	}{
+		{field: "caseId|legacyCaseId|case.id", want: "legacy-1"},
+		{field: "caseId|case.id|legacyCaseId", want: "nested-1"},
+		{field: "case.id", want: "nested-1"},
+		{field: "caseId|other.id", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := lookupField(payload, tc.field)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("lookupField(%q) error = %v", tc.field, err)
+		}
+		if !tc.wantErr && got != tc.want {
+			t.Fatalf("lookupField(%q) = %v, want %v", tc.field, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	limits := DecodeLimits{MaxDepth: 3, MaxNodes: 8, MaxStringLength: 5}
+	cases := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{name: "within limits", payload: `{"a":{"b":["abc",1,true]}}`},
+		{name: "escaped quote", payload: `{"a":"ab\"c"}`},
+		{name: "too deep", payload: `{"a":{"b":{"c":[1]}}}`, wantErr: true},
+		{name: "too many nodes", payload: `[1,2,3,4,5,6,7,8]`, wantErr: true},
+		{name: "long string", payload: `{"a":"abcdef"}`, wantErr: true},
+		{name: "long key", payload: `{"abcdef":1}`, wantErr: true},
+	}
+	for _, tc := range cases {
+		err := checkLimits([]byte(tc.payload), limits)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: checkLimits error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("%s: expected ErrLimitExceeded, got %v", tc.name, err)
+		}
+	}
+
+	m, err := NewMatcher("route", []Feed{{Name: "feed", Topic: "ref", MatchFields: []string{"id"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.SetDecodeLimits(limits)
+	if _, _, err := m.FirstMatch([]byte(`{"a":{"b":{"c":{"d":1}}}}`)); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected FirstMatch to reject deep payload, got %v", err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref", Value: []byte(`{"id":"much-too-long"}`)}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ProcessReference to reject long string, got %v", err)
+	}
+}
+
+func TestPayloadCompression(t *testing.T) {
+	plain := []byte(`{"id":"value1"}`)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(plain)
+	zw.Close()
+	zenc, _ := zstd.NewWriter(nil)
+	zstdPayload := zenc.EncodeAll(plain, nil)
+	zenc.Close()
+	var framed bytes.Buffer
+	sw := snappy.NewBufferedWriter(&framed)
+	sw.Write(plain)
+	sw.Close()
+	block := snappy.Encode(nil, plain)
+
+	cases := []struct {
+		compression string
+		payload     []byte
+		wantErr     bool
+	}{
+		{compression: CompressionGzip, payload: gz.Bytes()},
+		{compression: CompressionGzip, payload: plain},
+		{compression: CompressionZstd, payload: zstdPayload},
+		{compression: CompressionSnappy, payload: framed.Bytes()},
+		{compression: CompressionSnappy, payload: block},
+		{compression: CompressionSnappy, payload: plain},
+		{compression: CompressionAuto, payload: gz.Bytes()},
+		{compression: CompressionAuto, payload: zstdPayload},
+		{compression: CompressionAuto, payload: framed.Bytes()},
+		{compression: CompressionAuto, payload: plain},
+		{compression: CompressionNone, payload: gz.Bytes(), wantErr: true},
+		{compression: CompressionGzip, payload: zstdPayload, wantErr: true},
+	}
+	for i, tc := range cases {
+		s := store.NewMatchStore()
+		m, err := NewMatcher("route", []Feed{{Name: "feed", Topic: "ref", MatchFields: []string{"id"}}}, s)
+		if err != nil {
+			t.Fatalf("NewMatcher error: %v", err)
+		}
+		m.AddValues([]string{"value1"})
+		m.SetPayloadCompression(tc.compression, 1024)
+		ok, err := m.ShouldForward(tc.payload)
+		if (err != nil) != tc.wantErr || (err == nil && !ok) {
+			t.Fatalf("case %d (%s): ShouldForward = %v, %v; wantErr %v", i, tc.compression, ok, err, tc.wantErr)
+		}
+	}
+
+	m, err := NewMatcher("route", []Feed{{Name: "feed", Topic: "ref", MatchFields: []string{"id"}}}, store.NewMatchStore())
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.SetPayloadCompression(CompressionGzip, 8)
+	if _, err := m.Decompress(gz.Bytes()); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected oversized gzip payload to be rejected, got %v", err)
+	}
+	m.SetPayloadCompression(CompressionSnappy, 8)
+	if _, err := m.Decompress(block); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected oversized snappy block to be rejected, got %v", err)
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<ns:order xmlns:ns="urn:orders" id="o-1">
+  <customer><id>c-42</id><name>Ann</name></customer>
+  <item sku="a">first</item>
+  <item sku="b">second</item>
+  <note/>
+</ns:order>`
+	body, err := decodeXML([]byte(doc), DecodeLimits{})
+	if err != nil {
+		t.Fatalf("decodeXML: %v", err)
+	}
+	for field, want := range map[string]any{"order.@id": "o-1", "order.customer.id": "c-42", "order.note": ""} {
+		got, err := lookupPath(body, field)
+		if err != nil || got != want {
+			t.Fatalf("%s = %v (%v), want %q", field, got, err, want)
+		}
+	}
+	items, ok := xmlRoot(body)["item"].([]any)
+	if !ok || len(items) != 2 || items[1].(map[string]any)["#text"] != "second" || items[1].(map[string]any)["@sku"] != "b" {
+		t.Fatalf("expected repeated items as a list, got %#v", xmlRoot(body)["item"])
+	}
+
+	for _, bad := range []string{"", "<a><b></a>", "<a/><b/>"} {
+		if _, err := decodeXML([]byte(bad), DecodeLimits{}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+	if _, err := decodeXML([]byte(doc), DecodeLimits{MaxDepth: 2}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected the depth limit to apply, got %v", err)
+	}
+	if _, err := decodeXML([]byte(doc), DecodeLimits{MaxStringLength: 5}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected the string limit to apply, got %v", err)
+	}
+}
+
+func TestMatcherXMLPayloads(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Name: "legacy", Topic: "ref-xml", PayloadFormat: FormatXML, MatchFields: []string{"account.holder.@id"}},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.SetSourceFormat(FormatXML)
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref-xml", Value: []byte(`<account><holder id="h-7"/></account>`)}); err != nil {
+		t.Fatalf("ProcessReference: %v", err)
+	}
+	match, ok, err := m.FirstMatch([]byte(`<payment><to><holder>h-7</holder></to></payment>`))
+	if err != nil || !ok || match.Field != "payment.to.holder" {
+		t.Fatalf("FirstMatch = %+v, %v, %v", match, ok, err)
+	}
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref-xml", Value: []byte(`<account action="delete"><holder id="h-7"/></account>`)}); err != nil {
+		t.Fatalf("ProcessReference delete: %v", err)
+	}
+	if m.Size() != 0 {
+		t.Fatalf("expected the XML delete action to remove the value, got %d cached", m.Size())
+	}
+}
+
+func TestMatcherCloudEvents(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{
+		{Name: "customers", Topic: "ref-ce", PayloadFormat: FormatCloudEvents, MatchFields: []string{"customer.id"}},
+	}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.SetSourceFormat(FormatCloudEvents)
+	ref := `{"specversion":"1.0","id":"1","type":"customer.flagged","source":"/crm","data":{"customer":{"id":"c-1"}}}`
+	if _, err := m.ProcessReference(ReferenceMessage{Topic: "ref-ce", Value: []byte(ref)}); err != nil {
+		t.Fatalf("ProcessReference: %v", err)
+	}
+	m.AddValues([]string{"tenant-9"})
+
+	cases := []struct {
+		name    string
+		event   string
+		filter  map[string]string
+		field   string
+		wantErr bool
+	}{
+		{name: "data object", event: `{"specversion":"1.0","type":"order.created","source":"/shop","data":{"buyer":"c-1"}}`, field: "buyer"},
+		{name: "data string", event: `{"specversion":"1.0","type":"order.created","source":"/shop","datacontenttype":"application/json","data":"{\"buyer\":\"c-1\"}"}`, field: "buyer"},
+		{name: "data base64", event: `{"specversion":"1.0","type":"order.created","source":"/shop","data_base64":"eyJidXllciI6ImMtMSJ9"}`, field: "buyer"},
+		{name: "extension attribute", event: `{"specversion":"1.0","type":"order.created","source":"/shop","tenant":"tenant-9","data":{}}`, field: "ce.tenant"},
+		{name: "filter admits", event: `{"specversion":"1.0","type":"order.created","source":"/shop","data":{"buyer":"c-1"}}`, filter: map[string]string{"type": "order.*", "source": "/shop"}, field: "buyer"},
+		{name: "filter rejects", event: `{"specversion":"1.0","type":"order.created","source":"/shop","data":{"buyer":"c-1"}}`, filter: map[string]string{"type": "invoice.*"}},
+		{name: "filter missing attribute", event: `{"specversion":"1.0","type":"order.created","source":"/shop","data":{"buyer":"c-1"}}`, filter: map[string]string{"subject": "*"}},
+		{name: "not a cloudevent", event: `{"buyer":"c-1"}`, wantErr: true},
+	}
+	for _, tc := range cases {
+		m.SetEventFilter(tc.filter)
+		match, ok, err := m.FirstMatch([]byte(tc.event))
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: FirstMatch error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if ok != (tc.field != "") || match.Field != tc.field {
+			t.Fatalf("%s: FirstMatch = %+v, %v; want field %q", tc.name, match, ok, tc.field)
+		}
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"items":[{"id":"a","tags":["x","y"]},{"id":42},{"other":true}],"meta":{"b":"2","a":"1"}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"$.items[*].id":    "a,42",
+		"$.items[0].tags":  "x,y",
+		"$['items'][1].id": "42",
+		"$.meta.*":         "1,2",
+		"$.items[5].id":    "",
+		"$.missing":        "",
+	}
+	for path, want := range cases {
+		p, err := ParseJSONPath(path)
+		if err != nil {
+			t.Fatalf("ParseJSONPath(%q): %v", path, err)
+		}
+		if got := strings.Join(p.Strings(doc), ","); got != want {
+			t.Fatalf("%s = %q, want %q", path, got, want)
+		}
+	}
+	for _, bad := range []string{"items", "$.", "$[abc]", "$[-1]", "$.items[0"} {
+		if _, err := ParseJSONPath(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMatcherBytesContains(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"id"}}}, s, WithMatchStrategy(MatchStrategyBytesContains))
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.AddValues([]string{"ACC-1001", "24/0042"})
+
+	res, err := m.Evaluate([]byte(`{"order":{"account":"ACC-1001","ref":"2024/0042"}}`))
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if !res.Forward || len(res.Matches) != 2 {
+		t.Fatalf("expected both values to match, got %+v", res)
+	}
+	if got := res.Matches[1]; got.Value != "2024/0042" || got.Fingerprint != "24/0042" || got.Field != "" {
+		t.Fatalf("unexpected variant match: %+v", got)
+	}
+
+	// the payload is never decoded, so invalid JSON is searched too
+	if ok, err := m.ShouldForward([]byte(`not json ACC-1001`)); err != nil || !ok {
+		t.Fatalf("expected raw payload to match, got %v, %v", ok, err)
+	}
+	m.RemoveValues([]string{"ACC-1001"})
+	if ok, _ := m.ShouldForward([]byte(`{"account":"ACC-1001"}`)); ok {
+		t.Fatal("removed value still matches")
+	}
+}
+
+func TestMatcherPrefilter(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"id"}}}, s, WithPrefilter(true))
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.AddValues([]string{"ACC-1", "24/0042"})
+
+	for _, tc := range []struct {
+		payload string
+		want    bool
+	}{
+		{payload: `{"id":"ACC-1"}`, want: true},
+		{payload: `{"ref":"2024/0042"}`, want: true},
+		// the value occurs, but inside a longer field value
+		{payload: `{"id":"ACC-10"}`, want: false},
+		{payload: `{"id":"ACC-2"}`, want: false},
+		// escaped payloads are always decoded
+		{payload: `{"id":"ACC\u002d1"}`, want: true},
+	} {
+		ok, err := m.ShouldForward([]byte(tc.payload))
+		if err != nil || ok != tc.want {
+			t.Fatalf("ShouldForward(%s) = %v, %v; want %v", tc.payload, ok, err, tc.want)
+		}
+	}
+	if _, err := m.ShouldForward([]byte(`{"id":`)); err == nil {
+		t.Fatal("expected a prefiltered invalid payload to fail decoding")
+	}
+	if res, err := m.Evaluate([]byte(`{"id":"none"}`)); err != nil || res.Forward || res.Matches == nil {
+		t.Fatalf("unexpected evaluation of a prefiltered payload: %+v, %v", res, err)
+	}
+}
+
+func TestSourceScanMatchesFlattenFields(t *testing.T) {
+	for _, payload := range []string{
+		`{"b":{"z":1,"a":[true,null,{"y":"x","c":"d"}]},"a":"` + "\u00fc" + `","ab":"\"q\"","a-b":-12.50,"n":1234567,"e":1e3}`,
+		`[{"id":"24/1"},"2024/7",0.00001,-0]`,
+		`"scalar"`,
+		`{"":{"":""},"k":{},"l":[]}`,
+	} {
+		var body any
+		if err := json.Unmarshal([]byte(payload), &body); err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, fv := range flattenFields("", body) {
+			want = append(want, fv.path+"="+fv.value)
+		}
+		sc := getSourceScan()
+		if err := sc.scan([]byte(payload)); err != nil {
+			t.Fatalf("scan %s: %v", payload, err)
+		}
+		var got []string
+		for _, f := range sc.fields {
+			got = append(got, string(sc.bytes(f.path))+"="+string(sc.bytes(f.value)))
+		}
+		putSourceScan(sc)
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Fatalf("scan of %s:\n got %q\nwant %q", payload, got, want)
+		}
+	}
+	for _, payload := range []string{`{"a":}`, `{"a":1,}`, `[1 2]`, `{"a":01}`, `{"a":"x`, "{\"a\":\"\x01\"}", `tru`, `{} {}`, `1e999`} {
+		sc := getSourceScan()
+		err := sc.scan([]byte(payload))
+		putSourceScan(sc)
+		if err == nil || json.Unmarshal([]byte(payload), new(any)) == nil {
+			t.Fatalf("expected %s to fail both decoders, scanner: %v", payload, err)
+		}
+	}
+}
+
+func TestMatcherScanAllocations(t *testing.T) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"id"}}}, s)
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	m.AddValues([]string{"ACC-1"})
+	payload := []byte(`{"order":{"id":"ORD-9","lines":[{"sku":"A-1","qty":2},{"sku":"B-7","qty":1}]},"account":"ACC-2","at":"24/05/01"}`)
+	if allocs := testing.AllocsPerRun(100, func() {
+		if ok, err := m.ShouldForward(payload); err != nil || ok {
+			t.Fatalf("unexpected decision %v, %v", ok, err)
+		}
+	}); allocs > 0 && !raceEnabled {
+		t.Fatalf("ShouldForward allocated %v times per payload that matches nothing", allocs)
+	}
+	m.SetLegacyDecode(true)
+	if ok, err := m.ShouldForward([]byte(`{"account":"ACC-1"}`)); err != nil || !ok {
+		t.Fatalf("legacy decode missed a match: %v, %v", ok, err)
+	}
+}
+
+func BenchmarkMatcherShouldForward(b *testing.B) {
+	payload := []byte(`{"id":"evt-1","at":"2024-05-01T12:00:00Z","attr0":"value-0-17","attr1":"value-1-4","attr2":"value-2-980",` +
+		`"lines":[{"sku":"A-1","qty":2},{"sku":"B-7","qty":1}],"customer":{"account":"MISS-00000042"}}`)
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "scan"},
+		{name: "legacy", opts: []Option{WithLegacyDecode(true)}},
+		{name: "prefilter", opts: []Option{WithPrefilter(true)}},
+		{name: "bytesContains", opts: []Option{WithMatchStrategy(MatchStrategyBytesContains)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := store.NewMatchStore()
+			m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"customer.account"}}}, s, bc.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			values := make([]string, 100000)
+			for i := range values {
+				values[i] = fmt.Sprintf("ACC-%08d", i)
+			}
+			m.AddValues(values)
+			// the first payload builds the prefilter index
+			if _, err := m.ShouldForward(payload); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.ShouldForward(payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatcherProcessReference(b *testing.B) {
+	s := store.NewMatchStore()
+	m, err := NewMatcher("route", []Feed{{Topic: "feed-a", MatchFields: []string{"account"}}}, s)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		value := fmt.Appendf(nil, `{"account":"ACC-%08d"}`, i)
+		if _, err := m.ProcessReference(ReferenceMessage{Topic: "feed-a", Value: value}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
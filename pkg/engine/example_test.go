@@ -0,0 +1,27 @@
+package engine_test
+
+import (
+	"fmt"
+
+	"kafka-bridge/pkg/engine"
+	"kafka-bridge/pkg/store"
+)
+
+func ExampleNewMatcher() {
+	feeds := []engine.Feed{{Name: "customers", Topic: "customers", MatchFields: []string{"customer.id"}}}
+	m, err := engine.NewMatcher("orders", feeds, store.NewMatchStore(),
+		engine.WithDecodeLimits(engine.DecodeLimits{MaxDepth: 32}),
+	)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := m.ProcessReference(engine.ReferenceMessage{Topic: "customers", Value: []byte(`{"customer":{"id":"c-42"}}`)}); err != nil {
+		panic(err)
+	}
+	match, ok, err := m.FirstMatch([]byte(`{"order":"o-1","customer":{"id":"c-42"}}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(ok, match.Field, match.Value)
+	// Output: true customer.id c-42
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefixes of the fingerprints a matcher with value hashing stores.
+const (
+	HashPrefixSHA256     = "sha256:"
+	HashPrefixHMACSHA256 = "hmac-sha256:"
+)
+
+// SetValueHash makes the matcher store and look up SHA-256 hashes of values instead of
+// the values, so the cache never holds them in plaintext. With a key the hashes are
+// HMAC-SHA256, which cannot be reversed by hashing guessed values. References, injected
+// values, and source values are hashed, after canonicalization and year variants, in
+// the same way; values cached before hashing was enabled no longer match. Fingerprints
+// are the hex hash with HashPrefixSHA256 or HashPrefixHMACSHA256; AddValues and
+// RemoveValues accept them as well as the values they hash.
+func (m *Matcher) SetValueHash(enabled bool, key []byte) {
+	if !enabled {
+		m.hash, m.hashPrefix = nil, ""
+		return
+	}
+	if len(key) == 0 {
+		m.hashPrefix = HashPrefixSHA256
+		m.hash = func(v string) string {
+			sum := sha256.Sum256([]byte(v))
+			return HashPrefixSHA256 + hex.EncodeToString(sum[:])
+		}
+		return
+	}
+	key = append([]byte(nil), key...)
+	m.hashPrefix = HashPrefixHMACSHA256
+	m.hash = func(v string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(v))
+		return HashPrefixHMACSHA256 + hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+// WithValueHash is the construction-time form of SetValueHash.
+func WithValueHash(enabled bool, key []byte) Option {
+	return func(m *Matcher) { m.SetValueHash(enabled, key) }
+}
+
+// Fingerprint returns the form v is cached in: v itself, or its hash when the matcher
+// hashes values and v is not a fingerprint already, so exported fingerprints can be
+// injected again.
+func (m *Matcher) Fingerprint(v string) string {
+	if m.hash == nil || m.isFingerprint(v) {
+		return v
+	}
+	return m.hash(v)
+}
+
+func (m *Matcher) fingerprints(values []string) []string {
+	if m.hash == nil {
+		return values
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = m.Fingerprint(v)
+	}
+	return out
+}
+
+// probeHashes hashes the probes of a source value; unlike Fingerprint it hashes every
+// one, since a source value is never a fingerprint.
+func (m *Matcher) probeHashes(probes []string) []string {
+	if m.hash == nil {
+		return probes
+	}
+	for i, p := range probes {
+		probes[i] = m.hash(p)
+	}
+	return probes
+}
+
+// isFingerprint reports whether v is already a hash the matcher stores.
+func (m *Matcher) isFingerprint(v string) bool {
+	return m.hash != nil && strings.HasPrefix(v, m.hashPrefix)
+}
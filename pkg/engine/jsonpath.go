@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath selects values from a decoded JSON document. It supports the subset of
+// JSONPath that reference sources need: $ followed by .name, ['name'], [n], [*], and .*
+// steps.
+type JSONPath struct {
+	raw   string
+	steps []jsonPathStep
+}
+
+type jsonPathStep struct {
+	name     string
+	index    int
+	indexed  bool
+	wildcard bool
+}
+
+// ParseJSONPath compiles path, e.g. $.items[*].id.
+func ParseJSONPath(path string) (JSONPath, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return JSONPath{}, fmt.Errorf("jsonpath %q must start with $", path)
+	}
+	p := JSONPath{raw: path}
+	for rest != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".*"):
+			step.wildcard = true
+			rest = rest[2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			step.name = rest[1:end]
+			if step.name == "" {
+				return JSONPath{}, fmt.Errorf("jsonpath %q has an empty name", path)
+			}
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return JSONPath{}, fmt.Errorf("jsonpath %q has an unterminated [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if inner == "*" {
+				step.wildcard = true
+				break
+			}
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				step.name = inner[1 : len(inner)-1]
+				break
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return JSONPath{}, fmt.Errorf("jsonpath %q: [%s] is not an index, a quoted name, or *", path, inner)
+			}
+			step.index, step.indexed = n, true
+		default:
+			return JSONPath{}, fmt.Errorf("jsonpath %q: unexpected %q", path, rest)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// String returns the path as written.
+func (p JSONPath) String() string {
+	return p.raw
+}
+
+// Strings returns the scalar values the path reaches in doc, rendered the way matching
+// renders payload fields. An array the path ends on contributes each scalar element;
+// objects and nulls are skipped.
+func (p JSONPath) Strings(doc any) []string {
+	nodes := []any{doc}
+	for _, step := range p.steps {
+		var next []any
+		for _, node := range nodes {
+			switch v := node.(type) {
+			case map[string]any:
+				if step.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if child, ok := v[step.name]; ok && !step.indexed {
+					next = append(next, child)
+				}
+			case []any:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.indexed && step.index < len(v) {
+					next = append(next, v[step.index])
+				}
+			}
+		}
+		nodes = next
+	}
+	var out []string
+	for _, node := range nodes {
+		if items, ok := node.([]any); ok {
+			for _, item := range items {
+				out = appendScalar(out, item)
+			}
+			continue
+		}
+		out = appendScalar(out, node)
+	}
+	return out
+}
+
+func appendScalar(out []string, v any) []string {
+	switch v.(type) {
+	case nil, map[string]any, []any:
+		return out
+	}
+	return append(out, fmt.Sprintf("%v", v))
+}
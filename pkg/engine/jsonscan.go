@@ -0,0 +1,475 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"kafka-bridge/pkg/store"
+)
+
+// SetLegacyDecode matches json source payloads by decoding them into a tree of maps, as
+// earlier versions did, instead of scanning their values straight from the payload. The
+// decisions are the same, except that the scanner reports every occurrence of a
+// duplicated key rather than its last.
+func (m *Matcher) SetLegacyDecode(enabled bool) {
+	m.legacyDecode = enabled
+}
+
+// WithLegacyDecode is the construction-time form of SetLegacyDecode.
+func WithLegacyDecode(enabled bool) Option {
+	return func(m *Matcher) { m.SetLegacyDecode(enabled) }
+}
+
+// scannable reports whether source payloads can be matched by scanning them. A time
+// window needs the decoded source timestamp.
+func (m *Matcher) scannable() bool {
+	return !m.legacyDecode && (m.format == "" || m.format == FormatJSON) && m.window.field == ""
+}
+
+// scanSource matches a json source payload without decoding it into a tree: its values
+// are read into a pooled buffer and looked up in place, and only hits are copied out.
+func (m *Matcher) scanSource(payload []byte, first bool) ([]Match, error) {
+	payload, err := m.Decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+	if m.ruledOut(payload) {
+		return []Match{}, m.validate(payload)
+	}
+	if err := checkLimits(payload, m.limits); err != nil {
+		return nil, err
+	}
+	sc := getSourceScan()
+	defer putSourceScan(sc)
+	sc.canonical = m.canonical
+	if err := sc.scan(payload); err != nil {
+		return nil, err
+	}
+	if m.hash != nil {
+		for i, probe := range sc.probes {
+			sc.probes[i] = []byte(m.hash(string(probe)))
+		}
+	}
+	matches := []Match{}
+	m.store.LookupEachBytes(m.routeID, sc.probes, func(i int, origin store.Metadata) bool {
+		f := sc.fields[sc.owners[i]]
+		path, value, probe := sc.bytes(f.path), sc.bytes(f.value), sc.probes[i]
+		for _, prev := range matches {
+			if prev.Field == string(path) && prev.Value == string(value) && prev.Fingerprint == string(probe) {
+				return true
+			}
+		}
+		matches = append(matches, Match{Field: string(path), Value: string(value), Fingerprint: string(probe), Origin: origin})
+		return !first
+	})
+	return matches, nil
+}
+
+// maxScanDepth matches encoding/json's nesting limit, for payloads decoded without
+// decode limits.
+const maxScanDepth = 10000
+
+// sourceScan holds every scalar of a source payload as flattenFields would report it for
+// the decoded document, in the same order, without building the document. Paths and
+// values are kept in one buffer that is reused across payloads.
+type sourceScan struct {
+	path   []byte
+	buf    []byte
+	fields []scannedField
+	// members are the keys of the objects being scanned and the fields under each, for
+	// ordering them by key once the object ends.
+	members []member
+	keys    []byte
+	scratch []scannedField
+	// probes are the values and their variants, owned by fields[owners[i]].
+	probes [][]byte
+	owners []int
+	spans  []span
+	// canonical are the matcher's canonical formattings, probed besides the variants.
+	canonical []canonicalizer
+}
+
+type span struct{ start, end int }
+
+type scannedField struct {
+	path, value span
+}
+
+type member struct {
+	key        span
+	start, end int
+}
+
+// maxPooledScan is the largest buffer returned to the pool, so one huge payload does not
+// pin its memory.
+const maxPooledScan = 1 << 20
+
+var sourceScans = sync.Pool{New: func() any { return new(sourceScan) }}
+
+func getSourceScan() *sourceScan {
+	return sourceScans.Get().(*sourceScan)
+}
+
+func putSourceScan(sc *sourceScan) {
+	if cap(sc.buf) > maxPooledScan {
+		return
+	}
+	sc.path, sc.buf, sc.fields = sc.path[:0], sc.buf[:0], sc.fields[:0]
+	sc.members, sc.keys, sc.scratch = sc.members[:0], sc.keys[:0], sc.scratch[:0]
+	clear(sc.probes)
+	sc.probes, sc.owners, sc.spans = sc.probes[:0], sc.owners[:0], sc.spans[:0]
+	sc.canonical = nil
+	sourceScans.Put(sc)
+}
+
+func (sc *sourceScan) bytes(s span) []byte {
+	return sc.buf[s.start:s.end]
+}
+
+// scan fills sc with the scalars of the JSON document data, their year variants, and
+// their canonical forms.
+// Unlike json.Unmarshal into a map, every occurrence of a duplicated key is reported.
+func (sc *sourceScan) scan(data []byte) error {
+	p := jsonParser{data: data, sc: sc}
+	p.skipSpace()
+	if err := p.value(0); err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos < len(data) {
+		return p.syntaxError("after top-level value")
+	}
+	// yearVariants' rules, applied in buf; the probes are sliced once buf stops growing
+	for i, f := range sc.fields {
+		sc.spans = append(sc.spans, f.value)
+		sc.owners = append(sc.owners, i)
+		v := sc.bytes(f.value)
+		switch {
+		case len(v) >= 3 && isASCIIDigit(v[0]) && isASCIIDigit(v[1]) && v[2] == '/':
+			start := len(sc.buf)
+			sc.buf = append(append(sc.buf, "20"...), v...)
+			sc.spans = append(sc.spans, span{start, len(sc.buf)})
+			sc.owners = append(sc.owners, i)
+		case len(v) >= 5 && v[0] == '2' && v[1] == '0' && isASCIIDigit(v[2]) && isASCIIDigit(v[3]) && v[4] == '/':
+			sc.spans = append(sc.spans, span{f.value.start + 2, f.value.end})
+			sc.owners = append(sc.owners, i)
+		}
+		if len(sc.canonical) == 0 {
+			continue
+		}
+		value := string(v)
+		var added []string
+		for _, c := range sc.canonical {
+			if cv := c.format(value); cv != value && !slices.Contains(added, cv) {
+				added = append(added, cv)
+				start := len(sc.buf)
+				sc.buf = append(sc.buf, cv...)
+				sc.spans = append(sc.spans, span{start, len(sc.buf)})
+				sc.owners = append(sc.owners, i)
+			}
+		}
+	}
+	for _, s := range sc.spans {
+		sc.probes = append(sc.probes, sc.bytes(s))
+	}
+	return nil
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type jsonParser struct {
+	data []byte
+	pos  int
+	sc   *sourceScan
+}
+
+func (p *jsonParser) syntaxError(context string) error {
+	if p.pos >= len(p.data) {
+		return errors.New("unexpected end of JSON input")
+	}
+	return fmt.Errorf("invalid character %q %s at offset %d", p.data[p.pos], context, p.pos)
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// emit records the scalar appended to buf from start under the current path.
+func (p *jsonParser) emit(start int) {
+	sc := p.sc
+	mid := len(sc.buf)
+	sc.buf = append(sc.buf, sc.path...)
+	sc.fields = append(sc.fields, scannedField{value: span{start, mid}, path: span{mid, len(sc.buf)}})
+}
+
+func (p *jsonParser) value(depth int) error {
+	if depth > maxScanDepth {
+		return errors.New("exceeded max depth")
+	}
+	if p.pos >= len(p.data) {
+		return p.syntaxError("")
+	}
+	start := len(p.sc.buf)
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object(depth + 1)
+	case c == '[':
+		return p.array(depth + 1)
+	case c == '"':
+		if err := p.str(); err != nil {
+			return err
+		}
+	case c == 't':
+		if err := p.literal("true", "true"); err != nil {
+			return err
+		}
+	case c == 'f':
+		if err := p.literal("false", "false"); err != nil {
+			return err
+		}
+	case c == 'n':
+		// flattenFields renders a decoded null with %v
+		if err := p.literal("null", "<nil>"); err != nil {
+			return err
+		}
+	case c == '-' || isASCIIDigit(c):
+		if err := p.number(); err != nil {
+			return err
+		}
+	default:
+		return p.syntaxError("looking for beginning of value")
+	}
+	p.emit(start)
+	return nil
+}
+
+func (p *jsonParser) literal(lit, rendered string) error {
+	for i := 0; i < len(lit); i++ {
+		if p.pos >= len(p.data) || p.data[p.pos] != lit[i] {
+			return p.syntaxError("in literal " + lit)
+		}
+		p.pos++
+	}
+	p.sc.buf = append(p.sc.buf, rendered...)
+	return nil
+}
+
+func (p *jsonParser) object(depth int) error {
+	sc := p.sc
+	p.pos++
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == '}' {
+		p.pos++
+		return nil
+	}
+	memberBase, keyBase := len(sc.members), len(sc.keys)
+	for {
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return p.syntaxError("looking for beginning of object key string")
+		}
+		keyStart := len(sc.buf)
+		if err := p.str(); err != nil {
+			return err
+		}
+		base := len(sc.path)
+		if base > 0 {
+			sc.path = append(sc.path, '.')
+		}
+		sc.path = append(sc.path, sc.buf[keyStart:]...)
+		key := span{len(sc.keys), 0}
+		sc.keys = append(sc.keys, sc.buf[keyStart:]...)
+		key.end = len(sc.keys)
+		sc.buf = sc.buf[:keyStart]
+		fieldStart := len(sc.fields)
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return p.syntaxError("after object key")
+		}
+		p.pos++
+		p.skipSpace()
+		if err := p.value(depth); err != nil {
+			return err
+		}
+		sc.members = append(sc.members, member{key: key, start: fieldStart, end: len(sc.fields)})
+		sc.path = sc.path[:base]
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			p.skipSpace()
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			p.pos++
+			sc.sortMembers(memberBase)
+			sc.members, sc.keys = sc.members[:memberBase], sc.keys[:keyBase]
+			return nil
+		}
+		return p.syntaxError("after object key:value pair")
+	}
+}
+
+// sortMembers orders the fields of the object whose members start at base by key, as
+// flattenFields visits a decoded map. Objects whose keys are already in order, as most
+// are, cost one comparison per key.
+func (sc *sourceScan) sortMembers(base int) {
+	members := sc.members[base:]
+	key := func(m member) []byte { return sc.keys[m.key.start:m.key.end] }
+	sorted := true
+	for i := 1; i < len(members) && sorted; i++ {
+		sorted = bytes.Compare(key(members[i-1]), key(members[i])) <= 0
+	}
+	if sorted {
+		return
+	}
+	from, to := members[0].start, members[len(members)-1].end
+	sc.scratch = append(sc.scratch[:0], sc.fields[from:to]...)
+	slices.SortStableFunc(members, func(a, b member) int { return bytes.Compare(key(a), key(b)) })
+	at := from
+	for _, m := range members {
+		at += copy(sc.fields[at:], sc.scratch[m.start-from:m.end-from])
+	}
+}
+
+func (p *jsonParser) array(depth int) error {
+	sc := p.sc
+	p.pos++
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == ']' {
+		p.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		base := len(sc.path)
+		sc.path = append(strconv.AppendInt(append(sc.path, '['), int64(i), 10), ']')
+		if err := p.value(depth); err != nil {
+			return err
+		}
+		sc.path = sc.path[:base]
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			p.skipSpace()
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return nil
+		}
+		return p.syntaxError("after array element")
+	}
+}
+
+// str appends the string at pos, unescaped, to buf. Strings with escapes or invalid
+// UTF-8 are rare and left to encoding/json, so they decode exactly as before.
+func (p *jsonParser) str() error {
+	p.pos++
+	start := p.pos
+	escaped, ascii := false, true
+	for {
+		if p.pos >= len(p.data) {
+			return p.syntaxError("")
+		}
+		c := p.data[p.pos]
+		if c == '"' {
+			break
+		}
+		switch {
+		case c == '\\':
+			escaped = true
+			p.pos++
+		case c < 0x20:
+			return p.syntaxError("in string literal")
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+		p.pos++
+	}
+	raw := p.data[start:p.pos]
+	p.pos++
+	if !escaped && (ascii || utf8.Valid(raw)) {
+		p.sc.buf = append(p.sc.buf, raw...)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(p.data[start-1:p.pos], &s); err != nil {
+		return err
+	}
+	p.sc.buf = append(p.sc.buf, s...)
+	return nil
+}
+
+// number appends the number at pos as flattenFields renders a decoded float64.
+func (p *jsonParser) number() error {
+	data := p.data
+	start := p.pos
+	if data[p.pos] == '-' {
+		p.pos++
+	}
+	switch {
+	case p.pos < len(data) && data[p.pos] == '0':
+		p.pos++
+	case p.pos < len(data) && isASCIIDigit(data[p.pos]):
+		for p.pos < len(data) && isASCIIDigit(data[p.pos]) {
+			p.pos++
+		}
+	default:
+		return p.syntaxError("in numeric literal")
+	}
+	digits := p.pos - start
+	plain := true
+	if p.pos < len(data) && data[p.pos] == '.' {
+		plain = false
+		p.pos++
+		if err := p.digits(); err != nil {
+			return err
+		}
+	}
+	if p.pos < len(data) && (data[p.pos] == 'e' || data[p.pos] == 'E') {
+		plain = false
+		p.pos++
+		if p.pos < len(data) && (data[p.pos] == '+' || data[p.pos] == '-') {
+			p.pos++
+		}
+		if err := p.digits(); err != nil {
+			return err
+		}
+	}
+	lit := data[start:p.pos]
+	// %v prints integers of up to six digits as written; anything longer may switch to
+	// exponent form
+	if plain && digits <= 6 {
+		p.sc.buf = append(p.sc.buf, lit...)
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(lit), 64)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal number %s into Go value of type float64", lit)
+	}
+	p.sc.buf = strconv.AppendFloat(p.sc.buf, f, 'g', -1, 64)
+	return nil
+}
+
+func (p *jsonParser) digits() error {
+	if p.pos >= len(p.data) || !isASCIIDigit(p.data[p.pos]) {
+		return p.syntaxError("in numeric literal")
+	}
+	for p.pos < len(p.data) && isASCIIDigit(p.data[p.pos]) {
+		p.pos++
+	}
+	return nil
+}
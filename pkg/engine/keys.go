@@ -0,0 +1,104 @@
+package engine
+
+import "sync"
+
+// keyIndex remembers which values each keyed reference record contributed so tombstones
+// and updates can remove them. Values are reference counted across keys, so a value is
+// only released once no remaining record still carries it. The index covers records
+// consumed since startup and, through Matcher.RestoreKeys, the keys restored with the
+// cache.
+type keyIndex struct {
+	mu    sync.Mutex
+	byKey map[string][]string
+	refs  map[string]int
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		byKey: make(map[string][]string),
+		refs:  make(map[string]int),
+	}
+}
+
+func indexKey(feed, key string) string {
+	return feed + "\x00" + key
+}
+
+// replace records values for the key and returns values no longer referenced by any record.
+func (k *keyIndex) replace(feed, key string, values []string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := indexKey(feed, key)
+	previous := k.byKey[id]
+	unique := dedupe(values)
+	for _, v := range unique {
+		k.refs[v]++
+	}
+	k.byKey[id] = unique
+	return k.releaseLocked(previous)
+}
+
+// load replaces the index with byKey, values by indexKey.
+func (k *keyIndex) load(byKey map[string][]string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.byKey = make(map[string][]string, len(byKey))
+	k.refs = make(map[string]int)
+	for id, values := range byKey {
+		unique := dedupe(values)
+		for _, v := range unique {
+			k.refs[v]++
+		}
+		k.byKey[id] = unique
+	}
+}
+
+// drop forgets the key and returns values no longer referenced by any record.
+func (k *keyIndex) drop(feed, key string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := indexKey(feed, key)
+	previous, ok := k.byKey[id]
+	if !ok {
+		return nil
+	}
+	delete(k.byKey, id)
+	return k.releaseLocked(previous)
+}
+
+// forget clears all references to the value, used when it is deleted explicitly.
+func (k *keyIndex) forget(value string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.refs, value)
+}
+
+func (k *keyIndex) releaseLocked(values []string) []string {
+	var released []string
+	for _, v := range values {
+		n, ok := k.refs[v]
+		if !ok {
+			continue
+		}
+		if n <= 1 {
+			delete(k.refs, v)
+			released = append(released, v)
+			continue
+		}
+		k.refs[v] = n - 1
+	}
+	return released
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
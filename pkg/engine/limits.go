@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is wrapped by decode errors for payloads over the configured limits.
+var ErrLimitExceeded = errors.New("payload exceeds decode limits")
+
+// SetDecodeLimits bounds the JSON accepted from source and reference payloads. Zero
+// fields are unlimited.
+func (m *Matcher) SetDecodeLimits(limits DecodeLimits) {
+	m.limits = limits
+}
+
+// decode checks payload against the matcher's limits before unmarshalling it into v, so
+// pathological documents are rejected without building their tree.
+func (m *Matcher) decode(payload []byte, v any) error {
+	if err := checkLimits(payload, m.limits); err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// SetSourceFormat selects how source payloads are decoded: json (default), xml, or
+// cloudevents.
+func (m *Matcher) SetSourceFormat(format string) {
+	m.format = format
+}
+
+// decodeSource decompresses a source payload, if the route is configured to, and decodes
+// it in the route's payload format.
+func (m *Matcher) decodeSource(payload []byte) (any, error) {
+	payload, err := m.Decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+	return m.decodePlain(payload)
+}
+
+// decodePlain decodes a decompressed source payload in the route's payload format.
+func (m *Matcher) decodePlain(payload []byte) (any, error) {
+	switch m.format {
+	case FormatXML:
+		return decodeXML(payload, m.limits)
+	case FormatCloudEvents:
+		return m.decodeCloudEvent(payload)
+	}
+	var body any
+	if err := m.decode(payload, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// decodeReference decodes a reference payload in its feed's format. A CSV payload decodes
+// to its first record.
+func (m *Matcher) decodeReference(feed feedMatcher, payload []byte) (map[string]any, error) {
+	switch feed.format {
+	case FormatXML:
+		return decodeXML(payload, m.limits)
+	case FormatCloudEvents:
+		return m.decodeCloudEvent(payload)
+	case FormatCSV:
+		records, err := decodeCSV(payload, feed, m.limits)
+		if err != nil {
+			return nil, err
+		}
+		return records[0], nil
+	}
+	var body map[string]any
+	if err := m.decode(payload, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// decodeReferenceRecords decodes a reference payload into its records: the payload itself
+// when it is an object, the elements of a top-level JSON array, the elements of the
+// array at the feed's recordsPath, or the lines of a CSV payload. A single object at recordsPath is one record, as XML
+// decodes an element that occurs once.
+func (m *Matcher) decodeReferenceRecords(feed feedMatcher, payload []byte) ([]map[string]any, error) {
+	if feed.format == FormatCSV {
+		return decodeCSV(payload, feed, m.limits)
+	}
+	var body any
+	if feed.format == FormatXML || feed.format == FormatCloudEvents {
+		obj, err := m.decodeReference(feed, payload)
+		if err != nil {
+			return nil, err
+		}
+		body = obj
+	} else if err := m.decode(payload, &body); err != nil {
+		return nil, err
+	}
+	if feed.recordsPath != "" {
+		if root, ok := body.(map[string]any); ok {
+			v, err := lookupPath(root, feed.recordsPath)
+			if err != nil {
+				return nil, fmt.Errorf("recordsPath: %w", err)
+			}
+			body = v
+		}
+	}
+	switch val := body.(type) {
+	case map[string]any:
+		return []map[string]any{val}, nil
+	case []any:
+		records := make([]map[string]any, 0, len(val))
+		for i, elem := range val {
+			record, ok := elem.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("record %d is not an object", i)
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	return nil, errors.New("payload is not an object or an array of objects")
+}
+
+// checkLimits scans raw JSON for nesting depth, node count (containers, keys, and
+// scalars), and string length. It does not validate syntax; json.Unmarshal does.
+func checkLimits(data []byte, l DecodeLimits) error {
+	if l.MaxDepth <= 0 && l.MaxNodes <= 0 && l.MaxStringLength <= 0 {
+		return nil
+	}
+	depth, nodes := 0, 0
+	inScalar := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '"':
+			inScalar = false
+			start := i + 1
+			for i = start; i < len(data); i++ {
+				if data[i] == '\\' {
+					i++
+					continue
+				}
+				if data[i] == '"' {
+					break
+				}
+			}
+			if l.MaxStringLength > 0 && i-start > l.MaxStringLength {
+				return fmt.Errorf("%w: string of %d bytes at offset %d (max %d)", ErrLimitExceeded, i-start, start, l.MaxStringLength)
+			}
+			nodes++
+		case '{', '[':
+			inScalar = false
+			depth++
+			nodes++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return fmt.Errorf("%w: nesting deeper than %d at offset %d", ErrLimitExceeded, l.MaxDepth, i)
+			}
+		case '}', ']':
+			inScalar = false
+			depth--
+		case ',', ':', ' ', '\t', '\n', '\r':
+			inScalar = false
+		default:
+			if !inScalar {
+				inScalar = true
+				nodes++
+			}
+		}
+		if l.MaxNodes > 0 && nodes > l.MaxNodes {
+			return fmt.Errorf("%w: more than %d nodes", ErrLimitExceeded, l.MaxNodes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package engine
+
+import "kafka-bridge/pkg/schema"
+
+// Feed describes a reference feed: the topic (and optional header selector) whose records
+// populate a route's match cache, and the fields extracted from each record.
+type Feed struct {
+	Name  string
+	Topic string
+	// TopicHeaders selects records by header, as name=value pairs.
+	TopicHeaders []string
+	// MatchFields are the dotted paths whose values are cached.
+	MatchFields []string
+	// PayloadFormat is FormatJSON (default), FormatXML, FormatCloudEvents, or FormatCSV.
+	PayloadFormat string
+	// TimestampField, when set, records each value's event time from this field for
+	// SetTimeWindow.
+	TimestampField string
+	// RecordsPath, when set, is the dotted path of an array of records in each payload.
+	// Payloads that are arrays are always read as one record per element.
+	RecordsPath string
+	// MatchSource is where the cached value comes from: MatchSourceValue (default) reads
+	// MatchFields from the payload, MatchSourceKey caches the message key, and
+	// MatchSourceHeaderPrefix followed by a header name caches that header's value.
+	MatchSource string
+	// CSVHeader reads the first line of each FormatCSV payload as the column names.
+	CSVHeader bool
+	// CSVColumns names the columns of FormatCSV payloads without a header line.
+	CSVColumns []string
+	// CSVDelimiter separates FormatCSV fields; a comma by default.
+	CSVDelimiter string
+	// Canonicalize lists the kinds of value, CanonicalNumbers, CanonicalBooleans, and
+	// CanonicalTimestamps, cached in their canonical form. Source values are probed in
+	// the canonical form of every feed too.
+	Canonicalize []string
+	// CanonicalTimeLayouts are time layouts read as timestamps besides RFC 3339.
+	CanonicalTimeLayouts []string
+}
+
+// Match sources accepted by Feed.MatchSource.
+const (
+	MatchSourceValue        = "value"
+	MatchSourceKey          = "key"
+	MatchSourceHeaderPrefix = "header:"
+)
+
+// DisplayName returns the feed's name, or its topic when it has none.
+func (f Feed) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Topic
+}
+
+// DecodeLimits bounds the payloads a matcher decodes. Zero fields are unlimited.
+type DecodeLimits struct {
+	MaxDepth        int
+	MaxNodes        int
+	MaxStringLength int
+}
+
+// Payload formats accepted by WithSourceFormat and Feed.PayloadFormat.
+const (
+	FormatJSON        = "json"
+	FormatXML         = "xml"
+	FormatCloudEvents = "cloudevents"
+	// FormatCSV reads reference payloads as CSV lines, one record per line; it is not
+	// accepted for source payloads.
+	FormatCSV = "csv"
+)
+
+// Payload compressions accepted by WithCompression. CompressionAuto detects gzip, zstd,
+// and framed snappy by their magic bytes.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+	CompressionAuto   = "auto"
+)
+
+// Option configures a Matcher built by NewMatcher.
+type Option func(*Matcher)
+
+// WithDecodeLimits is the construction-time form of SetDecodeLimits.
+func WithDecodeLimits(limits DecodeLimits) Option {
+	return func(m *Matcher) { m.SetDecodeLimits(limits) }
+}
+
+// WithCompression is the construction-time form of SetPayloadCompression.
+func WithCompression(compression string, maxBytes int) Option {
+	return func(m *Matcher) { m.SetPayloadCompression(compression, maxBytes) }
+}
+
+// WithSourceFormat is the construction-time form of SetSourceFormat.
+func WithSourceFormat(format string) Option {
+	return func(m *Matcher) { m.SetSourceFormat(format) }
+}
+
+// WithEventFilter is the construction-time form of SetEventFilter.
+func WithEventFilter(filter map[string]string) Option {
+	return func(m *Matcher) { m.SetEventFilter(filter) }
+}
+
+// WithSchemaTracker is the construction-time form of TrackSchema.
+func WithSchemaTracker(tracker *schema.Tracker, sourceTopic string, sampleEvery int) Option {
+	return func(m *Matcher) { m.TrackSchema(tracker, sourceTopic, sampleEvery) }
+}
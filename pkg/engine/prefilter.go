@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// SetPrefilter keeps an Aho-Corasick index of the route's cached values, with their
+// variants, in the store and scans each raw JSON source payload with it before decoding.
+// A payload in which no cached value occurs cannot match, so it is only validated, not
+// decoded into a tree; the payloads that remain are matched field by field as usual.
+// Payloads holding escape sequences are always decoded, since a value may be spelled
+// escaped in them. Only json source payloads are prefiltered.
+func (m *Matcher) SetPrefilter(enabled bool) {
+	m.prefilter = enabled
+	if enabled {
+		m.store.IndexSubstrings(m.routeID, yearVariants)
+	}
+}
+
+// WithPrefilter is the construction-time form of SetPrefilter.
+func WithPrefilter(enabled bool) Option {
+	return func(m *Matcher) { m.SetPrefilter(enabled) }
+}
+
+// decodeCandidate decodes a source payload as decodeSource does and reports true, unless
+// the prefilter rules out a match: then the payload is only checked for the errors
+// decoding would report, and false is returned. A payload due for schema sampling is
+// always decoded; observed payloads that are not count towards the sampling.
+func (m *Matcher) decodeCandidate(payload []byte, observe, due bool) (any, bool, error) {
+	if !m.prefilter || due || (m.format != "" && m.format != FormatJSON) {
+		body, err := m.decodeSource(payload)
+		return body, true, err
+	}
+	payload, err := m.Decompress(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	if !m.ruledOut(payload) {
+		body, err := m.decodePlain(payload)
+		return body, true, err
+	}
+	if err := m.validate(payload); err != nil {
+		return nil, false, err
+	}
+	if observe && m.schema != nil {
+		m.sourceSeen.Add(1)
+	}
+	return nil, false, nil
+}
+
+// ruledOut reports whether the prefilter shows that a decompressed json payload holds no
+// cached value.
+func (m *Matcher) ruledOut(payload []byte) bool {
+	return m.prefilter && bytes.IndexByte(payload, '\\') < 0 && !m.store.ContainsSubstring(m.routeID, payload)
+}
+
+// validate reports the error decoding a json payload ruled out by the prefilter would.
+func (m *Matcher) validate(payload []byte) error {
+	if err := checkLimits(payload, m.limits); err != nil {
+		return err
+	}
+	if !json.Valid(payload) {
+		_, err := m.decodePlain(payload)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package engine
+
+// raceEnabled reports a -race build, whose detector drops sync.Pool entries at random.
+const raceEnabled = true
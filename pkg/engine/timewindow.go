@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// timeWindow restricts matches of timestamped reference values to source payloads whose
+// own timestamp lies between before ahead of and after behind the reference's.
+type timeWindow struct {
+	field  string
+	before time.Duration
+	after  time.Duration
+}
+
+// SetTimeWindow makes values cached with an event time (see Feed.TimestampField) match
+// only source payloads whose sourceField timestamp lies within [event-before,
+// event+after]. Values cached without an event time, such as injected ones, match
+// regardless. Timestamps may be RFC 3339, YYYY-MM-DD (UTC), or Unix seconds or
+// milliseconds.
+func (m *Matcher) SetTimeWindow(sourceField string, before, after time.Duration) {
+	m.window = timeWindow{field: sourceField, before: before, after: after}
+}
+
+// WithTimeWindow is the construction-time form of SetTimeWindow.
+func WithTimeWindow(sourceField string, before, after time.Duration) Option {
+	return func(m *Matcher) { m.SetTimeWindow(sourceField, before, after) }
+}
+
+// sourceTime resolves the source payload's timestamp for the window. A missing or
+// unparseable timestamp reports false, and then no timestamped value matches.
+func (w timeWindow) sourceTime(body any) (time.Time, bool) {
+	root, ok := body.(map[string]any)
+	if !ok {
+		return time.Time{}, false
+	}
+	v, err := lookupField(root, w.field)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := parseEventTime(v)
+	return t, err == nil
+}
+
+func (w timeWindow) admits(event, source time.Time) bool {
+	return !source.Before(event.Add(-w.before)) && !source.After(event.Add(w.after))
+}
+
+// referenceTime extracts the event time of a reference record from its feed's timestamp
+// field.
+func referenceTime(body map[string]any, field string) (time.Time, error) {
+	v, err := lookupField(body, field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := parseEventTime(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp field %s: %w", field, err)
+	}
+	return t, nil
+}
+
+// epochMillisThreshold separates Unix seconds from milliseconds: larger values are read
+// as milliseconds (seconds that large are past the year 33658).
+const epochMillisThreshold = 1e12
+
+// parseEventTime reads an RFC 3339 timestamp, a YYYY-MM-DD date (midnight UTC), or a Unix
+// timestamp in seconds or milliseconds, given as a number or a string of digits.
+func parseEventTime(v any) (time.Time, error) {
+	switch val := v.(type) {
+	case float64:
+		return epochTime(val), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.DateOnly, val); err == nil {
+			return t, nil
+		}
+		if n, err := strconv.ParseFloat(val, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			return epochTime(n), nil
+		}
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp, date, or Unix time", val)
+	}
+	return time.Time{}, fmt.Errorf("%v is not a timestamp", v)
+}
+
+func epochTime(n float64) time.Time {
+	if math.Abs(n) >= epochMillisThreshold {
+		return time.UnixMilli(int64(n)).UTC()
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlTextKey holds the text of an element that also has attributes or children.
+const xmlTextKey = "#text"
+
+// decodeXML turns an XML document into the same tree JSON decodes to, so match fields and
+// scanning work unchanged: the root element is the single top-level key, elements with
+// only text become strings, attributes become "@name" keys, repeated elements become
+// lists, and namespaces are dropped. The decode limits bound the depth, the nodes
+// (elements, attributes, and text), and the length of names and text.
+func decodeXML(data []byte, limits DecodeLimits) (map[string]any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	type frame struct {
+		name     string
+		children map[string]any
+		text     strings.Builder
+	}
+	var stack []*frame
+	var root map[string]any
+	nodes := 0
+	count := func(n int) error {
+		nodes += n
+		if limits.MaxNodes > 0 && nodes > limits.MaxNodes {
+			return fmt.Errorf("%w: more than %d nodes", ErrLimitExceeded, limits.MaxNodes)
+		}
+		return nil
+	}
+	checkString := func(s string) error {
+		if limits.MaxStringLength > 0 && len(s) > limits.MaxStringLength {
+			return fmt.Errorf("%w: string of %d bytes (max %d)", ErrLimitExceeded, len(s), limits.MaxStringLength)
+		}
+		return nil
+	}
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil {
+				return nil, errors.New("xml: content after the root element")
+			}
+			if limits.MaxDepth > 0 && len(stack)+1 > limits.MaxDepth {
+				return nil, fmt.Errorf("%w: nesting deeper than %d", ErrLimitExceeded, limits.MaxDepth)
+			}
+			if err := count(1 + len(t.Attr)); err != nil {
+				return nil, err
+			}
+			f := &frame{name: t.Name.Local, children: make(map[string]any)}
+			if err := checkString(f.name); err != nil {
+				return nil, err
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				if err := checkString(attr.Value); err != nil {
+					return nil, err
+				}
+				f.children["@"+attr.Name.Local] = attr.Value
+			}
+			stack = append(stack, f)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			text := strings.TrimSpace(f.text.String())
+			if err := checkString(text); err != nil {
+				return nil, err
+			}
+			var value any = text
+			if len(f.children) > 0 {
+				if text != "" {
+					if err := count(1); err != nil {
+						return nil, err
+					}
+					f.children[xmlTextKey] = text
+				}
+				value = f.children
+			}
+			if len(stack) == 0 {
+				root = map[string]any{f.name: value}
+				continue
+			}
+			addXMLChild(stack[len(stack)-1].children, f.name, value)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("xml: no root element")
+	}
+	return root, nil
+}
+
+// addXMLChild stores value under name, turning repeated elements into a list.
+func addXMLChild(children map[string]any, name string, value any) {
+	existing, ok := children[name]
+	if !ok {
+		children[name] = value
+		return
+	}
+	if list, ok := existing.([]any); ok {
+		children[name] = append(list, value)
+		return
+	}
+	children[name] = []any{existing, value}
+}
+
+// xmlRoot returns the root element of a decoded XML document, or nil when the root has
+// no attributes or children.
+func xmlRoot(body map[string]any) map[string]any {
+	for _, v := range body {
+		root, _ := v.(map[string]any)
+		return root
+	}
+	return nil
+}
+
+// isXMLDeleteAction reports whether the root element carries action="delete", as an
+// attribute or a child element.
+func isXMLDeleteAction(body map[string]any) bool {
+	root := xmlRoot(body)
+	for _, key := range []string{"@" + deleteActionField, deleteActionField} {
+		if action, ok := root[key].(string); ok && strings.EqualFold(action, "delete") {
+			return true
+		}
+	}
+	return false
+}
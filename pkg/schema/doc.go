@@ -0,0 +1,3 @@
+// Package schema tracks the fields seen in reference and source payloads and reports
+// drift from a learned baseline.
+package schema
@@ -0,0 +1,305 @@
+package schema
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role says whether a topic carries reference or source payloads.
+type Role string
+
+// Topic roles reported by the tracker.
+const (
+	RoleReference Role = "reference"
+	RoleSource    Role = "source"
+)
+
+const defaultBaseline = 100
+
+// Tracker records the field paths and JSON types observed per topic and reports drift:
+// fields that appear after the baseline window, paths whose type changes, and configured
+// matchFields missing from reference payloads.
+type Tracker struct {
+	mu       sync.Mutex
+	baseline int64
+	topics   map[string]*topicStats
+	logf     func(format string, args ...any)
+}
+
+type topicStats struct {
+	role        Role
+	messages    int64
+	fields      map[string]*fieldStats
+	matchFields map[string]*expectation
+}
+
+type fieldStats struct {
+	types        map[string]int64
+	count        int64
+	firstSeen    time.Time
+	lastSeen     time.Time
+	firstMessage int64
+}
+
+type expectation struct {
+	missing     int64
+	lastMissing time.Time
+}
+
+// Report summarises the observed schema of one topic.
+type Report struct {
+	Topic    string        `json:"topic"`
+	Role     Role          `json:"role"`
+	Messages int64         `json:"messages"`
+	Fields   []FieldReport `json:"fields"`
+	Drift    Drift         `json:"drift"`
+}
+
+// FieldReport describes one observed field path (array indices collapse to []).
+type FieldReport struct {
+	Path      string           `json:"path"`
+	Types     map[string]int64 `json:"types"`
+	Count     int64            `json:"count"`
+	FirstSeen time.Time        `json:"firstSeen"`
+	LastSeen  time.Time        `json:"lastSeen"`
+	New       bool             `json:"new"`
+}
+
+// Drift lists the schema changes that may break extraction or matching.
+type Drift struct {
+	NewFields          []string        `json:"newFields"`
+	TypeChanges        []string        `json:"typeChanges"`
+	MissingMatchFields []MissingReport `json:"missingMatchFields"`
+}
+
+// MissingReport counts reference payloads lacking a configured matchField.
+type MissingReport struct {
+	Field       string    `json:"field"`
+	Missing     int64     `json:"missing"`
+	LastMissing time.Time `json:"lastMissing"`
+}
+
+// NewTracker builds a tracker; fields first seen after baseline messages on a topic are
+// reported as new. A non-positive baseline uses the default of 100.
+func NewTracker(baseline int) *Tracker {
+	if baseline <= 0 {
+		baseline = defaultBaseline
+	}
+	return &Tracker{
+		baseline: int64(baseline),
+		topics:   make(map[string]*topicStats),
+		logf:     log.Printf,
+	}
+}
+
+// Expect registers the matchFields every payload on a reference topic should carry.
+func (t *Tracker) Expect(topic string, fields []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ts := t.topicLocked(topic, RoleReference)
+	for _, f := range fields {
+		if _, ok := ts.matchFields[f]; !ok {
+			ts.matchFields[f] = &expectation{}
+		}
+	}
+}
+
+// Observe records one decoded JSON payload seen on topic.
+func (t *Tracker) Observe(topic string, role Role, body any) {
+	paths := make(map[string]string)
+	collect("", body, paths)
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ts := t.topicLocked(topic, role)
+	ts.messages++
+
+	for path, typ := range paths {
+		fs, ok := ts.fields[path]
+		if !ok {
+			fs = &fieldStats{types: make(map[string]int64), firstSeen: now, firstMessage: ts.messages}
+			ts.fields[path] = fs
+			if ts.messages > t.baseline {
+				t.logf("schema drift: new field %s on %s topic %s", path, ts.role, topic)
+			}
+		}
+		if _, seen := fs.types[typ]; !seen && len(fs.types) > 0 {
+			t.logf("schema drift: field %s on %s topic %s changed type to %s (was %s)", path, ts.role, topic, typ, typeList(fs.types))
+		}
+		fs.types[typ]++
+		fs.count++
+		fs.lastSeen = now
+	}
+
+	for field, exp := range ts.matchFields {
+		if hasAnyPath(paths, field) {
+			continue
+		}
+		exp.missing++
+		exp.lastMissing = now
+		if exp.missing == 1 || exp.missing%100 == 0 {
+			t.logf("schema drift: matchField %s missing from %d payload(s) on reference topic %s", field, exp.missing, topic)
+		}
+	}
+}
+
+func (t *Tracker) topicLocked(topic string, role Role) *topicStats {
+	ts, ok := t.topics[topic]
+	if !ok {
+		ts = &topicStats{
+			role:        role,
+			fields:      make(map[string]*fieldStats),
+			matchFields: make(map[string]*expectation),
+		}
+		t.topics[topic] = ts
+	}
+	return ts
+}
+
+// Report returns the schema report for one topic.
+func (t *Tracker) Report(topic string) (Report, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ts, ok := t.topics[topic]
+	if !ok {
+		return Report{}, false
+	}
+	return t.reportLocked(topic, ts), true
+}
+
+// Reports returns schema reports for every observed topic, sorted by topic.
+func (t *Tracker) Reports() []Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Report, 0, len(t.topics))
+	for topic, ts := range t.topics {
+		out = append(out, t.reportLocked(topic, ts))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Topic < out[j].Topic })
+	return out
+}
+
+func (t *Tracker) reportLocked(topic string, ts *topicStats) Report {
+	rep := Report{
+		Topic:    topic,
+		Role:     ts.role,
+		Messages: ts.messages,
+		Fields:   make([]FieldReport, 0, len(ts.fields)),
+		Drift: Drift{
+			NewFields:          []string{},
+			TypeChanges:        []string{},
+			MissingMatchFields: []MissingReport{},
+		},
+	}
+	for path, fs := range ts.fields {
+		types := make(map[string]int64, len(fs.types))
+		for k, v := range fs.types {
+			types[k] = v
+		}
+		isNew := fs.firstMessage > t.baseline
+		rep.Fields = append(rep.Fields, FieldReport{
+			Path:      path,
+			Types:     types,
+			Count:     fs.count,
+			FirstSeen: fs.firstSeen,
+			LastSeen:  fs.lastSeen,
+			New:       isNew,
+		})
+		if isNew {
+			rep.Drift.NewFields = append(rep.Drift.NewFields, path)
+		}
+		if len(fs.types) > 1 {
+			rep.Drift.TypeChanges = append(rep.Drift.TypeChanges, path)
+		}
+	}
+	for field, exp := range ts.matchFields {
+		if exp.missing == 0 {
+			continue
+		}
+		rep.Drift.MissingMatchFields = append(rep.Drift.MissingMatchFields, MissingReport{
+			Field:       field,
+			Missing:     exp.missing,
+			LastMissing: exp.lastMissing,
+		})
+	}
+	sort.Slice(rep.Fields, func(i, j int) bool { return rep.Fields[i].Path < rep.Fields[j].Path })
+	sort.Strings(rep.Drift.NewFields)
+	sort.Strings(rep.Drift.TypeChanges)
+	sort.Slice(rep.Drift.MissingMatchFields, func(i, j int) bool {
+		return rep.Drift.MissingMatchFields[i].Field < rep.Drift.MissingMatchFields[j].Field
+	})
+	return rep
+}
+
+// collect walks a decoded JSON value recording each leaf path and its JSON type.
+func collect(prefix string, v any, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			collect(path, child, out)
+		}
+	case []any:
+		for _, item := range val {
+			collect(prefix+"[]", item, out)
+		}
+	case string:
+		out[prefix] = "string"
+	case float64, int, int64:
+		out[prefix] = "number"
+	case bool:
+		out[prefix] = "bool"
+	case nil:
+		out[prefix] = "null"
+	default:
+		out[prefix] = "unknown"
+	}
+}
+
+// hasAnyPath reports whether any "|"-separated fallback path of field was observed. A *
+// segment matches any one key.
+func hasAnyPath(paths map[string]string, field string) bool {
+	for _, path := range strings.Split(field, "|") {
+		if _, ok := paths[path]; ok {
+			return true
+		}
+		if !strings.Contains(path, "*") {
+			continue
+		}
+		want := strings.Split(path, ".")
+		for observed := range paths {
+			if wildcardPathMatch(want, strings.Split(observed, ".")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wildcardPathMatch(want, got []string) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for i, part := range want {
+		if part != "*" && part != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func typeList(types map[string]int64) string {
+	names := make([]string, 0, len(types))
+	for k := range types {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
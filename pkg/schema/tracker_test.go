@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, raw string) any {
+	t.Helper()
+	var body any
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	return body
+}
+
+func TestTrackerReportsDrift(t *testing.T) {
+	tr := NewTracker(2)
+	var logs []string
+	tr.logf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	tr.Expect("ref", []string{"caseId", "sub.id"})
+
+	payloads := []string{
+		`{"caseId":"c1","sub":{"id":"s1"},"items":[{"n":1}]}`,
+		`{"caseId":"c2","sub":{"id":"s2"}}`,
+		`{"caseId":3,"sub":{"id":"s3"},"extra":true}`,
+		`{"legacyCaseId":"c4","sub":{"id":"s4"}}`,
+	}
+	for _, p := range payloads {
+		tr.Observe("ref", RoleReference, decode(t, p))
+	}
+
+	rep, ok := tr.Report("ref")
+	if !ok {
+		t.Fatalf("expected report for ref topic")
+	}
+	if rep.Role != RoleReference || rep.Messages != 4 {
+		t.Fatalf("unexpected report header: %+v", rep)
+	}
+	if got := strings.Join(rep.Drift.NewFields, ","); got != "extra,legacyCaseId" {
+		t.Fatalf("unexpected new fields %q", got)
+	}
+	if got := strings.Join(rep.Drift.TypeChanges, ","); got != "caseId" {
+		t.Fatalf("unexpected type changes %q", got)
+	}
+	if len(rep.Drift.MissingMatchFields) != 1 || rep.Drift.MissingMatchFields[0].Field != "caseId" || rep.Drift.MissingMatchFields[0].Missing != 1 {
+		t.Fatalf("unexpected missing matchFields: %+v", rep.Drift.MissingMatchFields)
+	}
+	var sawArray bool
+	for _, f := range rep.Fields {
+		if f.Path == "items[].n" {
+			sawArray = f.Types["number"] == 1
+		}
+	}
+	if !sawArray {
+		t.Fatalf("expected array paths to collapse to items[].n: %+v", rep.Fields)
+	}
+	if len(logs) != 4 {
+		t.Fatalf("expected 4 drift warnings (type change, 2 new fields, missing field), got %d: %v", len(logs), logs)
+	}
+}
+
+func TestTrackerReportsSorted(t *testing.T) {
+	tr := NewTracker(0)
+	tr.logf = func(string, ...any) {}
+	tr.Observe("b-topic", RoleSource, decode(t, `{"x":1}`))
+	tr.Observe("a-topic", RoleSource, decode(t, `{"y":null}`))
+
+	reps := tr.Reports()
+	if len(reps) != 2 || reps[0].Topic != "a-topic" || reps[1].Topic != "b-topic" {
+		t.Fatalf("unexpected reports order: %+v", reps)
+	}
+	if _, ok := tr.Report("missing"); ok {
+		t.Fatalf("expected unknown topic to have no report")
+	}
+}
+
+func TestTrackerFallbackMatchFields(t *testing.T) {
+	tr := NewTracker(0)
+	tr.logf = func(string, ...any) {}
+	tr.Expect("ref", []string{"caseId|legacyCaseId"})
+	tr.Observe("ref", RoleReference, decode(t, `{"legacyCaseId":"c1"}`))
+
+	rep, _ := tr.Report("ref")
+	if len(rep.Drift.MissingMatchFields) != 0 {
+		t.Fatalf("expected fallback path to satisfy matchField, got %+v", rep.Drift.MissingMatchFields)
+	}
+}
+
+func TestTrackerWildcardMatchFields(t *testing.T) {
+	tr := NewTracker(0)
+	tr.logf = func(string, ...any) {}
+	tr.Expect("ref", []string{"accounts.*.number", "owners.*.id"})
+	tr.Observe("ref", RoleReference, decode(t, `{"accounts":{"a1":{"number":"N1"}},"owners":{"o1":{"name":"x"}}}`))
+
+	rep, _ := tr.Report("ref")
+	if len(rep.Drift.MissingMatchFields) != 1 || rep.Drift.MissingMatchFields[0].Field != "owners.*.id" {
+		t.Fatalf("expected only owners.*.id missing, got %+v", rep.Drift.MissingMatchFields)
+	}
+}
@@ -0,0 +1,379 @@
+package serialize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Avro encodes JSON payloads as Avro binary data of one schema. Payload fields the schema
+// does not declare are ignored, and ones it declares with a default may be missing. A
+// union takes the first of its branches the JSON value fits. Logical types are encoded as
+// their underlying type.
+type Avro struct {
+	schema *avroType
+	// header is the Confluent wire format prefix of a registered schema, empty otherwise.
+	header []byte
+}
+
+// NewAvro parses an Avro schema in its JSON form.
+func NewAvro(schema string) (*Avro, error) {
+	var raw any
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("avro schema: %w", err)
+	}
+	p := avroParser{named: make(map[string]*avroType)}
+	t, err := p.parse(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("avro schema: %w", err)
+	}
+	return &Avro{schema: t}, nil
+}
+
+// Framed prefixes every encoded value with the Confluent wire format header of the
+// schema registered under id: a zero magic byte and the big-endian id.
+func (a *Avro) Framed(id int) *Avro {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return &Avro{schema: a.schema, header: header}
+}
+
+// Encode implements Encoder.
+func (a *Avro) Encode(value []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+	out := append([]byte(nil), a.header...)
+	out, err := a.schema.encode(out, body, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+	return out, nil
+}
+
+// avroType is one node of a parsed schema. Named types are shared by every reference.
+type avroType struct {
+	kind     string
+	fields   []avroField
+	symbols  map[string]int
+	items    *avroType
+	branches []*avroType
+	size     int
+}
+
+type avroField struct {
+	name       string
+	typ        *avroType
+	def        any
+	hasDefault bool
+}
+
+type avroParser struct {
+	named map[string]*avroType
+}
+
+var avroPrimitives = map[string]bool{"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true}
+
+func (p *avroParser) parse(raw any, namespace string) (*avroType, error) {
+	switch s := raw.(type) {
+	case string:
+		if avroPrimitives[s] {
+			return &avroType{kind: s}, nil
+		}
+		if t, ok := p.named[fullName(s, namespace)]; ok {
+			return t, nil
+		}
+		if t, ok := p.named[s]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", s)
+	case []any:
+		t := &avroType{kind: "union"}
+		for _, b := range s {
+			branch, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			t.branches = append(t.branches, branch)
+		}
+		if len(t.branches) == 0 {
+			return nil, fmt.Errorf("empty union")
+		}
+		return t, nil
+	case map[string]any:
+		return p.parseComplex(s, namespace)
+	}
+	return nil, fmt.Errorf("unexpected schema %v", raw)
+}
+
+func (p *avroParser) parseComplex(s map[string]any, namespace string) (*avroType, error) {
+	kind, _ := s["type"].(string)
+	if ns, ok := s["namespace"].(string); ok {
+		namespace = ns
+	}
+	name, _ := s["name"].(string)
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		if name == "" {
+			return nil, fmt.Errorf("%s without a name", kind)
+		}
+	case "array", "map":
+	default:
+		// a primitive in object form, e.g. with a logicalType, or a nested type
+		if kind == "" {
+			return p.parse(s["type"], namespace)
+		}
+		return p.parse(kind, namespace)
+	}
+
+	t := &avroType{kind: kind}
+	if name != "" {
+		full := fullName(name, namespace)
+		if i := strings.LastIndex(full, "."); i >= 0 {
+			namespace = full[:i]
+		}
+		// registered first, so a record can refer to itself
+		p.named[full] = t
+	}
+	switch kind {
+	case "record", "error":
+		t.kind = "record"
+		fields, _ := s["fields"].([]any)
+		for _, f := range fields {
+			field, _ := f.(map[string]any)
+			fname, _ := field["name"].(string)
+			if fname == "" {
+				return nil, fmt.Errorf("record %s: field without a name", name)
+			}
+			ft, err := p.parse(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("record %s: field %s: %w", name, fname, err)
+			}
+			def, hasDefault := field["default"]
+			t.fields = append(t.fields, avroField{name: fname, typ: ft, def: def, hasDefault: hasDefault})
+		}
+	case "enum":
+		symbols, _ := s["symbols"].([]any)
+		t.symbols = make(map[string]int, len(symbols))
+		for i, sym := range symbols {
+			str, _ := sym.(string)
+			t.symbols[str] = i
+		}
+	case "fixed":
+		size, ok := s["size"].(float64)
+		if !ok || size < 0 {
+			return nil, fmt.Errorf("fixed %s without a size", name)
+		}
+		t.size = int(size)
+	case "array", "map":
+		key := "items"
+		if kind == "map" {
+			key = "values"
+		}
+		items, err := p.parse(s[key], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kind, err)
+		}
+		t.items = items
+	}
+	return t, nil
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// encode appends v, decoded from JSON with numbers as json.Number, to out. path names v
+// in errors.
+func (t *avroType) encode(out []byte, v any, path string) ([]byte, error) {
+	switch t.kind {
+	case "null":
+		if v != nil {
+			return nil, fmt.Errorf("%s: want null, got %T", pathName(path), v)
+		}
+		return out, nil
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a boolean, got %T", pathName(path), v)
+		}
+		if b {
+			return append(out, 1), nil
+		}
+		return append(out, 0), nil
+	case "int", "long":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a number, got %T", pathName(path), v)
+		}
+		i, err := n.Int64()
+		if err != nil || (t.kind == "int" && (i < math.MinInt32 || i > math.MaxInt32)) {
+			return nil, fmt.Errorf("%s: %s is not an %s", pathName(path), n, t.kind)
+		}
+		return binary.AppendVarint(out, i), nil
+	case "float", "double":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a number, got %T", pathName(path), v)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pathName(path), err)
+		}
+		if t.kind == "float" {
+			return binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(out, math.Float64bits(f)), nil
+	case "string", "bytes":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a string, got %T", pathName(path), v)
+		}
+		out = binary.AppendVarint(out, int64(len(s)))
+		return append(out, s...), nil
+	case "fixed":
+		s, ok := v.(string)
+		if !ok || len(s) != t.size {
+			return nil, fmt.Errorf("%s: want a string of %d bytes", pathName(path), t.size)
+		}
+		return append(out, s...), nil
+	case "enum":
+		s, _ := v.(string)
+		i, ok := t.symbols[s]
+		if !ok {
+			return nil, fmt.Errorf("%s: %v is not a symbol of the enum", pathName(path), v)
+		}
+		return binary.AppendVarint(out, int64(i)), nil
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want an array, got %T", pathName(path), v)
+		}
+		if len(items) > 0 {
+			out = binary.AppendVarint(out, int64(len(items)))
+		}
+		for i, item := range items {
+			var err error
+			if out, err = t.items.encode(out, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return append(out, 0), nil
+	case "map":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want an object, got %T", pathName(path), v)
+		}
+		if len(m) > 0 {
+			out = binary.AppendVarint(out, int64(len(m)))
+		}
+		for key, item := range m {
+			out = binary.AppendVarint(out, int64(len(key)))
+			out = append(out, key...)
+			var err error
+			if out, err = t.items.encode(out, item, joinPath(path, key)); err != nil {
+				return nil, err
+			}
+		}
+		return append(out, 0), nil
+	case "record":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want an object, got %T", pathName(path), v)
+		}
+		for _, f := range t.fields {
+			fv, ok := m[f.name]
+			if !ok {
+				if !f.hasDefault {
+					return nil, fmt.Errorf("%s: missing", joinPath(path, f.name))
+				}
+				fv = jsonNumbers(f.def)
+			}
+			var err error
+			if out, err = f.typ.encode(out, fv, joinPath(path, f.name)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case "union":
+		for i, b := range t.branches {
+			if !b.fits(v) {
+				continue
+			}
+			return b.encode(binary.AppendVarint(out, int64(i)), v, path)
+		}
+		return nil, fmt.Errorf("%s: %T fits no branch of the union", pathName(path), v)
+	}
+	return nil, fmt.Errorf("%s: unsupported type %s", pathName(path), t.kind)
+}
+
+// fits reports whether a union branch of type t can hold v.
+func (t *avroType) fits(v any) bool {
+	switch v.(type) {
+	case nil:
+		return t.kind == "null"
+	case bool:
+		return t.kind == "boolean"
+	case json.Number:
+		n := v.(json.Number)
+		switch t.kind {
+		case "int", "long":
+			_, err := n.Int64()
+			return err == nil
+		case "float", "double":
+			return true
+		}
+	case string:
+		return t.kind == "string" || t.kind == "bytes" || t.kind == "enum" || t.kind == "fixed"
+	case []any:
+		return t.kind == "array"
+	case map[string]any:
+		return t.kind == "record" || t.kind == "map"
+	}
+	return false
+}
+
+// jsonNumbers converts the float64 numbers of a schema default to json.Number, as payload
+// values are decoded.
+func jsonNumbers(v any) any {
+	switch x := v.(type) {
+	case float64:
+		return json.Number(fmt.Sprint(x))
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = jsonNumbers(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = jsonNumbers(item)
+		}
+		return out
+	}
+	return v
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "payload"
+	}
+	return path
+}
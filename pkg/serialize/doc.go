@@ -0,0 +1,3 @@
+// Package serialize re-encodes JSON payloads for destinations that expect another format:
+// Avro, framed for a Confluent schema registry when one is used, or protobuf.
+package serialize
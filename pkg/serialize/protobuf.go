@@ -0,0 +1,54 @@
+package serialize
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Protobuf encodes JSON payloads as one protobuf message, mapping fields as protojson
+// does: by their JSON or proto names, with 64-bit integers as numbers or strings and
+// enums by name. Payload fields the message does not declare are ignored.
+type Protobuf struct {
+	message protoreflect.MessageType
+}
+
+// NewProtobuf finds message, a fully qualified name such as shop.v1.Order, in a
+// serialized FileDescriptorSet that includes the imports of its files.
+func NewProtobuf(descriptorSet []byte, message string) (*Protobuf, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(descriptorSet, &set); err != nil {
+		return nil, fmt.Errorf("protobuf descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf descriptor set: %w", err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf message %s: %w", message, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("protobuf %s is not a message", message)
+	}
+	return &Protobuf{message: dynamicpb.NewMessageType(md)}, nil
+}
+
+// Encode implements Encoder.
+func (p *Protobuf) Encode(value []byte) ([]byte, error) {
+	msg := p.message.New().Interface()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(value, msg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+	out, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+	return out, nil
+}
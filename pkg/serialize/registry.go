@@ -0,0 +1,61 @@
+package serialize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Registry registers schemas with a Confluent-compatible schema registry.
+type Registry struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+// NewRegistry returns a client of the registry at baseURL that sends headers, such as
+// Authorization, with every request.
+func NewRegistry(baseURL string, client *http.Client, headers map[string]string) *Registry {
+	return &Registry{url: strings.TrimSuffix(baseURL, "/"), client: client, headers: headers}
+}
+
+// RegisterAvro registers schema under subject and returns its id. Registering a schema the
+// subject already has returns the existing id, so it is safe on every start.
+func (r *Registry) RegisterAvro(ctx context.Context, subject, schema string) (int, error) {
+	body, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{schema})
+	if err != nil {
+		return 0, fmt.Errorf("encode registry request: %w", err)
+	}
+	endpoint := r.url + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build registry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	for name, value := range r.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("register schema for %s: %w", subject, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("register schema for %s: registry returned %s: %s", subject, resp.Status, bytes.TrimSpace(detail))
+	}
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return 0, fmt.Errorf("register schema for %s: decode response: %w", subject, err)
+	}
+	return registered.ID, nil
+}
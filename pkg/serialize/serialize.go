@@ -0,0 +1,14 @@
+package serialize
+
+import (
+	"errors"
+)
+
+// ErrEncode marks a payload an Encoder cannot represent in its format, such as one
+// missing a required field; the payload is at fault, not the encoder.
+var ErrEncode = errors.New("cannot encode payload")
+
+// Encoder turns one JSON payload into the bytes of its output format.
+type Encoder interface {
+	Encode(value []byte) ([]byte, error)
+}
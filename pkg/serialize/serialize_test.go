@@ -0,0 +1,135 @@
+package serialize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	bridgev1 "kafka-bridge/proto/kafkabridge/v1"
+)
+
+const orderSchema = `{
+	"type": "record", "name": "Order", "namespace": "shop",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "qty", "type": "int"},
+		{"name": "note", "type": ["null", "string"], "default": null},
+		{"name": "tags", "type": {"type": "array", "items": "string"}, "default": []},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}, "default": "NEW"}
+	]
+}`
+
+func TestAvroEncode(t *testing.T) {
+	avro, err := NewAvro(orderSchema)
+	if err != nil {
+		t.Fatalf("NewAvro: %v", err)
+	}
+	for _, tc := range []struct {
+		payload string
+		want    []byte
+	}{
+		// id "a", qty 3, null note, no tags, NEW
+		{`{"id":"a","qty":3}`, []byte{2, 'a', 6, 0, 0, 0}},
+		// unknown fields are ignored; note takes the string branch
+		{`{"id":"a","qty":-1,"note":"hi","tags":["x"],"status":"PAID","extra":true}`, []byte{2, 'a', 1, 2, 4, 'h', 'i', 2, 2, 'x', 0, 2}},
+	} {
+		got, err := avro.Encode([]byte(tc.payload))
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", tc.payload, err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("Encode(%s) = %v, want %v", tc.payload, got, tc.want)
+		}
+	}
+
+	framed, err := avro.Framed(7).Encode([]byte(`{"id":"a","qty":3}`))
+	if err != nil || !bytes.Equal(framed, []byte{0, 0, 0, 0, 7, 2, 'a', 6, 0, 0, 0}) {
+		t.Fatalf("framed Encode = %v, %v; want the wire format header before the record", framed, err)
+	}
+
+	for _, payload := range []string{`{"qty":3}`, `{"id":"a","qty":1.5}`, `{"id":"a","qty":3,"status":"LOST"}`, `not json`} {
+		if _, err := avro.Encode([]byte(payload)); !errors.Is(err, ErrEncode) {
+			t.Errorf("Encode(%s) = %v, want ErrEncode", payload, err)
+		}
+	}
+}
+
+func TestNewAvroRejectsInvalidSchemas(t *testing.T) {
+	for _, schema := range []string{`{"type":"record","fields":[]}`, `"Missing"`, `[]`, `{`} {
+		if _, err := NewAvro(schema); err == nil {
+			t.Errorf("NewAvro(%s) succeeded", schema)
+		}
+	}
+}
+
+func TestProtobufEncode(t *testing.T) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+		protodesc.ToFileDescriptorProto(bridgev1.File_reference_proto),
+	}})
+	if err != nil {
+		t.Fatalf("marshal descriptor set: %v", err)
+	}
+	pb, err := NewProtobuf(set, "kafkabridge.v1.AddValuesRequest")
+	if err != nil {
+		t.Fatalf("NewProtobuf: %v", err)
+	}
+	got, err := pb.Encode([]byte(`{"route":"route-a","values":["x","y"],"idempotencyKey":"k","unknown":1}`))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var req bridgev1.AddValuesRequest
+	if err := proto.Unmarshal(got, &req); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if req.GetRoute() != "route-a" || len(req.GetValues()) != 2 || req.GetIdempotencyKey() != "k" {
+		t.Fatalf("decoded %v", &req)
+	}
+	if _, err := pb.Encode([]byte(`{"route":7}`)); !errors.Is(err, ErrEncode) {
+		t.Fatalf("Encode of a mistyped field = %v, want ErrEncode", err)
+	}
+	if _, err := NewProtobuf(set, "kafkabridge.v1.Missing"); err == nil {
+		t.Fatal("NewProtobuf of an unknown message succeeded")
+	}
+}
+
+func TestRegistryRegisterAvro(t *testing.T) {
+	var gotPath, gotAuth, gotSchema string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Schema string `json:"schema"`
+		}
+		_ = json.Unmarshal(body, &req)
+		gotSchema = req.Schema
+		if r.URL.Path == "/subjects/bad-value/versions" {
+			http.Error(w, `{"error_code":409,"message":"incompatible"}`, http.StatusConflict)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":42}`))
+	}))
+	defer srv.Close()
+
+	reg := NewRegistry(srv.URL+"/", srv.Client(), map[string]string{"Authorization": "Basic abc"})
+	id, err := reg.RegisterAvro(context.Background(), "orders-value", orderSchema)
+	if err != nil || id != 42 {
+		t.Fatalf("RegisterAvro = %d, %v; want 42", id, err)
+	}
+	if gotPath != "/subjects/orders-value/versions" || gotAuth != "Basic abc" || gotSchema != orderSchema {
+		t.Fatalf("registry got path %q, auth %q, schema %q", gotPath, gotAuth, gotSchema)
+	}
+	if _, err := reg.RegisterAvro(context.Background(), "bad-value", orderSchema); err == nil {
+		t.Fatal("RegisterAvro succeeded on a 409")
+	}
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"errors"
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// DefaultFalsePositiveRate is used by Probabilistic when FalsePositiveRate is zero.
+const DefaultFalsePositiveRate = 0.01
+
+// ExactSet is the complete fingerprint set of a probabilistic route kept outside memory,
+// such as the SQLite storage backend. It confirms filter hits and supplies their
+// provenance.
+type ExactSet interface {
+	Lookup(route, fingerprint string) (Metadata, bool, error)
+}
+
+// Probabilistic configures a route to keep its fingerprints in a counting bloom filter
+// instead of the in-memory map. Contains reports every cached fingerprint but also,
+// at about FalsePositiveRate, some that are not, unless Exact is set to confirm hits.
+type Probabilistic struct {
+	// ExpectedValues sizes the filter; the false positive rate grows once it holds more.
+	ExpectedValues    int
+	FalsePositiveRate float64
+	Exact             ExactSet
+}
+
+// probRoute is the filter of one probabilistic route. count tracks adds minus removes.
+type probRoute struct {
+	Probabilistic
+	filter         *countingBloom
+	count          int
+	falsePositives atomic.Uint64
+	exactErrors    atomic.Uint64
+}
+
+// SetProbabilistic switches route to a counting bloom filter sized by p, replacing the
+// fingerprints already cached for it. It must be called before the route's values are
+// restored. Entries, snapshots, Clone, and Compact see no values for the route, and
+// without p.Exact matches carry no provenance.
+func (s *MatchStore) SetProbabilistic(route string, p Probabilistic) error {
+	if p.ExpectedValues <= 0 {
+		return errors.New("expected values must be positive")
+	}
+	if p.FalsePositiveRate == 0 {
+		p.FalsePositiveRate = DefaultFalsePositiveRate
+	}
+	if p.FalsePositiveRate < 0 || p.FalsePositiveRate >= 1 {
+		return errors.New("false positive rate must be between 0 and 1")
+	}
+	pr := &probRoute{Probabilistic: p, filter: newCountingBloom(p.ExpectedValues, p.FalsePositiveRate)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fp := range s.values[route] {
+		pr.filter.add(fp)
+		pr.count++
+	}
+	delete(s.values, route)
+	s.indexResetLocked(route)
+	s.filters[route] = pr
+	return nil
+}
+
+// probabilistic returns route's filter, or nil when the route keeps exact values.
+func (s *MatchStore) probabilistic(route string) *probRoute {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filters[route]
+}
+
+func (s *MatchStore) resetFiltersLocked() {
+	for _, pr := range s.filters {
+		pr.filter.reset()
+		pr.count = 0
+	}
+}
+
+// restoreProbabilistic adds fingerprint to route's filter and reports whether the route
+// is probabilistic. Restores use it to fill filters without building the route's map.
+func (s *MatchStore) restoreProbabilistic(route, fingerprint string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, ok := s.filters[route]
+	if ok {
+		pr.filter.add(fingerprint)
+		pr.count++
+	}
+	return ok
+}
+
+// probLookup checks the filter and confirms a hit with the exact set, if there is one.
+func (s *MatchStore) probLookup(pr *probRoute, route, fingerprint string) (Metadata, bool) {
+	s.mu.RLock()
+	hit := pr.filter.contains(fingerprint)
+	s.mu.RUnlock()
+	if !hit || pr.Exact == nil {
+		return Metadata{}, hit
+	}
+	return pr.exactLookup(route, fingerprint, s.logf)
+}
+
+// exactLookup asks the route's exact set about fingerprint. Errors are counted and the
+// filter's answer stands, so an unavailable set never hides a cached value.
+func (pr *probRoute) exactLookup(route, fingerprint string, logf func(string, ...any)) (Metadata, bool) {
+	meta, ok, err := pr.Exact.Lookup(route, fingerprint)
+	if err != nil {
+		if n := pr.exactErrors.Add(1); n == 1 || n%evictionLogEvery == 0 {
+			logf("warn: exact set lookup for route %s failed, trusting bloom filter (%d failure(s)): %v", route, n, err)
+		}
+		return Metadata{}, true
+	}
+	if !ok {
+		pr.falsePositives.Add(1)
+	}
+	return meta, ok
+}
+
+// probAdd records fingerprint in the filter of a probabilistic route. Fingerprints the
+// filter or the exact set already holds are not counted twice, though the observer still
+// sees the add so persistence keeps the latest provenance.
+func (s *MatchStore) probAdd(pr *probRoute, route, fingerprint, canonical string, meta Metadata, replicated bool) bool {
+	var present bool
+	if pr.Exact != nil {
+		_, present, _ = pr.Exact.Lookup(route, fingerprint)
+	}
+	s.mu.Lock()
+	if pr.Exact == nil {
+		present = pr.filter.contains(fingerprint)
+	}
+	if !present {
+		pr.filter.add(fingerprint)
+		pr.count++
+	}
+	s.queueLocked(Mutation{Op: OpAdd, Route: route, Fingerprint: fingerprint, Canonical: canonical, Meta: meta, Replicated: replicated})
+	s.mu.Unlock()
+
+	s.flush()
+	return !present
+}
+
+// probRemove drops fingerprint from the filter of a probabilistic route if it appears to
+// be cached. Without an exact set a false positive decrements counters shared with other
+// fingerprints, which can then be missed.
+func (s *MatchStore) probRemove(pr *probRoute, route, fingerprint string, replicated bool) bool {
+	present := true
+	if pr.Exact != nil {
+		var err error
+		if _, present, err = pr.Exact.Lookup(route, fingerprint); err != nil {
+			return false
+		}
+	}
+	s.mu.Lock()
+	if !present || !pr.filter.contains(fingerprint) {
+		s.mu.Unlock()
+		return false
+	}
+	pr.filter.remove(fingerprint)
+	pr.count--
+	s.queueLocked(Mutation{Op: OpRemove, Route: route, Fingerprint: fingerprint, Replicated: replicated})
+	s.mu.Unlock()
+
+	s.flush()
+	return true
+}
+
+// countingBloom is a counting bloom filter with 4-bit counters. Saturated counters are
+// never decremented, so removals cannot cause false negatives through overflow.
+type countingBloom struct {
+	counters []uint64 // 16 counters per word
+	m        uint64
+	k        uint64
+	seed     maphash.Seed
+}
+
+const counterMax = 15
+
+// newCountingBloom sizes a filter for n values at false positive rate p.
+func newCountingBloom(n int, p float64) *countingBloom {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &countingBloom{counters: make([]uint64, (m+15)/16), m: m, k: k, seed: maphash.MakeSeed()}
+}
+
+// positions derives the k counter indexes of s by double hashing.
+func (b *countingBloom) positions(s string, fn func(idx uint64) bool) {
+	h := maphash.String(b.seed, s)
+	h1, h2 := h&0xffffffff, h>>32|1
+	for i := uint64(0); i < b.k; i++ {
+		if !fn((h1 + i*h2) % b.m) {
+			return
+		}
+	}
+}
+
+func (b *countingBloom) get(idx uint64) uint64 {
+	return b.counters[idx/16] >> (idx % 16 * 4) & counterMax
+}
+
+func (b *countingBloom) set(idx, v uint64) {
+	shift := idx % 16 * 4
+	word := &b.counters[idx/16]
+	*word = *word&^(counterMax<<shift) | v<<shift
+}
+
+func (b *countingBloom) add(s string) {
+	b.positions(s, func(idx uint64) bool {
+		if c := b.get(idx); c < counterMax {
+			b.set(idx, c+1)
+		}
+		return true
+	})
+}
+
+func (b *countingBloom) remove(s string) {
+	b.positions(s, func(idx uint64) bool {
+		if c := b.get(idx); c > 0 && c < counterMax {
+			b.set(idx, c-1)
+		}
+		return true
+	})
+}
+
+func (b *countingBloom) contains(s string) bool {
+	found := true
+	b.positions(s, func(idx uint64) bool {
+		found = b.get(idx) > 0
+		return found
+	})
+	return found
+}
+
+func (b *countingBloom) reset() {
+	clear(b.counters)
+}
+
+// bytes is the filter's memory footprint.
+func (b *countingBloom) bytes() int {
+	return len(b.counters) * 8
+}
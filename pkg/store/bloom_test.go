@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCountingBloomFalsePositiveRate(t *testing.T) {
+	const n = 20000
+	b := newCountingBloom(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.add("in-" + strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		if !b.contains("in-" + strconv.Itoa(i)) {
+			t.Fatalf("false negative for in-%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if b.contains("out-" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.02 {
+		t.Fatalf("false positive rate %.4f, want about 0.01", rate)
+	}
+
+	for i := 0; i < n; i++ {
+		b.remove("in-" + strconv.Itoa(i))
+	}
+	for _, w := range b.counters {
+		if w != 0 {
+			t.Fatal("counters not back to zero after removing every value")
+		}
+	}
+}
+
+func TestMatchStoreProbabilistic(t *testing.T) {
+	s := NewMatchStore()
+	s.Add("route", "before")
+	if err := s.SetProbabilistic("route", Probabilistic{ExpectedValues: 1000}); err != nil {
+		t.Fatalf("SetProbabilistic: %v", err)
+	}
+	var mutations []Mutation
+	s.SetObserver(func(m Mutation) { mutations = append(mutations, m) })
+
+	if !s.Add("route", "abc") || s.Add("route", "abc") {
+		t.Fatal("expected the first add only to be new")
+	}
+	if !s.Contains("route", "before") || !s.Contains("route", "abc") || s.Size("route") != 2 {
+		t.Fatalf("unexpected filter contents, size %d", s.Size("route"))
+	}
+	if len(s.Entries("route")) != 0 {
+		t.Fatal("probabilistic route should not enumerate entries")
+	}
+	if !s.Remove("route", "abc") || s.Contains("route", "abc") || s.Size("route") != 1 {
+		t.Fatal("remove did not drop the value")
+	}
+	if len(mutations) != 3 || mutations[2].Op != OpRemove {
+		t.Fatalf("unexpected mutations: %+v", mutations)
+	}
+	if stats := s.Stats("route"); stats.Values != 1 || stats.FilterBytes == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if err := s.SetProbabilistic("other", Probabilistic{}); err == nil {
+		t.Fatal("expected error without expected values")
+	}
+}
+
+func TestMatchStoreProbabilisticVerifiesWithSQLite(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := NewMatchStore()
+	if err := s.SetProbabilistic("route", Probabilistic{ExpectedValues: 10, FalsePositiveRate: 0.5, Exact: db}); err != nil {
+		t.Fatalf("SetProbabilistic: %v", err)
+	}
+	s.SetObserver(db.Record)
+	s.AddWithMeta("route", "abc", Metadata{Source: SourceHTTP})
+	if meta, ok := s.Lookup("route", "abc"); !ok || meta.Source != SourceHTTP {
+		t.Fatalf("pending value not confirmed: %+v, %v", meta, ok)
+	}
+	if err := db.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if meta, ok := s.Lookup("route", "abc"); !ok || meta.Source != SourceHTTP {
+		t.Fatalf("persisted value not confirmed: %+v, %v", meta, ok)
+	}
+
+	for i := 0; i < 9; i++ {
+		s.Add("route", "v"+strconv.Itoa(i))
+	}
+	// a full filter lets some absent values through; none may be reported
+	for i := 0; i < 1000; i++ {
+		if s.Contains("route", "absent-"+strconv.Itoa(i)) {
+			t.Fatalf("absent-%d reported as cached", i)
+		}
+	}
+	if s.Stats("route").FalsePositives == 0 {
+		t.Fatal("expected rejected filter hits to be counted")
+	}
+
+	if err := db.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	restored := NewMatchStore()
+	if err := restored.SetProbabilistic("route", Probabilistic{ExpectedValues: 10, Exact: db}); err != nil {
+		t.Fatalf("SetProbabilistic: %v", err)
+	}
+	if n, err := db.Restore(context.Background(), restored); err != nil || n != 10 || !restored.Contains("route", "abc") {
+		t.Fatalf("Restore = %d, %v; contains %v", n, err, restored.Contains("route", "abc"))
+	}
+}
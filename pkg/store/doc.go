@@ -0,0 +1,4 @@
+// Package store holds the per-route match cache shared by the bridge's matchers, with
+// optional size limits, mutation observers, and snapshot persistence to JSON files or
+// SQLite.
+package store
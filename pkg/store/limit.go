@@ -0,0 +1,204 @@
+package store
+
+import (
+	"sync/atomic"
+)
+
+// EvictionPolicy selects what happens when a route reaches its MaxValues cap.
+type EvictionPolicy string
+
+// Eviction policies supported by Limit.
+const (
+	// EvictLRU drops the least recently matched fingerprint.
+	EvictLRU EvictionPolicy = "lru"
+	// EvictLFU drops the least frequently matched fingerprint.
+	EvictLFU EvictionPolicy = "lfu"
+	// EvictRejectNew keeps the cache as is and refuses new fingerprints.
+	EvictRejectNew EvictionPolicy = "reject-new"
+)
+
+// evictionSamples is how many fingerprints are compared when choosing a victim. Eviction
+// is approximate: it samples the route rather than keeping a global ordering.
+const evictionSamples = 16
+
+// evictionLogEvery throttles the eviction warnings logged per route.
+const evictionLogEvery = 10000
+
+// Limit caps the fingerprints cached for one route. A zero MaxValues disables the cap.
+type Limit struct {
+	MaxValues int
+	Policy    EvictionPolicy
+}
+
+// RouteStats reports a route's cache size and how often its cap has been hit.
+type RouteStats struct {
+	Values    int
+	MaxValues int
+	Policy    EvictionPolicy
+	Evicted   uint64
+	Rejected  uint64
+	// FilterBytes and FalsePositives are set for probabilistic routes. FalsePositives
+	// counts filter hits the exact set did not confirm.
+	FilterBytes    int
+	FalsePositives uint64
+}
+
+type usage struct {
+	lastUsed atomic.Int64
+	hits     atomic.Uint64
+}
+
+type routeLimit struct {
+	Limit
+	usage    map[string]*usage
+	evicted  uint64
+	rejected uint64
+}
+
+// SetLimit caps the number of fingerprints cached for route. Fingerprints beyond the cap
+// are evicted immediately (except under EvictRejectNew); a zero MaxValues removes the cap.
+func (s *MatchStore) SetLimit(route string, limit Limit) {
+	if limit.Policy == "" {
+		limit.Policy = EvictLRU
+	}
+	s.mu.Lock()
+	if limit.MaxValues <= 0 {
+		delete(s.limits, route)
+		s.mu.Unlock()
+		return
+	}
+	rl := &routeLimit{Limit: limit}
+	s.limits[route] = rl
+	rl.reset(s.values[route], &s.clock)
+	evicted := s.trimLocked(route, 0)
+	s.queueLocked(evicted...)
+	s.mu.Unlock()
+
+	s.flush()
+}
+
+// Stats returns the cache size and eviction counters for route.
+func (s *MatchStore) Stats(route string) RouteStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := RouteStats{Values: len(s.values[route])}
+	if rl, ok := s.limits[route]; ok {
+		stats.MaxValues = rl.MaxValues
+		stats.Policy = rl.Policy
+		stats.Evicted = rl.evicted
+		stats.Rejected = rl.rejected
+	}
+	if pr, ok := s.filters[route]; ok {
+		stats.Values = pr.count
+		stats.FilterBytes = pr.filter.bytes()
+		stats.FalsePositives = pr.falsePositives.Load()
+	}
+	return stats
+}
+
+// admitLocked makes room for one new fingerprint on route. It reports false when the
+// route is full and rejects new values, otherwise it returns the evictions performed.
+func (s *MatchStore) admitLocked(route string) ([]Mutation, bool) {
+	rl, ok := s.limits[route]
+	if !ok || len(s.values[route]) < rl.MaxValues {
+		return nil, true
+	}
+	if rl.Policy == EvictRejectNew {
+		rl.rejected++
+		if rl.rejected == 1 || rl.rejected%evictionLogEvery == 0 {
+			s.logf("warn: cache for route %s is full (maxValues=%d), rejected %d new value(s)", route, rl.MaxValues, rl.rejected)
+		}
+		return nil, false
+	}
+	return s.trimLocked(route, 1), true
+}
+
+// trimLocked evicts fingerprints until route has room for headroom more values.
+func (s *MatchStore) trimLocked(route string, headroom int) []Mutation {
+	rl, ok := s.limits[route]
+	if !ok || rl.Policy == EvictRejectNew {
+		return nil
+	}
+	routeMap := s.values[route]
+	var evicted []Mutation
+	for len(routeMap) > 0 && len(routeMap)+headroom > rl.MaxValues {
+		victim := rl.victim(routeMap)
+		delete(routeMap, victim)
+		delete(rl.usage, victim)
+		s.indexRemoveLocked(route, victim)
+		rl.evicted++
+		if rl.evicted == 1 || rl.evicted%evictionLogEvery == 0 {
+			s.logf("warn: cache for route %s reached maxValues=%d, evicting by %s (%d evicted so far)", route, rl.MaxValues, rl.Policy, rl.evicted)
+		}
+		evicted = append(evicted, Mutation{Op: OpRemove, Route: route, Fingerprint: victim})
+	}
+	return evicted
+}
+
+// victim samples the route and returns the fingerprint the policy ranks lowest.
+func (rl *routeLimit) victim(routeMap map[string]entry) string {
+	var (
+		best      string
+		bestUsage *usage
+		sampled   int
+	)
+	for fp := range routeMap {
+		u := rl.usage[fp]
+		if u == nil {
+			// never tracked: evict before anything that has been seen
+			return fp
+		}
+		if bestUsage == nil || rl.less(u, bestUsage) {
+			best, bestUsage = fp, u
+		}
+		sampled++
+		if sampled >= evictionSamples {
+			break
+		}
+	}
+	return best
+}
+
+func (rl *routeLimit) less(a, b *usage) bool {
+	if rl.Policy == EvictLFU {
+		ah, bh := a.hits.Load(), b.hits.Load()
+		if ah != bh {
+			return ah < bh
+		}
+	}
+	return a.lastUsed.Load() < b.lastUsed.Load()
+}
+
+// track starts usage accounting for a newly stored fingerprint.
+func (rl *routeLimit) track(fingerprint string, clock *atomic.Int64) {
+	u := &usage{}
+	u.lastUsed.Store(clock.Add(1))
+	rl.usage[fingerprint] = u
+}
+
+// reset rebuilds usage for routeMap, keeping the counters of fingerprints still present.
+func (rl *routeLimit) reset(routeMap map[string]entry, clock *atomic.Int64) {
+	next := make(map[string]*usage, len(routeMap))
+	for fp := range routeMap {
+		if u, ok := rl.usage[fp]; ok {
+			next[fp] = u
+			continue
+		}
+		u := &usage{}
+		u.lastUsed.Store(clock.Add(1))
+		next[fp] = u
+	}
+	rl.usage = next
+}
+
+// touchLocked records a match against fingerprint. It only needs the read lock.
+func (s *MatchStore) touchLocked(route, fingerprint string) {
+	rl, ok := s.limits[route]
+	if !ok {
+		return
+	}
+	if u := rl.usage[fingerprint]; u != nil {
+		u.lastUsed.Store(s.clock.Add(1))
+		u.hits.Add(1)
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import "testing"
+
+func TestMatchStoreLimitEviction(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  EvictionPolicy
+		lookups []string
+		want    []string
+		gone    string
+	}{
+		{name: "lru", policy: EvictLRU, lookups: []string{"a"}, want: []string{"a", "c"}, gone: "b"},
+		{name: "lfu", policy: EvictLFU, lookups: []string{"b", "b", "a"}, want: []string{"b", "c"}, gone: "a"},
+		{name: "reject-new", policy: EvictRejectNew, lookups: []string{"a"}, want: []string{"a", "b"}, gone: "c"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewMatchStore()
+			s.logf = func(string, ...any) {}
+			s.SetLimit("route", Limit{MaxValues: 2, Policy: tc.policy})
+			var removed []string
+			s.SetObserver(func(m Mutation) {
+				if m.Op == OpRemove {
+					removed = append(removed, m.Fingerprint)
+				}
+			})
+
+			s.Add("route", "a")
+			s.Add("route", "b")
+			for _, fp := range tc.lookups {
+				s.Lookup("route", fp)
+			}
+			added := s.Add("route", "c")
+
+			if added != (tc.policy != EvictRejectNew) {
+				t.Fatalf("Add(c) = %v", added)
+			}
+			for _, fp := range tc.want {
+				if !s.Contains("route", fp) {
+					t.Fatalf("expected %s to be cached", fp)
+				}
+			}
+			if s.Contains("route", tc.gone) {
+				t.Fatalf("expected %s to be absent", tc.gone)
+			}
+			stats := s.Stats("route")
+			if stats.Values != 2 || stats.MaxValues != 2 || stats.Policy != tc.policy {
+				t.Fatalf("unexpected stats %+v", stats)
+			}
+			if tc.policy == EvictRejectNew {
+				if stats.Rejected != 1 || stats.Evicted != 0 || len(removed) != 0 {
+					t.Fatalf("expected one rejection, got %+v removed=%v", stats, removed)
+				}
+				return
+			}
+			if stats.Evicted != 1 || len(removed) != 1 || removed[0] != tc.gone {
+				t.Fatalf("expected %s evicted, got %+v removed=%v", tc.gone, stats, removed)
+			}
+		})
+	}
+}
+
+func TestMatchStoreLimitTrimsExisting(t *testing.T) {
+	s := NewMatchStore()
+	s.logf = func(string, ...any) {}
+	s.Load(map[string][]string{"route": {"a", "b", "c"}, "other": {"x", "y"}})
+
+	s.SetLimit("route", Limit{MaxValues: 1})
+	if got := s.Size("route"); got != 1 {
+		t.Fatalf("expected route trimmed to 1, got %d", got)
+	}
+	if got := s.Stats("route"); got.Evicted != 2 || got.Policy != EvictLRU {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	s.Load(map[string][]string{"route": {"a", "b"}, "other": {"x", "y"}})
+	if got := s.Size("route"); got != 1 {
+		t.Fatalf("expected load trimmed to 1, got %d", got)
+	}
+	if got := s.Size("other"); got != 2 {
+		t.Fatalf("expected unlimited route untouched, got %d", got)
+	}
+
+	s.SetLimit("route", Limit{})
+	s.Add("route", "z")
+	if got := s.Size("route"); got != 2 {
+		t.Fatalf("expected limit removed, got %d", got)
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"sort"
+)
+
+// entryOverhead approximates what a cached fingerprint costs beyond its strings: the map
+// slot, the entry and its provenance, and the usage counters of a bounded route.
+const entryOverhead = 160
+
+// MemoryUsage returns an approximation of the bytes the cache of route takes: its
+// fingerprints, canonical values, and provenance, or the filter of a probabilistic route.
+// It walks the route, so callers sample it rather than call it per message.
+func (s *MatchStore) MemoryUsage(route string) int64 {
+	if pr := s.probabilistic(route); pr != nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return int64(pr.filter.bytes())
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int64
+	for fp, e := range s.values[route] {
+		n += entryBytes(fp, e)
+	}
+	return n
+}
+
+func entryBytes(fingerprint string, e entry) int64 {
+	n := entryOverhead + len(fingerprint) + len(e.meta.Source) + len(e.meta.Feed) + len(e.meta.Topic)
+	if e.canonical != fingerprint {
+		n += len(e.canonical)
+	}
+	for k, v := range e.meta.Annotations {
+		n += len(k) + len(v)
+	}
+	return int64(n)
+}
+
+// EvictOldest removes the fingerprints of route cached longest ago, by their AddedAt,
+// until the MemoryUsage they account for reaches bytes, and returns how many it removed.
+// Observers see the removals. The values of a probabilistic route have no age, so none
+// are removed.
+func (s *MatchStore) EvictOldest(route string, bytes int64) int {
+	if bytes <= 0 || s.probabilistic(route) != nil {
+		return 0
+	}
+	s.mu.Lock()
+	routeMap := s.values[route]
+	fingerprints := make([]string, 0, len(routeMap))
+	for fp := range routeMap {
+		fingerprints = append(fingerprints, fp)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		a, b := routeMap[fingerprints[i]].meta.AddedAt, routeMap[fingerprints[j]].meta.AddedAt
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return fingerprints[i] < fingerprints[j]
+	})
+	var removed []Mutation
+	for _, fp := range fingerprints {
+		if bytes <= 0 {
+			break
+		}
+		bytes -= entryBytes(fp, routeMap[fp])
+		delete(routeMap, fp)
+		if rl, ok := s.limits[route]; ok {
+			delete(rl.usage, fp)
+		}
+		s.indexRemoveLocked(route, fp)
+		removed = append(removed, Mutation{Op: OpRemove, Route: route, Fingerprint: fp})
+	}
+	s.queueLocked(removed...)
+	s.mu.Unlock()
+
+	s.flush()
+	return len(removed)
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchStoreMemoryUsageAndEvictOldest(t *testing.T) {
+	s := NewMatchStore()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s.AddWithMeta("route", "newest", Metadata{Source: SourceHTTP, AddedAt: start.Add(2 * time.Hour)})
+	s.AddWithMeta("route", "oldest", Metadata{Source: SourceHTTP, AddedAt: start})
+	s.AddWithMeta("route", "middle", Metadata{Source: SourceHTTP, AddedAt: start.Add(time.Hour)})
+	if got, want := s.MemoryUsage("route"), int64(3*entryOverhead+len("newestoldestmiddle")+3*len(SourceHTTP)); got != want {
+		t.Fatalf("MemoryUsage = %d, want %d", got, want)
+	}
+	if s.MemoryUsage("other") != 0 {
+		t.Fatal("expected an unknown route to take no memory")
+	}
+
+	var removed []string
+	s.SetObserver(func(m Mutation) {
+		if m.Op == OpRemove {
+			removed = append(removed, m.Fingerprint)
+		}
+	})
+	// one byte more than the oldest entry takes, so the next oldest goes too
+	if n := s.EvictOldest("route", entryOverhead+int64(len("oldest")+len(SourceHTTP))+1); n != 2 {
+		t.Fatalf("EvictOldest removed %d value(s), want 2", n)
+	}
+	if len(removed) != 2 || removed[0] != "oldest" || removed[1] != "middle" || !s.Contains("route", "newest") {
+		t.Fatalf("removed %v, want the two oldest", removed)
+	}
+	if n := s.EvictOldest("route", 0); n != 0 {
+		t.Fatalf("EvictOldest(0) removed %d value(s)", n)
+	}
+}
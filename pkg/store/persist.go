@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	snapshotFormat = "kafka-bridge-snapshot"
+	// snapshotVersion 2 adds the provenance of the values; version 1 files still load.
+	snapshotVersion = 2
+)
+
+// SaveOptions controls how a snapshot is written.
+type SaveOptions struct {
+	// Gzip compresses the snapshot file. Loading detects compression automatically.
+	Gzip bool
+	// Legacy writes the bare JSON object of route values that bridges predating the
+	// versioned format read, without a checksum or provenance.
+	Legacy bool
+}
+
+// SnapshotFile is a snapshot as read from disk, with how it was stored.
+type SnapshotFile struct {
+	// Version is the format version, or 0 for the legacy format.
+	Version int
+	Gzip    bool
+	Routes  map[string][]string
+	// Meta is the provenance of the values by route and value. Files before version 2
+	// hold none.
+	Meta map[string]map[string]Metadata
+}
+
+// Entries returns the values of the file with their provenance, as MatchStore.LoadEntries
+// takes them.
+func (f SnapshotFile) Entries() map[string]map[string]Entry {
+	entries := make(map[string]map[string]Entry, len(f.Routes))
+	for route, values := range f.Routes {
+		routeEntries := make(map[string]Entry, len(values))
+		for _, v := range values {
+			routeEntries[v] = Entry{Meta: f.Meta[route][v]}
+		}
+		entries[route] = routeEntries
+	}
+	return entries
+}
+
+// snapshotEnvelope wraps the route values with a format version and a checksum of the
+// compacted routes and meta JSON, so truncated or edited files are rejected on load.
+type snapshotEnvelope struct {
+	Format   string          `json:"format"`
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	Routes   json.RawMessage `json:"routes"`
+	// Meta holds the provenance of the values by route and value, from version 2.
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// Save atomically writes the snapshot of route values to the provided path with
+// WriteFileAtomic.
+func Save(path string, snapshot map[string][]string, opts SaveOptions) error {
+	return SaveWithMeta(path, snapshot, nil, opts)
+}
+
+// SaveWithMeta is Save, also recording the provenance of the values in meta, by route and
+// value, so values loaded from the file keep their source, event time, and annotations.
+// Legacy snapshots drop it.
+func SaveWithMeta(path string, snapshot map[string][]string, meta map[string]map[string]Metadata, opts SaveOptions) error {
+	if path == "" {
+		return errors.New("path is empty")
+	}
+	data, err := encodeSnapshot(snapshot, meta, opts)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0o644)
+}
+
+// WriteFileAtomic writes data to path like os.WriteFile, but never leaves a partial file:
+// the data is written to a temporary file in the same directory, synced, and renamed over
+// path. Missing parent directories are created.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	// persist the rename itself; not every platform supports syncing a directory
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Load reads the snapshot from disk. It accepts gzip-compressed files and the legacy
+// format, a bare JSON object of route values.
+func Load(path string) (map[string][]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(raw)
+}
+
+// ReadSnapshotFile reads the snapshot at path like Load, also reporting its format.
+func ReadSnapshotFile(path string) (SnapshotFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	return decodeSnapshotFile(raw)
+}
+
+// WriteSnapshot writes snapshot to w in the format Save uses, so it can be stored as a
+// snapshot file or read back with ReadSnapshot.
+func WriteSnapshot(w io.Writer, snapshot map[string][]string, opts SaveOptions) error {
+	return WriteSnapshotWithMeta(w, snapshot, nil, opts)
+}
+
+// WriteSnapshotWithMeta writes snapshot to w in the format SaveWithMeta uses.
+func WriteSnapshotWithMeta(w io.Writer, snapshot map[string][]string, meta map[string]map[string]Metadata, opts SaveOptions) error {
+	data, err := encodeSnapshot(snapshot, meta, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadSnapshot reads a snapshot in any format Load accepts from r.
+func ReadSnapshot(r io.Reader) (map[string][]string, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(raw)
+}
+
+func encodeSnapshot(snapshot map[string][]string, meta map[string]map[string]Metadata, opts SaveOptions) ([]byte, error) {
+	routes, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	data := routes
+	if !opts.Legacy {
+		var metaJSON []byte
+		if len(meta) > 0 {
+			if metaJSON, err = json.Marshal(meta); err != nil {
+				return nil, fmt.Errorf("marshal: %w", err)
+			}
+		}
+		data, err = json.MarshalIndent(snapshotEnvelope{
+			Format:   snapshotFormat,
+			Version:  snapshotVersion,
+			Checksum: checksum(routes, metaJSON),
+			Routes:   routes,
+			Meta:     metaJSON,
+		}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshal: %w", err)
+		}
+	}
+	if !opts.Gzip {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSnapshot(raw []byte) (map[string][]string, error) {
+	file, err := decodeSnapshotFile(raw)
+	return file.Routes, err
+}
+
+func decodeSnapshotFile(raw []byte) (SnapshotFile, error) {
+	var file SnapshotFile
+	if len(raw) >= 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return file, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		if raw, err = io.ReadAll(zr); err != nil {
+			return file, fmt.Errorf("gzip: %w", err)
+		}
+		file.Gzip = true
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return file, fmt.Errorf("unmarshal: %w", err)
+	}
+	var format string
+	if f, ok := fields["format"]; !ok || json.Unmarshal(f, &format) != nil || format != snapshotFormat {
+		// legacy snapshot: the file is the route map itself
+		if err := json.Unmarshal(raw, &file.Routes); err != nil {
+			return file, fmt.Errorf("unmarshal: %w", err)
+		}
+		return file, nil
+	}
+
+	var env snapshotEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return file, fmt.Errorf("unmarshal: %w", err)
+	}
+	if env.Version < 1 || env.Version > snapshotVersion {
+		return file, fmt.Errorf("unsupported snapshot version %d", env.Version)
+	}
+	file.Version = env.Version
+	var routes, meta bytes.Buffer
+	if err := json.Compact(&routes, env.Routes); err != nil {
+		return file, fmt.Errorf("unmarshal routes: %w", err)
+	}
+	if len(env.Meta) > 0 {
+		if err := json.Compact(&meta, env.Meta); err != nil {
+			return file, fmt.Errorf("unmarshal meta: %w", err)
+		}
+	}
+	if got := checksum(routes.Bytes(), meta.Bytes()); got != env.Checksum {
+		return file, fmt.Errorf("snapshot checksum mismatch: got %s, want %s", got, env.Checksum)
+	}
+	if err := json.Unmarshal(env.Routes, &file.Routes); err != nil {
+		return file, fmt.Errorf("unmarshal routes: %w", err)
+	}
+	if len(env.Meta) > 0 {
+		if err := json.Unmarshal(env.Meta, &file.Meta); err != nil {
+			return file, fmt.Errorf("unmarshal meta: %w", err)
+		}
+	}
+	return file, nil
+}
+
+// checksum covers the compacted routes JSON followed by the compacted meta JSON, which
+// version 1 files do not have.
+func checksum(routes, meta []byte) string {
+	h := sha256.New()
+	h.Write(routes)
+	h.Write(meta)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
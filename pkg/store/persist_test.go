@@ -0,0 +1,161 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	snapshot := map[string][]string{"route-a": {"one", "two"}, "route-b": {"<tag>&"}}
+	for _, opts := range []SaveOptions{{}, {Gzip: true}, {Legacy: true}, {Legacy: true, Gzip: true}} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "cache.json")
+		if err := Save(path, snapshot, opts); err != nil {
+			t.Fatalf("Save(%+v): %v", opts, err)
+		}
+		got, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%+v): %v", opts, err)
+		}
+		if !reflect.DeepEqual(got, snapshot) {
+			t.Fatalf("round trip (%+v) = %v, want %v", opts, got, snapshot)
+		}
+		file, err := ReadSnapshotFile(path)
+		if err != nil {
+			t.Fatalf("ReadSnapshotFile(%+v): %v", opts, err)
+		}
+		if wantVersion := map[bool]int{false: 2, true: 0}[opts.Legacy]; file.Version != wantVersion || file.Gzip != opts.Gzip {
+			t.Fatalf("ReadSnapshotFile(%+v) reports version %d, gzip %v", opts, file.Version, file.Gzip)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("expected only the snapshot file, got %v", entries)
+		}
+	}
+}
+
+func TestSnapshotMeta(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMatchStore()
+	s.AddWithMeta("route-a", "one", Metadata{Source: SourceKafka, Feed: "feed-a", Topic: "ref", Offset: 7, AddedAt: at, EventTime: at.Add(-time.Hour)})
+	s.AddWithMeta("route-a", "two", Metadata{Source: SourceHTTP, AddedAt: at, Annotations: map[string]string{"owner": "ops"}})
+	s.Add("route-b", "three")
+	dir := t.TempDir()
+	for _, opts := range []SaveOptions{{Gzip: true}, {}} {
+		path := filepath.Join(dir, "cache.json")
+		if err := s.SaveSnapshot(path, opts); err != nil {
+			t.Fatalf("SaveSnapshot(%+v): %v", opts, err)
+		}
+		file, err := ReadSnapshotFile(path)
+		if err != nil {
+			t.Fatalf("ReadSnapshotFile(%+v): %v", opts, err)
+		}
+		restored := NewMatchStore()
+		restored.LoadEntries(file.Entries())
+		for _, tc := range []struct{ route, value string }{{"route-a", "one"}, {"route-a", "two"}, {"route-b", "three"}} {
+			want, _ := s.Lookup(tc.route, tc.value)
+			got, ok := restored.Lookup(tc.route, tc.value)
+			if !ok || !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s/%s after reload (%+v) = %+v, %v; want %+v", tc.route, tc.value, opts, got, ok, want)
+			}
+		}
+	}
+
+	// Editing the provenance breaks the checksum.
+	path := filepath.Join(dir, "cache.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), "feed-a", "feed-b", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Load of edited provenance = %v, want a checksum mismatch", err)
+	}
+}
+
+func TestLoadSnapshotFormats(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name:    "legacy",
+			content: `{"route-a":["one"],"format":["two"]}`,
+			want:    map[string][]string{"route-a": {"one"}, "format": {"two"}},
+		},
+		{
+			name:    "version 1",
+			content: `{"format":"kafka-bridge-snapshot","version":1,"checksum":"sha256:735e26340033c97d430f1731c7a133e55b1a697f8dcdbd44f1fafc050774dfc8","routes":{"route-a":["one"]}}`,
+			want:    map[string][]string{"route-a": {"one"}},
+		},
+		{
+			name:    "checksum mismatch",
+			content: `{"format":"kafka-bridge-snapshot","version":1,"checksum":"sha256:00","routes":{"route-a":["one"]}}`,
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "future version",
+			content: `{"format":"kafka-bridge-snapshot","version":3,"checksum":"","routes":{}}`,
+			wantErr: "unsupported snapshot version 3",
+		},
+		{
+			name:    "truncated",
+			content: `{"format":"kafka-bridge-snapshot","version":1,"rou`,
+			wantErr: "unmarshal",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.json")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			got, err := Load(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Load = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "state.json")
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFileAtomic: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != data {
+			t.Fatalf("read %q, %v; want %q", got, err, data)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("stat = %v, %v; want mode 0600", info, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the written file, got %v, %v", entries, err)
+	}
+}
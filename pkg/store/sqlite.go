@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how many have run.
+var sqliteMigrations = []string{
+	`CREATE TABLE cache_values (
+		route       TEXT    NOT NULL,
+		fingerprint TEXT    NOT NULL,
+		canonical   TEXT    NOT NULL,
+		added_at    TEXT    NOT NULL,
+		source      TEXT    NOT NULL DEFAULT '',
+		feed        TEXT    NOT NULL DEFAULT '',
+		topic       TEXT    NOT NULL DEFAULT '',
+		partition   INTEGER NOT NULL DEFAULT 0,
+		"offset"    INTEGER NOT NULL DEFAULT 0,
+		annotations TEXT,
+		PRIMARY KEY (route, fingerprint)
+	);
+	CREATE INDEX cache_values_canonical ON cache_values (route, canonical);
+	CREATE INDEX cache_values_added_at ON cache_values (added_at);`,
+	`ALTER TABLE cache_values ADD COLUMN event_time TEXT;`,
+	`ALTER TABLE cache_values ADD COLUMN record_key TEXT NOT NULL DEFAULT '';`,
+}
+
+// SQLite persists store mutations to a SQLite database so the cached reference set can be
+// queried with SQL. The in-memory store stays authoritative: mutations are queued by
+// Record and written in batches by Run. The database uses WAL mode, so readers such as
+// the sqlite3 shell do not block the bridge.
+type SQLite struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	pending []Mutation
+	// writing is the batch being written, which Lookup still has to see.
+	writing []Mutation
+	signal  chan struct{}
+}
+
+// OpenSQLite opens or creates the database at path and migrates it to the current schema.
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+	return &SQLite{db: db, signal: make(chan struct{}, 1)}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, len(sqliteMigrations))
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Restore loads every persisted fingerprint into s and returns how many were loaded.
+func (q *SQLite) Restore(ctx context.Context, s *MatchStore) (int, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT route, fingerprint, canonical, added_at, source, feed, topic, partition, "offset", annotations, event_time, record_key FROM cache_values`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	s.resetFiltersLocked()
+	s.mu.Unlock()
+	state := make(map[string]map[string]Entry)
+	total := 0
+	for rows.Next() {
+		var (
+			route, fingerprint, canonical, addedAt string
+			meta                                   Metadata
+			annotations, eventTime                 sql.NullString
+		)
+		if err := rows.Scan(&route, &fingerprint, &canonical, &addedAt, &meta.Source, &meta.Feed, &meta.Topic, &meta.Partition, &meta.Offset, &annotations, &eventTime, &meta.Key); err != nil {
+			return 0, err
+		}
+		if s.restoreProbabilistic(route, fingerprint) {
+			total++
+			continue
+		}
+		if meta.AddedAt, err = time.Parse(time.RFC3339Nano, addedAt); err != nil {
+			log.Printf("sqlite: %s|%s has invalid added_at %q: %v", route, fingerprint, addedAt, err)
+		}
+		if annotations.Valid {
+			if err := json.Unmarshal([]byte(annotations.String), &meta.Annotations); err != nil {
+				log.Printf("sqlite: %s|%s has invalid annotations: %v", route, fingerprint, err)
+			}
+		}
+		if eventTime.Valid {
+			if meta.EventTime, err = time.Parse(time.RFC3339Nano, eventTime.String); err != nil {
+				log.Printf("sqlite: %s|%s has invalid event_time %q: %v", route, fingerprint, eventTime.String, err)
+			}
+		}
+		fps, ok := state[route]
+		if !ok {
+			fps = make(map[string]Entry)
+			state[route] = fps
+		}
+		fps[fingerprint] = Entry{Canonical: canonical, Meta: meta}
+		total++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	s.loadEntries(state, false)
+	return total, nil
+}
+
+// Record queues a store mutation. It never blocks and is safe to use as a store observer.
+func (q *SQLite) Record(m Mutation) {
+	q.mu.Lock()
+	q.pending = append(q.pending, m)
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *SQLite) wake() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run writes queued mutations until the context is cancelled, then flushes what remains.
+func (q *SQLite) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := q.Flush(flushCtx); err != nil {
+				log.Printf("sqlite: final flush failed: %v", err)
+			}
+			return ctx.Err()
+		case <-q.signal:
+			if err := q.Flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("sqlite: write failed, retrying: %v", err)
+				time.AfterFunc(time.Second, q.wake)
+			}
+		}
+	}
+}
+
+// Flush writes every queued mutation in one transaction, for one-shot tools that do not
+// call Run.
+func (q *SQLite) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	batch := q.pending
+	q.pending, q.writing = nil, batch
+	q.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	err := q.write(ctx, batch)
+	q.mu.Lock()
+	if err != nil {
+		// keep ordering: failed mutations go back in front of anything queued since
+		q.pending = append(batch, q.pending...)
+	}
+	q.writing = nil
+	q.mu.Unlock()
+	return err
+}
+
+func (q *SQLite) write(ctx context.Context, batch []Mutation) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	upsert, err := tx.PrepareContext(ctx, `INSERT INTO cache_values (route, fingerprint, canonical, added_at, source, feed, topic, partition, "offset", annotations, event_time, record_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (route, fingerprint) DO UPDATE SET canonical = excluded.canonical, added_at = excluded.added_at,
+			source = excluded.source, feed = excluded.feed, topic = excluded.topic, partition = excluded.partition,
+			"offset" = excluded."offset", annotations = excluded.annotations, event_time = excluded.event_time,
+			record_key = excluded.record_key`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	remove, err := tx.PrepareContext(ctx, `DELETE FROM cache_values WHERE route = ? AND fingerprint = ?`)
+	if err != nil {
+		return err
+	}
+	defer remove.Close()
+
+	for _, m := range batch {
+		if m.Op == OpRemove {
+			if _, err := remove.ExecContext(ctx, m.Route, m.Fingerprint); err != nil {
+				return err
+			}
+			continue
+		}
+		canonical := m.Canonical
+		if canonical == "" {
+			canonical = m.Fingerprint
+		}
+		addedAt := m.Meta.AddedAt
+		if addedAt.IsZero() {
+			addedAt = time.Now()
+		}
+		var annotations any
+		if len(m.Meta.Annotations) > 0 {
+			raw, err := json.Marshal(m.Meta.Annotations)
+			if err != nil {
+				return err
+			}
+			annotations = string(raw)
+		}
+		var eventTime any
+		if !m.Meta.EventTime.IsZero() {
+			eventTime = m.Meta.EventTime.UTC().Format(time.RFC3339Nano)
+		}
+		if _, err := upsert.ExecContext(ctx, m.Route, m.Fingerprint, canonical, addedAt.UTC().Format(time.RFC3339Nano),
+			m.Meta.Source, m.Meta.Feed, m.Meta.Topic, m.Meta.Partition, m.Meta.Offset, annotations, eventTime, m.Meta.Key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Lookup reports whether fingerprint is persisted for route, counting mutations not yet
+// written, and returns its provenance. It makes the database the exact set of a
+// probabilistic route.
+func (q *SQLite) Lookup(route, fingerprint string) (Metadata, bool, error) {
+	q.mu.Lock()
+	for _, queue := range [][]Mutation{q.pending, q.writing} {
+		for i := len(queue) - 1; i >= 0; i-- {
+			if m := queue[i]; m.Route == route && m.Fingerprint == fingerprint {
+				q.mu.Unlock()
+				return m.Meta, m.Op != OpRemove, nil
+			}
+		}
+	}
+	q.mu.Unlock()
+
+	var (
+		meta                   Metadata
+		addedAt                string
+		annotations, eventTime sql.NullString
+	)
+	err := q.db.QueryRow(`SELECT added_at, source, feed, topic, partition, "offset", annotations, event_time, record_key FROM cache_values WHERE route = ? AND fingerprint = ?`, route, fingerprint).
+		Scan(&addedAt, &meta.Source, &meta.Feed, &meta.Topic, &meta.Partition, &meta.Offset, &annotations, &eventTime, &meta.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Metadata{}, false, nil
+	}
+	if err != nil {
+		return Metadata{}, false, err
+	}
+	meta.AddedAt, _ = time.Parse(time.RFC3339Nano, addedAt)
+	if annotations.Valid {
+		_ = json.Unmarshal([]byte(annotations.String), &meta.Annotations)
+	}
+	if eventTime.Valid {
+		meta.EventTime, _ = time.Parse(time.RFC3339Nano, eventTime.String)
+	}
+	return meta, true, nil
+}
+
+// Close closes the database.
+func (q *SQLite) Close() error {
+	return q.db.Close()
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	src := NewMatchStore()
+	src.SetObserver(db.Record)
+	addedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	src.AddWithMeta("route-a", "abc", Metadata{Source: SourceKafka, Feed: "feed-a", Topic: "ref", Partition: 2, Offset: 7, AddedAt: addedAt, EventTime: addedAt.Add(-time.Hour), Key: "k1"})
+	src.AddWithMeta("route-a", "manual", Metadata{Source: SourceHTTP, Annotations: map[string]string{"owner": "ops"}})
+	src.Add("route-b", "gone")
+	src.Remove("route-b", "gone")
+	if err := db.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var mode string
+	if err := db.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q, %v; want wal", mode, err)
+	}
+	var source string
+	if err := db.db.QueryRow(`SELECT source FROM cache_values WHERE route = 'route-a' AND fingerprint = 'abc'`).Scan(&source); err != nil || source != SourceKafka {
+		t.Fatalf("query source = %q, %v", source, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// reopening runs no migration twice
+	db, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	dst := NewMatchStore()
+	n, err := db.Restore(context.Background(), dst)
+	if err != nil || n != 2 {
+		t.Fatalf("Restore = %d, %v; want 2", n, err)
+	}
+	meta, ok := dst.Lookup("route-a", "abc")
+	if !ok || meta.Feed != "feed-a" || meta.Offset != 7 || meta.Partition != 2 || !meta.AddedAt.Equal(addedAt) || !meta.EventTime.Equal(addedAt.Add(-time.Hour)) || meta.Key != "k1" {
+		t.Fatalf("unexpected restored metadata: %+v (found %v)", meta, ok)
+	}
+	if meta, _ := dst.Lookup("route-a", "manual"); meta.Annotations["owner"] != "ops" {
+		t.Fatalf("annotations not restored: %+v", meta)
+	}
+	if dst.Contains("route-b", "gone") {
+		t.Fatal("removed fingerprint was restored")
+	}
+}
@@ -0,0 +1,836 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Op identifies the kind of change applied to the store.
+type Op int
+
+const (
+	// OpAdd records a newly cached fingerprint.
+	OpAdd Op = iota + 1
+	// OpRemove records a fingerprint dropped from the cache.
+	OpRemove
+)
+
+// Sources recorded in Metadata.
+const (
+	SourceKafka = "kafka"
+	SourceHTTP  = "http"
+	// SourceReferenceHTTP marks values loaded by a route's referenceHTTP poller.
+	SourceReferenceHTTP = "referenceHTTP"
+	// SourceReferenceSQL marks values loaded by a route's referenceSQL query.
+	SourceReferenceSQL = "referenceSQL"
+)
+
+// Metadata describes where a cached fingerprint came from.
+type Metadata struct {
+	Source    string    `json:"source,omitempty"`
+	Feed      string    `json:"feed,omitempty"`
+	Topic     string    `json:"topic,omitempty"`
+	Partition int       `json:"partition,omitempty"`
+	Offset    int64     `json:"offset,omitempty"`
+	AddedAt   time.Time `json:"addedAt,omitempty"`
+	// EventTime is the reference record's own timestamp, when its feed extracts one.
+	EventTime time.Time `json:"eventTime,omitempty"`
+	// Annotations are free-form operator notes (owner, ticket, reason) on injected values.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Key is the key of the reference record that carried the value, so a tombstone for
+	// it still finds the value after a restart.
+	Key string `json:"key,omitempty"`
+}
+
+// IsZero reports whether m records no provenance at all.
+func (m Metadata) IsZero() bool {
+	return m.Source == "" && m.Feed == "" && m.Topic == "" && m.Partition == 0 && m.Offset == 0 &&
+		m.AddedAt.IsZero() && m.EventTime.IsZero() && len(m.Annotations) == 0 && m.Key == ""
+}
+
+// String renders the origin compactly, e.g. "kafka:feed-a@topic-a/0:42" or "http".
+func (m Metadata) String() string {
+	if m.Source != SourceKafka {
+		if m.Source == "" {
+			return "unknown"
+		}
+		return m.Source
+	}
+	return fmt.Sprintf("%s:%s@%s/%d:%d", m.Source, m.Feed, m.Topic, m.Partition, m.Offset)
+}
+
+// Entry is a cached fingerprint's canonical value and provenance.
+type Entry struct {
+	// Canonical is the reference value the fingerprint was derived from; empty means itself.
+	Canonical string
+	Meta      Metadata
+}
+
+// Mutation describes a single fingerprint change applied to the store.
+type Mutation struct {
+	Op          Op
+	Route       string
+	Fingerprint string
+	// Canonical is the reference value the fingerprint was derived from (adds only).
+	Canonical string
+	// Meta is the provenance recorded with the fingerprint (adds only).
+	Meta Metadata
+	// Replicated marks changes applied by ApplyReplicated, which replication backends
+	// must not publish again.
+	Replicated bool
+}
+
+// Observer receives mutations after they have been applied, one at a time and in the
+// order they were applied.
+type Observer func(Mutation)
+
+// VariantFunc expands a canonical value into every fingerprint that should match it.
+type VariantFunc func(string) []string
+
+type entry struct {
+	canonical string
+	meta      Metadata
+}
+
+// MatchStore keeps allowed payload fingerprints per route.
+type MatchStore struct {
+	mu       sync.RWMutex
+	values   map[string]map[string]entry
+	limits   map[string]*routeLimit
+	filters  map[string]*probRoute
+	indexes  map[string]*substringIndex
+	clock    atomic.Int64
+	observer Observer
+	logf     func(format string, args ...any)
+
+	// observers are the observers registered, in registration order; observer calls each.
+	observers []*Observer
+
+	// noticeMu guards notices, the mutations queued under mu in the order they were
+	// applied, and notifying, set while a goroutine delivers them.
+	noticeMu  sync.Mutex
+	notices   []notice
+	notifying bool
+}
+
+// notice is a batch of mutations for the observer registered when they were applied.
+type notice struct {
+	observer  Observer
+	mutations []Mutation
+}
+
+// Option configures a MatchStore built by NewMatchStore.
+type Option func(*MatchStore)
+
+// WithObserver registers fn as the store's observer, as SetObserver does.
+func WithObserver(fn Observer) Option {
+	return func(s *MatchStore) { s.setObserverLocked(fn) }
+}
+
+// WithLimit bounds route as SetLimit does.
+func WithLimit(route string, limit Limit) Option {
+	return func(s *MatchStore) { s.SetLimit(route, limit) }
+}
+
+// WithLogger sends the store's log lines, such as eviction notices, to logf instead of
+// log.Printf.
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(s *MatchStore) { s.logf = logf }
+}
+
+// NewMatchStore creates an empty store.
+func NewMatchStore(opts ...Option) *MatchStore {
+	s := &MatchStore{
+		values:  make(map[string]map[string]entry),
+		limits:  make(map[string]*routeLimit),
+		filters: make(map[string]*probRoute),
+		logf:    log.Printf,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetObserver registers a callback invoked for every mutation, replacing any observer
+// already registered. Restores via Load are not reported.
+func (s *MatchStore) SetObserver(fn Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setObserverLocked(fn)
+}
+
+// AddObserver registers fn in addition to any observer already set; observers run in
+// registration order. The returned func unregisters fn; mutations applied before it is
+// called may still be delivered to fn.
+func (s *MatchStore) AddObserver(fn Observer) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registered := &fn
+	s.observers = append(s.observers, registered)
+	s.combineObserversLocked()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.observers = slices.DeleteFunc(s.observers, func(o *Observer) bool { return o == registered })
+		s.combineObserversLocked()
+	}
+}
+
+// Observers returns the number of observers registered.
+func (s *MatchStore) Observers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.observers)
+}
+
+func (s *MatchStore) setObserverLocked(fn Observer) {
+	s.observers = nil
+	if fn != nil {
+		s.observers = append(s.observers, &fn)
+	}
+	s.combineObserversLocked()
+}
+
+// combineObserversLocked sets observer to call every one of observers. Queued notices keep
+// the observer they were queued for.
+func (s *MatchStore) combineObserversLocked() {
+	switch len(s.observers) {
+	case 0:
+		s.observer = nil
+	case 1:
+		s.observer = *s.observers[0]
+	default:
+		fns := make([]Observer, len(s.observers))
+		for i, o := range s.observers {
+			fns[i] = *o
+		}
+		s.observer = func(m Mutation) {
+			for _, fn := range fns {
+				fn(m)
+			}
+		}
+	}
+}
+
+// Add inserts the canonical fingerprint for the given route. Variants are not stored;
+// callers probe with every variant of the candidate value instead.
+func (s *MatchStore) Add(route string, fingerprint string) bool {
+	return s.AddWithMeta(route, fingerprint, Metadata{})
+}
+
+// AddWithMeta inserts the canonical fingerprint and records where it came from. The first
+// recorded provenance wins; re-adding an existing fingerprint leaves it untouched. When the
+// route is at its Limit, older values are evicted first or, under EvictRejectNew, the new
+// fingerprint is refused and false is returned.
+func (s *MatchStore) AddWithMeta(route string, fingerprint string, meta Metadata) bool {
+	return s.add(route, fingerprint, fingerprint, meta, false)
+}
+
+func (s *MatchStore) add(route, fingerprint, canonical string, meta Metadata, replicated bool) bool {
+	if meta.AddedAt.IsZero() {
+		meta.AddedAt = time.Now().UTC()
+	}
+	if pr := s.probabilistic(route); pr != nil {
+		return s.probAdd(pr, route, fingerprint, canonical, meta, replicated)
+	}
+	s.mu.Lock()
+	changes, added := s.addLocked(route, fingerprint, canonical, meta)
+	for i := range changes {
+		changes[i].Replicated = replicated
+	}
+	s.queueLocked(changes...)
+	s.mu.Unlock()
+
+	s.flush()
+	return added
+}
+
+// addLocked stores fingerprint and returns the resulting mutations, evictions included,
+// and whether it was new.
+func (s *MatchStore) addLocked(route, fingerprint, canonical string, meta Metadata) ([]Mutation, bool) {
+	routeMap := s.routeLocked(route)
+	var changes []Mutation
+	if _, exists := routeMap[fingerprint]; !exists {
+		evicted, ok := s.admitLocked(route)
+		if !ok {
+			return nil, false
+		}
+		changes = evicted
+	}
+	if !s.putLocked(routeMap, fingerprint, entry{canonical: canonical, meta: meta}) {
+		return changes, false
+	}
+	if rl, ok := s.limits[route]; ok {
+		rl.track(fingerprint, &s.clock)
+	}
+	s.indexAddLocked(route, fingerprint)
+	return append(changes, Mutation{Op: OpAdd, Route: route, Fingerprint: fingerprint, Canonical: canonical, Meta: meta}), true
+}
+
+// AddAll inserts canonical fingerprints for route under one lock acquisition and returns
+// how many were new.
+func (s *MatchStore) AddAll(route string, fingerprints []string) int {
+	return len(s.AddAllWithMeta(route, fingerprints, Metadata{}))
+}
+
+// AddAllWithMeta is AddWithMeta for several fingerprints sharing one provenance, taking
+// the lock once. It returns the fingerprints that were new, in order.
+func (s *MatchStore) AddAllWithMeta(route string, fingerprints []string, meta Metadata) []string {
+	if meta.AddedAt.IsZero() {
+		meta.AddedAt = time.Now().UTC()
+	}
+	var added []string
+	if pr := s.probabilistic(route); pr != nil {
+		for _, fp := range fingerprints {
+			if s.probAdd(pr, route, fp, fp, meta, false) {
+				added = append(added, fp)
+			}
+		}
+		return added
+	}
+	s.mu.Lock()
+	var changes []Mutation
+	for _, fp := range fingerprints {
+		mutations, ok := s.addLocked(route, fp, fp, meta)
+		changes = append(changes, mutations...)
+		if ok {
+			added = append(added, fp)
+		}
+	}
+	s.queueLocked(changes...)
+	s.mu.Unlock()
+
+	s.flush()
+	return added
+}
+
+func (s *MatchStore) routeLocked(route string) map[string]entry {
+	routeMap, ok := s.values[route]
+	if !ok {
+		routeMap = make(map[string]entry)
+		s.values[route] = routeMap
+	}
+	return routeMap
+}
+
+// putLocked stores fingerprint and reports whether it is new. A fingerprint that is
+// itself canonical is never demoted to a variant of another value.
+func (s *MatchStore) putLocked(routeMap map[string]entry, fingerprint string, e entry) bool {
+	existing, exists := routeMap[fingerprint]
+	if exists {
+		if fingerprint == e.canonical && existing.canonical != fingerprint {
+			existing.canonical = fingerprint
+			routeMap[fingerprint] = existing
+		}
+		return false
+	}
+	routeMap[fingerprint] = e
+	return true
+}
+
+// Remove drops the fingerprint for the given route and reports whether it was present.
+func (s *MatchStore) Remove(route string, fingerprint string) bool {
+	return s.remove(route, fingerprint, false)
+}
+
+func (s *MatchStore) remove(route, fingerprint string, replicated bool) bool {
+	if pr := s.probabilistic(route); pr != nil {
+		return s.probRemove(pr, route, fingerprint, replicated)
+	}
+	s.mu.Lock()
+	routeMap, ok := s.values[route]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	if _, exists := routeMap[fingerprint]; !exists {
+		s.mu.Unlock()
+		return false
+	}
+	delete(routeMap, fingerprint)
+	if rl, ok := s.limits[route]; ok {
+		delete(rl.usage, fingerprint)
+	}
+	s.indexRemoveLocked(route, fingerprint)
+	s.queueLocked(Mutation{Op: OpRemove, Route: route, Fingerprint: fingerprint, Replicated: replicated})
+	s.mu.Unlock()
+
+	s.flush()
+	return true
+}
+
+// ApplyReplicated applies a mutation published by a replica, possibly this one, and
+// reports whether the store changed. Observers still see the resulting changes, with
+// Replicated set. Re-adding a cached fingerprint keeps its existing provenance.
+func (s *MatchStore) ApplyReplicated(m Mutation) bool {
+	if m.Op == OpRemove {
+		return s.remove(m.Route, m.Fingerprint, true)
+	}
+	canonical := m.Canonical
+	if canonical == "" {
+		canonical = m.Fingerprint
+	}
+	return s.add(m.Route, m.Fingerprint, canonical, m.Meta, true)
+}
+
+// Clone copies the entries cached for route from into route to, keeping their provenance.
+// keep selects which entries are copied; nil copies all. Fingerprints already cached for
+// to are left untouched. It returns the number of fingerprints added.
+func (s *MatchStore) Clone(from, to string, keep func(Entry) bool) int {
+	if from == to {
+		return 0
+	}
+	s.mu.Lock()
+	source := s.values[from]
+	target := s.routeLocked(to)
+	var changes []Mutation
+	added := 0
+	for fp, e := range source {
+		if keep != nil && !keep(Entry{Canonical: e.canonical, Meta: e.meta}) {
+			continue
+		}
+		if _, exists := target[fp]; exists {
+			continue
+		}
+		evicted, ok := s.admitLocked(to)
+		if !ok {
+			break
+		}
+		changes = append(changes, evicted...)
+		target[fp] = e
+		if rl, ok := s.limits[to]; ok {
+			rl.track(fp, &s.clock)
+		}
+		s.indexAddLocked(to, fp)
+		changes = append(changes, Mutation{Op: OpAdd, Route: to, Fingerprint: fp, Canonical: e.canonical, Meta: e.meta})
+		added++
+	}
+	s.queueLocked(changes...)
+	s.mu.Unlock()
+
+	s.flush()
+	return added
+}
+
+// Contains reports whether a fingerprint exists for the route.
+func (s *MatchStore) Contains(route string, fingerprint string) bool {
+	if pr := s.probabilistic(route); pr != nil {
+		_, ok := s.probLookup(pr, route, fingerprint)
+		return ok
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	routeMap, ok := s.values[route]
+	if !ok {
+		return false
+	}
+	_, exists := routeMap[fingerprint]
+	return exists
+}
+
+// ContainsAny reports the first of fingerprints cached for route, checking them all under
+// one lock acquisition.
+func (s *MatchStore) ContainsAny(route string, fingerprints []string) (string, bool) {
+	found := -1
+	s.lookupEach(route, fingerprints, false, func(i int, _ Metadata) bool {
+		found = i
+		return false
+	})
+	if found < 0 {
+		return "", false
+	}
+	return fingerprints[found], true
+}
+
+// LookupEach calls fn with the index and provenance of each of fingerprints cached for
+// route, in order, until fn returns false. The route is read under one lock acquisition,
+// which fn must not try to take again by calling the store. Hits count as uses for
+// LRU/LFU eviction.
+func (s *MatchStore) LookupEach(route string, fingerprints []string, fn func(i int, meta Metadata) bool) {
+	s.lookupEach(route, fingerprints, true, fn)
+}
+
+func (s *MatchStore) lookupEach(route string, fingerprints []string, touch bool, fn func(int, Metadata) bool) {
+	if pr := s.probabilistic(route); pr != nil {
+		// hits may need the exact set, which is consulted outside the lock
+		for i, fp := range fingerprints {
+			if meta, ok := s.probLookup(pr, route, fp); ok && !fn(i, meta) {
+				return
+			}
+		}
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	routeMap := s.values[route]
+	for i, fp := range fingerprints {
+		e, ok := routeMap[fp]
+		if !ok {
+			continue
+		}
+		if touch {
+			s.touchLocked(route, fp)
+		}
+		if !fn(i, e.meta) {
+			return
+		}
+	}
+}
+
+// LookupEachBytes is LookupEach for fingerprints held as byte slices, which are looked up
+// without copying them into strings.
+func (s *MatchStore) LookupEachBytes(route string, fingerprints [][]byte, fn func(i int, meta Metadata) bool) {
+	if pr := s.probabilistic(route); pr != nil {
+		for i, fp := range fingerprints {
+			if meta, ok := s.probLookup(pr, route, string(fp)); ok && !fn(i, meta) {
+				return
+			}
+		}
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	routeMap := s.values[route]
+	for i, fp := range fingerprints {
+		e, ok := routeMap[string(fp)]
+		if !ok {
+			continue
+		}
+		s.touchLocked(route, string(fp))
+		if !fn(i, e.meta) {
+			return
+		}
+	}
+}
+
+// Lookup returns the provenance of a fingerprint if it is cached for the route. A hit
+// counts as a use for LRU/LFU eviction.
+func (s *MatchStore) Lookup(route string, fingerprint string) (Metadata, bool) {
+	if pr := s.probabilistic(route); pr != nil {
+		return s.probLookup(pr, route, fingerprint)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.values[route][fingerprint]
+	if ok {
+		s.touchLocked(route, fingerprint)
+	}
+	return e.meta, ok
+}
+
+// Entries returns a copy of every fingerprint cached for the route with its provenance.
+func (s *MatchStore) Entries(route string) map[string]Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]Entry, len(s.values[route]))
+	for fp, e := range s.values[route] {
+		out[fp] = Entry{Canonical: e.canonical, Meta: e.meta}
+	}
+	return out
+}
+
+// Size returns the number of fingerprints stored for the route.
+func (s *MatchStore) Size(route string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if pr, ok := s.filters[route]; ok {
+		return pr.count
+	}
+	return len(s.values[route])
+}
+
+// Clear removes all cached fingerprints across routes and returns the count removed.
+func (s *MatchStore) Clear() int {
+	s.mu.Lock()
+	var removed []Mutation
+	for route, routeMap := range s.values {
+		for fp := range routeMap {
+			removed = append(removed, Mutation{Op: OpRemove, Route: route, Fingerprint: fp})
+		}
+	}
+	s.values = make(map[string]map[string]entry)
+	for _, rl := range s.limits {
+		rl.usage = make(map[string]*usage)
+	}
+	s.resetFiltersLocked()
+	s.indexResetLocked("")
+	s.queueLocked(removed...)
+	s.mu.Unlock()
+
+	s.flush()
+	return len(removed)
+}
+
+// Compact regenerates every stored variant for the route from its canonical values using
+// the provided rules, dropping superseded variants and adding new ones. Passing a func
+// that returns only its input drops all stored variants. It returns the number of
+// fingerprints added and removed.
+func (s *MatchStore) Compact(route string, variants VariantFunc) (int, int) {
+	s.mu.Lock()
+	routeMap := s.values[route]
+	if len(routeMap) == 0 {
+		s.mu.Unlock()
+		return 0, 0
+	}
+
+	canonicals := make(map[string]struct{})
+	for _, e := range routeMap {
+		canonicals[e.canonical] = struct{}{}
+	}
+	ordered := make([]string, 0, len(canonicals))
+	for c := range canonicals {
+		ordered = append(ordered, c)
+	}
+	sort.Strings(ordered)
+
+	next := make(map[string]entry, len(routeMap))
+	for _, c := range ordered {
+		next[c] = entry{canonical: c, meta: routeMap[c].meta}
+	}
+	for _, c := range ordered {
+		for _, v := range variants(c) {
+			if _, exists := next[v]; !exists {
+				next[v] = entry{canonical: c, meta: next[c].meta}
+			}
+		}
+	}
+
+	var changes []Mutation
+	for fp := range routeMap {
+		if _, keep := next[fp]; !keep {
+			changes = append(changes, Mutation{Op: OpRemove, Route: route, Fingerprint: fp})
+		}
+	}
+	removed := len(changes)
+	for fp, e := range next {
+		if old, exists := routeMap[fp]; !exists || old.canonical != e.canonical {
+			changes = append(changes, Mutation{Op: OpAdd, Route: route, Fingerprint: fp, Canonical: e.canonical, Meta: e.meta})
+		}
+	}
+	added := 0
+	for fp := range next {
+		if _, exists := routeMap[fp]; !exists {
+			added++
+		}
+	}
+	s.values[route] = next
+	s.indexResetLocked(route)
+	if rl, ok := s.limits[route]; ok {
+		rl.reset(next, &s.clock)
+		changes = append(changes, s.trimLocked(route, 0)...)
+	}
+	s.queueLocked(changes...)
+	s.mu.Unlock()
+
+	s.flush()
+	return added, removed
+}
+
+// SaveSnapshot atomically writes the canonical values of the current snapshot, with their
+// provenance, to disk.
+func (s *MatchStore) SaveSnapshot(path string, opts SaveOptions) error {
+	snapshot, meta := s.CanonicalSnapshotMeta()
+	return SaveWithMeta(path, snapshot, meta, opts)
+}
+
+// LoadSnapshot reads a snapshot from disk.
+func (s *MatchStore) LoadSnapshot(path string) (map[string][]string, error) {
+	return Load(path)
+}
+
+// Snapshot returns a copy of all values keyed by route.
+func (s *MatchStore) Snapshot() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.values))
+	for route, vals := range s.values {
+		list := make([]string, 0, len(vals))
+		for v := range vals {
+			list = append(list, v)
+		}
+		out[route] = list
+	}
+	return out
+}
+
+// CanonicalSnapshot returns only the canonical values keyed by route.
+func (s *MatchStore) CanonicalSnapshot() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.values))
+	for route, vals := range s.values {
+		list := make([]string, 0, len(vals))
+		for v, e := range vals {
+			if e.canonical == v {
+				list = append(list, v)
+			}
+		}
+		out[route] = list
+	}
+	return out
+}
+
+// CanonicalSnapshotMeta is CanonicalSnapshot, also returning the provenance of the values
+// that have one, by route and value.
+func (s *MatchStore) CanonicalSnapshotMeta() (map[string][]string, map[string]map[string]Metadata) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.values))
+	meta := make(map[string]map[string]Metadata)
+	for route, vals := range s.values {
+		list := make([]string, 0, len(vals))
+		for v, e := range vals {
+			if e.canonical != v {
+				continue
+			}
+			list = append(list, v)
+			if e.meta.IsZero() {
+				continue
+			}
+			if meta[route] == nil {
+				meta[route] = make(map[string]Metadata)
+			}
+			meta[route][v] = e.meta
+		}
+		out[route] = list
+	}
+	return out, meta
+}
+
+// Load replaces the store contents with the provided snapshot. Every value is treated as canonical.
+func (s *MatchStore) Load(snapshot map[string][]string) {
+	entries := make(map[string]map[string]Entry, len(snapshot))
+	for route, vals := range snapshot {
+		routeMap := make(map[string]Entry, len(vals))
+		for _, v := range vals {
+			routeMap[v] = Entry{}
+		}
+		entries[route] = routeMap
+	}
+	s.LoadEntries(entries)
+}
+
+// LoadRoute replaces the values of route with values, every one canonical, and leaves the
+// other routes as they are, for routes persisted apart from the rest. Like Load it does
+// not notify the observer.
+func (s *MatchStore) LoadRoute(route string, values []string) {
+	entries := make(map[string]Entry, len(values))
+	for _, v := range values {
+		entries[v] = Entry{}
+	}
+	s.LoadRouteEntries(route, entries)
+}
+
+// LoadRouteEntries is LoadRoute with the entries of the values, as LoadEntries takes them.
+func (s *MatchStore) LoadRouteEntries(route string, entries map[string]Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexResetLocked(route)
+	if pr, ok := s.filters[route]; ok {
+		pr.filter.reset()
+		pr.count = 0
+		for v := range entries {
+			pr.filter.add(v)
+			pr.count++
+		}
+		return
+	}
+	routeMap := make(map[string]entry, len(entries))
+	for v, e := range entries {
+		canonical := e.Canonical
+		if canonical == "" {
+			canonical = v
+		}
+		routeMap[v] = entry{canonical: canonical, meta: e.Meta}
+	}
+	s.values[route] = routeMap
+	if rl, ok := s.limits[route]; ok {
+		rl.usage = nil
+		rl.reset(routeMap, &s.clock)
+		s.trimLocked(route, 0)
+	}
+}
+
+// LoadEntries replaces the store contents with fingerprints and their entries, keyed by
+// route. An empty canonical marks the fingerprint as canonical itself; entries recorded
+// as variants by earlier versions can be dropped with Compact. Routes over their Limit
+// are trimmed without notifying the observer.
+func (s *MatchStore) LoadEntries(entries map[string]map[string]Entry) {
+	s.loadEntries(entries, true)
+}
+
+// loadEntries is LoadEntries; resetFilters false keeps what probabilistic routes already
+// hold, for restores that fill them while reading.
+func (s *MatchStore) loadEntries(entries map[string]map[string]Entry, resetFilters bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = make(map[string]map[string]entry, len(entries))
+	s.indexResetLocked("")
+	if resetFilters {
+		s.resetFiltersLocked()
+	}
+	for route, vals := range entries {
+		if pr, ok := s.filters[route]; ok {
+			for fp := range vals {
+				pr.filter.add(fp)
+				pr.count++
+			}
+			continue
+		}
+		routeMap := make(map[string]entry, len(vals))
+		for fp, e := range vals {
+			canonical := e.Canonical
+			if canonical == "" {
+				canonical = fp
+			}
+			routeMap[fp] = entry{canonical: canonical, meta: e.Meta}
+		}
+		s.values[route] = routeMap
+	}
+	for route, rl := range s.limits {
+		rl.usage = nil
+		rl.reset(s.values[route], &s.clock)
+		s.trimLocked(route, 0)
+	}
+}
+
+// queueLocked queues mutations, just applied under s.mu, for the observer.
+func (s *MatchStore) queueLocked(mutations ...Mutation) {
+	if s.observer == nil || len(mutations) == 0 {
+		return
+	}
+	s.noticeMu.Lock()
+	s.notices = append(s.notices, notice{observer: s.observer, mutations: mutations})
+	s.noticeMu.Unlock()
+}
+
+// flush delivers the queued mutations in the order they were applied. Only one goroutine
+// delivers at a time: a flush while another goroutine delivers, or from an observer that
+// mutates the store, returns at once and leaves its mutations to that delivery.
+func (s *MatchStore) flush() {
+	s.noticeMu.Lock()
+	if s.notifying {
+		s.noticeMu.Unlock()
+		return
+	}
+	s.notifying = true
+	for len(s.notices) > 0 {
+		batch := s.notices
+		s.notices = nil
+		s.noticeMu.Unlock()
+		for _, n := range batch {
+			for _, m := range n.mutations {
+				n.observer(m)
+			}
+		}
+		s.noticeMu.Lock()
+	}
+	s.notifying = false
+	s.noticeMu.Unlock()
+}
@@ -0,0 +1,484 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatchStoreClear(t *testing.T) {
+	s := NewMatchStore()
+	s.Add("route-a", "one")
+	s.Add("route-a", "two")
+	s.Add("route-b", "one")
+
+	removed := s.Clear()
+	if removed != 3 {
+		t.Fatalf("expected 3 removed entries, got %d", removed)
+	}
+	if got := s.Size("route-a"); got != 0 {
+		t.Fatalf("expected route-a to be empty, got %d", got)
+	}
+	if got := s.Size("route-b"); got != 0 {
+		t.Fatalf("expected route-b to be empty, got %d", got)
+	}
+
+	if removed = s.Clear(); removed != 0 {
+		t.Fatalf("expected no removals from empty store, got %d", removed)
+	}
+}
+
+func TestMatchStoreObserver(t *testing.T) {
+	s := NewMatchStore()
+	var got []Mutation
+	s.SetObserver(func(m Mutation) { got = append(got, m) })
+
+	s.Add("route-a", "one")
+	s.Add("route-a", "one")
+	s.Load(map[string][]string{"route-a": {"one", "two"}})
+	s.Clear()
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 mutations (add + 2 removes), got %d: %v", len(got), got)
+	}
+	if got[0].Op != OpAdd || got[0].Route != "route-a" || got[0].Fingerprint != "one" || got[0].Canonical != "one" {
+		t.Fatalf("unexpected first mutation: %+v", got[0])
+	}
+	for _, m := range got[1:] {
+		if m.Op != OpRemove || m.Route != "route-a" {
+			t.Fatalf("expected removals for route-a on clear, got %+v", m)
+		}
+	}
+}
+
+func TestMatchStoreAddObserverUnregisters(t *testing.T) {
+	s := NewMatchStore()
+	var calls []string
+	s.SetObserver(func(m Mutation) { calls = append(calls, "set:"+m.Fingerprint) })
+	removeA := s.AddObserver(func(m Mutation) { calls = append(calls, "a:"+m.Fingerprint) })
+	removeB := s.AddObserver(func(m Mutation) { calls = append(calls, "b:"+m.Fingerprint) })
+	s.Add("route-a", "one")
+	removeA()
+	removeA()
+	s.Add("route-a", "two")
+	removeB()
+	s.Add("route-a", "three")
+
+	want := []string{"set:one", "a:one", "b:one", "set:two", "b:two", "set:three"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("observers called %v, want %v", calls, want)
+	}
+	if n := s.Observers(); n != 1 {
+		t.Fatalf("%d observer(s) registered, want 1", n)
+	}
+}
+
+func TestMatchStoreObserverOrder(t *testing.T) {
+	s := NewMatchStore()
+	var mu sync.Mutex
+	var seen []Mutation
+	delivering, release := make(chan struct{}), make(chan struct{})
+	s.SetObserver(func(m Mutation) {
+		mu.Lock()
+		first := len(seen) == 0 && m.Op == OpAdd
+		mu.Unlock()
+		if first {
+			close(delivering)
+			<-release
+		}
+		mu.Lock()
+		seen = append(seen, m)
+		mu.Unlock()
+	})
+
+	added := make(chan struct{})
+	go func() {
+		s.Add("route-a", "x")
+		close(added)
+	}()
+	// remove x while the observer is still being told it was added
+	<-delivering
+	s.Remove("route-a", "x")
+	close(release)
+	<-added
+	if len(seen) != 2 || seen[0].Op != OpAdd || seen[1].Op != OpRemove {
+		t.Fatalf("observer saw %+v, want the add then the remove", seen)
+	}
+
+	// an observer that mutates the store sees its own change after the current one
+	seen = nil
+	s.SetObserver(func(m Mutation) {
+		seen = append(seen, m)
+		if m.Op == OpAdd && m.Fingerprint == "x" {
+			s.Remove("route-a", "x")
+		}
+	})
+	s.Add("route-a", "x")
+	if len(seen) != 2 || seen[0].Op != OpAdd || seen[1].Op != OpRemove {
+		t.Fatalf("reentrant mutation notified as %v", seen)
+	}
+}
+
+func TestMatchStoreCompact(t *testing.T) {
+	s := NewMatchStore()
+	s.LoadEntries(map[string]map[string]Entry{
+		"route-a": {"23/x": {}, "2023/x": {Canonical: "23/x"}, "old-rule": {Canonical: "23/x"}, "plain": {Canonical: "plain"}},
+	})
+
+	noVariants := func(v string) []string { return []string{v} }
+	added, removed := s.Compact("route-a", noVariants)
+	if added != 0 || removed != 2 {
+		t.Fatalf("expected 0 added / 2 removed, got %d / %d", added, removed)
+	}
+	if s.Contains("route-a", "2023/x") || s.Contains("route-a", "old-rule") {
+		t.Fatalf("expected superseded variants to be dropped")
+	}
+
+	prefixed := func(v string) []string { return []string{v, "20" + v} }
+	added, removed = s.Compact("route-a", prefixed)
+	if added != 2 || removed != 0 {
+		t.Fatalf("expected 2 added / 0 removed, got %d / %d", added, removed)
+	}
+	for _, fp := range []string{"23/x", "2023/x", "plain", "20plain"} {
+		if !s.Contains("route-a", fp) {
+			t.Fatalf("expected %q after compaction", fp)
+		}
+	}
+
+	canon := s.CanonicalSnapshot()["route-a"]
+	if len(canon) != 2 {
+		t.Fatalf("expected 2 canonical values, got %v", canon)
+	}
+}
+
+func TestMatchStoreAddPromotesVariant(t *testing.T) {
+	s := NewMatchStore()
+	s.LoadEntries(map[string]map[string]Entry{
+		"route-a": {"23/x": {}, "2023/x": {Canonical: "23/x"}},
+	})
+	if added := s.Add("route-a", "2023/x"); added {
+		t.Fatalf("expected existing fingerprint not to count as added")
+	}
+
+	canon := s.CanonicalSnapshot()["route-a"]
+	if len(canon) != 2 {
+		t.Fatalf("expected re-added variant to become canonical, got %v", canon)
+	}
+	if added, removed := s.Compact("route-a", func(v string) []string { return []string{v} }); added != 0 || removed != 0 {
+		t.Fatalf("expected promoted value to survive compaction, got %d / %d", added, removed)
+	}
+}
+
+func TestMatchStoreRemove(t *testing.T) {
+	s := NewMatchStore()
+	var removals int
+	s.SetObserver(func(m Mutation) {
+		if m.Op == OpRemove {
+			removals++
+		}
+	})
+	s.Add("route-a", "one")
+
+	if !s.Remove("route-a", "one") {
+		t.Fatalf("expected existing fingerprint to be removed")
+	}
+	if s.Remove("route-a", "one") || s.Remove("route-b", "one") {
+		t.Fatalf("expected removal of missing fingerprint to report false")
+	}
+	if s.Contains("route-a", "one") || removals != 1 {
+		t.Fatalf("expected one observed removal, got %d", removals)
+	}
+}
+
+func TestMatchStoreMetadata(t *testing.T) {
+	s := NewMatchStore()
+	first := Metadata{Source: SourceKafka, Feed: "feed-a", Topic: "ref", Partition: 1, Offset: 9}
+	if !s.AddWithMeta("route-a", "one", first) {
+		t.Fatalf("expected first add to succeed")
+	}
+	if s.AddWithMeta("route-a", "one", Metadata{Source: SourceHTTP}) {
+		t.Fatalf("expected duplicate add to report false")
+	}
+
+	meta, ok := s.Lookup("route-a", "one")
+	if !ok || meta.Source != SourceKafka || meta.Offset != 9 || meta.AddedAt.IsZero() {
+		t.Fatalf("expected first provenance to be kept, got %+v ok=%v", meta, ok)
+	}
+	if _, ok := s.Lookup("route-a", "missing"); ok {
+		t.Fatalf("expected missing fingerprint lookup to fail")
+	}
+	entries := s.Entries("route-a")
+	if len(entries) != 1 || entries["one"].Meta.Feed != "feed-a" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestMatchStoreClone(t *testing.T) {
+	s := NewMatchStore()
+	s.AddWithMeta("route-a", "one", Metadata{Source: SourceKafka, Feed: "feed-a", Offset: 3})
+	s.AddWithMeta("route-a", "two", Metadata{Source: SourceKafka, Feed: "feed-b"})
+	s.AddWithMeta("route-b", "two", Metadata{Source: SourceHTTP})
+	var added []string
+	s.SetObserver(func(m Mutation) {
+		if m.Op == OpAdd {
+			added = append(added, m.Route+"/"+m.Fingerprint)
+		}
+	})
+
+	if got := s.Clone("route-a", "route-c", func(e Entry) bool { return e.Meta.Feed == "feed-a" }); got != 1 {
+		t.Fatalf("expected 1 cloned entry, got %d", got)
+	}
+	if meta, ok := s.Lookup("route-c", "one"); !ok || meta.Feed != "feed-a" || meta.Offset != 3 {
+		t.Fatalf("expected provenance preserved, got %+v ok=%v", meta, ok)
+	}
+	if s.Contains("route-c", "two") {
+		t.Fatal("expected filtered entry to be skipped")
+	}
+
+	if got := s.Clone("route-a", "route-b", nil); got != 1 {
+		t.Fatalf("expected only the missing entry cloned, got %d", got)
+	}
+	if meta, _ := s.Lookup("route-b", "two"); meta.Source != SourceHTTP {
+		t.Fatalf("expected existing entry untouched, got %+v", meta)
+	}
+	if len(added) != 2 || added[0] != "route-c/one" || added[1] != "route-b/one" {
+		t.Fatalf("unexpected observed adds: %v", added)
+	}
+}
+
+func TestMatchStoreLoadRoute(t *testing.T) {
+	s := NewMatchStore()
+	s.Load(map[string][]string{"route-a": {"one", "two"}, "route-b": {"one"}})
+	notified := 0
+	s.SetObserver(func(Mutation) { notified++ })
+
+	s.LoadRoute("route-a", []string{"three"})
+	if s.Size("route-a") != 1 || !s.Contains("route-a", "three") || s.Contains("route-a", "one") {
+		t.Fatalf("expected route-a replaced, got %v", s.CanonicalSnapshot()["route-a"])
+	}
+	if !s.Contains("route-b", "one") {
+		t.Fatal("expected route-b untouched")
+	}
+	if notified != 0 {
+		t.Fatalf("expected no observer calls, got %d", notified)
+	}
+}
+
+func TestMatchStoreApplyReplicated(t *testing.T) {
+	s := NewMatchStore()
+	var got []Mutation
+	s.SetObserver(func(m Mutation) { got = append(got, m) })
+
+	if !s.ApplyReplicated(Mutation{Op: OpAdd, Route: "route-a", Fingerprint: "2023/x", Canonical: "23/x", Meta: Metadata{Source: SourceHTTP}}) {
+		t.Fatalf("expected replicated add to change the store")
+	}
+	if s.ApplyReplicated(Mutation{Op: OpAdd, Route: "route-a", Fingerprint: "2023/x", Canonical: "23/x"}) {
+		t.Fatalf("expected replaying an applied add to be a no-op")
+	}
+	meta, ok := s.Lookup("route-a", "2023/x")
+	if !ok || meta.Source != SourceHTTP {
+		t.Fatalf("expected replicated provenance to be kept, got %+v (%v)", meta, ok)
+	}
+	if canon := s.CanonicalSnapshot()["route-a"]; len(canon) != 0 {
+		t.Fatalf("expected replicated variant to keep its canonical value, got canonical %v", canon)
+	}
+	s.Add("route-a", "local")
+	if !s.ApplyReplicated(Mutation{Op: OpRemove, Route: "route-a", Fingerprint: "2023/x"}) {
+		t.Fatalf("expected replicated remove to change the store")
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 mutations, got %d: %+v", len(got), got)
+	}
+	if !got[0].Replicated || got[1].Replicated || !got[2].Replicated {
+		t.Fatalf("expected only the local add to be unreplicated, got %+v", got)
+	}
+}
+
+func TestNewMatchStoreOptions(t *testing.T) {
+	var observed []Mutation
+	var logged int
+	s := NewMatchStore(
+		WithObserver(func(m Mutation) { observed = append(observed, m) }),
+		WithLimit("route", Limit{MaxValues: 1, Policy: EvictRejectNew}),
+		WithLogger(func(string, ...any) { logged++ }),
+	)
+	s.Add("route", "a")
+	s.Add("route", "b")
+	if s.Size("route") != 1 || len(observed) != 1 || logged != 1 {
+		t.Fatalf("size=%d observed=%d logged=%d, want 1 each", s.Size("route"), len(observed), logged)
+	}
+}
+
+func TestMatchStoreBatchOperations(t *testing.T) {
+	s := NewMatchStore(WithLimit("route-a", Limit{MaxValues: 3, Policy: EvictRejectNew}))
+	var got []Mutation
+	s.SetObserver(func(m Mutation) { got = append(got, m) })
+
+	s.Add("route-a", "one")
+	if n := s.AddAll("route-a", []string{"one", "two", "three", "four"}); n != 2 {
+		t.Fatalf("AddAll = %d, want 2 (one cached, four rejected)", n)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 add mutations, got %v", got)
+	}
+	if fp, ok := s.ContainsAny("route-a", []string{"five", "three", "two"}); !ok || fp != "three" {
+		t.Fatalf("ContainsAny = %q, %v; want three", fp, ok)
+	}
+	if _, ok := s.ContainsAny("route-a", []string{"four", "five"}); ok {
+		t.Fatal("ContainsAny matched values that are not cached")
+	}
+
+	var hits []int
+	s.LookupEach("route-a", []string{"two", "x", "one", "three"}, func(i int, _ Metadata) bool {
+		hits = append(hits, i)
+		return len(hits) < 2
+	})
+	if len(hits) != 2 || hits[0] != 0 || hits[1] != 2 {
+		t.Fatalf("LookupEach hits = %v, want [0 2]", hits)
+	}
+}
+
+func TestMatchStoreScanSubstrings(t *testing.T) {
+	s := NewMatchStore()
+	s.Add("route-a", "he")
+	s.IndexSubstrings("route-a", nil)
+	s.Add("route-a", "she")
+	s.Add("route-a", "hers")
+	s.Add("route-b", "ushers")
+
+	scan := func(payload string) []string {
+		var found []string
+		s.ScanSubstrings("route-a", []byte(payload), func(fp, variant string, _ Metadata) bool {
+			found = append(found, variant)
+			return true
+		})
+		return found
+	}
+	if got := strings.Join(scan("ushers"), ","); got != "she,he,hers" {
+		t.Fatalf("scan found %s, want she,he,hers", got)
+	}
+
+	if !s.ContainsSubstring("route-a", []byte("a shell")) || s.ContainsSubstring("route-a", []byte("hrs")) {
+		t.Fatal("ContainsSubstring disagrees with the cached values")
+	}
+
+	s.Remove("route-a", "she")
+	s.Add("route-a", "us")
+	if got := strings.Join(scan("ushers"), ","); got != "us,he,hers" {
+		t.Fatalf("scan after changes found %s, want us,he,hers", got)
+	}
+
+	s.Clear()
+	if got := scan("ushers"); len(got) != 0 {
+		t.Fatalf("scan of cleared route found %v", got)
+	}
+	var untouched bool
+	s.ScanSubstrings("route-b", []byte("ushers"), func(string, string, Metadata) bool {
+		untouched = true
+		return true
+	})
+	if untouched {
+		t.Fatal("route without an index reported matches")
+	}
+}
+
+func TestMatchStoreScanSubstringsChanges(t *testing.T) {
+	s := NewMatchStore()
+	s.IndexSubstrings("route", nil)
+	rng := rand.New(rand.NewSource(1))
+	word := func(n int) string {
+		b := make([]byte, 1+rng.Intn(n))
+		for i := range b {
+			b[i] = "abc"[rng.Intn(3)]
+		}
+		return string(b)
+	}
+	for i := 0; i < 2000; i++ {
+		if v := word(5); rng.Intn(3) == 0 {
+			s.Remove("route", v)
+		} else {
+			s.Add("route", v)
+		}
+		payload := word(12)
+		var got []string
+		s.ScanSubstrings("route", []byte(payload), func(fp, _ string, _ Metadata) bool {
+			got = append(got, fp)
+			return true
+		})
+		var want []string
+		for end := 1; end <= len(payload); end++ {
+			for start := 0; start < end; start++ {
+				if s.Contains("route", payload[start:end]) {
+					want = append(want, payload[start:end])
+				}
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("step %d: scan of %s found %v, want %v", i, payload, got, want)
+		}
+	}
+}
+
+func benchmarkStore(n int) *MatchStore {
+	s := NewMatchStore()
+	values := make([]string, n)
+	for i := range values {
+		values[i] = fmt.Sprintf("ACC-%08d", i)
+	}
+	s.AddAll("route", values)
+	return s
+}
+
+func BenchmarkMatchStoreLookupEach(b *testing.B) {
+	s := benchmarkStore(100000)
+	probes := []string{"evt-1", "2024-05-01T12:00:00Z", "value-0-17", "value-1-4", "ACC-00004242", "MISS-1"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.LookupEach("route", probes, func(int, Metadata) bool { return true })
+	}
+}
+
+func BenchmarkMatchStoreAddAll(b *testing.B) {
+	s := NewMatchStore()
+	batch := make([]string, 100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := range batch {
+			batch[j] = fmt.Sprintf("ACC-%08d", i*len(batch)+j)
+		}
+		s.AddAll("route", batch)
+	}
+}
+
+func BenchmarkMatchStoreScanSubstrings(b *testing.B) {
+	s := benchmarkStore(100000)
+	s.IndexSubstrings("route", nil)
+	payload := []byte(`{"id":"evt-1","at":"2024-05-01T12:00:00Z","attr0":"value-0-17","customer":{"account":"MISS-00000042"}}`)
+	s.ContainsSubstring("route", payload)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.ContainsSubstring("route", payload)
+	}
+}
+
+func BenchmarkMatchStoreScanSubstringsChanges(b *testing.B) {
+	s := benchmarkStore(100000)
+	s.IndexSubstrings("route", nil)
+	payload := []byte(`{"id":"evt-1","at":"2024-05-01T12:00:00Z","attr0":"value-0-17","customer":{"account":"ACC-00000042"}}`)
+	s.ContainsSubstring("route", payload)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Add("route", fmt.Sprintf("NEW-%08d", i))
+		s.ContainsSubstring("route", payload)
+		s.Remove("route", fmt.Sprintf("ACC-%08d", i%100000))
+		s.ContainsSubstring("route", payload)
+	}
+}
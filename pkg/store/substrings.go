@@ -0,0 +1,396 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// substringIndex is an Aho-Corasick automaton over every variant of a route's cached
+// values, for finding them inside raw payloads in one pass however many are cached.
+// Values added to the route are inserted and linked as they are cached, relinking only
+// the nodes whose longest suffix in the trie the new nodes become. Values removed are
+// dropped from the nodes they end at; their nodes stay until removed variants outnumber
+// live ones, or the route's values are replaced, when the next scan rebuilds the trie.
+type substringIndex struct {
+	variants VariantFunc
+
+	// mu serialises rebuilds, which happen under the store's read lock.
+	mu sync.Mutex
+	// rebuild is set once the trie no longer reflects the route's values.
+	rebuild bool
+	// live counts the variants in the trie and removed those dropped since the last
+	// rebuild.
+	live, removed int
+	// root is the root's transition table, which every byte that continues no partial
+	// match goes through; the other nodes keep their few edges sorted.
+	root  [256]int32
+	nodes []acNode
+}
+
+type acNode struct {
+	edges []acEdge
+	// fail is the node of the longest proper suffix of this node's path that is also in
+	// the trie; out is a node along the fail chain that ends a value, or was ending one
+	// when it was linked, with none in between, or 0.
+	fail int32
+	out  int32
+	ends []acEnd
+	// depth is the length of the node's path; kids are the nodes failing to this one,
+	// and kid is this node's position among its fail node's kids.
+	depth int32
+	kids  []int32
+	kid   int32
+}
+
+type acEdge struct {
+	c  byte
+	to int32
+}
+
+// acEnd is a variant ending at a node and the fingerprint it was derived from.
+type acEnd struct {
+	fingerprint string
+	variant     string
+}
+
+// IndexSubstrings keeps an Aho-Corasick automaton of route's cached values, expanded by
+// variants, so ScanSubstrings can find them inside payloads. Indexing costs memory in
+// proportion to the total length of the variants. Probabilistic routes keep no values to
+// index.
+func (s *MatchStore) IndexSubstrings(route string, variants VariantFunc) {
+	if variants == nil {
+		variants = func(v string) []string { return []string{v} }
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexes == nil {
+		s.indexes = make(map[string]*substringIndex)
+	}
+	s.indexes[route] = &substringIndex{variants: variants, rebuild: true}
+}
+
+// indexAddLocked inserts and links a newly cached fingerprint in route's index, if it
+// has one.
+func (s *MatchStore) indexAddLocked(route, fingerprint string) {
+	idx := s.indexes[route]
+	if idx == nil || idx.rebuild {
+		return
+	}
+	idx.insert(fingerprint, true)
+}
+
+// indexRemoveLocked drops a fingerprint removed from route from its index, if it has one.
+func (s *MatchStore) indexRemoveLocked(route, fingerprint string) {
+	idx := s.indexes[route]
+	if idx == nil || idx.rebuild {
+		return
+	}
+	idx.remove(fingerprint)
+}
+
+// indexResetLocked marks the index of route, or of every route when route is "", for a
+// rebuild after its values were replaced.
+func (s *MatchStore) indexResetLocked(route string) {
+	for r, idx := range s.indexes {
+		if route == "" || r == route {
+			idx.rebuild = true
+		}
+	}
+}
+
+// ScanSubstrings calls fn with each cached fingerprint of route, and the variant of it
+// found, that occurs in payload, in order of where the variant ends, until fn returns
+// false. Hits count as uses for LRU/LFU eviction. The route must have been indexed with
+// IndexSubstrings; otherwise nothing is reported. fn must not call the store.
+func (s *MatchStore) ScanSubstrings(route string, payload []byte, fn func(fingerprint, variant string, meta Metadata) bool) {
+	s.scanSubstrings(route, payload, true, fn)
+}
+
+// ContainsSubstring reports whether any variant of a value cached for route occurs in
+// payload, without counting it as a use. It reports true for routes that are not indexed,
+// so callers can treat it as a prefilter.
+func (s *MatchStore) ContainsSubstring(route string, payload []byte) bool {
+	s.mu.RLock()
+	_, indexed := s.indexes[route]
+	s.mu.RUnlock()
+	if !indexed {
+		return true
+	}
+	found := false
+	s.scanSubstrings(route, payload, false, func(string, string, Metadata) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+func (s *MatchStore) scanSubstrings(route string, payload []byte, touch bool, fn func(string, string, Metadata) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx := s.indexes[route]
+	if idx == nil {
+		return
+	}
+	routeMap := s.values[route]
+	idx.prepare(routeMap)
+	nodes := idx.nodes
+	state := int32(0)
+	for _, c := range payload {
+		state = idx.step(state, c)
+		for n := state; n > 0; n = nodes[n].out {
+			for _, end := range nodes[n].ends {
+				e, ok := routeMap[end.fingerprint]
+				if !ok {
+					continue
+				}
+				if touch {
+					s.touchLocked(route, end.fingerprint)
+				}
+				if !fn(end.fingerprint, end.variant, e.meta) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// step follows c from state, falling back along the fail links until some node has an
+// edge for it.
+func (idx *substringIndex) step(state int32, c byte) int32 {
+	for state > 0 {
+		if next := idx.nodes[state].child(c); next > 0 {
+			return next
+		}
+		state = idx.nodes[state].fail
+	}
+	return idx.root[c]
+}
+
+// child returns the node n reaches by c, or 0.
+func (n *acNode) child(c byte) int32 {
+	edges := n.edges
+	if len(edges) <= 8 {
+		for _, e := range edges {
+			if e.c == c {
+				return e.to
+			}
+		}
+		return 0
+	}
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].c >= c })
+	if i < len(edges) && edges[i].c == c {
+		return edges[i].to
+	}
+	return 0
+}
+
+// prepare rebuilds the trie if the route's values were replaced, or enough were removed,
+// since the last scan.
+func (idx *substringIndex) prepare(routeMap map[string]entry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.rebuild {
+		return
+	}
+	idx.root = [256]int32{}
+	idx.nodes = nil
+	idx.live, idx.removed = 0, 0
+	for fp := range routeMap {
+		idx.insert(fp, false)
+	}
+	idx.link()
+	idx.rebuild = false
+}
+
+// insert adds every variant of fingerprint to the trie. With linked, the new nodes are
+// linked as they are added; otherwise the links are left for link.
+func (idx *substringIndex) insert(fingerprint string, linked bool) {
+	if len(idx.nodes) == 0 {
+		idx.nodes = []acNode{{}}
+	}
+	for _, variant := range idx.variants(fingerprint) {
+		if variant == "" {
+			continue
+		}
+		n := int32(0)
+		for i := 0; i < len(variant); i++ {
+			parent := n
+			var added bool
+			n, added = idx.childOrNew(parent, variant[i])
+			if added && linked {
+				idx.linkNew(parent, n, variant[i])
+			}
+		}
+		end := acEnd{fingerprint: fingerprint, variant: variant}
+		if slices.Contains(idx.nodes[n].ends, end) {
+			continue
+		}
+		idx.nodes[n].ends = append(idx.nodes[n].ends, end)
+		idx.live++
+		if linked && len(idx.nodes[n].ends) == 1 {
+			idx.relinkOut(n)
+		}
+	}
+}
+
+// remove drops every variant of fingerprint from the nodes they end at. The nodes keep
+// their links; a node left without ends is passed over by scans.
+func (idx *substringIndex) remove(fingerprint string) {
+	for _, variant := range idx.variants(fingerprint) {
+		n := idx.find(variant)
+		if n == 0 {
+			continue
+		}
+		node := &idx.nodes[n]
+		if i := slices.Index(node.ends, acEnd{fingerprint: fingerprint, variant: variant}); i >= 0 {
+			node.ends = slices.Delete(node.ends, i, i+1)
+			idx.live--
+			idx.removed++
+		}
+	}
+	if idx.removed > idx.live {
+		idx.rebuild = true
+	}
+}
+
+// find returns the node of variant, or 0.
+func (idx *substringIndex) find(variant string) int32 {
+	n := int32(0)
+	for i := 0; i < len(variant); i++ {
+		if n = idx.next(n, variant[i]); n == 0 {
+			return 0
+		}
+	}
+	return n
+}
+
+// next returns the node n reaches by c, or 0.
+func (idx *substringIndex) next(n int32, c byte) int32 {
+	if n == 0 {
+		return idx.root[c]
+	}
+	return idx.nodes[n].child(c)
+}
+
+// childOrNew returns the node n reaches by c, adding it if there is none, and whether it
+// was added.
+func (idx *substringIndex) childOrNew(n int32, c byte) (int32, bool) {
+	if next := idx.next(n, c); next > 0 {
+		return next, false
+	}
+	next := int32(len(idx.nodes))
+	idx.nodes = append(idx.nodes, acNode{depth: idx.nodes[n].depth + 1, kid: -1})
+	if n == 0 {
+		idx.root[c] = next
+		return next, true
+	}
+	edges := idx.nodes[n].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].c >= c })
+	edges = append(edges, acEdge{})
+	copy(edges[i+1:], edges[i:])
+	edges[i] = acEdge{c: c, to: next}
+	idx.nodes[n].edges = edges
+	return next, true
+}
+
+// link computes every node's fail and out links, breadth first from the root.
+func (idx *substringIndex) link() {
+	if len(idx.nodes) == 0 {
+		idx.nodes = []acNode{{}}
+	}
+	nodes := idx.nodes
+	queue := make([]int32, 0, len(nodes))
+	for _, child := range idx.root {
+		if child > 0 {
+			idx.setFail(child, 0)
+			nodes[child].out = 0
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range nodes[n].edges {
+			f := idx.step(nodes[n].fail, e.c)
+			idx.setFail(e.to, f)
+			nodes[e.to].out = idx.outOf(f)
+			queue = append(queue, e.to)
+		}
+	}
+}
+
+// linkNew links node v, just added below parent by c, into a trie whose other nodes are
+// linked. v becomes the fail node of every node whose path ends with v's and failed to a
+// shorter suffix: those are reached by c from the nodes failing, directly or not, to
+// parent. v ends no value yet, so no out link changes.
+func (idx *substringIndex) linkNew(parent, v int32, c byte) {
+	f := int32(0)
+	if parent > 0 {
+		f = idx.step(idx.nodes[parent].fail, c)
+	}
+	idx.setFail(v, f)
+	idx.nodes[v].out = idx.outOf(f)
+
+	var moved []int32
+	idx.walkFailTree(parent, func(x int32) bool {
+		// nodes added after v are not linked yet, and are linked after it
+		if u := idx.next(x, c); u > 0 && u != v && idx.nodes[u].kid >= 0 && idx.nodes[idx.nodes[u].fail].depth < idx.nodes[v].depth {
+			moved = append(moved, u)
+		}
+		return true
+	})
+	for _, u := range moved {
+		idx.setFail(u, v)
+	}
+}
+
+// relinkOut points at n the out links of the nodes failing to n, directly or through
+// nodes that end no value, once n ends one.
+func (idx *substringIndex) relinkOut(n int32) {
+	for _, kid := range idx.nodes[n].kids {
+		idx.walkFailTree(kid, func(x int32) bool {
+			idx.nodes[x].out = n
+			return len(idx.nodes[x].ends) == 0
+		})
+	}
+}
+
+// walkFailTree calls fn with n and every node failing to it, directly or not, not
+// descending below the nodes fn returns false for.
+func (idx *substringIndex) walkFailTree(n int32, fn func(int32) bool) {
+	stack := []int32{n}
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if fn(x) {
+			stack = append(stack, idx.nodes[x].kids...)
+		}
+	}
+}
+
+// setFail makes f the fail node of n, moving n from the kids of its previous one. A node
+// not linked yet has a kid of -1.
+func (idx *substringIndex) setFail(n, f int32) {
+	nodes := idx.nodes
+	if nodes[n].kid >= 0 {
+		if nodes[n].fail == f {
+			return
+		}
+		kids := nodes[nodes[n].fail].kids
+		last := kids[len(kids)-1]
+		kids[nodes[n].kid], nodes[last].kid = last, nodes[n].kid
+		nodes[nodes[n].fail].kids = kids[:len(kids)-1]
+	}
+	nodes[n].fail = f
+	nodes[n].kid = int32(len(nodes[f].kids))
+	nodes[f].kids = append(nodes[f].kids, n)
+}
+
+// outOf returns the out link of a node failing to f.
+func (idx *substringIndex) outOf(f int32) int32 {
+	if len(idx.nodes[f].ends) > 0 {
+		return f
+	}
+	return idx.nodes[f].out
+}
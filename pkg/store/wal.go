@@ -0,0 +1,209 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
+
+// WAL is an append-only log of store mutations kept next to a snapshot file, so changes
+// made since the last snapshot survive a crash. Each mutation is written as one JSON line
+// when Record sees it; Checkpoint starts a new log around every snapshot save.
+//
+// Records reach the operating system immediately but are not synced, so they survive a
+// crash of the process, not necessarily one of the host.
+type WAL struct {
+	path string
+
+	mu       sync.Mutex
+	f        *os.File
+	failures uint64
+}
+
+// walRecord is one line of the log.
+type walRecord struct {
+	Op          string   `json:"op"`
+	Route       string   `json:"route"`
+	Fingerprint string   `json:"fingerprint"`
+	Canonical   string   `json:"canonical,omitempty"`
+	Meta        Metadata `json:"meta,omitzero"`
+}
+
+const (
+	walOpAdd    = "add"
+	walOpRemove = "remove"
+)
+
+// walPrevSuffix names the log segment being checkpointed, kept until its snapshot is saved.
+const walPrevSuffix = ".prev"
+
+// OpenWAL opens the log at path for appending, creating it if needed. A last record cut
+// off by a crash is terminated so that new records start on a line of their own.
+func OpenWAL(path string) (*WAL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("terminate wal: %w", err)
+			}
+		}
+	}
+	return &WAL{path: path, f: f}, nil
+}
+
+// Record appends a mutation. It is safe to use as a store observer; write failures are
+// logged, throttled, rather than returned.
+func (w *WAL) Record(m Mutation) {
+	rec := walRecord{Op: walOpAdd, Route: m.Route, Fingerprint: m.Fingerprint, Canonical: m.Canonical, Meta: m.Meta}
+	if m.Op == OpRemove {
+		rec = walRecord{Op: walOpRemove, Route: m.Route, Fingerprint: m.Fingerprint}
+	}
+	line, err := json.Marshal(rec)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		_, err = w.f.Write(append(line, '\n'))
+	}
+	if err != nil {
+		w.failures++
+		if w.failures == 1 || w.failures%evictionLogEvery == 0 {
+			log.Printf("warn: wal %s: append failed (%d failure(s)): %v", w.path, w.failures, err)
+		}
+	}
+}
+
+// Checkpoint moves the records logged so far aside, runs save, which should write a
+// snapshot including them, and drops them once it succeeds. Records logged while save
+// runs start the next log. If save fails, the records are kept for the next checkpoint.
+func (w *WAL) Checkpoint(save func() error) error {
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	if err := os.Remove(w.path + walPrevSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove checkpointed wal: %w", err)
+	}
+	return nil
+}
+
+// rotate starts an empty log. Records of a previous segment whose snapshot failed to save
+// are still pending, so the current log is appended to it instead of replacing it.
+func (w *WAL) rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prev := w.path + walPrevSuffix
+	if _, err := os.Stat(prev); errors.Is(err, fs.ErrNotExist) {
+		if err := w.f.Close(); err != nil {
+			return fmt.Errorf("close wal: %w", err)
+		}
+		if err := os.Rename(w.path, prev); err != nil {
+			return fmt.Errorf("rotate wal: %w", err)
+		}
+	} else {
+		current, err := os.ReadFile(w.path)
+		if err != nil {
+			return fmt.Errorf("read wal: %w", err)
+		}
+		pf, err := os.OpenFile(prev, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open checkpointed wal: %w", err)
+		}
+		_, err = pf.Write(current)
+		if cerr := pf.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("append checkpointed wal: %w", err)
+		}
+		if err := w.f.Close(); err != nil {
+			return fmt.Errorf("close wal: %w", err)
+		}
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open wal: %w", err)
+	}
+	w.f = f
+	return nil
+}
+
+// Close closes the log.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// ReplayWAL applies the records of the log at path, including a segment left behind by
+// an unfinished checkpoint, to s and returns how many it applied. Call it after loading
+// the snapshot and before registering observers. Corrupt lines, such as a record cut off
+// by a crash, are logged and skipped.
+func ReplayWAL(path string, s *MatchStore) (int, error) {
+	total := 0
+	for _, segment := range []string{path + walPrevSuffix, path} {
+		n, err := replayWALFile(segment, s)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func replayWALFile(path string, s *MatchStore) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open wal: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	applied := 0
+	for line := 1; ; line++ {
+		raw, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(raw)) > 0 {
+				log.Printf("warn: wal %s: ignoring incomplete last record", path)
+			}
+			return applied, nil
+		}
+		if err != nil {
+			return applied, fmt.Errorf("read wal: %w", err)
+		}
+		var rec walRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			log.Printf("warn: wal %s: skipping corrupt record on line %d: %v", path, line, err)
+			continue
+		}
+		switch rec.Op {
+		case walOpAdd:
+			canonical := rec.Canonical
+			if canonical == "" {
+				canonical = rec.Fingerprint
+			}
+			s.add(rec.Route, rec.Fingerprint, canonical, rec.Meta, false)
+		case walOpRemove:
+			s.remove(rec.Route, rec.Fingerprint, false)
+		default:
+			log.Printf("warn: wal %s: skipping unknown op %q on line %d", path, rec.Op, line)
+			continue
+		}
+		applied++
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALReplaysChangesSinceSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "cache.json")
+	walPath := snapshotPath + ".wal"
+
+	wal, err := OpenWAL(walPath)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	s := NewMatchStore(WithObserver(wal.Record))
+	s.Add("route-a", "one")
+	save := func() error { return s.SaveSnapshot(snapshotPath, SaveOptions{}) }
+	if err := wal.Checkpoint(save); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	s.AddWithMeta("route-a", "two", Metadata{Source: SourceHTTP, Annotations: map[string]string{"owner": "ops"}})
+	s.Add("route-a", "three")
+	s.Remove("route-a", "one")
+	if err := wal.Checkpoint(func() error { return os.ErrPermission }); err == nil {
+		t.Fatal("expected the failed save to be reported")
+	}
+	s.Add("route-b", "four")
+	wal.Close()
+	// a crash cut the last record short
+	f, _ := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"op":"add","route":"route-b","fingerp`)
+	f.Close()
+
+	restored := NewMatchStore()
+	snap, err := Load(snapshotPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	restored.Load(snap)
+	n, err := ReplayWAL(walPath, restored)
+	if err != nil || n != 4 {
+		t.Fatalf("ReplayWAL = %d, %v; want 4", n, err)
+	}
+	if restored.Contains("route-a", "one") || !restored.Contains("route-a", "three") || !restored.Contains("route-b", "four") {
+		t.Fatalf("unexpected restored cache: %v", restored.Snapshot())
+	}
+	if meta, _ := restored.Lookup("route-a", "two"); meta.Annotations["owner"] != "ops" {
+		t.Fatalf("provenance not replayed: %+v", meta)
+	}
+
+	// the next successful checkpoint drops every replayed record
+	wal, err = OpenWAL(walPath)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	restored.SetObserver(wal.Record)
+	restored.Add("route-b", "five")
+	if err := wal.Checkpoint(func() error { return restored.SaveSnapshot(snapshotPath, SaveOptions{}) }); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if _, err := os.Stat(walPath + walPrevSuffix); !os.IsNotExist(err) {
+		t.Fatalf("checkpointed segment still present: %v", err)
+	}
+	if n, err := ReplayWAL(walPath, NewMatchStore()); err != nil || n != 0 {
+		t.Fatalf("ReplayWAL after checkpoint = %d, %v; want 0", n, err)
+	}
+}
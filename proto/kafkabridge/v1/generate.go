@@ -0,0 +1,4 @@
+// Package kafkabridgev1 holds the generated gRPC bindings of the bridge's reference API.
+package kafkabridgev1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative reference.proto
@@ -0,0 +1,761 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: reference.proto
+
+package kafkabridgev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AddValuesRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Route  string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	Values []string               `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	// Annotations are operator notes such as owner, ticket, and reason.
+	Annotations map[string]string `protobuf:"bytes,3,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// IdempotencyKey lets a retried call be applied once across coordinated replicas.
+	IdempotencyKey string `protobuf:"bytes,4,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AddValuesRequest) Reset() {
+	*x = AddValuesRequest{}
+	mi := &file_reference_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddValuesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddValuesRequest) ProtoMessage() {}
+
+func (x *AddValuesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddValuesRequest.ProtoReflect.Descriptor instead.
+func (*AddValuesRequest) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AddValuesRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *AddValuesRequest) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *AddValuesRequest) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *AddValuesRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type RemoveValuesRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Route          string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	Values         []string               `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	IdempotencyKey string                 `protobuf:"bytes,3,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RemoveValuesRequest) Reset() {
+	*x = RemoveValuesRequest{}
+	mi := &file_reference_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveValuesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveValuesRequest) ProtoMessage() {}
+
+func (x *RemoveValuesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveValuesRequest.ProtoReflect.Descriptor instead.
+func (*RemoveValuesRequest) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RemoveValuesRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *RemoveValuesRequest) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *RemoveValuesRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type MutationResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Changed reports whether any route's cache changed.
+	Changed       bool `protobuf:"varint,1,opt,name=changed,proto3" json:"changed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MutationResponse) Reset() {
+	*x = MutationResponse{}
+	mi := &file_reference_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MutationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MutationResponse) ProtoMessage() {}
+
+func (x *MutationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MutationResponse.ProtoReflect.Descriptor instead.
+func (*MutationResponse) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MutationResponse) GetChanged() bool {
+	if x != nil {
+		return x.Changed
+	}
+	return false
+}
+
+type QueryCacheRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Route string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	// Fingerprints, when set, limits the response to these cached fingerprints.
+	Fingerprints  []string `protobuf:"bytes,2,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryCacheRequest) Reset() {
+	*x = QueryCacheRequest{}
+	mi := &file_reference_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryCacheRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryCacheRequest) ProtoMessage() {}
+
+func (x *QueryCacheRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryCacheRequest.ProtoReflect.Descriptor instead.
+func (*QueryCacheRequest) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *QueryCacheRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *QueryCacheRequest) GetFingerprints() []string {
+	if x != nil {
+		return x.Fingerprints
+	}
+	return nil
+}
+
+type QueryCacheResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Route         string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	Annotations   map[string]string      `protobuf:"bytes,2,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Values        []*CachedValue         `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryCacheResponse) Reset() {
+	*x = QueryCacheResponse{}
+	mi := &file_reference_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryCacheResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryCacheResponse) ProtoMessage() {}
+
+func (x *QueryCacheResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryCacheResponse.ProtoReflect.Descriptor instead.
+func (*QueryCacheResponse) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *QueryCacheResponse) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *QueryCacheResponse) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *QueryCacheResponse) GetValues() []*CachedValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type CachedValue struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprint string                 `protobuf:"bytes,1,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Canonical is the reference value the fingerprint was derived from.
+	Canonical     string  `protobuf:"bytes,2,opt,name=canonical,proto3" json:"canonical,omitempty"`
+	Origin        *Origin `protobuf:"bytes,3,opt,name=origin,proto3" json:"origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CachedValue) Reset() {
+	*x = CachedValue{}
+	mi := &file_reference_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CachedValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedValue) ProtoMessage() {}
+
+func (x *CachedValue) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedValue.ProtoReflect.Descriptor instead.
+func (*CachedValue) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CachedValue) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *CachedValue) GetCanonical() string {
+	if x != nil {
+		return x.Canonical
+	}
+	return ""
+}
+
+func (x *CachedValue) GetOrigin() *Origin {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+// Origin describes where a cached value came from.
+type Origin struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Source is kafka for reference feeds and http for values added through the admin APIs.
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Feed          string                 `protobuf:"bytes,2,opt,name=feed,proto3" json:"feed,omitempty"`
+	Topic         string                 `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition     int32                  `protobuf:"varint,4,opt,name=partition,proto3" json:"partition,omitempty"`
+	Offset        int64                  `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	AddedAt       *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=added_at,json=addedAt,proto3" json:"added_at,omitempty"`
+	Annotations   map[string]string      `protobuf:"bytes,7,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Origin) Reset() {
+	*x = Origin{}
+	mi := &file_reference_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Origin) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Origin) ProtoMessage() {}
+
+func (x *Origin) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Origin.ProtoReflect.Descriptor instead.
+func (*Origin) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Origin) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Origin) GetFeed() string {
+	if x != nil {
+		return x.Feed
+	}
+	return ""
+}
+
+func (x *Origin) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Origin) GetPartition() int32 {
+	if x != nil {
+		return x.Partition
+	}
+	return 0
+}
+
+func (x *Origin) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Origin) GetAddedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AddedAt
+	}
+	return nil
+}
+
+func (x *Origin) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type WatchMatchesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Route         string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMatchesRequest) Reset() {
+	*x = WatchMatchesRequest{}
+	mi := &file_reference_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMatchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMatchesRequest) ProtoMessage() {}
+
+func (x *WatchMatchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMatchesRequest.ProtoReflect.Descriptor instead.
+func (*WatchMatchesRequest) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchMatchesRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+// MatchEvent describes one forwarded message.
+type MatchEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Route string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	// Field is the payload field that matched, value its payload value, and fingerprint
+	// the cached value it matched.
+	Field            string                 `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	Value            string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Fingerprint      string                 `protobuf:"bytes,4,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Origin           *Origin                `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`
+	SourcePartition  int32                  `protobuf:"varint,6,opt,name=source_partition,json=sourcePartition,proto3" json:"source_partition,omitempty"`
+	SourceOffset     int64                  `protobuf:"varint,7,opt,name=source_offset,json=sourceOffset,proto3" json:"source_offset,omitempty"`
+	DestinationTopic string                 `protobuf:"bytes,8,opt,name=destination_topic,json=destinationTopic,proto3" json:"destination_topic,omitempty"`
+	ForwardedAt      *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=forwarded_at,json=forwardedAt,proto3" json:"forwarded_at,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *MatchEvent) Reset() {
+	*x = MatchEvent{}
+	mi := &file_reference_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchEvent) ProtoMessage() {}
+
+func (x *MatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_reference_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchEvent.ProtoReflect.Descriptor instead.
+func (*MatchEvent) Descriptor() ([]byte, []int) {
+	return file_reference_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MatchEvent) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *MatchEvent) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *MatchEvent) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *MatchEvent) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *MatchEvent) GetOrigin() *Origin {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *MatchEvent) GetSourcePartition() int32 {
+	if x != nil {
+		return x.SourcePartition
+	}
+	return 0
+}
+
+func (x *MatchEvent) GetSourceOffset() int64 {
+	if x != nil {
+		return x.SourceOffset
+	}
+	return 0
+}
+
+func (x *MatchEvent) GetDestinationTopic() string {
+	if x != nil {
+		return x.DestinationTopic
+	}
+	return ""
+}
+
+func (x *MatchEvent) GetForwardedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ForwardedAt
+	}
+	return nil
+}
+
+var File_reference_proto protoreflect.FileDescriptor
+
+const file_reference_proto_rawDesc = "" +
+	"\n" +
+	"\x0freference.proto\x12\x0ekafkabridge.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfe\x01\n" +
+	"\x10AddValuesRequest\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12\x16\n" +
+	"\x06values\x18\x02 \x03(\tR\x06values\x12S\n" +
+	"\vannotations\x18\x03 \x03(\v21.kafkabridge.v1.AddValuesRequest.AnnotationsEntryR\vannotations\x12'\n" +
+	"\x0fidempotency_key\x18\x04 \x01(\tR\x0eidempotencyKey\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"l\n" +
+	"\x13RemoveValuesRequest\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12\x16\n" +
+	"\x06values\x18\x02 \x03(\tR\x06values\x12'\n" +
+	"\x0fidempotency_key\x18\x03 \x01(\tR\x0eidempotencyKey\",\n" +
+	"\x10MutationResponse\x12\x18\n" +
+	"\achanged\x18\x01 \x01(\bR\achanged\"M\n" +
+	"\x11QueryCacheRequest\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12\"\n" +
+	"\ffingerprints\x18\x02 \x03(\tR\ffingerprints\"\xf6\x01\n" +
+	"\x12QueryCacheResponse\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12U\n" +
+	"\vannotations\x18\x02 \x03(\v23.kafkabridge.v1.QueryCacheResponse.AnnotationsEntryR\vannotations\x123\n" +
+	"\x06values\x18\x03 \x03(\v2\x1b.kafkabridge.v1.CachedValueR\x06values\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"}\n" +
+	"\vCachedValue\x12 \n" +
+	"\vfingerprint\x18\x01 \x01(\tR\vfingerprint\x12\x1c\n" +
+	"\tcanonical\x18\x02 \x01(\tR\tcanonical\x12.\n" +
+	"\x06origin\x18\x03 \x01(\v2\x16.kafkabridge.v1.OriginR\x06origin\"\xc2\x02\n" +
+	"\x06Origin\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x12\n" +
+	"\x04feed\x18\x02 \x01(\tR\x04feed\x12\x14\n" +
+	"\x05topic\x18\x03 \x01(\tR\x05topic\x12\x1c\n" +
+	"\tpartition\x18\x04 \x01(\x05R\tpartition\x12\x16\n" +
+	"\x06offset\x18\x05 \x01(\x03R\x06offset\x125\n" +
+	"\badded_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\aaddedAt\x12I\n" +
+	"\vannotations\x18\a \x03(\v2'.kafkabridge.v1.Origin.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"+\n" +
+	"\x13WatchMatchesRequest\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\"\xdc\x02\n" +
+	"\n" +
+	"MatchEvent\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12 \n" +
+	"\vfingerprint\x18\x04 \x01(\tR\vfingerprint\x12.\n" +
+	"\x06origin\x18\x05 \x01(\v2\x16.kafkabridge.v1.OriginR\x06origin\x12)\n" +
+	"\x10source_partition\x18\x06 \x01(\x05R\x0fsourcePartition\x12#\n" +
+	"\rsource_offset\x18\a \x01(\x03R\fsourceOffset\x12+\n" +
+	"\x11destination_topic\x18\b \x01(\tR\x10destinationTopic\x12=\n" +
+	"\fforwarded_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\vforwardedAt2\xe2\x02\n" +
+	"\x10ReferenceService\x12O\n" +
+	"\tAddValues\x12 .kafkabridge.v1.AddValuesRequest\x1a .kafkabridge.v1.MutationResponse\x12U\n" +
+	"\fRemoveValues\x12#.kafkabridge.v1.RemoveValuesRequest\x1a .kafkabridge.v1.MutationResponse\x12S\n" +
+	"\n" +
+	"QueryCache\x12!.kafkabridge.v1.QueryCacheRequest\x1a\".kafkabridge.v1.QueryCacheResponse\x12Q\n" +
+	"\fWatchMatches\x12#.kafkabridge.v1.WatchMatchesRequest\x1a\x1a.kafkabridge.v1.MatchEvent0\x01B1Z/kafka-bridge/proto/kafkabridge/v1;kafkabridgev1b\x06proto3"
+
+var (
+	file_reference_proto_rawDescOnce sync.Once
+	file_reference_proto_rawDescData []byte
+)
+
+func file_reference_proto_rawDescGZIP() []byte {
+	file_reference_proto_rawDescOnce.Do(func() {
+		file_reference_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_reference_proto_rawDesc), len(file_reference_proto_rawDesc)))
+	})
+	return file_reference_proto_rawDescData
+}
+
+var file_reference_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_reference_proto_goTypes = []any{
+	(*AddValuesRequest)(nil),      // 0: kafkabridge.v1.AddValuesRequest
+	(*RemoveValuesRequest)(nil),   // 1: kafkabridge.v1.RemoveValuesRequest
+	(*MutationResponse)(nil),      // 2: kafkabridge.v1.MutationResponse
+	(*QueryCacheRequest)(nil),     // 3: kafkabridge.v1.QueryCacheRequest
+	(*QueryCacheResponse)(nil),    // 4: kafkabridge.v1.QueryCacheResponse
+	(*CachedValue)(nil),           // 5: kafkabridge.v1.CachedValue
+	(*Origin)(nil),                // 6: kafkabridge.v1.Origin
+	(*WatchMatchesRequest)(nil),   // 7: kafkabridge.v1.WatchMatchesRequest
+	(*MatchEvent)(nil),            // 8: kafkabridge.v1.MatchEvent
+	nil,                           // 9: kafkabridge.v1.AddValuesRequest.AnnotationsEntry
+	nil,                           // 10: kafkabridge.v1.QueryCacheResponse.AnnotationsEntry
+	nil,                           // 11: kafkabridge.v1.Origin.AnnotationsEntry
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_reference_proto_depIdxs = []int32{
+	9,  // 0: kafkabridge.v1.AddValuesRequest.annotations:type_name -> kafkabridge.v1.AddValuesRequest.AnnotationsEntry
+	10, // 1: kafkabridge.v1.QueryCacheResponse.annotations:type_name -> kafkabridge.v1.QueryCacheResponse.AnnotationsEntry
+	5,  // 2: kafkabridge.v1.QueryCacheResponse.values:type_name -> kafkabridge.v1.CachedValue
+	6,  // 3: kafkabridge.v1.CachedValue.origin:type_name -> kafkabridge.v1.Origin
+	12, // 4: kafkabridge.v1.Origin.added_at:type_name -> google.protobuf.Timestamp
+	11, // 5: kafkabridge.v1.Origin.annotations:type_name -> kafkabridge.v1.Origin.AnnotationsEntry
+	6,  // 6: kafkabridge.v1.MatchEvent.origin:type_name -> kafkabridge.v1.Origin
+	12, // 7: kafkabridge.v1.MatchEvent.forwarded_at:type_name -> google.protobuf.Timestamp
+	0,  // 8: kafkabridge.v1.ReferenceService.AddValues:input_type -> kafkabridge.v1.AddValuesRequest
+	1,  // 9: kafkabridge.v1.ReferenceService.RemoveValues:input_type -> kafkabridge.v1.RemoveValuesRequest
+	3,  // 10: kafkabridge.v1.ReferenceService.QueryCache:input_type -> kafkabridge.v1.QueryCacheRequest
+	7,  // 11: kafkabridge.v1.ReferenceService.WatchMatches:input_type -> kafkabridge.v1.WatchMatchesRequest
+	2,  // 12: kafkabridge.v1.ReferenceService.AddValues:output_type -> kafkabridge.v1.MutationResponse
+	2,  // 13: kafkabridge.v1.ReferenceService.RemoveValues:output_type -> kafkabridge.v1.MutationResponse
+	4,  // 14: kafkabridge.v1.ReferenceService.QueryCache:output_type -> kafkabridge.v1.QueryCacheResponse
+	8,  // 15: kafkabridge.v1.ReferenceService.WatchMatches:output_type -> kafkabridge.v1.MatchEvent
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_reference_proto_init() }
+func file_reference_proto_init() {
+	if File_reference_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_reference_proto_rawDesc), len(file_reference_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_reference_proto_goTypes,
+		DependencyIndexes: file_reference_proto_depIdxs,
+		MessageInfos:      file_reference_proto_msgTypes,
+	}.Build()
+	File_reference_proto = out.File
+	file_reference_proto_goTypes = nil
+	file_reference_proto_depIdxs = nil
+}
@@ -0,0 +1,257 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: reference.proto
+
+package kafkabridgev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ReferenceService_AddValues_FullMethodName    = "/kafkabridge.v1.ReferenceService/AddValues"
+	ReferenceService_RemoveValues_FullMethodName = "/kafkabridge.v1.ReferenceService/RemoveValues"
+	ReferenceService_QueryCache_FullMethodName   = "/kafkabridge.v1.ReferenceService/QueryCache"
+	ReferenceService_WatchMatches_FullMethodName = "/kafkabridge.v1.ReferenceService/WatchMatches"
+)
+
+// ReferenceServiceClient is the client API for ReferenceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ReferenceService manages the cached reference values of the bridge's routes. It mirrors
+// the /reference, /referenceAllRoutes, and /cache admin HTTP endpoints and is guarded by
+// the same admin token and read-only mode.
+type ReferenceServiceClient interface {
+	// AddValues caches values for one route, or for every route when route is empty.
+	AddValues(ctx context.Context, in *AddValuesRequest, opts ...grpc.CallOption) (*MutationResponse, error)
+	// RemoveValues drops values from one route, or from every route when route is empty.
+	RemoveValues(ctx context.Context, in *RemoveValuesRequest, opts ...grpc.CallOption) (*MutationResponse, error)
+	// QueryCache lists a route's cached values with their provenance.
+	QueryCache(ctx context.Context, in *QueryCacheRequest, opts ...grpc.CallOption) (*QueryCacheResponse, error)
+	// WatchMatches streams every message a route forwards, or every route's when route is
+	// empty, until the client cancels. Slow clients miss events rather than stall routes.
+	WatchMatches(ctx context.Context, in *WatchMatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MatchEvent], error)
+}
+
+type referenceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReferenceServiceClient(cc grpc.ClientConnInterface) ReferenceServiceClient {
+	return &referenceServiceClient{cc}
+}
+
+func (c *referenceServiceClient) AddValues(ctx context.Context, in *AddValuesRequest, opts ...grpc.CallOption) (*MutationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MutationResponse)
+	err := c.cc.Invoke(ctx, ReferenceService_AddValues_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *referenceServiceClient) RemoveValues(ctx context.Context, in *RemoveValuesRequest, opts ...grpc.CallOption) (*MutationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MutationResponse)
+	err := c.cc.Invoke(ctx, ReferenceService_RemoveValues_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *referenceServiceClient) QueryCache(ctx context.Context, in *QueryCacheRequest, opts ...grpc.CallOption) (*QueryCacheResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryCacheResponse)
+	err := c.cc.Invoke(ctx, ReferenceService_QueryCache_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *referenceServiceClient) WatchMatches(ctx context.Context, in *WatchMatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MatchEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReferenceService_ServiceDesc.Streams[0], ReferenceService_WatchMatches_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMatchesRequest, MatchEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReferenceService_WatchMatchesClient = grpc.ServerStreamingClient[MatchEvent]
+
+// ReferenceServiceServer is the server API for ReferenceService service.
+// All implementations must embed UnimplementedReferenceServiceServer
+// for forward compatibility.
+//
+// ReferenceService manages the cached reference values of the bridge's routes. It mirrors
+// the /reference, /referenceAllRoutes, and /cache admin HTTP endpoints and is guarded by
+// the same admin token and read-only mode.
+type ReferenceServiceServer interface {
+	// AddValues caches values for one route, or for every route when route is empty.
+	AddValues(context.Context, *AddValuesRequest) (*MutationResponse, error)
+	// RemoveValues drops values from one route, or from every route when route is empty.
+	RemoveValues(context.Context, *RemoveValuesRequest) (*MutationResponse, error)
+	// QueryCache lists a route's cached values with their provenance.
+	QueryCache(context.Context, *QueryCacheRequest) (*QueryCacheResponse, error)
+	// WatchMatches streams every message a route forwards, or every route's when route is
+	// empty, until the client cancels. Slow clients miss events rather than stall routes.
+	WatchMatches(*WatchMatchesRequest, grpc.ServerStreamingServer[MatchEvent]) error
+	mustEmbedUnimplementedReferenceServiceServer()
+}
+
+// UnimplementedReferenceServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReferenceServiceServer struct{}
+
+func (UnimplementedReferenceServiceServer) AddValues(context.Context, *AddValuesRequest) (*MutationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddValues not implemented")
+}
+func (UnimplementedReferenceServiceServer) RemoveValues(context.Context, *RemoveValuesRequest) (*MutationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveValues not implemented")
+}
+func (UnimplementedReferenceServiceServer) QueryCache(context.Context, *QueryCacheRequest) (*QueryCacheResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryCache not implemented")
+}
+func (UnimplementedReferenceServiceServer) WatchMatches(*WatchMatchesRequest, grpc.ServerStreamingServer[MatchEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMatches not implemented")
+}
+func (UnimplementedReferenceServiceServer) mustEmbedUnimplementedReferenceServiceServer() {}
+func (UnimplementedReferenceServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeReferenceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReferenceServiceServer will
+// result in compilation errors.
+type UnsafeReferenceServiceServer interface {
+	mustEmbedUnimplementedReferenceServiceServer()
+}
+
+func RegisterReferenceServiceServer(s grpc.ServiceRegistrar, srv ReferenceServiceServer) {
+	// If the following call pancis, it indicates UnimplementedReferenceServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ReferenceService_ServiceDesc, srv)
+}
+
+func _ReferenceService_AddValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReferenceServiceServer).AddValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReferenceService_AddValues_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReferenceServiceServer).AddValues(ctx, req.(*AddValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReferenceService_RemoveValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReferenceServiceServer).RemoveValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReferenceService_RemoveValues_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReferenceServiceServer).RemoveValues(ctx, req.(*RemoveValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReferenceService_QueryCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryCacheRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReferenceServiceServer).QueryCache(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReferenceService_QueryCache_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReferenceServiceServer).QueryCache(ctx, req.(*QueryCacheRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReferenceService_WatchMatches_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMatchesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReferenceServiceServer).WatchMatches(m, &grpc.GenericServerStream[WatchMatchesRequest, MatchEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReferenceService_WatchMatchesServer = grpc.ServerStreamingServer[MatchEvent]
+
+// ReferenceService_ServiceDesc is the grpc.ServiceDesc for ReferenceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReferenceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kafkabridge.v1.ReferenceService",
+	HandlerType: (*ReferenceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddValues",
+			Handler:    _ReferenceService_AddValues_Handler,
+		},
+		{
+			MethodName: "RemoveValues",
+			Handler:    _ReferenceService_RemoveValues_Handler,
+		},
+		{
+			MethodName: "QueryCache",
+			Handler:    _ReferenceService_QueryCache_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMatches",
+			Handler:       _ReferenceService_WatchMatches_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "reference.proto",
+}